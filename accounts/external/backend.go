@@ -0,0 +1,293 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package external implements an account backend that forwards all signing
+// requests to an external signer process over JSON-RPC, so that private keys
+// never have to be loaded into the node itself.
+package external
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// ExternalScheme is the protocol scheme prefixing account and wallet URLs.
+const ExternalScheme = "extapi"
+
+// Request kinds sent along with every signing request, allowing the external
+// signer to apply different approval rules depending on what is being signed.
+const (
+	KindTransaction = "transaction" // Regular user transaction
+	KindConsensus   = "consensus"   // Consensus engine seal or message
+	KindData        = "data"        // Arbitrary data hash
+)
+
+var (
+	// ErrNotSupported is returned for wallet operations that only make sense for
+	// local or hierarchical deterministic wallets.
+	ErrNotSupported = errors.New("operation not supported on external signers")
+
+	// ErrSignerUnavailable is returned if the external signer cannot be reached.
+	ErrSignerUnavailable = errors.New("external signer unavailable")
+)
+
+// SignContext is attached to every request forwarded to the external signer, so
+// that the user (or the signer's rule engine) has enough information to decide
+// whether to approve or reject it.
+type SignContext struct {
+	Kind        string `json:"kind"`                  // Category of the request (transaction, consensus, ...)
+	Description string `json:"description,omitempty"` // Human readable explanation of the request
+}
+
+// ExternalBackend is an accounts.Backend exposing a single wallet backed by an
+// external signer.
+type ExternalBackend struct {
+	signers []accounts.Wallet
+}
+
+// NewExternalBackend connects to the external signer at the given endpoint and
+// creates a backend around it.
+func NewExternalBackend(endpoint string) (*ExternalBackend, error) {
+	signer, err := NewExternalSigner(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	return &ExternalBackend{
+		signers: []accounts.Wallet{signer},
+	}, nil
+}
+
+// Wallets implements accounts.Backend, returning the single external signer.
+func (eb *ExternalBackend) Wallets() []accounts.Wallet {
+	return eb.signers
+}
+
+// Subscribe implements accounts.Backend. External signers never arrive or
+// depart during the lifetime of the backend, so no events are ever fired.
+func (eb *ExternalBackend) Subscribe(sink chan<- accounts.WalletEvent) event.Subscription {
+	return event.NewSubscription(func(quit <-chan struct{}) error {
+		<-quit
+		return nil
+	})
+}
+
+// ExternalSigner is an accounts.Wallet whose accounts and keys are managed by a
+// separate process, reachable over a JSON-RPC endpoint (IPC socket or HTTP).
+type ExternalSigner struct {
+	client   *rpc.Client
+	endpoint string
+	status   string
+
+	cache   []accounts.Account // Last known list of accounts served by the signer
+	cacheMu sync.RWMutex
+}
+
+// NewExternalSigner dials the external signer at the given endpoint.
+func NewExternalSigner(endpoint string) (*ExternalSigner, error) {
+	client, err := rpc.Dial(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	return newExternalSigner(client, endpoint), nil
+}
+
+// newExternalSigner wraps an already established RPC client into a wallet.
+func newExternalSigner(client *rpc.Client, endpoint string) *ExternalSigner {
+	signer := &ExternalSigner{
+		client:   client,
+		endpoint: endpoint,
+	}
+	// Query the version to ensure the other side speaks the protocol
+	var version string
+	if err := client.Call(&version, "account_version"); err != nil {
+		signer.status = fmt.Sprintf("unreachable: %v", err)
+	} else {
+		signer.status = fmt.Sprintf("ok [version=%v]", version)
+	}
+	return signer
+}
+
+// URL implements accounts.Wallet, returning the URL of the external signer.
+func (api *ExternalSigner) URL() accounts.URL {
+	return accounts.URL{
+		Scheme: ExternalScheme,
+		Path:   api.endpoint,
+	}
+}
+
+// Status implements accounts.Wallet, returning the last known connection status.
+func (api *ExternalSigner) Status() (string, error) {
+	return api.status, nil
+}
+
+// Open implements accounts.Wallet. The connection is established on creation,
+// so this is a noop.
+func (api *ExternalSigner) Open(passphrase string) error {
+	return nil
+}
+
+// Close implements accounts.Wallet, tearing down the connection to the signer.
+func (api *ExternalSigner) Close() error {
+	api.client.Close()
+	return nil
+}
+
+// Accounts implements accounts.Wallet, retrieving the list of accounts the
+// external signer is willing to expose to this node.
+func (api *ExternalSigner) Accounts() []accounts.Account {
+	var res []common.Address
+	if err := api.client.Call(&res, "account_list"); err != nil {
+		log.Warn("Failed to list external signer accounts", "endpoint", api.endpoint, "err", err)
+
+		api.cacheMu.RLock()
+		defer api.cacheMu.RUnlock()
+		return api.cache
+	}
+	accs := make([]accounts.Account, 0, len(res))
+	for _, addr := range res {
+		accs = append(accs, accounts.Account{
+			Address: addr,
+			URL:     api.URL(),
+		})
+	}
+	api.cacheMu.Lock()
+	api.cache = accs
+	api.cacheMu.Unlock()
+
+	return accs
+}
+
+// Contains implements accounts.Wallet, returning whether a particular account is
+// or is not served by the external signer.
+func (api *ExternalSigner) Contains(account accounts.Account) bool {
+	api.cacheMu.RLock()
+	cache := api.cache
+	api.cacheMu.RUnlock()
+
+	if cache == nil {
+		cache = api.Accounts()
+	}
+	for _, a := range cache {
+		if a.Address == account.Address && (account.URL == (accounts.URL{}) || account.URL == api.URL()) {
+			return true
+		}
+	}
+	return false
+}
+
+// Derive implements accounts.Wallet, but is not supported by external signers.
+func (api *ExternalSigner) Derive(path accounts.DerivationPath, pin bool) (accounts.Account, error) {
+	return accounts.Account{}, ErrNotSupported
+}
+
+// SelfDerive implements accounts.Wallet, but is not supported by external signers.
+func (api *ExternalSigner) SelfDerive(base accounts.DerivationPath, chain ethereum.ChainStateReader) {
+	log.Error("Operation not supported on external signers")
+}
+
+// SignHash implements accounts.Wallet, forwarding the hash to the external
+// signer as a generic data signing request.
+func (api *ExternalSigner) SignHash(account accounts.Account, hash []byte) ([]byte, error) {
+	return api.SignHashWithContext(account, hash, SignContext{Kind: KindData})
+}
+
+// SignHashWithContext requests the external signer to sign the given hash on
+// behalf of account, passing ctx along to aid the approval decision.
+func (api *ExternalSigner) SignHashWithContext(account accounts.Account, hash []byte, ctx SignContext) ([]byte, error) {
+	var res hexutil.Bytes
+	if err := api.client.Call(&res, "account_signHash", account.Address, hexutil.Bytes(hash), ctx); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// SignHashFn returns a signer callback tagging every request with the given
+// kind, suitable for handing to subsystems (e.g. consensus engines) that sign
+// on their own behalf.
+func (api *ExternalSigner) SignHashFn(kind string) func(accounts.Account, []byte) ([]byte, error) {
+	return func(account accounts.Account, hash []byte) ([]byte, error) {
+		return api.SignHashWithContext(account, hash, SignContext{Kind: kind})
+	}
+}
+
+// SendTxArgs is the transaction representation sent to the external signer.
+type SendTxArgs struct {
+	From     common.Address  `json:"from"`
+	To       *common.Address `json:"to"`
+	Gas      hexutil.Uint64  `json:"gas"`
+	GasPrice hexutil.Big     `json:"gasPrice"`
+	Value    hexutil.Big     `json:"value"`
+	Nonce    hexutil.Uint64  `json:"nonce"`
+	Data     hexutil.Bytes   `json:"data"`
+}
+
+// SignTxResult is the response of the external signer to a transaction signing
+// request.
+type SignTxResult struct {
+	Raw hexutil.Bytes      `json:"raw"`
+	Tx  *types.Transaction `json:"tx"`
+}
+
+// SignTx implements accounts.Wallet, forwarding the transaction to the external
+// signer for approval and signing.
+func (api *ExternalSigner) SignTx(account accounts.Account, tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	args := &SendTxArgs{
+		From:     account.Address,
+		To:       tx.To(),
+		Gas:      hexutil.Uint64(tx.Gas()),
+		GasPrice: hexutil.Big(*tx.GasPrice()),
+		Value:    hexutil.Big(*tx.Value()),
+		Nonce:    hexutil.Uint64(tx.Nonce()),
+		Data:     tx.Data(),
+	}
+	var chain *hexutil.Big
+	if chainID != nil {
+		chain = (*hexutil.Big)(chainID)
+	}
+	ctx := SignContext{Kind: KindTransaction}
+
+	var res SignTxResult
+	if err := api.client.Call(&res, "account_signTransaction", args, chain, ctx); err != nil {
+		return nil, err
+	}
+	if res.Tx == nil {
+		return nil, ErrSignerUnavailable
+	}
+	return res.Tx, nil
+}
+
+// SignHashWithPassphrase implements accounts.Wallet. Passphrases are managed by
+// the external signer, so this is not supported.
+func (api *ExternalSigner) SignHashWithPassphrase(account accounts.Account, passphrase string, hash []byte) ([]byte, error) {
+	return nil, ErrNotSupported
+}
+
+// SignTxWithPassphrase implements accounts.Wallet. Passphrases are managed by
+// the external signer, so this is not supported.
+func (api *ExternalSigner) SignTxWithPassphrase(account accounts.Account, passphrase string, tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	return nil, ErrNotSupported
+}
@@ -0,0 +1,135 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package external
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// SignerAPI is a minimal in-process implementation of the external signer API.
+type SignerAPI struct {
+	key     *ecdsa.PrivateKey
+	address common.Address
+	kinds   []string
+}
+
+func (s *SignerAPI) Version() string { return "1.0.0" }
+
+func (s *SignerAPI) List() []common.Address { return []common.Address{s.address} }
+
+func (s *SignerAPI) SignHash(addr common.Address, hash hexutil.Bytes, ctx SignContext) (hexutil.Bytes, error) {
+	s.kinds = append(s.kinds, ctx.Kind)
+	if addr != s.address {
+		return nil, errors.New("unknown account")
+	}
+	return append([]byte{0x01}, hash...), nil
+}
+
+func (s *SignerAPI) SignTransaction(args SendTxArgs, chainID *hexutil.Big, ctx SignContext) (*SignTxResult, error) {
+	s.kinds = append(s.kinds, ctx.Kind)
+	tx := types.NewTransaction(uint64(args.Nonce), *args.To, args.Value.ToInt(), uint64(args.Gas), args.GasPrice.ToInt(), args.Data)
+	tx, err := types.SignTx(tx, types.NewEIP155Signer(chainID.ToInt()), s.key)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := rlp.EncodeToBytes(tx)
+	if err != nil {
+		return nil, err
+	}
+	return &SignTxResult{Raw: raw, Tx: tx}, nil
+}
+
+func newTestSigner(t *testing.T) (*SignerAPI, *ExternalSigner) {
+	key, _ := crypto.GenerateKey()
+	backend := &SignerAPI{key: key, address: crypto.PubkeyToAddress(key.PublicKey)}
+
+	server := rpc.NewServer()
+	if err := server.RegisterName("account", backend); err != nil {
+		t.Fatalf("failed to register signer: %v", err)
+	}
+	return backend, newExternalSigner(rpc.DialInProc(server), "inproc")
+}
+
+func TestExternalSignerAccounts(t *testing.T) {
+	backend, signer := newTestSigner(t)
+
+	if status, _ := signer.Status(); status != "ok [version=1.0.0]" {
+		t.Errorf("status mismatch: have %q", status)
+	}
+	accs := signer.Accounts()
+	if len(accs) != 1 || accs[0].Address != backend.address {
+		t.Fatalf("account list mismatch: have %v, want %x", accs, backend.address)
+	}
+	if !signer.Contains(accounts.Account{Address: backend.address}) {
+		t.Errorf("signer should contain its own account")
+	}
+	if signer.Contains(accounts.Account{Address: common.Address{0xff}}) {
+		t.Errorf("signer should not contain foreign account")
+	}
+}
+
+func TestExternalSignerSigning(t *testing.T) {
+	backend, signer := newTestSigner(t)
+	account := accounts.Account{Address: backend.address}
+
+	hash := crypto.Keccak256([]byte("hello"))
+	sig, err := signer.SignHash(account, hash)
+	if err != nil {
+		t.Fatalf("failed to sign hash: %v", err)
+	}
+	if !bytes.Equal(sig, append([]byte{0x01}, hash...)) {
+		t.Errorf("signature mismatch: have %x", sig)
+	}
+	if _, err := signer.SignHashFn(KindConsensus)(account, hash); err != nil {
+		t.Fatalf("failed to sign consensus hash: %v", err)
+	}
+	tx := types.NewTransaction(1, common.Address{0x01}, big.NewInt(2), 21000, big.NewInt(3), nil)
+	signed, err := signer.SignTx(account, tx, big.NewInt(1))
+	if err != nil {
+		t.Fatalf("failed to sign transaction: %v", err)
+	}
+	if signed.Nonce() != tx.Nonce() || signed.Value().Cmp(tx.Value()) != 0 {
+		t.Errorf("signed transaction mismatch: have %v, want %v", signed, tx)
+	}
+	if from, err := types.Sender(types.NewEIP155Signer(big.NewInt(1)), signed); err != nil || from != backend.address {
+		t.Errorf("signed transaction sender mismatch: have %x, want %x (err %v)", from, backend.address, err)
+	}
+	want := []string{KindData, KindConsensus, KindTransaction}
+	if len(backend.kinds) != len(want) {
+		t.Fatalf("request kinds mismatch: have %v, want %v", backend.kinds, want)
+	}
+	for i := range want {
+		if backend.kinds[i] != want[i] {
+			t.Errorf("request %d kind mismatch: have %s, want %s", i, backend.kinds[i], want[i])
+		}
+	}
+	if _, err := signer.SignHashWithPassphrase(account, "", hash); err != ErrNotSupported {
+		t.Errorf("passphrase signing error mismatch: have %v, want %v", err, ErrNotSupported)
+	}
+}
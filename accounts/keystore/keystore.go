@@ -44,8 +44,46 @@ var (
 	ErrLocked  = accounts.NewAuthNeededError("password or unlock")
 	ErrNoMatch = errors.New("no key for given address or file")
 	ErrDecrypt = errors.New("could not decrypt key with given passphrase")
+	ErrScope   = errors.New("account not unlocked for this use")
 )
 
+// UnlockScope restricts the use of an unlocked account. Empty fields leave the
+// respective use unrestricted.
+type UnlockScope struct {
+	Namespaces []string         `json:"namespaces"` // RPC namespaces which may sign with the account
+	Targets    []common.Address `json:"targets"`    // Recipients of the transactions the account may sign
+}
+
+// allowsNamespace returns whether the account may sign for the RPC namespace.
+func (s *UnlockScope) allowsNamespace(namespace string) bool {
+	if s == nil || len(s.Namespaces) == 0 {
+		return true
+	}
+	for _, ns := range s.Namespaces {
+		if ns == namespace {
+			return true
+		}
+	}
+	return false
+}
+
+// allowsTarget returns whether the account may sign a transaction to the given
+// recipient, nil being a contract creation.
+func (s *UnlockScope) allowsTarget(to *common.Address) bool {
+	if s == nil || len(s.Targets) == 0 {
+		return true
+	}
+	if to == nil {
+		return false
+	}
+	for _, target := range s.Targets {
+		if target == *to {
+			return true
+		}
+	}
+	return false
+}
+
 // KeyStoreType is the reflect type of a keystore backend.
 var KeyStoreType = reflect.TypeOf(&KeyStore{})
 
@@ -73,12 +111,19 @@ type KeyStore struct {
 type unlocked struct {
 	*Key
 	abort chan struct{}
+	scope *UnlockScope // nil if unrestricted
 }
 
 // NewKeyStore creates a keystore for the given directory.
 func NewKeyStore(keydir string, scryptN, scryptP int) *KeyStore {
+	return NewKeyStoreWithKDF(keydir, KDFParams{KDF: keyHeaderKDF, ScryptN: scryptN, ScryptP: scryptP})
+}
+
+// NewKeyStoreWithKDF creates a keystore for the given directory, encrypting new
+// and updated keys with the given KDF.
+func NewKeyStoreWithKDF(keydir string, kdf KDFParams) *KeyStore {
 	keydir, _ = filepath.Abs(keydir)
-	ks := &KeyStore{storage: &keyStorePassphrase{keydir, scryptN, scryptP}}
+	ks := &KeyStore{storage: &keyStorePassphrase{keydir, kdf}}
 	ks.init(keydir)
 	return ks
 }
@@ -263,6 +308,10 @@ func (ks *KeyStore) SignHash(a accounts.Account, hash []byte) ([]byte, error) {
 	if !found {
 		return nil, ErrLocked
 	}
+	// Arbitrary hashes might be those of transactions to any recipient
+	if unlockedKey.scope != nil && len(unlockedKey.scope.Targets) > 0 {
+		return nil, ErrScope
+	}
 	// Sign the hash using plain ECDSA operations
 	return crypto.Sign(hash, unlockedKey.PrivateKey)
 }
@@ -277,6 +326,9 @@ func (ks *KeyStore) SignTx(a accounts.Account, tx *types.Transaction, chainID *b
 	if !found {
 		return nil, ErrLocked
 	}
+	if !unlockedKey.scope.allowsTarget(tx.To()) {
+		return nil, ErrScope
+	}
 	// Depending on the presence of the chain ID, sign with EIP155 or homestead
 	if chainID != nil {
 		return types.SignTx(tx, types.NewEIP155Signer(chainID), unlockedKey.PrivateKey)
@@ -337,6 +389,15 @@ func (ks *KeyStore) Lock(addr common.Address) error {
 // shortens the active unlock timeout. If the address was previously unlocked
 // indefinitely the timeout is not altered.
 func (ks *KeyStore) TimedUnlock(a accounts.Account, passphrase string, timeout time.Duration) error {
+	return ks.ScopedUnlock(a, passphrase, timeout, nil)
+}
+
+// ScopedUnlock unlocks the given account like TimedUnlock, restricting its use
+// to the given scope. A nil scope doesn't restrict the account.
+//
+// If the address was previously unlocked indefinitely, its timeout is not
+// altered but the scope is replaced.
+func (ks *KeyStore) ScopedUnlock(a accounts.Account, passphrase string, timeout time.Duration, scope *UnlockScope) error {
 	a, key, err := ks.getDecryptedKey(a, passphrase)
 	if err != nil {
 		return err
@@ -350,21 +411,34 @@ func (ks *KeyStore) TimedUnlock(a accounts.Account, passphrase string, timeout t
 			// The address was unlocked indefinitely, so unlocking
 			// it with a timeout would be confusing.
 			zeroKey(key.PrivateKey)
+			u.scope = scope
 			return nil
 		}
 		// Terminate the expire goroutine and replace it below.
 		close(u.abort)
 	}
 	if timeout > 0 {
-		u = &unlocked{Key: key, abort: make(chan struct{})}
+		u = &unlocked{Key: key, abort: make(chan struct{}), scope: scope}
 		go ks.expire(a.Address, u, timeout)
 	} else {
-		u = &unlocked{Key: key}
+		u = &unlocked{Key: key, scope: scope}
 	}
 	ks.unlocked[a.Address] = u
 	return nil
 }
 
+// CheckScope returns ErrScope if the account is unlocked for other RPC
+// namespaces than the given one.
+func (ks *KeyStore) CheckScope(addr common.Address, namespace string) error {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	if u, found := ks.unlocked[addr]; found && !u.scope.allowsNamespace(namespace) {
+		return ErrScope
+	}
+	return nil
+}
+
 // Find resolves the given account into a unique entry in the keystore.
 func (ks *KeyStore) Find(a accounts.Account) (accounts.Account, error) {
 	ks.cache.maybeReload()
@@ -423,13 +497,11 @@ func (ks *KeyStore) Export(a accounts.Account, passphrase, newPassphrase string)
 	if err != nil {
 		return nil, err
 	}
-	var N, P int
+	kdf := StandardKDF
 	if store, ok := ks.storage.(*keyStorePassphrase); ok {
-		N, P = store.scryptN, store.scryptP
-	} else {
-		N, P = StandardScryptN, StandardScryptP
+		kdf = store.kdf
 	}
-	return EncryptKey(key, newPassphrase, N, P)
+	return EncryptKeyWithKDF(key, newPassphrase, kdf)
 }
 
 // Import stores the given encrypted JSON key into the key directory.
@@ -32,6 +32,7 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"path/filepath"
@@ -41,12 +42,18 @@ import (
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/crypto/randentropy"
 	"github.com/pborman/uuid"
+	"golang.org/x/crypto/argon2"
 	"golang.org/x/crypto/pbkdf2"
 	"golang.org/x/crypto/scrypt"
 )
 
 const (
-	keyHeaderKDF = "scrypt"
+	keyHeaderKDF         = "scrypt"
+	keyHeaderKDFArgon2id = "argon2id"
+
+	// versionArgon2id is the version of key files encrypted with argon2id, so
+	// clients unaware of the KDF reject them by their version.
+	versionArgon2id = 4
 
 	// StandardScryptN is the N parameter of Scrypt encryption algorithm, using 256MB
 	// memory and taking approximately 1s CPU time on a modern processor.
@@ -64,14 +71,100 @@ const (
 	// memory and taking approximately 100ms CPU time on a modern processor.
 	LightScryptP = 6
 
+	// StandardArgon2Time, StandardArgon2Memory and StandardArgon2Threads are the
+	// argon2id parameters using 256MB memory and taking approximately 1s CPU time
+	// on a modern processor.
+	StandardArgon2Time    = 3
+	StandardArgon2Memory  = 256 * 1024 // KiB
+	StandardArgon2Threads = 4
+
+	// LightArgon2Time, LightArgon2Memory and LightArgon2Threads are the argon2id
+	// parameters using 4MB memory and taking approximately 10ms CPU time on a
+	// modern processor.
+	LightArgon2Time    = 1
+	LightArgon2Memory  = 4 * 1024 // KiB
+	LightArgon2Threads = 1
+
 	scryptR     = 8
 	scryptDKLen = 32
+
+	argon2DKLen     = 32
+	maxArgon2Memory = 4 * 1024 * 1024 // KiB a key file may demand for decryption
+)
+
+// KDFParams selects the key derivation function keys are encrypted with, scrypt
+// or argon2id, and its parameters. Keys are decrypted with the KDF and the
+// parameters recorded in their key file.
+type KDFParams struct {
+	KDF string `toml:",omitempty"` // "scrypt" (default) or "argon2id"
+
+	ScryptN int `toml:",omitempty"`
+	ScryptP int `toml:",omitempty"`
+
+	Argon2Time    uint32 `toml:",omitempty"` // number of passes over the memory
+	Argon2Memory  uint32 `toml:",omitempty"` // KiB of memory
+	Argon2Threads uint8  `toml:",omitempty"`
+}
+
+var (
+	// StandardKDF holds the standard parameters of the supported KDFs,
+	// selecting scrypt.
+	StandardKDF = KDFParams{
+		KDF:           keyHeaderKDF,
+		ScryptN:       StandardScryptN,
+		ScryptP:       StandardScryptP,
+		Argon2Time:    StandardArgon2Time,
+		Argon2Memory:  StandardArgon2Memory,
+		Argon2Threads: StandardArgon2Threads,
+	}
+
+	// LightKDF holds the light parameters of the supported KDFs, selecting
+	// scrypt.
+	LightKDF = KDFParams{
+		KDF:           keyHeaderKDF,
+		ScryptN:       LightScryptN,
+		ScryptP:       LightScryptP,
+		Argon2Time:    LightArgon2Time,
+		Argon2Memory:  LightArgon2Memory,
+		Argon2Threads: LightArgon2Threads,
+	}
 )
 
+// Validate checks the KDF is supported and its parameters are usable.
+func (p KDFParams) Validate() error {
+	switch p.KDF {
+	case "", keyHeaderKDF:
+		if p.ScryptN <= 1 || p.ScryptN&(p.ScryptN-1) != 0 {
+			return fmt.Errorf("scrypt N must be a power of two greater than 1, have %d", p.ScryptN)
+		}
+		if p.ScryptP < 1 {
+			return fmt.Errorf("scrypt P must be positive, have %d", p.ScryptP)
+		}
+	case keyHeaderKDFArgon2id:
+		return validateArgon2(int(p.Argon2Time), int(p.Argon2Memory), int(p.Argon2Threads))
+	default:
+		return fmt.Errorf("unsupported KDF %q", p.KDF)
+	}
+	return nil
+}
+
+func validateArgon2(time, memory, threads int) error {
+	switch {
+	case time < 1:
+		return errors.New("argon2id time must be positive")
+	case threads < 1 || threads > 255:
+		return fmt.Errorf("argon2id threads must be between 1 and 255, have %d", threads)
+	case memory < 8*threads:
+		return fmt.Errorf("argon2id memory must be at least 8KiB per thread, have %dKiB", memory)
+	case memory > maxArgon2Memory:
+		return fmt.Errorf("argon2id memory exceeds %dKiB, have %dKiB", maxArgon2Memory, memory)
+	}
+	return nil
+}
+
 type keyStorePassphrase struct {
 	keysDirPath string
-	scryptN     int
-	scryptP     int
+	kdf         KDFParams
 }
 
 func (ks keyStorePassphrase) GetKey(addr common.Address, filename, auth string) (*Key, error) {
@@ -93,12 +186,18 @@ func (ks keyStorePassphrase) GetKey(addr common.Address, filename, auth string)
 
 // StoreKey generates a key, encrypts with 'auth' and stores in the given directory
 func StoreKey(dir, auth string, scryptN, scryptP int) (common.Address, error) {
-	_, a, err := storeNewKey(&keyStorePassphrase{dir, scryptN, scryptP}, crand.Reader, auth)
+	return StoreKeyWithKDF(dir, auth, KDFParams{KDF: keyHeaderKDF, ScryptN: scryptN, ScryptP: scryptP})
+}
+
+// StoreKeyWithKDF generates a key, encrypts it with 'auth' using the given KDF
+// and stores it in the given directory.
+func StoreKeyWithKDF(dir, auth string, kdf KDFParams) (common.Address, error) {
+	_, a, err := storeNewKey(&keyStorePassphrase{dir, kdf}, crand.Reader, auth)
 	return a.Address, err
 }
 
 func (ks keyStorePassphrase) StoreKey(filename string, key *Key, auth string) error {
-	keyjson, err := EncryptKey(key, auth, ks.scryptN, ks.scryptP)
+	keyjson, err := EncryptKeyWithKDF(key, auth, ks.kdf)
 	if err != nil {
 		return err
 	}
@@ -116,12 +215,49 @@ func (ks keyStorePassphrase) JoinPath(filename string) string {
 // EncryptKey encrypts a key using the specified scrypt parameters into a json
 // blob that can be decrypted later on.
 func EncryptKey(key *Key, auth string, scryptN, scryptP int) ([]byte, error) {
+	return EncryptKeyWithKDF(key, auth, KDFParams{KDF: keyHeaderKDF, ScryptN: scryptN, ScryptP: scryptP})
+}
+
+// EncryptKeyWithKDF encrypts a key using the specified KDF into a json blob that
+// can be decrypted later on. Keys encrypted with argon2id are of version 4.
+func EncryptKeyWithKDF(key *Key, auth string, kdf KDFParams) ([]byte, error) {
 	authArray := []byte(auth)
 	salt := randentropy.GetEntropyCSPRNG(32)
-	derivedKey, err := scrypt.Key(authArray, salt, scryptN, scryptR, scryptP, scryptDKLen)
-	if err != nil {
-		return nil, err
+
+	var (
+		derivedKey []byte
+		err        error
+	)
+	kdfParamsJSON := make(map[string]interface{}, 5)
+	keyVersion := version
+	switch kdf.KDF {
+	case "", keyHeaderKDF:
+		kdf.KDF = keyHeaderKDF
+		derivedKey, err = scrypt.Key(authArray, salt, kdf.ScryptN, scryptR, kdf.ScryptP, scryptDKLen)
+		if err != nil {
+			return nil, err
+		}
+		kdfParamsJSON["n"] = kdf.ScryptN
+		kdfParamsJSON["r"] = scryptR
+		kdfParamsJSON["p"] = kdf.ScryptP
+		kdfParamsJSON["dklen"] = scryptDKLen
+
+	case keyHeaderKDFArgon2id:
+		if err := kdf.Validate(); err != nil {
+			return nil, err
+		}
+		derivedKey = argon2.IDKey(authArray, salt, kdf.Argon2Time, kdf.Argon2Memory, kdf.Argon2Threads, argon2DKLen)
+		kdfParamsJSON["t"] = kdf.Argon2Time
+		kdfParamsJSON["m"] = kdf.Argon2Memory
+		kdfParamsJSON["p"] = kdf.Argon2Threads
+		kdfParamsJSON["dklen"] = argon2DKLen
+		keyVersion = versionArgon2id
+
+	default:
+		return nil, fmt.Errorf("Unsupported KDF: %s", kdf.KDF)
 	}
+	kdfParamsJSON["salt"] = hex.EncodeToString(salt)
+
 	encryptKey := derivedKey[:16]
 	keyBytes := math.PaddedBigBytes(key.PrivateKey.D, 32)
 
@@ -132,13 +268,6 @@ func EncryptKey(key *Key, auth string, scryptN, scryptP int) ([]byte, error) {
 	}
 	mac := crypto.Keccak256(derivedKey[16:32], cipherText)
 
-	scryptParamsJSON := make(map[string]interface{}, 5)
-	scryptParamsJSON["n"] = scryptN
-	scryptParamsJSON["r"] = scryptR
-	scryptParamsJSON["p"] = scryptP
-	scryptParamsJSON["dklen"] = scryptDKLen
-	scryptParamsJSON["salt"] = hex.EncodeToString(salt)
-
 	cipherParamsJSON := cipherparamsJSON{
 		IV: hex.EncodeToString(iv),
 	}
@@ -147,15 +276,15 @@ func EncryptKey(key *Key, auth string, scryptN, scryptP int) ([]byte, error) {
 		Cipher:       "aes-128-ctr",
 		CipherText:   hex.EncodeToString(cipherText),
 		CipherParams: cipherParamsJSON,
-		KDF:          keyHeaderKDF,
-		KDFParams:    scryptParamsJSON,
+		KDF:          kdf.KDF,
+		KDFParams:    kdfParamsJSON,
 		MAC:          hex.EncodeToString(mac),
 	}
 	encryptedKeyJSONV3 := encryptedKeyJSONV3{
 		hex.EncodeToString(key.Address[:]),
 		cryptoStruct,
 		key.Id.String(),
-		version,
+		keyVersion,
 	}
 	return json.Marshal(encryptedKeyJSONV3)
 }
@@ -199,9 +328,12 @@ func DecryptKey(keyjson []byte, auth string) (*Key, error) {
 }
 
 func decryptKeyV3(keyProtected *encryptedKeyJSONV3, auth string) (keyBytes []byte, keyId []byte, err error) {
-	if keyProtected.Version != version {
+	if keyProtected.Version != version && keyProtected.Version != versionArgon2id {
 		return nil, nil, fmt.Errorf("Version not supported: %v", keyProtected.Version)
 	}
+	if (keyProtected.Version == versionArgon2id) != (keyProtected.Crypto.KDF == keyHeaderKDFArgon2id) {
+		return nil, nil, fmt.Errorf("KDF not supported in version %d: %v", keyProtected.Version, keyProtected.Crypto.KDF)
+	}
 
 	if keyProtected.Crypto.Cipher != "aes-128-ctr" {
 		return nil, nil, fmt.Errorf("Cipher not supported: %v", keyProtected.Crypto.Cipher)
@@ -296,6 +428,18 @@ func getKDFKey(cryptoJSON cryptoJSON, auth string) ([]byte, error) {
 		}
 		key := pbkdf2.Key(authArray, salt, c, dkLen, sha256.New)
 		return key, nil
+
+	} else if cryptoJSON.KDF == keyHeaderKDFArgon2id {
+		t := ensureInt(cryptoJSON.KDFParams["t"])
+		m := ensureInt(cryptoJSON.KDFParams["m"])
+		p := ensureInt(cryptoJSON.KDFParams["p"])
+		if err := validateArgon2(t, m, p); err != nil {
+			return nil, err
+		}
+		if dkLen < argon2DKLen {
+			return nil, fmt.Errorf("Invalid argon2id key length: %d", dkLen)
+		}
+		return argon2.IDKey(authArray, salt, uint32(t), uint32(m), uint8(p), uint32(dkLen)), nil
 	}
 
 	return nil, fmt.Errorf("Unsupported KDF: %s", cryptoJSON.KDF)
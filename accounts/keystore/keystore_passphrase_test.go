@@ -17,7 +17,11 @@
 package keystore
 
 import (
+	crand "crypto/rand"
+	"encoding/json"
 	"io/ioutil"
+	"os"
+	"strings"
 	"testing"
 
 	"github.com/ethereum/go-ethereum/common"
@@ -28,6 +32,8 @@ const (
 	veryLightScryptP = 1
 )
 
+var veryLightArgon2 = KDFParams{KDF: "argon2id", Argon2Time: 1, Argon2Memory: 64, Argon2Threads: 1}
+
 // Tests that a json key file can be decrypted and encrypted in multiple rounds.
 func TestKeyEncryptDecrypt(t *testing.T) {
 	keyjson, err := ioutil.ReadFile("testdata/very-light-scrypt.json")
@@ -58,3 +64,91 @@ func TestKeyEncryptDecrypt(t *testing.T) {
 		}
 	}
 }
+
+// Tests that keys encrypted with argon2id are written as version 4 key files,
+// which can be decrypted again.
+func TestKeyEncryptDecryptArgon2id(t *testing.T) {
+	key, err := newKey(crand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyjson, err := EncryptKeyWithKDF(key, "password", veryLightArgon2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var k encryptedKeyJSONV3
+	if err := json.Unmarshal(keyjson, &k); err != nil {
+		t.Fatal(err)
+	}
+	if k.Version != versionArgon2id || k.Crypto.KDF != "argon2id" {
+		t.Fatalf("key file mismatch: have version %d kdf %s, want version %d kdf argon2id", k.Version, k.Crypto.KDF, versionArgon2id)
+	}
+	if _, err := DecryptKey(keyjson, "bad"); err != ErrDecrypt {
+		t.Errorf("bad password error mismatch: have %v, want %v", err, ErrDecrypt)
+	}
+	decrypted, err := DecryptKey(keyjson, "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decrypted.Address != key.Address {
+		t.Errorf("key address mismatch: have %x, want %x", decrypted.Address, key.Address)
+	}
+
+	// argon2id keys must not claim an older version
+	keyjson = []byte(strings.Replace(string(keyjson), `"version":4`, `"version":3`, 1))
+	if _, err := DecryptKey(keyjson, "password"); err == nil {
+		t.Errorf("version 3 argon2id key decrypted")
+	}
+}
+
+// Tests that invalid KDF parameters are rejected.
+func TestKDFParamsValidate(t *testing.T) {
+	for _, p := range []KDFParams{
+		{KDF: "bcrypt"},
+		{KDF: "scrypt", ScryptN: 3, ScryptP: 1},
+		{KDF: "scrypt", ScryptN: 2, ScryptP: 0},
+		{KDF: "argon2id", Argon2Time: 0, Argon2Memory: 64, Argon2Threads: 1},
+		{KDF: "argon2id", Argon2Time: 1, Argon2Memory: 64, Argon2Threads: 0},
+		{KDF: "argon2id", Argon2Time: 1, Argon2Memory: 8, Argon2Threads: 2},
+		{KDF: "argon2id", Argon2Time: 1, Argon2Memory: maxArgon2Memory + 1, Argon2Threads: 1},
+	} {
+		if err := p.Validate(); err == nil {
+			t.Errorf("invalid params %+v validated", p)
+		}
+	}
+	for _, p := range []KDFParams{StandardKDF, LightKDF, veryLightArgon2} {
+		if err := p.Validate(); err != nil {
+			t.Errorf("params %+v failed to validate: %v", p, err)
+		}
+	}
+}
+
+// Tests that updating a key with the same passphrase migrates it to the KDF of
+// the key store.
+func TestKeyStoreMigrateKDF(t *testing.T) {
+	dir, ks := tmpKeyStore(t, true)
+	defer os.RemoveAll(dir)
+
+	a, err := ks.NewAccount("password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ks = NewKeyStoreWithKDF(dir, veryLightArgon2)
+	if err := ks.Update(a, "password", "password"); err != nil {
+		t.Fatal(err)
+	}
+	keyjson, err := ioutil.ReadFile(a.URL.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var k encryptedKeyJSONV3
+	if err := json.Unmarshal(keyjson, &k); err != nil {
+		t.Fatal(err)
+	}
+	if k.Version != versionArgon2id || k.Crypto.KDF != "argon2id" {
+		t.Fatalf("key file not migrated: have version %d kdf %s", k.Version, k.Crypto.KDF)
+	}
+	if err := ks.Unlock(a, "password"); err != nil {
+		t.Fatal(err)
+	}
+}
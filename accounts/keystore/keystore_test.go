@@ -18,6 +18,7 @@ package keystore
 
 import (
 	"io/ioutil"
+	"math/big"
 	"math/rand"
 	"os"
 	"runtime"
@@ -28,6 +29,7 @@ import (
 
 	"github.com/ethereum/go-ethereum/accounts"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/event"
 )
 
@@ -148,6 +150,53 @@ func TestTimedUnlock(t *testing.T) {
 	}
 }
 
+// Tests that accounts unlocked with a scope only sign within it.
+func TestScopedUnlock(t *testing.T) {
+	dir, ks := tmpKeyStore(t, true)
+	defer os.RemoveAll(dir)
+
+	pass := "foo"
+	a1, err := ks.NewAccount(pass)
+	if err != nil {
+		t.Fatal(err)
+	}
+	target, other := common.Address{1}, common.Address{2}
+	scope := &UnlockScope{Namespaces: []string{"eth"}, Targets: []common.Address{target}}
+	if err := ks.ScopedUnlock(a1, pass, 0, scope); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ks.SignTx(a1, types.NewTransaction(0, target, big.NewInt(1), 21000, big.NewInt(1), nil), nil); err != nil {
+		t.Errorf("signing a transaction to an allowed target failed: %v", err)
+	}
+	if _, err := ks.SignTx(a1, types.NewTransaction(0, other, big.NewInt(1), 21000, big.NewInt(1), nil), nil); err != ErrScope {
+		t.Errorf("signing a transaction to another target error mismatch: have %v, want %v", err, ErrScope)
+	}
+	if _, err := ks.SignTx(a1, types.NewContractCreation(0, big.NewInt(0), 100000, big.NewInt(1), nil), nil); err != ErrScope {
+		t.Errorf("signing a contract creation error mismatch: have %v, want %v", err, ErrScope)
+	}
+	if _, err := ks.SignHash(a1, testSigData); err != ErrScope {
+		t.Errorf("signing a hash error mismatch: have %v, want %v", err, ErrScope)
+	}
+	if err := ks.CheckScope(a1.Address, "eth"); err != nil {
+		t.Errorf("allowed namespace rejected: %v", err)
+	}
+	if err := ks.CheckScope(a1.Address, "personal"); err != ErrScope {
+		t.Errorf("other namespace error mismatch: have %v, want %v", err, ErrScope)
+	}
+
+	// Unlocking without a scope lifts the restrictions
+	if err := ks.Unlock(a1, pass); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ks.SignHash(a1, testSigData); err != nil {
+		t.Errorf("signing a hash failed after unscoped unlock: %v", err)
+	}
+	if err := ks.CheckScope(a1.Address, "personal"); err != nil {
+		t.Errorf("namespace rejected after unscoped unlock: %v", err)
+	}
+}
+
 func TestOverrideUnlock(t *testing.T) {
 	dir, ks := tmpKeyStore(t, false)
 	defer os.RemoveAll(dir)
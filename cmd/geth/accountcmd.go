@@ -54,6 +54,12 @@ passwordfile as argument containing the wallet password in plaintext.`,
 					utils.KeyStoreDirFlag,
 					utils.PasswordFileFlag,
 					utils.LightKDFFlag,
+					utils.KDFFlag,
+					utils.KDFScryptNFlag,
+					utils.KDFScryptPFlag,
+					utils.KDFArgon2TimeFlag,
+					utils.KDFArgon2MemoryFlag,
+					utils.KDFArgon2ThreadsFlag,
 				},
 				Description: `
 	geth wallet [options] /path/to/my/presale.wallet
@@ -110,6 +116,12 @@ Print a short summary of all accounts`,
 					utils.KeyStoreDirFlag,
 					utils.PasswordFileFlag,
 					utils.LightKDFFlag,
+					utils.KDFFlag,
+					utils.KDFScryptNFlag,
+					utils.KDFScryptPFlag,
+					utils.KDFArgon2TimeFlag,
+					utils.KDFArgon2MemoryFlag,
+					utils.KDFArgon2ThreadsFlag,
 				},
 				Description: `
     geth account new
@@ -135,6 +147,12 @@ password to file or expose in any other way.
 					utils.DataDirFlag,
 					utils.KeyStoreDirFlag,
 					utils.LightKDFFlag,
+					utils.KDFFlag,
+					utils.KDFScryptNFlag,
+					utils.KDFScryptPFlag,
+					utils.KDFArgon2TimeFlag,
+					utils.KDFArgon2MemoryFlag,
+					utils.KDFArgon2ThreadsFlag,
 				},
 				Description: `
     geth account update <address>
@@ -153,6 +171,40 @@ For non-interactive use the passphrase can be specified with the --password flag
 
 Since only one password can be given, only format update can be performed,
 changing your password is only possible interactively.
+`,
+			},
+			{
+				Name:      "migrate",
+				Usage:     "Re-encrypt accounts with the configured key-derivation function",
+				Action:    utils.MigrateFlags(accountMigrate),
+				ArgsUsage: "[<address> ...]",
+				Flags: []cli.Flag{
+					utils.DataDirFlag,
+					utils.KeyStoreDirFlag,
+					utils.PasswordFileFlag,
+					utils.LightKDFFlag,
+					utils.KDFFlag,
+					utils.KDFScryptNFlag,
+					utils.KDFScryptPFlag,
+					utils.KDFArgon2TimeFlag,
+					utils.KDFArgon2MemoryFlag,
+					utils.KDFArgon2ThreadsFlag,
+				},
+				Description: `
+    geth account migrate [options] [<address> ...]
+
+Re-encrypts the given accounts, or all accounts if none are given, with the
+key-derivation function and parameters selected by the --kdf flags, keeping
+their passwords. You are prompted for the password of each account.
+
+    geth account migrate --kdf argon2id
+
+migrates all accounts to the argon2id key file format (version 4), which
+only clients supporting argon2id can decrypt. Migrating with --kdf scrypt
+converts them back to the standard version 3 format.
+
+For non-interactive use the passwords can be specified with the --password
+flag, one per line in the order of the accounts.
 `,
 			},
 			{
@@ -164,6 +216,12 @@ changing your password is only possible interactively.
 					utils.KeyStoreDirFlag,
 					utils.PasswordFileFlag,
 					utils.LightKDFFlag,
+					utils.KDFFlag,
+					utils.KDFScryptNFlag,
+					utils.KDFScryptPFlag,
+					utils.KDFArgon2TimeFlag,
+					utils.KDFArgon2MemoryFlag,
+					utils.KDFArgon2ThreadsFlag,
 				},
 				ArgsUsage: "<keyFile>",
 				Description: `
@@ -299,15 +357,19 @@ func accountCreate(ctx *cli.Context) error {
 		}
 	}
 	utils.SetNodeConfig(ctx, &cfg.Node)
-	scryptN, scryptP, keydir, err := cfg.Node.AccountConfig()
+	_, _, keydir, err := cfg.Node.AccountConfig()
 
 	if err != nil {
 		utils.Fatalf("Failed to read configuration: %v", err)
 	}
+	kdf := cfg.Node.KDFConfig()
+	if err := kdf.Validate(); err != nil {
+		utils.Fatalf("Invalid key-derivation function: %v", err)
+	}
 
 	password := getPassPhrase("Your new account is locked with a password. Please give a password. Do not forget this password.", true, 0, utils.MakePasswordList(ctx))
 
-	address, err := keystore.StoreKey(keydir, password, scryptN, scryptP)
+	address, err := keystore.StoreKeyWithKDF(keydir, password, kdf)
 
 	if err != nil {
 		utils.Fatalf("Failed to create account: %v", err)
@@ -335,6 +397,33 @@ func accountUpdate(ctx *cli.Context) error {
 	return nil
 }
 
+// accountMigrate re-encrypts accounts with the configured KDF, keeping their
+// passwords.
+func accountMigrate(ctx *cli.Context) error {
+	stack, cfg := makeConfigNode(ctx)
+	ks := stack.AccountManager().Backends(keystore.KeyStoreType)[0].(*keystore.KeyStore)
+
+	addrs := []string(ctx.Args())
+	if len(addrs) == 0 {
+		for _, account := range ks.Accounts() {
+			addrs = append(addrs, account.Address.Hex())
+		}
+	}
+	if len(addrs) == 0 {
+		utils.Fatalf("No accounts to migrate")
+	}
+	kdf := cfg.Node.KDFConfig().KDF
+	passwords := utils.MakePasswordList(ctx)
+	for i, addr := range addrs {
+		account, password := unlockAccount(ctx, ks, addr, i, passwords)
+		if err := ks.Update(account, password, password); err != nil {
+			utils.Fatalf("Could not migrate the account: %v", err)
+		}
+		fmt.Printf("Migrated account {%x} to %s\n", account.Address, kdf)
+	}
+	return nil
+}
+
 func importWallet(ctx *cli.Context) error {
 	keyfile := ctx.Args().First()
 	if len(keyfile) == 0 {
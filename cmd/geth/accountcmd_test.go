@@ -106,6 +106,32 @@ Repeat passphrase: {{.InputLine "foobar2"}}
 `)
 }
 
+func TestAccountMigrate(t *testing.T) {
+	datadir := tmpDatadirWithKeystore(t)
+	geth := runGeth(t, "account", "migrate",
+		"--datadir", datadir, "--kdf", "argon2id", "--kdf.argon2.memory", "64",
+		"f466859ead1932d743d622cb74fc058882e8648a")
+	geth.Expect(`
+Unlocking account f466859ead1932d743d622cb74fc058882e8648a | Attempt 1/3
+!! Unsupported terminal, password will be echoed.
+Passphrase: {{.InputLine "foobar"}}
+Migrated account {f466859ead1932d743d622cb74fc058882e8648a} to argon2id
+`)
+	geth.ExpectExit()
+
+	// the migrated account must unlock with the same password
+	geth = runGeth(t, "account", "migrate",
+		"--datadir", datadir, "--lightkdf",
+		"f466859ead1932d743d622cb74fc058882e8648a")
+	defer geth.ExpectExit()
+	geth.Expect(`
+Unlocking account f466859ead1932d743d622cb74fc058882e8648a | Attempt 1/3
+!! Unsupported terminal, password will be echoed.
+Passphrase: {{.InputLine "foobar"}}
+Migrated account {f466859ead1932d743d622cb74fc058882e8648a} to scrypt
+`)
+}
+
 func TestWalletImport(t *testing.T) {
 	geth := runGeth(t, "wallet", "import", "--lightkdf", "testdata/guswallet.json")
 	defer geth.ExpectExit()
@@ -27,14 +27,20 @@ import (
 
 	"github.com/ethereum/go-ethereum/cmd/utils"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/consensus/clique"
+	"github.com/ethereum/go-ethereum/consensus/istanbul"
+	istanbulBackend "github.com/ethereum/go-ethereum/consensus/istanbul/backend"
 	"github.com/ethereum/go-ethereum/console"
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/eth/downloader"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/event"
 	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/params"
 	"github.com/ethereum/go-ethereum/trie"
 	"github.com/syndtr/goleveldb/leveldb/util"
 	"gopkg.in/urfave/cli.v1"
@@ -69,7 +75,10 @@ It expects the genesis file as argument.`,
 			utils.LightModeFlag,
 			utils.GCModeFlag,
 			utils.CacheDatabaseFlag,
+			utils.CacheTrieFlag,
 			utils.CacheGCFlag,
+			utils.CacheAsyncIndexFlag,
+			utils.DBCompressionFlag,
 		},
 		Category: "BLOCKCHAIN COMMANDS",
 		Description: `
@@ -141,8 +150,79 @@ Remove blockchain and state databases`,
 The arguments are interpreted as block numbers or hashes.
 Use "ethereum dump 0" to dump the genesis block.`,
 	}
+	dbCommand = cli.Command{
+		Name:     "db",
+		Usage:    "Low level chain database operations",
+		Category: "BLOCKCHAIN COMMANDS",
+		Subcommands: []cli.Command{
+			{
+				Name:      "verify",
+				Usage:     "Verify the integrity of the canonical chain, optionally repairing it",
+				ArgsUsage: " ",
+				Action:    utils.MigrateFlags(verifyDB),
+				Flags: []cli.Flag{
+					configFileFlag,
+					utils.DataDirFlag,
+					utils.CacheFlag,
+					utils.LightModeFlag,
+					utils.TestnetFlag,
+					utils.RinkebyFlag,
+					dbVerifyFromFlag,
+					dbVerifyRepairFlag,
+				},
+				Description: `
+    geth db verify [--from <number>] [--repair]
+
+walks the canonical chain of a stopped node from the given block, by default
+the last checkpoint where the consensus engine persisted its voting snapshot,
+up to the head block. It validates the hash and number indexes, the links
+between the blocks, the transaction and receipt roots, the total difficulties,
+the transaction lookup entries and, on clique and istanbul chains, the seals.
+
+With --repair the total difficulties and the indexes which can be regenerated
+from the block data are rewritten, e.g. after power loss corrupted them. Broken
+block data is only reported; such databases need to be resynced.`,
+			},
+			{
+				Name:      "compress",
+				Usage:     "Convert the stored block bodies and receipts to the configured compression",
+				ArgsUsage: " ",
+				Action:    utils.MigrateFlags(compressDB),
+				Flags: []cli.Flag{
+					configFileFlag,
+					utils.DataDirFlag,
+					utils.CacheFlag,
+					utils.TestnetFlag,
+					utils.RinkebyFlag,
+					utils.DBCompressionFlag,
+				},
+				Description: `
+    geth db compress --db.compression <algorithm>
+
+rewrites the block bodies and receipts of a stopped node's database with the
+given compression ("none" or "snappy"), followed by a compaction to reclaim the
+freed space. Nodes read both compressed and plain entries, so the conversion is
+only needed to shrink (or expand) the data written before --db.compression was
+changed.`,
+			},
+		},
+	}
+
+	dbVerifyFromFlag = cli.Uint64Flag{
+		Name:  "from",
+		Usage: "Block number to start verifying from (default = last checkpoint)",
+	}
+	dbVerifyRepairFlag = cli.BoolFlag{
+		Name:  "repair",
+		Usage: "Rewrite the broken total difficulty and index entries",
+	}
 )
 
+// verifyCheckpointInterval is the number of blocks after which clique and
+// istanbul persist their voting snapshots, which allows verifying the seals
+// from such a checkpoint without replaying the votes before it.
+const verifyCheckpointInterval = 1024
+
 // initGenesis will initialise the given JSON format genesis file and writes it as
 // the zero'd block (i.e. genesis) or will fail hard if it can't succeed.
 func initGenesis(ctx *cli.Context) error {
@@ -411,3 +491,101 @@ func hashish(x string) bool {
 	_, err := strconv.Atoi(x)
 	return err != nil
 }
+
+// verifyDB walks the canonical chain in the database, reporting and optionally
+// repairing the problems found. It fails if any problem is left.
+func verifyDB(ctx *cli.Context) error {
+	stack, cfg := makeConfigNode(ctx)
+	chainDb := utils.MakeChainDatabase(ctx, stack)
+	defer chainDb.Close()
+
+	genesis := core.GetCanonicalHash(chainDb, 0)
+	if genesis == (common.Hash{}) {
+		utils.Fatalf("Chain database not initialized")
+	}
+	chainConfig, err := core.GetChainConfig(chainDb, genesis)
+	if err != nil {
+		utils.Fatalf("Failed to load chain config: %v", err)
+	}
+	engine := makeVerifyEngine(cfg, chainConfig, chainDb)
+	chain, err := core.NewHeaderChain(chainDb, chainConfig, engine, func() bool { return false })
+	if err != nil {
+		utils.Fatalf("Failed to load header chain: %v", err)
+	}
+	from := ctx.GlobalUint64(dbVerifyFromFlag.Name)
+	if !ctx.GlobalIsSet(dbVerifyFromFlag.Name) {
+		if head := chain.GetHeaderByHash(core.GetHeadBlockHash(chainDb)); head != nil {
+			from = head.Number.Uint64() - head.Number.Uint64()%verifyCheckpointInterval
+		}
+	}
+	if engine == nil {
+		log.Warn("Proof-of-work seals are not verified")
+	}
+	repair := ctx.GlobalBool(dbVerifyRepairFlag.Name)
+
+	log.Info("Verifying chain database", "from", from, "repair", repair)
+	start := time.Now()
+	result, err := core.VerifyChain(chainDb, chain, engine, from, repair)
+	if err != nil {
+		utils.Fatalf("Chain verification failed: %v", err)
+	}
+	for _, problem := range result.Problems {
+		fmt.Println(problem)
+	}
+	fmt.Printf("Verified blocks #%d-#%d in %v: %d blocks checked, %d without receipts, %d problems, %d unrepaired\n",
+		result.From, result.To, common.PrettyDuration(time.Since(start)), result.Checked, result.MissingReceipts, len(result.Problems), result.Unrepaired())
+
+	if result.Unrepaired() > 0 {
+		utils.Fatalf("Chain database is corrupted")
+	}
+	return nil
+}
+
+// makeVerifyEngine creates the consensus engine to verify the seals of a stopped
+// node's chain with, or nil on proof-of-work chains.
+func makeVerifyEngine(cfg gethConfig, chainConfig *params.ChainConfig, db ethdb.Database) consensus.Engine {
+	switch chainConfig.Engine() {
+	case params.EngineClique:
+		return clique.New(chainConfig.Clique, db)
+	case params.EngineIstanbul:
+		config := cfg.Eth.Istanbul
+		if chainConfig.Istanbul.Epoch != 0 {
+			config.Epoch = chainConfig.Istanbul.Epoch
+		}
+		config.ProposerPolicy = istanbul.ProposerPolicy(chainConfig.Istanbul.ProposerPolicy)
+		config.EmptyBlockPeriod = chainConfig.Istanbul.EmptyBlockPeriod
+		config.ChainID = chainConfig.ChainId
+		config.AuditLog, config.ArchiveRetention = "", 0
+
+		// Seal verification doesn't need the node key, don't touch it
+		key, err := crypto.GenerateKey()
+		if err != nil {
+			utils.Fatalf("Failed to generate verification key: %v", err)
+		}
+		return istanbulBackend.New(&config, key, db)
+	}
+	return nil
+}
+
+// compressDB converts the block bodies and receipts in the database to the
+// configured compression, compacting the database afterwards.
+func compressDB(ctx *cli.Context) error {
+	stack, cfg := makeConfigNode(ctx)
+	chainDb := utils.MakeChainDatabase(ctx, stack)
+	defer chainDb.Close()
+
+	db, ok := chainDb.(*ethdb.LDBDatabase)
+	if !ok {
+		utils.Fatalf("Chain database doesn't support iteration")
+	}
+	if _, err := core.RecompressBlockData(db, cfg.Eth.BlockCompression); err != nil {
+		utils.Fatalf("Block data conversion failed: %v", err)
+	}
+	start := time.Now()
+	fmt.Println("Compacting entire database...")
+	if err := db.LDB().CompactRange(util.Range{}); err != nil {
+		utils.Fatalf("Compaction failed: %v", err)
+	}
+	fmt.Printf("Compaction done in %v.\n", time.Since(start))
+	return nil
+}
@@ -20,18 +20,21 @@ import (
 	"bufio"
 	"errors"
 	"fmt"
-	"io"
 	"os"
 	"reflect"
+	"sort"
 	"unicode"
 
 	cli "gopkg.in/urfave/cli.v1"
 
 	"github.com/ethereum/go-ethereum/cmd/utils"
+	"github.com/ethereum/go-ethereum/common/urlscheme"
 	"github.com/ethereum/go-ethereum/dashboard"
 	"github.com/ethereum/go-ethereum/eth"
+	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/node"
 	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/swarm/services/backup"
 	whisper "github.com/ethereum/go-ethereum/whisper/whisperv5"
 	"github.com/naoina/toml"
 )
@@ -80,6 +83,7 @@ type gethConfig struct {
 	Node      node.Config
 	Ethstats  ethstatsConfig
 	Dashboard dashboard.Config
+	Tenants   map[string]eth.Config `toml:",omitempty"`
 }
 
 func loadConfig(file string, cfg *gethConfig) error {
@@ -125,11 +129,21 @@ func makeConfigNode(ctx *cli.Context) (*node.Node, gethConfig) {
 
 	// Apply flags.
 	utils.SetNodeConfig(ctx, &cfg.Node)
+
+	// Restore any requested backup before the data directory gets used.
+	if dir := ctx.GlobalString(utils.RestoreFlag.Name); dir != "" {
+		if err := node.Restore(dir, &cfg.Node); err != nil {
+			utils.Fatalf("Failed to restore backup: %v", err)
+		}
+		log.Info("Restored node backup", "backup", dir, "datadir", cfg.Node.DataDir)
+	}
 	stack, err := node.New(&cfg.Node)
 	if err != nil {
 		utils.Fatalf("Failed to create the protocol stack: %v", err)
 	}
 	utils.SetEthConfig(ctx, stack, &cfg.Eth)
+	cfg.Eth.Istanbul.BuildVersion = params.Version
+	cfg.Eth.Istanbul.BuildCommit = gitCommit
 	if ctx.GlobalIsSet(utils.EthStatsURLFlag.Name) {
 		cfg.Ethstats.URL = ctx.GlobalString(utils.EthStatsURLFlag.Name)
 	}
@@ -155,6 +169,17 @@ func makeFullNode(ctx *cli.Context) *node.Node {
 
 	utils.RegisterEthService(stack, &cfg.Eth)
 
+	// Host any additional chains on their own p2p servers, in a stable order
+	tenants := make([]string, 0, len(cfg.Tenants))
+	for name := range cfg.Tenants {
+		tenants = append(tenants, name)
+	}
+	sort.Strings(tenants)
+	for _, name := range tenants {
+		tenantCfg := cfg.Tenants[name]
+		utils.RegisterTenantEthService(stack, name, &tenantCfg)
+	}
+
 	if ctx.GlobalBool(utils.DashboardEnabledFlag.Name) {
 		utils.RegisterDashboardService(stack, &cfg.Dashboard, gitCommit)
 	}
@@ -175,24 +200,70 @@ func makeFullNode(ctx *cli.Context) *node.Node {
 	if cfg.Ethstats.URL != "" {
 		utils.RegisterEthStatsService(stack, cfg.Ethstats.URL)
 	}
+	// Add the validator telemetry client if requested
+	if ctx.GlobalIsSet(utils.TelemetryURLFlag.Name) {
+		utils.RegisterTelemetryService(stack, ctx)
+	}
+	// Add the state snapshot generator if requested
+	if interval := ctx.GlobalUint64(utils.SnapshotIntervalFlag.Name); interval > 0 {
+		utils.RegisterSnapshotService(stack, ctx.GlobalString(utils.SnapshotGatewayFlag.Name), interval)
+	}
+	// Add the swarm chain publisher if requested
+	if size := ctx.GlobalUint64(utils.BackupRangeFlag.Name); size > 0 {
+		cfg := &backup.Config{
+			Gateway: ctx.GlobalString(utils.BackupGatewayFlag.Name),
+			Range:   size,
+			Name:    ctx.GlobalString(utils.BackupNameFlag.Name),
+		}
+		ens, err := urlscheme.ParseAddress(ctx.GlobalString(utils.BackupENSAddrFlag.Name))
+		if cfg.Name != "" && err != nil {
+			utils.Fatalf("Registering the chain backup requires a valid --%s: %v", utils.BackupENSAddrFlag.Name, err)
+		}
+		utils.RegisterBackupService(stack, cfg, ens)
+	}
+	// Add the finalized log exporter if requested
+	if ctx.GlobalIsSet(utils.ExporterWebhookFlag.Name) || ctx.GlobalIsSet(utils.ExporterKafkaFlag.Name) {
+		utils.RegisterExporterService(stack, ctx)
+	}
+	// Add the meta-transaction relayer if requested
+	if ctx.GlobalIsSet(utils.RelayerAccountFlag.Name) {
+		utils.RegisterRelayerService(stack, ctx)
+	}
+	// Add the cross-chain bridge relay if requested
+	if ctx.GlobalIsSet(utils.BridgeEndpointFlag.Name) {
+		utils.RegisterBridgeService(stack, ctx)
+	}
+	// Allow the effective configuration to be exported via admin_exportConfig
+	stack.SetConfigExporter(func() ([]byte, error) {
+		return encodeConfig(cfg)
+	})
 	return stack
 }
 
-// dumpConfig is the dumpconfig command.
-func dumpConfig(ctx *cli.Context) error {
-	_, cfg := makeConfigNode(ctx)
+// encodeConfig serializes the configuration into the TOML format accepted by
+// the --config flag. The genesis block is omitted.
+func encodeConfig(cfg gethConfig) ([]byte, error) {
 	comment := ""
 
 	if cfg.Eth.Genesis != nil {
 		cfg.Eth.Genesis = nil
 		comment += "# Note: this config doesn't contain the genesis block.\n\n"
 	}
-
 	out, err := tomlSettings.Marshal(&cfg)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(comment), out...), nil
+}
+
+// dumpConfig is the dumpconfig command.
+func dumpConfig(ctx *cli.Context) error {
+	_, cfg := makeConfigNode(ctx)
+
+	out, err := encodeConfig(cfg)
 	if err != nil {
 		return err
 	}
-	io.WriteString(os.Stdout, comment)
 	os.Stdout.Write(out)
 	return nil
 }
@@ -0,0 +1,70 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/dashboard"
+	"github.com/ethereum/go-ethereum/eth"
+	whisper "github.com/ethereum/go-ethereum/whisper/whisperv5"
+)
+
+// Tests that an exported configuration can be loaded back via --config.
+func TestConfigExportRoundtrip(t *testing.T) {
+	cfg := gethConfig{
+		Eth:       eth.DefaultConfig,
+		Shh:       whisper.DefaultConfig,
+		Node:      defaultNodeConfig(),
+		Dashboard: dashboard.DefaultConfig,
+	}
+	cfg.Eth.Genesis = core.DefaultGenesisBlock()
+	cfg.Eth.TxPool.GlobalSlots = 12345
+	cfg.Node.P2P.MaxPeers = 7
+
+	out, err := encodeConfig(cfg)
+	if err != nil {
+		t.Fatalf("failed to encode config: %v", err)
+	}
+	if cfg.Eth.Genesis == nil {
+		t.Fatalf("encoding modified the source config")
+	}
+	file, err := ioutil.TempFile("", "geth-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	file.Write(out)
+	file.Close()
+
+	var loaded gethConfig
+	if err := loadConfig(file.Name(), &loaded); err != nil {
+		t.Fatalf("failed to load exported config: %v", err)
+	}
+	if loaded.Eth.Genesis != nil {
+		t.Errorf("genesis block exported")
+	}
+	if loaded.Eth.TxPool.GlobalSlots != 12345 {
+		t.Errorf("txpool slots mismatch: have %d, want %d", loaded.Eth.TxPool.GlobalSlots, 12345)
+	}
+	if loaded.Node.P2P.MaxPeers != 7 {
+		t.Errorf("max peers mismatch: have %d, want %d", loaded.Node.P2P.MaxPeers, 7)
+	}
+}
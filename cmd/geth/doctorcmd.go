@@ -0,0 +1,133 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"os"
+	"time"
+
+	"github.com/ethereum/go-ethereum/cmd/utils"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/internal/doctor"
+	"github.com/ethereum/go-ethereum/params"
+	"gopkg.in/urfave/cli.v1"
+)
+
+var doctorCommand = cli.Command{
+	Action:    utils.MigrateFlags(runDoctor),
+	Name:      "doctor",
+	Usage:     "Check the node setup for common misconfigurations",
+	ArgsUsage: " ",
+	Flags: []cli.Flag{
+		configFileFlag,
+		utils.DataDirFlag,
+		utils.TestnetFlag,
+		utils.RinkebyFlag,
+		utils.LightModeFlag,
+		utils.ListenPortFlag,
+		utils.NoDiscoverFlag,
+		utils.NodeKeyFileFlag,
+		utils.NodeKeyHexFlag,
+		utils.IstanbulNTPServersFlag,
+		utils.IstanbulMaxClockDriftFlag,
+	},
+	Category: "MISCELLANEOUS COMMANDS",
+	Description: `
+The doctor command checks the setup of a stopped node: the permissions of the
+data directory, the consistency of the chain database, the genesis and chain
+config, the clock drift, the availability of the P2P port, the node key used
+to validate on istanbul chains and the persisted discovery table. Each problem
+found is reported with the steps to fix it.
+
+The same checks are available on a running node through admin.doctor().`,
+}
+
+// runDoctor checks the setup of a stopped node, failing if any check failed.
+func runDoctor(ctx *cli.Context) error {
+	stack, cfg := makeConfigNode(ctx)
+
+	config := &doctor.Config{
+		DataDir:       cfg.Node.DataDir,
+		InstanceDir:   stack.InstanceDir(),
+		Genesis:       cfg.Eth.Genesis,
+		NTPServers:    cfg.Eth.Istanbul.NTPServers,
+		MaxClockDrift: time.Duration(cfg.Eth.Istanbul.MaxClockDrift) * time.Millisecond,
+		ListenAddr:    cfg.Node.P2P.ListenAddr,
+		NodeKey:       cfg.Node.P2P.PrivateKey,
+	}
+	if !cfg.Node.P2P.NoDiscovery {
+		config.NodeDatabase = cfg.Node.NodeDB()
+	}
+	// Load the persisted node key without generating one if missing
+	if config.NodeKey == nil && cfg.Node.DataDir != "" {
+		config.NodeKey, _ = crypto.LoadECDSA(stack.ResolvePath("nodekey"))
+	}
+	if cfg.Node.DataDir != "" {
+		name := "chaindata"
+		if ctx.GlobalBool(utils.LightModeFlag.Name) {
+			name = "lightchaindata"
+		}
+		if !common.FileExist(stack.ResolvePath(name)) {
+			// Check an empty database instead of creating one
+			config.ChainDB, _ = ethdb.NewMemDatabase()
+		} else if db, err := stack.OpenDatabase(name, 16, 16); err != nil {
+			config.ChainDBError = err
+		} else {
+			defer db.Close()
+			config.ChainDB = db
+		}
+	}
+	config.Validators = genesisValidators(config)
+
+	results := doctor.Run(config)
+	doctor.Print(os.Stdout, results)
+	if doctor.Failed(results) {
+		utils.Fatalf("Node setup check failed")
+	}
+	return nil
+}
+
+// genesisValidators returns the validators of the istanbul genesis block, which
+// are the ones known without the engine replaying the votes since, or nil if
+// the chain isn't an istanbul one.
+func genesisValidators(config *doctor.Config) []common.Address {
+	var (
+		header      *types.Header
+		chainConfig *params.ChainConfig
+	)
+	if config.ChainDB != nil {
+		if hash := core.GetCanonicalHash(config.ChainDB, 0); hash != (common.Hash{}) {
+			header = core.GetHeader(config.ChainDB, hash, 0)
+			chainConfig, _ = core.GetChainConfig(config.ChainDB, hash)
+		}
+	}
+	if header == nil && config.Genesis != nil {
+		header, chainConfig = config.Genesis.ToBlock(nil).Header(), config.Genesis.Config
+	}
+	if header == nil || chainConfig == nil || chainConfig.Istanbul == nil {
+		return nil
+	}
+	extra, err := types.ExtractIstanbulExtra(header)
+	if err != nil {
+		return nil
+	}
+	return extra.Validators
+}
@@ -0,0 +1,437 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"crypto/ecdsa"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ethereum/go-ethereum/cmd/utils"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/urlscheme"
+	"github.com/ethereum/go-ethereum/consensus/istanbul"
+	"github.com/ethereum/go-ethereum/consensus/istanbul/backend"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/dashboard"
+	"github.com/ethereum/go-ethereum/eth"
+	"github.com/ethereum/go-ethereum/eth/downloader"
+	"github.com/ethereum/go-ethereum/p2p/discover"
+	"github.com/ethereum/go-ethereum/params"
+	whisper "github.com/ethereum/go-ethereum/whisper/whisperv5"
+	"gopkg.in/urfave/cli.v1"
+)
+
+var (
+	istanbulVanityFlag = cli.StringFlag{
+		Name:  "vanity",
+		Usage: "Hex encoded vanity prefix of the extra-data (up to 32 bytes)",
+	}
+	istanbulNodesFlag = cli.IntFlag{
+		Name:  "nodes",
+		Usage: "Number of validator nodes to set up",
+		Value: 4,
+	}
+	istanbulChainIDFlag = cli.Uint64Flag{
+		Name:  "chainid",
+		Usage: "Chain and network identifier of the new network",
+		Value: 1337,
+	}
+	istanbulOutputFlag = cli.StringFlag{
+		Name:  "output",
+		Usage: "Directory to create the node directories in",
+		Value: "istanbul-network",
+	}
+	istanbulIPFlag = cli.StringFlag{
+		Name:  "ip",
+		Usage: "IP address the nodes reach each other at",
+		Value: "127.0.0.1",
+	}
+	istanbulPortFlag = cli.IntFlag{
+		Name:  "port",
+		Usage: "Network listening port of the first node, incremented per node",
+		Value: 30303,
+	}
+	istanbulRPCPortFlag = cli.IntFlag{
+		Name:  "rpcport",
+		Usage: "HTTP-RPC port of the first node, incremented per node",
+		Value: 8545,
+	}
+	istanbulFormatFlag = cli.StringFlag{
+		Name:  "format",
+		Usage: `Format of the audit trail files ("json" or "csv")`,
+		Value: "json",
+	}
+
+	istanbulCommand = cli.Command{
+		Name:     "istanbul",
+		Usage:    "Manage Istanbul genesis configurations",
+		Category: "BLOCKCHAIN COMMANDS",
+		Description: `
+The Istanbul consensus engine keeps the initial validator set of a chain in
+the extra-data of its genesis block. These commands assemble and inspect it,
+and check the consensus fields of genesis files before initialising a node.`,
+		Subcommands: []cli.Command{
+			{
+				Name:      "encode",
+				Usage:     "Generate the genesis extra-data from a list of validators",
+				ArgsUsage: "<address> [<address>...]",
+				Action:    utils.MigrateFlags(encodeIstanbulExtra),
+				Flags: []cli.Flag{
+					istanbulVanityFlag,
+				},
+				Description: `
+    geth istanbul encode <address> [<address>...]
+
+prints the hex encoded extra-data of a genesis block with the given addresses
+as initial validators, to be used as the extraData field of the genesis file.`,
+			},
+			{
+				Name:      "decode",
+				Usage:     "Print the validators contained in extra-data",
+				ArgsUsage: "<extradata>",
+				Action:    utils.MigrateFlags(decodeIstanbulExtra),
+				Description: `
+    geth istanbul decode <extradata>
+
+decodes the hex encoded extra-data of an Istanbul block and prints its vanity,
+validators and seals.`,
+			},
+			{
+				Name:      "validate",
+				Usage:     "Check the consensus fields of a genesis file",
+				ArgsUsage: "<genesisPath>",
+				Action:    utils.MigrateFlags(validateIstanbulGenesis),
+				Description: `
+    geth istanbul validate <genesisPath>
+
+checks that the genesis file configures the Istanbul engine, has the mix digest,
+nonce and difficulty required by it, and an extra-data holding a non-empty set
+of distinct validators without seals.`,
+			},
+			{
+				Name:   "setup",
+				Usage:  "Bootstrap the configuration of a new validator network",
+				Action: utils.MigrateFlags(setupIstanbulNetwork),
+				Flags: []cli.Flag{
+					istanbulNodesFlag,
+					istanbulChainIDFlag,
+					istanbulOutputFlag,
+					istanbulIPFlag,
+					istanbulPortFlag,
+					istanbulRPCPortFlag,
+				},
+				Description: `
+    geth istanbul setup --nodes 4 --chainid 1337 --output istanbul-network
+
+generates the node keys of the given number of validators, and creates for
+each of them a data directory holding its key, the static-nodes.json list of
+all other validators and a config.toml to run it with. The genesis.json with
+the validators in its extra-data is written into the output directory. Every
+node is then started with:
+
+    geth --datadir <node> init <output>/genesis.json
+    geth --config <node>/config.toml --mine
+
+The nodes listen on consecutive ports starting from --port and --rpcport, so
+by default the whole network can be run on a single machine.`,
+			},
+			{
+				Name:      "audit",
+				Usage:     "Verify the hash chain of a consensus audit trail",
+				ArgsUsage: "<auditDir>",
+				Action:    utils.MigrateFlags(verifyIstanbulAudit),
+				Flags: []cli.Flag{
+					istanbulFormatFlag,
+				},
+				Description: `
+    geth istanbul audit --format json <auditDir>
+
+follows the hash chain through the records of the audit trail a validator run
+with --istanbul.audit kept in the directory, reporting the first record that
+was modified, removed or reordered.`,
+			},
+		},
+	}
+)
+
+// verifyIstanbulAudit checks the hash chain of a consensus audit trail.
+func verifyIstanbulAudit(ctx *cli.Context) error {
+	if len(ctx.Args()) != 1 {
+		utils.Fatalf("This command requires the audit trail directory as argument.")
+	}
+	records, err := backend.VerifyAuditLog(ctx.Args().First(), ctx.String(istanbulFormatFlag.Name))
+	if err != nil {
+		utils.Fatalf("Audit trail verification failed after %d records: %v", records, err)
+	}
+	fmt.Printf("Verified %d audit records\n", records)
+	return nil
+}
+
+// encodeIstanbulExtra prints the genesis extra-data for a set of validators.
+func encodeIstanbulExtra(ctx *cli.Context) error {
+	if len(ctx.Args()) == 0 {
+		utils.Fatalf("This command requires at least one validator address as argument.")
+	}
+	var validators []common.Address
+	for _, arg := range ctx.Args() {
+		addr, err := urlscheme.ParseAddress(arg)
+		if err != nil {
+			utils.Fatalf("Invalid validator address: %v", err)
+		}
+		validators = append(validators, addr)
+	}
+	var vanity []byte
+	if ctx.IsSet(istanbulVanityFlag.Name) {
+		var err error
+		if vanity, err = hexutil.Decode(ctx.String(istanbulVanityFlag.Name)); err != nil {
+			utils.Fatalf("Invalid vanity: %v", err)
+		}
+		if len(vanity) > types.IstanbulExtraVanity {
+			utils.Fatalf("Vanity too long: %d bytes, maximum %d", len(vanity), types.IstanbulExtraVanity)
+		}
+	}
+	extra, err := types.IstanbulGenesisExtra(vanity, validators)
+	if err != nil {
+		utils.Fatalf("Failed to encode extra-data: %v", err)
+	}
+	fmt.Println(hexutil.Encode(extra))
+	return nil
+}
+
+// decodeIstanbulExtra prints the contents of Istanbul extra-data.
+func decodeIstanbulExtra(ctx *cli.Context) error {
+	if len(ctx.Args()) != 1 {
+		utils.Fatalf("This command requires the extra-data as argument.")
+	}
+	extra, err := hexutil.Decode(ctx.Args().First())
+	if err != nil {
+		utils.Fatalf("Invalid extra-data: %v", err)
+	}
+	istanbulExtra, err := types.DecodeIstanbulExtra(extra)
+	if err != nil {
+		utils.Fatalf("Failed to decode extra-data: %v", err)
+	}
+	fmt.Printf("Vanity: %s\n", hexutil.Encode(extra[:types.IstanbulExtraVanity]))
+	fmt.Printf("Validators (%d):\n", len(istanbulExtra.Validators))
+	for _, validator := range istanbulExtra.Validators {
+		fmt.Printf("  %s\n", validator.Hex())
+	}
+	fmt.Printf("Seal: %s\n", hexutil.Encode(istanbulExtra.Seal))
+	fmt.Printf("Committed seals: %d\n", len(istanbulExtra.CommittedSeal))
+	return nil
+}
+
+// validateIstanbulGenesis checks the consensus fields of a genesis file.
+func validateIstanbulGenesis(ctx *cli.Context) error {
+	genesisPath := ctx.Args().First()
+	if len(genesisPath) == 0 {
+		utils.Fatalf("Must supply path to genesis JSON file")
+	}
+	file, err := os.Open(genesisPath)
+	if err != nil {
+		utils.Fatalf("Failed to read genesis file: %v", err)
+	}
+	defer file.Close()
+
+	genesis := new(core.Genesis)
+	if err := json.NewDecoder(file).Decode(genesis); err != nil {
+		utils.Fatalf("invalid genesis file: %v", err)
+	}
+	validators, err := backend.ValidateGenesis(genesis)
+	if err != nil {
+		utils.Fatalf("Invalid Istanbul genesis: %v", err)
+	}
+	fmt.Printf("Valid Istanbul genesis with %d validators:\n", len(validators))
+	for _, validator := range validators {
+		fmt.Printf("  %s\n", validator.Hex())
+	}
+	return nil
+}
+
+// istanbulNetwork is the configuration of a new validator network.
+type istanbulNetwork struct {
+	nodes   int    // Number of validator nodes
+	chainID uint64 // Chain and network identifier
+	dir     string // Directory to create the node directories in
+	ip      net.IP // IP address the nodes reach each other at
+	port    int    // Listening port of the first node
+	rpcPort int    // HTTP-RPC port of the first node
+}
+
+// setupIstanbulNetwork generates the keys, genesis and configs of a new network.
+func setupIstanbulNetwork(ctx *cli.Context) error {
+	network := &istanbulNetwork{
+		nodes:   ctx.Int(istanbulNodesFlag.Name),
+		chainID: ctx.Uint64(istanbulChainIDFlag.Name),
+		dir:     ctx.String(istanbulOutputFlag.Name),
+		ip:      net.ParseIP(ctx.String(istanbulIPFlag.Name)),
+		port:    ctx.Int(istanbulPortFlag.Name),
+		rpcPort: ctx.Int(istanbulRPCPortFlag.Name),
+	}
+	if network.nodes < 1 {
+		utils.Fatalf("At least one node is required")
+	}
+	if network.ip == nil {
+		utils.Fatalf("Invalid IP address %q", ctx.String(istanbulIPFlag.Name))
+	}
+	if _, err := os.Stat(network.dir); err == nil {
+		utils.Fatalf("Output directory %s already exists", network.dir)
+	}
+	validators, err := network.generate()
+	if err != nil {
+		utils.Fatalf("Failed to set up network: %v", err)
+	}
+	fmt.Printf("Network with chain ID %d set up in %s\n", network.chainID, network.dir)
+	for i, validator := range validators {
+		fmt.Printf("  node%d: validator %s\n", i, validator.Hex())
+	}
+	return nil
+}
+
+// generate creates the node directories and genesis file of the network,
+// returning the validator addresses.
+func (n *istanbulNetwork) generate() ([]common.Address, error) {
+	dir, err := filepath.Abs(n.dir)
+	if err != nil {
+		return nil, err
+	}
+	// Generate the node keys, the validators being identified by them
+	var (
+		keys       = make([]*ecdsa.PrivateKey, n.nodes)
+		validators = make([]common.Address, n.nodes)
+		enodes     = make([]string, n.nodes)
+	)
+	for i := range keys {
+		if keys[i], err = crypto.GenerateKey(); err != nil {
+			return nil, err
+		}
+		validators[i] = crypto.PubkeyToAddress(keys[i].PublicKey)
+		enodes[i] = discover.NewNode(discover.PubkeyID(&keys[i].PublicKey), n.ip, uint16(n.port+i), uint16(n.port+i)).String()
+	}
+	// Assemble the genesis with every validator in the initial set
+	genesis, err := n.genesis(validators)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := backend.ValidateGenesis(genesis); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	blob, err := json.MarshalIndent(genesis, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "genesis.json"), blob, 0644); err != nil {
+		return nil, err
+	}
+	// Create the data directory and config of every node
+	for i, key := range keys {
+		datadir := filepath.Join(dir, fmt.Sprintf("node%d", i))
+		instdir := filepath.Join(datadir, clientIdentifier)
+		if err := os.MkdirAll(instdir, 0700); err != nil {
+			return nil, err
+		}
+		if err := crypto.SaveECDSA(filepath.Join(instdir, "nodekey"), key); err != nil {
+			return nil, err
+		}
+		var peers []string
+		for j, enode := range enodes {
+			if j != i {
+				peers = append(peers, enode)
+			}
+		}
+		if peers == nil {
+			peers = []string{}
+		}
+		blob, err := json.MarshalIndent(peers, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		if err := ioutil.WriteFile(filepath.Join(instdir, "static-nodes.json"), blob, 0644); err != nil {
+			return nil, err
+		}
+		config, err := encodeConfig(n.config(i, datadir))
+		if err != nil {
+			return nil, err
+		}
+		if err := ioutil.WriteFile(filepath.Join(datadir, "config.toml"), config, 0644); err != nil {
+			return nil, err
+		}
+	}
+	return validators, nil
+}
+
+// genesis assembles the genesis block specification of the network.
+func (n *istanbulNetwork) genesis(validators []common.Address) (*core.Genesis, error) {
+	extra, err := types.IstanbulGenesisExtra(nil, validators)
+	if err != nil {
+		return nil, err
+	}
+	return &core.Genesis{
+		Config: &params.ChainConfig{
+			ChainId:        new(big.Int).SetUint64(n.chainID),
+			HomesteadBlock: big.NewInt(0),
+			EIP150Block:    big.NewInt(0),
+			EIP155Block:    big.NewInt(0),
+			EIP158Block:    big.NewInt(0),
+			ByzantiumBlock: big.NewInt(0),
+			Istanbul: &params.IstanbulConfig{
+				Epoch:          istanbul.DefaultConfig.Epoch,
+				ProposerPolicy: uint64(istanbul.DefaultConfig.ProposerPolicy),
+			},
+		},
+		Timestamp:  uint64(time.Now().Unix()),
+		ExtraData:  extra,
+		GasLimit:   params.GenesisGasLimit,
+		Difficulty: big.NewInt(1),
+		Mixhash:    types.IstanbulDigest,
+		Alloc:      core.GenesisAlloc{},
+	}, nil
+}
+
+// config assembles the configuration of the i-th node of the network.
+func (n *istanbulNetwork) config(i int, datadir string) gethConfig {
+	cfg := gethConfig{
+		Eth:       eth.DefaultConfig,
+		Shh:       whisper.DefaultConfig,
+		Node:      defaultNodeConfig(),
+		Dashboard: dashboard.DefaultConfig,
+	}
+	cfg.Eth.NetworkId = n.chainID
+	cfg.Eth.SyncMode = downloader.FullSync
+
+	cfg.Node.DataDir = datadir
+	cfg.Node.P2P.ListenAddr = fmt.Sprintf(":%d", n.port+i)
+	cfg.Node.P2P.NoDiscovery = true
+	cfg.Node.HTTPHost = "127.0.0.1"
+	cfg.Node.HTTPPort = n.rpcPort + i
+	cfg.Node.HTTPModules = append(cfg.Node.HTTPModules, "istanbul")
+
+	return cfg
+}
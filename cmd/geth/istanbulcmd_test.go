@@ -0,0 +1,105 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/consensus/istanbul/backend"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/p2p/discover"
+)
+
+// Tests that the network setup produces a valid genesis, and node directories
+// with keys matching the validators, peered with each other and configured.
+func TestIstanbulSetup(t *testing.T) {
+	dir := tmpdir(t)
+	defer os.RemoveAll(dir)
+
+	network := &istanbulNetwork{
+		nodes:   3,
+		chainID: 4242,
+		dir:     filepath.Join(dir, "network"),
+		ip:      net.ParseIP("127.0.0.1"),
+		port:    30303,
+		rpcPort: 8545,
+	}
+	validators, err := network.generate()
+	if err != nil {
+		t.Fatalf("failed to set up network: %v", err)
+	}
+	blob, err := ioutil.ReadFile(filepath.Join(network.dir, "genesis.json"))
+	if err != nil {
+		t.Fatalf("failed to read genesis: %v", err)
+	}
+	genesis := new(core.Genesis)
+	if err := json.Unmarshal(blob, genesis); err != nil {
+		t.Fatalf("failed to decode genesis: %v", err)
+	}
+	have, err := backend.ValidateGenesis(genesis)
+	if err != nil {
+		t.Fatalf("invalid genesis: %v", err)
+	}
+	if len(have) != network.nodes || genesis.Config.ChainId.Uint64() != network.chainID {
+		t.Fatalf("genesis mismatch: %d validators, chain ID %v", len(have), genesis.Config.ChainId)
+	}
+	for i, validator := range validators {
+		datadir := filepath.Join(network.dir, fmt.Sprintf("node%d", i))
+
+		key, err := crypto.LoadECDSA(filepath.Join(datadir, "geth", "nodekey"))
+		if err != nil {
+			t.Fatalf("node %d: failed to load key: %v", i, err)
+		}
+		if addr := crypto.PubkeyToAddress(key.PublicKey); addr != validator || have[i] != validator {
+			t.Errorf("node %d: validator mismatch: key %x, genesis %x, want %x", i, addr, have[i], validator)
+		}
+		var peers []string
+		blob, err := ioutil.ReadFile(filepath.Join(datadir, "geth", "static-nodes.json"))
+		if err != nil {
+			t.Fatalf("node %d: failed to read static nodes: %v", i, err)
+		}
+		if err := json.Unmarshal(blob, &peers); err != nil {
+			t.Fatalf("node %d: failed to decode static nodes: %v", i, err)
+		}
+		if len(peers) != network.nodes-1 {
+			t.Errorf("node %d: static node count mismatch: have %d, want %d", i, len(peers), network.nodes-1)
+		}
+		for _, peer := range peers {
+			node, err := discover.ParseNode(peer)
+			if err != nil {
+				t.Fatalf("node %d: invalid static node %s: %v", i, peer, err)
+			}
+			if node.ID == discover.PubkeyID(&key.PublicKey) {
+				t.Errorf("node %d: peered with itself", i)
+			}
+		}
+		var cfg gethConfig
+		if err := loadConfig(filepath.Join(datadir, "config.toml"), &cfg); err != nil {
+			t.Fatalf("node %d: failed to load config: %v", i, err)
+		}
+		if cfg.Node.DataDir != datadir || cfg.Eth.NetworkId != network.chainID || cfg.Node.P2P.ListenAddr != fmt.Sprintf(":%d", network.port+i) {
+			t.Errorf("node %d: config mismatch: datadir %s, network %d, listen %s", i, cfg.Node.DataDir, cfg.Eth.NetworkId, cfg.Node.P2P.ListenAddr)
+		}
+	}
+}
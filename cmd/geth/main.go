@@ -55,12 +55,17 @@ var (
 		utils.IdentityFlag,
 		utils.UnlockedAccountFlag,
 		utils.PasswordFileFlag,
+		utils.TxApprovalAccountsFlag,
+		utils.TxApprovalApproversFlag,
+		utils.TxApprovalThresholdFlag,
 		utils.BootnodesFlag,
 		utils.BootnodesV4Flag,
 		utils.BootnodesV5Flag,
 		utils.DataDirFlag,
 		utils.KeyStoreDirFlag,
+		utils.RestoreFlag,
 		utils.NoUSBFlag,
+		utils.ExternalSignerFlag,
 		utils.DashboardEnabledFlag,
 		utils.DashboardAddrFlag,
 		utils.DashboardPortFlag,
@@ -81,22 +86,37 @@ var (
 		utils.TxPoolAccountQueueFlag,
 		utils.TxPoolGlobalQueueFlag,
 		utils.TxPoolLifetimeFlag,
+		utils.TxPoolValidatorBroadcastFlag,
+		utils.TxPoolSqrtBroadcastFlag,
 		utils.FastSyncFlag,
 		utils.LightModeFlag,
 		utils.SyncModeFlag,
 		utils.GCModeFlag,
+		utils.ReceiptsRetentionFlag,
+		utils.IndexesFlag,
 		utils.LightServFlag,
 		utils.LightPeersFlag,
 		utils.LightKDFFlag,
+		utils.KDFFlag,
+		utils.KDFScryptNFlag,
+		utils.KDFScryptPFlag,
+		utils.KDFArgon2TimeFlag,
+		utils.KDFArgon2MemoryFlag,
+		utils.KDFArgon2ThreadsFlag,
 		utils.CacheFlag,
 		utils.CacheDatabaseFlag,
+		utils.CacheTrieFlag,
 		utils.CacheGCFlag,
+		utils.CacheAsyncIndexFlag,
+		utils.DBCompressionFlag,
 		utils.TrieCacheGenFlag,
+		utils.ParallelTxsFlag,
 		utils.ListenPortFlag,
 		utils.MaxPeersFlag,
 		utils.MaxPendingPeersFlag,
 		utils.EtherbaseFlag,
 		utils.GasPriceFlag,
+		utils.TxOrderingFlag,
 		utils.MinerThreadsFlag,
 		utils.MiningEnabledFlag,
 		utils.TargetGasLimitFlag,
@@ -115,16 +135,76 @@ var (
 		utils.NetworkIdFlag,
 		utils.RPCCORSDomainFlag,
 		utils.RPCVirtualHostsFlag,
+		utils.RPCReadTimeoutFlag,
+		utils.RPCWriteTimeoutFlag,
+		utils.RPCIdleTimeoutFlag,
+		utils.RPCNoKeepAliveFlag,
+		utils.RPCNoCompressionFlag,
+		utils.RPCAuthFileFlag,
+		utils.RPCBatchLimitFlag,
+		utils.RPCResponseLimitFlag,
+		utils.RPCInFlightLimitFlag,
+		utils.RPCRateLimitFlag,
+		utils.RPCRateBurstFlag,
+		utils.RPCSlowQueryFlag,
+		utils.RPCGasCapFlag,
+		utils.RPCEVMTimeoutFlag,
+		utils.RPCLogsBlockRangeFlag,
+		utils.RPCLogsMaxResultsFlag,
+		utils.RPCUnlockTimeoutFlag,
 		utils.EthStatsURLFlag,
+		utils.TelemetryURLFlag,
+		utils.TelemetryIntervalFlag,
+		utils.SnapshotIntervalFlag,
+		utils.SnapshotGatewayFlag,
+		utils.BackupRangeFlag,
+		utils.BackupGatewayFlag,
+		utils.BackupNameFlag,
+		utils.BackupENSAddrFlag,
+		utils.ExporterWebhookFlag,
+		utils.ExporterKafkaFlag,
+		utils.ExporterKafkaTopicFlag,
+		utils.ExporterAddressesFlag,
+		utils.ExporterTopicsFlag,
+		utils.ExporterFromFlag,
+		utils.RelayerAccountFlag,
+		utils.RelayerGasPriceFlag,
+		utils.RelayerMaxGasFlag,
+		utils.RelayerQuotaFlag,
+		utils.RelayerQuotaPeriodFlag,
+		utils.RelayerTargetsFlag,
+		utils.BridgeEndpointFlag,
+		utils.BridgeContractFlag,
+		utils.BridgeEventsFlag,
+		utils.BridgeDestinationFlag,
+		utils.BridgeChainIDFlag,
+		utils.BridgeAccountFlag,
+		utils.BridgeGasFlag,
+		utils.BridgeGasPriceFlag,
+		utils.BridgeFormatFlag,
+		utils.BridgeFromFlag,
 		utils.MetricsEnabledFlag,
 		utils.FakePoWFlag,
 		utils.NoCompactionFlag,
+		utils.GpoModeFlag,
 		utils.GpoBlocksFlag,
 		utils.GpoPercentileFlag,
 		utils.ExtraDataFlag,
 		configFileFlag,
 		utils.IstanbulRequestTimeoutFlag,
+		utils.IstanbulMinRequestTimeoutFlag,
+		utils.IstanbulMaxRequestTimeoutFlag,
 		utils.IstanbulBlockPeriodFlag,
+		utils.IstanbulNTPServersFlag,
+		utils.IstanbulMaxClockDriftFlag,
+		utils.IstanbulRefuseOnDriftFlag,
+		utils.IstanbulAnnouncePeriodFlag,
+		utils.IstanbulArchiveFlag,
+		utils.IstanbulFeaturesFlag,
+		utils.IstanbulUpgradeSignalFlag,
+		utils.IstanbulSigningFlag,
+		utils.IstanbulAuditFlag,
+		utils.IstanbulAuditFormatFlag,
 	}
 
 	rpcFlags = []cli.Flag{
@@ -161,6 +241,11 @@ func init() {
 		copydbCommand,
 		removedbCommand,
 		dumpCommand,
+		dbCommand,
+		// See snapshotcmd.go:
+		snapshotCommand,
+		// See istanbulcmd.go:
+		istanbulCommand,
 		// See monitorcmd.go:
 		monitorCommand,
 		// See accountcmd.go:
@@ -170,6 +255,8 @@ func init() {
 		consoleCommand,
 		attachCommand,
 		javascriptCommand,
+		// See doctorcmd.go:
+		doctorCommand,
 		// See misccmd.go:
 		makecacheCommand,
 		makedagCommand,
@@ -305,5 +392,16 @@ func startNode(ctx *cli.Context, stack *node.Node) {
 		if err := ethereum.StartMining(true); err != nil {
 			utils.Fatalf("Failed to start mining: %v", err)
 		}
+		// Seal on the tenant chains hosted by the node too
+		for _, tenant := range stack.Tenants() {
+			var ethereum *eth.Ethereum
+			if err := stack.TenantService(tenant, &ethereum); err != nil {
+				utils.Fatalf("Ethereum service of tenant %s not running: %v", tenant, err)
+			}
+			ethereum.TxPool().SetGasPrice(utils.GlobalBig(ctx, utils.GasPriceFlag.Name))
+			if err := ethereum.StartMining(true); err != nil {
+				utils.Fatalf("Failed to start mining on tenant %s: %v", tenant, err)
+			}
+		}
 	}
 }
@@ -0,0 +1,87 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"time"
+
+	"github.com/ethereum/go-ethereum/cmd/utils"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/swarm/services/snapshot"
+	"gopkg.in/urfave/cli.v1"
+)
+
+var (
+	snapshotCommand = cli.Command{
+		Name:     "snapshot",
+		Usage:    "Manage state snapshots stored in swarm",
+		Category: "BLOCKCHAIN COMMANDS",
+		Description: `
+State snapshots are generated by nodes running with --snapshot.interval at
+finalized blocks, and stored in swarm. They allow new nodes to skip most of
+the state download when joining a mature chain.`,
+		Subcommands: []cli.Command{
+			{
+				Name:      "restore",
+				Usage:     "Restore the state of a snapshot into the local database",
+				ArgsUsage: "<manifest>",
+				Action:    utils.MigrateFlags(restoreSnapshot),
+				Flags: []cli.Flag{
+					utils.DataDirFlag,
+					utils.CacheFlag,
+					utils.LightModeFlag,
+					utils.SnapshotGatewayFlag,
+				},
+				Description: `
+    geth snapshot restore <manifest>
+
+downloads the state snapshot with the given manifest hash through the swarm
+gateway, and verifies that the complete state of the snapshot block is present
+in the database afterwards. The manifest hash must be obtained from a trusted
+source, e.g. the snapshot_list RPC of a validator. A subsequent fast sync only
+needs to download the state changed since the snapshot block.`,
+			},
+		},
+	}
+)
+
+// restoreSnapshot downloads a state snapshot into the chain database.
+func restoreSnapshot(ctx *cli.Context) error {
+	if len(ctx.Args()) != 1 {
+		utils.Fatalf("This command requires a manifest hash as argument.")
+	}
+	stack, _ := makeConfigNode(ctx)
+	chainDb := utils.MakeChainDatabase(ctx, stack)
+	defer chainDb.Close()
+
+	store := snapshot.NewBzzStore(ctx.GlobalString(utils.SnapshotGatewayFlag.Name))
+	manifest, err := snapshot.Fetch(store, common.FromHex(ctx.Args().First()))
+	if err != nil {
+		utils.Fatalf("Failed to retrieve snapshot manifest: %v", err)
+	}
+	log.Info("Restoring state snapshot", "number", manifest.Header.Number, "hash", manifest.Header.Hash(),
+		"root", manifest.Header.Root, "entries", manifest.Entries, "segments", len(manifest.Segments))
+
+	start := time.Now()
+	if err := snapshot.Restore(store, manifest, chainDb); err != nil {
+		utils.Fatalf("Failed to restore state snapshot: %v", err)
+	}
+	log.Info("Restored state snapshot", "number", manifest.Header.Number, "hash", manifest.Header.Hash(),
+		"elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
@@ -70,14 +70,48 @@ var AppHelpFlagGroups = []flagGroup{
 			configFileFlag,
 			utils.DataDirFlag,
 			utils.KeyStoreDirFlag,
+			utils.RestoreFlag,
 			utils.NoUSBFlag,
+			utils.ExternalSignerFlag,
 			utils.NetworkIdFlag,
 			utils.TestnetFlag,
 			utils.RinkebyFlag,
 			utils.OttomanFlag,
 			utils.SyncModeFlag,
 			utils.GCModeFlag,
+			utils.ReceiptsRetentionFlag,
+			utils.IndexesFlag,
 			utils.EthStatsURLFlag,
+			utils.TelemetryURLFlag,
+			utils.TelemetryIntervalFlag,
+			utils.SnapshotIntervalFlag,
+			utils.SnapshotGatewayFlag,
+			utils.BackupRangeFlag,
+			utils.BackupGatewayFlag,
+			utils.BackupNameFlag,
+			utils.BackupENSAddrFlag,
+			utils.ExporterWebhookFlag,
+			utils.ExporterKafkaFlag,
+			utils.ExporterKafkaTopicFlag,
+			utils.ExporterAddressesFlag,
+			utils.ExporterTopicsFlag,
+			utils.ExporterFromFlag,
+			utils.RelayerAccountFlag,
+			utils.RelayerGasPriceFlag,
+			utils.RelayerMaxGasFlag,
+			utils.RelayerQuotaFlag,
+			utils.RelayerQuotaPeriodFlag,
+			utils.RelayerTargetsFlag,
+			utils.BridgeEndpointFlag,
+			utils.BridgeContractFlag,
+			utils.BridgeEventsFlag,
+			utils.BridgeDestinationFlag,
+			utils.BridgeChainIDFlag,
+			utils.BridgeAccountFlag,
+			utils.BridgeGasFlag,
+			utils.BridgeGasPriceFlag,
+			utils.BridgeFormatFlag,
+			utils.BridgeFromFlag,
 			utils.IdentityFlag,
 			utils.LightServFlag,
 			utils.LightPeersFlag,
@@ -124,6 +158,8 @@ var AppHelpFlagGroups = []flagGroup{
 			utils.TxPoolAccountQueueFlag,
 			utils.TxPoolGlobalQueueFlag,
 			utils.TxPoolLifetimeFlag,
+			utils.TxPoolValidatorBroadcastFlag,
+			utils.TxPoolSqrtBroadcastFlag,
 		},
 	},
 	{
@@ -131,8 +167,12 @@ var AppHelpFlagGroups = []flagGroup{
 		Flags: []cli.Flag{
 			utils.CacheFlag,
 			utils.CacheDatabaseFlag,
+			utils.CacheTrieFlag,
 			utils.CacheGCFlag,
+			utils.CacheAsyncIndexFlag,
+			utils.DBCompressionFlag,
 			utils.TrieCacheGenFlag,
+			utils.ParallelTxsFlag,
 		},
 	},
 	{
@@ -140,6 +180,15 @@ var AppHelpFlagGroups = []flagGroup{
 		Flags: []cli.Flag{
 			utils.UnlockedAccountFlag,
 			utils.PasswordFileFlag,
+			utils.KDFFlag,
+			utils.KDFScryptNFlag,
+			utils.KDFScryptPFlag,
+			utils.KDFArgon2TimeFlag,
+			utils.KDFArgon2MemoryFlag,
+			utils.KDFArgon2ThreadsFlag,
+			utils.TxApprovalAccountsFlag,
+			utils.TxApprovalApproversFlag,
+			utils.TxApprovalThresholdFlag,
 		},
 	},
 	{
@@ -158,6 +207,23 @@ var AppHelpFlagGroups = []flagGroup{
 			utils.IPCPathFlag,
 			utils.RPCCORSDomainFlag,
 			utils.RPCVirtualHostsFlag,
+			utils.RPCReadTimeoutFlag,
+			utils.RPCWriteTimeoutFlag,
+			utils.RPCIdleTimeoutFlag,
+			utils.RPCNoKeepAliveFlag,
+			utils.RPCNoCompressionFlag,
+			utils.RPCAuthFileFlag,
+			utils.RPCBatchLimitFlag,
+			utils.RPCResponseLimitFlag,
+			utils.RPCInFlightLimitFlag,
+			utils.RPCRateLimitFlag,
+			utils.RPCRateBurstFlag,
+			utils.RPCSlowQueryFlag,
+			utils.RPCGasCapFlag,
+			utils.RPCEVMTimeoutFlag,
+			utils.RPCLogsBlockRangeFlag,
+			utils.RPCLogsMaxResultsFlag,
+			utils.RPCUnlockTimeoutFlag,
 			utils.JSpathFlag,
 			utils.ExecFlag,
 			utils.PreloadJSFlag,
@@ -188,12 +254,14 @@ var AppHelpFlagGroups = []flagGroup{
 			utils.EtherbaseFlag,
 			utils.TargetGasLimitFlag,
 			utils.GasPriceFlag,
+			utils.TxOrderingFlag,
 			utils.ExtraDataFlag,
 		},
 	},
 	{
 		Name: "GAS PRICE ORACLE",
 		Flags: []cli.Flag{
+			utils.GpoModeFlag,
 			utils.GpoBlocksFlag,
 			utils.GpoPercentileFlag,
 		},
@@ -230,7 +298,19 @@ var AppHelpFlagGroups = []flagGroup{
 		Name: "ISTANBUL",
 		Flags: []cli.Flag{
 			utils.IstanbulRequestTimeoutFlag,
+			utils.IstanbulMinRequestTimeoutFlag,
+			utils.IstanbulMaxRequestTimeoutFlag,
 			utils.IstanbulBlockPeriodFlag,
+			utils.IstanbulNTPServersFlag,
+			utils.IstanbulMaxClockDriftFlag,
+			utils.IstanbulRefuseOnDriftFlag,
+			utils.IstanbulAnnouncePeriodFlag,
+			utils.IstanbulArchiveFlag,
+			utils.IstanbulFeaturesFlag,
+			utils.IstanbulUpgradeSignalFlag,
+			utils.IstanbulSigningFlag,
+			utils.IstanbulAuditFlag,
+			utils.IstanbulAuditFormatFlag,
 		},
 	},
 }
@@ -35,6 +35,7 @@ import (
 	"github.com/naoina/toml"
 
 	bzzapi "github.com/ethereum/go-ethereum/swarm/api"
+	"github.com/ethereum/go-ethereum/swarm/storage"
 )
 
 var (
@@ -58,19 +59,33 @@ var (
 
 //constants for environment variables
 const (
-	SWARM_ENV_CHEQUEBOOK_ADDR = "SWARM_CHEQUEBOOK_ADDR"
-	SWARM_ENV_ACCOUNT         = "SWARM_ACCOUNT"
-	SWARM_ENV_LISTEN_ADDR     = "SWARM_LISTEN_ADDR"
-	SWARM_ENV_PORT            = "SWARM_PORT"
-	SWARM_ENV_NETWORK_ID      = "SWARM_NETWORK_ID"
-	SWARM_ENV_SWAP_ENABLE     = "SWARM_SWAP_ENABLE"
-	SWARM_ENV_SWAP_API        = "SWARM_SWAP_API"
-	SWARM_ENV_SYNC_ENABLE     = "SWARM_SYNC_ENABLE"
-	SWARM_ENV_ENS_API         = "SWARM_ENS_API"
-	SWARM_ENV_ENS_ADDR        = "SWARM_ENS_ADDR"
-	SWARM_ENV_CORS            = "SWARM_CORS"
-	SWARM_ENV_BOOTNODES       = "SWARM_BOOTNODES"
-	GETH_ENV_DATADIR          = "GETH_DATADIR"
+	SWARM_ENV_CHEQUEBOOK_ADDR     = "SWARM_CHEQUEBOOK_ADDR"
+	SWARM_ENV_ACCOUNT             = "SWARM_ACCOUNT"
+	SWARM_ENV_LISTEN_ADDR         = "SWARM_LISTEN_ADDR"
+	SWARM_ENV_PORT                = "SWARM_PORT"
+	SWARM_ENV_NETWORK_ID          = "SWARM_NETWORK_ID"
+	SWARM_ENV_SWAP_ENABLE         = "SWARM_SWAP_ENABLE"
+	SWARM_ENV_SWAP_API            = "SWARM_SWAP_API"
+	SWARM_ENV_SYNC_ENABLE         = "SWARM_SYNC_ENABLE"
+	SWARM_ENV_ENS_API             = "SWARM_ENS_API"
+	SWARM_ENV_ENS_ADDR            = "SWARM_ENS_ADDR"
+	SWARM_ENV_CORS                = "SWARM_CORS"
+	SWARM_ENV_BOOTNODES           = "SWARM_BOOTNODES"
+	SWARM_ENV_HTTP_CACHE          = "SWARM_HTTP_CACHE"
+	SWARM_ENV_HTTP_CACHE_DISK     = "SWARM_HTTP_CACHE_DISK"
+	SWARM_ENV_STORE_PATH          = "SWARM_STORE_PATH"
+	SWARM_ENV_STORE_SHARDS        = "SWARM_STORE_SHARDS"
+	SWARM_ENV_RATELIMIT_STORE     = "SWARM_RATELIMIT_STORE"
+	SWARM_ENV_RATELIMIT_RETRIEVE  = "SWARM_RATELIMIT_RETRIEVE"
+	SWARM_ENV_RATELIMIT_REQUESTS  = "SWARM_RATELIMIT_REQUESTS"
+	SWARM_ENV_MANIFEST_MAXSIZE    = "SWARM_MANIFEST_MAXSIZE"
+	SWARM_ENV_MANIFEST_MAXENTRIES = "SWARM_MANIFEST_MAXENTRIES"
+	SWARM_ENV_MANIFEST_MAXDEPTH   = "SWARM_MANIFEST_MAXDEPTH"
+	SWARM_ENV_PUBLISH_API         = "SWARM_PUBLISH_API"
+	SWARM_ENV_PUBLISH_CONTRACT    = "SWARM_PUBLISH_CONTRACT"
+	SWARM_ENV_PUBLISH_FROM        = "SWARM_PUBLISH_FROM"
+	SWARM_ENV_PUBLISH_REFRESH     = "SWARM_PUBLISH_REFRESH"
+	GETH_ENV_DATADIR              = "GETH_DATADIR"
 )
 
 // These settings ensure that TOML keys use the same names as Go struct fields.
@@ -221,6 +236,66 @@ func cmdLineOverride(currentConfig *bzzapi.Config, ctx *cli.Context) *bzzapi.Con
 		currentConfig.BootNodes = ctx.GlobalString(utils.BootnodesFlag.Name)
 	}
 
+	if ctx.GlobalIsSet(SwarmHTTPCacheFlag.Name) {
+		currentConfig.HTTPCache = ctx.GlobalUint64(SwarmHTTPCacheFlag.Name)
+	}
+
+	if ctx.GlobalIsSet(SwarmHTTPCacheDiskFlag.Name) {
+		currentConfig.HTTPCacheDisk = ctx.GlobalUint64(SwarmHTTPCacheDiskFlag.Name)
+	}
+
+	if publishAPI := ctx.GlobalString(SwarmPublishAPIFlag.Name); publishAPI != "" {
+		currentConfig.PublishAPI = publishAPI
+	}
+
+	if contract := ctx.GlobalString(SwarmPublishContractFlag.Name); contract != "" {
+		currentConfig.PublishContract = common.HexToAddress(contract)
+	}
+
+	if ctx.GlobalIsSet(SwarmPublishFromFlag.Name) {
+		currentConfig.PublishFrom = ctx.GlobalUint64(SwarmPublishFromFlag.Name)
+	}
+
+	if ctx.GlobalIsSet(SwarmPublishRefreshFlag.Name) {
+		currentConfig.PublishRefresh = ctx.GlobalUint64(SwarmPublishRefreshFlag.Name)
+	}
+
+	if path := ctx.GlobalString(SwarmStorePathFlag.Name); path != "" {
+		currentConfig.ChunkDbPath = path
+	}
+
+	if shards := ctx.GlobalString(SwarmStoreShardsFlag.Name); shards != "" {
+		params, err := parseStoreShards(shards, currentConfig.DbCapacity)
+		if err != nil {
+			utils.Fatalf("invalid --%s: %v", SwarmStoreShardsFlag.Name, err)
+		}
+		currentConfig.Shards = params
+	}
+
+	if ctx.GlobalIsSet(SwarmStoreRateFlag.Name) {
+		currentConfig.StoreRate = ctx.GlobalUint64(SwarmStoreRateFlag.Name)
+	}
+
+	if ctx.GlobalIsSet(SwarmRetrieveRateFlag.Name) {
+		currentConfig.RetrieveRate = ctx.GlobalUint64(SwarmRetrieveRateFlag.Name)
+	}
+
+	if ctx.GlobalIsSet(SwarmClientRequestsFlag.Name) {
+		currentConfig.MaxRequests = ctx.GlobalInt(SwarmClientRequestsFlag.Name)
+	}
+
+	if ctx.GlobalIsSet(SwarmManifestSizeFlag.Name) {
+		currentConfig.MaxManifestSize = ctx.GlobalInt64(SwarmManifestSizeFlag.Name)
+	}
+
+	if ctx.GlobalIsSet(SwarmManifestEntriesFlag.Name) {
+		currentConfig.MaxManifestEntries = ctx.GlobalInt(SwarmManifestEntriesFlag.Name)
+	}
+
+	if ctx.GlobalIsSet(SwarmManifestDepthFlag.Name) {
+		currentConfig.MaxManifestDepth = ctx.GlobalInt(SwarmManifestDepthFlag.Name)
+	}
+
 	return currentConfig
 
 }
@@ -292,6 +367,84 @@ func envVarsOverride(currentConfig *bzzapi.Config) (config *bzzapi.Config) {
 		currentConfig.BootNodes = bootnodes
 	}
 
+	if cache := os.Getenv(SWARM_ENV_HTTP_CACHE); cache != "" {
+		if size, err := strconv.ParseUint(cache, 10, 64); err == nil {
+			currentConfig.HTTPCache = size
+		}
+	}
+
+	if cache := os.Getenv(SWARM_ENV_HTTP_CACHE_DISK); cache != "" {
+		if size, err := strconv.ParseUint(cache, 10, 64); err == nil {
+			currentConfig.HTTPCacheDisk = size
+		}
+	}
+
+	if publishAPI := os.Getenv(SWARM_ENV_PUBLISH_API); publishAPI != "" {
+		currentConfig.PublishAPI = publishAPI
+	}
+
+	if contract := os.Getenv(SWARM_ENV_PUBLISH_CONTRACT); contract != "" {
+		currentConfig.PublishContract = common.HexToAddress(contract)
+	}
+
+	if from := os.Getenv(SWARM_ENV_PUBLISH_FROM); from != "" {
+		if number, err := strconv.ParseUint(from, 10, 64); err == nil {
+			currentConfig.PublishFrom = number
+		}
+	}
+
+	if refresh := os.Getenv(SWARM_ENV_PUBLISH_REFRESH); refresh != "" {
+		if secs, err := strconv.ParseUint(refresh, 10, 64); err == nil {
+			currentConfig.PublishRefresh = secs
+		}
+	}
+
+	if path := os.Getenv(SWARM_ENV_STORE_PATH); path != "" {
+		currentConfig.ChunkDbPath = path
+	}
+
+	if shards := os.Getenv(SWARM_ENV_STORE_SHARDS); shards != "" {
+		if params, err := parseStoreShards(shards, currentConfig.DbCapacity); err == nil {
+			currentConfig.Shards = params
+		}
+	}
+
+	if rate := os.Getenv(SWARM_ENV_RATELIMIT_STORE); rate != "" {
+		if r, err := strconv.ParseUint(rate, 10, 64); err == nil {
+			currentConfig.StoreRate = r
+		}
+	}
+
+	if rate := os.Getenv(SWARM_ENV_RATELIMIT_RETRIEVE); rate != "" {
+		if r, err := strconv.ParseUint(rate, 10, 64); err == nil {
+			currentConfig.RetrieveRate = r
+		}
+	}
+
+	if requests := os.Getenv(SWARM_ENV_RATELIMIT_REQUESTS); requests != "" {
+		if n, err := strconv.Atoi(requests); err == nil {
+			currentConfig.MaxRequests = n
+		}
+	}
+
+	if size := os.Getenv(SWARM_ENV_MANIFEST_MAXSIZE); size != "" {
+		if n, err := strconv.ParseInt(size, 10, 64); err == nil {
+			currentConfig.MaxManifestSize = n
+		}
+	}
+
+	if entries := os.Getenv(SWARM_ENV_MANIFEST_MAXENTRIES); entries != "" {
+		if n, err := strconv.Atoi(entries); err == nil {
+			currentConfig.MaxManifestEntries = n
+		}
+	}
+
+	if depth := os.Getenv(SWARM_ENV_MANIFEST_MAXDEPTH); depth != "" {
+		if n, err := strconv.Atoi(depth); err == nil {
+			currentConfig.MaxManifestDepth = n
+		}
+	}
+
 	return currentConfig
 }
 
@@ -333,9 +486,41 @@ func validateConfig(cfg *bzzapi.Config) (err error) {
 			}
 		}
 	}
+	if cfg.PublishAPI != "" {
+		if cfg.PublishContract == (common.Address{}) {
+			return errors.New("missing contract address of the publish API")
+		}
+		if cfg.PublishRefresh == 0 {
+			return errors.New("zero refresh interval of the published content")
+		}
+	}
 	return nil
 }
 
+// parseStoreShards parses a comma separated list of <directory>[:<chunks>] chunk
+// store shards, the capacity defaulting to the one of the whole chunk store.
+func parseStoreShards(spec string, capacity uint64) ([]*storage.ShardParams, error) {
+	var shards []*storage.ShardParams
+	for _, shard := range strings.Split(spec, ",") {
+		params := &storage.ShardParams{Path: shard, Capacity: capacity}
+		if i := strings.LastIndex(shard, ":"); i >= 0 {
+			size, err := strconv.ParseUint(shard[i+1:], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid capacity of shard %q: %v", shard, err)
+			}
+			params.Path, params.Capacity = shard[:i], size
+		}
+		if params.Path == "" {
+			return nil, fmt.Errorf("missing directory of shard %q", shard)
+		}
+		if params.Capacity == 0 {
+			return nil, fmt.Errorf("shard %q has no capacity", shard)
+		}
+		shards = append(shards, params)
+	}
+	return shards, nil
+}
+
 //validate EnsAPIs configuration parameter
 func validateEnsAPIs(s string) (err error) {
 	// missing contract address
@@ -22,12 +22,14 @@ import (
 	"io/ioutil"
 	"os"
 	"os/exec"
+	"reflect"
 	"testing"
 	"time"
 
 	"github.com/ethereum/go-ethereum/rpc"
 	"github.com/ethereum/go-ethereum/swarm"
 	"github.com/ethereum/go-ethereum/swarm/api"
+	"github.com/ethereum/go-ethereum/swarm/storage"
 
 	"github.com/docker/docker/pkg/reexec"
 )
@@ -552,3 +554,22 @@ func TestValidateConfig(t *testing.T) {
 		}
 	}
 }
+
+func TestParseStoreShards(t *testing.T) {
+	shards, err := parseStoreShards("/mnt/a:1000,/mnt/b", 5000)
+	if err != nil {
+		t.Fatalf("failed to parse shards: %v", err)
+	}
+	want := []*storage.ShardParams{
+		{Path: "/mnt/a", Capacity: 1000},
+		{Path: "/mnt/b", Capacity: 5000},
+	}
+	if !reflect.DeepEqual(shards, want) {
+		t.Errorf("shards mismatch: have %v, want %v", shards, want)
+	}
+	for _, spec := range []string{"", "/mnt/a:", ":1000", "/mnt/a:0", "/mnt/a,,/mnt/b"} {
+		if _, err := parseStoreShards(spec, 5000); err == nil {
+			t.Errorf("%q: invalid shards accepted", spec)
+		}
+	}
+}
@@ -22,6 +22,7 @@ import (
 	"os"
 
 	"github.com/ethereum/go-ethereum/cmd/utils"
+	bzzapi "github.com/ethereum/go-ethereum/swarm/api"
 	"github.com/ethereum/go-ethereum/swarm/storage"
 	"gopkg.in/urfave/cli.v1"
 )
@@ -42,6 +43,8 @@ func hash(ctx *cli.Context) {
 	key, err := chunker.Split(f, stat.Size(), nil, nil, nil)
 	if err != nil {
 		utils.Fatalf("%v\n", err)
+	} else if ctx.GlobalBool(SwarmCIDFlag.Name) {
+		fmt.Printf("%v\n", bzzapi.EncodeCID(key, true))
 	} else {
 		fmt.Printf("%v\n", key)
 	}
@@ -31,7 +31,7 @@ import (
 	"github.com/ethereum/go-ethereum/accounts"
 	"github.com/ethereum/go-ethereum/accounts/keystore"
 	"github.com/ethereum/go-ethereum/cmd/utils"
-	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/urlscheme"
 	"github.com/ethereum/go-ethereum/console"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/ethclient"
@@ -136,11 +136,87 @@ var (
 		Name:  "mime",
 		Usage: "force mime type",
 	}
+	SwarmCIDFlag = cli.BoolFlag{
+		Name:  "cid",
+		Usage: "print the hash as a CID for IPFS tooling",
+	}
 	CorsStringFlag = cli.StringFlag{
 		Name:   "corsdomain",
 		Usage:  "Domain on which to send Access-Control-Allow-Origin header (multiple domains can be supplied separated by a ',')",
 		EnvVar: SWARM_ENV_CORS,
 	}
+	SwarmHTTPCacheFlag = cli.Uint64Flag{
+		Name:   "httpcache",
+		Usage:  "Megabytes of documents served by the HTTP gateway to cache in memory (0 = disabled)",
+		Value:  bzzapi.DefaultHTTPCache,
+		EnvVar: SWARM_ENV_HTTP_CACHE,
+	}
+	SwarmHTTPCacheDiskFlag = cli.Uint64Flag{
+		Name:   "httpcache.disk",
+		Usage:  "Megabytes of documents served by the HTTP gateway to cache on disk (0 = disabled)",
+		EnvVar: SWARM_ENV_HTTP_CACHE_DISK,
+	}
+	SwarmPublishAPIFlag = cli.StringFlag{
+		Name:   "publish.api",
+		Usage:  "Chain RPC endpoint to watch for ContentPublished events, pinning the published content",
+		EnvVar: SWARM_ENV_PUBLISH_API,
+	}
+	SwarmPublishContractFlag = cli.StringFlag{
+		Name:   "publish.contract",
+		Usage:  "Address of the contract emitting the ContentPublished events",
+		EnvVar: SWARM_ENV_PUBLISH_CONTRACT,
+	}
+	SwarmPublishFromFlag = cli.Uint64Flag{
+		Name:   "publish.from",
+		Usage:  "First block to pin the published content from",
+		EnvVar: SWARM_ENV_PUBLISH_FROM,
+	}
+	SwarmPublishRefreshFlag = cli.Uint64Flag{
+		Name:   "publish.refresh",
+		Usage:  "Seconds between re-fetches of the pinned content",
+		Value:  bzzapi.DefaultPublishRefresh,
+		EnvVar: SWARM_ENV_PUBLISH_REFRESH,
+	}
+	SwarmStorePathFlag = cli.StringFlag{
+		Name:   "store.path",
+		Usage:  "Directory of the persistent chunk store (default = inside the swarm data directory)",
+		EnvVar: SWARM_ENV_STORE_PATH,
+	}
+	SwarmStoreShardsFlag = cli.StringFlag{
+		Name:   "store.shards",
+		Usage:  "Comma separated list of <directory>[:<chunks>] shards splitting the chunk store across disks",
+		EnvVar: SWARM_ENV_STORE_SHARDS,
+	}
+	SwarmStoreRateFlag = cli.Uint64Flag{
+		Name:   "ratelimit.store",
+		Usage:  "Bytes per second a single HTTP or RPC client may store (0 = unlimited)",
+		EnvVar: SWARM_ENV_RATELIMIT_STORE,
+	}
+	SwarmRetrieveRateFlag = cli.Uint64Flag{
+		Name:   "ratelimit.retrieve",
+		Usage:  "Bytes per second a single HTTP or RPC client may retrieve (0 = unlimited)",
+		EnvVar: SWARM_ENV_RATELIMIT_RETRIEVE,
+	}
+	SwarmClientRequestsFlag = cli.IntFlag{
+		Name:   "ratelimit.requests",
+		Usage:  "Maximum number of concurrently served requests of a single HTTP or RPC client (0 = unlimited)",
+		EnvVar: SWARM_ENV_RATELIMIT_REQUESTS,
+	}
+	SwarmManifestSizeFlag = cli.Int64Flag{
+		Name:   "manifest.maxsize",
+		Usage:  "Maximum size in bytes of a manifest loaded from the store (0 = unlimited)",
+		EnvVar: SWARM_ENV_MANIFEST_MAXSIZE,
+	}
+	SwarmManifestEntriesFlag = cli.IntFlag{
+		Name:   "manifest.maxentries",
+		Usage:  "Maximum number of entries of a manifest loaded from the store (0 = unlimited)",
+		EnvVar: SWARM_ENV_MANIFEST_MAXENTRIES,
+	}
+	SwarmManifestDepthFlag = cli.IntFlag{
+		Name:   "manifest.maxdepth",
+		Usage:  "Maximum nesting depth of manifests loaded from the store (0 = unlimited)",
+		EnvVar: SWARM_ENV_MANIFEST_MAXDEPTH,
+	}
 
 	// the following flags are deprecated and should be removed in the future
 	DeprecatedEthAPIFlag = cli.StringFlag{
@@ -212,7 +288,7 @@ Lists files and directories contained in a manifest.
 			Usage:     "print the swarm hash of a file or directory",
 			ArgsUsage: " <file>",
 			Description: `
-Prints the swarm hash of file or directory.
+Prints the swarm hash of file or directory, as a CID with --cid.
 `,
 		},
 		{
@@ -337,6 +413,20 @@ DEPRECATED: use 'swarm db clean'.
 		utils.PasswordFileFlag,
 		// bzzd-specific flags
 		CorsStringFlag,
+		SwarmHTTPCacheFlag,
+		SwarmHTTPCacheDiskFlag,
+		SwarmPublishAPIFlag,
+		SwarmPublishContractFlag,
+		SwarmPublishFromFlag,
+		SwarmPublishRefreshFlag,
+		SwarmStorePathFlag,
+		SwarmStoreShardsFlag,
+		SwarmStoreRateFlag,
+		SwarmRetrieveRateFlag,
+		SwarmClientRequestsFlag,
+		SwarmManifestSizeFlag,
+		SwarmManifestEntriesFlag,
+		SwarmManifestDepthFlag,
 		EnsAPIFlag,
 		SwarmTomlConfigPathFlag,
 		SwarmConfigPathFlag,
@@ -355,6 +445,7 @@ DEPRECATED: use 'swarm db clean'.
 		SwarmUploadDefaultPath,
 		SwarmUpFromStdinFlag,
 		SwarmUploadMimeType,
+		SwarmCIDFlag,
 		//deprecated flags
 		DeprecatedEthAPIFlag,
 		DeprecatedEnsAddrFlag,
@@ -490,8 +581,8 @@ func getAccount(bzzaccount string, ctx *cli.Context, stack *node.Node) *ecdsa.Pr
 func decryptStoreAccount(ks *keystore.KeyStore, account string, passwords []string) *ecdsa.PrivateKey {
 	var a accounts.Account
 	var err error
-	if common.IsHexAddress(account) {
-		a, err = ks.Find(accounts.Account{Address: common.HexToAddress(account)})
+	if addr, perr := urlscheme.ParseAddress(account); perr == nil {
+		a, err = ks.Find(accounts.Account{Address: addr})
 	} else if ix, ixerr := strconv.Atoi(account); ixerr == nil && ix > 0 {
 		if accounts := ks.Accounts(); len(accounts) > ix {
 			a = accounts[ix]
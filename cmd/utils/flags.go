@@ -29,25 +29,38 @@ import (
 	"strings"
 
 	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/accounts/keystore"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/fdlimit"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/urlscheme"
 	"github.com/ethereum/go-ethereum/consensus"
 	"github.com/ethereum/go-ethereum/consensus/clique"
 	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/consensus/istanbul"
+	"github.com/ethereum/go-ethereum/contracts/ens"
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/core/vm"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/dashboard"
 	"github.com/ethereum/go-ethereum/eth"
+	"github.com/ethereum/go-ethereum/eth/bridge"
 	"github.com/ethereum/go-ethereum/eth/downloader"
+	"github.com/ethereum/go-ethereum/eth/exporter"
 	"github.com/ethereum/go-ethereum/eth/gasprice"
+	"github.com/ethereum/go-ethereum/eth/relayer"
+	"github.com/ethereum/go-ethereum/eth/telemetry"
+	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/ethstats"
+	"github.com/ethereum/go-ethereum/internal/ethapi"
 	"github.com/ethereum/go-ethereum/les"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/miner"
 	"github.com/ethereum/go-ethereum/node"
 	"github.com/ethereum/go-ethereum/p2p"
 	"github.com/ethereum/go-ethereum/p2p/discover"
@@ -55,6 +68,10 @@ import (
 	"github.com/ethereum/go-ethereum/p2p/nat"
 	"github.com/ethereum/go-ethereum/p2p/netutil"
 	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethereum/go-ethereum/swarm/api/client"
+	"github.com/ethereum/go-ethereum/swarm/services/backup"
+	"github.com/ethereum/go-ethereum/swarm/services/snapshot"
 	whisper "github.com/ethereum/go-ethereum/whisper/whisperv5"
 	"gopkg.in/urfave/cli.v1"
 )
@@ -122,10 +139,18 @@ var (
 		Name:  "keystore",
 		Usage: "Directory for the keystore (default = inside the datadir)",
 	}
+	RestoreFlag = DirectoryFlag{
+		Name:  "restore",
+		Usage: "Node backup to restore into the datadir before starting (see admin.backup)",
+	}
 	NoUSBFlag = cli.BoolFlag{
 		Name:  "nousb",
 		Usage: "Disables monitoring for and managing USB hardware wallets",
 	}
+	ExternalSignerFlag = cli.StringFlag{
+		Name:  "signer",
+		Usage: "External signer (IPC path or url) to forward all signing requests to",
+	}
 	NetworkIdFlag = cli.Uint64Flag{
 		Name:  "networkid",
 		Usage: "Network identifier (integer, 1=Frontier, 2=Morden (disused), 3=Ropsten, 4=Rinkeby, 5=Ottoman)",
@@ -179,6 +204,14 @@ var (
 		Usage: `Blockchain garbage collection mode ("full", "archive")`,
 		Value: "full",
 	}
+	ReceiptsRetentionFlag = cli.Uint64Flag{
+		Name:  "receipts.retention",
+		Usage: "Number of recent blocks to keep transaction receipts of (0 = keep all)",
+	}
+	IndexesFlag = cli.StringFlag{
+		Name:  "index",
+		Usage: `Comma separated secondary chain indexes to maintain ("addresses", "transfers" requiring --gcmode=archive)`,
+	}
 	LightServFlag = cli.IntFlag{
 		Name:  "lightserv",
 		Usage: "Maximum percentage of time allowed for serving LES requests (0-90)",
@@ -193,6 +226,43 @@ var (
 		Name:  "lightkdf",
 		Usage: "Reduce key-derivation RAM & CPU usage at some expense of KDF strength",
 	}
+	KDFFlag = cli.StringFlag{
+		Name:  "kdf",
+		Usage: `Key-derivation function new and updated keys are encrypted with ("scrypt" or "argon2id")`,
+		Value: keystore.StandardKDF.KDF,
+	}
+	KDFScryptNFlag = cli.IntFlag{
+		Name:  "kdf.scrypt.n",
+		Usage: "Scrypt CPU/memory cost parameter N, a power of two (default = 262144, 4096 with --lightkdf)",
+	}
+	KDFScryptPFlag = cli.IntFlag{
+		Name:  "kdf.scrypt.p",
+		Usage: "Scrypt parallelization parameter P (default = 1, 6 with --lightkdf)",
+	}
+	KDFArgon2TimeFlag = cli.UintFlag{
+		Name:  "kdf.argon2.time",
+		Usage: "Argon2id number of passes over the memory (default = 3, 1 with --lightkdf)",
+	}
+	KDFArgon2MemoryFlag = cli.UintFlag{
+		Name:  "kdf.argon2.memory",
+		Usage: "Argon2id memory in KiB (default = 262144, 4096 with --lightkdf)",
+	}
+	KDFArgon2ThreadsFlag = cli.UintFlag{
+		Name:  "kdf.argon2.threads",
+		Usage: "Argon2id number of threads (default = 4, 1 with --lightkdf)",
+	}
+	TxApprovalAccountsFlag = cli.StringFlag{
+		Name:  "txapproval.accounts",
+		Usage: "Comma separated accounts whose transactions require multi-signature approval",
+	}
+	TxApprovalApproversFlag = cli.StringFlag{
+		Name:  "txapproval.approvers",
+		Usage: "Comma separated keys allowed to approve the transactions of guarded accounts",
+	}
+	TxApprovalThresholdFlag = cli.IntFlag{
+		Name:  "txapproval.threshold",
+		Usage: "Approvals required to send a transaction of a guarded account",
+	}
 	// Dashboard settings
 	DashboardEnabledFlag = cli.BoolFlag{
 		Name:  "dashboard",
@@ -293,6 +363,14 @@ var (
 		Usage: "Maximum amount of time non-executable transaction are queued",
 		Value: eth.DefaultConfig.TxPool.Lifetime,
 	}
+	TxPoolValidatorBroadcastFlag = cli.BoolFlag{
+		Name:  "txpool.validatorbroadcast",
+		Usage: "Propagate transactions to validator peers only (Istanbul)",
+	}
+	TxPoolSqrtBroadcastFlag = cli.BoolFlag{
+		Name:  "txpool.sqrtbroadcast",
+		Usage: "Propagate each transaction to the square root of the eligible peers only",
+	}
 	// Performance tuning settings
 	CacheFlag = cli.IntFlag{
 		Name:  "cache",
@@ -302,18 +380,36 @@ var (
 	CacheDatabaseFlag = cli.IntFlag{
 		Name:  "cache.database",
 		Usage: "Percentage of cache memory allowance to use for database io",
-		Value: 75,
+		Value: 50,
+	}
+	CacheTrieFlag = cli.IntFlag{
+		Name:  "cache.trie",
+		Usage: "Percentage of cache memory allowance to use for caching trie nodes read from disk",
+		Value: 25,
 	}
 	CacheGCFlag = cli.IntFlag{
 		Name:  "cache.gc",
 		Usage: "Percentage of cache memory allowance to use for trie pruning",
 		Value: 25,
 	}
+	CacheAsyncIndexFlag = cli.BoolFlag{
+		Name:  "cache.asyncindex",
+		Usage: "Write transaction lookups of imported blocks in the background",
+	}
+	DBCompressionFlag = cli.StringFlag{
+		Name:  "db.compression",
+		Usage: `Compression of the block bodies and receipts written into the database ("none" or "snappy")`,
+		Value: string(core.CompressionNone),
+	}
 	TrieCacheGenFlag = cli.IntFlag{
 		Name:  "trie-cache-gens",
 		Usage: "Number of trie node generations to keep in memory",
 		Value: int(state.MaxTrieCacheGen),
 	}
+	ParallelTxsFlag = cli.IntFlag{
+		Name:  "paralleltxs",
+		Usage: "Number of transactions of imported blocks to execute in parallel (0 = serial execution)",
+	}
 	// Miner settings
 	MiningEnabledFlag = cli.BoolFlag{
 		Name:  "mine",
@@ -339,6 +435,11 @@ var (
 		Usage: "Minimal gas price to accept for mining a transactions",
 		Value: eth.DefaultConfig.GasPrice,
 	}
+	TxOrderingFlag = cli.StringFlag{
+		Name:  "txordering",
+		Usage: `Order of the transactions in mined blocks ("price" = highest gas price first, "fifo" = arrival order, "fairshare" = round robin across senders)`,
+		Value: string(miner.OrderByPrice),
+	}
 	ExtraDataFlag = cli.StringFlag{
 		Name:  "extradata",
 		Usage: "Block extra data set by the miner (default = client version)",
@@ -364,6 +465,133 @@ var (
 		Name:  "ethstats",
 		Usage: "Reporting URL of a ethstats service (nodename:secret@host:port)",
 	}
+	TelemetryURLFlag = cli.StringFlag{
+		Name:  "telemetry",
+		Usage: "Reporting URL of a validator telemetry collector (nodename:secret@host:port)",
+	}
+	TelemetryIntervalFlag = cli.DurationFlag{
+		Name:  "telemetry.interval",
+		Usage: "Time between periodic telemetry reports, on top of the ones of new blocks",
+		Value: telemetry.DefaultConfig.Interval,
+	}
+	SnapshotIntervalFlag = cli.Uint64Flag{
+		Name:  "snapshot.interval",
+		Usage: "Number of blocks between state snapshots stored in swarm (0 = disabled)",
+	}
+	SnapshotGatewayFlag = cli.StringFlag{
+		Name:  "snapshot.bzzapi",
+		Usage: "Swarm HTTP gateway to store and retrieve state snapshots through",
+		Value: client.DefaultGateway,
+	}
+	BackupRangeFlag = cli.Uint64Flag{
+		Name:  "backup.range",
+		Usage: "Number of finalized blocks per range published into swarm (0 = disabled)",
+	}
+	BackupGatewayFlag = cli.StringFlag{
+		Name:  "backup.bzzapi",
+		Usage: "Swarm HTTP gateway to publish the chain through",
+		Value: client.DefaultGateway,
+	}
+	BackupNameFlag = cli.StringFlag{
+		Name:  "backup.name",
+		Usage: "ENS name to register the published chain under, using the etherbase account",
+	}
+	BackupENSAddrFlag = cli.StringFlag{
+		Name:  "backup.ensaddr",
+		Usage: "Address of the ENS registry to register the published chain with",
+	}
+	ExporterWebhookFlag = cli.StringFlag{
+		Name:  "exporter.webhook",
+		Usage: "HTTP endpoint to post the logs of finalized blocks to",
+	}
+	ExporterKafkaFlag = cli.StringFlag{
+		Name:  "exporter.kafka",
+		Usage: "Kafka REST proxy to produce the logs of finalized blocks through",
+	}
+	ExporterKafkaTopicFlag = cli.StringFlag{
+		Name:  "exporter.kafkatopic",
+		Usage: "Kafka topic to produce the exported logs into",
+		Value: "ethereum-logs",
+	}
+	ExporterAddressesFlag = cli.StringFlag{
+		Name:  "exporter.addresses",
+		Usage: "Comma separated contract addresses to export the logs of (default = all)",
+	}
+	ExporterTopicsFlag = cli.StringFlag{
+		Name:  "exporter.topics",
+		Usage: "Comma separated event signature hashes (first log topic) to export (default = all)",
+	}
+	ExporterFromFlag = cli.Uint64Flag{
+		Name:  "exporter.from",
+		Usage: "Block to start exporting from if no progress is stored yet",
+	}
+	RelayerAccountFlag = cli.StringFlag{
+		Name:  "relayer.account",
+		Usage: "Unlocked account paying for relayed meta-transactions (enables the relayer)",
+	}
+	RelayerGasPriceFlag = BigFlag{
+		Name:  "relayer.gasprice",
+		Usage: "Gas price of relayed meta-transactions (default = transaction pool minimum)",
+	}
+	RelayerMaxGasFlag = cli.Uint64Flag{
+		Name:  "relayer.maxgas",
+		Usage: "Maximum gas of a single relayed meta-transaction (0 = block gas limit)",
+	}
+	RelayerQuotaFlag = cli.Uint64Flag{
+		Name:  "relayer.quota",
+		Usage: "Meta-transactions relayed per origin and quota period (0 = unlimited)",
+		Value: relayer.DefaultConfig.Quota,
+	}
+	RelayerQuotaPeriodFlag = cli.DurationFlag{
+		Name:  "relayer.quotaperiod",
+		Usage: "Period over which the relayer quotas are counted",
+		Value: relayer.DefaultConfig.QuotaPeriod,
+	}
+	RelayerTargetsFlag = cli.StringFlag{
+		Name:  "relayer.targets",
+		Usage: "Comma separated contract addresses to relay calls to (default = all)",
+	}
+	BridgeEndpointFlag = cli.StringFlag{
+		Name:  "bridge.endpoint",
+		Usage: "RPC endpoint of the chain to relay bridge events to (enables the bridge relay)",
+	}
+	BridgeContractFlag = cli.StringFlag{
+		Name:  "bridge.contract",
+		Usage: "Bridge contract of this chain emitting the lock and burn events to relay",
+	}
+	BridgeEventsFlag = cli.StringFlag{
+		Name:  "bridge.events",
+		Usage: "Comma separated signature hashes of the bridge events to relay (default = all)",
+	}
+	BridgeDestinationFlag = cli.StringFlag{
+		Name:  "bridge.destination",
+		Usage: "Bridge contract of the destination chain to submit the events to",
+	}
+	BridgeChainIDFlag = cli.Uint64Flag{
+		Name:  "bridge.chainid",
+		Usage: "Chain ID of the destination chain",
+	}
+	BridgeAccountFlag = cli.StringFlag{
+		Name:  "bridge.account",
+		Usage: "Unlocked account submitting the bridge events to the destination chain",
+	}
+	BridgeGasFlag = cli.Uint64Flag{
+		Name:  "bridge.gas",
+		Usage: "Gas allowance of the bridge event submissions (0 = estimate)",
+	}
+	BridgeGasPriceFlag = BigFlag{
+		Name:  "bridge.gasprice",
+		Usage: "Gas price of the bridge event submissions (default = suggested by the destination)",
+	}
+	BridgeFormatFlag = cli.StringFlag{
+		Name:  "bridge.format",
+		Usage: "Message format accepted by the destination bridge contract (abi, rlp)",
+		Value: bridge.DefaultConfig.Format,
+	}
+	BridgeFromFlag = cli.Uint64Flag{
+		Name:  "bridge.from",
+		Usage: "Block to start relaying from if no progress is stored yet",
+	}
 	MetricsEnabledFlag = cli.BoolFlag{
 		Name:  metrics.MetricsEnabledFlag,
 		Usage: "Enable metrics collection and reporting",
@@ -401,11 +629,84 @@ var (
 		Usage: "Comma separated list of virtual hostnames from which to accept requests (server enforced). Accepts '*' wildcard.",
 		Value: strings.Join(node.DefaultConfig.HTTPVirtualHosts, ","),
 	}
+	RPCReadTimeoutFlag = cli.DurationFlag{
+		Name:  "rpcreadtimeout",
+		Usage: "Maximum duration for reading an entire HTTP-RPC request (0 = no limit)",
+		Value: rpc.DefaultHTTPOptions.ReadTimeout,
+	}
+	RPCWriteTimeoutFlag = cli.DurationFlag{
+		Name:  "rpcwritetimeout",
+		Usage: "Maximum duration for writing an HTTP-RPC response (0 = no limit)",
+		Value: rpc.DefaultHTTPOptions.WriteTimeout,
+	}
+	RPCIdleTimeoutFlag = cli.DurationFlag{
+		Name:  "rpcidletimeout",
+		Usage: "Maximum time to keep idle HTTP-RPC keep-alive connections open (0 = read timeout)",
+		Value: rpc.DefaultHTTPOptions.IdleTimeout,
+	}
+	RPCNoKeepAliveFlag = cli.BoolFlag{
+		Name:  "rpcnokeepalive",
+		Usage: "Close HTTP-RPC connections after each response",
+	}
+	RPCNoCompressionFlag = cli.BoolFlag{
+		Name:  "rpcnocompression",
+		Usage: "Disable gzip/deflate compression of HTTP-RPC responses",
+	}
 	RPCApiFlag = cli.StringFlag{
 		Name:  "rpcapi",
 		Usage: "API's offered over the HTTP-RPC interface",
 		Value: "",
 	}
+	RPCAuthFileFlag = cli.StringFlag{
+		Name:  "rpcauth",
+		Usage: "JSON file with the JWT secret and per-token ACLs enforced on the HTTP-RPC and WS-RPC interfaces",
+		Value: "",
+	}
+	RPCBatchLimitFlag = cli.IntFlag{
+		Name:  "rpcbatchlimit",
+		Usage: "Maximum number of requests in a single IPC/WS-RPC batch (0 = unlimited)",
+	}
+	RPCResponseLimitFlag = cli.IntFlag{
+		Name:  "rpcresponselimit",
+		Usage: "Maximum size in bytes of a single IPC/WS-RPC response (0 = unlimited)",
+	}
+	RPCInFlightLimitFlag = cli.IntFlag{
+		Name:  "rpcinflightlimit",
+		Usage: "Maximum number of concurrently executing requests per IPC/WS-RPC connection (0 = unlimited)",
+	}
+	RPCRateLimitFlag = cli.Float64Flag{
+		Name:  "rpcratelimit",
+		Usage: "Sustained requests per second allowed per IPC/WS-RPC connection (0 = unlimited)",
+	}
+	RPCRateBurstFlag = cli.IntFlag{
+		Name:  "rpcrateburst",
+		Usage: "Maximum burst of requests above the sustained rate per IPC/WS-RPC connection",
+	}
+	RPCSlowQueryFlag = cli.DurationFlag{
+		Name:  "rpcslowquery",
+		Usage: "Log IPC/HTTP/WS-RPC calls executing longer than this, with their client and parameters (0 = disabled)",
+	}
+	RPCGasCapFlag = cli.Uint64Flag{
+		Name:  "rpcgascap",
+		Usage: "Maximum gas of eth_call and eth_estimateGas executions (0 = no cap)",
+	}
+	RPCEVMTimeoutFlag = cli.DurationFlag{
+		Name:  "rpcevmtimeout",
+		Usage: "Maximum execution time of eth_call, eth_estimateGas and debug_trace* executions (0 = no limit)",
+		Value: eth.DefaultConfig.RPCEVMTimeout,
+	}
+	RPCLogsBlockRangeFlag = cli.Uint64Flag{
+		Name:  "rpclogsblockrange",
+		Usage: "Maximum number of blocks a single eth_getLogs query may span (0 = unlimited)",
+	}
+	RPCLogsMaxResultsFlag = cli.IntFlag{
+		Name:  "rpclogsmaxresults",
+		Usage: "Maximum number of logs a single eth_getLogs query may return (0 = unlimited)",
+	}
+	RPCUnlockTimeoutFlag = cli.DurationFlag{
+		Name:  "rpcunlocktimeout",
+		Usage: "Maximum duration accounts stay unlocked via personal_unlockAccount, relocking them even if unlocked indefinitely (0 = unlimited)",
+	}
 	IPCDisabledFlag = cli.BoolFlag{
 		Name:  "ipcdisable",
 		Usage: "Disable the IPC-RPC server",
@@ -488,7 +789,7 @@ var (
 	}
 	NATFlag = cli.StringFlag{
 		Name:  "nat",
-		Usage: "NAT port mapping mechanism (any|none|upnp|pmp|extip:<IP>)",
+		Usage: "NAT port mapping mechanism (any|none|upnp|pmp|extip:<IP>|stun[:<host:port>])",
 		Value: "any",
 	}
 	NoDiscoverFlag = cli.BoolFlag{
@@ -512,6 +813,11 @@ var (
 	}
 
 	// Gas price oracle settings
+	GpoModeFlag = cli.StringFlag{
+		Name:  "gpomode",
+		Usage: `Gas price suggestion strategy ("percentile", "fixed" at --gasprice or "zero")`,
+		Value: gasprice.ModePercentile,
+	}
 	GpoBlocksFlag = cli.IntFlag{
 		Name:  "gpoblocks",
 		Usage: "Number of recent blocks to check for gas prices",
@@ -543,11 +849,63 @@ var (
 		Usage: "Timeout for each Istanbul round in milliseconds",
 		Value: eth.DefaultConfig.Istanbul.RequestTimeout,
 	}
+	IstanbulMinRequestTimeoutFlag = cli.Uint64Flag{
+		Name:  "istanbul.mintimeout",
+		Usage: "Lower bound of the round timeout adapting to the observed network latency in milliseconds",
+	}
+	IstanbulMaxRequestTimeoutFlag = cli.Uint64Flag{
+		Name:  "istanbul.maxtimeout",
+		Usage: "Upper bound of the round timeout adapting to the observed network latency in milliseconds (0 = fixed request timeout)",
+	}
 	IstanbulBlockPeriodFlag = cli.Uint64Flag{
 		Name:  "istanbul.blockperiod",
 		Usage: "Default minimum difference between two consecutive block's timestamps in seconds",
 		Value: eth.DefaultConfig.Istanbul.BlockPeriod,
 	}
+	IstanbulNTPServersFlag = cli.StringFlag{
+		Name:  "istanbul.ntpservers",
+		Usage: "Comma separated NTP servers to measure the local clock drift against",
+	}
+	IstanbulMaxClockDriftFlag = cli.Uint64Flag{
+		Name:  "istanbul.maxclockdrift",
+		Usage: "Local clock drift in milliseconds above which to alert (0 = disabled)",
+		Value: eth.DefaultConfig.Istanbul.MaxClockDrift,
+	}
+	IstanbulRefuseOnDriftFlag = cli.BoolFlag{
+		Name:  "istanbul.refuseondrift",
+		Usage: "Refuse to propose blocks while the local clock runs ahead by more than the maximum drift",
+	}
+	IstanbulAnnouncePeriodFlag = cli.Uint64Flag{
+		Name:  "istanbul.announceperiod",
+		Usage: "Interval in seconds between the broadcasts of the finalized head to lagging peers (0 = disabled)",
+		Value: eth.DefaultConfig.Istanbul.AnnouncePeriod,
+	}
+	IstanbulArchiveFlag = cli.Uint64Flag{
+		Name:  "istanbul.archive",
+		Usage: "Number of recent sequences to archive the consensus messages of for replaying (0 = disabled)",
+	}
+	IstanbulFeaturesFlag = cli.StringFlag{
+		Name:  "istanbul.features",
+		Usage: "Comma separated feature flags to attest to peers along with the client version",
+	}
+	IstanbulUpgradeSignalFlag = cli.StringFlag{
+		Name:  "istanbul.signal",
+		Usage: "Name of the protocol upgrade to signal readiness for in proposed blocks",
+	}
+	IstanbulSigningFlag = cli.StringFlag{
+		Name:  "istanbul.signing",
+		Usage: `Consensus message signature domain ("legacy" = unbound, "domain" = bound to the chain, "strict" = bound, rejecting unbound ones)`,
+		Value: "legacy",
+	}
+	IstanbulAuditFlag = DirectoryFlag{
+		Name:  "istanbul.audit",
+		Usage: "Directory to keep a hash chained audit trail of the received proposals, sent votes and committed blocks in",
+	}
+	IstanbulAuditFormatFlag = cli.StringFlag{
+		Name:  "istanbul.auditformat",
+		Usage: `Format of the audit trail files ("json" or "csv")`,
+		Value: "json",
+	}
 )
 
 // MakeDataDir retrieves the currently requested data directory, terminating
@@ -714,6 +1072,47 @@ func setHTTP(ctx *cli.Context, cfg *node.Config) {
 	if ctx.GlobalIsSet(RPCVirtualHostsFlag.Name) {
 		cfg.HTTPVirtualHosts = splitAndTrim(ctx.GlobalString(RPCVirtualHostsFlag.Name))
 	}
+	if ctx.GlobalIsSet(RPCAuthFileFlag.Name) {
+		cfg.RPCAuthFile = ctx.GlobalString(RPCAuthFileFlag.Name)
+	}
+	if ctx.GlobalIsSet(RPCReadTimeoutFlag.Name) {
+		cfg.HTTPOptions.ReadTimeout = ctx.GlobalDuration(RPCReadTimeoutFlag.Name)
+	}
+	if ctx.GlobalIsSet(RPCWriteTimeoutFlag.Name) {
+		cfg.HTTPOptions.WriteTimeout = ctx.GlobalDuration(RPCWriteTimeoutFlag.Name)
+	}
+	if ctx.GlobalIsSet(RPCIdleTimeoutFlag.Name) {
+		cfg.HTTPOptions.IdleTimeout = ctx.GlobalDuration(RPCIdleTimeoutFlag.Name)
+	}
+	if ctx.GlobalIsSet(RPCNoKeepAliveFlag.Name) {
+		cfg.HTTPOptions.DisableKeepAlives = ctx.GlobalBool(RPCNoKeepAliveFlag.Name)
+	}
+	if ctx.GlobalIsSet(RPCNoCompressionFlag.Name) {
+		cfg.HTTPOptions.DisableCompression = ctx.GlobalBool(RPCNoCompressionFlag.Name)
+	}
+}
+
+// setRPCLimits applies the per connection RPC resource limits and the slow query
+// threshold from the command line flags.
+func setRPCLimits(ctx *cli.Context, cfg *node.Config) {
+	if ctx.GlobalIsSet(RPCBatchLimitFlag.Name) {
+		cfg.RPCLimits.BatchItems = ctx.GlobalInt(RPCBatchLimitFlag.Name)
+	}
+	if ctx.GlobalIsSet(RPCResponseLimitFlag.Name) {
+		cfg.RPCLimits.ResponseSize = ctx.GlobalInt(RPCResponseLimitFlag.Name)
+	}
+	if ctx.GlobalIsSet(RPCInFlightLimitFlag.Name) {
+		cfg.RPCLimits.InFlight = ctx.GlobalInt(RPCInFlightLimitFlag.Name)
+	}
+	if ctx.GlobalIsSet(RPCRateLimitFlag.Name) {
+		cfg.RPCLimits.Rate = ctx.GlobalFloat64(RPCRateLimitFlag.Name)
+	}
+	if ctx.GlobalIsSet(RPCRateBurstFlag.Name) {
+		cfg.RPCLimits.Burst = ctx.GlobalInt(RPCRateBurstFlag.Name)
+	}
+	if ctx.GlobalIsSet(RPCSlowQueryFlag.Name) {
+		cfg.RPCSlowQuery = ctx.GlobalDuration(RPCSlowQueryFlag.Name)
+	}
 }
 
 // setWS creates the WebSocket RPC listener interface string from the set
@@ -767,12 +1166,12 @@ func makeDatabaseHandles() int {
 	return limit / 2 // Leave half for networking and other stuff
 }
 
-// MakeAddress converts an account specified directly as a hex encoded string or
-// a key index in the key store to an internal account representation.
+// MakeAddress converts an account specified directly as a hex encoded string, an
+// address URI or a key index in the key store to an internal account representation.
 func MakeAddress(ks *keystore.KeyStore, account string) (accounts.Account, error) {
 	// If the specified account is a valid address, return it
-	if common.IsHexAddress(account) {
-		return accounts.Account{Address: common.HexToAddress(account)}, nil
+	if addr, err := urlscheme.ParseAddress(account); err == nil {
+		return accounts.Account{Address: addr}, nil
 	}
 	// Otherwise try to interpret the account as a keystore index
 	index, err := strconv.Atoi(account)
@@ -895,6 +1294,7 @@ func SetNodeConfig(ctx *cli.Context, cfg *node.Config) {
 	setIPC(ctx, cfg)
 	setHTTP(ctx, cfg)
 	setWS(ctx, cfg)
+	setRPCLimits(ctx, cfg)
 	setNodeUserIdent(ctx, cfg)
 
 	switch {
@@ -916,12 +1316,45 @@ func SetNodeConfig(ctx *cli.Context, cfg *node.Config) {
 	if ctx.GlobalIsSet(LightKDFFlag.Name) {
 		cfg.UseLightweightKDF = ctx.GlobalBool(LightKDFFlag.Name)
 	}
+	setKDF(ctx, cfg)
 	if ctx.GlobalIsSet(NoUSBFlag.Name) {
 		cfg.NoUSB = ctx.GlobalBool(NoUSBFlag.Name)
 	}
+	if ctx.GlobalIsSet(ExternalSignerFlag.Name) {
+		cfg.ExternalSigner = ctx.GlobalString(ExternalSignerFlag.Name)
+	}
+}
+
+// setKDF applies the key store KDF flags to the node config.
+func setKDF(ctx *cli.Context, cfg *node.Config) {
+	if ctx.GlobalIsSet(KDFFlag.Name) {
+		cfg.KeyStoreKDF.KDF = ctx.GlobalString(KDFFlag.Name)
+	}
+	if ctx.GlobalIsSet(KDFScryptNFlag.Name) {
+		cfg.KeyStoreKDF.ScryptN = ctx.GlobalInt(KDFScryptNFlag.Name)
+	}
+	if ctx.GlobalIsSet(KDFScryptPFlag.Name) {
+		cfg.KeyStoreKDF.ScryptP = ctx.GlobalInt(KDFScryptPFlag.Name)
+	}
+	if ctx.GlobalIsSet(KDFArgon2TimeFlag.Name) {
+		cfg.KeyStoreKDF.Argon2Time = uint32(ctx.GlobalUint(KDFArgon2TimeFlag.Name))
+	}
+	if ctx.GlobalIsSet(KDFArgon2MemoryFlag.Name) {
+		cfg.KeyStoreKDF.Argon2Memory = uint32(ctx.GlobalUint(KDFArgon2MemoryFlag.Name))
+	}
+	if ctx.GlobalIsSet(KDFArgon2ThreadsFlag.Name) {
+		threads := ctx.GlobalUint(KDFArgon2ThreadsFlag.Name)
+		if threads > 255 {
+			Fatalf("--%s must be at most 255", KDFArgon2ThreadsFlag.Name)
+		}
+		cfg.KeyStoreKDF.Argon2Threads = uint8(threads)
+	}
 }
 
 func setGPO(ctx *cli.Context, cfg *gasprice.Config) {
+	if ctx.GlobalIsSet(GpoModeFlag.Name) {
+		cfg.Mode = ctx.GlobalString(GpoModeFlag.Name)
+	}
 	if ctx.GlobalIsSet(GpoBlocksFlag.Name) {
 		cfg.Blocks = ctx.GlobalInt(GpoBlocksFlag.Name)
 	}
@@ -963,6 +1396,43 @@ func setTxPool(ctx *cli.Context, cfg *core.TxPoolConfig) {
 	}
 }
 
+func setTxBroadcast(ctx *cli.Context, cfg *eth.TxBroadcastConfig) {
+	if ctx.GlobalIsSet(TxPoolValidatorBroadcastFlag.Name) {
+		cfg.ValidatorsOnly = ctx.GlobalBool(TxPoolValidatorBroadcastFlag.Name)
+	}
+	if ctx.GlobalIsSet(TxPoolSqrtBroadcastFlag.Name) {
+		cfg.SqrtFanout = ctx.GlobalBool(TxPoolSqrtBroadcastFlag.Name)
+	}
+}
+
+func setTxApproval(ctx *cli.Context, cfg *ethapi.ApprovalConfig) {
+	if ctx.GlobalIsSet(TxApprovalAccountsFlag.Name) {
+		cfg.Accounts = parseAddresses(TxApprovalAccountsFlag.Name, ctx.GlobalString(TxApprovalAccountsFlag.Name))
+	}
+	if ctx.GlobalIsSet(TxApprovalApproversFlag.Name) {
+		cfg.Approvers = parseAddresses(TxApprovalApproversFlag.Name, ctx.GlobalString(TxApprovalApproversFlag.Name))
+	}
+	if ctx.GlobalIsSet(TxApprovalThresholdFlag.Name) {
+		cfg.Threshold = ctx.GlobalInt(TxApprovalThresholdFlag.Name)
+	}
+	if err := cfg.Validate(); err != nil {
+		Fatalf("Invalid transaction approval settings: %v", err)
+	}
+}
+
+// parseAddresses parses a comma separated list of addresses given to a flag.
+func parseAddresses(flag string, list string) []common.Address {
+	var addrs []common.Address
+	for _, s := range splitAndTrim(list) {
+		addr, err := urlscheme.ParseAddress(s)
+		if err != nil {
+			Fatalf("Invalid address in --%s: %v", flag, err)
+		}
+		addrs = append(addrs, addr)
+	}
+	return addrs
+}
+
 func setEthash(ctx *cli.Context, cfg *eth.Config) {
 	if ctx.GlobalIsSet(EthashCacheDirFlag.Name) {
 		cfg.Ethash.CacheDir = ctx.GlobalString(EthashCacheDirFlag.Name)
@@ -988,9 +1458,59 @@ func setIstanbul(ctx *cli.Context, cfg *eth.Config) {
 	if ctx.GlobalIsSet(IstanbulRequestTimeoutFlag.Name) {
 		cfg.Istanbul.RequestTimeout = ctx.GlobalUint64(IstanbulRequestTimeoutFlag.Name)
 	}
+	if ctx.GlobalIsSet(IstanbulMinRequestTimeoutFlag.Name) {
+		cfg.Istanbul.MinRequestTimeout = ctx.GlobalUint64(IstanbulMinRequestTimeoutFlag.Name)
+	}
+	if ctx.GlobalIsSet(IstanbulMaxRequestTimeoutFlag.Name) {
+		cfg.Istanbul.MaxRequestTimeout = ctx.GlobalUint64(IstanbulMaxRequestTimeoutFlag.Name)
+	}
 	if ctx.GlobalIsSet(IstanbulBlockPeriodFlag.Name) {
 		cfg.Istanbul.BlockPeriod = ctx.GlobalUint64(IstanbulBlockPeriodFlag.Name)
 	}
+	if ctx.GlobalIsSet(IstanbulNTPServersFlag.Name) {
+		cfg.Istanbul.NTPServers = strings.Split(ctx.GlobalString(IstanbulNTPServersFlag.Name), ",")
+	}
+	if ctx.GlobalIsSet(IstanbulMaxClockDriftFlag.Name) {
+		cfg.Istanbul.MaxClockDrift = ctx.GlobalUint64(IstanbulMaxClockDriftFlag.Name)
+	}
+	if ctx.GlobalIsSet(IstanbulRefuseOnDriftFlag.Name) {
+		cfg.Istanbul.RefuseOnDrift = true
+	}
+	if ctx.GlobalIsSet(IstanbulAnnouncePeriodFlag.Name) {
+		cfg.Istanbul.AnnouncePeriod = ctx.GlobalUint64(IstanbulAnnouncePeriodFlag.Name)
+	}
+	if ctx.GlobalIsSet(IstanbulArchiveFlag.Name) {
+		cfg.Istanbul.ArchiveRetention = ctx.GlobalUint64(IstanbulArchiveFlag.Name)
+	}
+	if ctx.GlobalIsSet(IstanbulFeaturesFlag.Name) {
+		cfg.Istanbul.BuildFeatures = strings.Split(ctx.GlobalString(IstanbulFeaturesFlag.Name), ",")
+	}
+	if ctx.GlobalIsSet(IstanbulUpgradeSignalFlag.Name) {
+		cfg.Istanbul.UpgradeSignal = ctx.GlobalString(IstanbulUpgradeSignalFlag.Name)
+	}
+	if ctx.GlobalIsSet(IstanbulSigningFlag.Name) {
+		switch mode := ctx.GlobalString(IstanbulSigningFlag.Name); mode {
+		case "legacy":
+			cfg.Istanbul.SigningMode = istanbul.LegacySigning
+		case "domain":
+			cfg.Istanbul.SigningMode = istanbul.DomainSigning
+		case "strict":
+			cfg.Istanbul.SigningMode = istanbul.StrictSigning
+		default:
+			Fatalf("Option %q: unknown signing mode %q", IstanbulSigningFlag.Name, mode)
+		}
+	}
+	if ctx.GlobalIsSet(IstanbulAuditFlag.Name) {
+		cfg.Istanbul.AuditLog = ctx.GlobalString(IstanbulAuditFlag.Name)
+	}
+	if ctx.GlobalIsSet(IstanbulAuditFormatFlag.Name) {
+		switch format := ctx.GlobalString(IstanbulAuditFormatFlag.Name); format {
+		case "json", "csv":
+			cfg.Istanbul.AuditFormat = format
+		default:
+			Fatalf("Option %q: unknown audit format %q", IstanbulAuditFormatFlag.Name, format)
+		}
+	}
 }
 
 // checkExclusive verifies that only a single isntance of the provided flags was
@@ -1053,6 +1573,8 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *eth.Config) {
 	setEtherbase(ctx, ks, cfg)
 	setGPO(ctx, &cfg.GPO)
 	setTxPool(ctx, &cfg.TxPool)
+	setTxBroadcast(ctx, &cfg.TxBroadcast)
+	setTxApproval(ctx, &cfg.TxApproval)
 	setEthash(ctx, cfg)
 	setIstanbul(ctx, cfg)
 
@@ -1084,9 +1606,28 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *eth.Config) {
 	}
 	cfg.NoPruning = ctx.GlobalString(GCModeFlag.Name) == "archive"
 
+	if ctx.GlobalIsSet(ReceiptsRetentionFlag.Name) {
+		cfg.ReceiptsRetention = ctx.GlobalUint64(ReceiptsRetentionFlag.Name)
+	}
+	if ctx.GlobalIsSet(IndexesFlag.Name) {
+		cfg.Indexes = strings.Split(ctx.GlobalString(IndexesFlag.Name), ",")
+	}
+
 	if ctx.GlobalIsSet(CacheFlag.Name) || ctx.GlobalIsSet(CacheGCFlag.Name) {
 		cfg.TrieCache = ctx.GlobalInt(CacheFlag.Name) * ctx.GlobalInt(CacheGCFlag.Name) / 100
 	}
+	if ctx.GlobalIsSet(CacheFlag.Name) || ctx.GlobalIsSet(CacheTrieFlag.Name) {
+		cfg.TrieCleanCache = ctx.GlobalInt(CacheFlag.Name) * ctx.GlobalInt(CacheTrieFlag.Name) / 100
+	}
+	if ctx.GlobalIsSet(CacheAsyncIndexFlag.Name) {
+		cfg.AsyncIndexing = ctx.GlobalBool(CacheAsyncIndexFlag.Name)
+	}
+	if ctx.GlobalIsSet(DBCompressionFlag.Name) {
+		cfg.BlockCompression = core.Compression(ctx.GlobalString(DBCompressionFlag.Name))
+	}
+	if ctx.GlobalIsSet(ParallelTxsFlag.Name) {
+		cfg.ParallelTxs = ctx.GlobalInt(ParallelTxsFlag.Name)
+	}
 	if ctx.GlobalIsSet(MinerThreadsFlag.Name) {
 		cfg.MinerThreads = ctx.GlobalInt(MinerThreadsFlag.Name)
 	}
@@ -1099,10 +1640,28 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *eth.Config) {
 	if ctx.GlobalIsSet(GasPriceFlag.Name) {
 		cfg.GasPrice = GlobalBig(ctx, GasPriceFlag.Name)
 	}
+	if ctx.GlobalIsSet(TxOrderingFlag.Name) {
+		cfg.TxOrdering = miner.TxOrdering(ctx.GlobalString(TxOrderingFlag.Name))
+	}
 	if ctx.GlobalIsSet(VMEnableDebugFlag.Name) {
 		// TODO(fjl): force-enable this in --dev mode
 		cfg.EnablePreimageRecording = ctx.GlobalBool(VMEnableDebugFlag.Name)
 	}
+	if ctx.GlobalIsSet(RPCGasCapFlag.Name) {
+		cfg.RPCGasCap = ctx.GlobalUint64(RPCGasCapFlag.Name)
+	}
+	if ctx.GlobalIsSet(RPCEVMTimeoutFlag.Name) {
+		cfg.RPCEVMTimeout = ctx.GlobalDuration(RPCEVMTimeoutFlag.Name)
+	}
+	if ctx.GlobalIsSet(RPCLogsBlockRangeFlag.Name) {
+		cfg.RPCLogsBlockRange = ctx.GlobalUint64(RPCLogsBlockRangeFlag.Name)
+	}
+	if ctx.GlobalIsSet(RPCLogsMaxResultsFlag.Name) {
+		cfg.RPCLogsMaxResults = ctx.GlobalInt(RPCLogsMaxResultsFlag.Name)
+	}
+	if ctx.GlobalIsSet(RPCUnlockTimeoutFlag.Name) {
+		cfg.RPCUnlockTimeout = ctx.GlobalDuration(RPCUnlockTimeoutFlag.Name)
+	}
 
 	// Override any default configs for hard coded networks.
 	switch {
@@ -1180,6 +1739,20 @@ func RegisterEthService(stack *node.Node, cfg *eth.Config) {
 	}
 }
 
+// RegisterTenantEthService adds an independent Ethereum full node for the given
+// tenant chain to the stack.
+func RegisterTenantEthService(stack *node.Node, tenant string, cfg *eth.Config) {
+	if cfg.SyncMode == downloader.LightSync {
+		Fatalf("Light client unsupported for tenant %s", tenant)
+	}
+	err := stack.RegisterTenant(tenant, func(ctx *node.ServiceContext) (node.Service, error) {
+		return eth.New(ctx, cfg)
+	})
+	if err != nil {
+		Fatalf("Failed to register the Ethereum service of tenant %s: %v", tenant, err)
+	}
+}
+
 // RegisterDashboardService adds a dashboard to the stack.
 func RegisterDashboardService(stack *node.Node, cfg *dashboard.Config, commit string) {
 	stack.Register(func(ctx *node.ServiceContext) (node.Service, error) {
@@ -1213,6 +1786,210 @@ func RegisterEthStatsService(stack *node.Node, url string) {
 	}
 }
 
+// RegisterTelemetryService configures the validator telemetry client from the
+// command line flags and adds it to the given node.
+func RegisterTelemetryService(stack *node.Node, ctx *cli.Context) {
+	cfg := telemetry.DefaultConfig
+	cfg.URL = ctx.GlobalString(TelemetryURLFlag.Name)
+	if ctx.GlobalIsSet(TelemetryIntervalFlag.Name) {
+		cfg.Interval = ctx.GlobalDuration(TelemetryIntervalFlag.Name)
+	}
+	if err := stack.Register(func(ctx *node.ServiceContext) (node.Service, error) {
+		var ethServ *eth.Ethereum
+		if err := ctx.Service(&ethServ); err != nil {
+			return nil, err
+		}
+		return telemetry.New(&cfg, ethServ.BlockChain(), ethServ.TxPool(), ethServ.Downloader().Synchronising)
+	}); err != nil {
+		Fatalf("Failed to register the telemetry service: %v", err)
+	}
+}
+
+// RegisterSnapshotService configures the state snapshot generator and adds it to
+// the given node.
+func RegisterSnapshotService(stack *node.Node, gateway string, interval uint64) {
+	if err := stack.Register(func(ctx *node.ServiceContext) (node.Service, error) {
+		var ethServ *eth.Ethereum
+		if err := ctx.Service(&ethServ); err != nil {
+			return nil, err
+		}
+		return snapshot.New(ethServ.BlockChain(), snapshot.NewBzzStore(gateway), interval), nil
+	}); err != nil {
+		Fatalf("Failed to register the state snapshot service: %v", err)
+	}
+}
+
+// RegisterBackupService configures the swarm chain publisher and adds it to the
+// given node. If a name is configured, the root manifest is registered in ENS
+// by the etherbase account through the node's own RPC endpoint.
+func RegisterBackupService(stack *node.Node, cfg *backup.Config, ensAddr common.Address) {
+	if err := stack.Register(func(ctx *node.ServiceContext) (node.Service, error) {
+		var ethServ *eth.Ethereum
+		if err := ctx.Service(&ethServ); err != nil {
+			return nil, err
+		}
+		registrar := func() (backup.Registrar, error) {
+			etherbase, err := ethServ.Etherbase()
+			if err != nil {
+				return nil, err
+			}
+			account := accounts.Account{Address: etherbase}
+			wallet, err := ethServ.AccountManager().Find(account)
+			if err != nil {
+				return nil, err
+			}
+			rpcClient, err := stack.Attach()
+			if err != nil {
+				return nil, err
+			}
+			chainID := ethServ.BlockChain().Config().ChainId
+			opts := &bind.TransactOpts{
+				From: etherbase,
+				Signer: func(signer types.Signer, address common.Address, tx *types.Transaction) (*types.Transaction, error) {
+					return wallet.SignTx(account, tx, chainID)
+				},
+			}
+			return ens.NewENS(opts, ensAddr, ethclient.NewClient(rpcClient))
+		}
+		return backup.New(cfg, ethServ.BlockChain(), ethServ.ChainDb(), registrar)
+	}); err != nil {
+		Fatalf("Failed to register the swarm chain backup service: %v", err)
+	}
+}
+
+// RegisterExporterService configures the finalized log exporter from the command
+// line flags and adds it to the given node.
+func RegisterExporterService(stack *node.Node, ctx *cli.Context) {
+	cfg := &exporter.Config{
+		Webhook:    ctx.GlobalString(ExporterWebhookFlag.Name),
+		Kafka:      ctx.GlobalString(ExporterKafkaFlag.Name),
+		KafkaTopic: ctx.GlobalString(ExporterKafkaTopicFlag.Name),
+		From:       ctx.GlobalUint64(ExporterFromFlag.Name),
+	}
+	if addrs := ctx.GlobalString(ExporterAddressesFlag.Name); addrs != "" {
+		cfg.Addresses = parseAddresses(ExporterAddressesFlag.Name, addrs)
+	}
+	if topics := ctx.GlobalString(ExporterTopicsFlag.Name); topics != "" {
+		var events []common.Hash
+		for _, topic := range strings.Split(topics, ",") {
+			blob, err := hexutil.Decode(strings.TrimSpace(topic))
+			if err != nil || len(blob) != common.HashLength {
+				Fatalf("Invalid exporter topic: %q", topic)
+			}
+			events = append(events, common.BytesToHash(blob))
+		}
+		cfg.Topics = [][]common.Hash{events}
+	}
+	if err := stack.Register(func(ctx *node.ServiceContext) (node.Service, error) {
+		var ethServ *eth.Ethereum
+		if err := ctx.Service(&ethServ); err != nil {
+			return nil, err
+		}
+		return exporter.New(cfg, ethServ.BlockChain(), ethServ.ChainDb())
+	}); err != nil {
+		Fatalf("Failed to register the log exporter service: %v", err)
+	}
+}
+
+// RegisterRelayerService configures the meta-transaction relayer from the command
+// line flags and adds it to the given node. The relayer account must be unlocked
+// for the relayer to sign its transactions.
+func RegisterRelayerService(stack *node.Node, ctx *cli.Context) {
+	account, err := urlscheme.ParseAddress(ctx.GlobalString(RelayerAccountFlag.Name))
+	if err != nil {
+		Fatalf("Invalid relayer account: %v", err)
+	}
+	cfg := &relayer.Config{
+		Account:     account,
+		MaxGas:      ctx.GlobalUint64(RelayerMaxGasFlag.Name),
+		Quota:       ctx.GlobalUint64(RelayerQuotaFlag.Name),
+		QuotaPeriod: ctx.GlobalDuration(RelayerQuotaPeriodFlag.Name),
+	}
+	if ctx.GlobalIsSet(RelayerGasPriceFlag.Name) {
+		cfg.GasPrice = GlobalBig(ctx, RelayerGasPriceFlag.Name)
+	}
+	if addrs := ctx.GlobalString(RelayerTargetsFlag.Name); addrs != "" {
+		cfg.Targets = parseAddresses(RelayerTargetsFlag.Name, addrs)
+	}
+	if err := stack.Register(func(ctx *node.ServiceContext) (node.Service, error) {
+		var ethServ *eth.Ethereum
+		if err := ctx.Service(&ethServ); err != nil {
+			return nil, err
+		}
+		signer := accounts.Account{Address: cfg.Account}
+		sign := func(tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+			wallet, err := ethServ.AccountManager().Find(signer)
+			if err != nil {
+				return nil, err
+			}
+			return wallet.SignTx(signer, tx, chainID)
+		}
+		return relayer.New(cfg, ethServ.BlockChain(), ethServ.TxPool(), ethServ.ChainDb(), sign)
+	}); err != nil {
+		Fatalf("Failed to register the relayer service: %v", err)
+	}
+}
+
+// RegisterBridgeService configures the bridge relay from the command line flags
+// and adds it to the given node. The bridge account must be unlocked for the
+// relay to sign its submissions.
+func RegisterBridgeService(stack *node.Node, ctx *cli.Context) {
+	cfg := bridge.DefaultConfig
+	cfg.Endpoint = ctx.GlobalString(BridgeEndpointFlag.Name)
+	cfg.Format = ctx.GlobalString(BridgeFormatFlag.Name)
+	cfg.GasLimit = ctx.GlobalUint64(BridgeGasFlag.Name)
+	cfg.From = ctx.GlobalUint64(BridgeFromFlag.Name)
+
+	for _, field := range []struct {
+		flag string
+		addr *common.Address
+	}{
+		{BridgeContractFlag.Name, &cfg.Contract},
+		{BridgeDestinationFlag.Name, &cfg.Destination},
+		{BridgeAccountFlag.Name, &cfg.Account},
+	} {
+		addr, err := urlscheme.ParseAddress(ctx.GlobalString(field.flag))
+		if err != nil {
+			Fatalf("Invalid or missing --%s: %v", field.flag, err)
+		}
+		*field.addr = addr
+	}
+
+	if !ctx.GlobalIsSet(BridgeChainIDFlag.Name) {
+		Fatalf("Relaying bridge events requires the --%s of the destination", BridgeChainIDFlag.Name)
+	}
+	cfg.ChainID = new(big.Int).SetUint64(ctx.GlobalUint64(BridgeChainIDFlag.Name))
+	if ctx.GlobalIsSet(BridgeGasPriceFlag.Name) {
+		cfg.GasPrice = GlobalBig(ctx, BridgeGasPriceFlag.Name)
+	}
+	if events := ctx.GlobalString(BridgeEventsFlag.Name); events != "" {
+		for _, event := range strings.Split(events, ",") {
+			blob, err := hexutil.Decode(strings.TrimSpace(event))
+			if err != nil || len(blob) != common.HashLength {
+				Fatalf("Invalid bridge event: %q", event)
+			}
+			cfg.Events = append(cfg.Events, common.BytesToHash(blob))
+		}
+	}
+	if err := stack.Register(func(ctx *node.ServiceContext) (node.Service, error) {
+		var ethServ *eth.Ethereum
+		if err := ctx.Service(&ethServ); err != nil {
+			return nil, err
+		}
+		signer := accounts.Account{Address: cfg.Account}
+		sign := func(tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+			wallet, err := ethServ.AccountManager().Find(signer)
+			if err != nil {
+				return nil, err
+			}
+			return wallet.SignTx(signer, tx, chainID)
+		}
+		return bridge.New(&cfg, ethServ.BlockChain(), ethServ.ChainDb(), sign)
+	}); err != nil {
+		Fatalf("Failed to register the bridge service: %v", err)
+	}
+}
+
 // SetupNetwork configures the system for either the main net or some test network.
 func SetupNetwork(ctx *cli.Context) {
 	// TODO(fjl): move target gas limit into config
@@ -1256,6 +2033,9 @@ func MakeChain(ctx *cli.Context, stack *node.Node) (chain *core.BlockChain, chai
 	var err error
 	chainDb = MakeChainDatabase(ctx, stack)
 
+	if err := core.SetBlockCompression(core.Compression(ctx.GlobalString(DBCompressionFlag.Name))); err != nil {
+		Fatalf("%v", err)
+	}
 	config, _, err := core.SetupGenesisBlock(chainDb, MakeGenesis(ctx))
 	if err != nil {
 		Fatalf("%v", err)
@@ -1280,9 +2060,14 @@ func MakeChain(ctx *cli.Context, stack *node.Node) (chain *core.BlockChain, chai
 		Fatalf("--%s must be either 'full' or 'archive'", GCModeFlag.Name)
 	}
 	cache := &core.CacheConfig{
-		Disabled:      ctx.GlobalString(GCModeFlag.Name) == "archive",
-		TrieNodeLimit: eth.DefaultConfig.TrieCache,
-		TrieTimeLimit: eth.DefaultConfig.TrieTimeout,
+		Disabled:       ctx.GlobalString(GCModeFlag.Name) == "archive",
+		TrieNodeLimit:  eth.DefaultConfig.TrieCache,
+		TrieTimeLimit:  eth.DefaultConfig.TrieTimeout,
+		AsyncIndexing:  ctx.GlobalBool(CacheAsyncIndexFlag.Name),
+		TrieCleanLimit: eth.DefaultConfig.TrieCleanCache,
+	}
+	if ctx.GlobalIsSet(CacheFlag.Name) || ctx.GlobalIsSet(CacheTrieFlag.Name) {
+		cache.TrieCleanLimit = ctx.GlobalInt(CacheFlag.Name) * ctx.GlobalInt(CacheTrieFlag.Name) / 100
 	}
 	if ctx.GlobalIsSet(CacheFlag.Name) || ctx.GlobalIsSet(CacheGCFlag.Name) {
 		cache.TrieNodeLimit = ctx.GlobalInt(CacheFlag.Name) * ctx.GlobalInt(CacheGCFlag.Name) / 100
@@ -0,0 +1,127 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package urlscheme
+
+import (
+	"fmt"
+	"math/big"
+	"net/url"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// AddressURI is a parsed reference to an account, optionally requesting a call
+// of one of its functions (EIP-681).
+type AddressURI struct {
+	Address  common.Address // Account referenced
+	ChainID  *big.Int       // Chain the account lives on, nil if not given
+	Function string         // Function to call, empty for plain transfers
+	Params   url.Values     // Parameters of the call, keyed by name or type
+}
+
+// ParseAddressURI parses an account URI of the form
+//
+//	<scheme>:[//][pay-]<address>[@<chain id>][/<function>][?<parameters>]
+//
+// with scheme either eth or ethereum. The address must be hexadecimal, and if
+// given in mixed case, must carry a valid EIP-55 checksum.
+func ParseAddressURI(rawuri string) (*AddressURI, error) {
+	i := strings.Index(rawuri, ":")
+	if i < 0 {
+		return nil, fmt.Errorf("missing scheme in %q", rawuri)
+	}
+	if scheme := rawuri[:i]; scheme != "eth" && scheme != "ethereum" {
+		return nil, fmt.Errorf("unknown scheme %q", scheme)
+	}
+	rest := strings.TrimPrefix(rawuri[i+1:], "//")
+
+	uri := new(AddressURI)
+	if i := strings.Index(rest, "?"); i >= 0 {
+		params, err := url.ParseQuery(rest[i+1:])
+		if err != nil {
+			return nil, fmt.Errorf("invalid parameters: %v", err)
+		}
+		uri.Params, rest = params, rest[:i]
+	}
+	if i := strings.Index(rest, "/"); i >= 0 {
+		uri.Function, rest = rest[i+1:], rest[:i]
+	}
+	if i := strings.Index(rest, "@"); i >= 0 {
+		chainID, ok := new(big.Int).SetString(rest[i+1:], 10)
+		if !ok || chainID.Sign() <= 0 {
+			return nil, fmt.Errorf("invalid chain id %q", rest[i+1:])
+		}
+		uri.ChainID, rest = chainID, rest[:i]
+	}
+	addr := strings.TrimPrefix(rest, "pay-")
+	if err := checkAddress(addr); err != nil {
+		return nil, err
+	}
+	uri.Address = common.HexToAddress(addr)
+	return uri, nil
+}
+
+// ParseAddress parses an account given either as a plain hexadecimal address or
+// as an address URI, the forms accepted by the command line and RPC interfaces.
+func ParseAddress(s string) (common.Address, error) {
+	if strings.Contains(s, ":") {
+		uri, err := ParseAddressURI(s)
+		if err != nil {
+			return common.Address{}, err
+		}
+		return uri.Address, nil
+	}
+	if !common.IsHexAddress(s) {
+		return common.Address{}, fmt.Errorf("invalid address %q", s)
+	}
+	return common.HexToAddress(s), nil
+}
+
+// IsAddressURI reports whether s carries the scheme of an address URI.
+func IsAddressURI(s string) bool {
+	return strings.HasPrefix(s, "eth:") || strings.HasPrefix(s, "ethereum:")
+}
+
+// checkAddress checks that s is a 0x prefixed hexadecimal address, with a valid
+// checksum if given in mixed case.
+func checkAddress(s string) error {
+	if !strings.HasPrefix(s, "0x") || len(s) != 2+2*common.AddressLength || !isHex(s[2:]) {
+		return fmt.Errorf("invalid address %q", s)
+	}
+	if hex := s[2:]; hex != strings.ToLower(hex) && hex != strings.ToUpper(hex) {
+		if want := common.HexToAddress(s).Hex(); s != want {
+			return fmt.Errorf("invalid address checksum %q, want %q", s, want)
+		}
+	}
+	return nil
+}
+
+// String returns the URI in canonical form.
+func (u *AddressURI) String() string {
+	s := "ethereum:" + u.Address.Hex()
+	if u.ChainID != nil {
+		s += "@" + u.ChainID.String()
+	}
+	if u.Function != "" {
+		s += "/" + u.Function
+	}
+	if len(u.Params) > 0 {
+		s += "?" + u.Params.Encode()
+	}
+	return s
+}
@@ -0,0 +1,143 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package urlscheme parses and validates the URLs used across the node: swarm
+// content URLs (bzz://), node URLs (enode:// and enr:) and account URIs (eth:).
+package urlscheme
+
+import (
+	"fmt"
+	"math/big"
+	"net/url"
+	"strings"
+)
+
+// contentSchemes are the schemes of swarm content URLs, including deprecated ones.
+var contentSchemes = map[string]bool{
+	"bzz":           true, // an entry in a swarm manifest
+	"bzz-raw":       true, // raw swarm content
+	"bzz-immutable": true, // immutable entry in a swarm manifest (address is not resolved)
+	"bzz-list":      true, // list of all files contained in a swarm manifest
+	"bzz-info":      true, // metadata of an entry in a swarm manifest
+	"bzz-hash":      true, // hash of swarm content (deprecated)
+	"bzzr":          true, // raw swarm content (deprecated)
+	"bzzi":          true, // immutable entry in a swarm manifest (deprecated)
+}
+
+// ContentURL is a parsed reference to content stored in swarm.
+type ContentURL struct {
+	Scheme  string   // One of the swarm content schemes
+	Host    string   // Hexadecimal content hash, CID or name resolving to a content hash
+	Version *big.Int // Block the name is resolved at, nil for the latest state
+	Path    string   // Path to the content within a manifest, without leading slash
+
+	sep string // Separator of the version given, if any
+}
+
+// ParseContentURL parses a swarm content URL of one of the forms
+//
+//	<scheme>:/<addr>/<path>
+//	<scheme>://<addr>/<path>
+//
+// where both addr and path are optional. The address may be pinned to the state
+// of the name registry at a block by a port or suffix (e.g. swarm.eth:4200000 or
+// swarm.eth@4200000).
+func ParseContentURL(rawurl string) (*ContentURL, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, err
+	}
+	if !contentSchemes[u.Scheme] {
+		return nil, fmt.Errorf("unknown scheme %q", u.Scheme)
+	}
+	var addr, path string
+	if u.Host != "" {
+		// URL like bzz://<addr>/<path>, already split by url.Parse, except
+		// for a version suffix taken for user info
+		addr, path = u.Host, strings.TrimLeft(u.Path, "/")
+		if u.User != nil {
+			addr = u.User.String() + "@" + addr
+		}
+	} else {
+		// URL like bzz:/<addr>/<path>, split the raw path /<addr>/<path>
+		parts := strings.SplitN(strings.TrimLeft(u.Path, "/"), "/", 2)
+		addr = parts[0]
+		if len(parts) == 2 {
+			path = parts[1]
+		}
+	}
+	host, version := SplitVersion(addr)
+	content := &ContentURL{Scheme: u.Scheme, Host: host, Version: version, Path: path}
+	if version != nil {
+		content.sep = addr[len(host) : len(host)+1]
+	}
+	return content, nil
+}
+
+// Addr returns the address of the content, including the version if pinned,
+// with the separator it was parsed with.
+func (u *ContentURL) Addr() string {
+	if u.Version == nil {
+		return u.Host
+	}
+	sep := u.sep
+	if sep == "" {
+		sep = ":"
+	}
+	return u.Host + sep + u.Version.String()
+}
+
+// String returns the URL in canonical form.
+func (u *ContentURL) String() string {
+	return u.Scheme + ":/" + u.Addr() + "/" + u.Path
+}
+
+// SplitVersion splits the block a name is pinned at by a port or suffix (e.g.
+// swarm.eth:4200000 or swarm.eth@4200000) from the name. The version is nil if
+// the name isn't pinned.
+func SplitVersion(addr string) (string, *big.Int) {
+	i := strings.LastIndexAny(addr, ":@")
+	if i <= 0 || i == len(addr)-1 || !isDigits(addr[i+1:]) {
+		return addr, nil
+	}
+	version, _ := new(big.Int).SetString(addr[i+1:], 10)
+	return addr[:i], version
+}
+
+// IsContentHash checks whether s is a hexadecimal swarm content hash.
+func IsContentHash(s string) bool {
+	return len(s) == 64 && isHex(s)
+}
+
+// isHex checks whether s consists of hexadecimal characters only.
+func isHex(s string) bool {
+	for _, c := range s {
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F') {
+			return false
+		}
+	}
+	return true
+}
+
+// isDigits checks whether s consists of decimal digits only.
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,170 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package urlscheme
+
+import (
+	"crypto/elliptic"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/p2p/enr"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// NodeIDLength is the length of a node ID, the uncompressed public key of the
+// node without its format prefix byte.
+const NodeIDLength = 64
+
+// NodeURL is a parsed node designator.
+type NodeURL struct {
+	ID     [NodeIDLength]byte // Public key of the node
+	IP     net.IP             // IP address of the node, nil for incomplete designators
+	TCP    uint16             // TCP listening port
+	UDP    uint16             // UDP discovery port
+	Record *enr.Record        // Node record the designator was parsed from, nil for enode URLs
+}
+
+// Incomplete returns whether the designator only contains the node ID.
+func (n *NodeURL) Incomplete() bool {
+	return n.IP == nil
+}
+
+// ParseNodeURL parses a node designator, which is either an enode URL or the
+// text form of a node record (enr:<base64 of the RLP record>).
+//
+// Enode URLs come in two forms: incomplete ones only contain the node ID,
+//
+//	enode://<hex node id>
+//	<hex node id>
+//
+// while complete ones encode the node ID in the username portion of the URL,
+// separated from the host by an @ sign. The host can only be given as an IP
+// address, DNS domain names are not allowed. The port in the host section is
+// the TCP listening port. If the TCP and UDP (discovery) ports differ, the UDP
+// port is specified as query parameter "discport":
+//
+//	enode://<hex node id>@10.3.58.6:30303?discport=30301
+func ParseNodeURL(rawurl string) (*NodeURL, error) {
+	if strings.HasPrefix(rawurl, "enr:") {
+		return parseRecord(rawurl)
+	}
+	if id := strings.TrimPrefix(strings.ToLower(rawurl), "enode://"); id != "" && isHex(id) {
+		n := new(NodeURL)
+		if err := parseNodeID(id, &n.ID); err != nil {
+			return nil, err
+		}
+		return n, nil
+	}
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "enode" {
+		return nil, errors.New("invalid URL scheme, want \"enode\"")
+	}
+	// Parse the node ID from the user portion
+	if u.User == nil {
+		return nil, errors.New("does not contain node ID")
+	}
+	n := new(NodeURL)
+	if err := parseNodeID(u.User.String(), &n.ID); err != nil {
+		return nil, err
+	}
+	// Parse the IP address
+	host, port, err := net.SplitHostPort(u.Host)
+	if err != nil {
+		return nil, fmt.Errorf("invalid host: %v", err)
+	}
+	if n.IP = net.ParseIP(host); n.IP == nil {
+		return nil, errors.New("invalid IP address")
+	}
+	if ipv4 := n.IP.To4(); ipv4 != nil {
+		n.IP = ipv4
+	}
+	// Parse the port numbers
+	tcp, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return nil, errors.New("invalid port")
+	}
+	n.TCP, n.UDP = uint16(tcp), uint16(tcp)
+	if discport := u.Query().Get("discport"); discport != "" {
+		udp, err := strconv.ParseUint(discport, 10, 16)
+		if err != nil {
+			return nil, errors.New("invalid discport in query")
+		}
+		n.UDP = uint16(udp)
+	}
+	return n, nil
+}
+
+// parseNodeID decodes a hexadecimal node ID, optionally prefixed with 0x.
+func parseNodeID(in string, id *[NodeIDLength]byte) error {
+	b, err := hex.DecodeString(strings.TrimPrefix(in, "0x"))
+	if err != nil {
+		return fmt.Errorf("invalid node ID (%v)", err)
+	}
+	if len(b) != NodeIDLength {
+		return fmt.Errorf("invalid node ID (wrong length, want %d hex chars)", NodeIDLength*2)
+	}
+	copy(id[:], b)
+	return nil
+}
+
+// parseRecord decodes the text form of a node record, verifying its signature.
+// Records without an IP address yield incomplete designators.
+func parseRecord(rawurl string) (*NodeURL, error) {
+	blob, err := base64.RawURLEncoding.DecodeString(strings.TrimPrefix(rawurl, "enr:"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid node record encoding (%v)", err)
+	}
+	n := &NodeURL{Record: new(enr.Record)}
+	if err := rlp.DecodeBytes(blob, n.Record); err != nil {
+		return nil, fmt.Errorf("invalid node record (%v)", err)
+	}
+	var pubkey enr.Secp256k1
+	if err := n.Record.Load(&pubkey); err != nil {
+		return nil, fmt.Errorf("invalid node record (%v)", err)
+	}
+	copy(n.ID[:], elliptic.Marshal(pubkey.Curve, pubkey.X, pubkey.Y)[1:])
+
+	var ip4 enr.IP4
+	if err := n.Record.Load(&ip4); err == nil {
+		n.IP = net.IP(ip4)
+	} else {
+		var ip6 enr.IP6
+		if err := n.Record.Load(&ip6); err == nil {
+			n.IP = net.IP(ip6)
+		}
+	}
+	if n.IP == nil {
+		return n, nil
+	}
+	if err := n.Record.Load(enr.WithEntry("tcp", &n.TCP)); err != nil {
+		return nil, fmt.Errorf("invalid node record (%v)", err)
+	}
+	n.UDP = n.TCP
+	if err := n.Record.Load(enr.WithEntry("udp", &n.UDP)); err != nil && !enr.IsNotFound(err) {
+		return nil, fmt.Errorf("invalid node record (%v)", err)
+	}
+	return n, nil
+}
@@ -0,0 +1,246 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package urlscheme
+
+import (
+	"encoding/base64"
+	"math/big"
+	"net"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/p2p/enr"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+func TestParseContentURL(t *testing.T) {
+	tests := []struct {
+		url    string
+		want   *ContentURL
+		addr   string
+		errstr string
+	}{
+		{url: "http://swarm.eth/", errstr: `unknown scheme "http"`},
+		{url: "bzz:/", want: &ContentURL{Scheme: "bzz"}},
+		{url: "bzz-raw://", want: &ContentURL{Scheme: "bzz-raw"}},
+		{
+			url:  "bzz:/swarm.eth/path/to/entry",
+			want: &ContentURL{Scheme: "bzz", Host: "swarm.eth", Path: "path/to/entry"},
+			addr: "swarm.eth",
+		},
+		{
+			url:  "bzz-immutable://abc123/path",
+			want: &ContentURL{Scheme: "bzz-immutable", Host: "abc123", Path: "path"},
+			addr: "abc123",
+		},
+		{
+			url:  "bzz://swarm.eth:4200000/path",
+			want: &ContentURL{Scheme: "bzz", Host: "swarm.eth", Version: big.NewInt(4200000), Path: "path", sep: ":"},
+			addr: "swarm.eth:4200000",
+		},
+		{
+			url:  "bzz://swarm.eth@4200000/path",
+			want: &ContentURL{Scheme: "bzz", Host: "swarm.eth", Version: big.NewInt(4200000), Path: "path", sep: "@"},
+			addr: "swarm.eth@4200000",
+		},
+		{
+			url:  "bzz:/swarm.eth@latest",
+			want: &ContentURL{Scheme: "bzz", Host: "swarm.eth@latest"},
+			addr: "swarm.eth@latest",
+		},
+	}
+	for _, test := range tests {
+		u, err := ParseContentURL(test.url)
+		if test.errstr != "" {
+			if err == nil || err.Error() != test.errstr {
+				t.Errorf("%q: error mismatch: have %v, want %q", test.url, err, test.errstr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", test.url, err)
+			continue
+		}
+		if !reflect.DeepEqual(u, test.want) {
+			t.Errorf("%q: result mismatch: have %+v, want %+v", test.url, u, test.want)
+		}
+		if addr := u.Addr(); addr != test.addr {
+			t.Errorf("%q: address mismatch: have %q, want %q", test.url, addr, test.addr)
+		}
+	}
+}
+
+func TestIsContentHash(t *testing.T) {
+	hash := strings.Repeat("aB3", 21) + "f"
+	if !IsContentHash(hash) {
+		t.Errorf("%q not accepted", hash)
+	}
+	for _, s := range []string{"", hash[1:], hash + "0", hash[1:] + "g"} {
+		if IsContentHash(s) {
+			t.Errorf("%q accepted", s)
+		}
+	}
+}
+
+func TestParseNodeURL(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	var id [NodeIDLength]byte
+	copy(id[:], crypto.FromECDSAPub(&key.PublicKey)[1:])
+	hexID := common.Bytes2Hex(id[:])
+
+	tests := []struct {
+		url    string
+		want   *NodeURL
+		errstr string
+	}{
+		{url: "http://foobar", errstr: `invalid URL scheme, want "enode"`},
+		{url: "enode://01010101@123.124.125.126:3", errstr: "invalid node ID (wrong length, want 128 hex chars)"},
+		{url: "enode://" + hexID + "@hostname:3", errstr: "invalid IP address"},
+		{url: "enode://" + hexID + "@127.0.0.1:99999", errstr: "invalid port"},
+		{url: "enode://" + hexID + "@127.0.0.1:3?discport=foo", errstr: "invalid discport in query"},
+		{url: "enode://" + hexID, want: &NodeURL{ID: id}},
+		{url: strings.ToUpper(hexID), want: &NodeURL{ID: id}},
+		{
+			url:  "enode://" + hexID + "@127.0.0.1:52150",
+			want: &NodeURL{ID: id, IP: net.IP{127, 0, 0, 1}, TCP: 52150, UDP: 52150},
+		},
+		{
+			url:  "enode://" + hexID + "@[::1]:52150?discport=22334",
+			want: &NodeURL{ID: id, IP: net.ParseIP("::1"), TCP: 52150, UDP: 22334},
+		},
+	}
+	for _, test := range tests {
+		n, err := ParseNodeURL(test.url)
+		if test.errstr != "" {
+			if err == nil || err.Error() != test.errstr {
+				t.Errorf("%q: error mismatch: have %v, want %q", test.url, err, test.errstr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", test.url, err)
+			continue
+		}
+		if !reflect.DeepEqual(n, test.want) {
+			t.Errorf("%q: result mismatch: have %+v, want %+v", test.url, n, test.want)
+		}
+	}
+}
+
+func TestParseNodeURLRecord(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	var id [NodeIDLength]byte
+	copy(id[:], crypto.FromECDSAPub(&key.PublicKey)[1:])
+
+	var r enr.Record
+	r.Set(enr.IP4{10, 3, 58, 6})
+	r.Set(enr.WithEntry("tcp", uint16(30303)))
+	r.Set(enr.WithEntry("udp", uint16(30301)))
+	if err := r.Sign(key); err != nil {
+		t.Fatalf("failed to sign record: %v", err)
+	}
+	blob, _ := rlp.EncodeToBytes(&r)
+	text := "enr:" + base64.RawURLEncoding.EncodeToString(blob)
+
+	n, err := ParseNodeURL(text)
+	if err != nil {
+		t.Fatalf("failed to parse record: %v", err)
+	}
+	if n.ID != id || !n.IP.Equal(net.IP{10, 3, 58, 6}) || n.TCP != 30303 || n.UDP != 30301 || n.Record == nil {
+		t.Errorf("record mismatch: %+v", n)
+	}
+	// Tampering with the record must invalidate the signature
+	blob[len(blob)-1]++
+	if _, err := ParseNodeURL("enr:" + base64.RawURLEncoding.EncodeToString(blob)); err == nil {
+		t.Errorf("tampered record accepted")
+	}
+	if _, err := ParseNodeURL("enr:!!"); err == nil {
+		t.Errorf("invalid encoding accepted")
+	}
+}
+
+func TestParseAddressURI(t *testing.T) {
+	addr := common.HexToAddress("0xfb6916095ca1df60bb79ce92ce3ea74c37c5d359")
+	checksummed := addr.Hex()
+	miscased := "0x" + strings.ToUpper(checksummed[2:3]) + strings.ToLower(checksummed[3:])
+	tests := []struct {
+		uri    string
+		want   *AddressURI
+		errstr string
+	}{
+		{uri: "bitcoin:" + checksummed, errstr: `unknown scheme "bitcoin"`},
+		{uri: checksummed, errstr: `missing scheme in "` + checksummed + `"`},
+		{uri: "ethereum:0x1234", errstr: `invalid address "0x1234"`},
+		{uri: "ethereum:" + miscased, errstr: `invalid address checksum "` + miscased + `", want "` + checksummed + `"`},
+		{uri: "ethereum:" + checksummed + "@0", errstr: `invalid chain id "0"`},
+		{uri: "ethereum:" + checksummed, want: &AddressURI{Address: addr}},
+		{uri: "eth://" + strings.ToLower(checksummed), want: &AddressURI{Address: addr}},
+		{uri: "ethereum:pay-" + checksummed + "@1", want: &AddressURI{Address: addr, ChainID: big.NewInt(1)}},
+		{
+			uri: "ethereum:" + checksummed + "@3/transfer?address=0x0100&uint256=1e18",
+			want: &AddressURI{
+				Address:  addr,
+				ChainID:  big.NewInt(3),
+				Function: "transfer",
+				Params:   url.Values{"address": {"0x0100"}, "uint256": {"1e18"}},
+			},
+		},
+	}
+	for _, test := range tests {
+		u, err := ParseAddressURI(test.uri)
+		if test.errstr != "" {
+			if err == nil || err.Error() != test.errstr {
+				t.Errorf("%q: error mismatch: have %v, want %q", test.uri, err, test.errstr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", test.uri, err)
+			continue
+		}
+		if !reflect.DeepEqual(u, test.want) {
+			t.Errorf("%q: result mismatch: have %+v, want %+v", test.uri, u, test.want)
+		}
+		// The canonical form must parse back to the same URI
+		if u2, err := ParseAddressURI(u.String()); err != nil || !reflect.DeepEqual(u, u2) {
+			t.Errorf("%q: canonical form %q mismatch: have %+v, err %v", test.uri, u.String(), u2, err)
+		}
+	}
+}
+
+func TestParseAddress(t *testing.T) {
+	addr := common.HexToAddress("0xfb6916095ca1df60bb79ce92ce3ea74c37c5d359")
+	for _, s := range []string{
+		addr.Hex(),
+		strings.ToLower(addr.Hex()[2:]),
+		"ethereum:" + addr.Hex() + "@1",
+		"eth:pay-" + addr.Hex(),
+	} {
+		if have, err := ParseAddress(s); err != nil || have != addr {
+			t.Errorf("%q: have %x, err %v, want %x", s, have, err, addr)
+		}
+	}
+	for _, s := range []string{"", "0x1234", "bitcoin:" + addr.Hex(), "ethereum:0x1234"} {
+		if _, err := ParseAddress(s); err == nil {
+			t.Errorf("%q: invalid address accepted", s)
+		}
+	}
+}
@@ -113,6 +113,16 @@ type Handler interface {
 	SetBroadcaster(Broadcaster)
 }
 
+// PeerHandler is a consensus handler that exchanges messages with peers as soon
+// as they connect, extending the protocol handshake.
+type PeerHandler interface {
+	// PeerConnected is called once the handshake with a peer completed.
+	PeerConnected(address common.Address, peer Peer, version uint) error
+
+	// PeerDisconnected is called when a peer disconnects.
+	PeerDisconnected(address common.Address)
+}
+
 // PoW is a consensus engine based on proof-of-work.
 type PoW interface {
 	Engine
@@ -121,6 +131,82 @@ type PoW interface {
 	Hashrate() float64
 }
 
+// ForensicReporter is a consensus engine able to dump its internal state related
+// to a block, to be bundled into the forensic report of a bad block.
+type ForensicReporter interface {
+	// ForensicDump returns a JSON encodable snapshot of the engine state relevant
+	// to the given header, or nil if none is available.
+	ForensicDump(header *types.Header) interface{}
+}
+
+// FinalityVerifier is a consensus engine whose blocks carry an explicit proof of
+// finality (e.g. BFT commit seals) that can be checked without executing them.
+type FinalityVerifier interface {
+	// VerifyFinality checks whether the header is provably final. All ancestors
+	// of the header must already be known to the chain.
+	VerifyFinality(chain ChainReader, header *types.Header) error
+}
+
+// Rewinder is a consensus engine persisting state derived from the chain, which
+// needs to be rolled back whenever the chain is rewound.
+type Rewinder interface {
+	// Rewind drops any state the engine derived from blocks above the new head.
+	Rewind(chain ChainReader, head *types.Header) error
+}
+
+// CommitInspector is a consensus engine able to tell who proposed a block and in
+// which consensus round it was committed.
+type CommitInspector interface {
+	// CommitInfo returns the proposer of the header and the round in which it
+	// was committed. All ancestors of the header must already be known to the
+	// chain.
+	CommitInfo(chain ChainReader, header *types.Header) (proposer common.Address, round uint64, err error)
+}
+
+// ViewReporter is a BFT consensus engine able to report the consensus instance
+// it is currently running, e.g. for monitoring its progress.
+type ViewReporter interface {
+	// CurrentView returns the sequence and round being agreed on along with the
+	// state of the local validator, or an error if consensus isn't running.
+	CurrentView() (*View, error)
+}
+
+// View is the consensus instance a BFT engine is running.
+type View struct {
+	Sequence uint64         `json:"sequence"`
+	Round    uint64         `json:"round"`
+	State    string         `json:"state"`
+	Proposer common.Address `json:"proposer"`
+}
+
+// EmptyBlockSuppressor is a consensus engine holding back blocks without any
+// transactions, relying on the miner to hand it new work once some arrive.
+type EmptyBlockSuppressor interface {
+	// SuppressEmptyBlocks returns whether sealing empty blocks is delayed.
+	SuppressEmptyBlocks() bool
+}
+
+// ReceiptFinalizer is a consensus engine emitting receipts of its own when
+// finalizing blocks (e.g. for system contract calls), which the block includes
+// after the receipts of its transactions.
+type ReceiptFinalizer interface {
+	// FinalizeWithReceipts is like Finalize, but also returns the receipts of the
+	// block, the ones emitted by the engine appended to the given ones.
+	FinalizeWithReceipts(chain ChainReader, header *types.Header, state *state.StateDB, txs []*types.Transaction,
+		uncles []*types.Header, receipts []*types.Receipt) (*types.Block, []*types.Receipt, error)
+}
+
+// Finalize finalizes a block with the given engine, returning the assembled block
+// and its receipts, including any emitted by the engine itself.
+func Finalize(engine Engine, chain ChainReader, header *types.Header, state *state.StateDB, txs []*types.Transaction,
+	uncles []*types.Header, receipts []*types.Receipt) (*types.Block, []*types.Receipt, error) {
+	if f, ok := engine.(ReceiptFinalizer); ok {
+		return f.FinalizeWithReceipts(chain, header, state, txs, uncles, receipts)
+	}
+	block, err := engine.Finalize(chain, header, state, txs, uncles, receipts)
+	return block, receipts, err
+}
+
 // Istanbul is a consensus engine to avoid byzantine failure
 type Istanbul interface {
 	Engine
@@ -130,4 +216,28 @@ type Istanbul interface {
 
 	// Stop stops the engine
 	Stop() error
+
+	// GetValidatorsAt retrieves the validators authorized after the given block
+	GetValidatorsAt(number uint64) ([]common.Address, error)
+
+	// SetProposalValidator sets the application level hook to validate proposals
+	// with before voting for them
+	SetProposalValidator(validator ProposalValidator)
+
+	// IsUpgradeActive returns whether the named protocol upgrade signaled for by
+	// the validators is active at the given block, gating the consensus changes
+	// it ships.
+	IsUpgradeActive(name string, number uint64) bool
+
+	// Authorize delegates the signing of consensus messages and seals to the given
+	// callback (e.g. an external signer holding the validator key) instead of the
+	// node key. The callback receives the hash to sign.
+	Authorize(signFn func(hash []byte) ([]byte, error))
+}
+
+// ProposalValidator is an application level hook validating block proposals of
+// BFT engines before the local validator votes for them, e.g. to enforce rules of
+// a permissioned chain. Returning an error vetoes the proposal.
+type ProposalValidator interface {
+	ValidateProposal(chain ChainReader, block *types.Block) error
 }
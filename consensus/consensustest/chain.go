@@ -0,0 +1,63 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package consensustest
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// FinalEngine is a consensus engine considering every block up to a limit final,
+// standing in for engines with explicit finality in the tests of services
+// acting on final blocks only.
+type FinalEngine struct {
+	consensus.Engine
+	Final uint64 // Number of the last final block
+}
+
+// VerifyFinality implements consensus.FinalityVerifier, accepting the blocks up to the
+// limit as final.
+func (e *FinalEngine) VerifyFinality(chain consensus.ChainReader, header *types.Header) error {
+	if header.Number.Uint64() > e.Final {
+		return errors.New("not final")
+	}
+	return nil
+}
+
+// NewChain creates a chain of the given number of blocks on top of a genesis
+// with the given allocation, the blocks being filled by gen.
+func NewChain(t *testing.T, engine consensus.Engine, alloc core.GenesisAlloc, blocks int, gen func(int, *core.BlockGen)) (*core.BlockChain, ethdb.Database) {
+	db, _ := ethdb.NewMemDatabase()
+	gspec := &core.Genesis{Config: params.TestChainConfig, Alloc: alloc}
+	genesis := gspec.MustCommit(db)
+
+	chain, _ := core.NewBlockChain(db, nil, params.TestChainConfig, engine, vm.Config{})
+	chain.SetProcessor(core.NewStateProcessor(params.TestChainConfig, chain, engine))
+
+	generated, _ := core.GenerateChain(params.TestChainConfig, genesis, engine, db, blocks, gen)
+	if _, err := chain.InsertChain(generated); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	return chain, db
+}
@@ -0,0 +1,258 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package consensustest implements a conformance test suite checking that a
+// consensus engine honours the contract of the consensus.Engine interface and
+// of the optional extensions it implements.
+//
+// The suite is meant to be run from the tests of the engine packages:
+//
+//	func TestConformance(t *testing.T) {
+//		consensustest.Run(t, func(t *testing.T) *consensustest.Harness {
+//			...
+//		})
+//	}
+package consensustest
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Harness is a consensus engine under test, together with a chain it is able to
+// seal blocks on top of.
+type Harness struct {
+	Engine consensus.Engine // Engine under test, authorized to seal on Chain
+	Chain  *core.BlockChain // Chain initialised with the engine's genesis block
+
+	// Propose schedules a vote of the local sealer on adding or removing the
+	// given address from the validator set. Optional, engines without votes
+	// skip the validator set transition checks.
+	Propose func(address common.Address, authorize bool)
+
+	// Validators returns the validator set authorized after the given block.
+	// Optional, engines without validators skip the related checks.
+	Validators func(number uint64) ([]common.Address, error)
+}
+
+// Run runs the whole conformance suite, creating a fresh harness for each check.
+func Run(t *testing.T, newHarness func(t *testing.T) *Harness) {
+	t.Run("HeaderVerification", func(t *testing.T) { CheckHeaderVerification(t, newHarness(t)) })
+	t.Run("SealVerification", func(t *testing.T) { CheckSealVerification(t, newHarness(t)) })
+	t.Run("Finality", func(t *testing.T) { CheckFinality(t, newHarness(t)) })
+	t.Run("ValidatorTransitions", func(t *testing.T) { CheckValidatorTransitions(t, newHarness(t)) })
+}
+
+// CheckHeaderVerification checks that sealed headers are accepted both one by
+// one and in batches, and that headers with unknown ancestors or timestamps in
+// the future are rejected with the errors the block importer relies on.
+func CheckHeaderVerification(t *testing.T, h *Harness) {
+	first := h.seal(t, h.Chain.Genesis())
+	if err := h.Engine.VerifyHeader(h.Chain, first.Header(), true); err != nil {
+		t.Fatalf("sealed header rejected: %v", err)
+	}
+	// Headers extending unknown blocks must be reported as such
+	header := first.Header()
+	header.ParentHash = common.Hash{0x01}
+	if err := h.Engine.VerifyHeader(h.Chain, header, true); err != consensus.ErrUnknownAncestor {
+		t.Errorf("unknown ancestor: error mismatch: have %v, want %v", err, consensus.ErrUnknownAncestor)
+	}
+	header = h.prepare(t, h.Chain.Genesis()).Header()
+	header.Time = big.NewInt(time.Now().Add(time.Hour).Unix())
+	if err := h.Engine.VerifyHeader(h.Chain, header, true); err != consensus.ErrFutureBlock {
+		t.Errorf("future block: error mismatch: have %v, want %v", err, consensus.ErrFutureBlock)
+	}
+	// Batches must be verified in order, reporting results in input order
+	h.insert(t, first)
+	second := h.seal(t, first)
+	headers := []*types.Header{first.Header(), second.Header()}
+
+	abort, results := h.Engine.VerifyHeaders(h.Chain, headers, []bool{true, true})
+	defer close(abort)
+	for i := range headers {
+		select {
+		case err := <-results:
+			if err != nil {
+				t.Errorf("batch header %d rejected: %v", i, err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("batch header %d: verification timed out", i)
+		}
+	}
+	h.insert(t, second)
+	if head := h.Chain.CurrentBlock().Hash(); head != second.Hash() {
+		t.Errorf("head mismatch: have %x, want %x", head, second.Hash())
+	}
+}
+
+// CheckSealVerification checks that the seal of a sealed header verifies, that
+// it attributes the block to an authorized sealer and that it does not survive
+// any modification of the header.
+func CheckSealVerification(t *testing.T, h *Harness) {
+	block := h.seal(t, h.Chain.Genesis())
+	if err := h.Engine.VerifySeal(h.Chain, block.Header()); err != nil {
+		t.Fatalf("valid seal rejected: %v", err)
+	}
+	author, err := h.Engine.Author(block.Header())
+	if err != nil {
+		t.Fatalf("failed to retrieve author: %v", err)
+	}
+	if h.Validators != nil {
+		validators, err := h.Validators(0)
+		if err != nil {
+			t.Fatalf("failed to retrieve genesis validators: %v", err)
+		}
+		if !contains(validators, author) {
+			t.Errorf("author %x not in validator set %x", author, validators)
+		}
+	}
+	tampers := map[string]func(header *types.Header){
+		"root":     func(header *types.Header) { header.Root = common.Hash{0x01} },
+		"gaslimit": func(header *types.Header) { header.GasLimit++ },
+		"time":     func(header *types.Header) { header.Time = new(big.Int).Add(header.Time, common.Big1) },
+	}
+	for name, tamper := range tampers {
+		header := block.Header()
+		tamper(header)
+		if err := h.Engine.VerifySeal(h.Chain, header); err == nil {
+			t.Errorf("%s: tampered seal accepted", name)
+		}
+	}
+}
+
+// CheckFinality checks the finality proofs of engines implementing the
+// consensus.FinalityVerifier extension: sealed blocks are final, blocks merely
+// assembled are not.
+func CheckFinality(t *testing.T, h *Harness) {
+	verifier, ok := h.Engine.(consensus.FinalityVerifier)
+	if !ok {
+		t.Skip("engine without finality proofs")
+	}
+	genesis := h.Chain.Genesis()
+	if err := verifier.VerifyFinality(h.Chain, h.prepare(t, genesis).Header()); err == nil {
+		t.Errorf("unsealed header deemed final")
+	}
+	block := h.seal(t, genesis)
+	if err := verifier.VerifyFinality(h.Chain, block.Header()); err != nil {
+		t.Errorf("sealed header not final: %v", err)
+	}
+	// Finality is a property of the header alone, importing it changes nothing
+	h.insert(t, block)
+	if err := verifier.VerifyFinality(h.Chain, block.Header()); err != nil {
+		t.Errorf("imported header not final: %v", err)
+	}
+}
+
+// CheckValidatorTransitions checks that the validator set only changes through
+// sealed votes, and that a vote passing the majority takes effect in the block
+// carrying it.
+func CheckValidatorTransitions(t *testing.T, h *Harness) {
+	if h.Propose == nil || h.Validators == nil {
+		t.Skip("engine without validator votes")
+	}
+	genesis, err := h.Validators(0)
+	if err != nil {
+		t.Fatalf("failed to retrieve genesis validators: %v", err)
+	}
+	if len(genesis) != 1 {
+		t.Skipf("majority votes need a single genesis validator, have %d", len(genesis))
+	}
+	// Blocks without votes must carry the validator set over
+	block := h.seal(t, h.Chain.Genesis())
+	h.insert(t, block)
+	h.checkValidators(t, block.NumberU64(), genesis)
+
+	// A vote of the only validator passes, adding the candidate right away
+	key, _ := crypto.GenerateKey()
+	candidate := crypto.PubkeyToAddress(key.PublicKey)
+
+	h.Propose(candidate, true)
+	block = h.seal(t, block)
+	h.insert(t, block)
+	h.checkValidators(t, block.NumberU64(), append(genesis, candidate))
+}
+
+// prepare assembles an unsealed block on top of parent.
+func (h *Harness) prepare(t *testing.T, parent *types.Block) *types.Block {
+	header := &types.Header{
+		ParentHash: parent.Hash(),
+		Number:     new(big.Int).Add(parent.Number(), common.Big1),
+		GasLimit:   core.CalcGasLimit(parent),
+		Time:       new(big.Int).Add(parent.Time(), common.Big1),
+	}
+	if err := h.Engine.Prepare(h.Chain, header); err != nil {
+		t.Fatalf("failed to prepare header: %v", err)
+	}
+	state, err := h.Chain.StateAt(parent.Root())
+	if err != nil {
+		t.Fatalf("failed to retrieve parent state: %v", err)
+	}
+	block, err := h.Engine.Finalize(h.Chain, header, state, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to finalize block: %v", err)
+	}
+	return block
+}
+
+// seal assembles and seals a block on top of parent.
+func (h *Harness) seal(t *testing.T, parent *types.Block) *types.Block {
+	block, err := h.Engine.Seal(h.Chain, h.prepare(t, parent), nil)
+	if err != nil {
+		t.Fatalf("failed to seal block: %v", err)
+	}
+	return block
+}
+
+// insert imports a sealed block into the chain.
+func (h *Harness) insert(t *testing.T, block *types.Block) {
+	if _, err := h.Chain.InsertChain(types.Blocks{block}); err != nil {
+		t.Fatalf("failed to import block #%d: %v", block.NumberU64(), err)
+	}
+}
+
+// checkValidators checks that the validator set authorized after the given block
+// consists of the wanted addresses, in any order.
+func (h *Harness) checkValidators(t *testing.T, number uint64, want []common.Address) {
+	have, err := h.Validators(number)
+	if err != nil {
+		t.Fatalf("failed to retrieve validators after block #%d: %v", number, err)
+	}
+	if len(have) != len(want) {
+		t.Fatalf("block #%d: validator count mismatch: have %d, want %d", number, len(have), len(want))
+	}
+	for _, address := range want {
+		if !contains(have, address) {
+			t.Errorf("block #%d: validator %x missing", number, address)
+		}
+	}
+}
+
+// contains returns whether the address is part of the list.
+func contains(addresses []common.Address, address common.Address) bool {
+	for _, a := range addresses {
+		if a == address {
+			return true
+		}
+	}
+	return false
+}
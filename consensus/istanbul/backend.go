@@ -24,6 +24,11 @@ import (
 	"github.com/ethereum/go-ethereum/event"
 )
 
+// DeliveryCallback is invoked once a message was handed to all the validators
+// it was sent to, reporting those it could not be delivered to because of a
+// missing connection, a send failure or a peer not keeping up.
+type DeliveryCallback func(undelivered []common.Address)
+
 // Backend provides application specific functions for Istanbul core
 type Backend interface {
 	// Address returns the owner's address
@@ -35,11 +40,13 @@ type Backend interface {
 	// EventMux returns the event mux in backend
 	EventMux() *event.TypeMux
 
-	// Broadcast sends a message to all validators (include self)
-	Broadcast(valSet ValidatorSet, payload []byte) error
+	// Broadcast sends a message to all validators (include self), reporting
+	// the outcome to done if not nil
+	Broadcast(valSet ValidatorSet, payload []byte, done DeliveryCallback) error
 
-	// Gossip sends a message to all validators (exclude self)
-	Gossip(valSet ValidatorSet, payload []byte) error
+	// Gossip sends a message to all validators (exclude self), reporting the
+	// outcome to done if not nil
+	Gossip(valSet ValidatorSet, payload []byte, done DeliveryCallback) error
 
 	// Commit delivers an approved proposal to backend.
 	// The delivered proposal will be put into blockchain.
@@ -56,6 +63,14 @@ type Backend interface {
 	// the given validator
 	CheckSignature(data []byte, addr common.Address, sig []byte) error
 
+	// SignCommittedSeal creates the backend's committed seal of the proposal
+	// with the given hash and height
+	SignCommittedSeal(hash common.Hash, number *big.Int) ([]byte, error)
+
+	// CheckCommittedSeal verifies that the committed seal of the proposal with
+	// the given hash and height was created by the given validator
+	CheckCommittedSeal(hash common.Hash, number *big.Int, addr common.Address, seal []byte) error
+
 	// LastProposal retrieves latest committed proposal and the address of proposer
 	LastProposal() (Proposal, common.Address)
 
@@ -70,4 +85,7 @@ type Backend interface {
 
 	// HasBadBlock returns whether the block with the hash is a bad block
 	HasBadProposal(hash common.Hash) bool
+
+	// Penalize lowers the score of a validator caught sending invalid messages
+	Penalize(address common.Address, reason error)
 }
@@ -0,0 +1,179 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package backend
+
+import (
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/p2p"
+)
+
+// maxAnnounceLead is the maximum number of blocks an announced head may be ahead
+// of the local one to be synced to. Nodes lagging further behind are left to the
+// regular eth sync, as the validators at their head can't vouch for the seals.
+const maxAnnounceLead = 1024
+
+var finalizedSyncMeter = metrics.NewRegisteredMeter("consensus/istanbul/finalized/sync", nil)
+
+// finalizedAnnouncement is the latest finalized block of a validator, broadcast
+// periodically for lagging peers to catch up without waiting for the next eth
+// sync cycle.
+type finalizedAnnouncement struct {
+	Header *types.Header // Header of the finalized block, carrying its committed seals
+}
+
+// startAnnouncing launches the periodic finalized head announcements, if enabled.
+func (sb *backend) startAnnouncing() {
+	if sb.config.AnnouncePeriod == 0 || sb.announceQuit != nil {
+		return
+	}
+	sb.announceQuit = make(chan struct{})
+	go sb.announceLoop(sb.announceQuit)
+}
+
+// stopAnnouncing terminates the periodic finalized head announcements.
+func (sb *backend) stopAnnouncing() {
+	if sb.announceQuit != nil {
+		close(sb.announceQuit)
+		sb.announceQuit = nil
+	}
+}
+
+func (sb *backend) announceLoop(quit chan struct{}) {
+	ticker := time.NewTicker(time.Duration(sb.config.AnnouncePeriod) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			sb.announceFinalized()
+		case <-quit:
+			return
+		}
+	}
+}
+
+// announceFinalized broadcasts the local head, final as soon as imported, to the
+// peers accepting announcements. Only validators announce, as only theirs are
+// acted upon.
+func (sb *backend) announceFinalized() {
+	head := sb.currentBlock()
+	if head == nil || head.NumberU64() == 0 {
+		return
+	}
+	if _, v := sb.getValidators(head.NumberU64(), head.Hash()).GetByAddress(sb.address); v == nil {
+		return
+	}
+	announcement := &finalizedAnnouncement{Header: head.Header()}
+	sb.announceMu.Lock()
+	peers := make([]consensus.Peer, 0, len(sb.announcePeers))
+	for _, p := range sb.announcePeers {
+		peers = append(peers, p)
+	}
+	sb.announceMu.Unlock()
+
+	for _, p := range peers {
+		go p.Send(istanbulFinalizedMsg, announcement)
+	}
+}
+
+// handleFinalized processes a finalized head announcement, requesting the missing
+// blocks from the announcing validator if the local chain is behind.
+func (sb *backend) handleFinalized(addr common.Address, msg p2p.Msg) error {
+	announcement := new(finalizedAnnouncement)
+	if err := msg.Decode(announcement); err != nil || announcement.Header == nil || announcement.Header.Number == nil {
+		return errDecodeFailed
+	}
+	if sb.currentBlock == nil {
+		return nil
+	}
+	var (
+		head   = sb.currentBlock()
+		header = announcement.Header
+		number = header.Number.Uint64()
+	)
+	if number <= head.NumberU64() || number > head.NumberU64()+maxAnnounceLead {
+		return nil
+	}
+	// Only follow validators, others could keep the node busy syncing from them
+	if _, v := sb.getValidators(head.NumberU64(), head.Hash()).GetByAddress(addr); v == nil {
+		return nil
+	}
+	syncer, ok := sb.broadcaster.(consensus.Synchroniser)
+	if !ok {
+		return nil
+	}
+	sb.announceMu.Lock()
+	if number <= sb.announceTarget {
+		sb.announceMu.Unlock()
+		return nil
+	}
+	sb.announceMu.Unlock()
+
+	// Make sure the announced block is final before chasing it
+	if err := sb.verifyAnnounced(header, head); err != nil {
+		sb.Penalize(addr, err)
+		return nil
+	}
+	sb.announceMu.Lock()
+	if number <= sb.announceTarget {
+		sb.announceMu.Unlock()
+		return nil
+	}
+	sb.announceTarget = number
+	sb.announceMu.Unlock()
+
+	log.Debug("Behind announced finalized block, syncing", "peer", addr, "number", number, "hash", header.Hash(), "head", head.NumberU64())
+	finalizedSyncMeter.Mark(1)
+	go func() {
+		syncer.SyncFrom(addr, header.Hash(), number)
+
+		// Accept announcements up to the target again, whether the sync succeeded
+		// or failed, unless a later one is already in progress
+		sb.announceMu.Lock()
+		if sb.announceTarget == number {
+			sb.announceTarget = 0
+		}
+		sb.announceMu.Unlock()
+	}()
+	return nil
+}
+
+// verifyAnnounced checks the committed seals of an announced header against the
+// validators at the local head, the only ones known to the lagging node.
+func (sb *backend) verifyAnnounced(header *types.Header, head *types.Block) error {
+	extra, err := types.ExtractIstanbulExtra(header)
+	if err != nil {
+		return err
+	}
+	if len(extra.CommittedSeal) == 0 {
+		return errEmptyCommittedSeals
+	}
+	if config := thresholdConfig(sb.chain, header.Number); config != nil {
+		return VerifyThresholdSeal(config, header.Hash(), extra.CommittedSeal)
+	}
+	snap, err := sb.snapshot(sb.chain, head.NumberU64(), head.Hash(), nil)
+	if err != nil {
+		return err
+	}
+	return sb.verifySealQuorum(snap, header.Hash(), extra.CommittedSeal)
+}
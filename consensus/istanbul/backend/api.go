@@ -17,12 +17,23 @@
 package backend
 
 import (
+	"bytes"
+	"errors"
+	"sort"
+
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/consensus/istanbul"
+	istanbulCore "github.com/ethereum/go-ethereum/consensus/istanbul/core"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/rpc"
 )
 
+// errStateUnavailable is returned if the consensus core does not respond to a
+// state dump request in time.
+var errStateUnavailable = errors.New("consensus state unavailable")
+
 // API is a user facing RPC API to dump Istanbul state
 type API struct {
 	chain    consensus.ChainReader
@@ -87,6 +98,26 @@ func (api *API) GetValidatorsAtHash(hash common.Hash) ([]common.Address, error)
 	return snap.validators(), nil
 }
 
+// GetSystemCallReceipt retrieves the receipt of the system contract call made
+// when finalizing a block.
+func (api *API) GetSystemCallReceipt(number *rpc.BlockNumber) (*types.Receipt, error) {
+	// Retrieve the requested block number (or current if none requested)
+	var header *types.Header
+	if number == nil || *number == rpc.LatestBlockNumber {
+		header = api.chain.CurrentHeader()
+	} else {
+		header = api.chain.GetHeaderByNumber(uint64(number.Int64()))
+	}
+	if header == nil {
+		return nil, errUnknownBlock
+	}
+	block := api.chain.GetBlock(header.Hash(), header.Number.Uint64())
+	if block == nil {
+		return nil, errUnknownBlock
+	}
+	return api.istanbul.systemCall(block)
+}
+
 // Candidates returns the current candidates the node tries to uphold and vote on.
 func (api *API) Candidates() map[common.Address]bool {
 	api.istanbul.candidatesLock.RLock()
@@ -108,6 +139,34 @@ func (api *API) Propose(address common.Address, auth bool) {
 	api.istanbul.candidates[address] = auth
 }
 
+// SetMaintenance toggles maintenance mode, in which the validator keeps voting on
+// proposals but declines to act as proposer, allowing the node to be taken down
+// without stalling consensus on round change timeouts.
+func (api *API) SetMaintenance(enabled bool) {
+	api.istanbul.core.SetMaintenance(enabled)
+}
+
+// DumpState returns the current view of the consensus core: its state, the
+// pending round change votes, the backlog sizes and the timer deadlines. The
+// messages received for the current sequence are only included if requested.
+func (api *API) DumpState(messages *bool) (*istanbulCore.StateDump, error) {
+	api.istanbul.coreMu.RLock()
+	started := api.istanbul.coreStarted
+	api.istanbul.coreMu.RUnlock()
+
+	if !started {
+		return nil, istanbul.ErrStoppedEngine
+	}
+	dump := api.istanbul.core.Dump()
+	if dump == nil {
+		return nil, errStateUnavailable
+	}
+	if messages == nil || !*messages {
+		dump.Prepares, dump.Commits, dump.RoundChanges = nil, nil, nil
+	}
+	return dump, nil
+}
+
 // Discard drops a currently running candidate, stopping the validator from casting
 // further votes (either for or against).
 func (api *API) Discard(address common.Address) {
@@ -116,3 +175,228 @@ func (api *API) Discard(address common.Address) {
 
 	delete(api.istanbul.candidates, address)
 }
+
+// PeerVersions returns the attested build information of the current validators
+// and all connected peers, keyed by their address. Validators without an entry
+// carrying a version have not attested theirs (yet).
+func (api *API) PeerVersions() (map[common.Address]*PeerVersion, error) {
+	header := api.chain.CurrentHeader()
+	snap, err := api.istanbul.snapshot(api.chain, header.Number.Uint64(), header.Hash(), nil)
+	if err != nil {
+		return nil, err
+	}
+	return api.istanbul.peerVersions(snap.validators())
+}
+
+// Participation is the commit seal inclusion record of the validators over a
+// range of blocks.
+type Participation struct {
+	From       uint64                                     `json:"from"`
+	To         uint64                                     `json:"to"`
+	Validators map[common.Address]*ValidatorParticipation `json:"validators"`
+}
+
+// ValidatorParticipation is the commit seal inclusion record of a validator.
+type ValidatorParticipation struct {
+	Eligible uint64  `json:"eligible"` // Blocks the validator was expected to seal
+	Sealed   uint64  `json:"sealed"`   // Blocks including the validator's commit seal
+	Rate     float64 `json:"rate"`     // Fraction of eligible blocks sealed
+}
+
+// GetParticipation reports which validators' commit seals were included in the
+// window of blocks ending at the given block (current if none requested).
+func (api *API) GetParticipation(number *rpc.BlockNumber, window *uint64) (*Participation, error) {
+	var header *types.Header
+	if number == nil || *number == rpc.LatestBlockNumber {
+		header = api.chain.CurrentHeader()
+	} else {
+		header = api.chain.GetHeaderByNumber(uint64(number.Int64()))
+	}
+	if header == nil {
+		return nil, errUnknownBlock
+	}
+	blocks := uint64(defaultParticipationWindow)
+	if window != nil && *window > 0 {
+		blocks = *window
+	}
+	if blocks > maxParticipationWindow {
+		blocks = maxParticipationWindow
+	}
+	result := &Participation{
+		To:         header.Number.Uint64(),
+		Validators: make(map[common.Address]*ValidatorParticipation),
+	}
+	record := func(addr common.Address) *ValidatorParticipation {
+		if result.Validators[addr] == nil {
+			result.Validators[addr] = new(ValidatorParticipation)
+		}
+		return result.Validators[addr]
+	}
+	for ; blocks > 0 && header.Number.Sign() > 0; blocks-- {
+		result.From = header.Number.Uint64()
+
+		parent := api.chain.GetHeader(header.ParentHash, header.Number.Uint64()-1)
+		if parent == nil {
+			return nil, errUnknownBlock
+		}
+		snap, err := api.istanbul.snapshot(api.chain, parent.Number.Uint64(), parent.Hash(), nil)
+		if err != nil {
+			return nil, err
+		}
+		for _, validator := range snap.validators() {
+			record(validator).Eligible++
+		}
+		for _, sealer := range api.istanbul.sealersOf(api.chain, header) {
+			record(sealer).Sealed++
+		}
+		header = parent
+	}
+	for _, record := range result.Validators {
+		if record.Eligible > 0 {
+			record.Rate = float64(record.Sealed) / float64(record.Eligible)
+		}
+	}
+	return result, nil
+}
+
+// Misbehavior is evidence of a validator deviating from the protocol, along with
+// the block eventually agreed on at its sequence.
+type Misbehavior struct {
+	*istanbulCore.Misbehavior
+	Block    *common.Hash `json:"block"`    // Canonical block at the sequence, nil if not agreed on yet
+	Verified bool         `json:"verified"` // Whether all evidence is signed by the misbehaving validator
+}
+
+// GetMisbehavior returns the evidence of validator misbehavior detected locally
+// for the blocks in the given range, both ends included. The range defaults to
+// the current block, and the pending block stands for the sequence currently
+// being agreed on.
+func (api *API) GetMisbehavior(fromBlock, toBlock *rpc.BlockNumber) ([]*Misbehavior, error) {
+	head := api.chain.CurrentHeader().Number.Uint64()
+	resolve := func(number *rpc.BlockNumber) uint64 {
+		switch {
+		case number == nil || *number == rpc.LatestBlockNumber:
+			return head
+		case *number == rpc.PendingBlockNumber:
+			return head + 1
+		default:
+			return uint64(number.Int64())
+		}
+	}
+	to := resolve(toBlock)
+	from := to
+	if fromBlock != nil {
+		from = resolve(fromBlock)
+	}
+	records, err := api.istanbul.misbehavior.Load(from, to)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*Misbehavior, len(records))
+	for i, record := range records {
+		result[i] = &Misbehavior{Misbehavior: record, Verified: verifyEvidence(api.istanbul.config, record)}
+		if header := api.chain.GetHeaderByNumber(record.Sequence); header != nil {
+			hash := header.Hash()
+			result[i].Block = &hash
+		}
+	}
+	return result, nil
+}
+
+// verifyEvidence recomputes the signers of the evidence of a misbehavior, so it
+// doesn't have to be trusted to be filed under the right validator. Records
+// without evidence, e.g. timeouts, are never verified.
+func verifyEvidence(config *istanbul.Config, record *istanbulCore.Misbehavior) bool {
+	if len(record.Evidence) == 0 {
+		return false
+	}
+	for _, evidence := range record.Evidence {
+		if signer, err := istanbulCore.VerifyEvidence(config, evidence); err != nil || signer != record.Address {
+			return false
+		}
+	}
+	return true
+}
+
+// UpgradeStatus is the signaling progress of a protocol upgrade.
+type UpgradeStatus struct {
+	Name       string           `json:"name"`
+	Signal     hexutil.Bytes    `json:"signal"`               // Identifier signaled in the header vanity
+	Signaled   []common.Address `json:"signaled"`             // Validators signaled within the current epoch
+	Threshold  int              `json:"threshold"`            // Validators needing to signal within an epoch
+	Ready      *uint64          `json:"ready,omitempty"`      // End of the epoch the threshold was reached in
+	Activation *uint64          `json:"activation,omitempty"` // Block the upgrade activates at
+	Active     bool             `json:"active"`
+}
+
+// UpgradeStatus reports the signaling progress of the protocol upgrades
+// configured for the chain as of the current block.
+func (api *API) UpgradeStatus() ([]*UpgradeStatus, error) {
+	config := api.chain.Config().Istanbul
+	if config == nil || len(config.Upgrades) == 0 {
+		return []*UpgradeStatus{}, nil
+	}
+	header := api.chain.CurrentHeader()
+	number := header.Number.Uint64()
+
+	signals, snap, err := api.istanbul.signalsIn(api.chain, header, number-number%api.istanbul.config.Epoch)
+	if err != nil {
+		return nil, err
+	}
+	statuses := make([]*UpgradeStatus, 0, len(config.Upgrades))
+	for _, upgrade := range config.Upgrades {
+		id := types.IstanbulUpgradeID(upgrade.Name)
+		status := &UpgradeStatus{
+			Name:      upgrade.Name,
+			Signal:    id[:],
+			Signaled:  []common.Address{},
+			Threshold: 2*snap.ValSet.F() + 1,
+		}
+		for signer := range signals[id] {
+			status.Signaled = append(status.Signaled, signer)
+		}
+		sort.Slice(status.Signaled, func(i, j int) bool {
+			return bytes.Compare(status.Signaled[i][:], status.Signaled[j][:]) < 0
+		})
+		ready, ok, err := api.istanbul.upgradeReady(api.chain, upgrade, number)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			activation := ready + upgrade.Delay
+			status.Ready, status.Activation = &ready, &activation
+			status.Active = number >= activation
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// DealKeyShares starts the validator's part in a threshold key generation for
+// the members identified by their node public keys, returning the dealing to be
+// handed to all of them.
+func (api *API) DealKeyShares(threshold uint64, members []hexutil.Bytes) (*KeyDealing, error) {
+	return api.istanbul.dealKeyShares(threshold, members)
+}
+
+// CombineKeyShares finishes the validator's part in a threshold key generation,
+// storing its share of the group key and returning the group configuration to
+// activate threshold seals with.
+func (api *API) CombineKeyShares(dealings []*KeyDealing, exclude *[]common.Address) (*KeyGeneration, error) {
+	var excluded []common.Address
+	if exclude != nil {
+		excluded = *exclude
+	}
+	return api.istanbul.combineKeyShares(dealings, excluded)
+}
+
+// DebugAPI is a private RPC API to troubleshoot the Istanbul consensus.
+type DebugAPI struct {
+	istanbul *backend
+}
+
+// ReplayConsensus re-runs the consensus of the given sequence over the archived
+// messages of it, reporting the state transitions step by step.
+func (api *DebugAPI) ReplayConsensus(sequence uint64) (*ReplayResult, error) {
+	return api.istanbul.replay(sequence)
+}
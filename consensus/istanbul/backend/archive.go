@@ -0,0 +1,297 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package backend
+
+import (
+	"encoding/binary"
+	"errors"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/istanbul"
+	istanbulCore "github.com/ethereum/go-ethereum/consensus/istanbul/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+const (
+	dbKeyArchivePrefix = "istanbul-archive"        // Archived consensus messages keyed by big endian sequence and index
+	dbKeyArchiveLatest = "istanbul-archive-latest" // Highest sequence archived so far
+)
+
+var (
+	// errArchiveDisabled is returned when replaying a sequence without archiving
+	// consensus messages.
+	errArchiveDisabled = errors.New("consensus message archive disabled")
+	// errNotArchived is returned when replaying a sequence without any messages
+	// in the archive.
+	errNotArchived = errors.New("no archived messages for sequence")
+)
+
+// archiveKey returns the database key of the index-th message archived for a
+// sequence.
+func archiveKey(sequence uint64, index uint32) []byte {
+	key := make([]byte, len(dbKeyArchivePrefix)+12)
+	copy(key, dbKeyArchivePrefix)
+	binary.BigEndian.PutUint64(key[len(dbKeyArchivePrefix):], sequence)
+	binary.BigEndian.PutUint32(key[len(dbKeyArchivePrefix)+8:], index)
+	return key
+}
+
+// messageArchive persists the consensus messages of the most recent sequences
+// into the database, in the order they were received.
+type messageArchive struct {
+	db        ethdb.Database
+	retention uint64            // Number of sequences to keep the messages of
+	latest    uint64            // Highest sequence archived so far
+	counts    map[uint64]uint32 // Number of messages archived per sequence, filled lazily
+
+	lock sync.Mutex
+}
+
+// newMessageArchive creates an archive keeping the messages of the last retention
+// sequences, resuming the one persisted in the database, if any.
+func newMessageArchive(db ethdb.Database, retention uint64) *messageArchive {
+	a := &messageArchive{
+		db:        db,
+		retention: retention,
+		counts:    make(map[uint64]uint32),
+	}
+	if blob, err := db.Get([]byte(dbKeyArchiveLatest)); err == nil && len(blob) == 8 {
+		a.latest = binary.BigEndian.Uint64(blob)
+	}
+	return a
+}
+
+// Store implements core.Archive.Store, appending a message payload to the ones
+// of its sequence and pruning sequences falling out of the retention window.
+func (a *messageArchive) Store(sequence uint64, payload []byte) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	// Messages of pruned sequences may still trickle in, don't resurrect them
+	if sequence+a.retention <= a.latest {
+		return
+	}
+	index := a.count(sequence)
+	if err := a.db.Put(archiveKey(sequence, index), payload); err != nil {
+		log.Error("Failed to archive consensus message", "sequence", sequence, "err", err)
+		return
+	}
+	a.counts[sequence] = index + 1
+
+	if sequence > a.latest {
+		// Drop the archived sequences falling out of the moved window
+		var from uint64 = 1
+		if a.latest > a.retention {
+			from = a.latest - a.retention + 1
+		}
+		for old := from; old <= a.latest && old+a.retention <= sequence; old++ {
+			a.drop(old)
+		}
+		a.setLatest(sequence)
+	}
+}
+
+// Load retrieves the message payloads archived for a sequence.
+func (a *messageArchive) Load(sequence uint64) ([][]byte, error) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	return a.load(sequence)
+}
+
+// rewind drops the messages archived for the sequences above head, as they belong
+// to a rewound chain and would taint replays of the sequences run anew.
+func (a *messageArchive) rewind(head uint64) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	for seq := head + 1; seq <= a.latest; seq++ {
+		a.drop(seq)
+	}
+	if a.latest > head {
+		a.setLatest(head)
+	}
+}
+
+func (a *messageArchive) load(sequence uint64) ([][]byte, error) {
+	count := a.count(sequence)
+	if count == 0 {
+		return nil, errNotArchived
+	}
+	payloads := make([][]byte, count)
+	for i := range payloads {
+		payload, err := a.db.Get(archiveKey(sequence, uint32(i)))
+		if err != nil {
+			return nil, err
+		}
+		payloads[i] = payload
+	}
+	return payloads, nil
+}
+
+// count returns the number of messages archived for a sequence, looking them up
+// in the database if not known yet, e.g. after a restart.
+func (a *messageArchive) count(sequence uint64) uint32 {
+	if count, ok := a.counts[sequence]; ok {
+		return count
+	}
+	var count uint32
+	for {
+		if ok, _ := a.db.Has(archiveKey(sequence, count)); !ok {
+			break
+		}
+		count++
+	}
+	a.counts[sequence] = count
+	return count
+}
+
+// drop deletes the messages archived for a sequence.
+func (a *messageArchive) drop(sequence uint64) {
+	count := a.count(sequence)
+	for i := uint32(0); i < count; i++ {
+		a.db.Delete(archiveKey(sequence, i))
+	}
+	delete(a.counts, sequence)
+}
+
+// setLatest updates the highest archived sequence, persisting it to resume the
+// retention window after a restart.
+func (a *messageArchive) setLatest(sequence uint64) {
+	var blob [8]byte
+	binary.BigEndian.PutUint64(blob[:], sequence)
+	if err := a.db.Put([]byte(dbKeyArchiveLatest), blob[:]); err != nil {
+		log.Error("Failed to persist latest archived sequence", "sequence", sequence, "err", err)
+	}
+	a.latest = sequence
+}
+
+// ReplayResult is the outcome of replaying the archived messages of a sequence.
+type ReplayResult struct {
+	Sequence uint64 `json:"sequence"`
+	*istanbulCore.ReplayResult
+}
+
+// replay re-runs the consensus of a sequence over its archived messages in a
+// sandboxed core, which neither gossips messages nor commits blocks.
+func (sb *backend) replay(sequence uint64) (*ReplayResult, error) {
+	if sb.archive == nil {
+		return nil, errArchiveDisabled
+	}
+	sb.coreMu.RLock()
+	started := sb.coreStarted
+	sb.coreMu.RUnlock()
+	if !started {
+		return nil, istanbul.ErrStoppedEngine
+	}
+	if sequence == 0 {
+		return nil, errUnknownBlock
+	}
+	parent := sb.chain.GetHeaderByNumber(sequence - 1)
+	if parent == nil {
+		return nil, errUnknownBlock
+	}
+	block := sb.chain.GetBlock(parent.Hash(), parent.Number.Uint64())
+	if block == nil {
+		return nil, errUnknownBlock
+	}
+	var proposer common.Address
+	if parent.Number.Sign() > 0 {
+		var err error
+		if proposer, err = sb.Author(parent); err != nil {
+			return nil, err
+		}
+	}
+	payloads, err := sb.archive.Load(sequence)
+	if err != nil {
+		return nil, errNotArchived
+	}
+	sandbox := &replayBackend{
+		backend:  sb,
+		mux:      new(event.TypeMux),
+		parent:   block,
+		proposer: proposer,
+	}
+	return &ReplayResult{
+		Sequence:     sequence,
+		ReplayResult: istanbulCore.Replay(sandbox, sb.config, payloads),
+	}, nil
+}
+
+// replayBackend is the sandbox the archived messages of a sequence are replayed
+// in. It shares the keys and chain of the live backend, but rewinds the last
+// proposal to the parent of the sequence and swallows all outgoing messages and
+// commits.
+type replayBackend struct {
+	*backend
+
+	mux      *event.TypeMux
+	parent   *types.Block   // Last proposal before the replayed sequence
+	proposer common.Address // Proposer of the parent block
+}
+
+// EventMux implements istanbul.Backend.EventMux, isolating the sandbox from the
+// live core.
+func (rb *replayBackend) EventMux() *event.TypeMux {
+	return rb.mux
+}
+
+// Broadcast implements istanbul.Backend.Broadcast, dropping the message.
+func (rb *replayBackend) Broadcast(valSet istanbul.ValidatorSet, payload []byte, done istanbul.DeliveryCallback) error {
+	return nil
+}
+
+// Gossip implements istanbul.Backend.Gossip, dropping the message.
+func (rb *replayBackend) Gossip(valSet istanbul.ValidatorSet, payload []byte, done istanbul.DeliveryCallback) error {
+	return nil
+}
+
+// Commit implements istanbul.Backend.Commit, dropping the proposal.
+func (rb *replayBackend) Commit(proposal istanbul.Proposal, seals [][]byte) error {
+	return nil
+}
+
+// Verify implements istanbul.Backend.Verify, without reporting bad blocks or
+// sampling the clock drift.
+func (rb *replayBackend) Verify(proposal istanbul.Proposal) (time.Duration, error) {
+	block, ok := proposal.(*types.Block)
+	if !ok {
+		return 0, errInvalidProposal
+	}
+	return rb.verify(block)
+}
+
+// LastProposal implements istanbul.Backend.LastProposal, returning the parent of
+// the replayed sequence.
+func (rb *replayBackend) LastProposal() (istanbul.Proposal, common.Address) {
+	return rb.parent, rb.proposer
+}
+
+// HasPropsal implements istanbul.Backend.HasPropsal, hiding the blocks imported
+// after the parent of the replayed sequence.
+func (rb *replayBackend) HasPropsal(hash common.Hash, number *big.Int) bool {
+	if number.Cmp(rb.parent.Number()) > 0 {
+		return false
+	}
+	return rb.backend.HasPropsal(hash, number)
+}
@@ -0,0 +1,106 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package backend
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/ethdb"
+)
+
+// Tests that the message archive keeps the payloads of a sequence in order and
+// prunes the sequences leaving the retention window.
+func TestMessageArchive(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+	archive := newMessageArchive(db, 2)
+
+	archive.Store(1, []byte{0x01})
+	archive.Store(1, []byte{0x02})
+	archive.Store(2, []byte{0x03})
+
+	payloads, err := archive.Load(1)
+	if err != nil {
+		t.Fatalf("failed to load sequence 1: %v", err)
+	}
+	if len(payloads) != 2 || !bytes.Equal(payloads[0], []byte{0x01}) || !bytes.Equal(payloads[1], []byte{0x02}) {
+		t.Fatalf("payloads mismatch: have %x, want [01 02]", payloads)
+	}
+	// Entering sequence 3 should drop sequence 1, but keep 2
+	archive.Store(3, []byte{0x04})
+	if _, err := archive.Load(1); err == nil {
+		t.Errorf("sequence 1 not pruned")
+	}
+	if _, err := archive.Load(2); err != nil {
+		t.Errorf("sequence 2 pruned: %v", err)
+	}
+	// Late messages of pruned sequences should not be archived
+	archive.Store(1, []byte{0x05})
+	if _, err := archive.Load(1); err == nil {
+		t.Errorf("pruned sequence 1 resurrected")
+	}
+	// Jumping ahead should prune everything out of the window
+	archive.Store(10, []byte{0x06})
+	for _, seq := range []uint64{2, 3} {
+		if _, err := archive.Load(seq); err == nil {
+			t.Errorf("sequence %d not pruned", seq)
+		}
+	}
+}
+
+// Tests that the archive resumes its retention window and the messages of its
+// sequences after a restart.
+func TestMessageArchiveRestart(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+	archive := newMessageArchive(db, 2)
+
+	archive.Store(1, []byte{0x01})
+	archive.Store(2, []byte{0x02})
+	archive.Store(3, []byte{0x03})
+
+	archive = newMessageArchive(db, 2)
+
+	// Late messages of pruned sequences should not be archived after a restart
+	archive.Store(1, []byte{0x04})
+	if _, err := archive.Load(1); err == nil {
+		t.Errorf("pruned sequence 1 resurrected")
+	}
+	// Messages should be appended to the ones archived before the restart
+	archive.Store(3, []byte{0x05})
+	payloads, err := archive.Load(3)
+	if err != nil {
+		t.Fatalf("failed to load sequence 3: %v", err)
+	}
+	if len(payloads) != 2 || !bytes.Equal(payloads[0], []byte{0x03}) || !bytes.Equal(payloads[1], []byte{0x05}) {
+		t.Fatalf("payloads mismatch: have %x, want [03 05]", payloads)
+	}
+	// Moving the window should prune the sequences archived before the restart
+	archive.Store(4, []byte{0x06})
+	if _, err := archive.Load(2); err == nil {
+		t.Errorf("sequence 2 not pruned")
+	}
+	// Rewinds should survive restarts too
+	archive.rewind(3)
+	archive = newMessageArchive(db, 2)
+	if _, err := archive.Load(4); err == nil {
+		t.Errorf("rewound sequence 4 not dropped")
+	}
+	archive.Store(2, []byte{0x07})
+	if _, err := archive.Load(2); err != nil {
+		t.Errorf("sequence 2 within the rewound window not archived: %v", err)
+	}
+}
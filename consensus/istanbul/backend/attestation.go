@@ -0,0 +1,184 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package backend
+
+import (
+	"errors"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/consensus/istanbul"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// errAttestationSigner is returned if a version attestation is not signed by
+// the key of the peer sending it.
+var errAttestationSigner = errors.New("version attestation not signed by peer")
+
+// VersionAttestation is the build information a node signs with its node key
+// and sends to its peers after connecting, letting operators verify that the
+// whole validator set upgraded before activating a fork.
+type VersionAttestation struct {
+	Version   string   `json:"version"`
+	Commit    string   `json:"commit"`
+	Features  []string `json:"features"`
+	Time      uint64   `json:"time"` // Unix time of signing
+	Signature []byte   `json:"-"`
+}
+
+// sigData returns the data covered by the signature of the attestation.
+func (a *VersionAttestation) sigData() ([]byte, error) {
+	return rlp.EncodeToBytes([]interface{}{a.Version, a.Commit, a.Features, a.Time})
+}
+
+// PeerVersion is the attested build information of a validator or peer.
+type PeerVersion struct {
+	*VersionAttestation
+
+	Validator bool `json:"validator"` // Whether the node is in the current validator set
+	Connected bool `json:"connected"` // Whether the node is currently connected
+}
+
+// attestation creates a freshly signed attestation of the local build.
+func (sb *backend) attestation() (*VersionAttestation, error) {
+	att := &VersionAttestation{
+		Version:  sb.config.BuildVersion,
+		Commit:   sb.config.BuildCommit,
+		Features: sb.config.BuildFeatures,
+		Time:     uint64(time.Now().Unix()),
+	}
+	if att.Features == nil {
+		att.Features = []string{}
+	}
+	data, err := att.sigData()
+	if err != nil {
+		return nil, err
+	}
+	if att.Signature, err = sb.Sign(data); err != nil {
+		return nil, err
+	}
+	return att, nil
+}
+
+// PeerConnected implements consensus.PeerHandler, sending the signed version
+// attestation to peers supporting it.
+func (sb *backend) PeerConnected(addr common.Address, peer consensus.Peer, version uint) error {
+	sb.versionsMu.Lock()
+	if known := sb.versions[addr]; known != nil {
+		known.Connected = true
+	} else {
+		sb.versions[addr] = &PeerVersion{Connected: true}
+	}
+	sb.versionsMu.Unlock()
+
+	if version >= istanbul66 {
+		sb.announceMu.Lock()
+		sb.announcePeers[addr] = peer
+		sb.announceMu.Unlock()
+	}
+	if version < istanbul65 {
+		return nil
+	}
+	att, err := sb.attestation()
+	if err != nil {
+		return err
+	}
+	return peer.Send(istanbulVersionMsg, att)
+}
+
+// PeerDisconnected implements consensus.PeerHandler. The last attestation of
+// the peer is retained for reporting.
+func (sb *backend) PeerDisconnected(addr common.Address) {
+	sb.announceMu.Lock()
+	delete(sb.announcePeers, addr)
+	sb.announceMu.Unlock()
+
+	sb.versionsMu.Lock()
+	defer sb.versionsMu.Unlock()
+
+	if known := sb.versions[addr]; known != nil {
+		known.Connected = false
+	}
+}
+
+// handleAttestation verifies a version attestation received from a peer and
+// records it.
+func (sb *backend) handleAttestation(addr common.Address, msg p2p.Msg) error {
+	att := new(VersionAttestation)
+	if err := msg.Decode(att); err != nil {
+		return errDecodeFailed
+	}
+	data, err := att.sigData()
+	if err != nil {
+		return err
+	}
+	signer, err := istanbul.GetSignatureAddress(data, att.Signature)
+	if err != nil {
+		return err
+	}
+	if signer != addr {
+		return errAttestationSigner
+	}
+	// Attestations are signed right before being sent on connection, use the ones
+	// of validators to measure the drift of the local clock against them. Unlike
+	// proposal timestamps they can't be stale, e.g. re-proposed in a later round.
+	if sb.currentBlock != nil {
+		head := sb.currentBlock()
+		if _, v := sb.getValidators(head.NumberU64(), head.Hash()).GetByAddress(addr); v != nil {
+			sb.clock.addPeerSample(time.Unix(int64(att.Time), 0))
+		}
+	}
+	sb.versionsMu.Lock()
+	defer sb.versionsMu.Unlock()
+
+	if known := sb.versions[addr]; known != nil {
+		known.VersionAttestation = att
+	} else {
+		sb.versions[addr] = &PeerVersion{VersionAttestation: att, Connected: true}
+	}
+	return nil
+}
+
+// peerVersions returns the attested versions of the given validators and all
+// other known peers, including the local node.
+func (sb *backend) peerVersions(validators []common.Address) (map[common.Address]*PeerVersion, error) {
+	local, err := sb.attestation()
+	if err != nil {
+		return nil, err
+	}
+	versions := map[common.Address]*PeerVersion{
+		sb.address: {VersionAttestation: local, Connected: true},
+	}
+	sb.versionsMu.RLock()
+	for addr, known := range sb.versions {
+		if addr != sb.address {
+			entry := *known
+			versions[addr] = &entry
+		}
+	}
+	sb.versionsMu.RUnlock()
+
+	for _, addr := range validators {
+		if versions[addr] == nil {
+			versions[addr] = new(PeerVersion)
+		}
+		versions[addr].Validator = true
+	}
+	return versions, nil
+}
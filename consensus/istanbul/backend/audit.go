@@ -0,0 +1,282 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package backend
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	istanbulCore "github.com/ethereum/go-ethereum/consensus/istanbul/core"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+const (
+	auditFileLimit  = 64 * 1024 * 1024 // Size of an audit file after which a new one is started
+	auditFilePrefix = "audit-"         // Prefix of the audit file names, followed by their creation time
+)
+
+var (
+	// errUnknownAuditFormat is returned if the audit log is requested in a format
+	// other than JSON or CSV.
+	errUnknownAuditFormat = errors.New("unknown audit log format")
+
+	// auditHeader is the first line of every CSV audit file.
+	auditHeader = []string{"time", "event", "code", "sequence", "round", "address", "digest", "outcome", "duration", "prev"}
+)
+
+// auditLog appends the consensus activity of the validator to rotating files in
+// a directory, as JSON lines or CSV rows. Every record carries the hash of the
+// previous one, chaining them across files so that modifying, removing or
+// reordering any record breaks the chain from it on.
+type auditLog struct {
+	dir    string
+	format string
+
+	file *os.File    // Audit file being appended to, nil if not yet opened
+	size int64       // Bytes written into the current file
+	prev common.Hash // Hash of the last record written
+
+	lock   sync.Mutex
+	logger log.Logger
+}
+
+// newAuditLog opens the audit log in the given directory, continuing the hash
+// chain of the records already in there.
+func newAuditLog(dir string, format string) (*auditLog, error) {
+	if format == "" {
+		format = "json"
+	}
+	if format != "json" && format != "csv" {
+		return nil, errUnknownAuditFormat
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	files, err := auditFiles(dir, format)
+	if err != nil {
+		return nil, err
+	}
+	audit := &auditLog{
+		dir:    dir,
+		format: format,
+		logger: log.New("audit", dir),
+	}
+	if len(files) > 0 {
+		last := files[len(files)-1]
+		if audit.prev, _, err = verifyAuditFile(last, audit.prev, false); err != nil {
+			return nil, err
+		}
+		if info, err := os.Stat(last); err == nil && info.Size() < auditFileLimit {
+			if audit.file, err = os.OpenFile(last, os.O_WRONLY|os.O_APPEND, 0600); err != nil {
+				return nil, err
+			}
+			audit.size = info.Size()
+		}
+	}
+	return audit, nil
+}
+
+// Audit implements core.Auditor, appending the record to the current audit
+// file. Failures are only logged, not to stall the consensus on a full disk.
+func (a *auditLog) Audit(record *istanbulCore.AuditRecord) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	line, err := a.encode(record)
+	if err != nil {
+		a.logger.Error("Failed to encode audit record", "err", err)
+		return
+	}
+	var blob []byte
+	if a.file == nil || a.size >= auditFileLimit {
+		if a.file != nil {
+			a.file.Close()
+		}
+		name := filepath.Join(a.dir, fmt.Sprintf("%s%s.%s", auditFilePrefix, time.Now().UTC().Format("20060102T150405.000000000"), a.format))
+		if a.file, err = os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600); err != nil {
+			a.logger.Error("Failed to create audit file", "err", err)
+			a.file = nil
+			return
+		}
+		a.size = 0
+		if a.format == "csv" {
+			blob = append(encodeCSV(auditHeader), '\n')
+		}
+	}
+	blob = append(append(blob, line...), '\n')
+	if _, err := a.file.Write(blob); err != nil {
+		a.logger.Error("Failed to write audit record", "err", err)
+		return
+	}
+	a.size += int64(len(blob))
+	a.prev = crypto.Keccak256Hash(line)
+}
+
+// encode serializes the record chained to the previous one, without the line
+// terminator.
+func (a *auditLog) encode(record *istanbulCore.AuditRecord) ([]byte, error) {
+	if a.format == "csv" {
+		return encodeCSV([]string{
+			record.Time.UTC().Format(time.RFC3339Nano),
+			record.Event,
+			record.Code,
+			strconv.FormatUint(record.Sequence, 10),
+			strconv.FormatUint(record.Round, 10),
+			record.Address.Hex(),
+			record.Digest.Hex(),
+			record.Outcome,
+			strconv.FormatInt(int64(record.Duration), 10),
+			a.prev.Hex(),
+		}), nil
+	}
+	return json.Marshal(&struct {
+		*istanbulCore.AuditRecord
+		Prev common.Hash `json:"prev"`
+	}{record, a.prev})
+}
+
+// Close closes the current audit file.
+func (a *auditLog) Close() error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	if a.file == nil {
+		return nil
+	}
+	err := a.file.Close()
+	a.file = nil
+	return err
+}
+
+// encodeCSV encodes a single CSV row without the line terminator.
+func encodeCSV(fields []string) []byte {
+	buf := new(bytes.Buffer)
+	w := csv.NewWriter(buf)
+	w.Write(fields)
+	w.Flush()
+	return bytes.TrimSuffix(buf.Bytes(), []byte{'\n'})
+}
+
+// auditFiles lists the audit files of the given format in the directory, oldest
+// first.
+func auditFiles(dir string, format string) ([]string, error) {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, info := range infos {
+		if !info.IsDir() && strings.HasPrefix(info.Name(), auditFilePrefix) && filepath.Ext(info.Name()) == "."+format {
+			files = append(files, filepath.Join(dir, info.Name()))
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// VerifyAuditLog checks the hash chain of the audit files in the directory,
+// returning the number of records in it or the first record breaking the chain.
+func VerifyAuditLog(dir string, format string) (int, error) {
+	files, err := auditFiles(dir, format)
+	if err != nil {
+		return 0, err
+	}
+	var (
+		prev  common.Hash
+		total int
+	)
+	for _, file := range files {
+		var count int
+		prev, count, err = verifyAuditFile(file, prev, true)
+		if total += count; err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+// verifyAuditFile follows the hash chain through the records of an audit file,
+// starting from the given hash, returning the hash of its last record. Unless
+// strict, the links are not checked, only the last hash recovered.
+func verifyAuditFile(file string, prev common.Hash, strict bool) (common.Hash, int, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return prev, 0, err
+	}
+	defer f.Close()
+
+	var (
+		scanner = bufio.NewScanner(f)
+		number  int
+		records int
+	)
+	scanner.Buffer(nil, 1024*1024)
+	for scanner.Scan() {
+		number++
+		line := scanner.Bytes()
+		if len(line) == 0 || (filepath.Ext(file) == ".csv" && number == 1) {
+			continue
+		}
+		if strict {
+			link, err := auditLink(file, line)
+			if err != nil {
+				return prev, records, fmt.Errorf("%s:%d: %v", file, number, err)
+			}
+			if link != prev {
+				return prev, records, fmt.Errorf("%s:%d: broken hash chain: have %x, want %x", file, number, link, prev)
+			}
+		}
+		prev = crypto.Keccak256Hash(line)
+		records++
+	}
+	return prev, records, scanner.Err()
+}
+
+// auditLink extracts the hash of the previous record from an audit record.
+func auditLink(file string, line []byte) (common.Hash, error) {
+	if filepath.Ext(file) == ".csv" {
+		fields, err := csv.NewReader(bytes.NewReader(line)).Read()
+		if err != nil {
+			return common.Hash{}, err
+		}
+		if len(fields) != len(auditHeader) {
+			return common.Hash{}, fmt.Errorf("invalid field count %d", len(fields))
+		}
+		return common.HexToHash(fields[len(fields)-1]), nil
+	}
+	var record struct {
+		Prev common.Hash `json:"prev"`
+	}
+	if err := json.Unmarshal(line, &record); err != nil {
+		return common.Hash{}, err
+	}
+	return record.Prev, nil
+}
@@ -0,0 +1,85 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package backend
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	istanbulCore "github.com/ethereum/go-ethereum/consensus/istanbul/core"
+)
+
+// Tests that the audit log chains its records across restarts and files, and
+// that tampering with any of them is detected.
+func TestAuditLog(t *testing.T) {
+	for _, format := range []string{"json", "csv"} {
+		dir, err := ioutil.TempDir("", "istanbul-audit-")
+		if err != nil {
+			t.Fatalf("failed to create temporary directory: %v", err)
+		}
+		defer os.RemoveAll(dir)
+
+		record := func(audit *auditLog, sequence uint64) {
+			audit.Audit(&istanbulCore.AuditRecord{
+				Time:     time.Now(),
+				Event:    istanbulCore.AuditReceived,
+				Code:     "preprepare",
+				Sequence: sequence,
+				Address:  common.HexToAddress("0x01"),
+				Digest:   common.HexToHash("0x02"),
+				Outcome:  "invalid proposal, \"quoted\"",
+			})
+		}
+		// Write records into two files, reopening the log in between
+		audit, err := newAuditLog(dir, format)
+		if err != nil {
+			t.Fatalf("%s: failed to open audit log: %v", format, err)
+		}
+		for i := uint64(1); i <= 3; i++ {
+			record(audit, i)
+		}
+		audit.Close()
+		record(audit, 4)
+		audit.Close()
+
+		if audit, err = newAuditLog(dir, format); err != nil {
+			t.Fatalf("%s: failed to reopen audit log: %v", format, err)
+		}
+		record(audit, 5)
+		audit.Close()
+
+		files, _ := auditFiles(dir, format)
+		if len(files) != 2 {
+			t.Fatalf("%s: audit file count mismatch: have %d, want 2", format, len(files))
+		}
+		if records, err := VerifyAuditLog(dir, format); err != nil || records != 5 {
+			t.Fatalf("%s: verification mismatch: have %d records (err %v), want 5", format, records, err)
+		}
+		// Modify a record in the first file and ensure the chain breaks after it
+		blob, _ := ioutil.ReadFile(files[0])
+		blob = bytes.Replace(blob, []byte("invalid proposal"), []byte("valid proposal"), 1)
+		ioutil.WriteFile(files[0], blob, 0600)
+
+		if records, err := VerifyAuditLog(dir, format); err == nil || records != 1 {
+			t.Errorf("%s: tampered verification mismatch: have %d records (err %v), want 1 and failure", format, records, err)
+		}
+	}
+}
@@ -18,6 +18,7 @@ package backend
 
 import (
 	"crypto/ecdsa"
+	"errors"
 	"math/big"
 	"sync"
 	"time"
@@ -41,12 +42,18 @@ const (
 	fetcherID = "istanbul"
 )
 
+// errDeliveryTimeout is reported for a peer a message could not be handed to
+// within half a round.
+var errDeliveryTimeout = errors.New("delivery timed out")
+
 // New creates an Ethereum backend for Istanbul core engine.
 func New(config *istanbul.Config, privateKey *ecdsa.PrivateKey, db ethdb.Database) consensus.Istanbul {
 	// Allocate the snapshot caches and create the engine
 	recents, _ := lru.NewARC(inmemorySnapshots)
 	recentMessages, _ := lru.NewARC(inmemoryPeers)
 	knownMessages, _ := lru.NewARC(inmemoryMessages)
+	sealers, _ := lru.NewARC(inmemorySealers)
+	parentSealers, _ := lru.NewARC(inmemorySealers)
 	backend := &backend{
 		config:           config,
 		istanbulEventMux: new(event.TypeMux),
@@ -60,8 +67,31 @@ func New(config *istanbul.Config, privateKey *ecdsa.PrivateKey, db ethdb.Databas
 		coreStarted:      false,
 		recentMessages:   recentMessages,
 		knownMessages:    knownMessages,
+		sealers:          sealers,
+		parentSealers:    parentSealers,
+		versions:         make(map[common.Address]*PeerVersion),
+		announcePeers:    make(map[common.Address]consensus.Peer),
+		upgradeEpochs:    make(map[uint64]map[[4]byte]bool),
+		upgradesActive:   make(map[string]bool),
+		scores:           make(map[common.Address]int),
+		clock:            newClockGuard(config),
 	}
 	backend.core = istanbulCore.New(backend, backend.config)
+	backend.misbehavior = newMisbehaviorLog(db)
+	backend.core.SetMisbehaviorLog(backend.misbehavior)
+	if config.ArchiveRetention > 0 {
+		backend.archive = newMessageArchive(db, config.ArchiveRetention)
+		backend.core.SetArchive(backend.archive)
+	}
+	if config.AuditLog != "" {
+		audit, err := newAuditLog(config.AuditLog, config.AuditFormat)
+		if err != nil {
+			log.Error("Failed to open consensus audit log", "dir", config.AuditLog, "err", err)
+		} else {
+			backend.audit = audit
+			backend.core.SetAuditor(audit)
+		}
+	}
 	return backend
 }
 
@@ -98,6 +128,41 @@ type backend struct {
 
 	recentMessages *lru.ARCCache // the cache of peer's messages
 	knownMessages  *lru.ARCCache // the cache of self messages
+
+	clock   *clockGuard     // local clock drift detector
+	archive *messageArchive // consensus message archive for replaying, nil if disabled
+	audit   *auditLog       // audit trail of the consensus activity, nil if disabled
+
+	misbehavior *misbehaviorLog // evidence of the misbehavior detected by the core
+
+	proposalValidator   consensus.ProposalValidator // application level proposal validation hook
+	proposalValidatorMu sync.RWMutex
+
+	signFn func(hash []byte) ([]byte, error) // delegated signer, nil if signing with the node key
+	signMu sync.RWMutex
+
+	sealers           *lru.ARCCache // committers recovered from recent block headers
+	parentSealers     *lru.ARCCache // parent committers recovered from recent block headers
+	participationHead uint64        // last block accounted in the participation metrics
+	participationMu   sync.Mutex
+
+	versions   map[common.Address]*PeerVersion // attested build information of connected and past peers
+	versionsMu sync.RWMutex
+
+	announcePeers  map[common.Address]consensus.Peer // peers accepting finalized head announcements
+	announceTarget uint64                            // highest announced block a sync was requested for
+	announceQuit   chan struct{}                     // terminates the periodic announcements, nil if not running
+	announceMu     sync.Mutex
+
+	upgradeEpochs  map[uint64]map[[4]byte]bool // upgrades reaching the signaling threshold in complete epochs
+	upgradesActive map[string]bool             // upgrades whose activation was already announced
+	upgradesMu     sync.Mutex
+
+	scores   map[common.Address]int // scores of the validators caught misbehaving
+	scoresMu sync.Mutex
+
+	thresholdShare *thresholdShare // share of the threshold group key last signed with
+	thresholdMu    sync.Mutex
 }
 
 // Address implements istanbul.Backend.Address
@@ -111,9 +176,9 @@ func (sb *backend) Validators(proposal istanbul.Proposal) istanbul.ValidatorSet
 }
 
 // Broadcast implements istanbul.Backend.Broadcast
-func (sb *backend) Broadcast(valSet istanbul.ValidatorSet, payload []byte) error {
+func (sb *backend) Broadcast(valSet istanbul.ValidatorSet, payload []byte, done istanbul.DeliveryCallback) error {
 	// send to others
-	sb.Gossip(valSet, payload)
+	sb.Gossip(valSet, payload, done)
 	// send to self
 	msg := istanbul.MessageEvent{
 		Payload: payload,
@@ -123,7 +188,7 @@ func (sb *backend) Broadcast(valSet istanbul.ValidatorSet, payload []byte) error
 }
 
 // Broadcast implements istanbul.Backend.Gossip
-func (sb *backend) Gossip(valSet istanbul.ValidatorSet, payload []byte) error {
+func (sb *backend) Gossip(valSet istanbul.ValidatorSet, payload []byte, done istanbul.DeliveryCallback) error {
 	hash := istanbul.RLPHash(payload)
 	sb.knownMessages.Add(hash, true)
 
@@ -134,30 +199,76 @@ func (sb *backend) Gossip(valSet istanbul.ValidatorSet, payload []byte) error {
 		}
 	}
 
+	var ps map[common.Address]consensus.Peer
 	if sb.broadcaster != nil && len(targets) > 0 {
-		ps := sb.broadcaster.FindPeers(targets)
-		for addr, p := range ps {
-			ms, ok := sb.recentMessages.Get(addr)
-			var m *lru.ARCCache
-			if ok {
-				m, _ = ms.(*lru.ARCCache)
-				if _, k := m.Get(hash); k {
-					// This peer had this event, skip it
-					continue
-				}
-			} else {
-				m, _ = lru.NewARC(inmemoryMessages)
+		ps = sb.broadcaster.FindPeers(targets)
+	}
+	// Validators without a connection can't be delivered to at all
+	var undelivered []common.Address
+	for addr := range targets {
+		if _, ok := ps[addr]; !ok {
+			undelivered = append(undelivered, addr)
+		}
+	}
+	sends := make(map[common.Address]chan error)
+	for addr, p := range ps {
+		ms, ok := sb.recentMessages.Get(addr)
+		var m *lru.ARCCache
+		if ok {
+			m, _ = ms.(*lru.ARCCache)
+			if _, k := m.Get(hash); k {
+				// This peer had this event, skip it
+				continue
 			}
+		} else {
+			m, _ = lru.NewARC(inmemoryMessages)
+		}
 
-			m.Add(hash, true)
-			sb.recentMessages.Add(addr, m)
+		m.Add(hash, true)
+		sb.recentMessages.Add(addr, m)
 
-			go p.Send(istanbulMsg, payload)
-		}
+		errc := make(chan error, 1)
+		sends[addr] = errc
+		go func(p consensus.Peer) {
+			errc <- p.Send(istanbulMsg, payload)
+		}(p)
+	}
+	if done != nil {
+		go sb.awaitDelivery(sends, undelivered, done)
 	}
 	return nil
 }
 
+// awaitDelivery waits for the sends of a message to the peers to complete,
+// reporting the validators it could not be handed to within half a round.
+func (sb *backend) awaitDelivery(sends map[common.Address]chan error, undelivered []common.Address, done istanbul.DeliveryCallback) {
+	timeout := time.NewTimer(time.Duration(sb.config.RequestTimeout) * time.Millisecond / 2)
+	defer timeout.Stop()
+
+	expired := false
+	for addr, errc := range sends {
+		var err error
+		if expired {
+			select {
+			case err = <-errc:
+			default:
+				err = errDeliveryTimeout
+			}
+		} else {
+			select {
+			case err = <-errc:
+			case <-timeout.C:
+				expired, err = true, errDeliveryTimeout
+			}
+		}
+		if err != nil {
+			sb.logger.Debug("Failed to deliver consensus message", "peer", addr, "err", err)
+			undelivered = append(undelivered, addr)
+		}
+	}
+	done(undelivered)
+}
+
 // Commit implements istanbul.Backend.Commit
 func (sb *backend) Commit(proposal istanbul.Proposal, seals [][]byte) error {
 	// Check if the proposal is a valid block
@@ -170,7 +281,7 @@ func (sb *backend) Commit(proposal istanbul.Proposal, seals [][]byte) error {
 
 	h := block.Header()
 	// Append seals into extra-data
-	err := writeCommittedSeals(h, seals)
+	err := sb.writeSeals(h, seals)
 	if err != nil {
 		return err
 	}
@@ -210,7 +321,44 @@ func (sb *backend) Verify(proposal istanbul.Proposal) (time.Duration, error) {
 		sb.logger.Error("Invalid proposal, %v", proposal)
 		return 0, errInvalidProposal
 	}
+	delay, err := sb.verify(block)
 
+	// Warm the state caches for executing the proposal while the validators are
+	// still agreeing on it
+	if err == nil {
+		if prefetcher, ok := sb.chain.(blockPrefetcher); ok {
+			prefetcher.PrefetchBlock(block)
+		}
+	}
+	// Vetoed proposals are valid blocks, only rejected by the application
+	if _, vetoed := err.(*istanbul.VetoError); vetoed {
+		return delay, err
+	}
+	switch err {
+	case nil, consensus.ErrFutureBlock, consensus.ErrUnknownAncestor, core.ErrBlacklistedHash:
+	default:
+		// Report asynchronously, the core is blocked until we return
+		if reporter, ok := sb.chain.(badBlockReporter); ok {
+			go reporter.ReportBadBlock(block, err)
+		}
+	}
+	return delay, err
+}
+
+// badBlockReporter is implemented by chains persisting forensic reports of bad
+// blocks.
+type badBlockReporter interface {
+	ReportBadBlock(block *types.Block, err error)
+}
+
+// blockPrefetcher is implemented by chains able to warm their caches for the
+// execution of a block before importing it.
+type blockPrefetcher interface {
+	PrefetchBlock(block *types.Block)
+}
+
+// verify checks the validity of a proposed block without executing it.
+func (sb *backend) verify(block *types.Block) (time.Duration, error) {
 	// check bad block
 	if sb.HasBadProposal(block.Hash()) {
 		return 0, core.ErrBlacklistedHash
@@ -230,19 +378,59 @@ func (sb *backend) Verify(proposal istanbul.Proposal) (time.Duration, error) {
 	err := sb.VerifyHeader(sb.chain, block.Header(), false)
 	// ignore errEmptyCommittedSeals error because we don't have the committed seals yet
 	if err == nil || err == errEmptyCommittedSeals {
-		return 0, nil
+		return 0, sb.validateProposal(block)
 	} else if err == consensus.ErrFutureBlock {
 		return time.Unix(block.Header().Time.Int64(), 0).Sub(now()), consensus.ErrFutureBlock
 	}
 	return 0, err
 }
 
+// SetProposalValidator implements consensus.Istanbul.SetProposalValidator
+func (sb *backend) SetProposalValidator(validator consensus.ProposalValidator) {
+	sb.proposalValidatorMu.Lock()
+	defer sb.proposalValidatorMu.Unlock()
+
+	sb.proposalValidator = validator
+}
+
+// validateProposal runs the application level validation of a proposal, if any
+// was set, converting rejections into vetoes.
+func (sb *backend) validateProposal(block *types.Block) error {
+	sb.proposalValidatorMu.RLock()
+	validator := sb.proposalValidator
+	sb.proposalValidatorMu.RUnlock()
+
+	if validator == nil {
+		return nil
+	}
+	if err := validator.ValidateProposal(sb.chain, block); err != nil {
+		return istanbul.NewVetoError(err.Error())
+	}
+	return nil
+}
+
 // Sign implements istanbul.Backend.Sign
 func (sb *backend) Sign(data []byte) ([]byte, error) {
 	hashData := crypto.Keccak256(data)
+
+	sb.signMu.RLock()
+	signFn := sb.signFn
+	sb.signMu.RUnlock()
+
+	if signFn != nil {
+		return signFn(hashData)
+	}
 	return crypto.Sign(hashData, sb.privateKey)
 }
 
+// Authorize implements consensus.Istanbul.Authorize
+func (sb *backend) Authorize(signFn func(hash []byte) ([]byte, error)) {
+	sb.signMu.Lock()
+	defer sb.signMu.Unlock()
+
+	sb.signFn = signFn
+}
+
 // CheckSignature implements istanbul.Backend.CheckSignature
 func (sb *backend) CheckSignature(data []byte, address common.Address, sig []byte) error {
 	signer, err := istanbul.GetSignatureAddress(data, sig)
@@ -19,12 +19,15 @@ package backend
 import (
 	"bytes"
 	"crypto/ecdsa"
+	"errors"
+	"reflect"
 	"sort"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus"
 	"github.com/ethereum/go-ethereum/consensus/istanbul"
 	"github.com/ethereum/go-ethereum/consensus/istanbul/validator"
 	"github.com/ethereum/go-ethereum/core/types"
@@ -48,6 +51,34 @@ func TestSign(t *testing.T) {
 	}
 }
 
+// Tests that consensus messages are signed by the delegated signer once one is
+// authorized.
+func TestSignAuthorized(t *testing.T) {
+	b := newBackend()
+	key, _ := generatePrivateKey()
+
+	var calls int
+	b.Authorize(func(hash []byte) ([]byte, error) {
+		calls++
+		return crypto.Sign(hash, key)
+	})
+	data := []byte("Here is a string....")
+	sig, err := b.Sign(data)
+	if err != nil {
+		t.Fatalf("failed to sign: %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("delegated signer calls mismatch: have %d, want 1", calls)
+	}
+	pubkey, err := crypto.SigToPub(crypto.Keccak256(data), sig)
+	if err != nil {
+		t.Fatalf("failed to recover signer: %v", err)
+	}
+	if signer := crypto.PubkeyToAddress(*pubkey); signer != crypto.PubkeyToAddress(key.PublicKey) {
+		t.Errorf("signer mismatch: have %x, want %x", signer, crypto.PubkeyToAddress(key.PublicKey))
+	}
+}
+
 func TestCheckSignature(t *testing.T) {
 	key, _ := generatePrivateKey()
 	data := []byte("Here is a string....")
@@ -173,6 +204,125 @@ func TestCommit(t *testing.T) {
 	}
 }
 
+// vetoValidator is a proposal validator rejecting every proposal.
+type vetoValidator struct{ err error }
+
+func (v *vetoValidator) ValidateProposal(chain consensus.ChainReader, block *types.Block) error {
+	return v.err
+}
+
+func TestProposalValidator(t *testing.T) {
+	chain, engine := newBlockChain(1)
+	block := makeBlockWithoutSeal(chain, engine, chain.Genesis())
+	block, _ = engine.updateBlock(chain.Genesis().Header(), block)
+
+	if _, err := engine.Verify(block); err != nil {
+		t.Fatalf("error mismatch: have %v, want nil", err)
+	}
+	// Vetoes must be reported with their reason
+	engine.SetProposalValidator(&vetoValidator{errors.New("contract deploys forbidden")})
+	_, err := engine.Verify(block)
+	if veto, ok := err.(*istanbul.VetoError); !ok || veto.Reason != "contract deploys forbidden" {
+		t.Fatalf("error mismatch: have %v, want veto", err)
+	}
+	// Overly long reasons must be truncated
+	engine.SetProposalValidator(&vetoValidator{errors.New(strings.Repeat("x", 1024))})
+	if _, err := engine.Verify(block); len(err.(*istanbul.VetoError).Reason) != 256 {
+		t.Errorf("veto reason length mismatch: have %d, want 256", len(err.(*istanbul.VetoError).Reason))
+	}
+	engine.SetProposalValidator(nil)
+	if _, err := engine.Verify(block); err != nil {
+		t.Errorf("error mismatch: have %v, want nil", err)
+	}
+}
+
+// testPeer is a consensus peer whose sends fail with the given error, or block
+// until the peer is closed if it has a quit channel.
+type testPeer struct {
+	err  error
+	quit chan struct{}
+}
+
+func (p *testPeer) Send(msgcode uint64, data interface{}) error {
+	if p.quit != nil {
+		<-p.quit
+	}
+	return p.err
+}
+
+// testBroadcaster is a broadcaster connected to a fixed set of peers, reporting
+// the announced heads it was asked to sync to and holding the syncs until done
+// is signalled.
+type testBroadcaster struct {
+	peers map[common.Address]consensus.Peer
+	syncs chan uint64
+	done  chan struct{}
+}
+
+func (b *testBroadcaster) Enqueue(id string, block *types.Block) {}
+
+func (b *testBroadcaster) SyncFrom(addr common.Address, head common.Hash, number uint64) {
+	b.syncs <- number
+	<-b.done
+}
+
+func (b *testBroadcaster) FindPeers(targets map[common.Address]bool) map[common.Address]consensus.Peer {
+	peers := make(map[common.Address]consensus.Peer)
+	for addr, p := range b.peers {
+		if targets[addr] {
+			peers[addr] = p
+		}
+	}
+	return peers
+}
+
+// Tests that gossiping reports the validators that are not connected, whose
+// sends fail and whose sends don't complete within half a round as undelivered.
+func TestGossipDelivery(t *testing.T) {
+	_, engine := newBlockChain(5)
+
+	config := *engine.config
+	config.RequestTimeout = 100
+	engine.config = &config
+
+	var others []common.Address
+	valSet := engine.Validators(engine.currentBlock())
+	for _, val := range valSet.List() {
+		if val.Address() != engine.Address() {
+			others = append(others, val.Address())
+		}
+	}
+	quit := make(chan struct{})
+	defer close(quit)
+
+	engine.broadcaster = &testBroadcaster{peers: map[common.Address]consensus.Peer{
+		others[0]: &testPeer{},
+		others[1]: &testPeer{err: errors.New("disconnected")},
+		others[2]: &testPeer{quit: quit},
+	}}
+	result := make(chan []common.Address, 1)
+	engine.Gossip(valSet, []byte("payload"), func(undelivered []common.Address) {
+		result <- undelivered
+	})
+	byBytes := func(addrs []common.Address) {
+		sort.Slice(addrs, func(i, j int) bool {
+			return bytes.Compare(addrs[i][:], addrs[j][:]) < 0
+		})
+	}
+	want := append([]common.Address{}, others[1:]...)
+	byBytes(want)
+
+	select {
+	case undelivered := <-result:
+		byBytes(undelivered)
+		if !reflect.DeepEqual(undelivered, want) {
+			t.Errorf("undelivered validators mismatch: have %v, want %v", undelivered, want)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("delivery not reported")
+	}
+}
+
 func TestGetProposer(t *testing.T) {
 	chain, engine := newBlockChain(1)
 	block := makeBlock(chain, engine, chain.Genesis())
@@ -0,0 +1,200 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Contains the clock drift detection guarding against istanbul timestamp
+// validation failures, measured via SNTP (https://tools.ietf.org/html/rfc4330)
+// and the version attestations other validators sign when connecting.
+
+package backend
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/consensus/istanbul"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/p2p/netutil"
+)
+
+const (
+	clockCheckInterval = 10 * time.Minute // Interval between two NTP measurements
+	clockAlertInterval = time.Minute      // Minimum time between two drift alerts
+	ntpChecks          = 3                // Number of measurements to do against an NTP server
+	peerClockSamples   = 16               // Number of recent attestations to derive the peer drift from
+)
+
+var (
+	// errClockDrift is returned when refusing to propose a block because the local
+	// clock runs ahead of the network.
+	errClockDrift = errors.New("local clock drift too large")
+
+	clockDriftGauge = metrics.NewRegisteredGauge("consensus/istanbul/clock/drift", nil)
+	clockAlertMeter = metrics.NewRegisteredMeter("consensus/istanbul/clock/alerts", nil)
+)
+
+// clockGuard tracks the drift of the local clock against NTP servers and other
+// validators. A positive drift means the local clock runs ahead.
+type clockGuard struct {
+	servers   []string      // NTP servers to measure the drift against
+	threshold time.Duration // Drift above which to alert (0 = disabled)
+	refuse    bool          // Whether to refuse proposing while running ahead
+
+	ntpDrift   time.Duration   // Last drift measured against the NTP servers
+	ntpValid   bool            // Whether any NTP measurement succeeded
+	peerDrifts []time.Duration // Recent drifts measured against attestations
+	lastAlert  time.Time       // Time of the last drift alert
+	lock       sync.RWMutex
+
+	quit chan struct{}
+}
+
+// newClockGuard creates a clock drift guard from the istanbul configuration.
+func newClockGuard(config *istanbul.Config) *clockGuard {
+	return &clockGuard{
+		servers:   config.NTPServers,
+		threshold: time.Duration(config.MaxClockDrift) * time.Millisecond,
+		refuse:    config.RefuseOnDrift,
+	}
+}
+
+// start launches the periodic NTP measurements, if any servers are configured.
+func (g *clockGuard) start() {
+	if g.threshold == 0 || len(g.servers) == 0 || g.quit != nil {
+		return
+	}
+	g.quit = make(chan struct{})
+	go g.loop(g.quit)
+}
+
+// stop terminates the periodic NTP measurements.
+func (g *clockGuard) stop() {
+	if g.quit != nil {
+		close(g.quit)
+		g.quit = nil
+	}
+}
+
+func (g *clockGuard) loop(quit chan struct{}) {
+	ticker := time.NewTicker(clockCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		g.measure()
+
+		select {
+		case <-ticker.C:
+		case <-quit:
+			return
+		}
+	}
+}
+
+// measure queries the NTP servers in order, recording the drift reported by the
+// first one responding.
+func (g *clockGuard) measure() {
+	for _, server := range g.servers {
+		drift, err := netutil.SNTPDrift(server, ntpChecks)
+		if err != nil {
+			log.Debug("Failed to measure clock drift", "server", server, "err", err)
+			continue
+		}
+		g.lock.Lock()
+		g.ntpDrift, g.ntpValid = drift, true
+		g.lock.Unlock()
+
+		g.check()
+		return
+	}
+}
+
+// addPeerSample records the drift measured against the attestation of another
+// validator, timestamped when it was signed.
+func (g *clockGuard) addPeerSample(proposed time.Time) {
+	if g.threshold == 0 {
+		return
+	}
+	g.lock.Lock()
+	g.peerDrifts = append(g.peerDrifts, now().Sub(proposed))
+	if len(g.peerDrifts) > peerClockSamples {
+		g.peerDrifts = g.peerDrifts[1:]
+	}
+	g.lock.Unlock()
+
+	g.check()
+}
+
+// drift returns the current best estimate of the local clock drift, preferring
+// NTP measurements and falling back to the median of the peer samples.
+func (g *clockGuard) drift() (time.Duration, bool) {
+	g.lock.RLock()
+	defer g.lock.RUnlock()
+
+	if g.ntpValid {
+		return g.ntpDrift, true
+	}
+	if len(g.peerDrifts) == 0 {
+		return 0, false
+	}
+	drifts := make([]time.Duration, len(g.peerDrifts))
+	copy(drifts, g.peerDrifts)
+	sort.Slice(drifts, func(i, j int) bool { return drifts[i] < drifts[j] })
+
+	return drifts[len(drifts)/2], true
+}
+
+// check alerts the operator if the local clock drifted too far.
+func (g *clockGuard) check() {
+	drift, ok := g.drift()
+	if !ok {
+		return
+	}
+	clockDriftGauge.Update(int64(drift / time.Millisecond))
+	if drift >= -g.threshold && drift <= g.threshold {
+		return
+	}
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	if time.Since(g.lastAlert) < clockAlertInterval {
+		return
+	}
+	g.lastAlert = time.Now()
+	clockAlertMeter.Mark(1)
+
+	log.Warn("System clock seems off, istanbul timestamps may be rejected", "drift", drift, "threshold", g.threshold)
+	log.Warn("Please enable network time synchronisation in system settings.")
+}
+
+// checkPropose returns an error if proposing a block should be refused, as the
+// local clock runs ahead far enough for the block to be deemed a future one.
+func (g *clockGuard) checkPropose() error {
+	if !g.refuse || g.threshold == 0 {
+		return nil
+	}
+	if drift, ok := g.drift(); ok && drift > g.threshold {
+		return errClockDrift
+	}
+	return nil
+}
+
+// MeasureClockDrift measures the drift of the local clock against an NTP server,
+// a positive drift meaning the local clock runs ahead.
+func MeasureClockDrift(server string) (time.Duration, error) {
+	return netutil.SNTPDrift(server, ntpChecks)
+}
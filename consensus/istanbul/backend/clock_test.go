@@ -0,0 +1,96 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package backend
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/consensus/istanbul"
+)
+
+// Tests that the clock drift is derived from the median of the peer samples
+// and that proposing is refused only while running ahead of the network.
+func TestClockGuardPeerDrift(t *testing.T) {
+	config := *istanbul.DefaultConfig
+	config.MaxClockDrift = 2000
+	config.RefuseOnDrift = true
+
+	guard := newClockGuard(&config)
+	if _, ok := guard.drift(); ok {
+		t.Fatalf("drift reported without samples")
+	}
+	if err := guard.checkPropose(); err != nil {
+		t.Fatalf("proposing refused without samples: %v", err)
+	}
+	// Feed a few samples with a single outlier, the local clock running ahead
+	base := now()
+	for _, ahead := range []time.Duration{5 * time.Second, 5 * time.Second, 5 * time.Second, -time.Hour} {
+		guard.addPeerSample(base.Add(-ahead))
+	}
+	drift, ok := guard.drift()
+	if !ok || drift < 4*time.Second || drift > 6*time.Second {
+		t.Fatalf("drift mismatch: have %v (%v), want ~5s", drift, ok)
+	}
+	if err := guard.checkPropose(); err != errClockDrift {
+		t.Fatalf("proposing error mismatch: have %v, want %v", err, errClockDrift)
+	}
+	// Local clock running behind should not prevent proposing
+	guard = newClockGuard(&config)
+	for i := 0; i < peerClockSamples; i++ {
+		guard.addPeerSample(base.Add(time.Minute))
+	}
+	if err := guard.checkPropose(); err != nil {
+		t.Fatalf("proposing refused while running behind: %v", err)
+	}
+	// Alert-only mode should never refuse proposing
+	config.RefuseOnDrift = false
+	guard = newClockGuard(&config)
+	guard.addPeerSample(base.Add(-time.Minute))
+	if err := guard.checkPropose(); err != nil {
+		t.Fatalf("proposing refused in alert-only mode: %v", err)
+	}
+}
+
+// Tests that the attestations of validators are sampled for the clock drift, but
+// not the ones of other peers.
+func TestClockGuardAttestations(t *testing.T) {
+	_, local := newBlockChain(1)
+	_, remote := newBlockChain(1)
+
+	attest := func(signer *backend, ahead time.Duration) {
+		att := &VersionAttestation{Features: []string{}, Time: uint64(now().Add(-ahead).Unix())}
+		data, err := att.sigData()
+		if err != nil {
+			t.Fatalf("failed to encode attestation: %v", err)
+		}
+		if att.Signature, err = signer.Sign(data); err != nil {
+			t.Fatalf("failed to sign attestation: %v", err)
+		}
+		if _, err := local.HandleMsg(signer.Address(), makeMsg(istanbulVersionMsg, att)); err != nil {
+			t.Fatalf("failed to handle attestation: %v", err)
+		}
+	}
+	attest(remote, time.Hour)
+	if drift, ok := local.clock.drift(); ok {
+		t.Fatalf("non-validator sampled: drift %v", drift)
+	}
+	attest(local, 5*time.Second)
+	if drift, ok := local.clock.drift(); !ok || drift < 4*time.Second || drift > 6*time.Second {
+		t.Fatalf("drift mismatch: have %v (%v), want ~5s", drift, ok)
+	}
+}
@@ -0,0 +1,36 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package backend
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/consensus/consensustest"
+)
+
+// Tests that the Istanbul engine passes the consensus engine conformance suite.
+func TestConformance(t *testing.T) {
+	consensustest.Run(t, func(t *testing.T) *consensustest.Harness {
+		chain, engine := newBlockChain(1)
+		return &consensustest.Harness{
+			Engine:     engine,
+			Chain:      chain,
+			Propose:    (&API{chain: chain, istanbul: engine}).Propose,
+			Validators: engine.GetValidatorsAt,
+		}
+	})
+}
@@ -0,0 +1,248 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package backend
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"errors"
+	"math"
+	"reflect"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/consensus/istanbul"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/crypto/bls"
+	"github.com/ethereum/go-ethereum/crypto/ecies"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+var (
+	// errInvalidThreshold is returned if a key generation is requested with a
+	// threshold not between one and the number of members.
+	errInvalidThreshold = errors.New("invalid threshold")
+	// errInvalidMemberKey is returned if a member of a key generation is not
+	// identified by a valid node public key.
+	errInvalidMemberKey = errors.New("invalid member public key")
+	// errNotMember is returned if the local validator is not a member of the key
+	// generation it takes part in.
+	errNotMember = errors.New("not a member of the key generation")
+	// errInvalidDealing is returned if a dealing is malformed, not signed by its
+	// dealer or from a different key generation than the others.
+	errInvalidDealing = errors.New("invalid key dealing")
+	// errDuplicateDealing is returned if a member dealt more than once.
+	errDuplicateDealing = errors.New("duplicate key dealing")
+	// errTooFewDealings is returned if fewer dealings than the threshold are
+	// left to combine after the invalid ones have been disqualified.
+	errTooFewDealings = errors.New("not enough valid key dealings")
+)
+
+// KeyDealing is a validator's contribution to a threshold key generation: the
+// commitments to its secret polynomial and the shares of it, each encrypted to
+// the node key of the member it is destined to.
+type KeyDealing struct {
+	Dealer      common.Address   `json:"dealer"`
+	Threshold   uint64           `json:"threshold"`
+	Members     []common.Address `json:"members"`     // Members in share index order
+	Commitments []hexutil.Bytes  `json:"commitments"` // Commitments to the coefficients of the polynomial
+	Shares      []hexutil.Bytes  `json:"shares"`      // Encrypted shares in member order
+	Signature   hexutil.Bytes    `json:"signature"`   // Signature of the dealer over all the above
+}
+
+// signingData returns the data the dealer signs the dealing over.
+func (d *KeyDealing) signingData() ([]byte, error) {
+	return rlp.EncodeToBytes([]interface{}{d.Dealer, d.Threshold, d.Members, d.Commitments, d.Shares})
+}
+
+// KeyGeneration is the outcome of a threshold key generation, the group to
+// configure threshold seals with. Members disqualifying different dealers end
+// up with different group keys, the ceremony needing to be repeated with the
+// union of the disqualified dealers excluded.
+type KeyGeneration struct {
+	Threshold    uint64           `json:"threshold"`
+	Members      []common.Address `json:"members"`
+	GroupKey     hexutil.Bytes    `json:"groupKey"`
+	PublicShares []hexutil.Bytes  `json:"publicShares"`
+	Qualified    []common.Address `json:"qualified"`    // Dealers whose dealings were combined
+	Disqualified []common.Address `json:"disqualified"` // Dealers whose share for the local validator was invalid
+}
+
+// dealKeyShares deals a fresh secret polynomial to the members identified by
+// their node public keys, any threshold of which are to sign for the group.
+func (sb *backend) dealKeyShares(threshold uint64, members []hexutil.Bytes) (*KeyDealing, error) {
+	if threshold == 0 || threshold > uint64(len(members)) || len(members) > math.MaxUint16 {
+		return nil, errInvalidThreshold
+	}
+	keys := make([]*ecdsa.PublicKey, len(members))
+	dealing := &KeyDealing{
+		Dealer:    sb.address,
+		Threshold: threshold,
+		Members:   make([]common.Address, len(members)),
+		Shares:    make([]hexutil.Bytes, len(members)),
+	}
+	for i, member := range members {
+		// Accept node IDs as well, which are public keys without the format prefix
+		if len(member) == 64 {
+			member = append([]byte{0x04}, member...)
+		}
+		key := crypto.ToECDSAPub(member)
+		if key == nil || key.X == nil {
+			return nil, errInvalidMemberKey
+		}
+		keys[i], dealing.Members[i] = key, crypto.PubkeyToAddress(*key)
+	}
+	if indexOf(dealing.Members, sb.address) < 0 {
+		return nil, errNotMember
+	}
+	poly, err := bls.NewDealing(rand.Reader, int(threshold), len(members))
+	if err != nil {
+		return nil, err
+	}
+	for _, commitment := range poly.Commitments {
+		dealing.Commitments = append(dealing.Commitments, commitment.Marshal())
+	}
+	for i, share := range poly.Shares {
+		if dealing.Shares[i], err = ecies.Encrypt(rand.Reader, ecies.ImportECDSAPublic(keys[i]), share.Marshal(), nil, nil); err != nil {
+			return nil, err
+		}
+	}
+	data, err := dealing.signingData()
+	if err != nil {
+		return nil, err
+	}
+	if dealing.Signature, err = sb.Sign(data); err != nil {
+		return nil, err
+	}
+	return dealing, nil
+}
+
+// combineKeyShares verifies the dealings of a key generation, combines the shares
+// dealt to the local validator into its share of the group key and stores it.
+// Dealers whose share doesn't match their commitments are disqualified, as are
+// the explicitly excluded ones.
+func (sb *backend) combineKeyShares(dealings []*KeyDealing, exclude []common.Address) (*KeyGeneration, error) {
+	if len(dealings) == 0 {
+		return nil, errTooFewDealings
+	}
+	var (
+		threshold = dealings[0].Threshold
+		members   = dealings[0].Members
+		index     = indexOf(members, sb.address)
+	)
+	if index < 0 {
+		return nil, errNotMember
+	}
+	result := &KeyGeneration{
+		Threshold:    threshold,
+		Members:      members,
+		Qualified:    []common.Address{},
+		Disqualified: []common.Address{},
+	}
+	var (
+		dealt       = make(map[common.Address]bool)
+		commitments [][]*bls.PublicKey
+		shares      []*bls.SecretKey
+	)
+	for _, dealing := range dealings {
+		// Reject the whole lot if any dealing is malformed or forged
+		if dealing.Threshold != threshold || !reflect.DeepEqual(dealing.Members, members) {
+			return nil, errInvalidDealing
+		}
+		if uint64(len(dealing.Commitments)) != threshold || len(dealing.Shares) != len(members) || indexOf(members, dealing.Dealer) < 0 {
+			return nil, errInvalidDealing
+		}
+		data, err := dealing.signingData()
+		if err != nil {
+			return nil, err
+		}
+		if signer, err := istanbul.GetSignatureAddress(data, dealing.Signature); err != nil || signer != dealing.Dealer {
+			return nil, errInvalidDealing
+		}
+		if dealt[dealing.Dealer] {
+			return nil, errDuplicateDealing
+		}
+		dealt[dealing.Dealer] = true
+
+		if indexOf(exclude, dealing.Dealer) >= 0 {
+			continue
+		}
+		// Disqualify the dealer if the share dealt to us is not the committed one
+		committed, share, ok := sb.openKeyDealing(dealing, uint64(index+1))
+		if !ok {
+			sb.logger.Warn("Disqualified key dealer", "dealer", dealing.Dealer)
+			result.Disqualified = append(result.Disqualified, dealing.Dealer)
+			continue
+		}
+		commitments = append(commitments, committed)
+		shares = append(shares, share)
+		result.Qualified = append(result.Qualified, dealing.Dealer)
+	}
+	if uint64(len(shares)) < threshold {
+		return nil, errTooFewDealings
+	}
+	group, err := bls.CombineCommitments(commitments)
+	if err != nil {
+		return nil, err
+	}
+	result.GroupKey = group[0].Marshal()
+	for i := range members {
+		result.PublicShares = append(result.PublicShares, bls.PublicShare(group, uint64(i+1)).Marshal())
+	}
+	share := &thresholdShare{
+		GroupKey: result.GroupKey,
+		Index:    uint64(index + 1),
+		Share:    bls.CombineShares(shares).Marshal(),
+	}
+	if err := sb.storeThresholdShare(share); err != nil {
+		return nil, err
+	}
+	sb.logger.Info("Generated threshold key share", "group", hexutil.Bytes(result.GroupKey), "index", share.Index, "qualified", len(result.Qualified))
+	return result, nil
+}
+
+// openKeyDealing decrypts the share dealt to the member with the given index and
+// checks it against the commitments of the dealing.
+func (sb *backend) openKeyDealing(dealing *KeyDealing, index uint64) ([]*bls.PublicKey, *bls.SecretKey, bool) {
+	commitments := make([]*bls.PublicKey, len(dealing.Commitments))
+	for i, blob := range dealing.Commitments {
+		commitment, err := bls.UnmarshalPublicKey(blob)
+		if err != nil {
+			return nil, nil, false
+		}
+		commitments[i] = commitment
+	}
+	blob, err := ecies.ImportECDSA(sb.privateKey).Decrypt(rand.Reader, dealing.Shares[index-1], nil, nil)
+	if err != nil {
+		return nil, nil, false
+	}
+	share, err := bls.UnmarshalSecretKey(blob)
+	if err != nil || !bls.VerifyShare(commitments, index, share) {
+		return nil, nil, false
+	}
+	return commitments, share, true
+}
+
+// indexOf returns the position of the address in the list, or -1 if missing.
+func indexOf(addresses []common.Address, address common.Address) int {
+	for i, addr := range addresses {
+		if addr == address {
+			return i
+		}
+	}
+	return -1
+}
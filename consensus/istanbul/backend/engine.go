@@ -80,6 +80,12 @@ var (
 	errInvalidCommittedSeals = errors.New("invalid committed seals")
 	// errEmptyCommittedSeals is returned if the field of committed seals is zero.
 	errEmptyCommittedSeals = errors.New("zero committed seals")
+	// errInvalidParentCommittedSeals is returned if the parent committed seals
+	// carried by a block don't commit its parent.
+	errInvalidParentCommittedSeals = errors.New("invalid parent committed seals")
+	// errEmptyParentCommittedSeals is returned if a block omits the parent
+	// committed seals although the reward policy pays the parent's committers.
+	errEmptyParentCommittedSeals = errors.New("zero parent committed seals")
 	// errMismatchTxhashes is returned if the TxHash in header is mismatch.
 	errMismatchTxhashes = errors.New("mismatch transactions hashes")
 )
@@ -169,7 +175,11 @@ func (sb *backend) verifyCascadingFields(chain consensus.ChainReader, header *ty
 	if parent == nil || parent.Number.Uint64() != number-1 || parent.Hash() != header.ParentHash {
 		return consensus.ErrUnknownAncestor
 	}
-	if parent.Time.Uint64()+sb.config.BlockPeriod > header.Time.Uint64() {
+	if period := fixedPeriod(chain); period > 0 {
+		if parent.Time.Uint64()+period != header.Time.Uint64() {
+			return errInvalidTimestamp
+		}
+	} else if parent.Time.Uint64()+sb.config.BlockPeriod > header.Time.Uint64() {
 		return errInvalidTimestamp
 	}
 	// Verify validators in extraData. Validators in snapshot and extraData should be the same.
@@ -184,7 +194,9 @@ func (sb *backend) verifyCascadingFields(chain consensus.ChainReader, header *ty
 	if err := sb.verifySigner(chain, header, parents); err != nil {
 		return err
 	}
-
+	if err := sb.verifyParentCommittedSeals(chain, header, parent, parents); err != nil {
+		return err
+	}
 	return sb.verifyCommittedSeals(chain, header, parents)
 }
 
@@ -267,13 +279,66 @@ func (sb *backend) verifyCommittedSeals(chain consensus.ChainReader, header *typ
 	if len(extra.CommittedSeal) == 0 {
 		return errEmptyCommittedSeals
 	}
+	// Threshold seals are a single signature of the validator group
+	if config := thresholdConfig(chain, header.Number); config != nil {
+		return VerifyThresholdSeal(config, header.Hash(), extra.CommittedSeal)
+	}
+
+	return sb.verifySealQuorum(snap, header.Hash(), extra.CommittedSeal)
+}
+
+// verifyParentCommittedSeals checks the parent committed seals carried by the
+// header against the validators of the parent block. They are mandatory if the
+// reward policy pays the parent's committers, as the proposer could otherwise
+// keep their share by leaving them out.
+func (sb *backend) verifyParentCommittedSeals(chain consensus.ChainReader, header *types.Header, parent *types.Header, parents []*types.Header) error {
+	extra, err := types.ExtractIstanbulExtra(header)
+	if err != nil {
+		return err
+	}
+	// The genesis block has no committed seals to carry over
+	number := parent.Number.Uint64()
+	if number == 0 {
+		if len(extra.ParentCommittedSeal) > 0 {
+			return errInvalidParentCommittedSeals
+		}
+		return nil
+	}
+	if len(extra.ParentCommittedSeal) == 0 {
+		if config := chain.Config().Istanbul; config != nil && config.Reward != nil {
+			return errEmptyParentCommittedSeals
+		}
+		return nil
+	}
+	if config := thresholdConfig(chain, parent.Number); config != nil {
+		if err := VerifyThresholdSeal(config, parent.Hash(), extra.ParentCommittedSeal); err != nil {
+			return errInvalidParentCommittedSeals
+		}
+		return nil
+	}
+	if len(parents) > 0 {
+		parents = parents[:len(parents)-1]
+	}
+	snap, err := sb.snapshot(chain, number-1, parent.ParentHash, parents)
+	if err != nil {
+		return err
+	}
+	if err := sb.verifySealQuorum(snap, parent.Hash(), extra.ParentCommittedSeal); err != nil {
+		return errInvalidParentCommittedSeals
+	}
+	return nil
+}
 
+// verifySealQuorum checks whether the committed seals of the block with the given
+// hash are signed by more than two thirds of the validators of the snapshot,
+// each of them sealing at most once.
+func (sb *backend) verifySealQuorum(snap *Snapshot, hash common.Hash, seals [][]byte) error {
 	validators := snap.ValSet.Copy()
 	// Check whether the committed seals are generated by parent's validators
 	validSeal := 0
-	proposalSeal := istanbulCore.PrepareCommittedSeal(header.Hash())
+	proposalSeal := istanbulCore.PrepareCommittedSeal(hash)
 	// 1. Get committed seals from current header
-	for _, seal := range extra.CommittedSeal {
+	for _, seal := range seals {
 		// 2. Get the original address by seal and parent block hash
 		addr, err := istanbul.GetSignatureAddress(proposalSeal, seal)
 		if err != nil {
@@ -297,6 +362,92 @@ func (sb *backend) verifyCommittedSeals(chain consensus.ChainReader, header *typ
 	return nil
 }
 
+// ForensicDump implements consensus.ForensicReporter, returning the local
+// consensus state if it is working on the sequence of the given header.
+func (sb *backend) ForensicDump(header *types.Header) interface{} {
+	sb.coreMu.RLock()
+	started := sb.coreStarted
+	sb.coreMu.RUnlock()
+
+	if !started {
+		return nil
+	}
+	dump := sb.core.Dump()
+	if dump == nil || dump.Sequence.Cmp(header.Number) != 0 {
+		return nil
+	}
+	return dump
+}
+
+// CurrentView implements consensus.ViewReporter, returning the sequence and
+// round the consensus core is working on.
+func (sb *backend) CurrentView() (*consensus.View, error) {
+	sb.coreMu.RLock()
+	started := sb.coreStarted
+	sb.coreMu.RUnlock()
+
+	if !started {
+		return nil, istanbul.ErrStoppedEngine
+	}
+	dump := sb.core.Dump()
+	if dump == nil {
+		return nil, errStateUnavailable
+	}
+	return &consensus.View{
+		Sequence: dump.Sequence.Uint64(),
+		Round:    dump.Round.Uint64(),
+		State:    dump.State,
+		Proposer: dump.Proposer,
+	}, nil
+}
+
+// VerifyFinality implements consensus.FinalityVerifier, checking that the header
+// was proposed by an authorized validator and committed by more than 2F of them.
+func (sb *backend) VerifyFinality(chain consensus.ChainReader, header *types.Header) error {
+	if err := sb.verifySigner(chain, header, nil); err != nil {
+		return err
+	}
+	return sb.verifyCommittedSeals(chain, header, nil)
+}
+
+// CommitInfo implements consensus.CommitInspector, recovering the proposer from
+// the seal of the header and deriving the round it was committed in by replaying
+// the proposer selection over the parent validator set. As the selection cycles
+// through the validators, the lowest matching round is returned.
+func (sb *backend) CommitInfo(chain consensus.ChainReader, header *types.Header) (common.Address, uint64, error) {
+	number := header.Number.Uint64()
+	if number == 0 {
+		return common.Address{}, 0, errUnknownBlock
+	}
+	proposer, err := ecrecover(header)
+	if err != nil {
+		return common.Address{}, 0, err
+	}
+	parent := chain.GetHeader(header.ParentHash, number-1)
+	if parent == nil {
+		return common.Address{}, 0, consensus.ErrUnknownAncestor
+	}
+	// The genesis block has no proposer, same as for the live core
+	var lastProposer common.Address
+	if number > 1 {
+		if lastProposer, err = ecrecover(parent); err != nil {
+			return common.Address{}, 0, err
+		}
+	}
+	snap, err := sb.snapshot(chain, number-1, header.ParentHash, nil)
+	if err != nil {
+		return common.Address{}, 0, err
+	}
+	valSet := snap.ValSet.Copy()
+	for round := uint64(0); round < uint64(valSet.Size()); round++ {
+		valSet.CalcProposer(lastProposer, round)
+		if valSet.GetProposer().Address() == proposer {
+			return proposer, round, nil
+		}
+	}
+	return proposer, 0, errUnauthorized
+}
+
 // VerifySeal checks whether the crypto seal on a header is valid according to
 // the consensus rules of the given engine.
 func (sb *backend) VerifySeal(chain consensus.ChainReader, header *types.Header) error {
@@ -360,14 +511,35 @@ func (sb *backend) Prepare(chain consensus.ChainReader, header *types.Header) er
 		}
 	}
 
+	// carry the parent's committed seals if the reward policy pays its committers
+	var parentSeals [][]byte
+	if config := chain.Config().Istanbul; config != nil && config.Reward != nil && number > 1 {
+		parentExtra, err := types.ExtractIstanbulExtra(parent)
+		if err != nil {
+			return err
+		}
+		parentSeals = parentExtra.CommittedSeal
+	}
 	// add validators in snapshot to extraData's validators section
-	extra, err := prepareExtra(header, snap.validators())
+	extra, err := prepareExtra(header, snap.validators(), parentSeals)
 	if err != nil {
 		return err
 	}
+	// signal readiness for the configured protocol upgrade until it activates
+	if signal := sb.config.UpgradeSignal; signal != "" {
+		if sb.upgradeActive(chain, signal, number) {
+			types.ClearIstanbulSignal(extra[:types.IstanbulExtraVanity])
+		} else {
+			types.SetIstanbulSignal(extra[:types.IstanbulExtraVanity], types.IstanbulUpgradeID(signal))
+		}
+	}
 	header.Extra = extra
 
-	// set header's timestamp
+	// set header's timestamp, derived from the parent's only if deterministic
+	if period := fixedPeriod(chain); period > 0 {
+		header.Time = new(big.Int).Add(parent.Time, new(big.Int).SetUint64(period))
+		return nil
+	}
 	header.Time = new(big.Int).Add(parent.Time, new(big.Int).SetUint64(sb.config.BlockPeriod))
 	if header.Time.Int64() < time.Now().Unix() {
 		header.Time = big.NewInt(time.Now().Unix())
@@ -375,6 +547,15 @@ func (sb *backend) Prepare(chain consensus.ChainReader, header *types.Header) er
 	return nil
 }
 
+// fixedPeriod returns the period block timestamps are derived from if the chain
+// enforces deterministic timestamps, or 0 if they follow the wall clock.
+func fixedPeriod(chain consensus.ChainReader) uint64 {
+	if config := chain.Config().Istanbul; config != nil {
+		return config.FixedPeriod
+	}
+	return 0
+}
+
 // Finalize runs any post-transaction state modifications (e.g. block rewards)
 // and assembles the final block.
 //
@@ -382,12 +563,27 @@ func (sb *backend) Prepare(chain consensus.ChainReader, header *types.Header) er
 // consensus rules that happen at finalization (e.g. block rewards).
 func (sb *backend) Finalize(chain consensus.ChainReader, header *types.Header, state *state.StateDB, txs []*types.Transaction,
 	uncles []*types.Header, receipts []*types.Receipt) (*types.Block, error) {
-	// No block rewards in Istanbul, so the state remains as is and uncles are dropped
+	block, _, err := sb.FinalizeWithReceipts(chain, header, state, txs, uncles, receipts)
+	return block, err
+}
+
+// FinalizeWithReceipts implements consensus.ReceiptFinalizer, finalizing the block
+// and appending the receipt of the system contract call (if any) to the ones of
+// its transactions.
+func (sb *backend) FinalizeWithReceipts(chain consensus.ChainReader, header *types.Header, state *state.StateDB, txs []*types.Transaction,
+	uncles []*types.Header, receipts []*types.Receipt) (*types.Block, []*types.Receipt, error) {
+	// Apply the configured reward policy (if any), uncles are dropped
+	sb.accumulateRewards(chain, header, state, txs, receipts)
+
+	// Call the system contract (if any) with the rewards already distributed
+	if receipt := sb.applySystemCall(chain, header, state, txs, receipts); receipt != nil {
+		receipts = append(receipts[:len(receipts):len(receipts)], receipt)
+	}
 	header.Root = state.IntermediateRoot(chain.Config().IsEIP158(header.Number))
 	header.UncleHash = nilUncleHash
 
 	// Assemble and return the final block for sealing
-	return types.NewBlock(header, txs, nil, receipts), nil
+	return types.NewBlock(header, txs, nil, receipts), receipts, nil
 }
 
 // Seal generates a new block for the given input block with the local miner's
@@ -410,10 +606,22 @@ func (sb *backend) Seal(chain consensus.ChainReader, block *types.Block, stop <-
 	if parent == nil {
 		return nil, consensus.ErrUnknownAncestor
 	}
+	// Hold back empty blocks until the empty block period elapses, the miner
+	// hands over new work aborting the wait as soon as transactions arrive
+	if len(block.Transactions()) == 0 && sb.SuppressEmptyBlocks() && fixedPeriod(chain) == 0 {
+		if min := parent.Time.Uint64() + sb.config.EmptyBlockPeriod; header.Time.Uint64() < min {
+			header.Time = new(big.Int).SetUint64(min)
+			block = block.WithSeal(header)
+		}
+	}
 	block, err = sb.updateBlock(parent, block)
 	if err != nil {
 		return nil, err
 	}
+	// Refuse to propose blocks other validators would deem to be from the future
+	if err := sb.clock.checkPropose(); err != nil {
+		return nil, err
+	}
 
 	// wait for the timestamp of header, use this to adjust the block period
 	delay := time.Unix(block.Header().Time.Int64(), 0).Sub(now())
@@ -451,6 +659,12 @@ func (sb *backend) Seal(chain consensus.ChainReader, block *types.Block, stop <-
 	}
 }
 
+// SuppressEmptyBlocks implements consensus.EmptyBlockSuppressor, returning whether
+// an empty block period longer than the block period is configured.
+func (sb *backend) SuppressEmptyBlocks() bool {
+	return sb.config.EmptyBlockPeriod > sb.config.BlockPeriod
+}
+
 // CalcDifficulty is the difficulty adjustment algorithm. It returns the difficulty
 // that a new block should have based on the previous blocks in the chain and the
 // current signer.
@@ -482,6 +696,11 @@ func (sb *backend) APIs(chain consensus.ChainReader) []rpc.API {
 		Version:   "1.0",
 		Service:   &API{chain: chain, istanbul: sb},
 		Public:    true,
+	}, {
+		Namespace: "debug",
+		Version:   "1.0",
+		Service:   &DebugAPI{istanbul: sb},
+		Public:    false,
 	}}
 }
 
@@ -507,6 +726,8 @@ func (sb *backend) Start(chain consensus.ChainReader, currentBlock func() *types
 	if err := sb.core.Start(); err != nil {
 		return err
 	}
+	sb.clock.start()
+	sb.startAnnouncing()
 
 	sb.coreStarted = true
 	return nil
@@ -522,6 +743,11 @@ func (sb *backend) Stop() error {
 	if err := sb.core.Stop(); err != nil {
 		return err
 	}
+	sb.clock.stop()
+	sb.stopAnnouncing()
+	if sb.audit != nil {
+		sb.audit.Close()
+	}
 	sb.coreStarted = false
 	return nil
 }
@@ -543,6 +769,16 @@ func (sb *backend) snapshot(chain consensus.ChainReader, number uint64, hash com
 		if number%checkpointInterval == 0 {
 			if s, err := loadSnapshot(sb.config.Epoch, sb.db, hash); err == nil {
 				log.Trace("Loaded voting snapshot form disk", "number", number, "hash", hash)
+				forkPolicy(chain, s)
+				snap = s
+				break
+			}
+		}
+		// Otherwise an epoch snapshot will do if it belongs to the same chain
+		if number > 0 && number%sb.config.Epoch == 0 {
+			if s, err := loadEpochSnapshot(sb.config.Epoch, sb.db, number); err == nil && s.Hash == hash {
+				log.Trace("Loaded epoch snapshot from disk", "number", number, "hash", hash)
+				forkPolicy(chain, s)
 				snap = s
 				break
 			}
@@ -558,9 +794,13 @@ func (sb *backend) snapshot(chain consensus.ChainReader, number uint64, hash com
 				return nil, err
 			}
 			snap = newSnapshot(sb.config.Epoch, 0, genesis.Hash(), validator.NewSet(istanbulExtra.Validators, sb.config.ProposerPolicy))
+			forkPolicy(chain, snap)
 			if err := snap.store(sb.db); err != nil {
 				return nil, err
 			}
+			if err := snap.storeEpoch(sb.db); err != nil {
+				return nil, err
+			}
 			log.Trace("Stored genesis voting snapshot to disk")
 			break
 		}
@@ -587,20 +827,56 @@ func (sb *backend) snapshot(chain consensus.ChainReader, number uint64, hash com
 	for i := 0; i < len(headers)/2; i++ {
 		headers[i], headers[len(headers)-1-i] = headers[len(headers)-1-i], headers[i]
 	}
-	snap, err := snap.apply(headers)
-	if err != nil {
-		return nil, err
+	// Stop at every epoch boundary crossed to record its validator set
+	for start := 0; start < len(headers); {
+		end := start + 1
+		for end < len(headers) && headers[end-1].Number.Uint64()%sb.config.Epoch != 0 {
+			end++
+		}
+		var err error
+		if snap, err = snap.apply(headers[start:end]); err != nil {
+			return nil, err
+		}
+		forkPolicy(chain, snap)
+		if snap.Number%sb.config.Epoch == 0 {
+			if err := snap.storeEpoch(sb.db); err != nil {
+				return nil, err
+			}
+			log.Trace("Stored epoch snapshot to disk", "number", snap.Number, "hash", snap.Hash)
+		}
+		start = end
 	}
 	sb.recents.Add(snap.Hash, snap)
 
 	// If we've generated a new checkpoint snapshot, save to disk
 	if snap.Number%checkpointInterval == 0 && len(headers) > 0 {
-		if err = snap.store(sb.db); err != nil {
+		if err := snap.store(sb.db); err != nil {
 			return nil, err
 		}
 		log.Trace("Stored voting snapshot to disk", "number", snap.Number, "hash", snap.Hash)
 	}
-	return snap, err
+	return snap, nil
+}
+
+// GetValidatorsAt implements consensus.Istanbul.GetValidatorsAt, retrieving the
+// list of validators authorized to seal the block following the given one. If
+// the block is not (yet) available locally, the set recorded at the closest
+// preceding epoch boundary is returned instead.
+func (sb *backend) GetValidatorsAt(number uint64) ([]common.Address, error) {
+	if sb.chain != nil {
+		if header := sb.chain.GetHeaderByNumber(number); header != nil {
+			snap, err := sb.snapshot(sb.chain, number, header.Hash(), nil)
+			if err != nil {
+				return nil, err
+			}
+			return snap.validators(), nil
+		}
+	}
+	snap, err := loadEpochSnapshot(sb.config.Epoch, sb.db, number-number%sb.config.Epoch)
+	if err != nil {
+		return nil, errUnknownBlock
+	}
+	return snap.validators(), nil
 }
 
 // FIXME: Need to update this for Istanbul
@@ -641,8 +917,9 @@ func ecrecover(header *types.Header) (common.Address, error) {
 	return addr, nil
 }
 
-// prepareExtra returns a extra-data of the given header and validators
-func prepareExtra(header *types.Header, vals []common.Address) ([]byte, error) {
+// prepareExtra returns a extra-data of the given header, validators and parent
+// committed seals
+func prepareExtra(header *types.Header, vals []common.Address, parentSeals [][]byte) ([]byte, error) {
 	var buf bytes.Buffer
 
 	// compensate the lack bytes if header.Extra is not enough IstanbulExtraVanity bytes.
@@ -652,9 +929,10 @@ func prepareExtra(header *types.Header, vals []common.Address) ([]byte, error) {
 	buf.Write(header.Extra[:types.IstanbulExtraVanity])
 
 	ist := &types.IstanbulExtra{
-		Validators:    vals,
-		Seal:          []byte{},
-		CommittedSeal: [][]byte{},
+		Validators:          vals,
+		Seal:                []byte{},
+		CommittedSeal:       [][]byte{},
+		ParentCommittedSeal: parentSeals,
 	}
 
 	payload, err := rlp.EncodeToBytes(&ist)
@@ -698,7 +976,11 @@ func writeCommittedSeals(h *types.Header, committedSeals [][]byte) error {
 			return errInvalidCommittedSeals
 		}
 	}
+	return setCommittedSeal(h, committedSeals)
+}
 
+// setCommittedSeal replaces the committed seal in the extra-data of the header.
+func setCommittedSeal(h *types.Header, committedSeals [][]byte) error {
 	istanbulExtra, err := types.ExtractIstanbulExtra(h)
 	if err != nil {
 		return err
@@ -86,7 +86,7 @@ func getGenesisAndKeys(n int) (*core.Genesis, []*ecdsa.PrivateKey) {
 	genesis := core.DefaultGenesisBlock()
 	genesis.Config = params.TestChainConfig
 	// force enable Istanbul engine
-	genesis.Config.Istanbul = &params.IstanbulConfig{}
+	genesis.Config.Istanbul = &params.IstanbulConfig{Epoch: istanbul.DefaultConfig.Epoch}
 	genesis.Config.Ethash = nil
 	genesis.Difficulty = defaultDifficulty
 	genesis.Nonce = emptyNonce.Uint64()
@@ -157,6 +157,29 @@ func TestPrepare(t *testing.T) {
 	}
 }
 
+// Tests that with a fixed period configured, block timestamps are derived from
+// the parent's only, and headers deviating from it are rejected.
+func TestFixedPeriod(t *testing.T) {
+	chain, engine := newBlockChain(1)
+	chain.Config().Istanbul.FixedPeriod = 5
+
+	genesis := chain.Genesis()
+	header := makeHeader(genesis, engine.config)
+	if err := engine.Prepare(chain, header); err != nil {
+		t.Fatalf("failed to prepare header: %v", err)
+	}
+	if want := genesis.Time().Uint64() + 5; header.Time.Uint64() != want {
+		t.Errorf("timestamp mismatch: have %v, want %v", header.Time, want)
+	}
+	for _, offset := range []uint64{4, 6} {
+		header := makeBlockWithoutSeal(chain, engine, genesis).Header()
+		header.Time = new(big.Int).SetUint64(genesis.Time().Uint64() + offset)
+		if err := engine.VerifyHeader(chain, header, false); err != errInvalidTimestamp {
+			t.Errorf("offset %d: error mismatch: have %v, want %v", offset, err, errInvalidTimestamp)
+		}
+	}
+}
+
 func TestSealStopChannel(t *testing.T) {
 	chain, engine := newBlockChain(4)
 	block := makeBlockWithoutSeal(chain, engine, chain.Genesis())
@@ -181,6 +204,45 @@ func TestSealStopChannel(t *testing.T) {
 	}
 }
 
+func TestSealEmptyBlockPeriod(t *testing.T) {
+	chain, engine := newBlockChain(4)
+	engine.config.EmptyBlockPeriod = engine.config.BlockPeriod + 10
+	if !engine.SuppressEmptyBlocks() {
+		t.Fatalf("empty blocks not suppressed")
+	}
+	// Propose an empty block at the regular block period, on a clock already
+	// past the empty block period not to wait for it
+	block := makeBlockWithoutSeal(chain, engine, chain.Genesis())
+	header := block.Header()
+	header.Time = new(big.Int).Add(chain.Genesis().Time(), new(big.Int).SetUint64(engine.config.BlockPeriod))
+	block = block.WithSeal(header)
+
+	defer func(old func() time.Time) { now = old }(now)
+	now = func() time.Time {
+		return time.Unix(chain.Genesis().Time().Int64()+int64(engine.config.EmptyBlockPeriod), 0)
+	}
+	stop := make(chan struct{}, 1)
+	eventSub := engine.EventMux().Subscribe(istanbul.RequestEvent{})
+	eventLoop := func() {
+		ev := <-eventSub.Chan()
+		request, ok := ev.Data.(istanbul.RequestEvent)
+		if !ok {
+			t.Errorf("unexpected event comes: %v", reflect.TypeOf(ev.Data))
+		} else {
+			want := chain.Genesis().Time().Uint64() + engine.config.EmptyBlockPeriod
+			if have := request.Proposal.(*types.Block).Time().Uint64(); have != want {
+				t.Errorf("timestamp mismatch: have %d, want %d", have, want)
+			}
+		}
+		stop <- struct{}{}
+		eventSub.Unsubscribe()
+	}
+	go eventLoop()
+	if _, err := engine.Seal(chain, block, stop); err != nil {
+		t.Errorf("error mismatch: have %v, want nil", err)
+	}
+}
+
 func TestSealCommittedOtherHash(t *testing.T) {
 	chain, engine := newBlockChain(4)
 	block := makeBlockWithoutSeal(chain, engine, chain.Genesis())
@@ -330,6 +392,50 @@ func TestVerifySeal(t *testing.T) {
 	}
 }
 
+func TestCommitInfo(t *testing.T) {
+	chain, engine := newBlockChain(4)
+	block := makeBlockWithoutSeal(chain, engine, chain.Genesis())
+	sealed, err := engine.updateBlock(chain.Genesis().Header(), block)
+	if err != nil {
+		t.Fatalf("failed to seal block: %v", err)
+	}
+	// Without a previous proposer, the validator at index N proposes in round N
+	index, _ := engine.Validators(chain.Genesis()).GetByAddress(engine.Address())
+
+	proposer, round, err := engine.CommitInfo(chain, sealed.Header())
+	if err != nil {
+		t.Fatalf("failed to retrieve commit info: %v", err)
+	}
+	if proposer != engine.Address() {
+		t.Errorf("proposer mismatch: have %x, want %x", proposer, engine.Address())
+	}
+	if round != uint64(index) {
+		t.Errorf("round mismatch: have %d, want %d", round, index)
+	}
+	if _, _, err := engine.CommitInfo(chain, chain.Genesis().Header()); err != errUnknownBlock {
+		t.Errorf("genesis error mismatch: have %v, want %v", err, errUnknownBlock)
+	}
+}
+
+func TestCurrentView(t *testing.T) {
+	chain, engine := newBlockChain(1)
+
+	view, err := engine.CurrentView()
+	if err != nil {
+		t.Fatalf("failed to retrieve view: %v", err)
+	}
+	if view.Sequence != chain.CurrentBlock().NumberU64()+1 || view.Round != 0 {
+		t.Errorf("view mismatch: have %d/%d, want %d/0", view.Sequence, view.Round, chain.CurrentBlock().NumberU64()+1)
+	}
+	if view.Proposer != engine.Address() {
+		t.Errorf("proposer mismatch: have %x, want %x", view.Proposer, engine.Address())
+	}
+	engine.Stop()
+	if _, err := engine.CurrentView(); err != istanbul.ErrStoppedEngine {
+		t.Errorf("stopped engine error mismatch: have %v, want %v", err, istanbul.ErrStoppedEngine)
+	}
+}
+
 func TestVerifyHeaders(t *testing.T) {
 	chain, engine := newBlockChain(1)
 	genesis := chain.Genesis()
@@ -445,7 +551,7 @@ func TestPrepareExtra(t *testing.T) {
 		Extra: vanity,
 	}
 
-	payload, err := prepareExtra(h, validators)
+	payload, err := prepareExtra(h, validators, nil)
 	if err != nil {
 		t.Errorf("error mismatch: have %v, want: nil", err)
 	}
@@ -456,7 +562,7 @@ func TestPrepareExtra(t *testing.T) {
 	// append useless information to extra-data
 	h.Extra = append(vanity, make([]byte, 15)...)
 
-	payload, err = prepareExtra(h, validators)
+	payload, err = prepareExtra(h, validators, nil)
 	if !reflect.DeepEqual(payload, expectedResult) {
 		t.Errorf("payload mismatch: have %v, want %v", payload, expectedResult)
 	}
@@ -0,0 +1,44 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package backend
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/consensus/istanbul"
+	"github.com/ethereum/go-ethereum/consensus/istanbul/validator"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// forkPolicy switches the validator set of a newly created snapshot to the
+// proposer policy scheduled by the chain's forks for the block following it, as
+// that is the block whose proposer the set selects.
+func forkPolicy(chain consensus.ChainReader, snap *Snapshot) {
+	if chain.Config() == nil || chain.Config().Istanbul == nil {
+		return
+	}
+	config := chain.Config().Istanbul
+	if len(config.Forks) == 0 {
+		return
+	}
+	policy := istanbul.ProposerPolicy(config.ProposerPolicyAt(new(big.Int).SetUint64(snap.Number + 1)))
+	if snap.ValSet.Policy() != policy {
+		log.Debug("Switching proposer policy", "number", snap.Number+1, "old", snap.ValSet.Policy(), "new", policy)
+		snap.ValSet = validator.NewSet(snap.validators(), policy)
+	}
+}
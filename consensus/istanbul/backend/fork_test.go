@@ -0,0 +1,57 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package backend
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/consensus/istanbul"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// Tests that the proposer policy switches at the block scheduled by a fork.
+func TestForkPolicy(t *testing.T) {
+	chain, engine := newBlockChain(1)
+	defer engine.Stop()
+
+	sticky := uint64(istanbul.Sticky)
+	chain.Config().Istanbul.Forks = []*params.IstanbulForkConfig{{Name: "sticky", Block: big.NewInt(3), ProposerPolicy: &sticky}}
+
+	block := chain.Genesis()
+	for i := 0; i < 3; i++ {
+		block = makeBlock(chain, engine, block)
+		if _, err := chain.InsertChain(types.Blocks{block}); err != nil {
+			t.Fatalf("failed to insert block #%d: %v", block.NumberU64(), err)
+		}
+	}
+	// The snapshot at a block selects the proposer of the following one
+	for number, want := range []istanbul.ProposerPolicy{istanbul.RoundRobin, istanbul.RoundRobin, istanbul.Sticky, istanbul.Sticky} {
+		header := chain.GetHeaderByNumber(uint64(number))
+		snap, err := engine.snapshot(chain, uint64(number), header.Hash(), nil)
+		if err != nil {
+			t.Fatalf("failed to retrieve snapshot #%d: %v", number, err)
+		}
+		if have := snap.ValSet.Policy(); have != want {
+			t.Errorf("snapshot #%d: policy mismatch: have %d, want %d", number, have, want)
+		}
+	}
+	if !chain.Config().Istanbul.IsFork("sticky", big.NewInt(3)) || chain.Config().Istanbul.IsFork("sticky", big.NewInt(2)) {
+		t.Errorf("fork activation mismatch")
+	}
+}
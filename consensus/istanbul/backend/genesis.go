@@ -0,0 +1,73 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package backend
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+var (
+	// errNoIstanbulConfig is returned if a genesis lacks the Istanbul engine config.
+	errNoIstanbulConfig = errors.New("missing istanbul chain config")
+	// errNoValidators is returned if a genesis lacks initial validators.
+	errNoValidators = errors.New("no validators in extra data")
+	// errSealedGenesis is returned if a genesis carries a seal or committed seals.
+	errSealedGenesis = errors.New("genesis extra data contains seals")
+)
+
+// ValidateGenesis checks that the consensus fields of a genesis specification
+// make up a valid Istanbul genesis block, returning the initial validators.
+func ValidateGenesis(genesis *core.Genesis) ([]common.Address, error) {
+	if genesis.Config == nil || genesis.Config.Istanbul == nil {
+		return nil, errNoIstanbulConfig
+	}
+	if err := genesis.Config.CheckEngine(); err != nil {
+		return nil, err
+	}
+	if genesis.Mixhash != types.IstanbulDigest {
+		return nil, errInvalidMixDigest
+	}
+	if types.EncodeNonce(genesis.Nonce) != emptyNonce {
+		return nil, errInvalidNonce
+	}
+	if genesis.Difficulty == nil || genesis.Difficulty.Cmp(defaultDifficulty) != 0 {
+		return nil, errInvalidDifficulty
+	}
+	extra, err := types.DecodeIstanbulExtra(genesis.ExtraData)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %v", errInvalidExtraDataFormat, err)
+	}
+	if len(extra.Seal) > 0 || len(extra.CommittedSeal) > 0 {
+		return nil, errSealedGenesis
+	}
+	if len(extra.Validators) == 0 {
+		return nil, errNoValidators
+	}
+	seen := make(map[common.Address]bool)
+	for _, validator := range extra.Validators {
+		if seen[validator] {
+			return nil, fmt.Errorf("duplicate validator %x", validator)
+		}
+		seen[validator] = true
+	}
+	return extra.Validators, nil
+}
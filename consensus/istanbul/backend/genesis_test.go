@@ -0,0 +1,73 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package backend
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// Tests that the consensus fields of Istanbul genesis specifications are checked.
+func TestValidateGenesis(t *testing.T) {
+	tests := []struct {
+		modify func(genesis *core.Genesis)
+		fail   bool
+	}{
+		{func(genesis *core.Genesis) {}, false},
+		{func(genesis *core.Genesis) { genesis.Config.Istanbul = nil }, true},
+		{func(genesis *core.Genesis) { genesis.Config.Istanbul.Epoch = 0 }, false},
+		{func(genesis *core.Genesis) { genesis.Config.Istanbul.ProposerPolicy = 2 }, true},
+		{func(genesis *core.Genesis) { genesis.Config.Clique = &params.CliqueConfig{Epoch: 30000} }, true},
+		{func(genesis *core.Genesis) {
+			genesis.Config.Istanbul.Forks = []*params.IstanbulForkConfig{{Name: "a", Block: big.NewInt(2)}, {Name: "b", Block: big.NewInt(1)}}
+		}, true},
+		{func(genesis *core.Genesis) {
+			genesis.Config.Istanbul.Forks = []*params.IstanbulForkConfig{{Name: "a", Block: big.NewInt(1)}, {Name: "a", Block: big.NewInt(2)}}
+		}, true},
+		{func(genesis *core.Genesis) { genesis.Mixhash = common.Hash{} }, true},
+		{func(genesis *core.Genesis) { genesis.Nonce = 1 }, true},
+		{func(genesis *core.Genesis) { genesis.Difficulty = big.NewInt(2) }, true},
+		{func(genesis *core.Genesis) { genesis.ExtraData = genesis.ExtraData[:types.IstanbulExtraVanity] }, true},
+		{func(genesis *core.Genesis) { appendValidators(genesis, nil) }, true},
+		{func(genesis *core.Genesis) {
+			appendValidators(genesis, []common.Address{{0x01}, {0x02}, {0x01}})
+		}, true},
+	}
+	for i, tt := range tests {
+		genesis, keys := getGenesisAndKeys(2)
+		tt.modify(genesis)
+
+		validators, err := ValidateGenesis(genesis)
+		if tt.fail {
+			if err == nil {
+				t.Errorf("test %d: invalid genesis accepted", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("test %d: valid genesis rejected: %v", i, err)
+		}
+		if len(validators) != len(keys) {
+			t.Errorf("test %d: validator count mismatch: have %d, want %d", i, len(validators), len(keys))
+		}
+	}
+}
@@ -27,7 +27,14 @@ import (
 )
 
 const (
-	istanbulMsg = 0x11
+	istanbul64 = 64
+	istanbul65 = 65 // Adds the signed version attestation after connecting
+	istanbul66 = 66 // Adds the periodic finalized head announcements
+	istanbul67 = 67 // Adds the chain config fingerprint to the status handshake
+
+	istanbulMsg          = 0x11
+	istanbulVersionMsg   = 0x12
+	istanbulFinalizedMsg = 0x13
 )
 
 var (
@@ -38,14 +45,21 @@ var (
 // Protocol implements consensus.Engine.Protocol
 func (sb *backend) Protocol() consensus.Protocol {
 	return consensus.Protocol{
-		Name:     "istanbul",
-		Versions: []uint{64},
-		Lengths:  []uint64{18},
+		Name:            "istanbul",
+		Versions:        []uint{istanbul67, istanbul66, istanbul65, istanbul64},
+		Lengths:         []uint64{20, 20, 19, 18},
+		FingerprintFrom: istanbul67,
 	}
 }
 
 // HandleMsg implements consensus.Handler.HandleMsg
 func (sb *backend) HandleMsg(addr common.Address, msg p2p.Msg) (bool, error) {
+	if msg.Code == istanbulVersionMsg {
+		return true, sb.handleAttestation(addr, msg)
+	}
+	if msg.Code == istanbulFinalizedMsg {
+		return true, sb.handleFinalized(addr, msg)
+	}
 	sb.coreMu.Lock()
 	defer sb.coreMu.Unlock()
 
@@ -53,6 +67,9 @@ func (sb *backend) HandleMsg(addr common.Address, msg p2p.Msg) (bool, error) {
 		if !sb.coreStarted {
 			return true, istanbul.ErrStoppedEngine
 		}
+		if err := sb.checkScore(addr); err != nil {
+			return true, err
+		}
 
 		var data []byte
 		if err := msg.Decode(&data); err != nil {
@@ -98,6 +115,8 @@ func (sb *backend) NewChainHead() error {
 	if !sb.coreStarted {
 		return istanbul.ErrStoppedEngine
 	}
+	go sb.recordParticipation(sb.chain)
+	go sb.announceUpgrades(sb.chain)
 	go sb.istanbulEventMux.Post(istanbul.FinalCommittedEvent{})
 	return nil
 }
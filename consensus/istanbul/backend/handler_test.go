@@ -17,10 +17,15 @@
 package backend
 
 import (
+	"math/big"
 	"testing"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/consensus/istanbul"
+	istanbulCore "github.com/ethereum/go-ethereum/consensus/istanbul/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/p2p"
 	"github.com/ethereum/go-ethereum/rlp"
 	lru "github.com/hashicorp/golang-lru"
@@ -66,6 +71,152 @@ func TestIstanbulMessage(t *testing.T) {
 	}
 }
 
+func TestVersionAttestation(t *testing.T) {
+	_, local := newBlockChain(1)
+	_, remote := newBlockChain(1)
+
+	config := *remote.config
+	config.BuildVersion = "1.8.0-stable"
+	config.BuildFeatures = []string{"participation"}
+	remote.config = &config
+
+	att, err := remote.attestation()
+	if err != nil {
+		t.Fatalf("failed to create attestation: %v", err)
+	}
+	// an attestation relayed by a different peer must be rejected
+	other := common.StringToAddress("other")
+	if _, err := local.HandleMsg(other, makeMsg(istanbulVersionMsg, att)); err != errAttestationSigner {
+		t.Fatalf("error mismatch: have %v, want %v", err, errAttestationSigner)
+	}
+	handled, err := local.HandleMsg(remote.Address(), makeMsg(istanbulVersionMsg, att))
+	if !handled || err != nil {
+		t.Fatalf("failed to handle attestation: handled %v, err %v", handled, err)
+	}
+	versions, err := local.peerVersions([]common.Address{remote.Address()})
+	if err != nil {
+		t.Fatalf("failed to retrieve versions: %v", err)
+	}
+	if _, ok := versions[other]; ok {
+		t.Errorf("rejected attestation recorded")
+	}
+	peer := versions[remote.Address()]
+	if peer == nil || peer.VersionAttestation == nil {
+		t.Fatalf("attestation not recorded")
+	}
+	if peer.Version != "1.8.0-stable" || len(peer.Features) != 1 || !peer.Validator {
+		t.Errorf("attestation mismatch: %+v", peer)
+	}
+	if versions[local.Address()] == nil {
+		t.Errorf("local version missing")
+	}
+}
+
+func TestPeerScore(t *testing.T) {
+	_, backend := newBlockChain(1)
+
+	addr := common.StringToAddress("address")
+	for i := 0; i < maxPeerScore/faultPenalty; i++ {
+		if _, err := backend.HandleMsg(addr, makeMsg(istanbulMsg, []byte{byte(i)})); err != nil {
+			t.Fatalf("penalty %d: peer dropped early: %v", i, err)
+		}
+		backend.Penalize(addr, errInvalidSignature)
+	}
+	if _, err := backend.HandleMsg(addr, makeMsg(istanbulMsg, []byte("data"))); err != errLowPeerScore {
+		t.Fatalf("error mismatch: have %v, want %v", err, errLowPeerScore)
+	}
+	// The score is reset for the peer to start over after reconnecting
+	if _, err := backend.HandleMsg(addr, makeMsg(istanbulMsg, []byte("data"))); err != nil {
+		t.Errorf("peer score not reset: %v", err)
+	}
+}
+
+// Tests that finalized head announcements of validators ahead of the local chain
+// trigger a sync with the announcing peer once their seals check out, once per
+// announced block until the sync ends.
+func TestFinalizedAnnouncement(t *testing.T) {
+	_, engine := newBlockChain(2)
+	broadcaster := &testBroadcaster{syncs: make(chan uint64, 16), done: make(chan struct{})}
+	engine.SetBroadcaster(broadcaster)
+
+	var validator common.Address
+	for _, val := range engine.Validators(engine.currentBlock()).List() {
+		if val.Address() != engine.Address() {
+			validator = val.Address()
+		}
+	}
+	forger, _ := crypto.GenerateKey()
+
+	announce := func(addr common.Address, number uint64, sign func([]byte) ([]byte, error)) {
+		header := &types.Header{Number: new(big.Int).SetUint64(number), Difficulty: defaultDifficulty, MixDigest: types.IstanbulDigest}
+		header.Extra, _ = prepareExtra(header, nil, nil)
+
+		var seals [][]byte
+		if sign != nil {
+			seal, err := sign(istanbulCore.PrepareCommittedSeal(header.Hash()))
+			if err != nil {
+				t.Fatalf("failed to sign committed seal: %v", err)
+			}
+			seals = append(seals, seal)
+		}
+		if err := setCommittedSeal(header, seals); err != nil {
+			t.Fatalf("failed to write committed seals: %v", err)
+		}
+		msg := makeMsg(istanbulFinalizedMsg, &finalizedAnnouncement{Header: header})
+		if handled, err := engine.HandleMsg(addr, msg); !handled || err != nil {
+			t.Fatalf("failed to handle announcement: handled %v, err %v", handled, err)
+		}
+	}
+	forge := func(data []byte) ([]byte, error) {
+		return crypto.Sign(crypto.Keccak256(data), forger)
+	}
+	expect := func(want ...uint64) {
+		var have []uint64
+		for len(have) < len(want) {
+			select {
+			case number := <-broadcaster.syncs:
+				have = append(have, number)
+			case <-time.After(time.Second):
+				t.Fatalf("sync mismatch: have %v, want %v", have, want)
+			}
+		}
+		select {
+		case number := <-broadcaster.syncs:
+			t.Fatalf("unexpected sync to %d", number)
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	announce(validator, 0, engine.Sign)                       // not ahead of the local chain
+	announce(validator, maxAnnounceLead+1, engine.Sign)       // too far ahead of the local chain
+	announce(validator, 5, nil)                               // not finalized
+	announce(validator, 5, forge)                             // not sealed by the validators
+	announce(common.StringToAddress("other"), 5, engine.Sign) // not a validator
+	expect()
+
+	announce(validator, 5, engine.Sign)
+	announce(validator, 5, engine.Sign) // already syncing
+	announce(validator, 4, engine.Sign) // already syncing
+	announce(validator, 6, engine.Sign)
+	expect(5, 6)
+
+	// Once the syncs end, whatever their outcome, announcements are followed again
+	close(broadcaster.done)
+	for i := 0; ; i++ {
+		engine.announceMu.Lock()
+		target := engine.announceTarget
+		engine.announceMu.Unlock()
+		if target == 0 {
+			break
+		}
+		if i == 100 {
+			t.Fatalf("announce target not reset: %d", target)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	announce(validator, 5, engine.Sign)
+	expect(5)
+}
+
 func makeMsg(msgcode uint64, data interface{}) p2p.Msg {
 	size, r, _ := rlp.EncodeToReader(data)
 	return p2p.Msg{Code: msgcode, Size: uint32(size), Payload: r}
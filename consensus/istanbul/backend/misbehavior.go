@@ -0,0 +1,121 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package backend
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"sync"
+
+	istanbulCore "github.com/ethereum/go-ethereum/consensus/istanbul/core"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+const (
+	dbKeyMisbehaviorPrefix = "istanbul-misbehavior" // Detected misbehavior keyed by big endian sequence
+
+	maxMisbehaviorRange = 10000 // Maximum number of sequences to query misbehavior over at once
+)
+
+var (
+	// errMisbehaviorRange is returned if misbehavior is queried over an invalid
+	// or too large range of blocks.
+	errMisbehaviorRange = errors.New("invalid block range")
+
+	misbehaviorCounter = metrics.NewRegisteredCounter("consensus/istanbul/misbehavior", nil)
+)
+
+// misbehaviorKey returns the database key of the misbehavior recorded for a
+// sequence.
+func misbehaviorKey(sequence uint64) []byte {
+	key := make([]byte, len(dbKeyMisbehaviorPrefix)+8)
+	copy(key, dbKeyMisbehaviorPrefix)
+	binary.BigEndian.PutUint64(key[len(dbKeyMisbehaviorPrefix):], sequence)
+	return key
+}
+
+// misbehaviorLog persists the misbehavior detected by the core into the
+// database, grouped by sequence. Unlike archived messages, the evidence is kept
+// forever and survives rewinds of the chain.
+type misbehaviorLog struct {
+	db   ethdb.Database
+	lock sync.Mutex
+}
+
+// newMisbehaviorLog creates a log persisting misbehavior into the database.
+func newMisbehaviorLog(db ethdb.Database) *misbehaviorLog {
+	return &misbehaviorLog{db: db}
+}
+
+// Record implements core.MisbehaviorLog.Record, appending the misbehavior to the
+// ones recorded for its sequence.
+func (l *misbehaviorLog) Record(m *istanbulCore.Misbehavior) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	records, _ := l.load(m.Sequence)
+	blob, err := json.Marshal(append(records, m))
+	if err != nil {
+		log.Error("Failed to encode validator misbehavior", "sequence", m.Sequence, "err", err)
+		return
+	}
+	if err := l.db.Put(misbehaviorKey(m.Sequence), blob); err != nil {
+		log.Error("Failed to persist validator misbehavior", "sequence", m.Sequence, "err", err)
+		return
+	}
+	misbehaviorCounter.Inc(1)
+}
+
+// Load retrieves the misbehavior recorded for the sequences in the given range,
+// both ends included, in the order it was detected.
+func (l *misbehaviorLog) Load(from, to uint64) ([]*istanbulCore.Misbehavior, error) {
+	if from > to || to-from >= maxMisbehaviorRange {
+		return nil, errMisbehaviorRange
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	records := []*istanbulCore.Misbehavior{}
+	for seq := from; ; seq++ {
+		recorded, err := l.load(seq)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, recorded...)
+		if seq == to {
+			break
+		}
+	}
+	return records, nil
+}
+
+// load retrieves the misbehavior recorded for a sequence, none if the sequence
+// has no record.
+func (l *misbehaviorLog) load(sequence uint64) ([]*istanbulCore.Misbehavior, error) {
+	blob, err := l.db.Get(misbehaviorKey(sequence))
+	if err != nil {
+		return nil, nil
+	}
+	var records []*istanbulCore.Misbehavior
+	if err := json.Unmarshal(blob, &records); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
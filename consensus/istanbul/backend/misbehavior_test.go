@@ -0,0 +1,75 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package backend
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	istanbulCore "github.com/ethereum/go-ethereum/consensus/istanbul/core"
+	"github.com/ethereum/go-ethereum/ethdb"
+)
+
+// Tests that recorded misbehavior is persisted per sequence and retrieved over
+// ranges of sequences in the order recorded.
+func TestMisbehaviorLog(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+	log := newMisbehaviorLog(db)
+
+	records := []*istanbulCore.Misbehavior{
+		{Kind: istanbulCore.MisbehaviorTimeout, Address: common.HexToAddress("0x01"), Sequence: 3, Round: 0},
+		{Kind: istanbulCore.MisbehaviorEquivocation, Address: common.HexToAddress("0x02"), Sequence: 5, Code: "prepare", Evidence: []hexutil.Bytes{{1}, {2}}},
+		{Kind: istanbulCore.MisbehaviorTimeout, Address: common.HexToAddress("0x03"), Sequence: 3, Round: 1},
+		{Kind: istanbulCore.MisbehaviorInvalid, Address: common.HexToAddress("0x04"), Sequence: 7, Reason: "invalid committed seal"},
+	}
+	for _, record := range records {
+		log.Record(record)
+	}
+	// Reopening the log must not lose anything
+	log = newMisbehaviorLog(db)
+
+	tests := []struct {
+		from, to uint64
+		want     []*istanbulCore.Misbehavior
+	}{
+		{0, 2, nil},
+		{3, 3, []*istanbulCore.Misbehavior{records[0], records[2]}},
+		{3, 5, []*istanbulCore.Misbehavior{records[0], records[2], records[1]}},
+		{5, 100, []*istanbulCore.Misbehavior{records[1], records[3]}},
+	}
+	for i, test := range tests {
+		have, err := log.Load(test.from, test.to)
+		if err != nil {
+			t.Fatalf("test %d: failed to load misbehavior: %v", i, err)
+		}
+		if len(have) != len(test.want) {
+			t.Fatalf("test %d: record count mismatch: have %d, want %d", i, len(have), len(test.want))
+		}
+		for j := range have {
+			if have[j].Kind != test.want[j].Kind || have[j].Address != test.want[j].Address || have[j].Round != test.want[j].Round || len(have[j].Evidence) != len(test.want[j].Evidence) {
+				t.Errorf("test %d, record %d: mismatch: have %+v, want %+v", i, j, have[j], test.want[j])
+			}
+		}
+	}
+	if _, err := log.Load(5, 3); err != errMisbehaviorRange {
+		t.Errorf("inverted range error mismatch: have %v, want %v", err, errMisbehaviorRange)
+	}
+	if _, err := log.Load(0, maxMisbehaviorRange); err != errMisbehaviorRange {
+		t.Errorf("oversized range error mismatch: have %v, want %v", err, errMisbehaviorRange)
+	}
+}
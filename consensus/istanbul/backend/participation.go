@@ -0,0 +1,125 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package backend
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+const (
+	inmemorySealers = 4096 // Number of recent blocks to keep the recovered committers of
+
+	defaultParticipationWindow = 1000  // Blocks to report participation over if not requested otherwise
+	maxParticipationWindow     = 10000 // Maximum number of blocks to report participation over
+
+	maxParticipationCatchup = 1024 // Maximum number of blocks to account for in the metrics at once
+)
+
+var (
+	participationBlocksCounter = metrics.NewRegisteredCounter("consensus/istanbul/participation/blocks", nil)
+	participationMissedMeter   = metrics.NewRegisteredMeter("consensus/istanbul/participation/missed", nil)
+)
+
+// sealersOf returns the validators whose commit seals are included in the given
+// header, caching the result as recovering the seals is expensive. The seals are
+// gathered locally and not covered by the block hash, so they may differ between
+// nodes and must not feed into consensus.
+func (sb *backend) sealersOf(chain consensus.ChainReader, header *types.Header) []common.Address {
+	// The same block may be stored with different seals, key by those too
+	hash := crypto.Keccak256Hash(header.Hash().Bytes(), header.Extra)
+	if sealers, ok := sb.sealers.Get(hash); ok {
+		return sealers.([]common.Address)
+	}
+	sealers := committedSealers(chain.Config().Istanbul, header)
+	sb.sealers.Add(hash, sealers)
+	return sealers
+}
+
+// parentSealersOf returns the validators whose commit seals of the parent block
+// are carried by the given header. Unlike the header's own commit seals these
+// are covered by its hash, so every node agrees on them.
+func (sb *backend) parentSealersOf(chain consensus.ChainReader, header *types.Header) []common.Address {
+	hash := header.Hash()
+	if sealers, ok := sb.parentSealers.Get(hash); ok {
+		return sealers.([]common.Address)
+	}
+	sealers := parentCommittedSealers(chain.Config().Istanbul, header)
+	sb.parentSealers.Add(hash, sealers)
+	return sealers
+}
+
+// participation counts the commit seals of each validator in the window of
+// blocks preceding the given header, returning the counts and the number of
+// blocks actually looked at (fewer close to the genesis). Only the parent seals
+// carried by the headers are counted, which every node agrees on.
+func (sb *backend) participation(chain consensus.ChainReader, header *types.Header, window uint64) (map[common.Address]uint64, uint64) {
+	counts := make(map[common.Address]uint64)
+
+	var blocks uint64
+	for blocks < window && header != nil && header.Number.Uint64() > 1 {
+		for _, sealer := range sb.parentSealersOf(chain, header) {
+			counts[sealer]++
+		}
+		blocks++
+		header = chain.GetHeader(header.ParentHash, header.Number.Uint64()-1)
+	}
+	return counts, blocks
+}
+
+// recordParticipation accounts the commit seals of the blocks imported since
+// the last invocation in the participation metrics.
+func (sb *backend) recordParticipation(chain consensus.ChainReader) {
+	head := chain.CurrentHeader()
+	if head == nil {
+		return
+	}
+	sb.participationMu.Lock()
+	defer sb.participationMu.Unlock()
+
+	number := head.Number.Uint64()
+	if sb.participationHead == 0 || sb.participationHead >= number || number-sb.participationHead > maxParticipationCatchup {
+		sb.participationHead = number - 1
+	}
+	for n := sb.participationHead + 1; n <= number && n > 0; n++ {
+		header := chain.GetHeaderByNumber(n)
+		if header == nil {
+			break
+		}
+		sealed := false
+		for _, sealer := range sb.sealersOf(chain, header) {
+			metrics.GetOrRegisterCounter("consensus/istanbul/participation/sealed/"+sealer.Hex(), nil).Inc(1)
+			sealed = sealed || sealer == sb.address
+		}
+		participationBlocksCounter.Inc(1)
+
+		// Only count our own misses for blocks we were supposed to seal
+		if !sealed {
+			if parent := chain.GetHeader(header.ParentHash, n-1); parent != nil {
+				if snap, err := sb.snapshot(chain, n-1, parent.Hash(), nil); err == nil {
+					if _, v := snap.ValSet.GetByAddress(sb.address); v != nil {
+						participationMissedMeter.Mark(1)
+					}
+				}
+			}
+		}
+		sb.participationHead = n
+	}
+}
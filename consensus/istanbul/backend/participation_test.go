@@ -0,0 +1,82 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package backend
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// Tests that the commit seals of sealed blocks are accounted for both in the
+// participation counts and the RPC report.
+func TestParticipation(t *testing.T) {
+	chain, engine := newBlockChain(1)
+	chain.Config().Istanbul.Reward = &params.IstanbulRewardConfig{ParticipationWindow: 2}
+
+	parent := chain.Genesis()
+	for i := 0; i < 3; i++ {
+		block := makeBlock(chain, engine, parent)
+		if _, err := chain.InsertChain(types.Blocks{block}); err != nil {
+			t.Fatalf("failed to insert block %d: %v", i+1, err)
+		}
+		parent = block
+	}
+	counts, blocks := engine.participation(chain, chain.CurrentHeader(), 2)
+	if blocks != 2 || counts[engine.Address()] != 2 {
+		t.Errorf("participation mismatch: have %d/%d blocks, want 2/2", counts[engine.Address()], blocks)
+	}
+	// The first block carries no parent seals, the genesis having none
+	if _, blocks := engine.participation(chain, chain.CurrentHeader(), 10); blocks != 2 {
+		t.Errorf("window not capped at genesis: have %d blocks, want 2", blocks)
+	}
+	api := &API{chain: chain, istanbul: engine}
+
+	report, err := api.GetParticipation(nil, nil)
+	if err != nil {
+		t.Fatalf("failed to retrieve participation: %v", err)
+	}
+	if report.From != 1 || report.To != 3 {
+		t.Errorf("range mismatch: have %d-%d, want 1-3", report.From, report.To)
+	}
+	record := report.Validators[engine.Address()]
+	if record == nil || record.Eligible != 3 || record.Sealed != 3 || record.Rate != 1 {
+		t.Errorf("validator record mismatch: have %+v", record)
+	}
+}
+
+// Tests that recovered committers are cached by the seals too, as the same block
+// may be seen with different ones.
+func TestSealersCache(t *testing.T) {
+	chain, engine := newBlockChain(1)
+
+	block := makeBlock(chain, engine, chain.Genesis())
+	header := block.Header()
+	if sealers := engine.sealersOf(chain, header); len(sealers) != 1 || sealers[0] != engine.Address() {
+		t.Fatalf("sealers mismatch: have %v, want [%x]", sealers, engine.Address())
+	}
+	if err := writeCommittedSeals(header, [][]byte{make([]byte, types.IstanbulExtraSeal)}); err != nil {
+		t.Fatalf("failed to replace committed seals: %v", err)
+	}
+	if header.Hash() != block.Hash() {
+		t.Fatalf("committed seals covered by the hash")
+	}
+	if sealers := engine.sealersOf(chain, header); len(sealers) != 0 {
+		t.Errorf("stale sealers returned: %v", sealers)
+	}
+}
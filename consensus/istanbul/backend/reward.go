@@ -0,0 +1,138 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package backend
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/consensus/istanbul"
+	istanbulCore "github.com/ethereum/go-ethereum/consensus/istanbul/core"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// accumulateRewards credits the block reward and redistributes the transaction
+// fees of the given block according to the chain's reward policy.
+func (sb *backend) accumulateRewards(chain consensus.ChainReader, header *types.Header, state *state.StateDB, txs []*types.Transaction, receipts []*types.Receipt) {
+	config := chain.Config().Istanbul
+	if config == nil || config.Reward == nil {
+		return
+	}
+	// Blocks being finalized for sealing are not yet signed, those are ours
+	proposer, err := ecrecover(header)
+	if err != nil {
+		proposer = sb.address
+	}
+	// The parent's committers are taken from the seals carried by this block, as
+	// the ones stored with the parent are only what this node happened to gather
+	var (
+		committers    = sb.parentSealersOf(chain, header)
+		participation map[common.Address]uint64
+		window        uint64
+	)
+	if config.Reward.ParticipationWindow > 0 {
+		participation, window = sb.participation(chain, header, config.Reward.ParticipationWindow)
+	}
+	fees := new(big.Int)
+	for i, receipt := range receipts {
+		if i < len(txs) {
+			fees.Add(fees, new(big.Int).Mul(new(big.Int).SetUint64(receipt.GasUsed), txs[i].GasPrice()))
+		}
+	}
+	applyRewards(config.Reward, state, header.Coinbase, proposer, committers, participation, window, fees)
+}
+
+// applyRewards credits the block reward to the proposer and committers and moves
+// the already paid transaction fees away from the coinbase if the policy says so.
+// If participation counts are given, each committer only receives the fraction
+// of its share matching the blocks it sealed out of the window.
+func applyRewards(policy *params.IstanbulRewardConfig, state *state.StateDB, coinbase, proposer common.Address, committers []common.Address, participation map[common.Address]uint64, window uint64, fees *big.Int) {
+	if policy.BlockReward != nil && policy.BlockReward.Sign() > 0 {
+		share := policy.SealerShare
+		if share > 100 {
+			share = 100
+		}
+		reward := new(big.Int).Set(policy.BlockReward)
+		if len(committers) > 0 && share > 0 {
+			pool := new(big.Int).Mul(policy.BlockReward, new(big.Int).SetUint64(share))
+			pool.Div(pool, big.NewInt(100))
+
+			// Split evenly, any rounding dust stays with the proposer
+			each := new(big.Int).Div(pool, big.NewInt(int64(len(committers))))
+			for _, committer := range committers {
+				part := each
+				if participation != nil && window > 0 {
+					part = new(big.Int).Mul(each, new(big.Int).SetUint64(participation[committer]))
+					part.Div(part, new(big.Int).SetUint64(window))
+				}
+				state.AddBalance(committer, part)
+				reward.Sub(reward, part)
+			}
+		}
+		state.AddBalance(proposer, reward)
+	}
+	// Transaction fees were credited to the coinbase during execution
+	if fees.Sign() > 0 && (policy.BurnFees || policy.Treasury != nil) {
+		state.SubBalance(coinbase, fees)
+		if !policy.BurnFees {
+			state.AddBalance(*policy.Treasury, fees)
+		}
+	}
+}
+
+// committedSealers recovers the addresses of the validators that committed the
+// given block. Invalid seals are skipped, as the header was already verified.
+func committedSealers(config *params.IstanbulConfig, header *types.Header) []common.Address {
+	extra, err := types.ExtractIstanbulExtra(header)
+	if err != nil {
+		return nil
+	}
+	return recoverSealers(config, header.Number, header.Hash(), extra.CommittedSeal)
+}
+
+// parentCommittedSealers recovers the addresses of the validators that committed
+// the parent of the given block from the parent committed seals it carries.
+func parentCommittedSealers(config *params.IstanbulConfig, header *types.Header) []common.Address {
+	if header.Number.Sign() == 0 {
+		return nil
+	}
+	extra, err := types.ExtractIstanbulExtra(header)
+	if err != nil {
+		return nil
+	}
+	return recoverSealers(config, new(big.Int).Sub(header.Number, common.Big1), header.ParentHash, extra.ParentCommittedSeal)
+}
+
+// recoverSealers recovers the addresses of the validators that created the given
+// committed seals of the block with the given number and hash.
+func recoverSealers(config *params.IstanbulConfig, number *big.Int, hash common.Hash, seals [][]byte) []common.Address {
+	if config != nil && config.Threshold.IsActive(number) {
+		return thresholdSealers(config.Threshold, seals)
+	}
+	proposalSeal := istanbulCore.PrepareCommittedSeal(hash)
+
+	var sealers []common.Address
+	for _, seal := range seals {
+		if addr, err := istanbul.GetSignatureAddress(proposalSeal, seal); err == nil {
+			sealers = append(sealers, addr)
+		}
+	}
+	return sealers
+}
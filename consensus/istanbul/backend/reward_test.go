@@ -0,0 +1,157 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package backend
+
+import (
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	istanbulCore "github.com/ethereum/go-ethereum/consensus/istanbul/core"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+func TestApplyRewards(t *testing.T) {
+	var (
+		coinbase   = common.Address{0x01}
+		proposer   = common.Address{0x02}
+		committers = []common.Address{{0x03}, {0x04}, {0x05}}
+		treasury   = common.Address{0x06}
+	)
+	tests := []struct {
+		policy        params.IstanbulRewardConfig
+		committers    []common.Address
+		participation map[common.Address]uint64
+		window        uint64
+		fees          int64
+		balances      map[common.Address]int64
+	}{
+		// Proposer takes the whole reward, fees stay with the coinbase
+		{
+			policy:     params.IstanbulRewardConfig{BlockReward: big.NewInt(1000)},
+			committers: committers,
+			fees:       10,
+			balances:   map[common.Address]int64{coinbase: 10, proposer: 1000},
+		},
+		// Committers split their share, dust goes to the proposer
+		{
+			policy:     params.IstanbulRewardConfig{BlockReward: big.NewInt(1000), SealerShare: 50},
+			committers: committers,
+			balances:   map[common.Address]int64{proposer: 502, committers[0]: 166, committers[1]: 166, committers[2]: 166},
+		},
+		// Committers are paid by participation, the withheld part goes to the proposer
+		{
+			policy:        params.IstanbulRewardConfig{BlockReward: big.NewInt(1000), SealerShare: 60, ParticipationWindow: 10},
+			committers:    committers,
+			participation: map[common.Address]uint64{committers[0]: 10, committers[1]: 5, committers[2]: 0},
+			window:        10,
+			balances:      map[common.Address]int64{proposer: 700, committers[0]: 200, committers[1]: 100, committers[2]: 0},
+		},
+		// Without committers (first block) the proposer gets everything
+		{
+			policy:   params.IstanbulRewardConfig{BlockReward: big.NewInt(1000), SealerShare: 50},
+			balances: map[common.Address]int64{proposer: 1000},
+		},
+		// Fees are burnt
+		{
+			policy:   params.IstanbulRewardConfig{BurnFees: true},
+			fees:     10,
+			balances: map[common.Address]int64{coinbase: 0},
+		},
+		// Fees are moved into the treasury
+		{
+			policy:   params.IstanbulRewardConfig{Treasury: &treasury},
+			fees:     10,
+			balances: map[common.Address]int64{coinbase: 0, treasury: 10},
+		},
+	}
+	for i, tt := range tests {
+		db, _ := ethdb.NewMemDatabase()
+		statedb, _ := state.New(common.Hash{}, state.NewDatabase(db))
+
+		// Simulate the fees credited to the coinbase during execution
+		statedb.AddBalance(coinbase, big.NewInt(tt.fees))
+		applyRewards(&tt.policy, statedb, coinbase, proposer, tt.committers, tt.participation, tt.window, big.NewInt(tt.fees))
+
+		for addr, want := range tt.balances {
+			if have := statedb.GetBalance(addr); have.Cmp(big.NewInt(want)) != 0 {
+				t.Errorf("test %d: balance mismatch for %x: have %v, want %d", i, addr, have, want)
+			}
+		}
+	}
+}
+
+// Tests that blocks carry the committed seals of their parent if the reward
+// policy pays the parent's committers, and that the seals are verified.
+func TestParentCommittedSeals(t *testing.T) {
+	chain, engine := newBlockChain(1)
+	chain.Config().Istanbul.FixedPeriod = 1
+	chain.Config().Istanbul.Reward = &params.IstanbulRewardConfig{BlockReward: big.NewInt(1000), SealerShare: 50}
+
+	parent := chain.Genesis()
+	for i := 1; i <= 2; i++ {
+		block := makeCommittedBlock(t, chain, engine, parent)
+		if _, err := chain.InsertChain(types.Blocks{block}); err != nil {
+			t.Fatalf("failed to insert block %d: %v", i, err)
+		}
+		parent = block
+	}
+	first, second := chain.GetHeaderByNumber(1), chain.GetHeaderByNumber(2)
+
+	sealed, _ := types.ExtractIstanbulExtra(first)
+	extra, _ := types.ExtractIstanbulExtra(second)
+	if !reflect.DeepEqual(extra.ParentCommittedSeal, sealed.CommittedSeal) {
+		t.Fatalf("parent seals mismatch: have %x, want %x", extra.ParentCommittedSeal, sealed.CommittedSeal)
+	}
+	if have := engine.parentSealersOf(chain, second); !reflect.DeepEqual(have, []common.Address{engine.Address()}) {
+		t.Errorf("parent sealers mismatch: have %v, want %v", have, []common.Address{engine.Address()})
+	}
+	// Both blocks paid the whole reward to the single validator
+	state, _ := chain.State()
+	if have := state.GetBalance(engine.Address()); have.Cmp(big.NewInt(2000)) != 0 {
+		t.Errorf("balance mismatch: have %v, want 2000", have)
+	}
+	// Omitted and foreign parent seals are rejected
+	forged, _ := crypto.GenerateKey()
+	seal, _ := crypto.Sign(crypto.Keccak256(istanbulCore.PrepareCommittedSeal(first.Hash())), forged)
+
+	tests := []struct {
+		seals [][]byte
+		err   error
+	}{
+		{sealed.CommittedSeal, nil},
+		{nil, errEmptyParentCommittedSeals},
+		{[][]byte{seal}, errInvalidParentCommittedSeals},
+		{append(sealed.CommittedSeal, sealed.CommittedSeal...), errInvalidParentCommittedSeals},
+	}
+	for i, tt := range tests {
+		header := types.CopyHeader(second)
+		extra.ParentCommittedSeal = tt.seals
+		payload, _ := rlp.EncodeToBytes(extra)
+		header.Extra = append(header.Extra[:types.IstanbulExtraVanity], payload...)
+
+		if err := engine.verifyParentCommittedSeals(chain, header, first, nil); err != tt.err {
+			t.Errorf("test %d: error mismatch: have %v, want %v", i, err, tt.err)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package backend
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// Rewind implements consensus.Rewinder, dropping the consensus state derived from
+// the blocks above the new head of a rewound chain: the epoch snapshots and the
+// upgrade signaling results keyed by number, the cached validator snapshots and
+// the archived messages of the dropped sequences. The validator snapshot of the
+// new head is derived anew and a running core restarted at the sequence after it.
+func (sb *backend) Rewind(chain consensus.ChainReader, head *types.Header) error {
+	number := head.Number.Uint64()
+
+	// Drop the epoch snapshots above the head, which are stored contiguously
+	for epoch := (number/sb.config.Epoch + 1) * sb.config.Epoch; ; epoch += sb.config.Epoch {
+		if ok, _ := sb.db.Has(epochKey(epoch)); !ok {
+			break
+		}
+		if err := sb.db.Delete(epochKey(epoch)); err != nil {
+			return err
+		}
+	}
+	sb.upgradesMu.Lock()
+	for epoch := range sb.upgradeEpochs {
+		if epoch*sb.config.Epoch > number {
+			delete(sb.upgradeEpochs, epoch)
+		}
+	}
+	sb.upgradesMu.Unlock()
+
+	if sb.archive != nil {
+		sb.archive.rewind(number)
+	}
+	// Re-derive the validator snapshot of the new head from scratch
+	sb.recents.Purge()
+	if _, err := sb.snapshot(chain, number, head.Hash(), nil); err != nil {
+		return err
+	}
+	// Restart a running core, which would otherwise wait for a sequence the
+	// chain won't reach anymore
+	sb.coreMu.Lock()
+	defer sb.coreMu.Unlock()
+
+	if !sb.coreStarted {
+		return nil
+	}
+	if err := sb.core.Stop(); err != nil {
+		return err
+	}
+	sb.proposedBlockHash = common.Hash{}
+	if err := sb.core.Start(); err != nil {
+		return err
+	}
+	log.Warn("Restarted consensus after chain rewind", "number", number, "hash", head.Hash())
+	return nil
+}
@@ -0,0 +1,76 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package backend
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// Tests that rewinding the chain drops the consensus state derived from the
+// dropped blocks, while keeping the one of the remaining chain.
+func TestRewind(t *testing.T) {
+	chain, engine := newBlockChain(1)
+	chain.Config().Istanbul.FixedPeriod = 1
+
+	config := *engine.config
+	config.Epoch = 2
+	engine.config = &config
+	engine.archive = newMessageArchive(engine.db, 10)
+
+	parent := chain.Genesis()
+	for i := 1; i <= 7; i++ {
+		block := makeCommittedBlock(t, chain, engine, parent)
+		if _, err := chain.InsertChain(types.Blocks{block}); err != nil {
+			t.Fatalf("failed to insert block %d: %v", i, err)
+		}
+		engine.archive.Store(uint64(i), []byte{byte(i)})
+		parent = block
+	}
+	head := chain.CurrentHeader()
+	if _, err := engine.snapshot(chain, head.Number.Uint64(), head.Hash(), nil); err != nil {
+		t.Fatalf("failed to create snapshot: %v", err)
+	}
+	// Committed blocks are final, rewinding them must be explicitly allowed
+	if err := chain.Rewind(3, false); err != core.ErrFinalizedRewind {
+		t.Fatalf("safe rewind error mismatch: have %v, want %v", err, core.ErrFinalizedRewind)
+	}
+	if err := chain.Rewind(3, true); err != nil {
+		t.Fatalf("failed to rewind chain: %v", err)
+	}
+	for number, want := range map[uint64]bool{2: true, 4: false, 6: false} {
+		if ok, _ := engine.db.Has(epochKey(number)); ok != want {
+			t.Errorf("epoch snapshot %d: presence mismatch: have %v, want %v", number, ok, want)
+		}
+	}
+	for seq, want := range map[uint64]bool{3: true, 4: false, 7: false} {
+		if _, err := engine.archive.Load(seq); (err == nil) != want {
+			t.Errorf("archived sequence %d: presence mismatch: have %v, want %v", seq, err == nil, want)
+		}
+	}
+	head = chain.CurrentHeader()
+	if _, ok := engine.recents.Get(head.Hash()); !ok {
+		t.Errorf("snapshot of the new head not derived")
+	}
+	// The rewound chain must be extendable again
+	block := makeCommittedBlock(t, chain, engine, chain.CurrentBlock())
+	if _, err := chain.InsertChain(types.Blocks{block}); err != nil {
+		t.Fatalf("failed to extend rewound chain: %v", err)
+	}
+}
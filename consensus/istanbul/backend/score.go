@@ -0,0 +1,66 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package backend
+
+import (
+	"errors"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+const (
+	maxPeerScore = 100 // Score of validators not caught misbehaving
+	faultPenalty = 25  // Score deducted for every invalid message
+)
+
+var (
+	// errLowPeerScore is returned when a peer is dropped for sending too many
+	// invalid consensus messages.
+	errLowPeerScore = errors.New("peer score too low")
+
+	penaltyCounter = metrics.NewRegisteredCounter("consensus/istanbul/penalties", nil)
+)
+
+// Penalize implements istanbul.Backend.Penalize, deducting from the score of the
+// validator. Once the score is used up, the peer is dropped on its next message.
+func (sb *backend) Penalize(address common.Address, reason error) {
+	sb.scoresMu.Lock()
+	defer sb.scoresMu.Unlock()
+
+	score, ok := sb.scores[address]
+	if !ok {
+		score = maxPeerScore
+	}
+	sb.scores[address] = score - faultPenalty
+	penaltyCounter.Inc(1)
+
+	sb.logger.Warn("Penalized validator", "address", address, "reason", reason, "score", score-faultPenalty)
+}
+
+// checkScore returns an error if the peer used up its score, resetting it for
+// the peer to start over after reconnecting.
+func (sb *backend) checkScore(address common.Address) error {
+	sb.scoresMu.Lock()
+	defer sb.scoresMu.Unlock()
+
+	if score, ok := sb.scores[address]; ok && score <= 0 {
+		delete(sb.scores, address)
+		return errLowPeerScore
+	}
+	return nil
+}
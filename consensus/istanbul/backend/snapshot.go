@@ -18,6 +18,7 @@ package backend
 
 import (
 	"bytes"
+	"encoding/binary"
 	"encoding/json"
 
 	"github.com/ethereum/go-ethereum/common"
@@ -29,6 +30,7 @@ import (
 
 const (
 	dbKeySnapshotPrefix = "istanbul-snapshot"
+	dbKeyEpochPrefix    = "istanbul-epoch" // Epoch snapshots keyed by big endian block number
 )
 
 // Vote represents a single vote that an authorized validator made to modify the
@@ -96,6 +98,41 @@ func (s *Snapshot) store(db ethdb.Database) error {
 	return db.Put(append([]byte(dbKeySnapshotPrefix), s.Hash[:]...), blob)
 }
 
+// epochKey returns the database key of the epoch snapshot at the given block.
+func epochKey(number uint64) []byte {
+	key := make([]byte, len(dbKeyEpochPrefix)+8)
+	copy(key, dbKeyEpochPrefix)
+	binary.BigEndian.PutUint64(key[len(dbKeyEpochPrefix):], number)
+	return key
+}
+
+// loadEpochSnapshot loads the snapshot stored for the epoch boundary block with
+// the given number.
+func loadEpochSnapshot(epoch uint64, db ethdb.Database, number uint64) (*Snapshot, error) {
+	blob, err := db.Get(epochKey(number))
+	if err != nil {
+		return nil, err
+	}
+	snap := new(Snapshot)
+	if err := json.Unmarshal(blob, snap); err != nil {
+		return nil, err
+	}
+	snap.Epoch = epoch
+
+	return snap, nil
+}
+
+// storeEpoch inserts the snapshot into the database keyed by its block number,
+// so that the validator set of an epoch can be looked up without knowing the
+// hash of its boundary block.
+func (s *Snapshot) storeEpoch(db ethdb.Database) error {
+	blob, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	return db.Put(epochKey(s.Number), blob)
+}
+
 // copy creates a deep copy of the snapshot, though not the individual votes.
 func (s *Snapshot) copy() *Snapshot {
 	cpy := &Snapshot{
@@ -336,7 +336,7 @@ func TestVoting(t *testing.T) {
 			Mixhash:    types.IstanbulDigest,
 		}
 		b := genesis.ToBlock(nil)
-		extra, _ := prepareExtra(b.Header(), validators)
+		extra, _ := prepareExtra(b.Header(), validators, nil)
 		genesis.ExtraData = extra
 		// Create a pristine blockchain with the genesis injected
 		db, _ := ethdb.NewMemDatabase()
@@ -359,7 +359,7 @@ func TestVoting(t *testing.T) {
 				Difficulty: defaultDifficulty,
 				MixDigest:  types.IstanbulDigest,
 			}
-			extra, _ := prepareExtra(headers[j], validators)
+			extra, _ := prepareExtra(headers[j], validators, nil)
 			headers[j].Extra = extra
 			if j > 0 {
 				headers[j].ParentHash = headers[j-1].Hash()
@@ -453,3 +453,48 @@ func TestSaveAndLoad(t *testing.T) {
 		t.Errorf("validator set mismatch: have %v, want %v", snap1.ValSet, snap.ValSet)
 	}
 }
+
+// Tests that the validator set is persisted at every epoch boundary and that
+// these epoch snapshots can be used to look up validators and resume snapshot
+// reconstruction even without the preceding headers.
+func TestEpochSnapshots(t *testing.T) {
+	chain, engine := newBlockChain(1)
+
+	config := *engine.config
+	config.Epoch = 3
+	engine.config = &config
+
+	parent := chain.Genesis()
+	var headers []*types.Header
+	for i := 0; i < 8; i++ {
+		block, _ := engine.updateBlock(parent.Header(), makeBlockWithoutSeal(chain, engine, parent))
+		headers = append(headers, block.Header())
+		parent = block
+	}
+	head := headers[len(headers)-1]
+	if _, err := engine.snapshot(chain, head.Number.Uint64(), head.Hash(), headers); err != nil {
+		t.Fatalf("failed to create snapshot: %v", err)
+	}
+	for _, number := range []uint64{0, 3, 6} {
+		snap, err := loadEpochSnapshot(config.Epoch, engine.db, number)
+		if err != nil {
+			t.Fatalf("epoch snapshot %d missing: %v", number, err)
+		}
+		if vals := snap.validators(); len(vals) != 1 || vals[0] != engine.address {
+			t.Errorf("epoch %d: validators mismatch: have %x, want [%x]", number, vals, engine.address)
+		}
+	}
+	// Blocks unknown to the chain resolve to their epoch's validator set
+	vals, err := engine.GetValidatorsAt(7)
+	if err != nil {
+		t.Fatalf("failed to retrieve validators: %v", err)
+	}
+	if len(vals) != 1 || vals[0] != engine.address {
+		t.Errorf("validators mismatch: have %x, want [%x]", vals, engine.address)
+	}
+	// Reconstruction must stop at the epoch boundary instead of requiring all ancestors
+	engine.recents.Purge()
+	if _, err := engine.snapshot(chain, head.Number.Uint64(), head.Hash(), headers[6:]); err != nil {
+		t.Fatalf("failed to create snapshot from epoch: %v", err)
+	}
+}
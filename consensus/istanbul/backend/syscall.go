@@ -0,0 +1,124 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package backend
+
+import (
+	"errors"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// defaultSystemCallGas is the gas allowance of the system call if none configured.
+const defaultSystemCallGas uint64 = 5000000
+
+var (
+	// systemAddress is the sender of the system contract calls.
+	systemAddress = common.HexToAddress("0xfffffffffffffffffffffffffffffffffffffffe")
+
+	// errNoSystemCall is returned when retrieving the system call receipt of a
+	// block which didn't call the system contract.
+	errNoSystemCall = errors.New("no system call in block")
+)
+
+// chainContext adapts a chain reader to the core.ChainContext needed to run the
+// EVM, the block hashes accessible being the ones of the local chain.
+type chainContext struct {
+	consensus.ChainReader
+	engine consensus.Engine
+}
+
+// Engine implements core.ChainContext, returning the Istanbul engine.
+func (c *chainContext) Engine() consensus.Engine {
+	return c.engine
+}
+
+// applySystemCall calls the configured system contract on top of the block state,
+// returning the receipt of the call or nil if no call is due. The call is made
+// with a zero gas price from the system address, so a failing call only reverts
+// its own changes and never invalidates the block.
+func (sb *backend) applySystemCall(chain consensus.ChainReader, header *types.Header, statedb *state.StateDB, txs []*types.Transaction, receipts []*types.Receipt) *types.Receipt {
+	config := chain.Config().Istanbul
+	if config == nil || config.SystemCall == nil || !config.SystemCall.IsActive(header.Number) {
+		return nil
+	}
+	gas := config.SystemCall.Gas
+	if gas == 0 {
+		gas = defaultSystemCallGas
+	}
+	context := vm.Context{
+		CanTransfer: core.CanTransfer,
+		Transfer:    core.Transfer,
+		GetHash:     core.GetHashFn(header, &chainContext{chain, sb}),
+		Origin:      systemAddress,
+		Coinbase:    header.Coinbase,
+		BlockNumber: new(big.Int).Set(header.Number),
+		Time:        new(big.Int).Set(header.Time),
+		Difficulty:  new(big.Int).Set(header.Difficulty),
+		GasLimit:    header.GasLimit,
+		GasPrice:    new(big.Int),
+	}
+	// The block hash is unknown before sealing, logs are tagged by position only
+	statedb.Prepare(common.Hash{}, common.Hash{}, len(txs))
+
+	evm := vm.NewEVM(context, statedb, chain.Config(), vm.Config{})
+	_, left, err := evm.Call(vm.AccountRef(systemAddress), config.SystemCall.Contract, config.SystemCall.Data, gas, new(big.Int))
+	if err != nil {
+		log.Debug("System contract call failed", "number", header.Number, "contract", config.SystemCall.Contract, "err", err)
+	}
+	var cumulative uint64
+	if len(receipts) > 0 {
+		cumulative = receipts[len(receipts)-1].CumulativeGasUsed
+	}
+	receipt := types.NewReceipt(nil, err != nil, cumulative+gas-left)
+	receipt.GasUsed = gas - left
+	receipt.Logs = statedb.GetLogs(common.Hash{})
+	receipt.Bloom = types.CreateBloom(types.Receipts{receipt})
+
+	return receipt
+}
+
+// systemCall retrieves the receipt of the system call made in a block, stored
+// along with the block receipts after the ones of its transactions.
+func (sb *backend) systemCall(block *types.Block) (*types.Receipt, error) {
+	txs := len(block.Transactions())
+
+	receipts := core.GetBlockReceipts(sb.db, block.Hash(), block.NumberU64())
+	if len(receipts) <= txs {
+		return nil, errNoSystemCall
+	}
+	receipt := receipts[txs]
+
+	// Derive the fields not known when the call was made
+	var index uint
+	for _, r := range receipts[:txs] {
+		index += uint(len(r.Logs))
+	}
+	for i, log := range receipt.Logs {
+		log.BlockNumber = block.NumberU64()
+		log.BlockHash = block.Hash()
+		log.TxIndex = uint(txs)
+		log.Index = index + uint(i)
+	}
+	return receipt, nil
+}
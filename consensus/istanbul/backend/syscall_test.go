@@ -0,0 +1,124 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package backend
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// Tests that the system contract is called when finalizing blocks once activated,
+// its state changes included in the block and its receipt retrievable.
+func TestSystemCall(t *testing.T) {
+	chain, engine := newBlockChain(1)
+	contract := common.Address{0xcc}
+
+	chain.Config().Istanbul.SystemCall = &params.IstanbulSystemCallConfig{
+		Contract: contract,
+		Block:    big.NewInt(2),
+	}
+	for number := int64(1); number <= 2; number++ {
+		parent := chain.Genesis()
+		header := makeHeader(parent, engine.config)
+		header.Number = big.NewInt(number)
+		engine.Prepare(chain, header)
+
+		// Counter incrementing slot 0 and emitting a log on every call
+		state, _ := chain.StateAt(parent.Root())
+		state.SetCode(contract, common.FromHex("0x600054600101600055600060006000a000"))
+
+		block, receipts, err := engine.FinalizeWithReceipts(chain, header, state, nil, nil, nil)
+		if err != nil {
+			t.Fatalf("block %d: failed to finalize: %v", number, err)
+		}
+		if have := types.DeriveSha(types.Receipts(receipts)); have != block.ReceiptHash() {
+			t.Errorf("block %d: receipt root mismatch: have %x, want %x", number, have, block.ReceiptHash())
+		}
+		if number < 2 {
+			if len(receipts) != 0 {
+				t.Errorf("block %d: receipt count mismatch: have %d, want 0", number, len(receipts))
+			}
+			if have := state.GetState(contract, common.Hash{}); have != (common.Hash{}) {
+				t.Errorf("block %d: counter mismatch: have %x, want 0", number, have)
+			}
+			continue
+		}
+		if len(receipts) != 1 {
+			t.Fatalf("block %d: receipt count mismatch: have %d, want 1", number, len(receipts))
+		}
+		// Finalizing must not persist anything, the receipt is stored with the block
+		if _, err := engine.systemCall(block); err != errNoSystemCall {
+			t.Errorf("block %d: error mismatch before writing: have %v, want %v", number, err, errNoSystemCall)
+		}
+		if err := core.WriteBlockReceipts(engine.db, block.Hash(), block.NumberU64(), receipts); err != nil {
+			t.Fatalf("block %d: failed to write receipts: %v", number, err)
+		}
+		receipt, err := engine.systemCall(block)
+		if err != nil {
+			t.Fatalf("block %d: failed to retrieve receipt: %v", number, err)
+		}
+		if have := state.GetState(contract, common.Hash{}); have != common.BigToHash(big.NewInt(1)) {
+			t.Errorf("block %d: counter mismatch: have %x, want 1", number, have)
+		}
+		if receipt.Status != types.ReceiptStatusSuccessful {
+			t.Errorf("block %d: status mismatch: have %d, want %d", number, receipt.Status, types.ReceiptStatusSuccessful)
+		}
+		if receipt.GasUsed == 0 || receipt.CumulativeGasUsed != receipt.GasUsed {
+			t.Errorf("block %d: gas mismatch: used %d, cumulative %d", number, receipt.GasUsed, receipt.CumulativeGasUsed)
+		}
+		if len(receipt.Logs) != 1 || receipt.Logs[0].Address != contract || receipt.Logs[0].BlockHash != block.Hash() {
+			t.Errorf("block %d: logs mismatch: have %v", number, receipt.Logs)
+		}
+	}
+}
+
+// Tests that the receipt of the system call is validated and stored along with
+// the ones of the block transactions when importing blocks.
+func TestSystemCallImport(t *testing.T) {
+	chain, engine := newBlockChain(1)
+	chain.Config().Istanbul.FixedPeriod = 1
+	chain.Config().Istanbul.SystemCall = &params.IstanbulSystemCallConfig{
+		Contract: common.Address{0xcc},
+		Block:    big.NewInt(2),
+	}
+	parent := chain.Genesis()
+	for number := uint64(1); number <= 2; number++ {
+		block := makeBlock(chain, engine, parent)
+		if _, err := chain.InsertChain(types.Blocks{block}); err != nil {
+			t.Fatalf("block %d: failed to insert: %v", number, err)
+		}
+		receipts := chain.GetReceiptsByHash(block.Hash())
+		if number < 2 {
+			if len(receipts) != 0 {
+				t.Errorf("block %d: receipt count mismatch: have %d, want 0", number, len(receipts))
+			}
+		} else {
+			if len(receipts) != 1 {
+				t.Fatalf("block %d: receipt count mismatch: have %d, want 1", number, len(receipts))
+			}
+			if _, err := engine.systemCall(block); err != nil {
+				t.Errorf("block %d: failed to retrieve receipt: %v", number, err)
+			}
+		}
+		parent = block
+	}
+}
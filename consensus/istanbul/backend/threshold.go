@@ -0,0 +1,275 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package backend
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus"
+	istanbulCore "github.com/ethereum/go-ethereum/consensus/istanbul/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/crypto/bls"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+const (
+	dbKeyThresholdSharePrefix = "istanbul-threshold-share" // Threshold key shares keyed by the hash of the group key
+
+	partialSealLength = 2 + bls.SignatureLength // Share index and signature
+)
+
+var (
+	// errNoThresholdShare is returned when a committed seal has to be signed with
+	// the share of a group key the local validator doesn't hold.
+	errNoThresholdShare = errors.New("no key share of the threshold group key")
+	// errInvalidPartialSeal is returned if a partial committed seal is malformed
+	// or wasn't created by the share of the sender.
+	errInvalidPartialSeal = errors.New("invalid partial committed seal")
+	// ErrInvalidThresholdSeal is returned if the threshold committed seal of a
+	// block is malformed or doesn't verify against the group key.
+	ErrInvalidThresholdSeal = errors.New("invalid threshold committed seal")
+)
+
+// thresholdShare is a validator's share of a threshold group key, as stored in
+// the database after a key generation ceremony.
+type thresholdShare struct {
+	GroupKey []byte // Public key of the group the share belongs to
+	Index    uint64 // Index of the share, starting from 1
+	Share    []byte // Marshalled secret key of the share
+}
+
+// thresholdConfig returns the threshold seal configuration if threshold seals
+// are active at the given block, nil otherwise.
+func thresholdConfig(chain consensus.ChainReader, number *big.Int) *params.IstanbulThresholdConfig {
+	config := chain.Config().Istanbul
+	if config == nil || !config.Threshold.IsActive(number) {
+		return nil
+	}
+	return config.Threshold
+}
+
+// SignCommittedSeal implements istanbul.Backend.SignCommittedSeal, signing with
+// the validator's key share once threshold seals are active.
+func (sb *backend) SignCommittedSeal(hash common.Hash, number *big.Int) ([]byte, error) {
+	config := thresholdConfig(sb.chain, number)
+	if config == nil {
+		return sb.Sign(istanbulCore.PrepareCommittedSeal(hash))
+	}
+	share, err := sb.loadThresholdShare(config.GroupKey)
+	if err != nil {
+		return nil, err
+	}
+	key, err := bls.UnmarshalSecretKey(share.Share)
+	if err != nil {
+		return nil, err
+	}
+	seal := make([]byte, partialSealLength)
+	binary.BigEndian.PutUint16(seal, uint16(share.Index))
+	copy(seal[2:], key.Sign(istanbulCore.PrepareCommittedSeal(hash)).Marshal())
+	return seal, nil
+}
+
+// CheckCommittedSeal implements istanbul.Backend.CheckCommittedSeal, checking
+// partial seals against the member's public share once threshold seals are active.
+func (sb *backend) CheckCommittedSeal(hash common.Hash, number *big.Int, address common.Address, seal []byte) error {
+	config := thresholdConfig(sb.chain, number)
+	if config == nil {
+		return sb.CheckSignature(istanbulCore.PrepareCommittedSeal(hash), address, seal)
+	}
+	partial, err := decodePartialSeal(config, seal)
+	if err != nil {
+		return err
+	}
+	if partial.Index != config.MemberIndex(address) {
+		return errInvalidPartialSeal
+	}
+	share, err := bls.UnmarshalPublicKey(config.PublicShares[partial.Index-1])
+	if err != nil {
+		return err
+	}
+	if !share.Verify(istanbulCore.PrepareCommittedSeal(hash), partial.Signature) {
+		return errInvalidPartialSeal
+	}
+	return nil
+}
+
+// writeSeals writes the committed seals gathered by the core into the header,
+// combining them into a single threshold seal once threshold seals are active.
+func (sb *backend) writeSeals(h *types.Header, seals [][]byte) error {
+	config := thresholdConfig(sb.chain, h.Number)
+	if config == nil {
+		return writeCommittedSeals(h, seals)
+	}
+	seal, err := assembleThresholdSeal(config, seals)
+	if err != nil {
+		return err
+	}
+	return setCommittedSeal(h, seal)
+}
+
+// decodePartialSeal splits a partial committed seal into the share index and
+// the signature.
+func decodePartialSeal(config *params.IstanbulThresholdConfig, seal []byte) (*bls.PartialSignature, error) {
+	if len(seal) != partialSealLength {
+		return nil, errInvalidPartialSeal
+	}
+	index := uint64(binary.BigEndian.Uint16(seal))
+	if index == 0 || index > uint64(len(config.Members)) || index > uint64(len(config.PublicShares)) {
+		return nil, errInvalidPartialSeal
+	}
+	sig, err := bls.UnmarshalSignature(seal[2:])
+	if err != nil {
+		return nil, errInvalidPartialSeal
+	}
+	return &bls.PartialSignature{Index: index, Signature: sig}, nil
+}
+
+// assembleThresholdSeal combines the partial seals into the threshold seal of
+// the group, stored in the header as the signature, a bitmap of the members
+// whose partial seals were gathered and the aggregate of those partial seals.
+// The aggregate proves the bitmap, which the group signature alone doesn't as
+// any threshold of members yields the same one.
+func assembleThresholdSeal(config *params.IstanbulThresholdConfig, seals [][]byte) ([][]byte, error) {
+	var (
+		partials = make([]*bls.PartialSignature, 0, len(seals))
+		sigs     = make([]*bls.Signature, 0, len(seals))
+		bitmap   = make([]byte, (len(config.Members)+7)/8)
+	)
+	for _, seal := range seals {
+		partial, err := decodePartialSeal(config, seal)
+		if err != nil {
+			return nil, err
+		}
+		if bitmap[(partial.Index-1)/8]&(1<<((partial.Index-1)%8)) != 0 {
+			continue // duplicate, would be counted twice in the aggregate
+		}
+		partials = append(partials, partial)
+		sigs = append(sigs, partial.Signature)
+		bitmap[(partial.Index-1)/8] |= 1 << ((partial.Index - 1) % 8)
+	}
+	sig, err := bls.Combine(partials, int(config.Threshold))
+	if err != nil {
+		return nil, err
+	}
+	return [][]byte{sig.Marshal(), bitmap, bls.AggregateSignatures(sigs).Marshal()}, nil
+}
+
+// VerifyThresholdSeal checks the threshold committed seal of a block against the
+// group key, and the aggregate of the partial seals against the public shares of
+// the members in the signer bitmap, proving they all committed the block.
+func VerifyThresholdSeal(config *params.IstanbulThresholdConfig, hash common.Hash, seal [][]byte) error {
+	if len(seal) != 3 || len(seal[1]) != (len(config.Members)+7)/8 {
+		return ErrInvalidThresholdSeal
+	}
+	signers := thresholdSealers(config, seal)
+	if uint64(len(signers)) < config.Threshold {
+		return ErrInvalidThresholdSeal
+	}
+	msg := istanbulCore.PrepareCommittedSeal(hash)
+
+	sig, err := bls.UnmarshalSignature(seal[0])
+	if err != nil {
+		return ErrInvalidThresholdSeal
+	}
+	key, err := bls.UnmarshalPublicKey(config.GroupKey)
+	if err != nil {
+		return err
+	}
+	if !key.Verify(msg, sig) {
+		return ErrInvalidThresholdSeal
+	}
+	aggregate, err := bls.UnmarshalSignature(seal[2])
+	if err != nil {
+		return ErrInvalidThresholdSeal
+	}
+	shares := make([]*bls.PublicKey, 0, len(signers))
+	for i := range config.Members {
+		if seal[1][i/8]&(1<<uint(i%8)) == 0 {
+			continue
+		}
+		if i >= len(config.PublicShares) {
+			return ErrInvalidThresholdSeal
+		}
+		share, err := bls.UnmarshalPublicKey(config.PublicShares[i])
+		if err != nil {
+			return err
+		}
+		shares = append(shares, share)
+	}
+	if !bls.AggregatePublicKeys(shares).Verify(msg, aggregate) {
+		return ErrInvalidThresholdSeal
+	}
+	return nil
+}
+
+// thresholdSealers returns the members marked in the signer bitmap of a
+// threshold committed seal. The bitmap is only trustworthy once the seal passed
+// VerifyThresholdSeal.
+func thresholdSealers(config *params.IstanbulThresholdConfig, seal [][]byte) []common.Address {
+	if len(seal) != 3 {
+		return nil
+	}
+	var sealers []common.Address
+	for i, member := range config.Members {
+		if i/8 < len(seal[1]) && seal[1][i/8]&(1<<uint(i%8)) != 0 {
+			sealers = append(sealers, member)
+		}
+	}
+	return sealers
+}
+
+// loadThresholdShare retrieves the validator's share of the given group key.
+func (sb *backend) loadThresholdShare(groupKey []byte) (*thresholdShare, error) {
+	sb.thresholdMu.Lock()
+	defer sb.thresholdMu.Unlock()
+
+	if sb.thresholdShare != nil && bytes.Equal(sb.thresholdShare.GroupKey, groupKey) {
+		return sb.thresholdShare, nil
+	}
+	blob, err := sb.db.Get(append([]byte(dbKeyThresholdSharePrefix), crypto.Keccak256(groupKey)...))
+	if err != nil {
+		return nil, errNoThresholdShare
+	}
+	share := new(thresholdShare)
+	if err := rlp.DecodeBytes(blob, share); err != nil {
+		return nil, err
+	}
+	sb.thresholdShare = share
+	return share, nil
+}
+
+// storeThresholdShare saves the validator's share of a group key.
+func (sb *backend) storeThresholdShare(share *thresholdShare) error {
+	blob, err := rlp.EncodeToBytes(share)
+	if err != nil {
+		return err
+	}
+	if err := sb.db.Put(append([]byte(dbKeyThresholdSharePrefix), crypto.Keccak256(share.GroupKey)...), blob); err != nil {
+		return err
+	}
+	sb.thresholdMu.Lock()
+	sb.thresholdShare = share
+	sb.thresholdMu.Unlock()
+
+	return nil
+}
@@ -0,0 +1,152 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package backend
+
+import (
+	"bytes"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/consensus/istanbul"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// Tests that validators running a key generation end up with shares of the same
+// group key, and that blocks committed by a threshold of them verify against it.
+func TestThresholdSeal(t *testing.T) {
+	const threshold = 3
+
+	chain, engine := newBlockChain(1)
+	chain.Config().Istanbul.FixedPeriod = 1
+
+	// Run a key generation among the validator and three more members
+	members := []*backend{engine}
+	for i := 0; i < 3; i++ {
+		key, _ := crypto.GenerateKey()
+		db, _ := ethdb.NewMemDatabase()
+
+		member := New(istanbul.DefaultConfig, key, db).(*backend)
+		member.chain = chain
+		members = append(members, member)
+	}
+	pubkeys := make([]hexutil.Bytes, len(members))
+	for i, member := range members {
+		pubkeys[i] = crypto.FromECDSAPub(&member.privateKey.PublicKey)
+	}
+	dealings := make([]*KeyDealing, len(members))
+	for i, member := range members {
+		dealing, err := member.dealKeyShares(threshold, pubkeys)
+		if err != nil {
+			t.Fatalf("member %d: failed to deal key shares: %v", i, err)
+		}
+		dealings[i] = dealing
+	}
+	var group *KeyGeneration
+	for i, member := range members {
+		result, err := member.combineKeyShares(dealings, nil)
+		if err != nil {
+			t.Fatalf("member %d: failed to combine key shares: %v", i, err)
+		}
+		if len(result.Qualified) != len(members) || len(result.Disqualified) != 0 {
+			t.Fatalf("member %d: qualification mismatch: have %d/%d qualified/disqualified, want %d/0", i, len(result.Qualified), len(result.Disqualified), len(members))
+		}
+		if group != nil && !bytes.Equal(result.GroupKey, group.GroupKey) {
+			t.Fatalf("member %d: group key mismatch: have %x, want %x", i, result.GroupKey, group.GroupKey)
+		}
+		group = result
+	}
+	// Dealings forged or tampered with must be rejected
+	forged := *dealings[1]
+	forged.Dealer = engine.Address()
+	if _, err := engine.combineKeyShares([]*KeyDealing{dealings[0], &forged}, nil); err != errInvalidDealing {
+		t.Errorf("forged dealing: error mismatch: have %v, want %v", err, errInvalidDealing)
+	}
+	if _, err := engine.combineKeyShares([]*KeyDealing{dealings[0], dealings[0]}, nil); err != errDuplicateDealing {
+		t.Errorf("duplicate dealing: error mismatch: have %v, want %v", err, errDuplicateDealing)
+	}
+	if _, err := engine.combineKeyShares(dealings, group.Members[:2]); err != errTooFewDealings {
+		t.Errorf("excluded dealings: error mismatch: have %v, want %v", err, errTooFewDealings)
+	}
+	chain.Config().Istanbul.Threshold = &params.IstanbulThresholdConfig{
+		Block:        big.NewInt(1),
+		Threshold:    group.Threshold,
+		Members:      group.Members,
+		GroupKey:     group.GroupKey,
+		PublicShares: group.PublicShares,
+	}
+	// Gather the partial seals of all members but the validator itself
+	block, err := engine.updateBlock(chain.Genesis().Header(), makeBlockWithoutSeal(chain, engine, chain.Genesis()))
+	if err != nil {
+		t.Fatalf("failed to seal block: %v", err)
+	}
+	var seals [][]byte
+	for i, member := range members[1:] {
+		seal, err := member.SignCommittedSeal(block.Hash(), block.Number())
+		if err != nil {
+			t.Fatalf("member %d: failed to sign partial seal: %v", i+1, err)
+		}
+		if err := engine.CheckCommittedSeal(block.Hash(), block.Number(), member.Address(), seal); err != nil {
+			t.Errorf("member %d: valid partial seal rejected: %v", i+1, err)
+		}
+		if err := engine.CheckCommittedSeal(block.Hash(), block.Number(), engine.Address(), seal); err != errInvalidPartialSeal {
+			t.Errorf("member %d: partial seal of another member: error mismatch: have %v, want %v", i+1, err, errInvalidPartialSeal)
+		}
+		seals = append(seals, seal)
+	}
+	header := block.Header()
+	if err := engine.writeSeals(header, seals[:threshold-1]); err == nil {
+		t.Errorf("seal assembled from too few partial seals")
+	}
+	if err := engine.writeSeals(header, seals); err != nil {
+		t.Fatalf("failed to assemble threshold seal: %v", err)
+	}
+	extra, _ := types.ExtractIstanbulExtra(header)
+	if len(extra.CommittedSeal) != 3 {
+		t.Fatalf("committed seal count mismatch: have %d, want 3", len(extra.CommittedSeal))
+	}
+	// Members that didn't commit must not be claimed in the signer bitmap
+	claimed := [][]byte{extra.CommittedSeal[0], common.CopyBytes(extra.CommittedSeal[1]), extra.CommittedSeal[2]}
+	claimed[1][0] |= 0x01
+	if err := VerifyThresholdSeal(chain.Config().Istanbul.Threshold, block.Hash(), claimed); err != ErrInvalidThresholdSeal {
+		t.Errorf("claimed sealer: error mismatch: have %v, want %v", err, ErrInvalidThresholdSeal)
+	}
+	block = block.WithSeal(header)
+	if _, err := chain.InsertChain(types.Blocks{block}); err != nil {
+		t.Fatalf("failed to insert threshold sealed block: %v", err)
+	}
+	if sealers := engine.sealersOf(chain, block.Header()); !reflect.DeepEqual(sealers, group.Members[1:]) {
+		t.Errorf("sealers mismatch: have %v, want %v", sealers, group.Members[1:])
+	}
+	// The threshold seal of one block must not verify for another
+	next, err := engine.updateBlock(block.Header(), makeBlockWithoutSeal(chain, engine, block))
+	if err != nil {
+		t.Fatalf("failed to seal block: %v", err)
+	}
+	header = next.Header()
+	if err := setCommittedSeal(header, extra.CommittedSeal); err != nil {
+		t.Fatalf("failed to write committed seal: %v", err)
+	}
+	if err := engine.VerifyHeader(chain, header, false); err != ErrInvalidThresholdSeal {
+		t.Errorf("replayed threshold seal: error mismatch: have %v, want %v", err, ErrInvalidThresholdSeal)
+	}
+}
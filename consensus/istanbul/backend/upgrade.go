@@ -0,0 +1,156 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package backend
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// epochSignals is the set of validators which signaled readiness for each
+// upgrade within an epoch.
+type epochSignals map[[4]byte]map[common.Address]bool
+
+// signalsIn collects the upgrade signals of the proposers of the blocks after
+// from up to and including the given header. Only signals of validators of the
+// set at the given header are counted.
+func (sb *backend) signalsIn(chain consensus.ChainReader, header *types.Header, from uint64) (epochSignals, *Snapshot, error) {
+	snap, err := sb.snapshot(chain, header.Number.Uint64(), header.Hash(), nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	signals := make(epochSignals)
+	for header != nil && header.Number.Uint64() > from {
+		if id, ok := types.IstanbulSignal(header); ok {
+			proposer, err := ecrecover(header)
+			if err != nil {
+				return nil, nil, err
+			}
+			if _, v := snap.ValSet.GetByAddress(proposer); v != nil {
+				if signals[id] == nil {
+					signals[id] = make(map[common.Address]bool)
+				}
+				signals[id][proposer] = true
+			}
+		}
+		header = chain.GetHeader(header.ParentHash, header.Number.Uint64()-1)
+	}
+	return signals, snap, nil
+}
+
+// epochReady returns for each upgrade whether more than two thirds of the
+// validators signaled for it within the given complete epoch. The results are
+// cached, past epochs being final.
+func (sb *backend) epochReady(chain consensus.ChainReader, epoch uint64) (map[[4]byte]bool, error) {
+	sb.upgradesMu.Lock()
+	defer sb.upgradesMu.Unlock()
+
+	if ready, ok := sb.upgradeEpochs[epoch]; ok {
+		return ready, nil
+	}
+	last := chain.GetHeaderByNumber(epoch * sb.config.Epoch)
+	if last == nil {
+		return nil, errUnknownBlock
+	}
+	signals, snap, err := sb.signalsIn(chain, last, (epoch-1)*sb.config.Epoch)
+	if err != nil {
+		return nil, err
+	}
+	ready := make(map[[4]byte]bool)
+	for id, signers := range signals {
+		ready[id] = len(signers) > 2*snap.ValSet.F()
+	}
+	sb.upgradeEpochs[epoch] = ready
+	return ready, nil
+}
+
+// upgradeReady returns the last block of the first complete epoch up to the
+// given block in which the signaling threshold was reached for the upgrade.
+func (sb *backend) upgradeReady(chain consensus.ChainReader, upgrade *params.IstanbulUpgradeConfig, number uint64) (uint64, bool, error) {
+	id := types.IstanbulUpgradeID(upgrade.Name)
+	for epoch := uint64(1); epoch*sb.config.Epoch <= number; epoch++ {
+		ready, err := sb.epochReady(chain, epoch)
+		if err != nil {
+			return 0, false, err
+		}
+		if ready[id] {
+			return epoch * sb.config.Epoch, true, nil
+		}
+	}
+	return 0, false, nil
+}
+
+// upgradeActive returns whether the named upgrade configured for the chain is
+// active at the given block.
+func (sb *backend) upgradeActive(chain consensus.ChainReader, name string, number uint64) bool {
+	upgrade := upgradeConfig(chain, name)
+	if upgrade == nil {
+		return false
+	}
+	ready, ok, err := sb.upgradeReady(chain, upgrade, number)
+	return err == nil && ok && number >= ready+upgrade.Delay
+}
+
+// IsUpgradeActive implements consensus.Istanbul.IsUpgradeActive, returning whether
+// the named protocol upgrade is active at the given block of the local chain.
+func (sb *backend) IsUpgradeActive(name string, number uint64) bool {
+	if sb.chain == nil {
+		return false
+	}
+	return sb.upgradeActive(sb.chain, name, number)
+}
+
+// announceUpgrades logs the activation of the configured upgrades as the chain
+// head passes their activation blocks.
+func (sb *backend) announceUpgrades(chain consensus.ChainReader) {
+	config := chain.Config().Istanbul
+	head := chain.CurrentHeader()
+	if config == nil || head == nil {
+		return
+	}
+	for _, upgrade := range config.Upgrades {
+		sb.upgradesMu.Lock()
+		announced := sb.upgradesActive[upgrade.Name]
+		sb.upgradesMu.Unlock()
+		if announced {
+			continue
+		}
+		if sb.upgradeActive(chain, upgrade.Name, head.Number.Uint64()) {
+			sb.upgradesMu.Lock()
+			sb.upgradesActive[upgrade.Name] = true
+			sb.upgradesMu.Unlock()
+
+			log.Info("Protocol upgrade activated", "name", upgrade.Name, "number", head.Number)
+		}
+	}
+}
+
+// upgradeConfig returns the chain configuration of the named upgrade, or nil if
+// the chain does not define it.
+func upgradeConfig(chain consensus.ChainReader, name string) *params.IstanbulUpgradeConfig {
+	if config := chain.Config().Istanbul; config != nil {
+		for _, upgrade := range config.Upgrades {
+			if upgrade.Name == name {
+				return upgrade
+			}
+		}
+	}
+	return nil
+}
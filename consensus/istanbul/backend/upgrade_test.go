@@ -0,0 +1,107 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package backend
+
+import (
+	"testing"
+
+	istanbulCore "github.com/ethereum/go-ethereum/consensus/istanbul/core"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// Tests that an upgrade activates the configured delay after the end of the
+// first epoch a supermajority of the validators signaled for it in.
+func TestUpgradeActivation(t *testing.T) {
+	chain, engine := newBlockChain(1)
+	chain.Config().Istanbul.FixedPeriod = 1
+	chain.Config().Istanbul.Upgrades = []*params.IstanbulUpgradeConfig{{Name: "test", Delay: 3}}
+
+	config := *engine.config
+	config.Epoch = 2
+	engine.config = &config
+
+	api := &API{chain: chain, istanbul: engine}
+
+	// Skip signaling in the first epoch, signal from the second on until the
+	// upgrade activates
+	parent := chain.Genesis()
+	for i := 1; i <= 8; i++ {
+		if i == 3 {
+			config.UpgradeSignal = "test"
+		}
+		block := makeCommittedBlock(t, chain, engine, parent)
+		if _, ok := types.IstanbulSignal(block.Header()); ok != (i >= 3 && i < 7) {
+			t.Fatalf("block %d: signal mismatch: have %v, want %v", i, ok, i >= 3 && i < 7)
+		}
+		if _, err := chain.InsertChain(types.Blocks{block}); err != nil {
+			t.Fatalf("failed to insert block %d: %v", i, err)
+		}
+		parent = block
+
+		if i == 5 {
+			statuses, err := api.UpgradeStatus()
+			if err != nil {
+				t.Fatalf("failed to retrieve upgrade status: %v", err)
+			}
+			if status := statuses[0]; status.Threshold != 1 || len(status.Signaled) != 1 || status.Signaled[0] != engine.Address() {
+				t.Errorf("signaling mismatch: have %+v", status)
+			}
+		}
+	}
+	for number, want := range map[uint64]bool{4: false, 6: false, 7: true, 8: true} {
+		if active := engine.IsUpgradeActive("test", number); active != want {
+			t.Errorf("block %d: activation mismatch: have %v, want %v", number, active, want)
+		}
+	}
+	if engine.IsUpgradeActive("unknown", 7) {
+		t.Errorf("unconfigured upgrade active")
+	}
+	statuses, err := api.UpgradeStatus()
+	if err != nil {
+		t.Fatalf("failed to retrieve upgrade status: %v", err)
+	}
+	if len(statuses) != 1 {
+		t.Fatalf("status count mismatch: have %d, want 1", len(statuses))
+	}
+	status := statuses[0]
+	if status.Ready == nil || *status.Ready != 4 || status.Activation == nil || *status.Activation != 7 || !status.Active {
+		t.Errorf("activation mismatch: have %+v", status)
+	}
+	if len(status.Signaled) != 0 {
+		t.Errorf("signaling after activation: have %+v", status)
+	}
+}
+
+// makeCommittedBlock creates a block sealed and committed by the single validator
+// of the chain without running the consensus rounds.
+func makeCommittedBlock(t *testing.T, chain *core.BlockChain, engine *backend, parent *types.Block) *types.Block {
+	block, err := engine.updateBlock(parent.Header(), makeBlockWithoutSeal(chain, engine, parent))
+	if err != nil {
+		t.Fatalf("failed to seal block: %v", err)
+	}
+	seal, err := engine.Sign(istanbulCore.PrepareCommittedSeal(block.Hash()))
+	if err != nil {
+		t.Fatalf("failed to sign committed seal: %v", err)
+	}
+	header := block.Header()
+	if err := writeCommittedSeals(header, [][]byte{seal}); err != nil {
+		t.Fatalf("failed to write committed seal: %v", err)
+	}
+	return block.WithSeal(header)
+}
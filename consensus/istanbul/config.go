@@ -16,6 +16,8 @@
 
 package istanbul
 
+import "math/big"
+
 type ProposerPolicy uint64
 
 const (
@@ -23,11 +25,46 @@ const (
 	Sticky
 )
 
+// SigningMode is the domain separation of consensus message signatures. Signing
+// within the domain of the chain (its ID, the engine and the message code) makes
+// messages unusable on other chains the validator keys are used on.
+type SigningMode uint64
+
+const (
+	LegacySigning SigningMode = iota // Sign without domain, accept both forms (while upgrading)
+	DomainSigning                    // Sign within the chain's domain, accept both forms
+	StrictSigning                    // Sign within the chain's domain, reject legacy signatures
+)
+
 type Config struct {
 	RequestTimeout uint64         `toml:",omitempty"` // The timeout for each Istanbul round in milliseconds.
 	BlockPeriod    uint64         `toml:",omitempty"` // Default minimum difference between two consecutive block's timestamps in second
 	ProposerPolicy ProposerPolicy `toml:",omitempty"` // The policy for proposer selection
 	Epoch          uint64         `toml:",omitempty"` // The number of blocks after which to checkpoint and reset the pending votes
+	NTPServers     []string       `toml:",omitempty"` // NTP servers to measure the local clock drift against
+	MaxClockDrift  uint64         `toml:",omitempty"` // Clock drift in milliseconds above which to alert (0 = disabled)
+	RefuseOnDrift  bool           `toml:",omitempty"` // Whether to refuse proposing blocks while the local clock runs ahead too far
+
+	MinRequestTimeout uint64 `toml:",omitempty"` // Lower bound of the adaptive round timeout in milliseconds
+	MaxRequestTimeout uint64 `toml:",omitempty"` // Upper bound of the adaptive round timeout in milliseconds (0 = fixed RequestTimeout)
+
+	EmptyBlockPeriod uint64 `toml:"-"` // Minimum difference between the timestamps of an empty block and its parent in second, filled in from the chain config
+
+	AnnouncePeriod uint64 `toml:",omitempty"` // Interval in seconds between the broadcasts of the finalized head to peers (0 = disabled)
+
+	ArchiveRetention uint64 `toml:",omitempty"` // Number of sequences to archive the consensus messages of for replaying (0 = disabled)
+
+	AuditLog    string `toml:",omitempty"` // Directory to keep the audit trail of the consensus activity in (empty = disabled)
+	AuditFormat string `toml:",omitempty"` // Format of the audit files ("json" or "csv")
+
+	UpgradeSignal string `toml:",omitempty"` // Name of the protocol upgrade to signal readiness for in proposed blocks
+
+	SigningMode SigningMode `toml:",omitempty"` // Domain separation of consensus message signatures
+	ChainID     *big.Int    `toml:"-"`          // Chain the consensus messages are signed for, filled in by the node
+
+	BuildVersion  string   `toml:"-"`          // Client version attested to peers, filled in by the node
+	BuildCommit   string   `toml:"-"`          // Source commit attested to peers, filled in by the node
+	BuildFeatures []string `toml:",omitempty"` // Feature flags attested to peers (e.g. readiness for an upcoming fork)
 }
 
 var DefaultConfig = &Config{
@@ -35,4 +72,6 @@ var DefaultConfig = &Config{
 	BlockPeriod:    1,
 	ProposerPolicy: RoundRobin,
 	Epoch:          30000,
+	MaxClockDrift:  2000,
+	AnnouncePeriod: 5,
 }
@@ -0,0 +1,119 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/istanbul"
+)
+
+// Audit events recorded by the core.
+const (
+	AuditReceived  = "received"  // PRE-PREPARE received from the proposer
+	AuditSent      = "sent"      // Message broadcast by the local validator
+	AuditCommitted = "committed" // Proposal committed by the local validator
+)
+
+// Auditor records the consensus activity of the local validator, e.g. to keep
+// an audit trail for compliance purposes.
+type Auditor interface {
+	// Audit records a single consensus event
+	Audit(record *AuditRecord)
+}
+
+// AuditRecord is a consensus event of the local validator.
+type AuditRecord struct {
+	Time     time.Time      `json:"time"`
+	Event    string         `json:"event"`
+	Code     string         `json:"code,omitempty"` // Type of the received or sent message
+	Sequence uint64         `json:"sequence"`
+	Round    uint64         `json:"round"`
+	Address  common.Address `json:"address"`            // Sender of the message, committer of the proposal
+	Digest   common.Hash    `json:"digest"`             // Hash of the proposal
+	Outcome  string         `json:"outcome,omitempty"`  // Error handling a received message, empty if accepted
+	Duration time.Duration  `json:"duration,omitempty"` // Time from accepting the PRE-PREPARE to committing
+}
+
+// codeNames are the names of the message codes in audit records.
+var codeNames = map[uint64]string{
+	msgPreprepare:  "preprepare",
+	msgPrepare:     "prepare",
+	msgCommit:      "commit",
+	msgRoundChange: "roundchange",
+}
+
+// SetAuditor implements core.Engine.SetAuditor, setting the auditor to record
+// the consensus activity with.
+func (c *core) SetAuditor(auditor Auditor) {
+	c.auditor = auditor
+}
+
+// auditMessage records a received or sent message along with the outcome of
+// handling it.
+func (c *core) auditMessage(event string, msg *message, err error) {
+	if c.auditor == nil {
+		return
+	}
+	record := &AuditRecord{
+		Time:    c.clock.Now(),
+		Event:   event,
+		Code:    codeNames[msg.Code],
+		Address: msg.Address,
+	}
+	switch msg.Code {
+	case msgPreprepare:
+		var preprepare *istanbul.Preprepare
+		if err := msg.Decode(&preprepare); err != nil {
+			return
+		}
+		record.Sequence, record.Round = preprepare.View.Sequence.Uint64(), preprepare.View.Round.Uint64()
+		record.Digest = preprepare.Proposal.Hash()
+	default:
+		var subject *istanbul.Subject
+		if err := msg.Decode(&subject); err != nil {
+			return
+		}
+		record.Sequence, record.Round = subject.View.Sequence.Uint64(), subject.View.Round.Uint64()
+		record.Digest = subject.Digest
+	}
+	if err != nil {
+		record.Outcome = err.Error()
+	}
+	c.auditor.Audit(record)
+}
+
+// auditCommit records the commitment of the current proposal, timed from the
+// acceptance of its PRE-PREPARE.
+func (c *core) auditCommit(proposal istanbul.Proposal) {
+	if c.auditor == nil {
+		return
+	}
+	record := &AuditRecord{
+		Time:     c.clock.Now(),
+		Event:    AuditCommitted,
+		Sequence: c.current.Sequence().Uint64(),
+		Round:    c.current.Round().Uint64(),
+		Address:  c.address,
+		Digest:   proposal.Hash(),
+	}
+	if !c.consensusTimestamp.IsZero() {
+		record.Duration = c.clock.Now().Sub(c.consensusTimestamp)
+	}
+	c.auditor.Audit(record)
+}
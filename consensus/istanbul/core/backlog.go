@@ -162,6 +162,10 @@ func (c *core) processBacklog() {
 			}
 			logger.Trace("Post backlog event", "msg", msg)
 
+			if c.replaying {
+				c.replayBacklog = append(c.replayBacklog, backlogEvent{src: src, msg: msg})
+				continue
+			}
 			go c.sendEvent(backlogEvent{
 				src: src,
 				msg: msg,
@@ -0,0 +1,324 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/istanbul"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+var (
+	// invalidProposalExtra marks the proposals the test backend fails to verify.
+	invalidProposalExtra = []byte("invalid")
+
+	// errInvalidTestProposal is returned when verifying a proposal marked invalid.
+	errInvalidTestProposal = errors.New("invalid test proposal")
+)
+
+// byzantineStrategy is an adversarial behavior of a validator, tampering with
+// the consensus messages it sends.
+type byzantineStrategy interface {
+	// tamper returns the messages to deliver in place of the given one.
+	tamper(backend *testSystemBackend, msg *message) []testDelivery
+}
+
+// reencode returns the payload of a tampered message.
+func reencode(msg *message) []byte {
+	payload, err := msg.Payload()
+	if err != nil {
+		panic(fmt.Sprintf("failed to encode message: %v", err))
+	}
+	return payload
+}
+
+// vote assembles a prepare or commit message of a validator for a proposal.
+func vote(backend *testSystemBackend, code uint64, view *istanbul.View, digest common.Hash) []byte {
+	subject, _ := Encode(&istanbul.Subject{View: view, Digest: digest})
+	msg := &message{
+		Code:          code,
+		Msg:           subject,
+		Address:       backend.address,
+		Signature:     backend.address.Bytes(),
+		CommittedSeal: []byte{},
+	}
+	if code == msgCommit {
+		msg.CommittedSeal, _ = backend.SignCommittedSeal(digest, view.Sequence)
+	}
+	return reencode(msg)
+}
+
+// equivocate is a byzantine strategy sending conflicting proposals and votes to
+// the two halves of the validator set. As proposer, it votes for both of its
+// proposals right away.
+type equivocate struct {
+	conflicts map[common.Hash]common.Hash // Digests of the conflicting proposals sent
+	lock      sync.Mutex
+}
+
+func (s *equivocate) tamper(backend *testSystemBackend, msg *message) []testDelivery {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	// Split the validators into the ones receiving the original and the conflict
+	var first, second []common.Address
+	for i, val := range backend.peers.List() {
+		if i < backend.peers.Size()/2 {
+			first = append(first, val.Address())
+		} else {
+			second = append(second, val.Address())
+		}
+	}
+	conflict := *msg
+
+	switch msg.Code {
+	case msgPreprepare:
+		var preprepare *istanbul.Preprepare
+		if err := msg.Decode(&preprepare); err != nil {
+			return nil
+		}
+		block := preprepare.Proposal.(*types.Block)
+		header := block.Header()
+		header.Time = new(big.Int).Add(header.Time, common.Big1)
+		proposal := block.WithSeal(header)
+
+		if s.conflicts == nil {
+			s.conflicts = make(map[common.Hash]common.Hash)
+		}
+		s.conflicts[block.Hash()] = proposal.Hash()
+		conflict.Msg, _ = Encode(&istanbul.Preprepare{View: preprepare.View, Proposal: proposal})
+
+		deliveries := []testDelivery{
+			{payload: reencode(msg), targets: first},
+			{payload: reencode(&conflict), targets: second},
+		}
+		for _, code := range []uint64{msgPrepare, msgCommit} {
+			deliveries = append(deliveries,
+				testDelivery{payload: vote(backend, code, preprepare.View, block.Hash()), targets: first},
+				testDelivery{payload: vote(backend, code, preprepare.View, proposal.Hash()), targets: second},
+			)
+		}
+		return deliveries
+
+	case msgPrepare, msgCommit:
+		var subject *istanbul.Subject
+		if err := msg.Decode(&subject); err != nil {
+			return nil
+		}
+		digest, ok := s.conflicts[subject.Digest]
+		if !ok {
+			digest = crypto.Keccak256Hash(subject.Digest[:])
+		}
+		conflict.Msg, _ = Encode(&istanbul.Subject{View: subject.View, Digest: digest})
+
+	default:
+		return []testDelivery{{payload: reencode(msg)}}
+	}
+	return []testDelivery{
+		{payload: reencode(msg), targets: first},
+		{payload: reencode(&conflict), targets: second},
+	}
+}
+
+// withholdCommits is a byzantine strategy participating in consensus, but never
+// sending its commits.
+type withholdCommits struct{}
+
+func (withholdCommits) tamper(backend *testSystemBackend, msg *message) []testDelivery {
+	if msg.Code == msgCommit {
+		return nil
+	}
+	return []testDelivery{{payload: reencode(msg)}}
+}
+
+// proposeInvalid is a byzantine strategy replacing its proposals by blocks which
+// fail verification.
+type proposeInvalid struct{}
+
+func (proposeInvalid) tamper(backend *testSystemBackend, msg *message) []testDelivery {
+	if msg.Code == msgPreprepare {
+		var preprepare *istanbul.Preprepare
+		if err := msg.Decode(&preprepare); err != nil {
+			return nil
+		}
+		header := preprepare.Proposal.(*types.Block).Header()
+		header.Extra = invalidProposalExtra
+
+		msg.Msg, _ = Encode(&istanbul.Preprepare{View: preprepare.View, Proposal: types.NewBlockWithHeader(header)})
+	}
+	return []testDelivery{{payload: reencode(msg)}}
+}
+
+// delayMessages is a byzantine strategy holding back the messages of the given
+// types to the given validators.
+type delayMessages struct {
+	codes   []uint64         // Message types to delay
+	targets []common.Address // Validators to delay the messages to
+	delay   time.Duration    // Time to hold back the messages for
+}
+
+func (s *delayMessages) tamper(backend *testSystemBackend, msg *message) []testDelivery {
+	payload := reencode(msg)
+	for _, code := range s.codes {
+		if code != msg.Code {
+			continue
+		}
+		var prompt []common.Address
+		for _, val := range backend.peers.List() {
+			delayed := false
+			for _, target := range s.targets {
+				delayed = delayed || target == val.Address()
+			}
+			if !delayed {
+				prompt = append(prompt, val.Address())
+			}
+		}
+		return []testDelivery{
+			{payload: payload, targets: prompt},
+			{payload: payload, targets: s.targets, delay: s.delay},
+		}
+	}
+	return []testDelivery{{payload: payload}}
+}
+
+// checkSafety verifies that no two honest validators committed different
+// proposals at the same height.
+func (t *testSystem) checkSafety() error {
+	committed := make(map[uint64]common.Hash)
+	for i, backend := range t.backends {
+		if backend.byzantine != nil {
+			continue
+		}
+		for _, msg := range backend.committedMsgs {
+			number, hash := msg.commitProposal.Number().Uint64(), msg.commitProposal.Hash()
+			if have, ok := committed[number]; ok && have != hash {
+				return fmt.Errorf("backend %d: conflicting commit at %d: have %x, others %x", i, number, hash, have)
+			}
+			committed[number] = hash
+		}
+	}
+	return nil
+}
+
+// runByzantine runs a 4 validator system, with the first validator (the proposer
+// of the first round) following the given strategy, until the first block is
+// expected to be committed.
+func runByzantine(strategy byzantineStrategy) *testSystem {
+	sys := NewTestSystemWithBackend(4, 1)
+	for _, backend := range sys.backends {
+		c := backend.engine.(*core)
+		c.roundChangeSet = newRoundChangeSet(c.valSet)
+	}
+	sys.backends[0].byzantine = strategy
+
+	close := sys.Run(true)
+	defer close()
+
+	request := makeBlock(1)
+	for _, backend := range sys.backends {
+		backend.NewRequest(request)
+	}
+	<-time.After(time.Second)
+
+	return sys
+}
+
+// Tests that a proposer sending conflicting proposals and votes can't make the
+// honest validators commit different blocks.
+func TestByzantineEquivocation(t *testing.T) {
+	sys := runByzantine(new(equivocate))
+
+	if err := sys.checkSafety(); err != nil {
+		t.Fatal(err)
+	}
+	// The validators receiving the conflicting proposal form a quorum with the
+	// byzantine proposer and commit it, the others may not.
+	for i, backend := range sys.backends[2:] {
+		if len(backend.committedMsgs) != 1 {
+			t.Errorf("backend %d: the number of executed requests mismatch: have %v, want 1", i+2, len(backend.committedMsgs))
+		}
+	}
+	if n := len(sys.backends[1].committedMsgs); n != 0 {
+		t.Errorf("backend 1: the number of executed requests mismatch: have %v, want 0", n)
+	}
+}
+
+// Tests that a single validator withholding its commits doesn't prevent the
+// others from committing.
+func TestByzantineWithholdCommits(t *testing.T) {
+	sys := runByzantine(withholdCommits{})
+
+	if err := sys.checkSafety(); err != nil {
+		t.Fatal(err)
+	}
+	for i, backend := range sys.backends {
+		if len(backend.committedMsgs) != 1 {
+			t.Errorf("backend %d: the number of executed requests mismatch: have %v, want 1", i, len(backend.committedMsgs))
+		}
+	}
+}
+
+// Tests that invalid proposals are never committed, but rejected in favor of the
+// proposal of the next round.
+func TestByzantineInvalidProposal(t *testing.T) {
+	sys := runByzantine(proposeInvalid{})
+
+	for i, backend := range sys.backends {
+		if len(backend.committedMsgs) != 1 {
+			t.Fatalf("backend %d: the number of executed requests mismatch: have %v, want 1", i, len(backend.committedMsgs))
+		}
+		if hash := backend.committedMsgs[0].commitProposal.Hash(); hash != makeBlock(1).Hash() {
+			t.Errorf("backend %d: committed proposal mismatch: have %x, want %x", i, hash, makeBlock(1).Hash())
+		}
+	}
+}
+
+// Tests that selectively delayed votes only hold back, but don't prevent the
+// commit of the validators they're delayed to.
+func TestByzantineDelayedMessages(t *testing.T) {
+	sys := NewTestSystemWithBackend(4, 1)
+	sys.backends[0].byzantine = &delayMessages{
+		codes:   []uint64{msgPrepare, msgCommit},
+		targets: []common.Address{sys.backends[1].address},
+		delay:   300 * time.Millisecond,
+	}
+	close := sys.Run(true)
+	defer close()
+
+	request := makeBlock(1)
+	for _, backend := range sys.backends {
+		backend.NewRequest(request)
+	}
+	<-time.After(time.Second)
+
+	if err := sys.checkSafety(); err != nil {
+		t.Fatal(err)
+	}
+	for i, backend := range sys.backends {
+		if len(backend.committedMsgs) != 1 {
+			t.Errorf("backend %d: the number of executed requests mismatch: have %v, want 1", i, len(backend.committedMsgs))
+		}
+	}
+}
@@ -0,0 +1,46 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import "time"
+
+// Clock is the source of time of the core, and the scheduler of its timers.
+// Tests replace it to advance time deterministically.
+type Clock interface {
+	// Now returns the current time
+	Now() time.Time
+
+	// AfterFunc calls f in its own goroutine once the duration elapsed
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// Timer is a function call scheduled on a clock.
+type Timer interface {
+	// Stop prevents the call if still pending, returning whether it was
+	Stop() bool
+}
+
+// systemClock is the clock of the operating system.
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) AfterFunc(d time.Duration, f func()) Timer {
+	return time.AfterFunc(d, f)
+}
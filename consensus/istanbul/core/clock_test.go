@@ -0,0 +1,231 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"math/big"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/consensus/istanbul"
+)
+
+// virtualClock is a clock only moving forward when advanced, firing the timers
+// falling due synchronously.
+type virtualClock struct {
+	now    time.Time
+	timers []*virtualTimer
+	lock   sync.Mutex
+}
+
+type virtualTimer struct {
+	at    time.Time
+	fn    func()
+	clock *virtualClock
+}
+
+func newVirtualClock() *virtualClock {
+	return &virtualClock{now: time.Unix(1500000000, 0)}
+}
+
+func (c *virtualClock) Now() time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.now
+}
+
+func (c *virtualClock) AfterFunc(d time.Duration, f func()) Timer {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	t := &virtualTimer{at: c.now.Add(d), fn: f, clock: c}
+	c.timers = append(c.timers, t)
+	return t
+}
+
+func (t *virtualTimer) Stop() bool {
+	c := t.clock
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for i, timer := range c.timers {
+		if timer == t {
+			c.timers = append(c.timers[:i], c.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Advance moves the clock forward, calling the timers falling due in order of
+// their deadline. It returns the number of timers fired.
+func (c *virtualClock) Advance(d time.Duration) int {
+	c.lock.Lock()
+	c.now = c.now.Add(d)
+
+	var due, pending []*virtualTimer
+	for _, t := range c.timers {
+		if t.at.After(c.now) {
+			pending = append(pending, t)
+		} else {
+			due = append(due, t)
+		}
+	}
+	c.timers = pending
+	c.lock.Unlock()
+
+	sort.SliceStable(due, func(i, j int) bool { return due[i].at.Before(due[j].at) })
+	for _, t := range due {
+		t.fn()
+	}
+	return len(due)
+}
+
+// Tests that the round change timeout is scheduled on the clock of the core,
+// backing off exponentially in later rounds, and cancelled by stopTimer.
+func TestRoundChangeTimer(t *testing.T) {
+	config := *istanbul.DefaultConfig
+	config.RequestTimeout = 1000
+
+	sys := NewTestSystemWithBackend(1, 0)
+	c := sys.backends[0].engine.(*core)
+	c.config = &config
+
+	clock := newVirtualClock()
+	c.clock = clock
+
+	// Posting on the mux blocks until read, drain it in the background
+	sub := c.backend.EventMux().Subscribe(timeoutEvent{})
+	defer sub.Unsubscribe()
+
+	timeouts := make(chan struct{}, 16)
+	go func() {
+		for range sub.Chan() {
+			timeouts <- struct{}{}
+		}
+	}()
+	expect := func(n int) {
+		for i := 0; i < n; i++ {
+			select {
+			case <-timeouts:
+			case <-time.After(time.Second):
+				t.Fatalf("timeout event %d missing", i)
+			}
+		}
+		select {
+		case <-timeouts:
+			t.Fatalf("unexpected timeout event")
+		default:
+		}
+	}
+
+	// The first round times out after the request timeout
+	c.newRoundChangeTimer()
+	if want := clock.Now().Add(time.Second); c.roundChangeAt != want {
+		t.Errorf("round change deadline mismatch: have %v, want %v", c.roundChangeAt, want)
+	}
+	if fired := clock.Advance(999 * time.Millisecond); fired != 0 {
+		t.Fatalf("timer fired early: %d", fired)
+	}
+	if fired := clock.Advance(time.Millisecond); fired != 1 {
+		t.Fatalf("fired timers mismatch: have %d, want 1", fired)
+	}
+	expect(1)
+
+	// Later rounds wait for 2^round seconds more
+	c.current.SetRound(big.NewInt(1))
+	c.newRoundChangeTimer()
+	if fired := clock.Advance(2999 * time.Millisecond); fired != 0 {
+		t.Fatalf("timer fired early: %d", fired)
+	}
+	if fired := clock.Advance(time.Millisecond); fired != 1 {
+		t.Fatalf("fired timers mismatch: have %d, want 1", fired)
+	}
+	expect(1)
+
+	// Restarting the timer replaces the pending one
+	c.newRoundChangeTimer()
+	clock.Advance(time.Second)
+	c.newRoundChangeTimer()
+	if fired := clock.Advance(2999 * time.Millisecond); fired != 0 {
+		t.Fatalf("replaced timer fired: %d", fired)
+	}
+	if fired := clock.Advance(time.Millisecond); fired != 1 {
+		t.Fatalf("fired timers mismatch: have %d, want 1", fired)
+	}
+	expect(1)
+
+	// Stopped timers never fire
+	c.newRoundChangeTimer()
+	c.stopTimer()
+	if !c.roundChangeAt.IsZero() {
+		t.Errorf("round change deadline not cleared: %v", c.roundChangeAt)
+	}
+	if fired := clock.Advance(time.Hour); fired != 0 {
+		t.Fatalf("stopped timer fired: %d", fired)
+	}
+	expect(0)
+}
+
+// Tests that the adaptive round change timeout follows the observed latency
+// within the configured bounds, and sticks to the request timeout otherwise.
+func TestAdaptiveRoundChangeTimer(t *testing.T) {
+	config := *istanbul.DefaultConfig
+	config.RequestTimeout = 10000
+	config.BlockPeriod = 1
+
+	sys := NewTestSystemWithBackend(1, 0)
+	c := sys.backends[0].engine.(*core)
+	c.config = &config
+	c.clock = newVirtualClock()
+
+	// Latency is ignored unless the timeout is adaptive
+	c.latency.addRound(500 * time.Millisecond)
+	if have := c.requestTimeout(); have != 10*time.Second {
+		t.Errorf("fixed timeout mismatch: have %v, want %v", have, 10*time.Second)
+	}
+	config.MinRequestTimeout, config.MaxRequestTimeout = 2000, 30000
+	if have, want := c.requestTimeout(), 2500*time.Millisecond; have != want {
+		t.Errorf("adaptive timeout mismatch: have %v, want %v", have, want)
+	}
+	// Slow message round trips dominate fast rounds
+	c.latency.addRTT(time.Second)
+	if have, want := c.requestTimeout(), 10*time.Second; have != want {
+		t.Errorf("round trip timeout mismatch: have %v, want %v", have, want)
+	}
+	// The timeout stays within the configured bounds
+	for i := 0; i < 50; i++ {
+		c.latency.addRound(time.Minute)
+	}
+	if have, want := c.requestTimeout(), 30*time.Second; have != want {
+		t.Errorf("upper bound mismatch: have %v, want %v", have, want)
+	}
+	c.latency = latencyTracker{round: time.Millisecond}
+	config.BlockPeriod = 0
+	if have, want := c.requestTimeout(), 2*time.Second; have != want {
+		t.Errorf("lower bound mismatch: have %v, want %v", have, want)
+	}
+	// Round timers are scheduled with the adaptive timeout
+	c.newRoundChangeTimer()
+	if want := c.clock.Now().Add(2 * time.Second); c.roundChangeAt != want {
+		t.Errorf("round change deadline mismatch: have %v, want %v", c.roundChangeAt, want)
+	}
+	c.stopTimer()
+}
@@ -65,9 +65,20 @@ func (c *core) handleCommit(msg *message, src istanbul.Validator) error {
 	if err := c.verifyCommit(commit, src); err != nil {
 		return err
 	}
+	if err := c.verifyCommittedSeal(commit, msg, src); err != nil {
+		return err
+	}
 
 	c.acceptCommit(msg, src)
 
+	// Validators locked on the proposal in an earlier round skip the PREPARE and
+	// only send COMMIT messages, so count them towards the prepare quorum too.
+	if c.current.GetPrepareOrCommitSize() > 2*c.valSet.F() && c.state.Cmp(StatePrepared) < 0 {
+		c.current.LockHash()
+		c.setState(StatePrepared)
+		c.sendCommit()
+	}
+
 	// Commit the proposal once we have enough COMMIT messages and we are not in the Committed state.
 	//
 	// If we already have a proposal, we may have chance to speed up the consensus process
@@ -94,6 +105,21 @@ func (c *core) verifyCommit(commit *istanbul.Subject, src istanbul.Validator) er
 	return nil
 }
 
+// verifyCommittedSeal verifies that the committed seal piggy-backed on a COMMIT
+// message is the sender's signature of the proposal digest, penalizing senders
+// of forged seals as they would only be caught when inserting the block.
+func (c *core) verifyCommittedSeal(commit *istanbul.Subject, msg *message, src istanbul.Validator) error {
+	if err := c.backend.CheckCommittedSeal(commit.Digest, commit.View.Sequence, src.Address(), msg.CommittedSeal); err != nil {
+		c.logger.Warn("Invalid committed seal in COMMIT", "from", src, "state", c.state, "err", err)
+		if !c.replaying {
+			c.backend.Penalize(src.Address(), errInvalidCommittedSeal)
+		}
+		c.recordInvalid(msg, commit.View, commit.Digest, errInvalidCommittedSeal)
+		return errInvalidCommittedSeal
+	}
+	return nil
+}
+
 func (c *core) acceptCommit(msg *message, src istanbul.Validator) error {
 	logger := c.logger.New("from", src, "state", c.state)
 
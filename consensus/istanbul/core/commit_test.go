@@ -18,6 +18,8 @@ package core
 
 import (
 	"bytes"
+	"crypto/ecdsa"
+	"errors"
 	"math/big"
 	"testing"
 
@@ -323,3 +325,82 @@ func TestVerifyCommit(t *testing.T) {
 		}
 	}
 }
+
+// signingBackend is a test backend checking committed seals against real keys.
+type signingBackend struct {
+	*testSystemBackend
+}
+
+func (b *signingBackend) CheckCommittedSeal(hash common.Hash, number *big.Int, address common.Address, seal []byte) error {
+	signer, err := istanbul.GetSignatureAddress(PrepareCommittedSeal(hash), seal)
+	if err != nil {
+		return err
+	}
+	if signer != address {
+		return errors.New("signer mismatch")
+	}
+	return nil
+}
+
+// Tests that COMMIT messages are only accepted if their committed seal signs the
+// current proposal, and the senders of forged seals are penalized.
+func TestHandleCommitForgedSeal(t *testing.T) {
+	keys := make([]*ecdsa.PrivateKey, 4)
+	addrs := make([]common.Address, len(keys))
+	for i := range keys {
+		keys[i], _ = crypto.GenerateKey()
+		addrs[i] = crypto.PubkeyToAddress(keys[i].PublicKey)
+	}
+	valSet := validator.NewSet(addrs, istanbul.RoundRobin)
+	view := &istanbul.View{Round: big.NewInt(0), Sequence: big.NewInt(1)}
+	digest := newTestProposal().Hash()
+
+	sign := func(key *ecdsa.PrivateKey, hash common.Hash) []byte {
+		seal, _ := crypto.Sign(crypto.Keccak256(PrepareCommittedSeal(hash)), key)
+		return seal
+	}
+	tests := []struct {
+		seal []byte
+		err  error
+	}{
+		{sign(keys[1], digest), nil},                                                // valid seal
+		{sign(keys[2], digest), errInvalidCommittedSeal},                            // seal of another validator
+		{sign(keys[1], common.StringToHash("1234567890")), errInvalidCommittedSeal}, // seal of another proposal
+		{addrs[1].Bytes(), errInvalidCommittedSeal},                                 // garbage seal
+		{[]byte{}, errInvalidCommittedSeal},                                         // missing seal
+	}
+	for i, test := range tests {
+		sys := NewTestSystemWithBackend(1, 0)
+		backend := &signingBackend{sys.backends[0]}
+
+		c := New(backend, istanbul.DefaultConfig).(*core)
+		c.valSet = valSet
+		c.current = newTestRoundState(view, valSet)
+		c.state = StatePreprepared
+
+		subject, _ := Encode(&istanbul.Subject{View: view, Digest: digest})
+		_, src := valSet.GetByAddress(addrs[1])
+		err := c.handleCommit(&message{
+			Code:          msgCommit,
+			Msg:           subject,
+			Address:       addrs[1],
+			Signature:     []byte{},
+			CommittedSeal: test.seal,
+		}, src)
+		if err != test.err {
+			t.Errorf("test %d: error mismatch: have %v, want %v", i, err, test.err)
+		}
+		if test.err == nil {
+			if c.current.Commits.Size() != 1 || len(backend.penalties) != 0 {
+				t.Errorf("test %d: valid commit not accepted: %d commits, %d penalties", i, c.current.Commits.Size(), len(backend.penalties))
+			}
+			continue
+		}
+		if c.current.Commits.Size() != 0 {
+			t.Errorf("test %d: forged commit accepted", i)
+		}
+		if len(backend.penalties) != 1 || backend.penalties[0] != addrs[1] {
+			t.Errorf("test %d: penalties mismatch: have %v, want [%x]", i, backend.penalties, addrs[1])
+		}
+	}
+}
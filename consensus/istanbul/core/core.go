@@ -21,11 +21,11 @@ import (
 	"math"
 	"math/big"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/consensus/istanbul"
-	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/event"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/metrics"
@@ -37,11 +37,13 @@ func New(backend istanbul.Backend, config *istanbul.Config) Engine {
 	c := &core{
 		config:             config,
 		address:            backend.Address(),
+		clock:              systemClock{},
 		state:              StateAcceptRequest,
 		handlerWg:          new(sync.WaitGroup),
 		logger:             log.New("address", backend.Address()),
 		backend:            backend,
 		backlogs:           make(map[istanbul.Validator]*prque.Prque),
+		unreachable:        make(map[common.Address]bool),
 		backlogsMu:         new(sync.Mutex),
 		pendingRequests:    prque.New(),
 		pendingRequestsMu:  new(sync.Mutex),
@@ -61,12 +63,14 @@ type core struct {
 	address common.Address
 	state   State
 	logger  log.Logger
+	clock   Clock // source of time of the timers, replaced in tests
 
 	backend               istanbul.Backend
 	events                *event.TypeMuxSubscription
 	finalCommittedSub     *event.TypeMuxSubscription
 	timeoutSub            *event.TypeMuxSubscription
-	futurePreprepareTimer *time.Timer
+	futurePreprepareTimer Timer
+	futurePreprepareAt    time.Time // deadline of the future preprepare timer, zero if stopped
 
 	valSet                istanbul.ValidatorSet
 	waitingForRoundChange bool
@@ -75,16 +79,32 @@ type core struct {
 	backlogs   map[istanbul.Validator]*prque.Prque
 	backlogsMu *sync.Mutex
 
+	unreachable map[common.Address]bool // validators the last sent message could not be delivered to
+
 	current   *roundState
 	handlerWg *sync.WaitGroup
 
 	roundChangeSet   *roundChangeSet
-	roundChangeTimer *time.Timer
+	roundChangeTimer Timer
+	roundChangeAt    time.Time // deadline of the round change timer, zero if stopped
 
 	pendingRequests   *prque.Prque
 	pendingRequestsMu *sync.Mutex
 
+	maintenance int32 // Flag whether to decline proposing blocks (atomic)
+
+	archive        Archive                     // Archive to persist valid messages into (nil = disabled)
+	auditor        Auditor                     // Auditor to record the consensus activity with (nil = disabled)
+	misbehavior    MisbehaviorLog              // Log to persist detected misbehavior into (nil = disabled)
+	signed         map[signedKey]signedMessage // First PRE-PREPARE, PREPARE and COMMIT seen per sender and round
+	signedSequence uint64                      // Sequence the signed messages belong to
+	replaying      bool                        // Whether the core runs in a replay sandbox
+	replayBacklog  []backlogEvent              // Backlog events to process synchronously when replaying
+	replaySent     []*message                  // Messages broadcast while handling the current replayed one
+
 	consensusTimestamp time.Time
+	prepareTimestamp   time.Time      // time the PREPARE of the current round was sent, zero if none
+	latency            latencyTracker // observed consensus latencies for the adaptive timeout
 	// the meter to record the round change rate
 	roundMeter metrics.Meter
 	// the meter to record the sequence update rate
@@ -102,15 +122,15 @@ func (c *core) finalizeMessage(msg *message) ([]byte, error) {
 	msg.CommittedSeal = []byte{}
 	// Assign the CommittedSeal if it's a COMMIT message and proposal is not nil
 	if msg.Code == msgCommit && c.current.Proposal() != nil {
-		seal := PrepareCommittedSeal(c.current.Proposal().Hash())
-		msg.CommittedSeal, err = c.backend.Sign(seal)
+		proposal := c.current.Proposal()
+		msg.CommittedSeal, err = c.backend.SignCommittedSeal(proposal.Hash(), proposal.Number())
 		if err != nil {
 			return nil, err
 		}
 	}
 
 	// Sign message
-	data, err := msg.PayloadNoSig()
+	data, err := c.signingPayload(msg)
 	if err != nil {
 		return nil, err
 	}
@@ -136,12 +156,16 @@ func (c *core) broadcast(msg *message) {
 		logger.Error("Failed to finalize message", "msg", msg, "err", err)
 		return
 	}
+	if c.replaying {
+		c.replaySent = append(c.replaySent, msg)
+	}
 
 	// Broadcast payload
-	if err = c.backend.Broadcast(c.valSet, payload); err != nil {
+	if err = c.backend.Broadcast(c.valSet, payload, c.deliveryCallback(msg)); err != nil {
 		logger.Error("Failed to broadcast message", "msg", msg, "err", err)
 		return
 	}
+	c.auditMessage(AuditSent, msg, nil)
 }
 
 func (c *core) currentView() *istanbul.View {
@@ -159,6 +183,21 @@ func (c *core) isProposer() bool {
 	return v.IsProposer(c.backend.Address())
 }
 
+// SetMaintenance implements core.Engine.SetMaintenance, toggling whether the
+// validator declines to act as proposer. While in maintenance, the validator
+// keeps voting but requests a round change whenever it would have to propose.
+func (c *core) SetMaintenance(enabled bool) {
+	if enabled {
+		atomic.StoreInt32(&c.maintenance, 1)
+	} else {
+		atomic.StoreInt32(&c.maintenance, 0)
+	}
+}
+
+func (c *core) inMaintenance() bool {
+	return atomic.LoadInt32(&c.maintenance) == 1
+}
+
 func (c *core) commit() {
 	c.setState(StateCommitted)
 
@@ -166,8 +205,7 @@ func (c *core) commit() {
 	if proposal != nil {
 		committedSeals := make([][]byte, c.current.Commits.Size())
 		for i, v := range c.current.Commits.Values() {
-			committedSeals[i] = make([]byte, types.IstanbulExtraSeal)
-			copy(committedSeals[i][:], v.CommittedSeal[:])
+			committedSeals[i] = common.CopyBytes(v.CommittedSeal)
 		}
 
 		if err := c.backend.Commit(proposal, committedSeals); err != nil {
@@ -175,6 +213,7 @@ func (c *core) commit() {
 			c.sendNextRoundChange()
 			return
 		}
+		c.auditCommit(proposal)
 	}
 }
 
@@ -197,7 +236,8 @@ func (c *core) startNewRound(round *big.Int) {
 		c.sequenceMeter.Mark(new(big.Int).Add(diff, common.Big1).Int64())
 
 		if !c.consensusTimestamp.IsZero() {
-			c.consensusTimer.UpdateSince(c.consensusTimestamp)
+			c.consensusTimer.Update(c.clock.Now().Sub(c.consensusTimestamp))
+			c.latency.addRound(c.clock.Now().Sub(c.consensusTimestamp))
 			c.consensusTimestamp = time.Time{}
 		}
 		logger.Trace("Catch up latest proposal", "number", lastProposal.Number().Uint64(), "hash", lastProposal.Hash())
@@ -274,6 +314,8 @@ func (c *core) catchUpRound(view *istanbul.View) {
 
 // updateRoundState updates round state by checking if locking block is necessary
 func (c *core) updateRoundState(view *istanbul.View, validatorSet istanbul.ValidatorSet, roundChange bool) {
+	c.prepareTimestamp = time.Time{}
+
 	// Lock only if both roundChange is true and it is locked
 	if roundChange && c.current != nil {
 		if c.current.IsHashLocked() {
@@ -304,6 +346,7 @@ func (c *core) stopFuturePreprepareTimer() {
 	if c.futurePreprepareTimer != nil {
 		c.futurePreprepareTimer.Stop()
 	}
+	c.futurePreprepareAt = time.Time{}
 }
 
 func (c *core) stopTimer() {
@@ -311,19 +354,30 @@ func (c *core) stopTimer() {
 	if c.roundChangeTimer != nil {
 		c.roundChangeTimer.Stop()
 	}
+	c.roundChangeAt = time.Time{}
 }
 
 func (c *core) newRoundChangeTimer() {
 	c.stopTimer()
 
+	// Timeouts can't be reproduced when replaying
+	if c.replaying {
+		return
+	}
+
 	// set timeout based on the round number
-	timeout := time.Duration(c.config.RequestTimeout) * time.Millisecond
+	timeout := c.requestTimeout()
 	round := c.current.Round().Uint64()
 	if round > 0 {
 		timeout += time.Duration(math.Pow(2, float64(round))) * time.Second
+	} else if c.config.EmptyBlockPeriod > c.config.BlockPeriod {
+		// The proposer may be holding back an empty block, don't mistake an idle
+		// network for a faulty proposer
+		timeout += time.Duration(c.config.EmptyBlockPeriod) * time.Second
 	}
 
-	c.roundChangeTimer = time.AfterFunc(timeout, func() {
+	c.roundChangeAt = c.clock.Now().Add(timeout)
+	c.roundChangeTimer = c.clock.AfterFunc(timeout, func() {
 		c.sendEvent(timeoutEvent{})
 	})
 }
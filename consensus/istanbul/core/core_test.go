@@ -19,6 +19,7 @@ package core
 import (
 	"math/big"
 	"reflect"
+	"sync"
 	"testing"
 	"time"
 
@@ -76,3 +77,220 @@ func TestNewRequest(t *testing.T) {
 		}
 	}
 }
+
+func TestMaintenanceMode(t *testing.T) {
+	N := uint64(4)
+	F := uint64(1)
+
+	sys := NewTestSystemWithBackend(N, F)
+	for _, backend := range sys.backends {
+		c := backend.engine.(*core)
+		c.roundChangeSet = newRoundChangeSet(c.valSet)
+
+		config := *c.config
+		config.RequestTimeout = 500
+		c.config = &config
+
+		// The first round is set up by hand, arm its timer too
+		c.newRoundChangeTimer()
+	}
+	close := sys.Run(true)
+	defer close()
+
+	// Put the proposer of the first round into maintenance, and ensure the block
+	// is committed by the proposer of the next round
+	sys.backends[0].engine.SetMaintenance(true)
+
+	request := makeBlock(1)
+	for _, backend := range sys.backends[1:] {
+		backend.NewRequest(request)
+	}
+	<-time.After(100 * time.Millisecond)
+	sys.backends[0].NewRequest(request)
+
+	<-time.After(2 * time.Second)
+
+	for i, backend := range sys.backends {
+		if len(backend.committedMsgs) != 1 {
+			t.Fatalf("backend %d: the number of executed requests mismatch: have %v, want 1", i, len(backend.committedMsgs))
+		}
+		if have := backend.committedMsgs[0].commitProposal.Number(); have.Cmp(request.Number()) != 0 {
+			t.Errorf("backend %d: committed number mismatch: have %v, want %v", i, have, request.Number())
+		}
+	}
+	for _, payload := range sys.backends[0].sentMsgs {
+		msg := new(message)
+		if err := msg.FromPayload(payload, nil); err != nil {
+			t.Fatalf("failed to decode sent message: %v", err)
+		}
+		if msg.Code == msgPreprepare {
+			t.Errorf("proposal sent in maintenance mode")
+		}
+	}
+}
+
+// Tests that a state dump reflects the view, state and timers of the core.
+func TestDumpState(t *testing.T) {
+	N := uint64(4)
+	F := uint64(1)
+
+	sys := NewTestSystemWithBackend(N, F)
+	close := sys.Run(true)
+	defer close()
+
+	// Commit a block for the next round to arm the round change timer
+	request := makeBlock(1)
+	for _, backend := range sys.backends {
+		backend.NewRequest(request)
+	}
+	<-time.After(time.Second)
+
+	engine := sys.backends[0].engine
+	engine.SetMaintenance(true)
+
+	dump := engine.Dump()
+	if dump == nil {
+		t.Fatalf("no state dump from running core")
+	}
+	if dump.Sequence.Uint64() != 2 || dump.Round.Sign() != 0 {
+		t.Errorf("view mismatch: have %v/%v, want 2/0", dump.Sequence, dump.Round)
+	}
+	if dump.State != StateAcceptRequest.String() {
+		t.Errorf("state mismatch: have %v, want %v", dump.State, StateAcceptRequest)
+	}
+	if !dump.Maintenance {
+		t.Errorf("maintenance mode not reported")
+	}
+	if dump.RoundChangeDeadline == nil || !dump.RoundChangeDeadline.After(time.Now()) {
+		t.Errorf("round change deadline mismatch: have %v", dump.RoundChangeDeadline)
+	}
+	if dump.FuturePreprepareDeadline != nil {
+		t.Errorf("unexpected future preprepare deadline: %v", dump.FuturePreprepareDeadline)
+	}
+}
+
+// testArchive is an in memory consensus message archive.
+type testArchive struct {
+	payloads map[uint64][][]byte
+	lock     sync.Mutex
+}
+
+func (a *testArchive) Store(sequence uint64, payload []byte) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	a.payloads[sequence] = append(a.payloads[sequence], payload)
+}
+
+func TestReplay(t *testing.T) {
+	N := uint64(4)
+	F := uint64(1)
+
+	sys := NewTestSystemWithBackend(N, F)
+	archive := &testArchive{payloads: make(map[uint64][][]byte)}
+	sys.backends[1].engine.SetArchive(archive)
+
+	close := sys.Run(true)
+	request := makeBlock(1)
+	for _, backend := range sys.backends {
+		backend.NewRequest(request)
+	}
+	<-time.After(time.Second)
+	close()
+
+	if len(sys.backends[1].committedMsgs) != 1 {
+		t.Fatalf("the number of executed requests mismatch: have %v, want 1", len(sys.backends[1].committedMsgs))
+	}
+	archive.lock.Lock()
+	payloads := archive.payloads[1]
+	archive.lock.Unlock()
+
+	// Replay the archived messages on a sandboxed backend of the same validator
+	replaySys := newTestSystem(N)
+	backend := replaySys.NewBackend(1)
+	backend.peers = sys.backends[1].peers
+	backend.address = sys.backends[1].address
+
+	go func() {
+		for range replaySys.queuedMessage {
+		}
+	}()
+	c := New(backend, istanbul.DefaultConfig).(*core)
+	c.logger = testLogger
+	c.validateFn = backend.CheckValidatorSignature
+
+	result := c.replay(payloads)
+
+	if len(result.Steps) != len(payloads) {
+		t.Fatalf("replay steps mismatch: have %d, want %d", len(result.Steps), len(payloads))
+	}
+	for i, step := range result.Steps {
+		if step.Message == nil {
+			t.Errorf("step %d: message not decoded", i)
+		}
+	}
+	if result.Committed == nil || *result.Committed != request.Hash() {
+		t.Errorf("committed proposal mismatch: have %v, want %v", result.Committed, request.Hash())
+	}
+	if len(backend.committedMsgs) != 1 {
+		t.Errorf("the number of replayed commits mismatch: have %v, want 1", len(backend.committedMsgs))
+	}
+}
+
+// testAuditor is an in memory consensus auditor.
+type testAuditor struct {
+	records []*AuditRecord
+	lock    sync.Mutex
+}
+
+func (a *testAuditor) Audit(record *AuditRecord) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	a.records = append(a.records, record)
+}
+
+// Tests that the received proposal, the sent votes and the commitment of a
+// sequence are all recorded by the auditor.
+func TestAudit(t *testing.T) {
+	N := uint64(4)
+	F := uint64(1)
+
+	sys := NewTestSystemWithBackend(N, F)
+	auditor := new(testAuditor)
+	backend := sys.backends[1]
+	backend.engine.SetAuditor(auditor)
+
+	close := sys.Run(true)
+	defer close()
+
+	request := makeBlock(1)
+	for _, backend := range sys.backends {
+		backend.NewRequest(request)
+	}
+	<-time.After(time.Second)
+
+	auditor.lock.Lock()
+	defer auditor.lock.Unlock()
+
+	var events []string
+	for _, record := range auditor.records {
+		if record.Sequence != 1 || record.Round != 0 || record.Digest != request.Hash() {
+			t.Errorf("record %s/%s: subject mismatch: have %d/%d/%x, want 1/0/%x", record.Event, record.Code, record.Sequence, record.Round, record.Digest, request.Hash())
+		}
+		if record.Outcome != "" {
+			t.Errorf("record %s/%s: unexpected outcome: %s", record.Event, record.Code, record.Outcome)
+		}
+		events = append(events, record.Event+"/"+record.Code)
+	}
+	want := []string{"received/preprepare", "sent/prepare", "sent/commit", "committed/"}
+	if !reflect.DeepEqual(events, want) {
+		t.Fatalf("audited events mismatch: have %v, want %v", events, want)
+	}
+	if sender := auditor.records[0].Address; sender != sys.backends[0].Address() {
+		t.Errorf("preprepare sender mismatch: have %x, want %x", sender, sys.backends[0].Address())
+	}
+	if committer := auditor.records[3].Address; committer != backend.Address() {
+		t.Errorf("committer mismatch: have %x, want %x", committer, backend.Address())
+	}
+}
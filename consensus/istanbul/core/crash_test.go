@@ -0,0 +1,125 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/consensus/istanbul"
+)
+
+// crashAt is a fault crashing the validator when it sends its first message of
+// the given type, either right after or right before the message gets out.
+type crashAt struct {
+	code uint64 // Message type to crash at
+	sent bool   // Whether the message is delivered before the crash
+	once sync.Once
+}
+
+func (s *crashAt) tamper(backend *testSystemBackend, msg *message) []testDelivery {
+	if msg.Code != s.code {
+		return []testDelivery{{payload: reencode(msg)}}
+	}
+	crashed := false
+	s.once.Do(func() {
+		backend.crash()
+		crashed = true
+	})
+	if crashed && !s.sent {
+		return nil
+	}
+	return []testDelivery{{payload: reencode(msg)}}
+}
+
+// runCrash runs a 4 validator system with the last validator offline throughout,
+// the first validator (the proposer of the first round) crashing at the given
+// point and restarting after a while. Without the proposer the remaining two
+// validators can't reach a quorum, so the request is only committed once the
+// restarted proposer joined the view change.
+func runCrash(t *testing.T, fault *crashAt) *testSystem {
+	config := *istanbul.DefaultConfig
+	config.RequestTimeout = 200
+
+	sys := NewTestSystemWithBackend(4, 1)
+	for _, backend := range sys.backends {
+		c := backend.engine.(*core)
+		c.config = &config
+		c.roundChangeSet = newRoundChangeSet(c.valSet)
+
+		// The first round is set up by hand, arm its timer too
+		c.newRoundChangeTimer()
+	}
+	atomic.StoreInt32(&sys.backends[3].down, 1)
+	sys.backends[0].byzantine = fault
+
+	close := sys.Run(true)
+	defer close()
+
+	request := makeBlock(1)
+	for _, backend := range sys.backends {
+		backend.NewRequest(request)
+	}
+	<-time.After(300 * time.Millisecond)
+
+	if !sys.backends[0].isDown() {
+		t.Fatalf("proposer did not crash")
+	}
+	for i, backend := range sys.backends[:3] {
+		if n := len(backend.committedMsgs); n != 0 {
+			t.Fatalf("backend %d: committed without quorum: have %d requests", i, n)
+		}
+	}
+	sys.backends[0].restart(&config)
+	sys.backends[0].NewRequest(request)
+
+	// Wait for the validators to agree on the next round
+	<-time.After(3 * time.Second)
+	return sys
+}
+
+// checkRecovered verifies that the online validators committed the original
+// request after the view change.
+func checkRecovered(t *testing.T, sys *testSystem) {
+	if err := sys.checkSafety(); err != nil {
+		t.Fatal(err)
+	}
+	want := makeBlock(1).Hash()
+	for i, backend := range sys.backends[:3] {
+		if len(backend.committedMsgs) != 1 {
+			t.Fatalf("backend %d: the number of executed requests mismatch: have %v, want 1", i, len(backend.committedMsgs))
+		}
+		if hash := backend.committedMsgs[0].commitProposal.Hash(); hash != want {
+			t.Errorf("backend %d: committed proposal mismatch: have %x, want %x", i, hash, want)
+		}
+	}
+}
+
+// Tests that a proposer crashing right after sending its preprepare doesn't stop
+// the request from being committed in a later round.
+func TestCrashAfterPreprepare(t *testing.T) {
+	checkRecovered(t, runCrash(t, &crashAt{code: msgPreprepare, sent: true}))
+}
+
+// Tests that a proposer crashing after reaching the prepare quorum, but before
+// sending its commit, doesn't stop the proposal the others locked on from being
+// committed in a later round.
+func TestCrashAfterPrepareQuorum(t *testing.T) {
+	checkRecovered(t, runCrash(t, &crashAt{code: msgCommit}))
+}
@@ -0,0 +1,70 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/istanbul"
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+// undeliverableMeter counts the rounds abandoned early because the proposal
+// could not be delivered to a quorum.
+var undeliverableMeter = metrics.NewRegisteredMeter("consensus/istanbul/core/undeliverable", nil)
+
+// deliveryCallback returns the callback feeding the delivery report of a message
+// sent in the current view back into the event loop.
+func (c *core) deliveryCallback(msg *message) istanbul.DeliveryCallback {
+	if c.replaying {
+		return nil
+	}
+	ev := deliveryEvent{code: msg.Code, view: c.currentView()}
+	return func(undelivered []common.Address) {
+		ev.undelivered = undelivered
+		c.sendEvent(ev)
+	}
+}
+
+// handleDelivery tracks the validators the core's messages can't be delivered
+// to. If the proposer finds its PRE-PREPARE undeliverable to a quorum, it's cut
+// off from the rest of the network and moves on to the next round right away
+// instead of waiting out the round timeout.
+func (c *core) handleDelivery(ev deliveryEvent) {
+	if c.current == nil {
+		return
+	}
+	undelivered := make(map[common.Address]bool)
+	for _, addr := range ev.undelivered {
+		undelivered[addr] = true
+	}
+	c.unreachable = make(map[common.Address]bool)
+	for _, val := range c.valSet.List() {
+		if addr := val.Address(); addr != c.address && undelivered[addr] {
+			c.unreachable[addr] = true
+		}
+	}
+	if ev.code != msgPreprepare || ev.view.Cmp(c.currentView()) != 0 || c.state == StateCommitted || c.waitingForRoundChange {
+		return
+	}
+	reachable := c.valSet.Size() - len(c.unreachable)
+	if reachable > 2*c.valSet.F() {
+		return
+	}
+	c.logger.Warn("Proposal undeliverable to a quorum, changing round", "view", ev.view, "reachable", reachable, "unreachable", len(c.unreachable))
+	undeliverableMeter.Mark(1)
+	c.sendNextRoundChange()
+}
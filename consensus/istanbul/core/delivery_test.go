@@ -0,0 +1,74 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Tests that a proposer unable to deliver its PRE-PREPARE to a quorum moves on
+// to the next round right away, while one reaching a quorum carries on despite
+// the unreachable validators.
+func TestUndeliverableProposal(t *testing.T) {
+	tests := []struct {
+		down      []int
+		committed bool
+	}{
+		{down: []int{3}, committed: true},
+		{down: []int{2, 3}, committed: false},
+	}
+	for i, test := range tests {
+		sys := NewTestSystemWithBackend(4, 1)
+		for _, backend := range sys.backends {
+			c := backend.engine.(*core)
+			c.roundChangeSet = newRoundChangeSet(c.valSet)
+		}
+		var unreachable []common.Address
+		for _, idx := range test.down {
+			atomic.StoreInt32(&sys.backends[idx].down, 1)
+			unreachable = append(unreachable, sys.backends[idx].address)
+		}
+		close := sys.Run(true)
+
+		request := makeBlock(1)
+		for _, backend := range sys.backends {
+			backend.NewRequest(request)
+		}
+		<-time.After(300 * time.Millisecond)
+
+		proposer := sys.backends[0]
+		dump := proposer.engine.Dump()
+		if dump == nil {
+			t.Fatalf("test %d: no state dump from running core", i)
+		}
+		if committed := len(proposer.committedMsgs) == 1; committed != test.committed {
+			t.Errorf("test %d: commit mismatch: have %v, want %v", i, committed, test.committed)
+		}
+		if !test.committed && !dump.WaitingForRoundChange {
+			t.Errorf("test %d: proposer still waiting for the round timeout", i)
+		}
+		if !test.committed && !reflect.DeepEqual(dump.Unreachable, unreachable) {
+			t.Errorf("test %d: unreachable validators mismatch: have %v, want %v", i, dump.Unreachable, unreachable)
+		}
+		close()
+	}
+}
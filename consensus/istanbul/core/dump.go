@@ -0,0 +1,163 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"bytes"
+	"math/big"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// dumpTimeout is the maximum time to wait for the core to snapshot its state.
+const dumpTimeout = time.Second
+
+// MessageDump is the JSON representation of a consensus message.
+type MessageDump struct {
+	Code          uint64         `json:"code"`
+	Address       common.Address `json:"address"`
+	Msg           hexutil.Bytes  `json:"msg"`
+	Signature     hexutil.Bytes  `json:"signature"`
+	CommittedSeal hexutil.Bytes  `json:"committedSeal,omitempty"`
+}
+
+// StateDump is a snapshot of the consensus state of the core, including every
+// message received for the current sequence.
+type StateDump struct {
+	Sequence     *big.Int                  `json:"sequence"`
+	Round        *big.Int                  `json:"round"`
+	State        string                    `json:"state"`
+	Proposer     common.Address            `json:"proposer"`
+	LockedHash   common.Hash               `json:"lockedHash"`
+	Proposal     *common.Hash              `json:"proposal"`
+	Prepares     []*MessageDump            `json:"prepares"`
+	Commits      []*MessageDump            `json:"commits"`
+	RoundChanges map[uint64][]*MessageDump `json:"roundChanges"`
+
+	WaitingForRoundChange    bool                        `json:"waitingForRoundChange"`
+	Maintenance              bool                        `json:"maintenance"`
+	RoundChangeVotes         map[uint64][]common.Address `json:"roundChangeVotes"` // Senders of the pending round changes by round
+	Backlogs                 map[common.Address]int      `json:"backlogs"`         // Number of future messages queued by validator
+	Unreachable              []common.Address            `json:"unreachable"`      // Validators the last sent message could not be delivered to
+	PendingRequests          int                         `json:"pendingRequests"`
+	RoundChangeDeadline      *time.Time                  `json:"roundChangeDeadline,omitempty"`
+	FuturePreprepareDeadline *time.Time                  `json:"futurePreprepareDeadline,omitempty"`
+}
+
+// dumpEvent requests a state snapshot from the core's event loop.
+type dumpEvent struct {
+	result chan *StateDump
+}
+
+// Dump implements core.Engine.Dump, returning a snapshot of the consensus state
+// or nil if the core is not running or too busy to respond.
+func (c *core) Dump() *StateDump {
+	result := make(chan *StateDump, 1)
+	go c.sendEvent(dumpEvent{result: result})
+
+	select {
+	case dump := <-result:
+		return dump
+	case <-time.After(dumpTimeout):
+		return nil
+	}
+}
+
+// dumpState snapshots the consensus state. It must only be called from the
+// event loop.
+func (c *core) dumpState() *StateDump {
+	if c.current == nil {
+		return nil
+	}
+	dump := &StateDump{
+		Sequence:     new(big.Int).Set(c.current.Sequence()),
+		Round:        new(big.Int).Set(c.current.Round()),
+		State:        c.state.String(),
+		LockedHash:   c.current.GetLockedHash(),
+		Prepares:     dumpMessages(c.current.Prepares.Values()),
+		Commits:      dumpMessages(c.current.Commits.Values()),
+		RoundChanges: make(map[uint64][]*MessageDump),
+
+		WaitingForRoundChange: c.waitingForRoundChange,
+		Maintenance:           atomic.LoadInt32(&c.maintenance) == 1,
+		RoundChangeVotes:      make(map[uint64][]common.Address),
+		Backlogs:              make(map[common.Address]int),
+	}
+	if c.valSet != nil && c.valSet.GetProposer() != nil {
+		dump.Proposer = c.valSet.GetProposer().Address()
+	}
+	if proposal := c.current.Proposal(); proposal != nil {
+		hash := proposal.Hash()
+		dump.Proposal = &hash
+	}
+	if c.roundChangeSet != nil {
+		c.roundChangeSet.mu.Lock()
+		for round, msgs := range c.roundChangeSet.roundChanges {
+			dump.RoundChanges[round] = dumpMessages(msgs.Values())
+			for _, msg := range msgs.Values() {
+				dump.RoundChangeVotes[round] = append(dump.RoundChangeVotes[round], msg.Address)
+			}
+		}
+		c.roundChangeSet.mu.Unlock()
+	}
+	c.backlogsMu.Lock()
+	for src, backlog := range c.backlogs {
+		dump.Backlogs[src.Address()] = backlog.Size()
+	}
+	c.backlogsMu.Unlock()
+
+	dump.Unreachable = make([]common.Address, 0, len(c.unreachable))
+	for addr := range c.unreachable {
+		dump.Unreachable = append(dump.Unreachable, addr)
+	}
+	sort.Slice(dump.Unreachable, func(i, j int) bool {
+		return bytes.Compare(dump.Unreachable[i][:], dump.Unreachable[j][:]) < 0
+	})
+
+	c.pendingRequestsMu.Lock()
+	dump.PendingRequests = c.pendingRequests.Size()
+	c.pendingRequestsMu.Unlock()
+
+	if !c.roundChangeAt.IsZero() {
+		deadline := c.roundChangeAt
+		dump.RoundChangeDeadline = &deadline
+	}
+	if !c.futurePreprepareAt.IsZero() {
+		deadline := c.futurePreprepareAt
+		dump.FuturePreprepareDeadline = &deadline
+	}
+	return dump
+}
+
+// dumpMessages converts a list of consensus messages into their JSON form.
+func dumpMessages(msgs []*message) []*MessageDump {
+	dumps := make([]*MessageDump, 0, len(msgs))
+	for _, msg := range msgs {
+		dumps = append(dumps, &MessageDump{
+			Code:          msg.Code,
+			Address:       msg.Address,
+			Msg:           msg.Msg,
+			Signature:     msg.Signature,
+			CommittedSeal: msg.CommittedSeal,
+		})
+	}
+	return dumps
+}
@@ -33,6 +33,9 @@ var (
 	// errOldMessage is returned when the received message's view is earlier
 	// than current view.
 	errOldMessage = errors.New("old message")
+	// errInvalidSigner is returned when a message is signed by another validator
+	// than the one it claims to come from.
+	errInvalidSigner = errors.New("message signer does not match sender")
 	// errInvalidMessage is returned when the message is malformed.
 	errInvalidMessage = errors.New("invalid message")
 	// errFailedDecodePreprepare is returned when the PRE-PREPARE message is malformed.
@@ -41,6 +44,12 @@ var (
 	errFailedDecodePrepare = errors.New("failed to decode PREPARE")
 	// errFailedDecodeCommit is returned when the COMMIT message is malformed.
 	errFailedDecodeCommit = errors.New("failed to decode COMMIT")
+	// errInvalidCommittedSeal is returned when the committed seal of a COMMIT
+	// message does not sign the proposal digest for the current view.
+	errInvalidCommittedSeal = errors.New("invalid committed seal")
+	// errLockedProposal is reported when a PRE-PREPARE is declined because the
+	// validator is locked on another proposal.
+	errLockedProposal = errors.New("locked on another proposal")
 	// errFailedDecodeMessageSet is returned when the message set is malformed.
 	errFailedDecodeMessageSet = errors.New("failed to decode message set")
 )
@@ -17,6 +17,7 @@
 package core
 
 import (
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/consensus/istanbul"
 )
 
@@ -26,3 +27,11 @@ type backlogEvent struct {
 }
 
 type timeoutEvent struct{}
+
+// deliveryEvent reports the validators a message sent by the core in the given
+// view could not be delivered to.
+type deliveryEvent struct {
+	code        uint64
+	view        *istanbul.View
+	undelivered []common.Address
+}
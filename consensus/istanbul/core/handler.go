@@ -54,6 +54,8 @@ func (c *core) subscribeEvents() {
 		istanbul.MessageEvent{},
 		// internal events
 		backlogEvent{},
+		deliveryEvent{},
+		dumpEvent{},
 	)
 	c.timeoutSub = c.backend.EventMux().Subscribe(
 		timeoutEvent{},
@@ -97,7 +99,7 @@ func (c *core) handleEvents() {
 				}
 			case istanbul.MessageEvent:
 				if err := c.handleMsg(ev.Payload); err == nil {
-					c.backend.Gossip(c.valSet, ev.Payload)
+					c.backend.Gossip(c.valSet, ev.Payload, nil)
 				}
 			case backlogEvent:
 				// No need to check signature for internal messages
@@ -107,8 +109,12 @@ func (c *core) handleEvents() {
 						c.logger.Warn("Get message payload failed", "err", err)
 						continue
 					}
-					c.backend.Gossip(c.valSet, p)
+					c.backend.Gossip(c.valSet, p, nil)
 				}
+			case deliveryEvent:
+				c.handleDelivery(ev)
+			case dumpEvent:
+				ev.result <- c.dumpState()
 			}
 		case _, ok := <-c.timeoutSub.Chan():
 			if !ok {
@@ -137,10 +143,21 @@ func (c *core) handleMsg(payload []byte) error {
 
 	// Decode message and check its signature
 	msg := new(message)
-	if err := msg.FromPayload(payload, c.validateFn); err != nil {
+	if err := msg.FromPayload(payload, nil); err != nil {
 		logger.Error("Failed to decode message from payload", "err", err)
 		return err
 	}
+	signer, err := c.checkMessageSignature(msg)
+	if err != nil {
+		logger.Error("Invalid message signature", "msg", msg, "err", err)
+		return err
+	}
+	// The claimed sender is only trusted if it signed the message itself, as
+	// penalties and evidence are attributed to it
+	if signer != msg.Address {
+		logger.Error("Message signer mismatch", "msg", msg, "signer", signer)
+		return errInvalidSigner
+	}
 
 	// Only accept message if the address is valid
 	_, src := c.valSet.GetByAddress(msg.Address)
@@ -148,6 +165,10 @@ func (c *core) handleMsg(payload []byte) error {
 		logger.Error("Invalid address in message", "msg", msg)
 		return istanbul.ErrUnauthorizedAddress
 	}
+	if c.archive != nil {
+		c.archiveMessage(msg, payload)
+	}
+	c.checkEquivocation(msg, payload)
 
 	return c.handleCheckedMsg(msg, src)
 }
@@ -166,7 +187,11 @@ func (c *core) handleCheckedMsg(msg *message, src istanbul.Validator) error {
 
 	switch msg.Code {
 	case msgPreprepare:
-		return testBacklog(c.handlePreprepare(msg, src))
+		err := c.handlePreprepare(msg, src)
+		if err != nil && err != errFutureMessage {
+			c.auditMessage(AuditReceived, msg, err)
+		}
+		return testBacklog(err)
 	case msgPrepare:
 		return testBacklog(c.handlePrepare(msg, src))
 	case msgCommit:
@@ -197,6 +222,9 @@ func (c *core) handleTimeoutMsg() {
 		c.logger.Trace("round change timeout, catch up latest sequence", "number", lastProposal.Number().Uint64())
 		c.startNewRound(common.Big0)
 	} else {
+		if !c.waitingForRoundChange {
+			c.recordTimeout()
+		}
 		c.sendNextRoundChange()
 	}
 }
@@ -125,3 +125,42 @@ func TestHandleMsg(t *testing.T) {
 		t.Errorf("error mismatch: have %v, want nil", err)
 	}
 }
+
+// Tests that a validator can't pass its messages off as another's, getting the
+// victim penalized for forged committed seals or backlogged in its name.
+func TestHandleMsgSpoofedAddress(t *testing.T) {
+	sys := NewTestSystemWithBackend(4, 1)
+
+	v0, attacker, victim := sys.backends[0], sys.backends[1], sys.backends[2]
+	r0 := v0.engine.(*core)
+
+	// Accept COMMITs of the current sequence, so a forged seal would be penalized
+	r0.current = newTestRoundState(&istanbul.View{Sequence: big.NewInt(1), Round: big.NewInt(0)}, r0.valSet)
+	r0.state = StatePreprepared
+	digest := r0.current.Proposal().Hash()
+
+	for _, sequence := range []int64{1, 2} {
+		subject, _ := Encode(&istanbul.Subject{
+			View:   &istanbul.View{Sequence: big.NewInt(sequence), Round: big.NewInt(0)},
+			Digest: digest,
+		})
+		msg := &message{
+			Code:          msgCommit,
+			Msg:           subject,
+			Address:       victim.Address(),
+			CommittedSeal: []byte{0xde, 0xad},
+		}
+		msg.Signature, _ = attacker.Sign(nil)
+		payload, _ := msg.Payload()
+
+		if err := r0.handleMsg(payload); err != errInvalidSigner {
+			t.Errorf("sequence %d: error mismatch: have %v, want %v", sequence, err, errInvalidSigner)
+		}
+	}
+	if len(v0.penalties) != 0 {
+		t.Errorf("penalties recorded for spoofed messages: %v", v0.penalties)
+	}
+	if len(r0.backlogs) != 0 {
+		t.Errorf("spoofed future message backlogged")
+	}
+}
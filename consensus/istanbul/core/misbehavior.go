@@ -0,0 +1,207 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/consensus/istanbul"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// Kinds of misbehavior detected by the core.
+const (
+	MisbehaviorInvalid      = "invalid"      // Message failing validation, e.g. a forged committed seal or an invalid proposal
+	MisbehaviorEquivocation = "equivocation" // Conflicting messages signed for the same view
+	MisbehaviorTimeout      = "timeout"      // Proposer not proposing before the round timed out
+)
+
+// MisbehaviorLog persists evidence of validators deviating from the protocol,
+// e.g. for governance to vote them out.
+type MisbehaviorLog interface {
+	// Record persists a single misbehavior
+	Record(m *Misbehavior)
+}
+
+// Misbehavior is evidence of a validator deviating from the protocol.
+type Misbehavior struct {
+	Time     time.Time       `json:"time"`
+	Kind     string          `json:"kind"`
+	Address  common.Address  `json:"address"`            // Misbehaving validator
+	Sequence uint64          `json:"sequence"`           // Number of the block being agreed on
+	Round    uint64          `json:"round"`              // Round of the consensus on the block
+	Code     string          `json:"code,omitempty"`     // Type of the offending messages
+	Digest   common.Hash     `json:"digest"`             // Proposal referenced by the offending message, if any
+	Reason   string          `json:"reason,omitempty"`   // Validation failure of an invalid message
+	Evidence []hexutil.Bytes `json:"evidence,omitempty"` // Signed offending messages, two for equivocations
+}
+
+// signedKey identifies the messages a validator may sign only once per view.
+type signedKey struct {
+	code    uint64
+	address common.Address
+	round   uint64
+}
+
+// signedMessage is the first message seen signed for a view.
+type signedMessage struct {
+	digest      common.Hash
+	payload     []byte
+	equivocated bool // Whether a conflicting message was recorded already
+}
+
+// VerifyEvidence recovers the signer of a message kept as misbehavior evidence,
+// failing unless it is the validator the message claims to come from. Both
+// signing forms are accepted, as evidence outlives changes of the signing mode.
+func VerifyEvidence(config *istanbul.Config, evidence []byte) (common.Address, error) {
+	msg := new(message)
+	if err := rlp.DecodeBytes(evidence, msg); err != nil {
+		return common.Address{}, err
+	}
+	payload, err := msg.PayloadNoSig()
+	if err != nil {
+		return common.Address{}, err
+	}
+	signer, err := istanbul.GetSignatureAddress(append(signingDomain(config.ChainID, msg.Code), payload...), msg.Signature)
+	if err != nil || signer != msg.Address {
+		signer, err = istanbul.GetSignatureAddress(payload, msg.Signature)
+	}
+	if err != nil {
+		return common.Address{}, err
+	}
+	if signer != msg.Address {
+		return common.Address{}, errInvalidSigner
+	}
+	return signer, nil
+}
+
+// SetMisbehaviorLog implements core.Engine.SetMisbehaviorLog, setting the log to
+// persist detected misbehavior into.
+func (c *core) SetMisbehaviorLog(log MisbehaviorLog) {
+	c.misbehavior = log
+}
+
+// recordMisbehavior persists the misbehavior of a validator, unless running in a
+// replay sandbox.
+func (c *core) recordMisbehavior(m *Misbehavior) {
+	if c.misbehavior == nil || c.replaying {
+		return
+	}
+	m.Time = c.clock.Now()
+	c.logger.Warn("Validator misbehaved", "kind", m.Kind, "address", m.Address, "seq", m.Sequence, "round", m.Round, "reason", m.Reason)
+	c.misbehavior.Record(m)
+}
+
+// recordInvalid records a signed message which failed validation.
+func (c *core) recordInvalid(msg *message, view *istanbul.View, digest common.Hash, reason error) {
+	if c.misbehavior == nil || c.replaying {
+		return
+	}
+	m := &Misbehavior{
+		Kind:     MisbehaviorInvalid,
+		Address:  msg.Address,
+		Sequence: view.Sequence.Uint64(),
+		Round:    view.Round.Uint64(),
+		Code:     codeNames[msg.Code],
+		Digest:   digest,
+		Reason:   reason.Error(),
+	}
+	if payload, err := msg.Payload(); err == nil {
+		m.Evidence = []hexutil.Bytes{payload}
+	}
+	c.recordMisbehavior(m)
+}
+
+// recordTimeout records the proposer of the current round failing to propose
+// before the round timed out.
+func (c *core) recordTimeout() {
+	if c.misbehavior == nil || c.current == nil || c.current.Preprepare != nil {
+		return
+	}
+	proposer := c.valSet.GetProposer()
+	if proposer == nil {
+		return
+	}
+	c.recordMisbehavior(&Misbehavior{
+		Kind:     MisbehaviorTimeout,
+		Address:  proposer.Address(),
+		Sequence: c.current.Sequence().Uint64(),
+		Round:    c.current.Round().Uint64(),
+	})
+}
+
+// checkEquivocation compares a PRE-PREPARE, PREPARE or COMMIT of the current
+// sequence with the one the sender signed before for the same view, recording
+// the sender as equivocating if they reference different proposals. The message
+// must have been checked to be signed by its sender, as the evidence is filed
+// under msg.Address.
+func (c *core) checkEquivocation(msg *message, payload []byte) {
+	if c.misbehavior == nil || c.replaying || c.current == nil {
+		return
+	}
+	var (
+		view   *istanbul.View
+		digest common.Hash
+	)
+	switch msg.Code {
+	case msgPreprepare:
+		var preprepare *istanbul.Preprepare
+		if err := msg.Decode(&preprepare); err != nil {
+			return
+		}
+		view, digest = preprepare.View, preprepare.Proposal.Hash()
+	case msgPrepare, msgCommit:
+		var subject *istanbul.Subject
+		if err := msg.Decode(&subject); err != nil {
+			return
+		}
+		view, digest = subject.View, subject.Digest
+	default:
+		return
+	}
+	if view.Sequence.Cmp(c.current.Sequence()) != 0 {
+		return
+	}
+	// Only the messages of the current sequence are kept around
+	if c.signedSequence != view.Sequence.Uint64() {
+		c.signed = make(map[signedKey]signedMessage)
+		c.signedSequence = view.Sequence.Uint64()
+	}
+	key := signedKey{code: msg.Code, address: msg.Address, round: view.Round.Uint64()}
+	prev, ok := c.signed[key]
+	if !ok {
+		c.signed[key] = signedMessage{digest: digest, payload: payload}
+		return
+	}
+	if prev.digest == digest || prev.equivocated {
+		return
+	}
+	c.recordMisbehavior(&Misbehavior{
+		Kind:     MisbehaviorEquivocation,
+		Address:  msg.Address,
+		Sequence: view.Sequence.Uint64(),
+		Round:    view.Round.Uint64(),
+		Code:     codeNames[msg.Code],
+		Digest:   digest,
+		Evidence: []hexutil.Bytes{prev.payload, payload},
+	})
+	// Record the equivocation of a view once only
+	prev.equivocated = true
+	c.signed[key] = prev
+}
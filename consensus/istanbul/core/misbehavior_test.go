@@ -0,0 +1,152 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/istanbul"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// testMisbehaviorLog collects the recorded misbehavior in memory.
+type testMisbehaviorLog struct {
+	records []*Misbehavior
+}
+
+func (l *testMisbehaviorLog) Record(m *Misbehavior) {
+	l.records = append(l.records, m)
+}
+
+// newMisbehaviorTestCore creates a core of the first of four validators, in the
+// first round of the first sequence.
+func newMisbehaviorTestCore() (*core, *testMisbehaviorLog) {
+	sys := NewTestSystemWithBackend(4, 1)
+	c := sys.backends[0].engine.(*core)
+
+	view := &istanbul.View{Round: big.NewInt(0), Sequence: big.NewInt(1)}
+	c.current = newTestRoundState(view, c.valSet)
+
+	log := new(testMisbehaviorLog)
+	c.SetMisbehaviorLog(log)
+	return c, log
+}
+
+// Tests that validators signing conflicting messages for the same view are
+// recorded as equivocating once, with both messages as evidence.
+func TestEquivocation(t *testing.T) {
+	c, log := newMisbehaviorTestCore()
+	sender := c.valSet.GetByIndex(1).Address()
+
+	send := func(code uint64, round int64, sequence int64, digest common.Hash) []byte {
+		view := &istanbul.View{Round: big.NewInt(round), Sequence: big.NewInt(sequence)}
+		subject, _ := Encode(&istanbul.Subject{View: view, Digest: digest})
+		msg := &message{Code: code, Msg: subject, Address: sender, Signature: []byte{}, CommittedSeal: []byte{}}
+		payload, _ := msg.Payload()
+		c.checkEquivocation(msg, payload)
+		return payload
+	}
+	first := send(msgPrepare, 0, 1, common.HexToHash("0x01"))
+	send(msgPrepare, 0, 1, common.HexToHash("0x01")) // repeated message
+	send(msgCommit, 0, 1, common.HexToHash("0x02"))  // different message type
+	send(msgPrepare, 1, 1, common.HexToHash("0x02")) // different round
+	send(msgPrepare, 0, 2, common.HexToHash("0x02")) // future sequence
+	if len(log.records) != 0 {
+		t.Fatalf("consistent messages recorded as misbehavior: %+v", log.records)
+	}
+	second := send(msgPrepare, 0, 1, common.HexToHash("0x03"))
+	send(msgPrepare, 0, 1, common.HexToHash("0x04"))
+
+	if len(log.records) != 1 {
+		t.Fatalf("equivocation record count mismatch: have %d, want 1", len(log.records))
+	}
+	record := log.records[0]
+	if record.Kind != MisbehaviorEquivocation || record.Address != sender || record.Sequence != 1 || record.Round != 0 || record.Code != "prepare" {
+		t.Errorf("equivocation record mismatch: %+v", record)
+	}
+	if len(record.Evidence) != 2 || !bytes.Equal(record.Evidence[0], first) || !bytes.Equal(record.Evidence[1], second) {
+		t.Errorf("equivocation evidence mismatch: %x", record.Evidence)
+	}
+}
+
+// Tests that the proposer of a round is recorded for timing out only if no
+// proposal was accepted in the round.
+func TestTimeoutMisbehavior(t *testing.T) {
+	c, log := newMisbehaviorTestCore()
+
+	c.current.SetPreprepare(&istanbul.Preprepare{View: c.currentView(), Proposal: newTestProposal()})
+	c.recordTimeout()
+	if len(log.records) != 0 {
+		t.Fatalf("timeout recorded despite accepted proposal: %+v", log.records)
+	}
+	c.current.SetPreprepare(nil)
+	c.recordTimeout()
+	if len(log.records) != 1 {
+		t.Fatalf("timeout record count mismatch: have %d, want 1", len(log.records))
+	}
+	if record := log.records[0]; record.Kind != MisbehaviorTimeout || record.Address != c.valSet.GetProposer().Address() || record.Sequence != 1 {
+		t.Errorf("timeout record mismatch: %+v", record)
+	}
+}
+
+// Tests that nothing is recorded when replaying archived messages.
+func TestMisbehaviorReplaying(t *testing.T) {
+	c, log := newMisbehaviorTestCore()
+	c.replaying = true
+
+	c.recordTimeout()
+	if len(log.records) != 0 {
+		t.Errorf("misbehavior recorded when replaying: %+v", log.records)
+	}
+}
+
+// Tests that the signer of evidence is recovered from the stored message in
+// either signing form, and evidence claiming another sender is rejected.
+func TestVerifyEvidence(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	addr := crypto.PubkeyToAddress(key.PublicKey)
+	config := &istanbul.Config{ChainID: big.NewInt(1)}
+
+	evidence := func(sender common.Address, domained bool) []byte {
+		subject, _ := Encode(&istanbul.Subject{
+			View:   &istanbul.View{Round: big.NewInt(0), Sequence: big.NewInt(1)},
+			Digest: common.StringToHash("1234567890"),
+		})
+		msg := &message{Code: msgPrepare, Msg: subject, Address: sender}
+		data, _ := msg.PayloadNoSig()
+		if domained {
+			data = append(signingDomain(config.ChainID, msg.Code), data...)
+		}
+		msg.Signature, _ = crypto.Sign(crypto.Keccak256(data), key)
+		payload, _ := msg.Payload()
+		return payload
+	}
+	for _, domained := range []bool{false, true} {
+		if signer, err := VerifyEvidence(config, evidence(addr, domained)); err != nil || signer != addr {
+			t.Errorf("domained %v: signer mismatch: have %x (%v), want %x", domained, signer, err, addr)
+		}
+		if _, err := VerifyEvidence(config, evidence(common.HexToAddress("0x01"), domained)); err != errInvalidSigner {
+			t.Errorf("domained %v: spoofed evidence error mismatch: have %v, want %v", domained, err, errInvalidSigner)
+		}
+	}
+	if _, err := VerifyEvidence(config, []byte{0x01}); err == nil {
+		t.Errorf("malformed evidence verified")
+	}
+}
@@ -18,6 +18,7 @@ package core
 
 import (
 	"reflect"
+	"time"
 
 	"github.com/ethereum/go-ethereum/consensus/istanbul"
 )
@@ -31,6 +32,7 @@ func (c *core) sendPrepare() {
 		logger.Error("Failed to encode", "subject", sub)
 		return
 	}
+	c.prepareTimestamp = c.clock.Now()
 	c.broadcast(&message{
 		Code: msgPrepare,
 		Msg:  encodedSubject,
@@ -61,6 +63,10 @@ func (c *core) handlePrepare(msg *message, src istanbul.Validator) error {
 	// and we are in earlier state before Prepared state.
 	if ((c.current.IsHashLocked() && prepare.Digest == c.current.GetLockedHash()) || c.current.GetPrepareOrCommitSize() > 2*c.valSet.F()) &&
 		c.state.Cmp(StatePrepared) < 0 {
+		if !c.prepareTimestamp.IsZero() {
+			c.latency.addRTT(c.clock.Now().Sub(c.prepareTimestamp))
+			c.prepareTimestamp = time.Time{}
+		}
 		c.current.LockHash()
 		c.setState(StatePrepared)
 		c.sendCommit()
@@ -17,8 +17,6 @@
 package core
 
 import (
-	"time"
-
 	"github.com/ethereum/go-ethereum/consensus"
 	"github.com/ethereum/go-ethereum/consensus/istanbul"
 )
@@ -28,6 +26,14 @@ func (c *core) sendPreprepare(request *istanbul.Request) {
 
 	// If I'm the proposer and I have the same sequence with the proposal
 	if c.current.Sequence().Cmp(request.Proposal.Number()) == 0 && c.isProposer() {
+		// If I'm in maintenance though, hand over to the next proposer right away
+		if c.inMaintenance() {
+			if !c.waitingForRoundChange {
+				logger.Info("Declining to propose in maintenance mode", "seq", c.current.Sequence(), "round", c.current.Round())
+				c.sendNextRoundChange()
+			}
+			return
+		}
 		curView := c.currentView()
 		preprepare, err := Encode(&istanbul.Preprepare{
 			View:     curView,
@@ -86,13 +92,19 @@ func (c *core) handlePreprepare(msg *message, src istanbul.Validator) error {
 		// if it's a future block, we will handle it again after the duration
 		if err == consensus.ErrFutureBlock {
 			c.stopFuturePreprepareTimer()
-			c.futurePreprepareTimer = time.AfterFunc(duration, func() {
+			c.futurePreprepareAt = c.clock.Now().Add(duration)
+			c.futurePreprepareTimer = c.clock.AfterFunc(duration, func() {
 				c.sendEvent(backlogEvent{
 					src: src,
 					msg: msg,
 				})
 			})
+		} else if veto, ok := err.(*istanbul.VetoError); ok {
+			c.sendNextRoundChangeWithReason(veto.Reason)
 		} else {
+			if err != consensus.ErrUnknownAncestor {
+				c.recordInvalid(msg, preprepare.View, preprepare.Proposal.Hash(), err)
+			}
 			c.sendNextRoundChange()
 		}
 		return err
@@ -104,27 +116,32 @@ func (c *core) handlePreprepare(msg *message, src istanbul.Validator) error {
 		if c.current.IsHashLocked() {
 			if preprepare.Proposal.Hash() == c.current.GetLockedHash() {
 				// Broadcast COMMIT and enters Prepared state directly
+				c.auditMessage(AuditReceived, msg, nil)
 				c.acceptPreprepare(preprepare)
 				c.setState(StatePrepared)
 				c.sendCommit()
 			} else {
 				// Send round change
+				c.auditMessage(AuditReceived, msg, errLockedProposal)
 				c.sendNextRoundChange()
 			}
 		} else {
 			// Either
 			//   1. the locked proposal and the received proposal match
 			//   2. we have no locked proposal
+			c.auditMessage(AuditReceived, msg, nil)
 			c.acceptPreprepare(preprepare)
 			c.setState(StatePreprepared)
 			c.sendPrepare()
 		}
+	} else {
+		c.auditMessage(AuditReceived, msg, errIgnored)
 	}
 
 	return nil
 }
 
 func (c *core) acceptPreprepare(preprepare *istanbul.Preprepare) {
-	c.consensusTimestamp = time.Now()
+	c.consensusTimestamp = c.clock.Now()
 	c.current.SetPreprepare(preprepare)
 }
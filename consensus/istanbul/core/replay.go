@@ -0,0 +1,123 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/istanbul"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// Archive persists the valid consensus messages received by the core, to allow
+// replaying them later on.
+type Archive interface {
+	// Store archives the payload of a message belonging to the given sequence
+	Store(sequence uint64, payload []byte)
+}
+
+// ReplayStep is the outcome of replaying a single archived message.
+type ReplayStep struct {
+	Message *MessageDump   `json:"message"`
+	Error   string         `json:"error,omitempty"`
+	Sent    []*MessageDump `json:"sent,omitempty"` // Messages the core broadcast in response
+	State   string         `json:"state"`          // Consensus state after handling the message
+	Round   uint64         `json:"round"`          // Consensus round after handling the message
+}
+
+// ReplayResult is the outcome of replaying the archived messages of a sequence.
+type ReplayResult struct {
+	Steps     []*ReplayStep `json:"steps"`
+	Final     *StateDump    `json:"final"`
+	Committed *common.Hash  `json:"committed"` // Proposal committed by the replay, if any
+}
+
+// SetArchive implements core.Engine.SetArchive, setting the archive to persist
+// the valid consensus messages into.
+func (c *core) SetArchive(archive Archive) {
+	c.archive = archive
+}
+
+// archiveMessage stores a message into the archive under the sequence it belongs
+// to. The payload must be the signature validated encoding of msg.
+func (c *core) archiveMessage(msg *message, payload []byte) {
+	var view *istanbul.View
+	switch msg.Code {
+	case msgPreprepare:
+		var preprepare *istanbul.Preprepare
+		if err := msg.Decode(&preprepare); err == nil {
+			view = preprepare.View
+		}
+	default:
+		var subject *istanbul.Subject
+		if err := msg.Decode(&subject); err == nil {
+			view = subject.View
+		}
+	}
+	if view == nil || view.Sequence == nil {
+		return
+	}
+	c.archive.Store(view.Sequence.Uint64(), payload)
+}
+
+// Replay re-runs the consensus state machine of the sequence following the last
+// proposal of the backend over the given archived messages, in order. The core
+// runs in a sandbox without timers and processes its backlog synchronously, so
+// the outcome is deterministic. It's up to the backend not to leak the messages
+// and the proposal committed by the sandboxed core.
+func Replay(backend istanbul.Backend, config *istanbul.Config, payloads [][]byte) *ReplayResult {
+	c := New(backend, config).(*core)
+	c.logger = log.New("replay", true, "address", backend.Address())
+
+	return c.replay(payloads)
+}
+
+// replay runs a fresh core in replay mode over the given message payloads.
+func (c *core) replay(payloads [][]byte) *ReplayResult {
+	c.replaying = true
+	c.startNewRound(common.Big0)
+	defer c.stopTimer()
+
+	result := &ReplayResult{Steps: make([]*ReplayStep, 0, len(payloads))}
+	for _, payload := range payloads {
+		step := new(ReplayStep)
+
+		msg := new(message)
+		if err := msg.FromPayload(payload, nil); err == nil {
+			step.Message = dumpMessages([]*message{msg})[0]
+		}
+		if err := c.handleMsg(payload); err != nil {
+			step.Error = err.Error()
+		}
+		// Process any backlog unlocked by the message before moving on
+		for len(c.replayBacklog) > 0 {
+			ev := c.replayBacklog[0]
+			c.replayBacklog = c.replayBacklog[1:]
+			c.handleCheckedMsg(ev.msg, ev.src)
+		}
+		step.Sent = dumpMessages(c.replaySent)
+		step.State, step.Round = c.state.String(), c.current.Round().Uint64()
+		result.Steps = append(result.Steps, step)
+
+		c.replaySent = nil
+	}
+	result.Final = c.dumpState()
+	if c.state == StateCommitted && c.current.Proposal() != nil {
+		hash := c.current.Proposal().Hash()
+		result.Committed = &hash
+	}
+	return result
+}
@@ -30,8 +30,21 @@ func (c *core) sendNextRoundChange() {
 	c.sendRoundChange(new(big.Int).Add(cv.Round, common.Big1))
 }
 
+// sendNextRoundChangeWithReason sends the ROUND CHANGE message with current
+// round + 1, notifying the other validators why the round is abandoned.
+func (c *core) sendNextRoundChangeWithReason(reason string) {
+	cv := c.currentView()
+	c.broadcastRoundChange(new(big.Int).Add(cv.Round, common.Big1), reason)
+}
+
 // sendRoundChange sends the ROUND CHANGE message with the given round
 func (c *core) sendRoundChange(round *big.Int) {
+	c.broadcastRoundChange(round, "")
+}
+
+// broadcastRoundChange sends the ROUND CHANGE message with the given round and
+// an optional reason
+func (c *core) broadcastRoundChange(round *big.Int, reason string) {
 	logger := c.logger.New("state", c.state)
 
 	cv := c.currentView()
@@ -51,6 +64,7 @@ func (c *core) sendRoundChange(round *big.Int) {
 	rc := &istanbul.Subject{
 		View:   cv,
 		Digest: common.Hash{},
+		Reason: reason,
 	}
 
 	payload, err := Encode(rc)
@@ -78,6 +92,9 @@ func (c *core) handleRoundChange(msg *message, src istanbul.Validator) error {
 	if err := c.checkMessage(msgRoundChange, rc.View); err != nil {
 		return err
 	}
+	if rc.Reason != "" {
+		logger.Warn("Validator requested round change", "round", rc.View.Round, "reason", rc.Reason)
+	}
 
 	cv := c.currentView()
 	roundView := rc.View
@@ -102,6 +119,12 @@ func (c *core) handleRoundChange(msg *message, src istanbul.Validator) error {
 		// We've received 2f+1 ROUND CHANGE messages, start a new round immediately.
 		c.startNewRound(roundView.Round)
 		return nil
+	} else if !c.waitingForRoundChange && num == c.valSet.F()+1 && new(big.Int).Add(cv.Round, common.Big1).Cmp(roundView.Round) == 0 {
+		// At least one honest validator gave up on the current round (e.g. the
+		// proposer declined to propose in maintenance), follow the weak certificate
+		// into the next round instead of waiting for the timeout.
+		c.sendRoundChange(roundView.Round)
+		return nil
 	} else if cv.Round.Cmp(roundView.Round) < 0 {
 		// Only gossip the message with current round to other validators.
 		return errIgnored
@@ -90,3 +90,33 @@ func TestRoundChangeSet(t *testing.T) {
 		t.Errorf("the change messages mismatch: have %v, want nil", rc.roundChanges[view.Round.Uint64()])
 	}
 }
+
+// Tests that validators only follow others into the next round once F+1 of them
+// asked for it, a lone ROUND CHANGE possibly coming from a faulty validator.
+func TestRoundChangeWeakCertificate(t *testing.T) {
+	sys := NewTestSystemWithBackend(4, 1)
+	close := sys.Run(false)
+	defer close()
+
+	c := sys.backends[3].engine.(*core)
+	c.roundChangeSet = newRoundChangeSet(c.valSet)
+
+	subject, _ := Encode(&istanbul.Subject{
+		View:   &istanbul.View{Round: big.NewInt(1), Sequence: big.NewInt(1)},
+		Digest: common.Hash{},
+	})
+	for i, want := range []int64{0, 1} {
+		src := c.valSet.GetByIndex(uint64(i))
+		msg := &message{
+			Code:    msgRoundChange,
+			Msg:     subject,
+			Address: src.Address(),
+		}
+		if err := c.handleRoundChange(msg, src); err != nil && err != errIgnored {
+			t.Fatalf("message %d: failed to handle round change: %v", i, err)
+		}
+		if round := c.current.Round().Int64(); round != want {
+			t.Errorf("message %d: round mismatch: have %d, want %d", i, round, want)
+		}
+	}
+}
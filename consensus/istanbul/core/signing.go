@@ -0,0 +1,91 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"encoding/binary"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/istanbul"
+)
+
+// signingDomainPrefix is the engine name mixed into the signing domain.
+var signingDomainPrefix = []byte("istanbul")
+
+// signingDomain returns the prefix of the signed payload of consensus messages
+// of the given type: the engine name, the chain ID and the message code.
+func (c *core) signingDomain(code uint64) []byte {
+	return signingDomain(c.config.ChainID, code)
+}
+
+// signingDomain returns the signing domain of consensus messages of the given
+// type on the chain with the given ID.
+func signingDomain(chainID *big.Int, code uint64) []byte {
+	domain := make([]byte, 0, len(signingDomainPrefix)+common.HashLength+8)
+	domain = append(domain, signingDomainPrefix...)
+
+	var id common.Hash
+	if chainID != nil {
+		id = common.BigToHash(chainID)
+	}
+	domain = append(domain, id[:]...)
+
+	var enc [8]byte
+	binary.BigEndian.PutUint64(enc[:], code)
+	return append(domain, enc[:]...)
+}
+
+// signingPayload returns the data to sign for a message, prefixed with the
+// signing domain unless configured to sign the legacy way.
+func (c *core) signingPayload(msg *message) ([]byte, error) {
+	payload, err := msg.PayloadNoSig()
+	if err != nil {
+		return nil, err
+	}
+	if c.config.SigningMode == istanbul.LegacySigning {
+		return payload, nil
+	}
+	return append(c.signingDomain(msg.Code), payload...), nil
+}
+
+// checkMessageSignature verifies that a message was signed by a validator,
+// returning the recovered signer. The signature is checked the way the local
+// node signs first, and unless strict, falls back to the other form for
+// validators still signing the other way.
+func (c *core) checkMessageSignature(msg *message) (common.Address, error) {
+	payload, err := msg.PayloadNoSig()
+	if err != nil {
+		return common.Address{}, err
+	}
+	domained := append(c.signingDomain(msg.Code), payload...)
+
+	var signer common.Address
+	switch c.config.SigningMode {
+	case istanbul.StrictSigning:
+		signer, err = c.validateFn(domained, msg.Signature)
+	case istanbul.DomainSigning:
+		if signer, err = c.validateFn(domained, msg.Signature); err != nil {
+			signer, err = c.validateFn(payload, msg.Signature)
+		}
+	default:
+		if signer, err = c.validateFn(payload, msg.Signature); err != nil {
+			signer, err = c.validateFn(domained, msg.Signature)
+		}
+	}
+	return signer, err
+}
@@ -0,0 +1,80 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/istanbul"
+	"github.com/ethereum/go-ethereum/consensus/istanbul/validator"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Tests that messages signed within the domain of a chain are only accepted on
+// that chain, and legacy signatures only outside of strict mode.
+func TestSigningDomain(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	addr := crypto.PubkeyToAddress(key.PublicKey)
+	valSet := validator.NewSet([]common.Address{addr}, istanbul.RoundRobin)
+
+	newCore := func(chainID int64, mode istanbul.SigningMode) *core {
+		config := *istanbul.DefaultConfig
+		config.ChainID, config.SigningMode = big.NewInt(chainID), mode
+
+		c := NewTestSystemWithBackend(1, 0).backends[0].engine.(*core)
+		c.config = &config
+		c.validateFn = func(data []byte, sig []byte) (common.Address, error) {
+			return istanbul.CheckValidatorSignature(valSet, data, sig)
+		}
+		return c
+	}
+	sign := func(c *core, code uint64) *message {
+		msg := &message{Code: code, Msg: []byte{0x01}, Address: addr, CommittedSeal: []byte{}}
+		data, _ := c.signingPayload(msg)
+		msg.Signature, _ = crypto.Sign(crypto.Keccak256(data), key)
+		return msg
+	}
+	tests := []struct {
+		signer, verifier istanbul.SigningMode
+		chainID          int64 // Chain of the verifier, the signer is on chain 1
+		ok               bool
+	}{
+		{istanbul.LegacySigning, istanbul.LegacySigning, 1, true},
+		{istanbul.LegacySigning, istanbul.DomainSigning, 1, true},
+		{istanbul.LegacySigning, istanbul.StrictSigning, 1, false},
+		{istanbul.DomainSigning, istanbul.LegacySigning, 1, true},
+		{istanbul.DomainSigning, istanbul.DomainSigning, 1, true},
+		{istanbul.DomainSigning, istanbul.StrictSigning, 1, true},
+
+		// Domain bound signatures are worthless on other chains
+		{istanbul.DomainSigning, istanbul.LegacySigning, 2, false},
+		{istanbul.DomainSigning, istanbul.DomainSigning, 2, false},
+		{istanbul.DomainSigning, istanbul.StrictSigning, 2, false},
+	}
+	for i, test := range tests {
+		msg := sign(newCore(1, test.signer), msgPrepare)
+		signer, err := newCore(test.chainID, test.verifier).checkMessageSignature(msg)
+		if (err == nil) != test.ok {
+			t.Errorf("test %d: verification mismatch: have %v, want ok %v", i, err, test.ok)
+		}
+		if err == nil && signer != addr {
+			t.Errorf("test %d: signer mismatch: have %x, want %x", i, signer, addr)
+		}
+	}
+}
@@ -17,13 +17,16 @@
 package core
 
 import (
+	"bytes"
 	"crypto/ecdsa"
 	"math/big"
+	"sync/atomic"
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/consensus/istanbul"
 	"github.com/ethereum/go-ethereum/consensus/istanbul/validator"
+	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/event"
@@ -45,6 +48,13 @@ type testSystemBackend struct {
 
 	address common.Address
 	db      ethdb.Database
+
+	byzantine byzantineStrategy // Adversarial behavior of the validator (nil = honest)
+
+	penalties []common.Address // Validators penalized by the core
+
+	down    int32         // Flag whether the validator is cut off from the network (atomic)
+	stopped chan struct{} // Closed when the core of a crashed validator stopped
 }
 
 type testCommittedMsgs struct {
@@ -72,22 +82,49 @@ func (self *testSystemBackend) EventMux() *event.TypeMux {
 func (self *testSystemBackend) Send(message []byte, target common.Address) error {
 	testLogger.Info("enqueuing a message...", "address", self.Address())
 	self.sentMsgs = append(self.sentMsgs, message)
-	self.sys.queuedMessage <- istanbul.MessageEvent{
-		Payload: message,
-	}
+	self.deliver(message)
 	return nil
 }
 
-func (self *testSystemBackend) Broadcast(valSet istanbul.ValidatorSet, message []byte) error {
+func (self *testSystemBackend) Broadcast(valSet istanbul.ValidatorSet, message []byte, done istanbul.DeliveryCallback) error {
 	testLogger.Info("enqueuing a message...", "address", self.Address())
 	self.sentMsgs = append(self.sentMsgs, message)
-	self.sys.queuedMessage <- istanbul.MessageEvent{
-		Payload: message,
+	self.deliver(message)
+
+	// Messages can't be delivered to crashed validators
+	if done != nil {
+		var undelivered []common.Address
+		for _, backend := range self.sys.backends {
+			if backend != self && backend.isDown() {
+				undelivered = append(undelivered, backend.address)
+			}
+		}
+		go done(undelivered)
 	}
 	return nil
 }
 
-func (self *testSystemBackend) Gossip(valSet istanbul.ValidatorSet, message []byte) error {
+// deliver queues a message for all validators, or if the validator is byzantine,
+// the messages its strategy sends in place of it.
+func (self *testSystemBackend) deliver(payload []byte) {
+	if self.isDown() {
+		return
+	}
+	deliveries := []testDelivery{{payload: payload}}
+	if self.byzantine != nil {
+		msg := new(message)
+		if err := msg.FromPayload(payload, nil); err != nil {
+			testLogger.Error("failed to decode message", "err", err)
+			return
+		}
+		deliveries = self.byzantine.tamper(self, msg)
+	}
+	for _, delivery := range deliveries {
+		self.sys.queuedMessage <- delivery
+	}
+}
+
+func (self *testSystemBackend) Gossip(valSet istanbul.ValidatorSet, message []byte, done istanbul.DeliveryCallback) error {
 	testLogger.Warn("not sign any data")
 	return nil
 }
@@ -104,21 +141,41 @@ func (self *testSystemBackend) Commit(proposal istanbul.Proposal, seals [][]byte
 	return nil
 }
 
+// Only proposals marked invalid by a byzantine proposer will be rejected
 func (self *testSystemBackend) Verify(proposal istanbul.Proposal) (time.Duration, error) {
+	if block, ok := proposal.(*types.Block); ok && bytes.Equal(block.Extra(), invalidProposalExtra) {
+		return 0, errInvalidTestProposal
+	}
 	return 0, nil
 }
 
+// Sign stands in for a signature by the address of the signing validator, which
+// CheckValidatorSignature recovers.
 func (self *testSystemBackend) Sign(data []byte) ([]byte, error) {
-	testLogger.Warn("not sign any data")
-	return data, nil
+	return self.address.Bytes(), nil
 }
 
 func (self *testSystemBackend) CheckSignature([]byte, common.Address, []byte) error {
 	return nil
 }
 
+func (self *testSystemBackend) SignCommittedSeal(hash common.Hash, number *big.Int) ([]byte, error) {
+	return self.Sign(PrepareCommittedSeal(hash))
+}
+
+func (self *testSystemBackend) CheckCommittedSeal(hash common.Hash, number *big.Int, addr common.Address, seal []byte) error {
+	if !bytes.Equal(seal, addr.Bytes()) {
+		return istanbul.ErrUnauthorizedAddress
+	}
+	return self.CheckSignature(PrepareCommittedSeal(hash), addr, seal)
+}
+
 func (self *testSystemBackend) CheckValidatorSignature(data []byte, sig []byte) (common.Address, error) {
-	return common.Address{}, nil
+	signer := common.BytesToAddress(sig)
+	if _, val := self.peers.GetByAddress(signer); len(sig) != common.AddressLength || val == nil {
+		return common.Address{}, istanbul.ErrUnauthorizedAddress
+	}
+	return signer, nil
 }
 
 func (self *testSystemBackend) Hash(b interface{}) common.Hash {
@@ -135,6 +192,10 @@ func (self *testSystemBackend) HasBadProposal(hash common.Hash) bool {
 	return false
 }
 
+func (self *testSystemBackend) Penalize(address common.Address, reason error) {
+	self.penalties = append(self.penalties, address)
+}
+
 func (self *testSystemBackend) LastProposal() (istanbul.Proposal, common.Address) {
 	l := len(self.committedMsgs)
 	if l > 0 {
@@ -163,16 +224,36 @@ func (self *testSystemBackend) ParentValidators(proposal istanbul.Proposal) ista
 type testSystem struct {
 	backends []*testSystemBackend
 
-	queuedMessage chan istanbul.MessageEvent
+	queuedMessage chan testDelivery
 	quit          chan struct{}
 }
 
+// testDelivery is a consensus message queued for delivery to the validators.
+type testDelivery struct {
+	payload []byte
+	targets []common.Address // Validators to deliver the message to (nil = all)
+	delay   time.Duration    // Time to hold back the message for
+}
+
+// deliversTo checks whether the message is to be delivered to a validator.
+func (d *testDelivery) deliversTo(address common.Address) bool {
+	if d.targets == nil {
+		return true
+	}
+	for _, target := range d.targets {
+		if target == address {
+			return true
+		}
+	}
+	return false
+}
+
 func newTestSystem(n uint64) *testSystem {
 	testLogger.SetHandler(elog.StdoutHandler)
 	return &testSystem{
 		backends: make([]*testSystemBackend, n),
 
-		queuedMessage: make(chan istanbul.MessageEvent),
+		queuedMessage: make(chan testDelivery),
 		quit:          make(chan struct{}),
 	}
 }
@@ -228,10 +309,17 @@ func (t *testSystem) listen() {
 		select {
 		case <-t.quit:
 			return
-		case queuedMessage := <-t.queuedMessage:
+		case delivery := <-t.queuedMessage:
 			testLogger.Info("consuming a queue message...")
+			ev := istanbul.MessageEvent{Payload: delivery.payload}
 			for _, backend := range t.backends {
-				go backend.EventMux().Post(queuedMessage)
+				if !delivery.deliversTo(backend.address) || backend.isDown() {
+					continue
+				}
+				go func(mux *event.TypeMux, delay time.Duration) {
+					time.Sleep(delay)
+					mux.Post(ev)
+				}(backend.EventMux(), delivery.delay)
 			}
 		}
 	}
@@ -277,6 +365,39 @@ func (t *testSystem) NewBackend(id uint64) *testSystemBackend {
 	return backend
 }
 
+// isDown checks whether the validator is cut off from the network.
+func (self *testSystemBackend) isDown() bool {
+	return atomic.LoadInt32(&self.down) == 1
+}
+
+// crash cuts the validator off from the network and stops its core, losing all
+// of its consensus state. It may be called from within the core's event loop.
+func (self *testSystemBackend) crash() {
+	atomic.StoreInt32(&self.down, 1)
+
+	self.stopped = make(chan struct{})
+	go func(engine Engine, stopped chan struct{}) {
+		engine.Stop()
+		close(stopped)
+	}(self.engine, self.stopped)
+}
+
+// restart brings a crashed validator back with a fresh core, which starts over
+// from the last committed proposal.
+func (self *testSystemBackend) restart(config *istanbul.Config) {
+	<-self.stopped
+
+	core := New(self, config).(*core)
+	core.logger = testLogger
+	core.validateFn = self.CheckValidatorSignature
+
+	self.engine = core
+	self.byzantine = nil
+	atomic.StoreInt32(&self.down, 0)
+
+	core.Start()
+}
+
 // ==============================================
 //
 // helper functions.
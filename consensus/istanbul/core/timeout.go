@@ -0,0 +1,88 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"time"
+
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+const (
+	latencySampleWeight = 0.2 // Weight of a new sample in the moving averages
+	roundTimeoutFactor  = 3   // Multiple of the observed latency to wait for before changing rounds
+	rttPhases           = 3   // Message exchanges needed to commit a proposal
+)
+
+// requestTimeoutGauge reports the base round change timeout in milliseconds.
+var requestTimeoutGauge = metrics.NewRegisteredGauge("consensus/istanbul/core/timeout", nil)
+
+// latencyTracker keeps moving averages of the consensus latencies observed on
+// the network, from which the adaptive round change timeout is derived.
+type latencyTracker struct {
+	round time.Duration // Average duration from accepting a proposal to committing it
+	rtt   time.Duration // Average round trip from sending a PREPARE to reaching the quorum
+}
+
+// addRound records the duration of a committed sequence.
+func (t *latencyTracker) addRound(d time.Duration) {
+	t.round = movingAverage(t.round, d)
+}
+
+// addRTT records the round trip of a PREPARE message.
+func (t *latencyTracker) addRTT(d time.Duration) {
+	t.rtt = movingAverage(t.rtt, d)
+}
+
+// latency returns the expected time needed to commit a proposal, zero if there
+// are no samples yet.
+func (t *latencyTracker) latency() time.Duration {
+	if rtts := rttPhases * t.rtt; rtts > t.round {
+		return rtts
+	}
+	return t.round
+}
+
+// movingAverage folds a sample into an exponentially weighted moving average,
+// seeded with the first sample.
+func movingAverage(avg, sample time.Duration) time.Duration {
+	if avg == 0 {
+		return sample
+	}
+	return time.Duration((1-latencySampleWeight)*float64(avg) + latencySampleWeight*float64(sample))
+}
+
+// requestTimeout returns the base timeout of a round. If the adaptive timeout is
+// enabled, it follows the observed network latency within the configured bounds,
+// otherwise the configured request timeout is used.
+func (c *core) requestTimeout() time.Duration {
+	timeout := time.Duration(c.config.RequestTimeout) * time.Millisecond
+	if c.config.MaxRequestTimeout > 0 {
+		if latency := c.latency.latency(); latency > 0 {
+			// The proposal itself is only sent once the block period elapsed
+			timeout = roundTimeoutFactor*latency + time.Duration(c.config.BlockPeriod)*time.Second
+		}
+		if min := time.Duration(c.config.MinRequestTimeout) * time.Millisecond; timeout < min {
+			timeout = min
+		}
+		if max := time.Duration(c.config.MaxRequestTimeout) * time.Millisecond; timeout > max {
+			timeout = max
+		}
+	}
+	requestTimeoutGauge.Update(int64(timeout / time.Millisecond))
+	return timeout
+}
@@ -27,6 +27,21 @@ import (
 type Engine interface {
 	Start() error
 	Stop() error
+
+	// Dump returns a snapshot of the consensus state, nil if unavailable
+	Dump() *StateDump
+
+	// SetMaintenance toggles whether the validator declines to act as proposer
+	SetMaintenance(enabled bool)
+
+	// SetArchive sets the archive to persist valid consensus messages into
+	SetArchive(archive Archive)
+
+	// SetAuditor sets the auditor to record the consensus activity with
+	SetAuditor(auditor Auditor)
+
+	// SetMisbehaviorLog sets the log to persist detected misbehavior into
+	SetMisbehaviorLog(log MisbehaviorLog)
 }
 
 type State uint64
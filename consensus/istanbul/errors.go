@@ -18,6 +18,10 @@ package istanbul
 
 import "errors"
 
+// maxVetoReasonLength is the maximum length of a veto reason propagated to other
+// validators.
+const maxVetoReasonLength = 256
+
 var (
 	// ErrUnauthorizedAddress is returned when given address cannot be found in
 	// current validator set.
@@ -26,4 +30,25 @@ var (
 	ErrStoppedEngine = errors.New("stopped engine")
 	// ErrStartedEngine is returned if the engine is already started
 	ErrStartedEngine = errors.New("started engine")
+	// errReasonTooLong is returned when decoding a subject whose round change
+	// reason exceeds the length honest validators truncate vetoes to.
+	errReasonTooLong = errors.New("round change reason too long")
 )
+
+// VetoError is returned when the application vetoed a proposal, carrying the
+// reason to propagate along with the round change.
+type VetoError struct {
+	Reason string
+}
+
+// NewVetoError creates a veto error, truncating overly long reasons.
+func NewVetoError(reason string) *VetoError {
+	if len(reason) > maxVetoReasonLength {
+		reason = reason[:maxVetoReasonLength]
+	}
+	return &VetoError{Reason: reason}
+}
+
+func (e *VetoError) Error() string {
+	return "proposal vetoed: " + e.Reason
+}
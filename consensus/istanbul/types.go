@@ -121,11 +121,15 @@ func (b *Preprepare) DecodeRLP(s *rlp.Stream) error {
 type Subject struct {
 	View   *View
 	Digest common.Hash
+	Reason string // Optional reason of a ROUND CHANGE, omitted from the encoding if empty
 }
 
 // EncodeRLP serializes b into the Ethereum RLP format.
 func (b *Subject) EncodeRLP(w io.Writer) error {
-	return rlp.Encode(w, []interface{}{b.View, b.Digest})
+	if b.Reason == "" {
+		return rlp.Encode(w, []interface{}{b.View, b.Digest})
+	}
+	return rlp.Encode(w, []interface{}{b.View, b.Digest, b.Reason})
 }
 
 // DecodeRLP implements rlp.Decoder, and load the consensus fields from a RLP stream.
@@ -133,15 +137,25 @@ func (b *Subject) DecodeRLP(s *rlp.Stream) error {
 	var subject struct {
 		View   *View
 		Digest common.Hash
+		Rest   []string `rlp:"tail"`
 	}
 
 	if err := s.Decode(&subject); err != nil {
 		return err
 	}
-	b.View, b.Digest = subject.View, subject.Digest
+	b.View, b.Digest, b.Reason = subject.View, subject.Digest, ""
+	if len(subject.Rest) > 0 {
+		if len(subject.Rest[0]) > maxVetoReasonLength {
+			return errReasonTooLong
+		}
+		b.Reason = subject.Rest[0]
+	}
 	return nil
 }
 
 func (b *Subject) String() string {
+	if b.Reason != "" {
+		return fmt.Sprintf("{View: %v, Digest: %v, Reason: %q}", b.View, b.Digest.String(), b.Reason)
+	}
 	return fmt.Sprintf("{View: %v, Digest: %v}", b.View, b.Digest.String())
 }
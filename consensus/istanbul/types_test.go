@@ -18,7 +18,12 @@ package istanbul
 
 import (
 	"math/big"
+	"reflect"
+	"strings"
 	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/rlp"
 )
 
 func TestViewCompare(t *testing.T) {
@@ -69,3 +74,40 @@ func TestViewCompare(t *testing.T) {
 		t.Errorf("source(%v) should be smaller than target(%v): have %v, want %v", srvView, tarView, r, -1)
 	}
 }
+
+func TestSubjectRLP(t *testing.T) {
+	view := &View{Sequence: big.NewInt(2), Round: big.NewInt(1)}
+	tests := []*Subject{
+		{View: view, Digest: common.HexToHash("0x1234")},
+		{View: view, Reason: "proposal vetoed"},
+	}
+	for i, subject := range tests {
+		blob, err := rlp.EncodeToBytes(subject)
+		if err != nil {
+			t.Fatalf("test %d: failed to encode subject: %v", i, err)
+		}
+		decoded := new(Subject)
+		if err := rlp.DecodeBytes(blob, decoded); err != nil {
+			t.Fatalf("test %d: failed to decode subject: %v", i, err)
+		}
+		if !reflect.DeepEqual(decoded, subject) {
+			t.Errorf("test %d: subject mismatch: have %v, want %v", i, decoded, subject)
+		}
+	}
+	// Subjects without a reason must retain their original encoding
+	blob, _ := rlp.EncodeToBytes(tests[0])
+	legacy, _ := rlp.EncodeToBytes([]interface{}{view, tests[0].Digest})
+	if !reflect.DeepEqual(blob, legacy) {
+		t.Errorf("encoding mismatch: have %x, want %x", blob, legacy)
+	}
+	// Reasons longer than honest validators send must be rejected
+	reason := NewVetoError(strings.Repeat("x", 2*maxVetoReasonLength)).Reason
+	blob, _ = rlp.EncodeToBytes(&Subject{View: view, Reason: reason})
+	if err := rlp.DecodeBytes(blob, new(Subject)); err != nil {
+		t.Errorf("maximum length reason rejected: %v", err)
+	}
+	blob, _ = rlp.EncodeToBytes(&Subject{View: view, Reason: reason + "x"})
+	if err := rlp.DecodeBytes(blob, new(Subject)); err != errReasonTooLong {
+		t.Errorf("overly long reason error mismatch: have %v, want %v", err, errReasonTooLong)
+	}
+}
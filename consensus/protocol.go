@@ -26,13 +26,19 @@ import (
 const (
 	Eth62 = 62
 	Eth63 = 63
+
+	// Eth163 is eth/63 with the chain config fingerprint added to the status
+	// handshake. It's numbered apart from upstream eth/64 and later, which are
+	// unrelated extensions.
+	Eth163 = 163
 )
 
 var (
 	EthProtocol = Protocol{
-		Name:     "eth",
-		Versions: []uint{Eth62, Eth63},
-		Lengths:  []uint64{17, 8},
+		Name:            "eth",
+		Versions:        []uint{Eth163, Eth63, Eth62},
+		Lengths:         []uint64{17, 17, 8},
+		FingerprintFrom: Eth163,
 	}
 )
 
@@ -44,6 +50,8 @@ type Protocol struct {
 	Versions []uint
 	// Number of implemented message corresponding to different protocol versions.
 	Lengths []uint64
+	// First version exchanging the chain config fingerprint in the handshake (0 = never).
+	FingerprintFrom uint
 }
 
 // Broadcaster defines the interface to enqueue blocks to fetcher and find peer
@@ -54,6 +62,14 @@ type Broadcaster interface {
 	FindPeers(map[common.Address]bool) map[common.Address]Peer
 }
 
+// Synchroniser is a broadcaster able to fetch the blocks up to a head announced
+// by a peer, without waiting for the next regular sync cycle.
+type Synchroniser interface {
+	// SyncFrom requests the blocks up to the given head from the peer, returning
+	// once the sync cycle ended, successfully or not.
+	SyncFrom(address common.Address, head common.Hash, number uint64)
+}
+
 // Peer defines the interface to communicate with peer
 type Peer interface {
 	// Send sends the message to this peer
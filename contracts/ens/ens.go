@@ -21,6 +21,7 @@ package ens
 //go:generate abigen --sol contract/PublicResolver.sol --exc contract/AbstractENS.sol:AbstractENS --pkg contract --out contract/publicresolver.go
 
 import (
+	"math/big"
 	"strings"
 
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
@@ -39,6 +40,9 @@ var (
 type ENS struct {
 	*contract.ENSSession
 	contractBackend bind.ContractBackend
+
+	address common.Address // address of the registry
+	index   *nameIndex     // names learnt through the registry, see WatchNames
 }
 
 // NewENS creates a struct exposing convenient high-level operations for interacting with
@@ -50,11 +54,13 @@ func NewENS(transactOpts *bind.TransactOpts, contractAddr common.Address, contra
 	}
 
 	return &ENS{
-		&contract.ENSSession{
+		ENSSession: &contract.ENSSession{
 			Contract:     ens,
 			TransactOpts: *transactOpts,
 		},
-		contractBackend,
+		contractBackend: contractBackend,
+		address:         contractAddr,
+		index:           newNameIndex(),
 	}, nil
 }
 
@@ -147,7 +153,31 @@ func (self *ENS) Resolve(name string) (common.Hash, error) {
 	if err != nil {
 		return common.Hash{}, err
 	}
+	hash := common.BytesToHash(ret[:])
+	self.learn(name, &hash)
+
+	return hash, nil
+}
+
+// ResolveAt is a non-transactional call that returns the content hash associated
+// with a name in the state of the registry at the given block, allowing content
+// to be linked to permanently even if the name is later updated.
+func (self *ENS) ResolveAt(name string, block *big.Int) (common.Hash, error) {
+	node := ensNode(name)
+	opts := &bind.CallOpts{BlockNumber: block}
 
+	resolverAddr, err := self.Contract.Resolver(opts, node)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	resolver, err := contract.NewPublicResolver(resolverAddr, self.contractBackend)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	ret, err := resolver.Content(opts, node)
+	if err != nil {
+		return common.Hash{}, err
+	}
 	return common.BytesToHash(ret[:]), nil
 }
 
@@ -159,7 +189,11 @@ func (self *ENS) Register(name string) (*types.Transaction, error) {
 	if err != nil {
 		return nil, err
 	}
-	return registrar.Contract.Register(&self.TransactOpts, label, self.TransactOpts.From)
+	tx, err := registrar.Contract.Register(&self.TransactOpts, label, self.TransactOpts.From)
+	if err == nil {
+		self.learn(name, nil)
+	}
+	return tx, err
 }
 
 // SetContentHash sets the content hash associated with a name. Only works if the caller
@@ -174,5 +208,9 @@ func (self *ENS) SetContentHash(name string, hash common.Hash) (*types.Transacti
 
 	opts := self.TransactOpts
 	opts.GasLimit = 200000
-	return resolver.Contract.SetContent(&opts, node, hash)
+	tx, err := resolver.Contract.SetContent(&opts, node, hash)
+	if err == nil {
+		self.learn(name, nil)
+	}
+	return tx, err
 }
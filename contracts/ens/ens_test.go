@@ -19,9 +19,11 @@ package ens
 import (
 	"math/big"
 	"testing"
+	"time"
 
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind/backends"
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/contracts/ens/contract"
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/crypto"
@@ -44,6 +46,13 @@ func TestENS(t *testing.T) {
 	}
 	contractBackend.Commit()
 
+	// Index the names as they are registered and updated.
+	sub, err := ens.WatchNames()
+	if err != nil {
+		t.Fatalf("can't watch names: %v", err)
+	}
+	defer sub.Unsubscribe()
+
 	// Set ourself as the owner of the name.
 	if _, err := ens.Register(name); err != nil {
 		t.Fatalf("can't register: %v", err)
@@ -74,4 +83,47 @@ func TestENS(t *testing.T) {
 	if vhost != hash {
 		t.Fatalf("resolve error, expected %v, got %v", hash.Hex(), vhost.Hex())
 	}
+
+	// Update the content hash, the previous one remains resolvable in the state
+	// of the block it was set in (genesis and four commits).
+	update := crypto.Keccak256Hash([]byte("my updated content"))
+	if _, err = ens.SetContentHash(name, update); err != nil {
+		t.Fatalf("can't update content hash: %v", err)
+	}
+	contractBackend.Commit()
+
+	if vhost, err = ens.Resolve(name); err != nil || vhost != update {
+		t.Fatalf("resolve error, expected %v, got %v (%v)", update.Hex(), vhost.Hex(), err)
+	}
+	if vhost, err = ens.ResolveAt(name, big.NewInt(4)); err != nil || vhost != hash {
+		t.Fatalf("historical resolve error, expected %v, got %v (%v)", hash.Hex(), vhost.Hex(), err)
+	}
+	if vhost, err = ens.ResolveAt(name, big.NewInt(3)); err != nil || vhost != (common.Hash{}) {
+		t.Fatalf("resolved before content was set: %v (%v)", vhost.Hex(), err)
+	}
+	if _, err = ens.ResolveAt(name, big.NewInt(100)); err == nil {
+		t.Fatal("resolved in future block")
+	}
+
+	// Check the names indexed from the events.
+	waitIndex(t, func() bool {
+		owned := ens.OwnedNames(addr)
+		return len(owned) == 1 && owned[0] == name
+	})
+	waitIndex(t, func() bool {
+		names := ens.NamesOf(update)
+		return len(names) == 1 && names[0] == name && len(ens.NamesOf(hash)) == 0
+	})
+	if owned := ens.OwnedNames(common.Address{}); len(owned) != 0 {
+		t.Fatalf("unexpected names owned by zero address: %v", owned)
+	}
+}
+
+// waitIndex waits for the name index to satisfy a condition.
+func waitIndex(t *testing.T, cond func() bool) {
+	for deadline := time.Now().Add(5 * time.Second); !cond(); time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for name index")
+		}
+	}
 }
@@ -0,0 +1,191 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ens
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// Topics of the registry and resolver events maintaining the name index.
+var (
+	newOwnerTopic       = crypto.Keccak256Hash([]byte("NewOwner(bytes32,bytes32,address)"))
+	transferTopic       = crypto.Keccak256Hash([]byte("Transfer(bytes32,address)"))
+	newResolverTopic    = crypto.Keccak256Hash([]byte("NewResolver(bytes32,address)"))
+	contentChangedTopic = crypto.Keccak256Hash([]byte("ContentChanged(bytes32,bytes32)"))
+)
+
+// nameIndex tracks the owners, resolvers and content hashes of names. The
+// registry only knows the hashes of names, so the index is limited to the names
+// learnt by resolving or registering them.
+type nameIndex struct {
+	names     map[common.Hash]string         // node -> name
+	owners    map[common.Hash]common.Address // node -> owner
+	resolvers map[common.Hash]common.Address // node -> resolver
+	content   map[common.Hash]common.Hash    // node -> content hash
+	lock      sync.RWMutex
+}
+
+func newNameIndex() *nameIndex {
+	return &nameIndex{
+		names:     make(map[common.Hash]string),
+		owners:    make(map[common.Hash]common.Address),
+		resolvers: make(map[common.Hash]common.Address),
+		content:   make(map[common.Hash]common.Hash),
+	}
+}
+
+// OwnedNames returns the names known to the index owned by an address.
+func (self *ENS) OwnedNames(owner common.Address) []string {
+	idx := self.index
+	idx.lock.RLock()
+	defer idx.lock.RUnlock()
+
+	var names []string
+	for node, name := range idx.names {
+		if o, ok := idx.owners[node]; ok && o == owner {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NamesOf returns the names known to the index resolving to a content hash.
+func (self *ENS) NamesOf(hash common.Hash) []string {
+	idx := self.index
+	idx.lock.RLock()
+	defer idx.lock.RUnlock()
+
+	var names []string
+	for node, name := range idx.names {
+		if h, ok := idx.content[node]; ok && h == hash {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// learn adds a name to the index, looking up its owner and resolver if not yet
+// known. The content hash of the name is recorded if given.
+func (self *ENS) learn(name string, content *common.Hash) {
+	idx := self.index
+	node := ensNode(name)
+
+	idx.lock.RLock()
+	_, known := idx.names[node]
+	idx.lock.RUnlock()
+
+	var (
+		owner, resolver common.Address
+		err             error
+	)
+	if !known {
+		if owner, err = self.Owner(node); err == nil {
+			resolver, err = self.Resolver(node)
+		}
+		if err != nil {
+			log.Debug("Failed to index ENS name", "name", name, "err", err)
+			return
+		}
+	}
+	idx.lock.Lock()
+	defer idx.lock.Unlock()
+
+	if _, ok := idx.names[node]; !ok {
+		idx.names[node] = name
+		idx.owners[node] = owner
+		idx.resolvers[node] = resolver
+	}
+	if content != nil {
+		idx.content[node] = *content
+	}
+}
+
+// WatchNames keeps the name index up to date with the events of the registry
+// and the resolvers of the indexed names until the returned subscription is
+// unsubscribed. The contract backend has to support log subscriptions.
+func (self *ENS) WatchNames() (event.Subscription, error) {
+	query := ethereum.FilterQuery{
+		Topics: [][]common.Hash{{newOwnerTopic, transferTopic, newResolverTopic, contentChangedTopic}},
+	}
+	logs := make(chan types.Log, 64)
+	sub, err := self.contractBackend.SubscribeFilterLogs(context.Background(), query, logs)
+	if err != nil {
+		return nil, err
+	}
+	return event.NewSubscription(func(quit <-chan struct{}) error {
+		defer sub.Unsubscribe()
+		for {
+			select {
+			case l := <-logs:
+				self.index.process(&l, self.address)
+			case err := <-sub.Err():
+				return err
+			case <-quit:
+				return nil
+			}
+		}
+	}), nil
+}
+
+// process updates the index with a registry or resolver event. Events of nodes
+// not in the index, as well as content changes not emitted by the resolver of
+// the node, are ignored.
+func (idx *nameIndex) process(l *types.Log, registry common.Address) {
+	if l.Removed || len(l.Topics) < 2 || len(l.Data) < 32 {
+		return
+	}
+	idx.lock.Lock()
+	defer idx.lock.Unlock()
+
+	node := l.Topics[1]
+	value := common.BytesToHash(l.Data[:32])
+
+	switch l.Topics[0] {
+	case newOwnerTopic:
+		if len(l.Topics) < 3 || l.Address != registry {
+			return
+		}
+		node = crypto.Keccak256Hash(l.Topics[1][:], l.Topics[2][:])
+		if _, ok := idx.names[node]; ok {
+			idx.owners[node] = common.BytesToAddress(value[:])
+		}
+	case transferTopic:
+		if _, ok := idx.names[node]; ok && l.Address == registry {
+			idx.owners[node] = common.BytesToAddress(value[:])
+		}
+	case newResolverTopic:
+		if _, ok := idx.names[node]; ok && l.Address == registry {
+			idx.resolvers[node] = common.BytesToAddress(value[:])
+			delete(idx.content, node)
+		}
+	case contentChangedTopic:
+		if resolver, ok := idx.resolvers[node]; ok && l.Address == resolver {
+			idx.content[node] = value
+		}
+	}
+}
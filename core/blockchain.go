@@ -46,7 +46,12 @@ import (
 )
 
 var (
-	blockInsertTimer = metrics.NewRegisteredTimer("chain/inserts", nil)
+	blockInsertTimer    = metrics.NewRegisteredTimer("chain/inserts", nil)
+	blockWriteSizeMeter = metrics.NewRegisteredMeter("chain/write/size", nil)
+
+	// Percentage of the gas limit used by canonical blocks
+	blockGasUsageGauge     = metrics.NewRegisteredGauge("chain/gas/usage", nil)
+	blockGasUsageHistogram = metrics.NewRegisteredHistogram("chain/gas/usage/blocks", nil, metrics.NewExpDecaySample(1028, 0.015))
 
 	ErrNoGenesis = errors.New("Genesis not found in chain")
 )
@@ -66,9 +71,11 @@ const (
 // CacheConfig contains the configuration values for the trie caching/pruning
 // that's resident in a blockchain.
 type CacheConfig struct {
-	Disabled      bool          // Whether to disable trie write caching (archive node)
-	TrieNodeLimit int           // Memory limit (MB) at which to flush the current in-memory trie to disk
-	TrieTimeLimit time.Duration // Time limit after which to flush the current in-memory trie to disk
+	Disabled       bool          // Whether to disable trie write caching (archive node)
+	TrieNodeLimit  int           // Memory limit (MB) at which to flush the current in-memory trie to disk
+	TrieTimeLimit  time.Duration // Time limit after which to flush the current in-memory trie to disk
+	AsyncIndexing  bool          // Whether to write transaction lookups in the background
+	TrieCleanLimit int           // Memory allowance (MB) to use for caching trie nodes read from disk
 }
 
 // BlockChain represents the canonical chain given a database with a genesis
@@ -89,9 +96,10 @@ type BlockChain struct {
 	chainConfig *params.ChainConfig // Chain & network configuration
 	cacheConfig *CacheConfig        // Cache configuration for pruning
 
-	db     ethdb.Database // Low level persistent database to store final content in
-	triegc *prque.Prque   // Priority queue mapping block numbers to tries to gc
-	gcproc time.Duration  // Accumulates canonical block processing for trie dumping
+	db      ethdb.Database // Low level persistent database to store final content in
+	indexer *indexWriter   // Background writer of index data (nil = written with the blocks)
+	triegc  *prque.Prque   // Priority queue mapping block numbers to tries to gc
+	gcproc  time.Duration  // Accumulates canonical block processing for trie dumping
 
 	hc            *HeaderChain
 	rmLogsFeed    event.Feed
@@ -99,6 +107,7 @@ type BlockChain struct {
 	chainSideFeed event.Feed
 	chainHeadFeed event.Feed
 	logsFeed      event.Feed
+	finalityFeed  event.Feed
 	scope         event.SubscriptionScope
 	genesisBlock  *types.Block
 
@@ -122,18 +131,34 @@ type BlockChain struct {
 	procInterrupt int32          // interrupt signaler for block processing
 	wg            sync.WaitGroup // chain processing wait group for shutting down
 
-	engine    consensus.Engine
-	processor Processor // block processor interface
-	validator Validator // block and state validator interface
-	vmConfig  vm.Config
+	engine     consensus.Engine
+	processor  Processor // block processor interface
+	prefetcher *statePrefetcher
+	validator  Validator // block and state validator interface
+	vmConfig   vm.Config
+
+	badBlocks   *lru.Cache // Bad block cache
+	badBlockDir string     // Directory to persist forensic bad block reports into (empty = disabled)
+
+	prefetchInterrupt *uint32    // Interrupt flag of the running block prefetch, if any
+	prefetchLock      sync.Mutex // Lock protecting the prefetch interrupt flag
 
-	badBlocks *lru.Cache // Bad block cache
+	halted   int32             // Block import halted due to a finality violation (atomic)
+	conflict *FinalityConflict // Conflicting branches that caused the halt
 }
 
 // NewBlockChain returns a fully initialised block chain using information
 // available in the database. It initialises the default Ethereum Validator and
 // Processor.
 func NewBlockChain(db ethdb.Database, cacheConfig *CacheConfig, chainConfig *params.ChainConfig, engine consensus.Engine, vmConfig vm.Config) (*BlockChain, error) {
+	if chainConfig != nil {
+		if err := vm.CheckPrecompiles(chainConfig); err != nil {
+			return nil, err
+		}
+		if err := vm.CheckGasOverrides(chainConfig); err != nil {
+			return nil, err
+		}
+	}
 	if cacheConfig == nil {
 		cacheConfig = &CacheConfig{
 			TrieNodeLimit: 256 * 1024 * 1024,
@@ -151,7 +176,7 @@ func NewBlockChain(db ethdb.Database, cacheConfig *CacheConfig, chainConfig *par
 		cacheConfig:  cacheConfig,
 		db:           db,
 		triegc:       prque.New(),
-		stateCache:   state.NewDatabase(db),
+		stateCache:   state.NewDatabaseWithCache(db, cacheConfig.TrieCleanLimit),
 		quit:         make(chan struct{}),
 		bodyCache:    bodyCache,
 		bodyRLPCache: bodyRLPCache,
@@ -163,6 +188,7 @@ func NewBlockChain(db ethdb.Database, cacheConfig *CacheConfig, chainConfig *par
 	}
 	bc.SetValidator(NewBlockValidator(chainConfig, bc, engine))
 	bc.SetProcessor(NewStateProcessor(chainConfig, bc, engine))
+	bc.prefetcher = newStatePrefetcher(chainConfig, bc)
 
 	var err error
 	bc.hc, err = NewHeaderChain(db, chainConfig, engine, bc.getProcInterrupt)
@@ -189,11 +215,110 @@ func NewBlockChain(db ethdb.Database, cacheConfig *CacheConfig, chainConfig *par
 			}
 		}
 	}
+	// Recover any transaction lookups lost by the background writer on a crash
+	if err := bc.repairIndex(); err != nil {
+		return nil, err
+	}
+	if cacheConfig.AsyncIndexing {
+		bc.indexer = newIndexWriter(db)
+	}
 	// Take ownership of this particular state
 	go bc.update()
 	return bc, nil
 }
 
+// repairIndex rewrites the transaction lookups of the blocks the background index
+// writer didn't get to before the node went down, starting from the last block it
+// marked as indexed. Lookups of blocks reorganised away since are dropped.
+func (bc *BlockChain) repairIndex() error {
+	head := bc.CurrentBlock()
+
+	hash := GetLastIndexedHash(bc.db)
+	if hash == (common.Hash{}) {
+		if bc.cacheConfig.AsyncIndexing {
+			return WriteLastIndexedHash(bc.db, head.Hash())
+		}
+		return nil
+	}
+	block := bc.GetBlockByHash(hash)
+	if block == nil {
+		return fmt.Errorf("last indexed block %x missing", hash)
+	}
+	// Drop the lookups of the indexed blocks no longer canonical
+	for GetCanonicalHash(bc.db, block.NumberU64()) != block.Hash() {
+		for _, tx := range block.Transactions() {
+			if indexed, _, _ := GetTxLookupEntry(bc.db, tx.Hash()); indexed == block.Hash() {
+				DeleteTxLookupEntry(bc.db, tx.Hash())
+			}
+		}
+		if block = bc.GetBlock(block.ParentHash(), block.NumberU64()-1); block == nil {
+			return fmt.Errorf("last indexed block %x disconnected", hash)
+		}
+	}
+	// Index the canonical blocks above the last indexed one
+	if block.NumberU64() < head.NumberU64() {
+		log.Info("Repairing transaction index", "from", block.NumberU64()+1, "to", head.NumberU64())
+	}
+	for number := block.NumberU64() + 1; number <= head.NumberU64(); number++ {
+		block := bc.GetBlockByNumber(number)
+		if block == nil {
+			return fmt.Errorf("canonical block #%d missing", number)
+		}
+		if err := WriteTxLookupEntries(bc.db, block); err != nil {
+			return err
+		}
+	}
+	if bc.cacheConfig.AsyncIndexing {
+		return WriteLastIndexedHash(bc.db, head.Hash())
+	}
+	DeleteLastIndexedHash(bc.db)
+	return nil
+}
+
+// PrefetchBlock executes the transactions of a block whose import is pending,
+// e.g. a proposal still being agreed on by validators, in the background on
+// throwaway state. This warms the trie node cache for the actual import of the
+// block. A prefetch still running for another block is aborted.
+func (bc *BlockChain) PrefetchBlock(block *types.Block) {
+	if atomic.LoadInt32(&bc.running) == 1 {
+		return
+	}
+	parent := bc.GetHeader(block.ParentHash(), block.NumberU64()-1)
+	if parent == nil {
+		return
+	}
+	statedb, err := state.New(parent.Root, bc.stateCache)
+	if err != nil {
+		return
+	}
+	bc.abortPrefetch()
+
+	interrupt := new(uint32)
+	bc.prefetchLock.Lock()
+	bc.prefetchInterrupt = interrupt
+	bc.prefetchLock.Unlock()
+
+	bc.wg.Add(1)
+	go func() {
+		defer bc.wg.Done()
+
+		start := time.Now()
+		bc.prefetcher.Prefetch(block, statedb, vm.Config{}, interrupt)
+		prefetchTimer.UpdateSince(start)
+	}()
+}
+
+// abortPrefetch interrupts the running block prefetch, if any.
+func (bc *BlockChain) abortPrefetch() {
+	bc.prefetchLock.Lock()
+	defer bc.prefetchLock.Unlock()
+
+	if bc.prefetchInterrupt != nil {
+		atomic.StoreUint32(bc.prefetchInterrupt, 1)
+		bc.prefetchInterrupt = nil
+	}
+}
+
 func (bc *BlockChain) getProcInterrupt() bool {
 	return atomic.LoadInt32(&bc.procInterrupt) == 1
 }
@@ -262,11 +387,29 @@ func (bc *BlockChain) loadLastState() error {
 // though, the head may be further rewound if block bodies are missing (non-archive
 // nodes after a fast sync).
 func (bc *BlockChain) SetHead(head uint64) error {
+	if err := bc.setHead(head); err != nil {
+		return err
+	}
+	// Let the consensus engine roll back its state outside of the chain lock
+	if rewinder, ok := bc.engine.(consensus.Rewinder); ok {
+		return rewinder.Rewind(bc, bc.CurrentHeader())
+	}
+	return nil
+}
+
+// setHead rewinds the chain itself, see SetHead.
+func (bc *BlockChain) setHead(head uint64) error {
 	log.Warn("Rewinding blockchain", "target", head)
 
 	bc.mu.Lock()
 	defer bc.mu.Unlock()
 
+	// Let the pending index writes land, the rewound head being marked indexed
+	if bc.indexer != nil {
+		bc.indexer.wait()
+		defer func() { WriteLastIndexedHash(bc.db, bc.CurrentBlock().Hash()) }()
+	}
+
 	// Rewind the header chain, deleting all block bodies until then
 	delFn := func(hash common.Hash, num uint64) {
 		DeleteBody(bc.db, hash, num)
@@ -387,6 +530,11 @@ func (bc *BlockChain) StateAt(root common.Hash) (*state.StateDB, error) {
 	return state.New(root, bc.stateCache)
 }
 
+// StateCache returns the caching database underpinning the blockchain instance.
+func (bc *BlockChain) StateCache() state.Database {
+	return bc.stateCache
+}
+
 // Reset purges the entire blockchain, restoring it to its genesis state.
 func (bc *BlockChain) Reset() error {
 	return bc.ResetWithGenesisBlock(bc.genesisBlock)
@@ -639,9 +787,13 @@ func (bc *BlockChain) Stop() {
 	bc.scope.Close()
 	close(bc.quit)
 	atomic.StoreInt32(&bc.procInterrupt, 1)
+	bc.abortPrefetch()
 
 	bc.wg.Wait()
 
+	if bc.indexer != nil {
+		bc.indexer.close()
+	}
 	// Ensure the state of a recent block is also stored to disk before exiting.
 	// We're writing three different states to catch different restart scenarios:
 	//  - HEAD:     So we don't need to reprocess any blocks in the general case
@@ -727,16 +879,18 @@ func SetReceiptsData(config *params.ChainConfig, block *types.Block, receipts ty
 	signer := types.MakeSigner(config, block.Number())
 
 	transactions, logIndex := block.Transactions(), uint(0)
-	if len(transactions) != len(receipts) {
+	if len(transactions) > len(receipts) {
 		return errors.New("transaction and receipt count mismatch")
 	}
-
+	// Receipts beyond the transactions are emitted by the consensus engine when
+	// finalizing the block, they carry no transaction to derive fields from
 	for j := 0; j < len(receipts); j++ {
 		// The transaction hash can be retrieved from the transaction itself
-		receipts[j].TxHash = transactions[j].Hash()
-
+		if j < len(transactions) {
+			receipts[j].TxHash = transactions[j].Hash()
+		}
 		// The contract address can be derived from the transaction itself
-		if transactions[j].To() == nil {
+		if j < len(transactions) && transactions[j].To() == nil {
 			// Deriving the signer is expensive, only do if it's actually needed
 			from, _ := types.Sender(signer, transactions[j])
 			receipts[j].ContractAddress = crypto.CreateAddress(from, transactions[j].Nonce())
@@ -888,12 +1042,13 @@ func (bc *BlockChain) WriteBlockWithState(block *types.Block, receipts []*types.
 	localTd := bc.GetTd(currentBlock.Hash(), currentBlock.NumberU64())
 	externTd := new(big.Int).Add(block.Difficulty(), ptd)
 
-	// Irrelevant of the canonical status, write the block itself to the database
-	if err := bc.hc.WriteTd(block.Hash(), block.NumberU64(), externTd); err != nil {
+	// Irrelevant of the canonical status, write the block itself to the database.
+	// All the block data is collected into a single batch, so that it's persisted
+	// atomically and with as few disk seeks as possible.
+	batch := bc.db.NewBatch()
+	if err := WriteTd(batch, block.Hash(), block.NumberU64(), externTd); err != nil {
 		return NonStatTy, err
 	}
-	// Write other block data using a batch.
-	batch := bc.db.NewBatch()
 	if err := WriteBlock(batch, block); err != nil {
 		return NonStatTy, err
 	}
@@ -903,9 +1058,10 @@ func (bc *BlockChain) WriteBlockWithState(block *types.Block, receipts []*types.
 	}
 	triedb := bc.stateCache.TrieDB()
 
-	// If we're running an archive node, always flush
+	// If we're running an archive node, always flush along with the block
+	var release func()
 	if bc.cacheConfig.Disabled {
-		if err := triedb.Commit(root, false); err != nil {
+		if release, err = triedb.CommitTo(root, batch); err != nil {
 			return NonStatTy, err
 		}
 	} else {
@@ -972,8 +1128,17 @@ func (bc *BlockChain) WriteBlockWithState(block *types.Block, receipts []*types.
 				return NonStatTy, err
 			}
 		}
-		// Write the positional metadata for transaction and receipt lookups
-		if err := WriteTxLookupEntries(batch, block); err != nil {
+		// Write the positional metadata for transaction and receipt lookups. In the
+		// background the block is marked indexed afterwards, so that lookups lost
+		// on a crash are rewritten on startup.
+		if bc.indexer != nil {
+			bc.indexer.write(func(db ethdb.Database) error {
+				if err := WriteTxLookupEntries(db, block); err != nil {
+					return err
+				}
+				return WriteLastIndexedHash(db, block.Hash())
+			})
+		} else if err := WriteTxLookupEntries(batch, block); err != nil {
 			return NonStatTy, err
 		}
 		// Write hash preimages
@@ -987,10 +1152,21 @@ func (bc *BlockChain) WriteBlockWithState(block *types.Block, receipts []*types.
 	if err := batch.Write(); err != nil {
 		return NonStatTy, err
 	}
+	blockWriteSizeMeter.Mark(int64(batch.ValueSize()))
 
+	bc.hc.tdCache.Add(block.Hash(), new(big.Int).Set(externTd))
+	if release != nil {
+		release()
+	}
 	// Set new head.
 	if status == CanonStatTy {
 		bc.insert(block)
+
+		if limit := block.GasLimit(); limit > 0 {
+			usage := int64(block.GasUsed() * 100 / limit)
+			blockGasUsageGauge.Update(usage)
+			blockGasUsageHistogram.Update(usage)
+		}
 	}
 	bc.futureBlocks.Remove(block.Hash())
 	return status, nil
@@ -1030,6 +1206,10 @@ func (bc *BlockChain) insertChain(chain types.Blocks) (int, []interface{}, []*ty
 	bc.chainmu.Lock()
 	defer bc.chainmu.Unlock()
 
+	// Refuse to import anything after a finalized block was contested
+	if atomic.LoadInt32(&bc.halted) == 1 {
+		return 0, nil, nil, ErrFinalityViolation
+	}
 	// A queued approach to delivering events. This is generally
 	// faster than direct delivery and requires much less mutex
 	// acquiring.
@@ -1310,6 +1490,10 @@ func (bc *BlockChain) reorg(oldBlock, newBlock *types.Block) error {
 			return fmt.Errorf("Invalid new chain")
 		}
 	}
+	// Never reorganise away finalized blocks
+	if err := bc.checkFinality(commonBlock, oldChain, newChain); err != nil {
+		return err
+	}
 	// Ensure the user sees large reorgs
 	if len(oldChain) > 0 && len(newChain) > 0 {
 		logFn := log.Debug
@@ -1327,7 +1511,10 @@ func (bc *BlockChain) reorg(oldBlock, newBlock *types.Block) error {
 		// insert the block in the canonical way, re-writing history
 		bc.insert(newChain[i])
 		// write lookup entries for hash based transaction/receipt searches
-		if err := WriteTxLookupEntries(bc.db, newChain[i]); err != nil {
+		if bc.indexer != nil {
+			block := newChain[i]
+			bc.indexer.write(func(db ethdb.Database) error { return WriteTxLookupEntries(db, block) })
+		} else if err := WriteTxLookupEntries(bc.db, newChain[i]); err != nil {
 			return err
 		}
 		addedTxs = append(addedTxs, newChain[i].Transactions()...)
@@ -1337,7 +1524,15 @@ func (bc *BlockChain) reorg(oldBlock, newBlock *types.Block) error {
 	// When transactions get deleted from the database that means the
 	// receipts that were created in the fork must also be deleted
 	for _, tx := range diff {
-		DeleteTxLookupEntry(bc.db, tx.Hash())
+		if bc.indexer != nil {
+			hash := tx.Hash()
+			bc.indexer.write(func(db ethdb.Database) error {
+				DeleteTxLookupEntry(db, hash)
+				return nil
+			})
+		} else {
+			DeleteTxLookupEntry(bc.db, tx.Hash())
+		}
 	}
 	if len(deletedLogs) > 0 {
 		go bc.rmLogsFeed.Send(RemovedLogsEvent{deletedLogs})
@@ -1419,6 +1614,7 @@ func (bc *BlockChain) addBadBlock(block *types.Block) {
 // reportBlock logs a bad block error.
 func (bc *BlockChain) reportBlock(block *types.Block, receipts types.Receipts, err error) {
 	bc.addBadBlock(block)
+	bc.writeBadBlockReport(block, receipts, err)
 
 	var receiptString string
 	for _, receipt := range receipts {
@@ -1338,3 +1338,182 @@ func TestLargeReorgTrieGC(t *testing.T) {
 		}
 	}
 }
+
+// Tests that archive nodes writing indexes in the background persist the state
+// of every block along with the block itself, and that the transaction lookups
+// end up matching the canonical chain across reorgs.
+func TestAsyncIndexing(t *testing.T) {
+	var (
+		key, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		address = crypto.PubkeyToAddress(key.PublicKey)
+		gspec   = &Genesis{Config: params.TestChainConfig, Alloc: GenesisAlloc{address: {Balance: big.NewInt(1000000000)}}}
+		signer  = types.NewEIP155Signer(gspec.Config.ChainId)
+	)
+	db, _ := ethdb.NewMemDatabase()
+	genesis := gspec.MustCommit(db)
+
+	transfer := func(to common.Address) func(int, *BlockGen) {
+		return func(i int, gen *BlockGen) {
+			tx, err := types.SignTx(types.NewTransaction(gen.TxNonce(address), to, big.NewInt(1000), params.TxGas, nil, nil), signer, key)
+			if err != nil {
+				t.Fatalf("failed to create tx: %v", err)
+			}
+			gen.AddTx(tx)
+		}
+	}
+	original, _ := GenerateChain(gspec.Config, genesis, ethash.NewFaker(), db, 4, transfer(common.Address{1}))
+	competitor, _ := GenerateChain(gspec.Config, genesis, ethash.NewFaker(), db, 5, transfer(common.Address{2}))
+
+	diskdb, _ := ethdb.NewMemDatabase()
+	gspec.MustCommit(diskdb)
+
+	chain, err := NewBlockChain(diskdb, &CacheConfig{Disabled: true, AsyncIndexing: true}, gspec.Config, ethash.NewFaker(), vm.Config{})
+	if err != nil {
+		t.Fatalf("failed to create tester chain: %v", err)
+	}
+	if _, err := chain.InsertChain(original); err != nil {
+		t.Fatalf("failed to insert original chain: %v", err)
+	}
+	for i, block := range original {
+		if _, err := diskdb.Get(block.Root().Bytes()); err != nil {
+			t.Errorf("block %d: state root not persisted: %v", i, err)
+		}
+	}
+	if size := chain.stateCache.TrieDB().Size(); size != 0 {
+		t.Errorf("persisted state still cached: %v", size)
+	}
+	if _, err := chain.InsertChain(competitor); err != nil {
+		t.Fatalf("failed to insert competitor chain: %v", err)
+	}
+	chain.Stop()
+
+	for i, block := range original {
+		if hash, _, _ := GetTxLookupEntry(diskdb, block.Transactions()[0].Hash()); hash != (common.Hash{}) {
+			t.Errorf("original block %d: reorged transaction still indexed", i)
+		}
+	}
+	for i, block := range competitor {
+		if hash, _, _ := GetTxLookupEntry(diskdb, block.Transactions()[0].Hash()); hash != block.Hash() {
+			t.Errorf("competitor block %d: transaction lookup mismatch: have %x, want %x", i, hash, block.Hash())
+		}
+	}
+}
+
+// Tests that transaction lookups the background index writer didn't get to before
+// a crash are repaired on startup, including the ones of blocks reorganised away.
+func TestAsyncIndexingRepair(t *testing.T) {
+	var (
+		key, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		address = crypto.PubkeyToAddress(key.PublicKey)
+		gspec   = &Genesis{Config: params.TestChainConfig, Alloc: GenesisAlloc{address: {Balance: big.NewInt(1000000000)}}}
+		signer  = types.NewEIP155Signer(gspec.Config.ChainId)
+	)
+	db, _ := ethdb.NewMemDatabase()
+	genesis := gspec.MustCommit(db)
+
+	transfer := func(to common.Address) func(int, *BlockGen) {
+		return func(i int, gen *BlockGen) {
+			tx, err := types.SignTx(types.NewTransaction(gen.TxNonce(address), to, big.NewInt(1000), params.TxGas, nil, nil), signer, key)
+			if err != nil {
+				t.Fatalf("failed to create tx: %v", err)
+			}
+			gen.AddTx(tx)
+		}
+	}
+	original, _ := GenerateChain(gspec.Config, genesis, ethash.NewFaker(), db, 4, transfer(common.Address{1}))
+	competitor, _ := GenerateChain(gspec.Config, genesis, ethash.NewFaker(), db, 5, transfer(common.Address{2}))
+
+	diskdb, _ := ethdb.NewMemDatabase()
+	gspec.MustCommit(diskdb)
+
+	chain, err := NewBlockChain(diskdb, &CacheConfig{Disabled: true, AsyncIndexing: true}, gspec.Config, ethash.NewFaker(), vm.Config{})
+	if err != nil {
+		t.Fatalf("failed to create tester chain: %v", err)
+	}
+	if _, err := chain.InsertChain(original); err != nil {
+		t.Fatalf("failed to insert original chain: %v", err)
+	}
+	if _, err := chain.InsertChain(competitor); err != nil {
+		t.Fatalf("failed to insert competitor chain: %v", err)
+	}
+	chain.Stop()
+
+	if hash := GetLastIndexedHash(diskdb); hash != competitor[4].Hash() {
+		t.Fatalf("last indexed block mismatch: have %x, want %x", hash, competitor[4].Hash())
+	}
+	// Simulate a crash before any index write of the reorg landed
+	for _, block := range original {
+		WriteTxLookupEntries(diskdb, block)
+	}
+	for _, block := range competitor {
+		DeleteTxLookupEntry(diskdb, block.Transactions()[0].Hash())
+	}
+	WriteLastIndexedHash(diskdb, original[3].Hash())
+
+	chain, err = NewBlockChain(diskdb, &CacheConfig{Disabled: true, AsyncIndexing: true}, gspec.Config, ethash.NewFaker(), vm.Config{})
+	if err != nil {
+		t.Fatalf("failed to reopen tester chain: %v", err)
+	}
+	defer chain.Stop()
+
+	for i, block := range original {
+		if hash, _, _ := GetTxLookupEntry(diskdb, block.Transactions()[0].Hash()); hash != (common.Hash{}) {
+			t.Errorf("original block %d: reorged transaction still indexed", i)
+		}
+	}
+	for i, block := range competitor {
+		if hash, _, _ := GetTxLookupEntry(diskdb, block.Transactions()[0].Hash()); hash != block.Hash() {
+			t.Errorf("competitor block %d: transaction lookup mismatch: have %x, want %x", i, hash, block.Hash())
+		}
+	}
+	if hash := GetLastIndexedHash(diskdb); hash != competitor[4].Hash() {
+		t.Errorf("repaired last indexed block mismatch: have %x, want %x", hash, competitor[4].Hash())
+	}
+}
+
+// Tests that prefetching a pending block leaves the chain untouched, and that
+// the block imports fine afterwards.
+func TestPrefetchBlock(t *testing.T) {
+	var (
+		key, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		address = crypto.PubkeyToAddress(key.PublicKey)
+		gspec   = &Genesis{Config: params.TestChainConfig, Alloc: GenesisAlloc{address: {Balance: big.NewInt(1000000000)}}}
+		signer  = types.NewEIP155Signer(gspec.Config.ChainId)
+	)
+	db, _ := ethdb.NewMemDatabase()
+	genesis := gspec.MustCommit(db)
+
+	blocks, _ := GenerateChain(gspec.Config, genesis, ethash.NewFaker(), db, 2, func(i int, gen *BlockGen) {
+		tx, err := types.SignTx(types.NewTransaction(gen.TxNonce(address), common.Address{1}, big.NewInt(1000), params.TxGas, nil, nil), signer, key)
+		if err != nil {
+			t.Fatalf("failed to create tx: %v", err)
+		}
+		gen.AddTx(tx)
+	})
+	diskdb, _ := ethdb.NewMemDatabase()
+	gspec.MustCommit(diskdb)
+
+	chain, err := NewBlockChain(diskdb, &CacheConfig{Disabled: true, TrieCleanLimit: 16}, gspec.Config, ethash.NewFaker(), vm.Config{})
+	if err != nil {
+		t.Fatalf("failed to create tester chain: %v", err)
+	}
+	defer chain.Stop()
+
+	if _, err := chain.InsertChain(blocks[:1]); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	chain.PrefetchBlock(blocks[1])
+	chain.PrefetchBlock(blocks[1]) // aborts the first prefetch
+	chain.wg.Wait()
+
+	if head := chain.CurrentBlock(); head.Hash() != blocks[0].Hash() {
+		t.Fatalf("head changed by prefetch: have #%d, want #%d", head.NumberU64(), blocks[0].NumberU64())
+	}
+	statedb, _ := chain.State()
+	if nonce := statedb.GetNonce(address); nonce != 1 {
+		t.Errorf("head state changed by prefetch: nonce %d, want 1", nonce)
+	}
+	if _, err := chain.InsertChain(blocks[1:]); err != nil {
+		t.Fatalf("failed to insert prefetched block: %v", err)
+	}
+}
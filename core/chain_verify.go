@@ -0,0 +1,257 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// ChainProblem is an inconsistency of the canonical chain found in the database.
+type ChainProblem struct {
+	Number     uint64
+	Hash       common.Hash
+	Problem    string
+	Repairable bool // Whether the entry can be regenerated from the block data
+	Repaired   bool
+}
+
+func (p *ChainProblem) String() string {
+	status := "unrepairable"
+	switch {
+	case p.Repaired:
+		status = "repaired"
+	case p.Repairable:
+		status = "repairable"
+	}
+	return fmt.Sprintf("#%d [%x…]: %s (%s)", p.Number, p.Hash[:4], p.Problem, status)
+}
+
+// ChainVerification is the outcome of walking the canonical chain in the database.
+type ChainVerification struct {
+	From, To        uint64
+	Checked         uint64 // Number of blocks checked
+	MissingReceipts uint64 // Number of blocks without receipts (pruned or never stored)
+	Problems        []*ChainProblem
+}
+
+// Unrepaired returns the number of problems left in the database.
+func (v *ChainVerification) Unrepaired() int {
+	count := 0
+	for _, problem := range v.Problems {
+		if !problem.Repaired {
+			count++
+		}
+	}
+	return count
+}
+
+// VerifyChain walks the canonical chain stored in the database from the given
+// block up to the head block, checking the hash and number indexes, the links
+// between the blocks, the transaction and receipt roots, the total difficulties
+// and the transaction lookup entries. If an engine is given, the seals of the
+// headers are verified too, reading the ancestors through chain.
+//
+// If repair is set, the total difficulties and the indexes which can be derived
+// from the block data are rewritten; broken block data is only reported.
+func VerifyChain(db ethdb.Database, chain consensus.ChainReader, engine consensus.Engine, from uint64, repair bool) (*ChainVerification, error) {
+	head := GetHeadBlockHash(db)
+	if head == (common.Hash{}) {
+		return nil, errors.New("no head block")
+	}
+	to := GetBlockNumber(db, head)
+	if to == missingNumber {
+		return nil, fmt.Errorf("head block %x not indexed", head)
+	}
+	if from > to {
+		return nil, fmt.Errorf("start block #%d beyond head block #%d", from, to)
+	}
+	result := &ChainVerification{From: from, To: to}
+
+	var (
+		parent   *types.Header
+		parentTd *big.Int
+		start    = time.Now()
+		logged   = time.Now()
+	)
+	for number := from; number <= to; number++ {
+		hash, problems := verifyCanonicalHash(db, number, repair)
+		result.Problems = append(result.Problems, problems...)
+		if hash == (common.Hash{}) {
+			parent, parentTd = nil, nil
+			continue
+		}
+		header, problems := verifyBlock(db, hash, number, parent, repair)
+		result.Problems = append(result.Problems, problems...)
+		result.Checked++
+
+		if header == nil {
+			parent, parentTd = nil, nil
+			continue
+		}
+		// Receipts may have been pruned, only check the ones present
+		if receipts := GetBlockReceipts(db, hash, number); receipts != nil {
+			if root := types.DeriveSha(receipts); root != header.ReceiptHash {
+				result.Problems = append(result.Problems, &ChainProblem{Number: number, Hash: hash, Problem: fmt.Sprintf("receipt root mismatch: have %x, want %x", root, header.ReceiptHash)})
+			}
+		} else if header.ReceiptHash != types.EmptyRootHash {
+			result.MissingReceipts++
+		}
+		td, problems := verifyTd(db, header, parentTd, repair)
+		result.Problems = append(result.Problems, problems...)
+
+		if engine != nil && number > 0 {
+			if err := engine.VerifyHeader(chain, header, true); err != nil {
+				result.Problems = append(result.Problems, &ChainProblem{Number: number, Hash: hash, Problem: fmt.Sprintf("invalid seal: %v", err)})
+			}
+		}
+		parent, parentTd = header, td
+
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Verifying chain", "number", number, "head", to, "problems", len(result.Problems), "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+	}
+	return result, nil
+}
+
+// verifyCanonicalHash retrieves the canonical hash at the given height. A missing
+// entry is restored from the parent hash of the next canonical header if repair
+// is set.
+func verifyCanonicalHash(db ethdb.Database, number uint64, repair bool) (common.Hash, []*ChainProblem) {
+	if hash := GetCanonicalHash(db, number); hash != (common.Hash{}) {
+		return hash, nil
+	}
+	problem := &ChainProblem{Number: number, Problem: "missing canonical hash"}
+
+	if next := GetCanonicalHash(db, number+1); next != (common.Hash{}) {
+		if header := GetHeader(db, next, number+1); header != nil && GetHeader(db, header.ParentHash, number) != nil {
+			problem.Hash, problem.Repairable = header.ParentHash, true
+		}
+	}
+	if !problem.Repairable || !repair {
+		return common.Hash{}, []*ChainProblem{problem}
+	}
+	if err := WriteCanonicalHash(db, problem.Hash, number); err != nil {
+		log.Error("Failed to repair canonical hash", "number", number, "err", err)
+		return common.Hash{}, []*ChainProblem{problem}
+	}
+	problem.Repaired = true
+	return problem.Hash, []*ChainProblem{problem}
+}
+
+// verifyBlock checks the header and body of a canonical block, along with its
+// number index, parent link and transaction lookup entries. The header
+// is returned if it's present and matches the hash.
+func verifyBlock(db ethdb.Database, hash common.Hash, number uint64, parent *types.Header, repair bool) (*types.Header, []*ChainProblem) {
+	var problems []*ChainProblem
+	report := func(problem string, repairable bool, fix func() error) {
+		p := &ChainProblem{Number: number, Hash: hash, Problem: problem, Repairable: repairable}
+		if repairable && repair {
+			if err := fix(); err != nil {
+				log.Error("Failed to repair chain entry", "number", number, "hash", hash, "problem", problem, "err", err)
+			} else {
+				p.Repaired = true
+			}
+		}
+		problems = append(problems, p)
+	}
+	header := GetHeader(db, hash, number)
+	if header == nil {
+		report("missing header", false, nil)
+		return nil, problems
+	}
+	if header.Hash() != hash {
+		report(fmt.Sprintf("header hash mismatch: have %x", header.Hash()), false, nil)
+		return nil, problems
+	}
+	if header.Number.Uint64() != number {
+		report(fmt.Sprintf("header number mismatch: have %d", header.Number), false, nil)
+		return nil, problems
+	}
+	if indexed := GetBlockNumber(db, hash); indexed != number {
+		report("broken block number index", true, func() error { return WriteHeader(db, header) })
+	}
+	if parent != nil && header.ParentHash != parent.Hash() {
+		report(fmt.Sprintf("parent hash mismatch: have %x, want %x", header.ParentHash, parent.Hash()), false, nil)
+	}
+	body := GetBody(db, hash, number)
+	if body == nil {
+		report("missing body", false, nil)
+		return header, problems
+	}
+	if root := types.DeriveSha(types.Transactions(body.Transactions)); root != header.TxHash {
+		report(fmt.Sprintf("transaction root mismatch: have %x, want %x", root, header.TxHash), false, nil)
+	}
+	if uncles := types.CalcUncleHash(body.Uncles); uncles != header.UncleHash {
+		report(fmt.Sprintf("uncle hash mismatch: have %x, want %x", uncles, header.UncleHash), false, nil)
+	}
+	block := types.NewBlockWithHeader(header).WithBody(body.Transactions, body.Uncles)
+	for i, tx := range body.Transactions {
+		if lookupHash, lookupNumber, index := GetTxLookupEntry(db, tx.Hash()); lookupHash != hash || lookupNumber != number || index != uint64(i) {
+			report("broken transaction lookup entries", true, func() error { return WriteTxLookupEntries(db, block) })
+			break
+		}
+	}
+	return header, problems
+}
+
+// verifyTd checks the total difficulty of a canonical block against the one of
+// its parent, returning the correct value. If the parent's is unknown (e.g. at
+// the start of the walk), the stored one is trusted.
+func verifyTd(db ethdb.Database, header *types.Header, parentTd *big.Int, repair bool) (*big.Int, []*ChainProblem) {
+	hash, number := header.Hash(), header.Number.Uint64()
+
+	have := GetTd(db, hash, number)
+
+	var want *big.Int
+	switch {
+	case parentTd != nil:
+		want = new(big.Int).Add(parentTd, header.Difficulty)
+	case have != nil:
+		// Start of the walk or genesis (whose stored value may differ from its difficulty)
+	case number > 0:
+		if td := GetTd(db, header.ParentHash, number-1); td != nil {
+			want = new(big.Int).Add(td, header.Difficulty)
+		}
+	default:
+		want = header.Difficulty
+	}
+	if have != nil && (want == nil || have.Cmp(want) == 0) {
+		return have, nil
+	}
+	problem := &ChainProblem{Number: number, Hash: hash, Problem: "missing total difficulty", Repairable: want != nil}
+	if have != nil {
+		problem.Problem = fmt.Sprintf("total difficulty mismatch: have %v, want %v", have, want)
+	}
+	if problem.Repairable && repair {
+		if err := WriteTd(db, hash, number, want); err != nil {
+			log.Error("Failed to repair total difficulty", "number", number, "hash", hash, "err", err)
+		} else {
+			problem.Repaired = true
+		}
+	}
+	return want, []*ChainProblem{problem}
+}
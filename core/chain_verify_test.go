@@ -0,0 +1,98 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// Tests that corrupted indexes and total difficulties are found and repaired,
+// while broken block data is only reported.
+func TestVerifyChain(t *testing.T) {
+	var (
+		db, _   = ethdb.NewMemDatabase()
+		key, _  = crypto.GenerateKey()
+		address = crypto.PubkeyToAddress(key.PublicKey)
+		gspec   = &Genesis{Config: params.TestChainConfig, Alloc: GenesisAlloc{address: {Balance: big.NewInt(1000000000)}}}
+		genesis = gspec.MustCommit(db)
+		signer  = types.NewEIP155Signer(gspec.Config.ChainId)
+	)
+	blocks, receipts := GenerateChain(gspec.Config, genesis, ethash.NewFaker(), db, 10, func(i int, block *BlockGen) {
+		tx, _ := types.SignTx(types.NewTransaction(block.TxNonce(address), common.Address{0x01}, big.NewInt(1000), params.TxGas, nil, nil), signer, key)
+		block.AddTx(tx)
+	})
+	blockchain, _ := NewBlockChain(db, nil, gspec.Config, ethash.NewFaker(), vm.Config{})
+	if _, err := blockchain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	blockchain.Stop()
+
+	result, err := VerifyChain(db, blockchain, ethash.NewFaker(), 0, false)
+	if err != nil {
+		t.Fatalf("failed to verify sound chain: %v", err)
+	}
+	if result.Checked != 11 || len(result.Problems) != 0 {
+		t.Fatalf("sound chain: checked %d blocks, problems %v", result.Checked, result.Problems)
+	}
+	// Corrupt the database and check that every problem is found
+	DeleteCanonicalHash(db, 2)
+	db.Put(append(blockHashPrefix, blocks[3].Hash().Bytes()...), encodeBlockNumber(100))
+	DeleteTd(db, blocks[5].Hash(), 6)
+	WriteTd(db, blocks[6].Hash(), 7, big.NewInt(1))
+	DeleteTxLookupEntry(db, blocks[7].Transactions()[0].Hash())
+	WriteBlockReceipts(db, blocks[8].Hash(), 9, append(receipts[0], receipts[1]...))
+
+	result, err = VerifyChain(db, blockchain, nil, 1, false)
+	if err != nil {
+		t.Fatalf("failed to verify corrupted chain: %v", err)
+	}
+	if len(result.Problems) != 6 {
+		t.Fatalf("problem count mismatch: have %v, want 6", result.Problems)
+	}
+	for i, number := range []uint64{2, 4, 6, 7, 8, 9} {
+		if problem := result.Problems[i]; problem.Number != number || problem.Repaired || problem.Repairable != (number != 9) {
+			t.Errorf("problem %d: have %v, want block #%d", i, problem, number)
+		}
+	}
+	// Repair the database and check that only the broken receipts remain
+	result, err = VerifyChain(db, blockchain, nil, 1, true)
+	if err != nil {
+		t.Fatalf("failed to repair chain: %v", err)
+	}
+	if result.Unrepaired() != 1 {
+		t.Errorf("unrepaired problem count mismatch: have %d, want 1", result.Unrepaired())
+	}
+	result, err = VerifyChain(db, blockchain, nil, 1, false)
+	if err != nil {
+		t.Fatalf("failed to verify repaired chain: %v", err)
+	}
+	if len(result.Problems) != 1 || result.Problems[0].Number != 9 {
+		t.Errorf("repaired chain: problems %v, want receipt root mismatch of block #9", result.Problems)
+	}
+	if td := GetTd(db, blocks[6].Hash(), 7); td.Cmp(blockchain.GetTdByHash(blocks[6].Hash())) != 0 {
+		t.Errorf("total difficulty not repaired: have %v", td)
+	}
+}
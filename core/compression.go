@@ -0,0 +1,174 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/golang/snappy"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+// Compression is the algorithm compressing the block bodies and receipts written
+// into the database. Compressed entries are tagged with the algorithm, so reads
+// decompress them transparently whatever the current setting.
+type Compression string
+
+const (
+	CompressionNone   Compression = "none"   // Entries are stored as plain RLP
+	CompressionSnappy Compression = "snappy" // Entries are snappy compressed, trading a little CPU for disk space
+)
+
+// Tags prepended to compressed entries. Plain entries are RLP lists, starting
+// with a byte of 0xc0 or above, so any lower tag is unambiguous.
+const (
+	noneTag   = 0x00 // Placeholder for disabled compression, never stored
+	snappyTag = 0x01
+)
+
+// blockCompression is the tag of the compression of the block bodies and
+// receipts written from now on (atomic).
+var blockCompression uint32
+
+// compressionTag returns the storage tag of a compression algorithm.
+func compressionTag(compression Compression) (byte, error) {
+	switch compression {
+	case "", CompressionNone:
+		return noneTag, nil
+	case CompressionSnappy:
+		return snappyTag, nil
+	}
+	return 0, fmt.Errorf("unknown block compression %q", compression)
+}
+
+// SetBlockCompression sets the compression of the block bodies and receipts
+// written from now on by the process. Existing entries are left as they are,
+// see RecompressBlockData for converting them.
+func SetBlockCompression(compression Compression) error {
+	tag, err := compressionTag(compression)
+	if err != nil {
+		return err
+	}
+	atomic.StoreUint32(&blockCompression, uint32(tag))
+	return nil
+}
+
+// compressBlockData compresses an RLP encoded block body or receipt list with
+// the configured algorithm.
+func compressBlockData(data []byte) []byte {
+	return compressWith(byte(atomic.LoadUint32(&blockCompression)), data)
+}
+
+// compressWith compresses an RLP encoded block body or receipt list with the
+// given algorithm, keeping it plain if compression doesn't shrink it.
+func compressWith(tag byte, data []byte) []byte {
+	if tag != snappyTag || len(data) == 0 {
+		return data
+	}
+	compressed := make([]byte, 1+snappy.MaxEncodedLen(len(data)))
+	compressed[0] = tag
+	compressed = compressed[:1+len(snappy.Encode(compressed[1:], data))]
+	if len(compressed) >= len(data) {
+		return data
+	}
+	return compressed
+}
+
+// decompressBlockData returns the RLP encoding of a stored block body or receipt
+// list, whether compressed or not.
+func decompressBlockData(data []byte) ([]byte, error) {
+	if len(data) == 0 || data[0] >= 0xc0 {
+		return data, nil
+	}
+	switch data[0] {
+	case snappyTag:
+		return snappy.Decode(nil, data[1:])
+	}
+	return nil, fmt.Errorf("unknown compression tag %#x", data[0])
+}
+
+// RecompressBlockData converts all the block bodies and receipts stored in the
+// database to the given compression, returning the number of entries rewritten.
+// The stored size before and after the conversion is reported in the logs.
+func RecompressBlockData(db *ethdb.LDBDatabase, compression Compression) (int, error) {
+	tag, err := compressionTag(compression)
+	if err != nil {
+		return 0, err
+	}
+	var (
+		converted        int
+		before, after    common.StorageSize
+		start            = time.Now()
+		logged           = time.Now()
+		batch            = db.NewBatch()
+		prefixes         = [][]byte{bodyPrefix, blockReceiptsPrefix}
+		numberHashLength = 8 + common.HashLength
+	)
+	for _, prefix := range prefixes {
+		it := db.LDB().NewIterator(util.BytesPrefix(prefix), nil)
+		for it.Next() {
+			// Skip any entries which are not bodies or receipts (name clash with other prefixes)
+			key, value := it.Key(), it.Value()
+			if len(key) != len(prefix)+numberHashLength {
+				continue
+			}
+			data, err := decompressBlockData(value)
+			if err != nil {
+				log.Warn("Skipping undecodable block data", "key", common.Bytes2Hex(key), "err", err)
+				continue
+			}
+			before += common.StorageSize(len(value))
+			recoded := compressWith(tag, data)
+			after += common.StorageSize(len(recoded))
+
+			if bytes.Equal(recoded, value) {
+				continue
+			}
+			if err := batch.Put(common.CopyBytes(key), recoded); err != nil {
+				it.Release()
+				return converted, err
+			}
+			if batch.ValueSize() > ethdb.IdealBatchSize {
+				if err := batch.Write(); err != nil {
+					it.Release()
+					return converted, err
+				}
+				batch.Reset()
+			}
+			converted++
+			if time.Since(logged) > 8*time.Second {
+				log.Info("Recompressing block data", "compression", compression, "converted", converted, "before", before, "after", after, "elapsed", common.PrettyDuration(time.Since(start)))
+				logged = time.Now()
+			}
+		}
+		it.Release()
+		if err := it.Error(); err != nil {
+			return converted, err
+		}
+	}
+	if err := batch.Write(); err != nil {
+		return converted, err
+	}
+	log.Info("Recompressed block data", "compression", compression, "converted", converted, "before", before, "after", after, "elapsed", common.PrettyDuration(time.Since(start)))
+	return converted, nil
+}
@@ -0,0 +1,136 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// compressibleBody returns a block body with a few similar transactions.
+func compressibleBody() *types.Body {
+	body := new(types.Body)
+	for i := 0; i < 8; i++ {
+		body.Transactions = append(body.Transactions, types.NewTransaction(uint64(i), common.Address{0x01}, big.NewInt(1), 21000, big.NewInt(1), make([]byte, 256)))
+	}
+	return body
+}
+
+// compressibleReceipts returns receipts with a few similar logs.
+func compressibleReceipts() types.Receipts {
+	receipt := &types.Receipt{Status: types.ReceiptStatusSuccessful, CumulativeGasUsed: 21000}
+	for i := 0; i < 8; i++ {
+		receipt.Logs = append(receipt.Logs, &types.Log{Address: common.Address{0x01}, Topics: []common.Hash{{0x02}}, Data: make([]byte, 128)})
+	}
+	return types.Receipts{receipt}
+}
+
+// Tests that compressed bodies and receipts are transparently decompressed, and
+// that plain ones stay readable whatever the setting.
+func TestBlockCompression(t *testing.T) {
+	defer SetBlockCompression(CompressionNone)
+
+	db, _ := ethdb.NewMemDatabase()
+	body, receipts := compressibleBody(), compressibleReceipts()
+	plain, _ := rlp.EncodeToBytes(body)
+
+	for i, compression := range []Compression{CompressionNone, CompressionSnappy} {
+		if err := SetBlockCompression(compression); err != nil {
+			t.Fatalf("failed to set compression %s: %v", compression, err)
+		}
+		hash := common.Hash{byte(i)}
+		WriteBody(db, hash, 1, body)
+		WriteBlockReceipts(db, hash, 1, receipts)
+
+		raw, _ := db.Get(blockBodyKey(hash, 1))
+		if compressed := raw[0] == snappyTag; compressed != (compression == CompressionSnappy) {
+			t.Errorf("%s: body compressed: %v", compression, compressed)
+		}
+		if compression == CompressionSnappy && len(raw) >= len(plain) {
+			t.Errorf("%s: body not shrunk: have %d bytes, plain %d", compression, len(raw), len(plain))
+		}
+	}
+	SetBlockCompression(CompressionNone)
+	for i := 0; i < 2; i++ {
+		hash := common.Hash{byte(i)}
+		if data := GetBodyRLP(db, hash, 1); !bytes.Equal(data, plain) {
+			t.Errorf("entry %d: body RLP mismatch", i)
+		}
+		if have := GetBody(db, hash, 1); have == nil || len(have.Transactions) != len(body.Transactions) {
+			t.Errorf("entry %d: body mismatch: have %v", i, have)
+		}
+		if have := GetBlockReceipts(db, hash, 1); len(have) != 1 || len(have[0].Logs) != len(receipts[0].Logs) {
+			t.Errorf("entry %d: receipts mismatch: have %v", i, have)
+		}
+	}
+	if err := SetBlockCompression("zip"); err == nil {
+		t.Errorf("unknown compression accepted")
+	}
+}
+
+// Tests that the stored block data is converted between compressions.
+func TestRecompressBlockData(t *testing.T) {
+	dir, err := ioutil.TempDir("", "compression-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := ethdb.NewLDBDatabase(dir, 0, 0)
+	if err != nil {
+		t.Fatalf("failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	body, receipts := compressibleBody(), compressibleReceipts()
+	for i := 0; i < 4; i++ {
+		WriteBody(db, common.Hash{byte(i)}, uint64(i), body)
+		WriteBlockReceipts(db, common.Hash{byte(i)}, uint64(i), receipts)
+	}
+	// Unrelated entries sharing the prefixes must be left alone
+	db.Put([]byte("body-unrelated"), []byte{0x01})
+
+	for _, compression := range []Compression{CompressionSnappy, CompressionNone} {
+		converted, err := RecompressBlockData(db, compression)
+		if err != nil {
+			t.Fatalf("%s: failed to convert block data: %v", compression, err)
+		}
+		if converted != 8 {
+			t.Errorf("%s: converted entry count mismatch: have %d, want 8", compression, converted)
+		}
+		for i := 0; i < 4; i++ {
+			raw, _ := db.Get(blockBodyKey(common.Hash{byte(i)}, uint64(i)))
+			if compressed := raw[0] == snappyTag; compressed != (compression == CompressionSnappy) {
+				t.Errorf("%s: body %d compressed: %v", compression, i, compressed)
+			}
+			if have := GetBlockReceipts(db, common.Hash{byte(i)}, uint64(i)); len(have) != 1 {
+				t.Errorf("%s: receipts %d unreadable", compression, i)
+			}
+		}
+		if data, _ := db.Get([]byte("body-unrelated")); !bytes.Equal(data, []byte{0x01}) {
+			t.Errorf("%s: unrelated entry modified: %x", compression, data)
+		}
+	}
+}
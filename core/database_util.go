@@ -48,6 +48,7 @@ var (
 	headBlockKey  = []byte("LastBlock")
 	headFastKey   = []byte("LastFast")
 	trieSyncKey   = []byte("TrieSync")
+	lastIndexKey  = []byte("LastIndexed")
 
 	// Data item prefixes (use single byte to avoid mixing data types, avoid `i`).
 	headerPrefix        = []byte("h") // headerPrefix + num (uint64 big endian) + hash -> header
@@ -63,7 +64,8 @@ var (
 	configPrefix   = []byte("ethereum-config-") // config prefix for the db
 
 	// Chain index prefixes (use `i` + single byte to avoid mixing data types).
-	BloomBitsIndexPrefix = []byte("iB") // BloomBitsIndexPrefix is the data table of a chain indexer to track its progress
+	BloomBitsIndexPrefix    = []byte("iB") // BloomBitsIndexPrefix is the data table of a chain indexer to track its progress
+	ReceiptPruneIndexPrefix = []byte("iR") // ReceiptPruneIndexPrefix is the data table of the receipt pruner to track its progress
 
 	// used by old db, now only used for conversion
 	oldReceiptsPrefix = []byte("receipts-")
@@ -147,6 +149,16 @@ func GetHeadFastBlockHash(db DatabaseReader) common.Hash {
 	return common.BytesToHash(data)
 }
 
+// GetLastIndexedHash retrieves the hash of the last block whose transaction
+// lookups were written by the background index writer.
+func GetLastIndexedHash(db DatabaseReader) common.Hash {
+	data, _ := db.Get(lastIndexKey)
+	if len(data) == 0 {
+		return common.Hash{}
+	}
+	return common.BytesToHash(data)
+}
+
 // GetTrieSyncProgress retrieves the number of tries nodes fast synced to allow
 // reportinc correct numbers across restarts.
 func GetTrieSyncProgress(db DatabaseReader) uint64 {
@@ -179,9 +191,15 @@ func GetHeader(db DatabaseReader, hash common.Hash, number uint64) *types.Header
 	return header
 }
 
-// GetBodyRLP retrieves the block body (transactions and uncles) in RLP encoding.
+// GetBodyRLP retrieves the block body (transactions and uncles) in RLP encoding,
+// decompressing it if needed.
 func GetBodyRLP(db DatabaseReader, hash common.Hash, number uint64) rlp.RawValue {
 	data, _ := db.Get(blockBodyKey(hash, number))
+	data, err := decompressBlockData(data)
+	if err != nil {
+		log.Error("Invalid compressed block body", "hash", hash, "err", err)
+		return nil
+	}
 	return data
 }
 
@@ -247,6 +265,11 @@ func GetBlock(db DatabaseReader, hash common.Hash, number uint64) *types.Block {
 // in a block given by its hash.
 func GetBlockReceipts(db DatabaseReader, hash common.Hash, number uint64) types.Receipts {
 	data, _ := db.Get(append(append(blockReceiptsPrefix, encodeBlockNumber(number)...), hash[:]...))
+	data, err := decompressBlockData(data)
+	if err != nil {
+		log.Error("Invalid compressed receipt array", "hash", hash, "err", err)
+		return nil
+	}
 	if len(data) == 0 {
 		return nil
 	}
@@ -385,6 +408,15 @@ func WriteHeadFastBlockHash(db ethdb.Putter, hash common.Hash) error {
 	return nil
 }
 
+// WriteLastIndexedHash stores the hash of the last block whose transaction lookups
+// were written by the background index writer.
+func WriteLastIndexedHash(db ethdb.Putter, hash common.Hash) error {
+	if err := db.Put(lastIndexKey, hash.Bytes()); err != nil {
+		log.Crit("Failed to store last indexed block's hash", "err", err)
+	}
+	return nil
+}
+
 // WriteTrieSyncProgress stores the fast sync trie process counter to support
 // retrieving it across restarts.
 func WriteTrieSyncProgress(db ethdb.Putter, count uint64) error {
@@ -423,10 +455,12 @@ func WriteBody(db ethdb.Putter, hash common.Hash, number uint64, body *types.Bod
 	return WriteBodyRLP(db, hash, number, data)
 }
 
-// WriteBodyRLP writes a serialized body of a block into the database.
+// WriteBodyRLP writes a serialized body of a block into the database, compressed
+// as configured by SetBlockCompression.
 func WriteBodyRLP(db ethdb.Putter, hash common.Hash, number uint64, rlp rlp.RawValue) error {
 	key := append(append(bodyPrefix, encodeBlockNumber(number)...), hash.Bytes()...)
-	if err := db.Put(key, rlp); err != nil {
+	data := compressBlockData(rlp)
+	if err := db.Put(key, data); err != nil {
 		log.Crit("Failed to store block body", "err", err)
 	}
 	return nil
@@ -471,9 +505,9 @@ func WriteBlockReceipts(db ethdb.Putter, hash common.Hash, number uint64, receip
 	if err != nil {
 		return err
 	}
-	// Store the flattened receipt slice
+	// Store the flattened receipt slice, compressed if configured
 	key := append(append(blockReceiptsPrefix, encodeBlockNumber(number)...), hash.Bytes()...)
-	if err := db.Put(key, bytes); err != nil {
+	if err := db.Put(key, compressBlockData(bytes)); err != nil {
 		log.Crit("Failed to store block receipts", "err", err)
 	}
 	return nil
@@ -513,6 +547,12 @@ func WriteBloomBits(db ethdb.Putter, bit uint, section uint64, head common.Hash,
 	}
 }
 
+// DeleteLastIndexedHash removes the marker of the last block indexed in the
+// background, once lookups are written along with the blocks again.
+func DeleteLastIndexedHash(db DatabaseDeleter) {
+	db.Delete(lastIndexKey)
+}
+
 // DeleteCanonicalHash removes the number to hash canonical mapping.
 func DeleteCanonicalHash(db DatabaseDeleter, number uint64) {
 	db.Delete(append(append(headerPrefix, encodeBlockNumber(number)...), numSuffix...))
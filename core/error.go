@@ -29,6 +29,14 @@ var (
 	// ErrBlacklistedHash is returned if a block to import is on the blacklist.
 	ErrBlacklistedHash = errors.New("blacklisted hash")
 
+	// ErrFinalityViolation is returned if a block to import would reorganise away
+	// a finalized block, or if block import was halted due to such an attempt.
+	ErrFinalityViolation = errors.New("finalized block reorg")
+
+	// ErrFinalizedRewind is returned if rewinding the chain would drop finalized
+	// blocks without this being explicitly allowed.
+	ErrFinalizedRewind = errors.New("rewind drops finalized blocks")
+
 	// ErrNonceTooHigh is returned if the nonce of a transaction is higher than the
 	// next one expected based on the local chain.
 	ErrNonceTooHigh = errors.New("nonce too high")
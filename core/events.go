@@ -24,6 +24,29 @@ import (
 // TxPreEvent is posted when a transaction enters the transaction pool.
 type TxPreEvent struct{ Tx *types.Transaction }
 
+// TxDropReason tells why a transaction left the transaction pool without being
+// included in a block.
+type TxDropReason string
+
+const (
+	TxDropInvalid     TxDropReason = "invalid"     // Failed validation when being added
+	TxDropUnderpriced TxDropReason = "underpriced" // Rejected or evicted in favour of better paying transactions
+	TxDropReplaced    TxDropReason = "replaced"    // Replaced by a transaction with the same nonce and a higher price
+	TxDropUnpayable   TxDropReason = "unpayable"   // Sender can no longer afford the transaction
+	TxDropPoolFull    TxDropReason = "pool-full"   // Evicted to keep the pool or an account within its limits
+	TxDropNonceGap    TxDropReason = "nonce-gap"   // Queued behind a missing nonce for longer than the pool lifetime
+)
+
+// TxDropEvent is posted when a transaction is rejected by or dropped from the
+// transaction pool. Transactions leaving the pool because their nonce has been
+// used by an included transaction are not reported.
+type TxDropEvent struct {
+	Tx          *types.Transaction
+	Reason      TxDropReason
+	Err         error              // Rejection error, nil for evicted transactions
+	Replacement *types.Transaction // Transaction replacing the dropped one, if any
+}
+
 // PendingLogsEvent is posted pre mining and notifies of pending logs.
 type PendingLogsEvent struct {
 	Logs []*types.Log
@@ -52,3 +75,6 @@ type ChainSideEvent struct {
 }
 
 type ChainHeadEvent struct{ Block *types.Block }
+
+// FinalityViolationEvent is posted when a reorg of a finalized block is attempted.
+type FinalityViolationEvent struct{ Conflict *FinalityConflict }
@@ -23,6 +23,9 @@ import (
 	"github.com/ethereum/go-ethereum/consensus"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/core/vm"
+
+	// Register the extension precompiled contracts chain configs may enable
+	_ "github.com/ethereum/go-ethereum/core/vm/extensions"
 )
 
 // ChainContext supports retrieving headers and consensus parameters from the
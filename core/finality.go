@@ -0,0 +1,113 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+var finalityViolationMeter = metrics.NewRegisteredMeter("chain/finality/violations", nil)
+
+// FinalityConflict describes two conflicting branches, both of which carry valid
+// finality proofs. For engines with explicit finality this is only possible if
+// more than a third of the validators are Byzantine.
+type FinalityConflict struct {
+	Time      time.Time       `json:"time"`
+	Ancestor  *types.Header   `json:"ancestor"`  // Last block common to both branches
+	Finalized []*types.Header `json:"finalized"` // Local canonical branch, oldest first
+	Competing []*types.Header `json:"competing"` // Branch attempting to replace it, oldest first
+}
+
+// checkFinality ensures a reorg does not drop any block carrying a valid finality
+// proof. If it would, block import is halted and the conflict recorded for later
+// inspection. The method assumes that the chain mutex is held.
+func (bc *BlockChain) checkFinality(ancestor *types.Block, oldChain, newChain types.Blocks) error {
+	verifier, ok := bc.engine.(consensus.FinalityVerifier)
+	if !ok {
+		return nil
+	}
+	finalized := false
+	for _, block := range oldChain {
+		if verifier.VerifyFinality(bc, block.Header()) == nil {
+			finalized = true
+			break
+		}
+	}
+	if !finalized {
+		return nil
+	}
+	conflict := &FinalityConflict{
+		Time:      time.Now(),
+		Ancestor:  ancestor.Header(),
+		Finalized: make([]*types.Header, 0, len(oldChain)),
+		Competing: make([]*types.Header, 0, len(newChain)),
+	}
+	for i := len(oldChain) - 1; i >= 0; i-- {
+		conflict.Finalized = append(conflict.Finalized, oldChain[i].Header())
+	}
+	for i := len(newChain) - 1; i >= 0; i-- {
+		conflict.Competing = append(conflict.Competing, newChain[i].Header())
+	}
+	bc.conflict = conflict
+	atomic.StoreInt32(&bc.halted, 1)
+	finalityViolationMeter.Mark(1)
+
+	log.Error("Finalized block reorg attempted, halting block import", "number", ancestor.Number(), "hash", ancestor.Hash(),
+		"finalized", oldChain[0].Hash(), "competing", newChain[0].Hash())
+
+	// Deliver the alert outside of the chain lock, subscribers may call back in
+	go bc.finalityFeed.Send(FinalityViolationEvent{Conflict: conflict})
+	return ErrFinalityViolation
+}
+
+// Rewind rewinds the chain to the given head like SetHead, but refuses to drop
+// blocks carrying a valid finality proof unless unsafe is set, as that reverts
+// transactions the whole network considers final. Meant for disaster recovery.
+func (bc *BlockChain) Rewind(head uint64, unsafe bool) error {
+	if current := bc.CurrentHeader().Number.Uint64(); head >= current {
+		return fmt.Errorf("rewind target %d not below current head %d", head, current)
+	}
+	if verifier, ok := bc.engine.(consensus.FinalityVerifier); ok && !unsafe {
+		if next := bc.GetHeaderByNumber(head + 1); next != nil && verifier.VerifyFinality(bc, next) == nil {
+			return ErrFinalizedRewind
+		}
+	}
+	log.Warn("Rewinding chain on request", "target", head, "unsafe", unsafe)
+	return bc.SetHead(head)
+}
+
+// FinalityConflict returns the conflicting branches which caused block import to
+// be halted, or nil if no finality violation has been detected.
+func (bc *BlockChain) FinalityConflict() *FinalityConflict {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+
+	return bc.conflict
+}
+
+// SubscribeFinalityViolationEvent registers a subscription of FinalityViolationEvent.
+func (bc *BlockChain) SubscribeFinalityViolationEvent(ch chan<- FinalityViolationEvent) event.Subscription {
+	return bc.scope.Track(bc.finalityFeed.Subscribe(ch))
+}
@@ -0,0 +1,138 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// finalEngine is a consensus engine considering every block final.
+type finalEngine struct {
+	consensus.Engine
+}
+
+func (finalEngine) VerifyFinality(chain consensus.ChainReader, header *types.Header) error {
+	return nil
+}
+
+// Tests that reorganising away a finalized block halts block import and records
+// the conflicting branches.
+func TestFinalityViolation(t *testing.T) {
+	engine := finalEngine{ethash.NewFaker()}
+
+	db, blockchain, err := newCanonical(engine, 0, true)
+	if err != nil {
+		t.Fatalf("failed to create pristine chain: %v", err)
+	}
+	defer blockchain.Stop()
+
+	violations := make(chan FinalityViolationEvent, 1)
+	sub := blockchain.SubscribeFinalityViolationEvent(violations)
+	defer sub.Unsubscribe()
+
+	// Import a canonical chain, then a longer competing one
+	genesis := blockchain.CurrentBlock()
+	finalized := makeBlockChain(genesis, 3, engine, db, 10)
+	competing := makeBlockChain(genesis, 5, engine, db, 11)
+
+	if _, err := blockchain.InsertChain(finalized); err != nil {
+		t.Fatalf("failed to insert canonical chain: %v", err)
+	}
+	if _, err := blockchain.InsertChain(competing); err != ErrFinalityViolation {
+		t.Fatalf("error mismatch: have %v, want %v", err, ErrFinalityViolation)
+	}
+	if head := blockchain.CurrentBlock().Hash(); head != finalized[2].Hash() {
+		t.Errorf("head block mismatch: have %x, want %x", head, finalized[2].Hash())
+	}
+	select {
+	case ev := <-violations:
+		if ev.Conflict != blockchain.FinalityConflict() {
+			t.Errorf("event conflict mismatch")
+		}
+	case <-time.After(time.Second):
+		t.Errorf("no finality violation event")
+	}
+	conflict := blockchain.FinalityConflict()
+	if conflict == nil {
+		t.Fatalf("no finality conflict recorded")
+	}
+	if conflict.Ancestor.Hash() != genesis.Hash() {
+		t.Errorf("ancestor mismatch: have %x, want %x", conflict.Ancestor.Hash(), genesis.Hash())
+	}
+	if len(conflict.Finalized) != 3 || conflict.Finalized[0].Hash() != finalized[0].Hash() {
+		t.Errorf("finalized branch mismatch: %v", conflict.Finalized)
+	}
+	if len(conflict.Competing) == 0 || conflict.Competing[0].Hash() != competing[0].Hash() {
+		t.Errorf("competing branch mismatch: %v", conflict.Competing)
+	}
+	// Ensure block import is halted, even for blocks extending the canonical chain
+	if _, err := blockchain.InsertChain(makeBlockChain(finalized[2], 1, engine, db, 10)); err != ErrFinalityViolation {
+		t.Fatalf("error mismatch: have %v, want %v", err, ErrFinalityViolation)
+	}
+}
+
+// rewindingEngine is a consensus engine considering every block final, recording
+// the heads it's rewound to.
+type rewindingEngine struct {
+	finalEngine
+	heads []uint64
+}
+
+func (e *rewindingEngine) Rewind(chain consensus.ChainReader, head *types.Header) error {
+	e.heads = append(e.heads, head.Number.Uint64())
+	return nil
+}
+
+// Tests that rewinding away finalized blocks must be explicitly allowed, and that
+// the consensus engine is rewound along with the chain.
+func TestRewindFinalized(t *testing.T) {
+	engine := &rewindingEngine{finalEngine: finalEngine{ethash.NewFaker()}}
+
+	db, blockchain, err := newCanonical(engine, 0, true)
+	if err != nil {
+		t.Fatalf("failed to create pristine chain: %v", err)
+	}
+	defer blockchain.Stop()
+
+	blocks := makeBlockChain(blockchain.CurrentBlock(), 5, engine, db, 10)
+	if _, err := blockchain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	if err := blockchain.Rewind(2, false); err != ErrFinalizedRewind {
+		t.Fatalf("safe rewind error mismatch: have %v, want %v", err, ErrFinalizedRewind)
+	}
+	if head := blockchain.CurrentBlock().NumberU64(); head != 5 {
+		t.Fatalf("head rewound by refused rewind: have %d, want %d", head, 5)
+	}
+	if err := blockchain.Rewind(5, true); err == nil {
+		t.Fatalf("rewind to the current head succeeded")
+	}
+	if err := blockchain.Rewind(2, true); err != nil {
+		t.Fatalf("failed to rewind chain: %v", err)
+	}
+	if head := blockchain.CurrentBlock().NumberU64(); head != 2 {
+		t.Errorf("head mismatch: have %d, want %d", head, 2)
+	}
+	if len(engine.heads) != 1 || engine.heads[0] != 2 {
+		t.Errorf("engine rewinds mismatch: have %v, want [2]", engine.heads)
+	}
+}
@@ -0,0 +1,146 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// BadBlockReport is a forensic bundle persisted for every block failing
+// verification or import, to ease the post-mortem analysis of chain splits.
+type BadBlockReport struct {
+	Time      time.Time       `json:"time"`
+	Number    uint64          `json:"number"`
+	Hash      common.Hash     `json:"hash"`
+	Error     string          `json:"error"`
+	Block     hexutil.Bytes   `json:"block"`               // RLP encoded offending block
+	Receipts  types.Receipts  `json:"receipts,omitempty"`  // Receipts produced up to the failure, if any
+	Consensus json.RawMessage `json:"consensus,omitempty"` // Engine specific state (e.g. consensus messages)
+}
+
+// SetBadBlockDir sets the directory to persist forensic bad block reports into.
+// An empty path disables persisting them.
+func (bc *BlockChain) SetBadBlockDir(dir string) {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+
+	bc.badBlockDir = dir
+}
+
+// ReportBadBlock persists a forensic report about a block rejected outside of
+// the chain import (e.g. a consensus proposal failing verification).
+func (bc *BlockChain) ReportBadBlock(block *types.Block, err error) {
+	bc.writeBadBlockReport(block, nil, err)
+}
+
+// writeBadBlockReport assembles the forensic report of a bad block and writes
+// it into the bad block directory, if one is configured.
+func (bc *BlockChain) writeBadBlockReport(block *types.Block, receipts types.Receipts, err error) {
+	bc.mu.RLock()
+	dir := bc.badBlockDir
+	bc.mu.RUnlock()
+
+	if dir == "" {
+		return
+	}
+	report := &BadBlockReport{
+		Time:     time.Now(),
+		Number:   block.NumberU64(),
+		Hash:     block.Hash(),
+		Error:    err.Error(),
+		Receipts: receipts,
+	}
+	blob, encErr := rlp.EncodeToBytes(block)
+	if encErr != nil {
+		log.Warn("Failed to encode bad block", "hash", block.Hash(), "err", encErr)
+	}
+	report.Block = blob
+
+	if reporter, ok := bc.engine.(consensus.ForensicReporter); ok {
+		if dump := reporter.ForensicDump(block.Header()); dump != nil {
+			if report.Consensus, encErr = json.Marshal(dump); encErr != nil {
+				log.Warn("Failed to encode consensus state", "hash", block.Hash(), "err", encErr)
+			}
+		}
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		log.Warn("Failed to create bad block directory", "dir", dir, "err", err)
+		return
+	}
+	out, encErr := json.MarshalIndent(report, "", "  ")
+	if encErr != nil {
+		log.Warn("Failed to encode bad block report", "hash", block.Hash(), "err", encErr)
+		return
+	}
+	path := filepath.Join(dir, fmt.Sprintf("%d-%x.json", report.Number, report.Hash))
+	if err := ioutil.WriteFile(path, out, 0600); err != nil {
+		log.Warn("Failed to write bad block report", "path", path, "err", err)
+		return
+	}
+	log.Info("Wrote bad block report", "number", report.Number, "hash", report.Hash, "path", path)
+}
+
+// BadBlockReports retrieves all the forensic bad block reports persisted so far,
+// ordered from the oldest to the newest.
+func (bc *BlockChain) BadBlockReports() ([]*BadBlockReport, error) {
+	bc.mu.RLock()
+	dir := bc.badBlockDir
+	bc.mu.RUnlock()
+
+	if dir == "" {
+		return nil, nil
+	}
+	files, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var reports []*BadBlockReport
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), ".json") {
+			continue
+		}
+		blob, err := ioutil.ReadFile(filepath.Join(dir, file.Name()))
+		if err != nil {
+			return nil, err
+		}
+		report := new(BadBlockReport)
+		if err := json.Unmarshal(blob, report); err != nil {
+			log.Warn("Skipping corrupt bad block report", "file", file.Name(), "err", err)
+			continue
+		}
+		reports = append(reports, report)
+	}
+	sort.Slice(reports, func(i, j int) bool { return reports[i].Time.Before(reports[j].Time) })
+	return reports, nil
+}
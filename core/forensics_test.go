@@ -0,0 +1,81 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// Tests that blocks failing import are persisted as forensic reports.
+func TestBadBlockReports(t *testing.T) {
+	dir, err := ioutil.TempDir("", "badblocks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, blockchain, err := newCanonical(ethash.NewFaker(), 0, true)
+	if err != nil {
+		t.Fatalf("failed to create pristine chain: %v", err)
+	}
+	defer blockchain.Stop()
+
+	// No reports without a directory, nor before anything went wrong
+	if reports, err := blockchain.BadBlockReports(); err != nil || len(reports) != 0 {
+		t.Fatalf("unexpected reports: %v, %v", reports, err)
+	}
+	blockchain.SetBadBlockDir(dir)
+	if reports, err := blockchain.BadBlockReports(); err != nil || len(reports) != 0 {
+		t.Fatalf("unexpected reports: %v, %v", reports, err)
+	}
+	// Import a chain with a banned block and ensure it's reported
+	blocks := makeBlockChain(blockchain.CurrentBlock(), 3, ethash.NewFaker(), db, 10)
+
+	BadHashes[blocks[2].Hash()] = true
+	defer func() { delete(BadHashes, blocks[2].Hash()) }()
+
+	if _, err := blockchain.InsertChain(blocks); err != ErrBlacklistedHash {
+		t.Fatalf("error mismatch: have %v, want %v", err, ErrBlacklistedHash)
+	}
+	reports, err := blockchain.BadBlockReports()
+	if err != nil {
+		t.Fatalf("failed to retrieve reports: %v", err)
+	}
+	if len(reports) != 1 {
+		t.Fatalf("report count mismatch: have %d, want 1", len(reports))
+	}
+	report := reports[0]
+	if report.Hash != blocks[2].Hash() || report.Number != blocks[2].NumberU64() {
+		t.Errorf("reported block mismatch: have #%d [%x], want #%d [%x]", report.Number, report.Hash, blocks[2].NumberU64(), blocks[2].Hash())
+	}
+	if report.Error != ErrBlacklistedHash.Error() {
+		t.Errorf("reported error mismatch: have %q, want %q", report.Error, ErrBlacklistedHash)
+	}
+	block := new(types.Block)
+	if err := rlp.DecodeBytes(report.Block, block); err != nil {
+		t.Fatalf("failed to decode reported block: %v", err)
+	}
+	if block.Hash() != blocks[2].Hash() {
+		t.Errorf("decoded block mismatch: have %x, want %x", block.Hash(), blocks[2].Hash())
+	}
+}
@@ -0,0 +1,86 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"sync"
+
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+// indexQueueSize is the number of index writes that may be pending before block
+// import blocks waiting for the database to catch up.
+const indexQueueSize = 1024
+
+var indexQueueGauge = metrics.NewRegisteredGauge("chain/index/queue", nil)
+
+// indexWrite is a write of non-critical lookup data, which can be regenerated
+// from the canonical chain if lost, e.g. transaction lookup entries.
+type indexWrite func(db ethdb.Database) error
+
+// indexWriter moves the index writes of imported blocks off the import path,
+// applying them in the background in the order they were queued, so that the
+// writes of consecutive blocks can be coalesced by the database.
+type indexWriter struct {
+	db      ethdb.Database
+	queue   chan indexWrite
+	pending sync.WaitGroup // Writes queued but not yet applied
+	done    chan struct{}  // Closed when the background loop terminated
+}
+
+// newIndexWriter creates an index writer and starts its background loop.
+func newIndexWriter(db ethdb.Database) *indexWriter {
+	w := &indexWriter{
+		db:    db,
+		queue: make(chan indexWrite, indexQueueSize),
+		done:  make(chan struct{}),
+	}
+	go w.loop()
+	return w
+}
+
+// loop applies the queued index writes until the writer is closed.
+func (w *indexWriter) loop() {
+	defer close(w.done)
+
+	for write := range w.queue {
+		if err := write(w.db); err != nil {
+			log.Error("Failed to write chain index", "err", err)
+		}
+		indexQueueGauge.Update(int64(len(w.queue)))
+		w.pending.Done()
+	}
+}
+
+// write queues an index write, blocking if too many writes are pending.
+func (w *indexWriter) write(write indexWrite) {
+	w.pending.Add(1)
+	w.queue <- write
+}
+
+// wait blocks until all the index writes queued so far were applied.
+func (w *indexWriter) wait() {
+	w.pending.Wait()
+}
+
+// close applies the pending index writes and stops the background loop.
+func (w *indexWriter) close() {
+	close(w.queue)
+	<-w.done
+}
@@ -0,0 +1,398 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"math/big"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/consensus/misc"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+var (
+	parallelTxMeter = metrics.NewRegisteredMeter("chain/parallel/txs", nil)
+	serialTxMeter   = metrics.NewRegisteredMeter("chain/parallel/serial", nil)
+)
+
+// ParallelProcessor is a Processor executing the transactions of a block
+// optimistically in parallel.
+//
+// Every transaction is first executed on a private copy of the state the block
+// starts from, recording the accounts it accesses. The results are then applied
+// in block order: a transaction which didn't access any account modified by the
+// transactions before it would have produced the exact same outcome when run in
+// sequence, so its changes are merged as is. The rest are re-executed serially
+// on top of the merged state.
+//
+// Fees credited to the block beneficiary are commutative, so they don't count as
+// an access, unless the transaction otherwise interacts with the beneficiary.
+// Blocks before Byzantium are always processed serially, as their receipts hold
+// the intermediate state roots, and so are blocks being traced.
+//
+// ParallelProcessor implements Processor.
+type ParallelProcessor struct {
+	config  *params.ChainConfig // Chain configuration options
+	bc      *BlockChain         // Canonical block chain
+	engine  consensus.Engine    // Consensus engine used for block rewards
+	serial  *StateProcessor     // Processor to fall back to for unsupported blocks
+	workers int                 // Number of transactions to execute concurrently
+}
+
+// NewParallelProcessor initialises a new ParallelProcessor executing up to the
+// given number of transactions concurrently.
+func NewParallelProcessor(config *params.ChainConfig, bc *BlockChain, engine consensus.Engine, workers int) *ParallelProcessor {
+	return &ParallelProcessor{
+		config:  config,
+		bc:      bc,
+		engine:  engine,
+		serial:  NewStateProcessor(config, bc, engine),
+		workers: workers,
+	}
+}
+
+// speculation is the outcome of executing a transaction on a private copy of
+// the state the block starts from.
+type speculation struct {
+	statedb *state.StateDB
+	access  *accessRecorder
+	receipt *types.Receipt
+	err     error
+}
+
+// Process processes the state changes according to the Ethereum rules, running
+// the independent transactions of the block in parallel. The results are the
+// same as those of StateProcessor.Process.
+func (p *ParallelProcessor) Process(block *types.Block, statedb *state.StateDB, cfg vm.Config) (types.Receipts, []*types.Log, uint64, error) {
+	// Tracers aren't safe for concurrent use, trace blocks serially
+	txs := block.Transactions()
+	if p.workers < 2 || len(txs) < 2 || cfg.Debug || !p.config.IsByzantium(block.Number()) {
+		return p.serial.Process(block, statedb, cfg)
+	}
+	var (
+		receipts types.Receipts
+		usedGas  = new(uint64)
+		header   = block.Header()
+		allLogs  []*types.Log
+		gp       = new(GasPool).AddGas(block.GasLimit())
+	)
+	// Mutate the the block and state according to any hard-fork specs
+	if p.config.DAOForkSupport && p.config.DAOForkBlock != nil && p.config.DAOForkBlock.Cmp(block.Number()) == 0 {
+		misc.ApplyDAOHardFork(statedb)
+	}
+	// Resolve the fee beneficiary once, instead of for every transaction
+	author, _ := p.engine.Author(header)
+
+	specs := p.speculate(block, statedb, author, cfg)
+
+	// Apply the speculative results in order, re-executing the invalidated ones
+	modified := make(map[common.Address]struct{})
+	for i, tx := range txs {
+		spec := specs[i]
+		if spec.err != nil || spec.access.conflicts(modified) {
+			serialTxMeter.Mark(1)
+
+			access := newAccessRecorder(statedb, author)
+			statedb.Prepare(tx.Hash(), block.Hash(), i)
+			receipt, err := applyTransaction(p.config, p.bc, author, gp, access, header, tx, usedGas, cfg)
+			if err != nil {
+				return nil, nil, 0, err
+			}
+			access.modified(modified)
+			receipts = append(receipts, receipt)
+			allLogs = append(allLogs, receipt.Logs...)
+			continue
+		}
+		parallelTxMeter.Mark(1)
+
+		// Speculation still valid, charge the block gas pool as if executed here
+		if err := gp.SubGas(tx.Gas()); err != nil {
+			return nil, nil, 0, err
+		}
+		gp.AddGas(tx.Gas() - spec.receipt.GasUsed)
+		*usedGas += spec.receipt.GasUsed
+		spec.receipt.CumulativeGasUsed = *usedGas
+
+		// Import the modified accounts and credit the fee separately, unless the
+		// transaction touched the beneficiary itself
+		statedb.Merge(spec.statedb, func(addr common.Address) bool {
+			return addr != author || spec.access.coinbaseUsed()
+		})
+		if !spec.access.coinbaseUsed() {
+			statedb.AddBalance(author, new(big.Int).Mul(new(big.Int).SetUint64(spec.receipt.GasUsed), tx.GasPrice()))
+		}
+		statedb.Prepare(tx.Hash(), block.Hash(), i)
+		for _, l := range spec.receipt.Logs {
+			statedb.AddLog(l)
+		}
+		for hash, preimage := range spec.statedb.Preimages() {
+			statedb.AddPreimage(hash, preimage)
+		}
+		statedb.Finalise(true)
+
+		spec.access.modified(modified)
+		receipts = append(receipts, spec.receipt)
+		allLogs = append(allLogs, spec.receipt.Logs...)
+	}
+	// Finalize the block, applying any consensus engine specific extras (e.g. block rewards)
+	_, receipts, err := consensus.Finalize(p.engine, p.bc, header, statedb, txs, block.Uncles(), receipts)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	for _, receipt := range receipts[len(txs):] {
+		allLogs = append(allLogs, receipt.Logs...)
+	}
+
+	return receipts, allLogs, *usedGas, nil
+}
+
+// speculate executes all the transactions of a block concurrently, each on its
+// own copy of the state the block starts from.
+func (p *ParallelProcessor) speculate(block *types.Block, statedb *state.StateDB, author common.Address, cfg vm.Config) []*speculation {
+	var (
+		txs    = block.Transactions()
+		header = block.Header()
+		specs  = make([]*speculation, len(txs))
+		tasks  = make(chan int, len(txs))
+		wg     sync.WaitGroup
+	)
+	for i := range txs {
+		tasks <- i
+	}
+	close(tasks)
+
+	workers := p.workers
+	if workers > len(txs) {
+		workers = len(txs)
+	}
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for i := range tasks {
+				spec := &speculation{statedb: statedb.Copy()}
+				spec.access = newAccessRecorder(spec.statedb, author)
+				spec.statedb.Prepare(txs[i].Hash(), block.Hash(), i)
+
+				gp := new(GasPool).AddGas(block.GasLimit())
+				spec.receipt, spec.err = applyTransaction(p.config, p.bc, author, gp, spec.access, header, txs[i], new(uint64), cfg)
+				if spec.err == nil {
+					spec.err = spec.statedb.Error()
+				}
+				if spec.err != nil {
+					log.Trace("Speculative transaction execution failed", "hash", txs[i].Hash(), "err", spec.err)
+				}
+				specs[i] = spec
+			}
+		}()
+	}
+	wg.Wait()
+
+	return specs
+}
+
+// applyTransaction is ApplyTransaction for post-Byzantium blocks, running the
+// transaction against a recording state.
+func applyTransaction(config *params.ChainConfig, bc *BlockChain, author common.Address, gp *GasPool, statedb *accessRecorder, header *types.Header, tx *types.Transaction, usedGas *uint64, cfg vm.Config) (*types.Receipt, error) {
+	msg, err := tx.AsMessage(types.MakeSigner(config, header.Number))
+	if err != nil {
+		return nil, err
+	}
+	vmenv := vm.NewEVM(NewEVMContext(msg, header, bc, &author), statedb, config, cfg)
+
+	_, gas, failed, err := ApplyMessage(vmenv, msg, gp)
+	if err != nil {
+		return nil, err
+	}
+	statedb.Finalise(true)
+	*usedGas += gas
+
+	receipt := types.NewReceipt(nil, failed, *usedGas)
+	receipt.TxHash = tx.Hash()
+	receipt.GasUsed = gas
+	if msg.To() == nil {
+		receipt.ContractAddress = crypto.CreateAddress(vmenv.Context.Origin, tx.Nonce())
+	}
+	receipt.Logs = statedb.GetLogs(tx.Hash())
+	receipt.Bloom = types.CreateBloom(types.Receipts{receipt})
+
+	return receipt, nil
+}
+
+// accessRecorder is a vm.StateDB recording the accounts read and modified by a
+// transaction. Accesses of the block beneficiary are only counted, as every
+// transaction ends with crediting the fee to it.
+type accessRecorder struct {
+	*state.StateDB
+
+	coinbase    common.Address
+	coinbaseOps int // Number of operations on the beneficiary
+	reads       map[common.Address]struct{}
+	writes      map[common.Address]struct{}
+}
+
+// newAccessRecorder wraps a state to record the accounts accessed in it.
+func newAccessRecorder(statedb *state.StateDB, coinbase common.Address) *accessRecorder {
+	return &accessRecorder{
+		StateDB:  statedb,
+		coinbase: coinbase,
+		reads:    make(map[common.Address]struct{}),
+		writes:   make(map[common.Address]struct{}),
+	}
+}
+
+// coinbaseUsed returns whether the transaction accessed the beneficiary besides
+// crediting the fee to it.
+func (r *accessRecorder) coinbaseUsed() bool {
+	return r.coinbaseOps > 1
+}
+
+// conflicts checks whether the transaction accessed any of the given accounts.
+func (r *accessRecorder) conflicts(accounts map[common.Address]struct{}) bool {
+	if r.coinbaseUsed() {
+		if _, ok := accounts[r.coinbase]; ok {
+			return true
+		}
+	}
+	for _, set := range []map[common.Address]struct{}{r.reads, r.writes} {
+		for addr := range set {
+			if _, ok := accounts[addr]; ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// modified adds the accounts modified by the transaction to the given set. The
+// beneficiary is always included, as at least its balance changes.
+func (r *accessRecorder) modified(accounts map[common.Address]struct{}) {
+	for addr := range r.writes {
+		accounts[addr] = struct{}{}
+	}
+	accounts[r.coinbase] = struct{}{}
+}
+
+func (r *accessRecorder) read(addr common.Address) {
+	if addr == r.coinbase {
+		r.coinbaseOps++
+		return
+	}
+	r.reads[addr] = struct{}{}
+}
+
+func (r *accessRecorder) write(addr common.Address) {
+	if addr == r.coinbase {
+		r.coinbaseOps++
+		return
+	}
+	r.writes[addr] = struct{}{}
+}
+
+func (r *accessRecorder) CreateAccount(addr common.Address) {
+	r.write(addr)
+	r.StateDB.CreateAccount(addr)
+}
+
+func (r *accessRecorder) SubBalance(addr common.Address, amount *big.Int) {
+	r.write(addr)
+	r.StateDB.SubBalance(addr, amount)
+}
+
+func (r *accessRecorder) AddBalance(addr common.Address, amount *big.Int) {
+	r.write(addr)
+	r.StateDB.AddBalance(addr, amount)
+}
+
+func (r *accessRecorder) GetBalance(addr common.Address) *big.Int {
+	r.read(addr)
+	return r.StateDB.GetBalance(addr)
+}
+
+func (r *accessRecorder) GetNonce(addr common.Address) uint64 {
+	r.read(addr)
+	return r.StateDB.GetNonce(addr)
+}
+
+func (r *accessRecorder) SetNonce(addr common.Address, nonce uint64) {
+	r.write(addr)
+	r.StateDB.SetNonce(addr, nonce)
+}
+
+func (r *accessRecorder) GetCodeHash(addr common.Address) common.Hash {
+	r.read(addr)
+	return r.StateDB.GetCodeHash(addr)
+}
+
+func (r *accessRecorder) GetCode(addr common.Address) []byte {
+	r.read(addr)
+	return r.StateDB.GetCode(addr)
+}
+
+func (r *accessRecorder) SetCode(addr common.Address, code []byte) {
+	r.write(addr)
+	r.StateDB.SetCode(addr, code)
+}
+
+func (r *accessRecorder) GetCodeSize(addr common.Address) int {
+	r.read(addr)
+	return r.StateDB.GetCodeSize(addr)
+}
+
+func (r *accessRecorder) GetState(addr common.Address, key common.Hash) common.Hash {
+	r.read(addr)
+	return r.StateDB.GetState(addr, key)
+}
+
+func (r *accessRecorder) SetState(addr common.Address, key common.Hash, value common.Hash) {
+	r.write(addr)
+	r.StateDB.SetState(addr, key, value)
+}
+
+func (r *accessRecorder) Suicide(addr common.Address) bool {
+	r.write(addr)
+	return r.StateDB.Suicide(addr)
+}
+
+func (r *accessRecorder) HasSuicided(addr common.Address) bool {
+	r.read(addr)
+	return r.StateDB.HasSuicided(addr)
+}
+
+func (r *accessRecorder) Exist(addr common.Address) bool {
+	r.read(addr)
+	return r.StateDB.Exist(addr)
+}
+
+func (r *accessRecorder) Empty(addr common.Address) bool {
+	r.read(addr)
+	return r.StateDB.Empty(addr)
+}
+
+func (r *accessRecorder) ForEachStorage(addr common.Address, cb func(key, value common.Hash) bool) {
+	r.read(addr)
+	r.StateDB.ForEachStorage(addr, cb)
+}
@@ -0,0 +1,121 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"crypto/ecdsa"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// Tests that processing blocks with both independent and conflicting transactions
+// in parallel yields the same state and receipts as processing them serially.
+func TestParallelProcessor(t *testing.T) {
+	var (
+		keys     = make([]*ecdsa.PrivateKey, 8)
+		addrs    = make([]common.Address, len(keys))
+		alloc    = make(GenesisAlloc)
+		coinbase = common.Address{0xc0}
+		counter  = common.Address{0xcc}
+		signer   = types.HomesteadSigner{}
+	)
+	for i := range keys {
+		keys[i], _ = crypto.GenerateKey()
+		addrs[i] = crypto.PubkeyToAddress(keys[i].PublicKey)
+		alloc[addrs[i]] = GenesisAccount{Balance: big.NewInt(params.Ether)}
+	}
+	// Counter incrementing slot 0 and emitting a log on every call
+	alloc[counter] = GenesisAccount{Balance: new(big.Int), Code: common.FromHex("0x600054600101600055600060006000a000")}
+
+	gspec := &Genesis{Config: params.TestChainConfig, Alloc: alloc}
+	db, _ := ethdb.NewMemDatabase()
+	genesis := gspec.MustCommit(db)
+
+	sign := func(gen *BlockGen, key int, to *common.Address, value int64, gas uint64, data []byte) {
+		nonce := gen.TxNonce(addrs[key])
+		var tx *types.Transaction
+		if to == nil {
+			tx = types.NewContractCreation(nonce, big.NewInt(value), gas, big.NewInt(1), data)
+		} else {
+			tx = types.NewTransaction(nonce, *to, big.NewInt(value), gas, big.NewInt(1), data)
+		}
+		tx, _ = types.SignTx(tx, signer, keys[key])
+		gen.AddTx(tx)
+	}
+	blocks, receipts := GenerateChain(gspec.Config, genesis, ethash.NewFaker(), db, 4, func(i int, gen *BlockGen) {
+		gen.SetCoinbase(coinbase)
+
+		// Independent transfers to fresh accounts
+		for j := 0; j < 4; j++ {
+			to := common.Address{byte(i), byte(j)}
+			sign(gen, j, &to, 1000, params.TxGas, nil)
+		}
+		// Transactions depending on each other through the sender and the counter
+		sign(gen, 4, &addrs[5], 1000, params.TxGas, nil)
+		sign(gen, 4, &counter, 0, 100000, nil)
+		sign(gen, 5, &counter, 0, 100000, nil)
+
+		// Contract creation emitting a log from its constructor
+		sign(gen, 6, nil, 0, 100000, common.FromHex("0x600060006000a000"))
+
+		// Transfer interacting with the beneficiary beyond paying the fee
+		if i%2 == 1 {
+			sign(gen, 7, &coinbase, 1000, params.TxGas, nil)
+		}
+	})
+	// Import the chain with parallel execution, ensuring the same state and receipts
+	db, _ = ethdb.NewMemDatabase()
+	gspec.MustCommit(db)
+
+	chain, _ := NewBlockChain(db, nil, gspec.Config, ethash.NewFaker(), vm.Config{})
+	defer chain.Stop()
+	chain.SetProcessor(NewParallelProcessor(gspec.Config, chain, chain.Engine(), 4))
+
+	if n, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("block %d: failed to insert: %v", n, err)
+	}
+	for i, block := range blocks {
+		have := GetBlockReceipts(db, block.Hash(), block.NumberU64())
+		if len(have) != len(receipts[i]) {
+			t.Fatalf("block %d: receipt count mismatch: have %d, want %d", i, len(have), len(receipts[i]))
+		}
+		for j, want := range receipts[i] {
+			if have[j].CumulativeGasUsed != want.CumulativeGasUsed || have[j].GasUsed != want.GasUsed || have[j].Status != want.Status {
+				t.Errorf("block %d, tx %d: receipt mismatch: have %+v, want %+v", i, j, have[j], want)
+			}
+			if have[j].ContractAddress != want.ContractAddress {
+				t.Errorf("block %d, tx %d: contract address mismatch: have %x, want %x", i, j, have[j].ContractAddress, want.ContractAddress)
+			}
+			if len(have[j].Logs) != len(want.Logs) {
+				t.Fatalf("block %d, tx %d: log count mismatch: have %d, want %d", i, j, len(have[j].Logs), len(want.Logs))
+			}
+			for k, log := range have[j].Logs {
+				if log.Index != want.Logs[k].Index || log.TxIndex != want.Logs[k].TxIndex || log.TxHash != want.Logs[k].TxHash {
+					t.Errorf("block %d, tx %d, log %d: log mismatch: have %+v, want %+v", i, j, k, log, want.Logs[k])
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,212 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package state
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+// DiffAccount is the state of an account on one side of a diff.
+type DiffAccount struct {
+	Balance  string      `json:"balance"`
+	Nonce    uint64      `json:"nonce"`
+	Root     common.Hash `json:"root"`
+	CodeHash common.Hash `json:"codeHash"`
+}
+
+// StorageDiff is a changed storage slot of an account.
+type StorageDiff struct {
+	Key    *common.Hash `json:"key"`    // Preimage of the slot hash, nil if unknown
+	Before common.Hash  `json:"before"` // Zero if the slot was unset
+	After  common.Hash  `json:"after"`  // Zero if the slot was cleared
+}
+
+// AccountDiff is the change of an account between two states.
+type AccountDiff struct {
+	Address common.Address              `json:"address"`
+	Before  *DiffAccount                `json:"before"`            // Nil if the account was created
+	After   *DiffAccount                `json:"after"`             // Nil if the account was deleted
+	Storage map[common.Hash]StorageDiff `json:"storage,omitempty"` // Changed slots keyed by slot hash
+}
+
+// StateDiff is the set of changes between two states.
+type StateDiff struct {
+	From     common.Hash   `json:"from"`
+	To       common.Hash   `json:"to"`
+	Accounts []AccountDiff `json:"accounts"` // Changed accounts, ordered by address hash
+}
+
+// leafChange is the value of a trie leaf before and after a change, nil if the
+// leaf is absent on that side.
+type leafChange struct {
+	before, after []byte
+}
+
+// Diff computes the accounts and storage slots which differ between the states
+// with the given roots. Only the changed parts of the tries are walked, and the
+// result is ordered canonically, so that diffs of the same states compare equal
+// across nodes. Accounts are identified by the hash preimages recorded in the
+// database, and an error is returned if one is missing.
+func Diff(db Database, from, to common.Hash) (*StateDiff, error) {
+	fromTrie, err := db.OpenTrie(from)
+	if err != nil {
+		return nil, err
+	}
+	toTrie, err := db.OpenTrie(to)
+	if err != nil {
+		return nil, err
+	}
+	keys, changes, err := diffLeaves(fromTrie, toTrie)
+	if err != nil {
+		return nil, err
+	}
+	diff := &StateDiff{From: from, To: to, Accounts: make([]AccountDiff, 0, len(keys))}
+	for _, key := range keys {
+		preimage := toTrie.GetKey(key)
+		if preimage == nil {
+			return nil, fmt.Errorf("no preimage found for hash %x", key)
+		}
+		account := AccountDiff{Address: common.BytesToAddress(preimage)}
+
+		change := changes[string(key)]
+		var fromRoot, toRoot common.Hash
+		if change.before != nil {
+			if account.Before, err = decodeDiffAccount(change.before); err != nil {
+				return nil, err
+			}
+			fromRoot = account.Before.Root
+		}
+		if change.after != nil {
+			if account.After, err = decodeDiffAccount(change.after); err != nil {
+				return nil, err
+			}
+			toRoot = account.After.Root
+		}
+		if fromRoot != toRoot {
+			if account.Storage, err = diffStorage(db, common.BytesToHash(key), fromRoot, toRoot); err != nil {
+				return nil, err
+			}
+			if len(account.Storage) == 0 {
+				account.Storage = nil // Empty storage of a created or deleted account
+			}
+		}
+		diff.Accounts = append(diff.Accounts, account)
+	}
+	return diff, nil
+}
+
+// diffStorage computes the slots which differ between two storage tries of an
+// account.
+func diffStorage(db Database, addrHash, from, to common.Hash) (map[common.Hash]StorageDiff, error) {
+	fromTrie, err := db.OpenStorageTrie(addrHash, from)
+	if err != nil {
+		return nil, err
+	}
+	toTrie, err := db.OpenStorageTrie(addrHash, to)
+	if err != nil {
+		return nil, err
+	}
+	keys, changes, err := diffLeaves(fromTrie, toTrie)
+	if err != nil {
+		return nil, err
+	}
+	slots := make(map[common.Hash]StorageDiff, len(keys))
+	for _, key := range keys {
+		var slot StorageDiff
+		if preimage := toTrie.GetKey(key); preimage != nil {
+			preimage := common.BytesToHash(preimage)
+			slot.Key = &preimage
+		}
+		change := changes[string(key)]
+		if slot.Before, err = decodeSlot(change.before); err != nil {
+			return nil, err
+		}
+		if slot.After, err = decodeSlot(change.after); err != nil {
+			return nil, err
+		}
+		slots[common.BytesToHash(key)] = slot
+	}
+	return slots, nil
+}
+
+// diffLeaves collects the leaves differing between two tries, returning their
+// keys in trie order along with their values on both sides.
+func diffLeaves(a, b Trie) ([][]byte, map[string]*leafChange, error) {
+	changes := make(map[string]*leafChange)
+
+	// Leaves changed or added in b
+	added, _ := trie.NewDifferenceIterator(a.NodeIterator(nil), b.NodeIterator(nil))
+	it := trie.NewIterator(added)
+	for it.Next() {
+		changes[string(it.Key)] = &leafChange{after: it.Value}
+	}
+	if it.Err != nil {
+		return nil, nil, it.Err
+	}
+	// Leaves changed or removed in b
+	removed, _ := trie.NewDifferenceIterator(b.NodeIterator(nil), a.NodeIterator(nil))
+	it = trie.NewIterator(removed)
+	for it.Next() {
+		if change, ok := changes[string(it.Key)]; ok {
+			change.before = it.Value
+		} else {
+			changes[string(it.Key)] = &leafChange{before: it.Value}
+		}
+	}
+	if it.Err != nil {
+		return nil, nil, it.Err
+	}
+	keys := make([][]byte, 0, len(changes))
+	for key := range changes {
+		keys = append(keys, []byte(key))
+	}
+	sort.Slice(keys, func(i, j int) bool { return bytes.Compare(keys[i], keys[j]) < 0 })
+	return keys, changes, nil
+}
+
+// decodeDiffAccount decodes an account leaf of the state trie.
+func decodeDiffAccount(blob []byte) (*DiffAccount, error) {
+	var data Account
+	if err := rlp.DecodeBytes(blob, &data); err != nil {
+		return nil, err
+	}
+	return &DiffAccount{
+		Balance:  data.Balance.String(),
+		Nonce:    data.Nonce,
+		Root:     data.Root,
+		CodeHash: common.BytesToHash(data.CodeHash),
+	}, nil
+}
+
+// decodeSlot decodes a storage trie leaf, the zero hash standing for an absent
+// leaf.
+func decodeSlot(blob []byte) (common.Hash, error) {
+	if blob == nil {
+		return common.Hash{}, nil
+	}
+	_, content, _, err := rlp.Split(blob)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return common.BytesToHash(content), nil
+}
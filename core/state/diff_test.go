@@ -0,0 +1,168 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package state
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+)
+
+// Tests that diffs report changed, created and deleted accounts along with
+// their changed storage slots, in address hash order.
+func TestDiff(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+	sdb := NewDatabase(db)
+	state, _ := New(common.Hash{}, sdb)
+
+	var (
+		changed   = common.HexToAddress("0x01")
+		created   = common.HexToAddress("0x02")
+		deleted   = common.HexToAddress("0x03")
+		unchanged = common.HexToAddress("0x04")
+		slotA     = common.HexToHash("0x0a")
+		slotB     = common.HexToHash("0x0b")
+	)
+	state.SetBalance(changed, big.NewInt(1))
+	state.SetState(changed, slotA, common.HexToHash("0x01"))
+	state.SetBalance(deleted, big.NewInt(3))
+	state.SetState(deleted, slotA, common.HexToHash("0x03"))
+	state.SetBalance(unchanged, big.NewInt(4))
+	from, _ := state.Commit(false)
+
+	state, _ = New(from, sdb)
+	state.AddBalance(changed, big.NewInt(1))
+	state.SetState(changed, slotA, common.Hash{})
+	state.SetState(changed, slotB, common.HexToHash("0x02"))
+	state.SetNonce(created, 1)
+	state.Suicide(deleted)
+	to, _ := state.Commit(true)
+
+	diff, err := Diff(sdb, from, to)
+	if err != nil {
+		t.Fatalf("failed to diff states: %v", err)
+	}
+	if diff.From != from || diff.To != to || len(diff.Accounts) != 3 {
+		t.Fatalf("diff mismatch: %+v", diff)
+	}
+	for i := 1; i < len(diff.Accounts); i++ {
+		prev, next := crypto.Keccak256(diff.Accounts[i-1].Address[:]), crypto.Keccak256(diff.Accounts[i].Address[:])
+		if bytes.Compare(prev, next) >= 0 {
+			t.Errorf("accounts out of order: %x before %x", diff.Accounts[i-1].Address, diff.Accounts[i].Address)
+		}
+	}
+	accounts := make(map[common.Address]AccountDiff)
+	for _, account := range diff.Accounts {
+		accounts[account.Address] = account
+	}
+	// Check the account with changed balance and storage
+	account, ok := accounts[changed]
+	if !ok || account.Before == nil || account.After == nil || account.Before.Balance != "1" || account.After.Balance != "2" {
+		t.Fatalf("changed account mismatch: %+v", account)
+	}
+	if len(account.Storage) != 2 {
+		t.Fatalf("changed storage mismatch: %+v", account.Storage)
+	}
+	for _, want := range []struct {
+		key           common.Hash
+		before, after common.Hash
+	}{
+		{slotA, common.HexToHash("0x01"), common.Hash{}},
+		{slotB, common.Hash{}, common.HexToHash("0x02")},
+	} {
+		slot := account.Storage[crypto.Keccak256Hash(want.key[:])]
+		if slot.Key == nil || *slot.Key != want.key || slot.Before != want.before || slot.After != want.after {
+			t.Errorf("slot %x mismatch: %+v", want.key, slot)
+		}
+	}
+	// Check the created and the deleted accounts
+	if account, ok := accounts[created]; !ok || account.Before != nil || account.After == nil || account.After.Nonce != 1 {
+		t.Errorf("created account mismatch: %+v", account)
+	}
+	account, ok = accounts[deleted]
+	if !ok || account.Before == nil || account.After != nil || account.Before.Balance != "3" {
+		t.Fatalf("deleted account mismatch: %+v", account)
+	}
+	if slot := account.Storage[crypto.Keccak256Hash(slotA[:])]; len(account.Storage) != 1 || slot.Before != common.HexToHash("0x03") || slot.After != (common.Hash{}) {
+		t.Errorf("deleted storage mismatch: %+v", account.Storage)
+	}
+	// Diffing a state with itself must yield no changes
+	if diff, err := Diff(sdb, to, to); err != nil || len(diff.Accounts) != 0 {
+		t.Errorf("self diff mismatch: %+v, %v", diff, err)
+	}
+}
+
+// Tests that ordered dumps list the accounts in address hash order and can be
+// continued from where a limited dump stopped.
+func TestOrderedDump(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+	state, _ := New(common.Hash{}, NewDatabase(db))
+	for i := byte(1); i <= 5; i++ {
+		addr := common.BytesToAddress([]byte{i})
+		state.SetBalance(addr, big.NewInt(int64(i)))
+		state.SetCode(addr, []byte{i})
+		state.SetState(addr, common.Hash{i}, common.Hash{i})
+	}
+	state.Commit(false)
+
+	full, err := state.OrderedDump(DumpOptions{})
+	if err != nil {
+		t.Fatalf("failed to dump state: %v", err)
+	}
+	if len(full.Accounts) != 5 || full.Next != nil {
+		t.Fatalf("full dump mismatch: %d accounts, next %v", len(full.Accounts), full.Next)
+	}
+	for i, account := range full.Accounts {
+		if account.Key != crypto.Keccak256Hash(account.Address[:]) || account.Code == "" || len(account.Storage) != 1 {
+			t.Errorf("account %d mismatch: %+v", i, account)
+		}
+		if i > 0 && bytes.Compare(full.Accounts[i-1].Key[:], account.Key[:]) >= 0 {
+			t.Errorf("account %d out of order", i)
+		}
+	}
+	// Dump in pages of two, leaving out code and storage
+	var (
+		paged []OrderedDumpAccount
+		opts  = DumpOptions{SkipCode: true, SkipStorage: true, Max: 2}
+	)
+	for {
+		page, err := state.OrderedDump(opts)
+		if err != nil {
+			t.Fatalf("failed to dump page: %v", err)
+		}
+		paged = append(paged, page.Accounts...)
+		if page.Next == nil {
+			break
+		}
+		opts.Start = *page.Next
+	}
+	if len(paged) != len(full.Accounts) {
+		t.Fatalf("paged dump length mismatch: have %d, want %d", len(paged), len(full.Accounts))
+	}
+	for i, account := range paged {
+		if account.Address != full.Accounts[i].Address || account.Balance != full.Accounts[i].Balance {
+			t.Errorf("page account %d mismatch: have %+v, want %+v", i, account, full.Accounts[i])
+		}
+		if account.Code != "" || account.Storage != nil {
+			t.Errorf("page account %d has code or storage", i)
+		}
+	}
+}
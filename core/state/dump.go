@@ -71,6 +71,85 @@ func (self *StateDB) RawDump() Dump {
 	return dump
 }
 
+// DumpOptions configures an ordered state dump.
+type DumpOptions struct {
+	SkipCode    bool        `json:"skipCode"`    // Leave out contract code
+	SkipStorage bool        `json:"skipStorage"` // Leave out storage slots
+	Start       common.Hash `json:"start"`       // Address hash to start the dump at
+	Max         int         `json:"max"`         // Maximum number of accounts, 0 for all
+}
+
+// OrderedDumpAccount is an account of an ordered state dump.
+type OrderedDumpAccount struct {
+	Address common.Address `json:"address"`
+	Key     common.Hash    `json:"key"` // Hash of the address, the dump is ordered by
+	DumpAccount
+}
+
+// OrderedDump is a dump of the state with accounts in trie order, which makes
+// dumps of the same state byte-for-byte identical across nodes.
+type OrderedDump struct {
+	Root     string               `json:"root"`
+	Accounts []OrderedDumpAccount `json:"accounts"`
+	Next     *common.Hash         `json:"next"` // Key to continue the dump at, nil if complete
+}
+
+// OrderedDump dumps the accounts of the state in trie order, starting at the
+// given address hash and stopping after the maximum number of accounts.
+func (self *StateDB) OrderedDump(opts DumpOptions) (OrderedDump, error) {
+	dump := OrderedDump{
+		Root:     fmt.Sprintf("%x", self.trie.Hash()),
+		Accounts: []OrderedDumpAccount{},
+	}
+	it := trie.NewIterator(self.trie.NodeIterator(opts.Start[:]))
+	for it.Next() {
+		if opts.Max > 0 && len(dump.Accounts) == opts.Max {
+			next := common.BytesToHash(it.Key)
+			dump.Next = &next
+			break
+		}
+		var data Account
+		if err := rlp.DecodeBytes(it.Value, &data); err != nil {
+			return OrderedDump{}, err
+		}
+		addr := common.BytesToAddress(self.trie.GetKey(it.Key))
+		obj := newObject(nil, addr, data, nil)
+		account := OrderedDumpAccount{
+			Address: addr,
+			Key:     common.BytesToHash(it.Key),
+			DumpAccount: DumpAccount{
+				Balance:  data.Balance.String(),
+				Nonce:    data.Nonce,
+				Root:     common.Bytes2Hex(data.Root[:]),
+				CodeHash: common.Bytes2Hex(data.CodeHash),
+			},
+		}
+		if !opts.SkipCode {
+			account.Code = common.Bytes2Hex(obj.Code(self.db))
+		}
+		if !opts.SkipStorage {
+			account.Storage = make(map[string]string)
+			storageIt := trie.NewIterator(obj.getTrie(self.db).NodeIterator(nil))
+			for storageIt.Next() {
+				// Slots without known preimage are keyed by their hash
+				key := self.trie.GetKey(storageIt.Key)
+				if key == nil {
+					key = storageIt.Key
+				}
+				account.Storage[common.Bytes2Hex(key)] = common.Bytes2Hex(storageIt.Value)
+			}
+			if storageIt.Err != nil {
+				return OrderedDump{}, storageIt.Err
+			}
+		}
+		dump.Accounts = append(dump.Accounts, account)
+	}
+	if it.Err != nil {
+		return OrderedDump{}, it.Err
+	}
+	return dump, nil
+}
+
 func (self *StateDB) Dump() []byte {
 	json, err := json.MarshalIndent(self.RawDump(), "", "    ")
 	if err != nil {
@@ -31,6 +31,14 @@ import (
 	"github.com/ethereum/go-ethereum/trie"
 )
 
+// proofList collects the trie nodes of a Merkle proof, in root to leaf order.
+type proofList [][]byte
+
+func (n *proofList) Put(key []byte, value []byte) error {
+	*n = append(*n, value)
+	return nil
+}
+
 type revision struct {
 	id           int
 	journalIndex int
@@ -259,6 +267,26 @@ func (self *StateDB) StorageTrie(a common.Address) Trie {
 	return cpy.updateTrie(self.db)
 }
 
+// GetProof returns the Merkle proof of an account in the state trie, proving its
+// absence if the account doesn't exist.
+func (self *StateDB) GetProof(a common.Address) ([][]byte, error) {
+	var proof proofList
+	err := self.trie.Prove(crypto.Keccak256(a.Bytes()), 0, &proof)
+	return proof, err
+}
+
+// GetStorageProof returns the Merkle proof of a slot in the storage trie of an
+// account, proving its absence if the slot is empty.
+func (self *StateDB) GetStorageProof(a common.Address, key common.Hash) ([][]byte, error) {
+	trie := self.StorageTrie(a)
+	if trie == nil {
+		return nil, fmt.Errorf("storage trie for %x does not exist", a)
+	}
+	var proof proofList
+	err := trie.Prove(crypto.Keccak256(key.Bytes()), 0, &proof)
+	return proof, err
+}
+
 func (self *StateDB) HasSuicided(addr common.Address) bool {
 	stateObject := self.getStateObject(addr)
 	if stateObject != nil {
@@ -488,6 +516,23 @@ func (self *StateDB) Copy() *StateDB {
 	return state
 }
 
+// Merge imports the objects modified in another state, overwriting their local
+// versions. Both states must be derived from the same one, and the caller must
+// ensure they modified disjoint sets of accounts since. Objects for which the
+// filter returns false are left untouched.
+func (self *StateDB) Merge(src *StateDB, filter func(addr common.Address) bool) {
+	src.lock.Lock()
+	defer src.lock.Unlock()
+
+	for addr := range src.stateObjectsDirty {
+		if filter != nil && !filter(addr) {
+			continue
+		}
+		self.stateObjects[addr] = src.stateObjects[addr].deepCopy(self, self.MarkStateObjectDirty)
+		self.stateObjectsDirty[addr] = struct{}{}
+	}
+}
+
 // Snapshot returns an identifier for the current revision of the state.
 func (self *StateDB) Snapshot() int {
 	id := self.nextRevisionId
@@ -32,7 +32,9 @@ import (
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/trie"
 )
 
 // Tests that updating a state trie does not leak any database writes prior to
@@ -421,3 +423,52 @@ func (s *StateSuite) TestTouchDelete(c *check.C) {
 		c.Fatal("expected no dirty state object")
 	}
 }
+
+// Tests that account and storage proofs verify against the state and storage
+// roots, both for existing and missing entries.
+func TestGetProof(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+	state, _ := New(common.Hash{}, NewDatabase(db))
+
+	addr, missing := common.HexToAddress("0xaa"), common.HexToAddress("0xbb")
+	state.SetBalance(addr, big.NewInt(42))
+	state.SetState(addr, common.HexToHash("0x01"), common.HexToHash("0x2a"))
+	root, _ := state.Commit(false)
+	state.Database().TrieDB().Commit(root, false)
+	state, _ = New(root, state.Database())
+
+	verify := func(root common.Hash, key []byte, proof [][]byte) []byte {
+		proofDb, _ := ethdb.NewMemDatabase()
+		for _, node := range proof {
+			proofDb.Put(crypto.Keccak256(node), node)
+		}
+		value, err, _ := trie.VerifyProof(root, crypto.Keccak256(key), proofDb)
+		if err != nil {
+			t.Fatalf("proof of %x failed to verify: %v", key, err)
+		}
+		return value
+	}
+	proof, err := state.GetProof(addr)
+	if err != nil {
+		t.Fatalf("failed to prove account: %v", err)
+	}
+	if value := verify(root, addr.Bytes(), proof); value == nil {
+		t.Errorf("account proof missing value")
+	}
+	if proof, err = state.GetProof(missing); err != nil {
+		t.Fatalf("failed to prove missing account: %v", err)
+	}
+	if value := verify(root, missing.Bytes(), proof); value != nil {
+		t.Errorf("missing account proof has value %x", value)
+	}
+	storageRoot := state.StorageTrie(addr).Hash()
+	if proof, err = state.GetStorageProof(addr, common.HexToHash("0x01")); err != nil {
+		t.Fatalf("failed to prove storage: %v", err)
+	}
+	if value := verify(storageRoot, common.HexToHash("0x01").Bytes(), proof); !bytes.Equal(value, []byte{0x2a}) {
+		t.Errorf("storage proof value mismatch: have %x", value)
+	}
+	if _, err := state.GetStorageProof(missing, common.Hash{}); err == nil {
+		t.Errorf("storage proof of missing account succeeded")
+	}
+}
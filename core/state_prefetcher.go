@@ -0,0 +1,70 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"sync/atomic"
+
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+var (
+	prefetchTimer       = metrics.NewRegisteredTimer("chain/prefetch", nil)
+	prefetchAbortsMeter = metrics.NewRegisteredMeter("chain/prefetch/aborts", nil)
+)
+
+// statePrefetcher is a basic Prefetcher, which blindly executes a block on top
+// of an arbitrary state with the goal of prefetching potentially useful state
+// data from disk before the main block processor starts executing.
+type statePrefetcher struct {
+	config *params.ChainConfig // Chain configuration options
+	bc     *BlockChain         // Canonical block chain
+}
+
+// newStatePrefetcher initialises a new statePrefetcher.
+func newStatePrefetcher(config *params.ChainConfig, bc *BlockChain) *statePrefetcher {
+	return &statePrefetcher{
+		config: config,
+		bc:     bc,
+	}
+}
+
+// Prefetch processes the state changes according to the Ethereum rules by running
+// the transaction messages using the statedb, but any changes are discarded. The
+// only goal is to pre-cache the accounts, storage slots and code touched by the
+// transactions. Processing stops at the first invalid transaction, or as soon as
+// interrupt is set.
+func (p *statePrefetcher) Prefetch(block *types.Block, statedb *state.StateDB, cfg vm.Config, interrupt *uint32) {
+	var (
+		header  = block.Header()
+		gaspool = new(GasPool).AddGas(block.GasLimit())
+	)
+	for i, tx := range block.Transactions() {
+		if atomic.LoadUint32(interrupt) == 1 {
+			prefetchAbortsMeter.Mark(1)
+			return
+		}
+		statedb.Prepare(tx.Hash(), block.Hash(), i)
+		if _, _, err := ApplyTransaction(p.config, p.bc, nil, gaspool, statedb, header, tx, new(uint64), cfg); err != nil {
+			return
+		}
+	}
+}
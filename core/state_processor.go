@@ -76,7 +76,13 @@ func (p *StateProcessor) Process(block *types.Block, statedb *state.StateDB, cfg
 		allLogs = append(allLogs, receipt.Logs...)
 	}
 	// Finalize the block, applying any consensus engine specific extras (e.g. block rewards)
-	p.engine.Finalize(p.bc, header, statedb, block.Transactions(), block.Uncles(), receipts)
+	_, receipts, err := consensus.Finalize(p.engine, p.bc, header, statedb, block.Transactions(), block.Uncles(), receipts)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	for _, receipt := range receipts[len(block.Transactions()):] {
+		allLogs = append(allLogs, receipt.Logs...)
+	}
 
 	return receipts, allLogs, *usedGas, nil
 }
@@ -101,8 +101,40 @@ var (
 	// General tx metrics
 	invalidTxCounter     = metrics.NewRegisteredCounter("txpool/invalid", nil)
 	underpricedTxCounter = metrics.NewRegisteredCounter("txpool/underpriced", nil)
+
+	// Composition of the pool, updated on every stats report. Transactions are
+	// also counted into buckets by the number of pooled transactions of their
+	// sender (see senderBuckets), making floods from single accounts visible.
+	pendingGauge        = metrics.NewRegisteredGauge("txpool/pending", nil)
+	queuedGauge         = metrics.NewRegisteredGauge("txpool/queued", nil)
+	pendingSenderGauges = newSenderGauges("txpool/pending/senders")
+	queuedSenderGauges  = newSenderGauges("txpool/queued/senders")
+
+	// Gas price distribution of the pending transactions, in wei
+	gasPriceAvgGauge = metrics.NewRegisteredGauge("txpool/gasprice/avg", nil)
+	gasPriceP50Gauge = metrics.NewRegisteredGauge("txpool/gasprice/p50", nil)
+	gasPriceP90Gauge = metrics.NewRegisteredGauge("txpool/gasprice/p90", nil)
+	gasPriceP99Gauge = metrics.NewRegisteredGauge("txpool/gasprice/p99", nil)
+
+	// Time from a transaction entering the pool until its inclusion in the
+	// canonical chain, which is final right away with istanbul
+	inclusionTimer = metrics.NewRegisteredTimer("txpool/inclusion", nil)
 )
 
+// senderBuckets are the upper limits of the sender buckets, by the number of
+// transactions a sender has in the pool. Senders above the last go into "more".
+var senderBuckets = []int{1, 4, 16, 64}
+
+// newSenderGauges registers the gauges of the sender buckets under prefix.
+func newSenderGauges(prefix string) []metrics.Gauge {
+	gauges := make([]metrics.Gauge, len(senderBuckets)+1)
+	for i, limit := range senderBuckets {
+		gauges[i] = metrics.NewRegisteredGauge(fmt.Sprintf("%s/%d", prefix, limit), nil)
+	}
+	gauges[len(senderBuckets)] = metrics.NewRegisteredGauge(prefix+"/more", nil)
+	return gauges
+}
+
 // TxStatus is the current status of a transaction as seen by the pool.
 type TxStatus uint
 
@@ -189,6 +221,7 @@ type TxPool struct {
 	chain        blockChain
 	gasPrice     *big.Int
 	txFeed       event.Feed
+	dropFeed     event.Feed
 	scope        event.SubscriptionScope
 	chainHeadCh  chan ChainHeadEvent
 	chainHeadSub event.Subscription
@@ -207,6 +240,7 @@ type TxPool struct {
 	beats   map[common.Address]time.Time       // Last heartbeat from each known account
 	all     map[common.Hash]*types.Transaction // All transactions to allow lookups
 	priced  *txPricedList                      // All transactions sorted by price
+	seen    map[common.Hash]time.Time          // Time transactions entered the pool
 
 	wg sync.WaitGroup // for shutdown sync
 
@@ -229,6 +263,7 @@ func NewTxPool(config TxPoolConfig, chainconfig *params.ChainConfig, chain block
 		queue:       make(map[common.Address]*txList),
 		beats:       make(map[common.Address]time.Time),
 		all:         make(map[common.Hash]*types.Transaction),
+		seen:        make(map[common.Hash]time.Time),
 		chainHeadCh: make(chan ChainHeadEvent, chainHeadChanSize),
 		gasPrice:    new(big.Int).SetUint64(config.PriceLimit),
 	}
@@ -289,6 +324,9 @@ func (pool *TxPool) loop() {
 					pool.homestead = true
 				}
 				pool.reset(head.Header(), ev.Block.Header())
+				if metrics.Enabled {
+					pool.trackInclusions(head, ev.Block)
+				}
 				head = ev.Block
 
 				pool.mu.Unlock()
@@ -302,6 +340,9 @@ func (pool *TxPool) loop() {
 			pool.mu.RLock()
 			pending, queued := pool.stats()
 			stales := pool.priced.stales
+			if metrics.Enabled {
+				pool.updateMetrics()
+			}
 			pool.mu.RUnlock()
 
 			if pending != prevPending || queued != prevQueued || stales != prevStales {
@@ -321,9 +362,16 @@ func (pool *TxPool) loop() {
 				if time.Since(pool.beats[addr]) > pool.config.Lifetime {
 					for _, tx := range pool.queue[addr].Flatten() {
 						pool.removeTx(tx.Hash())
+						pool.dropped(tx, TxDropNonceGap, nil)
 					}
 				}
 			}
+			// Forget the arrival of transactions gone without being seen included
+			for hash := range pool.seen {
+				if pool.all[hash] == nil {
+					delete(pool.seen, hash)
+				}
+			}
 			pool.mu.Unlock()
 
 		// Handle local transaction journal rotation
@@ -452,6 +500,24 @@ func (pool *TxPool) SubscribeTxPreEvent(ch chan<- TxPreEvent) event.Subscription
 	return pool.scope.Track(pool.txFeed.Subscribe(ch))
 }
 
+// SubscribeTxDropEvent registers a subscription of TxDropEvent and starts sending
+// event to the given channel.
+func (pool *TxPool) SubscribeTxDropEvent(ch chan<- TxDropEvent) event.Subscription {
+	return pool.scope.Track(pool.dropFeed.Subscribe(ch))
+}
+
+// dropped notifies subsystems of a transaction rejected by or evicted from the
+// pool.
+func (pool *TxPool) dropped(tx *types.Transaction, reason TxDropReason, err error) {
+	go pool.dropFeed.Send(TxDropEvent{Tx: tx, Reason: reason, Err: err})
+}
+
+// replaced notifies subsystems of a transaction replaced by another one with the
+// same nonce.
+func (pool *TxPool) replaced(old, tx *types.Transaction) {
+	go pool.dropFeed.Send(TxDropEvent{Tx: old, Reason: TxDropReplaced, Replacement: tx})
+}
+
 // GasPrice returns the current gas price enforced by the transaction pool.
 func (pool *TxPool) GasPrice() *big.Int {
 	pool.mu.RLock()
@@ -469,6 +535,7 @@ func (pool *TxPool) SetGasPrice(price *big.Int) {
 	pool.gasPrice = price
 	for _, tx := range pool.priced.Cap(price, pool.locals) {
 		pool.removeTx(tx.Hash())
+		pool.dropped(tx, TxDropUnderpriced, nil)
 	}
 	log.Info("Transaction pool price threshold updated", "price", price)
 }
@@ -504,6 +571,81 @@ func (pool *TxPool) stats() (int, int) {
 	return pending, queued
 }
 
+// updateMetrics refreshes the gauges describing the composition of the pool and
+// the gas prices of the pending transactions. The method assumes that the pool
+// lock is held.
+func (pool *TxPool) updateMetrics() {
+	pendingGauge.Update(int64(bucketSenders(pool.pending, pendingSenderGauges)))
+	queuedGauge.Update(int64(bucketSenders(pool.queue, queuedSenderGauges)))
+
+	var (
+		prices []*big.Int
+		sum    = new(big.Int)
+	)
+	for _, list := range pool.pending {
+		for _, tx := range list.txs.items {
+			prices = append(prices, tx.GasPrice())
+			sum.Add(sum, tx.GasPrice())
+		}
+	}
+	if len(prices) == 0 {
+		for _, gauge := range []metrics.Gauge{gasPriceAvgGauge, gasPriceP50Gauge, gasPriceP90Gauge, gasPriceP99Gauge} {
+			gauge.Update(0)
+		}
+		return
+	}
+	sort.Slice(prices, func(i, j int) bool { return prices[i].Cmp(prices[j]) < 0 })
+	percentile := func(p int) *big.Int { return prices[(len(prices)-1)*p/100] }
+
+	gasPriceAvgGauge.Update(gaugeValue(sum.Div(sum, big.NewInt(int64(len(prices))))))
+	gasPriceP50Gauge.Update(gaugeValue(percentile(50)))
+	gasPriceP90Gauge.Update(gaugeValue(percentile(90)))
+	gasPriceP99Gauge.Update(gaugeValue(percentile(99)))
+}
+
+// bucketSenders updates the gauges of the sender buckets with the number of
+// transactions in each, returning the total number of transactions.
+func bucketSenders(lists map[common.Address]*txList, gauges []metrics.Gauge) int {
+	var (
+		counts = make([]int64, len(gauges))
+		total  int
+	)
+	for _, list := range lists {
+		n := list.Len()
+		counts[sort.SearchInts(senderBuckets, n)] += int64(n)
+		total += n
+	}
+	for i, gauge := range gauges {
+		gauge.Update(counts[i])
+	}
+	return total
+}
+
+// gaugeValue converts a big integer into a gauge value, capping it at the int64
+// range.
+func gaugeValue(x *big.Int) int64 {
+	if !x.IsInt64() {
+		return math.MaxInt64
+	}
+	return x.Int64()
+}
+
+// trackInclusions measures how long the transactions included by the blocks since
+// the previous head spent in the pool. Like reset, it gives up on deep gaps. The
+// method assumes that the pool lock is held.
+func (pool *TxPool) trackInclusions(oldHead, newHead *types.Block) {
+	block := newHead
+	for i := 0; block != nil && block.NumberU64() > oldHead.NumberU64() && i < 64; i++ {
+		for _, tx := range block.Transactions() {
+			if seen, ok := pool.seen[tx.Hash()]; ok {
+				inclusionTimer.UpdateSince(seen)
+				delete(pool.seen, tx.Hash())
+			}
+		}
+		block = pool.chain.GetBlock(block.ParentHash(), block.NumberU64()-1)
+	}
+}
+
 // Content retrieves the data content of the transaction pool, returning all the
 // pending as well as queued transactions, grouped by account and sorted by nonce.
 func (pool *TxPool) Content() (map[common.Address]types.Transactions, map[common.Address]types.Transactions) {
@@ -535,6 +677,19 @@ func (pool *TxPool) Pending() (map[common.Address]types.Transactions, error) {
 	return pending, nil
 }
 
+// Arrivals returns the times the transactions currently in the pool entered it,
+// for sealers to include them in arrival order.
+func (pool *TxPool) Arrivals() map[common.Hash]time.Time {
+	pool.mu.RLock()
+	defer pool.mu.RUnlock()
+
+	arrivals := make(map[common.Hash]time.Time, len(pool.seen))
+	for hash, at := range pool.seen {
+		arrivals[hash] = at
+	}
+	return arrivals
+}
+
 // local retrieves all currently known local transactions, groupped by origin
 // account and sorted by nonce. The returned transaction set is a copy and can be
 // freely modified by calling code.
@@ -615,6 +770,7 @@ func (pool *TxPool) add(tx *types.Transaction, local bool) (bool, error) {
 	if err := pool.validateTx(tx, local); err != nil {
 		log.Trace("Discarding invalid transaction", "hash", hash, "err", err)
 		invalidTxCounter.Inc(1)
+		pool.dropped(tx, TxDropInvalid, err)
 		return false, err
 	}
 	// If the transaction pool is full, discard underpriced transactions
@@ -623,6 +779,7 @@ func (pool *TxPool) add(tx *types.Transaction, local bool) (bool, error) {
 		if pool.priced.Underpriced(tx, pool.locals) {
 			log.Trace("Discarding underpriced transaction", "hash", hash, "price", tx.GasPrice())
 			underpricedTxCounter.Inc(1)
+			pool.dropped(tx, TxDropUnderpriced, ErrUnderpriced)
 			return false, ErrUnderpriced
 		}
 		// New transaction is better than our worse ones, make room for it
@@ -631,8 +788,11 @@ func (pool *TxPool) add(tx *types.Transaction, local bool) (bool, error) {
 			log.Trace("Discarding freshly underpriced transaction", "hash", tx.Hash(), "price", tx.GasPrice())
 			underpricedTxCounter.Inc(1)
 			pool.removeTx(tx.Hash())
+			pool.dropped(tx, TxDropUnderpriced, nil)
 		}
 	}
+	// Remember when the transaction arrived to order blocks and measure its inclusion latency
+	pool.seen[hash] = time.Now()
 	// If the transaction is replacing an already pending one, do directly
 	from, _ := types.Sender(pool.signer, tx) // already validated
 	if list := pool.pending[from]; list != nil && list.Overlaps(tx) {
@@ -640,6 +800,7 @@ func (pool *TxPool) add(tx *types.Transaction, local bool) (bool, error) {
 		inserted, old := list.Add(tx, pool.config.PriceBump)
 		if !inserted {
 			pendingDiscardCounter.Inc(1)
+			pool.dropped(tx, TxDropUnderpriced, ErrReplaceUnderpriced)
 			return false, ErrReplaceUnderpriced
 		}
 		// New transaction is better, replace old one
@@ -647,6 +808,7 @@ func (pool *TxPool) add(tx *types.Transaction, local bool) (bool, error) {
 			delete(pool.all, old.Hash())
 			pool.priced.Removed()
 			pendingReplaceCounter.Inc(1)
+			pool.replaced(old, tx)
 		}
 		pool.all[tx.Hash()] = tx
 		pool.priced.Put(tx)
@@ -687,6 +849,7 @@ func (pool *TxPool) enqueueTx(hash common.Hash, tx *types.Transaction) (bool, er
 	if !inserted {
 		// An older transaction was better, discard this
 		queuedDiscardCounter.Inc(1)
+		pool.dropped(tx, TxDropUnderpriced, ErrReplaceUnderpriced)
 		return false, ErrReplaceUnderpriced
 	}
 	// Discard any previous transaction and mark this
@@ -694,6 +857,7 @@ func (pool *TxPool) enqueueTx(hash common.Hash, tx *types.Transaction) (bool, er
 		delete(pool.all, old.Hash())
 		pool.priced.Removed()
 		queuedReplaceCounter.Inc(1)
+		pool.replaced(old, tx)
 	}
 	pool.all[hash] = tx
 	pool.priced.Put(tx)
@@ -729,6 +893,7 @@ func (pool *TxPool) promoteTx(addr common.Address, hash common.Hash, tx *types.T
 		pool.priced.Removed()
 
 		pendingDiscardCounter.Inc(1)
+		pool.dropped(tx, TxDropUnderpriced, ErrReplaceUnderpriced)
 		return
 	}
 	// Otherwise discard any previous transaction and mark this
@@ -737,6 +902,7 @@ func (pool *TxPool) promoteTx(addr common.Address, hash common.Hash, tx *types.T
 		pool.priced.Removed()
 
 		pendingReplaceCounter.Inc(1)
+		pool.replaced(old, tx)
 	}
 	// Failsafe to work around direct pending inserts (tests)
 	if pool.all[hash] == nil {
@@ -934,6 +1100,7 @@ func (pool *TxPool) promoteExecutables(accounts []common.Address) {
 			delete(pool.all, hash)
 			pool.priced.Removed()
 			queuedNofundsCounter.Inc(1)
+			pool.dropped(tx, TxDropUnpayable, nil)
 		}
 		// Gather all executable transactions and promote them
 		for _, tx := range list.Ready(pool.pendingState.GetNonce(addr)) {
@@ -949,6 +1116,7 @@ func (pool *TxPool) promoteExecutables(accounts []common.Address) {
 				pool.priced.Removed()
 				queuedRateLimitCounter.Inc(1)
 				log.Trace("Removed cap-exceeding queued transaction", "hash", hash)
+				pool.dropped(tx, TxDropPoolFull, nil)
 			}
 		}
 		// Delete the entire queue entry if it became empty.
@@ -998,6 +1166,7 @@ func (pool *TxPool) promoteExecutables(accounts []common.Address) {
 								pool.pendingState.SetNonce(offenders[i], nonce)
 							}
 							log.Trace("Removed fairness-exceeding pending transaction", "hash", hash)
+							pool.dropped(tx, TxDropPoolFull, nil)
 						}
 						pending--
 					}
@@ -1020,6 +1189,7 @@ func (pool *TxPool) promoteExecutables(accounts []common.Address) {
 							pool.pendingState.SetNonce(addr, nonce)
 						}
 						log.Trace("Removed fairness-exceeding pending transaction", "hash", hash)
+						pool.dropped(tx, TxDropPoolFull, nil)
 					}
 					pending--
 				}
@@ -1053,6 +1223,7 @@ func (pool *TxPool) promoteExecutables(accounts []common.Address) {
 			if size := uint64(list.Len()); size <= drop {
 				for _, tx := range list.Flatten() {
 					pool.removeTx(tx.Hash())
+					pool.dropped(tx, TxDropPoolFull, nil)
 				}
 				drop -= size
 				queuedRateLimitCounter.Inc(int64(size))
@@ -1062,6 +1233,7 @@ func (pool *TxPool) promoteExecutables(accounts []common.Address) {
 			txs := list.Flatten()
 			for i := len(txs) - 1; i >= 0 && drop > 0; i-- {
 				pool.removeTx(txs[i].Hash())
+				pool.dropped(txs[i], TxDropPoolFull, nil)
 				drop--
 				queuedRateLimitCounter.Inc(1)
 			}
@@ -1092,6 +1264,7 @@ func (pool *TxPool) demoteUnexecutables() {
 			delete(pool.all, hash)
 			pool.priced.Removed()
 			pendingNofundsCounter.Inc(1)
+			pool.dropped(tx, TxDropUnpayable, nil)
 		}
 		for _, tx := range invalids {
 			hash := tx.Hash()
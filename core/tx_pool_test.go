@@ -32,6 +32,7 @@ import (
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/metrics"
 	"github.com/ethereum/go-ethereum/params"
 )
 
@@ -1430,6 +1431,59 @@ func TestTransactionPoolUnderpricing(t *testing.T) {
 	}
 }
 
+// Tests that rejected, replaced and evicted transactions are announced along
+// with the reason of their dropping.
+func TestTransactionDropEvents(t *testing.T) {
+	t.Parallel()
+
+	pool, key := setupTxPool()
+	defer pool.Stop()
+
+	drops := make(chan TxDropEvent, 32)
+	sub := pool.SubscribeTxDropEvent(drops)
+	defer sub.Unsubscribe()
+
+	pool.currentState.AddBalance(crypto.PubkeyToAddress(key.PublicKey), big.NewInt(1000000000))
+
+	expect := func(tx *types.Transaction, reason TxDropReason, err error, replacement *types.Transaction) {
+		select {
+		case ev := <-drops:
+			if ev.Tx.Hash() != tx.Hash() || ev.Reason != reason || ev.Err != err || ev.Replacement != replacement {
+				t.Fatalf("drop event mismatch: have %x %s %v %v, want %x %s %v %v", ev.Tx.Hash(), ev.Reason, ev.Err, ev.Replacement, tx.Hash(), reason, err, replacement)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("drop event of %x (%s) not fired", tx.Hash(), reason)
+		}
+	}
+	original := pricedTransaction(0, 100000, big.NewInt(1), key)
+	if err := pool.AddRemote(original); err != nil {
+		t.Fatalf("failed to add original transaction: %v", err)
+	}
+	cheap := pricedTransaction(0, 100001, big.NewInt(1), key)
+	pool.AddRemote(cheap)
+	expect(cheap, TxDropUnderpriced, ErrReplaceUnderpriced, nil)
+
+	replacement := pricedTransaction(0, 100000, big.NewInt(2), key)
+	if err := pool.AddRemote(replacement); err != nil {
+		t.Fatalf("failed to replace original transaction: %v", err)
+	}
+	expect(original, TxDropReplaced, nil, replacement)
+
+	unfunded, _ := crypto.GenerateKey()
+	invalid := transaction(0, 100000, unfunded)
+	pool.AddRemote(invalid)
+	expect(invalid, TxDropInvalid, ErrInsufficientFunds, nil)
+
+	pool.SetGasPrice(big.NewInt(3))
+	expect(replacement, TxDropUnderpriced, nil, nil)
+
+	select {
+	case ev := <-drops:
+		t.Fatalf("unexpected drop event: %x %s", ev.Tx.Hash(), ev.Reason)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
 // Tests that the pool rejects replacement transactions that don't meet the minimum
 // price bump required.
 func TestTransactionReplacement(t *testing.T) {
@@ -1777,3 +1831,54 @@ func benchmarkPoolBatchInsert(b *testing.B, size int) {
 		pool.AddRemotes(batch)
 	}
 }
+
+// Tests that the pool composition is bucketed by sender correctly and that the
+// arrival of included transactions is forgotten.
+func TestTransactionPoolMetrics(t *testing.T) {
+	t.Parallel()
+
+	pool, _ := setupTxPool()
+	defer pool.Stop()
+
+	// Fill the pool with senders of various transaction counts
+	var included *types.Transaction
+	for _, count := range []int{1, 3, 70} {
+		key, _ := crypto.GenerateKey()
+		pool.currentState.AddBalance(crypto.PubkeyToAddress(key.PublicKey), big.NewInt(1000000000))
+
+		txs := make([]*types.Transaction, count)
+		for i := 0; i < count; i++ {
+			txs[i] = transaction(uint64(i), 100000, key)
+		}
+		for i, err := range pool.AddRemotes(txs) {
+			if err != nil {
+				t.Fatalf("tx %d: failed to add transaction: %v", i, err)
+			}
+		}
+		included = txs[0]
+	}
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	gauges := make([]metrics.Gauge, len(senderBuckets)+1)
+	for i := range gauges {
+		gauges[i] = new(metrics.StandardGauge)
+	}
+	if total := bucketSenders(pool.pending, gauges); total != 74 {
+		t.Errorf("pending transaction count mismatch: have %d, want %d", total, 74)
+	}
+	for i, want := range []int64{1, 3, 0, 0, 70} {
+		if have := gauges[i].Value(); have != want {
+			t.Errorf("sender bucket %d: transaction count mismatch: have %d, want %d", i, have, want)
+		}
+	}
+	// Include a transaction and make sure its arrival is forgotten
+	pool.seen[included.Hash()] = time.Now()
+
+	head := types.NewBlock(&types.Header{Number: big.NewInt(1)}, types.Transactions{included}, nil, nil)
+	pool.trackInclusions(pool.chain.CurrentBlock(), head)
+
+	if _, ok := pool.seen[included.Hash()]; ok {
+		t.Errorf("arrival of included transaction not forgotten")
+	}
+}
@@ -17,10 +17,12 @@
 package types
 
 import (
+	"bytes"
 	"errors"
 	"io"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/rlp"
 )
 
@@ -31,6 +33,11 @@ var (
 
 	IstanbulExtraVanity = 32 // Fixed number of extra-data bytes reserved for validator vanity
 	IstanbulExtraSeal   = 65 // Fixed number of extra-data bytes reserved for validator seal
+	IstanbulExtraSignal = 8  // Trailing vanity bytes reserved for signaling readiness for an upgrade
+
+	// istanbulSignalMarker prefixes an upgrade signal in the vanity, telling it
+	// apart from arbitrary vanity content.
+	istanbulSignalMarker = []byte("upg:")
 
 	// ErrInvalidIstanbulHeaderExtra is returned if the length of extra-data is less than 32 bytes
 	ErrInvalidIstanbulHeaderExtra = errors.New("invalid istanbul header extra-data")
@@ -40,15 +47,25 @@ type IstanbulExtra struct {
 	Validators    []common.Address
 	Seal          []byte
 	CommittedSeal [][]byte
+
+	// ParentCommittedSeal carries the committed seals of the parent block. Unlike
+	// CommittedSeal it is covered by the block hash, making the set of parent
+	// committers canonical. It's omitted from the encoding if empty, keeping the
+	// hashes of blocks without it unchanged.
+	ParentCommittedSeal [][]byte
 }
 
 // EncodeRLP serializes ist into the Ethereum RLP format.
 func (ist *IstanbulExtra) EncodeRLP(w io.Writer) error {
-	return rlp.Encode(w, []interface{}{
+	fields := []interface{}{
 		ist.Validators,
 		ist.Seal,
 		ist.CommittedSeal,
-	})
+	}
+	if len(ist.ParentCommittedSeal) > 0 {
+		fields = append(fields, ist.ParentCommittedSeal)
+	}
+	return rlp.Encode(w, fields)
 }
 
 // DecodeRLP implements rlp.Decoder, and load the istanbul fields from a RLP stream.
@@ -57,11 +74,24 @@ func (ist *IstanbulExtra) DecodeRLP(s *rlp.Stream) error {
 		Validators    []common.Address
 		Seal          []byte
 		CommittedSeal [][]byte
+		Rest          [][][]byte `rlp:"tail"`
 	}
 	if err := s.Decode(&istanbulExtra); err != nil {
 		return err
 	}
 	ist.Validators, ist.Seal, ist.CommittedSeal = istanbulExtra.Validators, istanbulExtra.Seal, istanbulExtra.CommittedSeal
+
+	switch len(istanbulExtra.Rest) {
+	case 0:
+		ist.ParentCommittedSeal = nil
+	case 1:
+		if len(istanbulExtra.Rest[0]) == 0 {
+			return ErrInvalidIstanbulHeaderExtra // must be omitted instead, keeping the encoding unique
+		}
+		ist.ParentCommittedSeal = istanbulExtra.Rest[0]
+	default:
+		return ErrInvalidIstanbulHeaderExtra
+	}
 	return nil
 }
 
@@ -69,18 +99,42 @@ func (ist *IstanbulExtra) DecodeRLP(s *rlp.Stream) error {
 // error if the length of the given extra-data is less than 32 bytes or the extra-data can not
 // be decoded.
 func ExtractIstanbulExtra(h *Header) (*IstanbulExtra, error) {
-	if len(h.Extra) < IstanbulExtraVanity {
+	return DecodeIstanbulExtra(h.Extra)
+}
+
+// DecodeIstanbulExtra decodes the IstanbulExtra from raw header extra-data, the
+// vanity prefix skipped.
+func DecodeIstanbulExtra(extra []byte) (*IstanbulExtra, error) {
+	if len(extra) < IstanbulExtraVanity {
 		return nil, ErrInvalidIstanbulHeaderExtra
 	}
 
 	var istanbulExtra *IstanbulExtra
-	err := rlp.DecodeBytes(h.Extra[IstanbulExtraVanity:], &istanbulExtra)
+	err := rlp.DecodeBytes(extra[IstanbulExtraVanity:], &istanbulExtra)
 	if err != nil {
 		return nil, err
 	}
 	return istanbulExtra, nil
 }
 
+// IstanbulGenesisExtra assembles the extra-data of an Istanbul genesis block out
+// of a vanity, truncated or zero padded to IstanbulExtraVanity bytes, and the
+// initial validators. The seals are left empty.
+func IstanbulGenesisExtra(vanity []byte, validators []common.Address) ([]byte, error) {
+	extra := make([]byte, IstanbulExtraVanity)
+	copy(extra, vanity)
+
+	payload, err := rlp.EncodeToBytes(&IstanbulExtra{
+		Validators:    validators,
+		Seal:          []byte{},
+		CommittedSeal: [][]byte{},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return append(extra, payload...), nil
+}
+
 // IstanbulFilteredHeader returns a filtered header which some information (like seal, committed seals)
 // are clean to fulfill the Istanbul hash rules. It returns nil if the extra-data cannot be
 // decoded/encoded by rlp.
@@ -105,3 +159,42 @@ func IstanbulFilteredHeader(h *Header, keepSeal bool) *Header {
 
 	return newHeader
 }
+
+// IstanbulUpgradeID returns the identifier validators signal readiness for the
+// named protocol upgrade with.
+func IstanbulUpgradeID(name string) (id [4]byte) {
+	copy(id[:], crypto.Keccak256([]byte(name)))
+	return id
+}
+
+// IstanbulSignal returns the upgrade the proposer of the header signaled
+// readiness for, if any.
+func IstanbulSignal(h *Header) ([4]byte, bool) {
+	var id [4]byte
+	if len(h.Extra) < IstanbulExtraVanity {
+		return id, false
+	}
+	signal := h.Extra[IstanbulExtraVanity-IstanbulExtraSignal : IstanbulExtraVanity]
+	if !bytes.HasPrefix(signal, istanbulSignalMarker) {
+		return id, false
+	}
+	copy(id[:], signal[len(istanbulSignalMarker):])
+	return id, true
+}
+
+// SetIstanbulSignal writes an upgrade readiness signal into the reserved tail
+// of the given vanity, which must be IstanbulExtraVanity bytes long.
+func SetIstanbulSignal(vanity []byte, id [4]byte) {
+	signal := vanity[IstanbulExtraVanity-IstanbulExtraSignal : IstanbulExtraVanity]
+	copy(signal, istanbulSignalMarker)
+	copy(signal[len(istanbulSignalMarker):], id[:])
+}
+
+// ClearIstanbulSignal removes the upgrade readiness signal from the reserved tail
+// of the given vanity, which must be IstanbulExtraVanity bytes long.
+func ClearIstanbulSignal(vanity []byte) {
+	signal := vanity[IstanbulExtraVanity-IstanbulExtraSignal : IstanbulExtraVanity]
+	for i := range signal {
+		signal[i] = 0
+	}
+}
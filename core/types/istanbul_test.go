@@ -23,6 +23,7 @@ import (
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rlp"
 )
 
 func TestHeaderHash(t *testing.T) {
@@ -86,3 +87,95 @@ func TestExtractToIstanbul(t *testing.T) {
 		}
 	}
 }
+
+func TestIstanbulGenesisExtra(t *testing.T) {
+	validators := []common.Address{
+		common.HexToAddress("0x44add0ec310f115a0e603b2d7db9f067778eaf8a"),
+		common.HexToAddress("0x294fc7e8f22b3bcdcf955dd7ff3ba2ed833f8212"),
+	}
+	for _, vanity := range [][]byte{nil, []byte("vanity"), bytes.Repeat([]byte{0x01}, IstanbulExtraVanity+1)} {
+		extra, err := IstanbulGenesisExtra(vanity, validators)
+		if err != nil {
+			t.Fatalf("failed to encode extra: %v", err)
+		}
+		want := make([]byte, IstanbulExtraVanity)
+		copy(want, vanity)
+		if !bytes.Equal(extra[:IstanbulExtraVanity], want) {
+			t.Errorf("vanity mismatch: have %x, want %x", extra[:IstanbulExtraVanity], want)
+		}
+		decoded, err := DecodeIstanbulExtra(extra)
+		if err != nil {
+			t.Fatalf("failed to decode extra: %v", err)
+		}
+		if !reflect.DeepEqual(decoded.Validators, validators) {
+			t.Errorf("validators mismatch: have %v, want %v", decoded.Validators, validators)
+		}
+		if len(decoded.Seal) != 0 || len(decoded.CommittedSeal) != 0 {
+			t.Errorf("unexpected seals: %x, %x", decoded.Seal, decoded.CommittedSeal)
+		}
+	}
+}
+
+func TestIstanbulSignal(t *testing.T) {
+	extra, err := IstanbulGenesisExtra([]byte("vanity"), nil)
+	if err != nil {
+		t.Fatalf("failed to assemble extra-data: %v", err)
+	}
+	header := &Header{Extra: extra}
+	if _, ok := IstanbulSignal(header); ok {
+		t.Fatalf("signal found in plain vanity")
+	}
+	id := IstanbulUpgradeID("test")
+	SetIstanbulSignal(header.Extra[:IstanbulExtraVanity], id)
+
+	if signal, ok := IstanbulSignal(header); !ok || signal != id {
+		t.Errorf("signal mismatch: have %x (%v), want %x", signal, ok, id)
+	}
+	if !bytes.HasPrefix(header.Extra, []byte("vanity")) {
+		t.Errorf("vanity overwritten: %x", header.Extra[:IstanbulExtraVanity])
+	}
+	if _, err := ExtractIstanbulExtra(header); err != nil {
+		t.Errorf("failed to decode signaling extra-data: %v", err)
+	}
+	ClearIstanbulSignal(header.Extra[:IstanbulExtraVanity])
+	if _, ok := IstanbulSignal(header); ok {
+		t.Errorf("signal found after clearing")
+	}
+	if !bytes.HasPrefix(header.Extra, []byte("vanity")) {
+		t.Errorf("vanity cleared: %x", header.Extra[:IstanbulExtraVanity])
+	}
+}
+
+// Tests that the parent committed seals survive an encoding round trip and are
+// covered by the header hash, unlike the committed seals of the block itself.
+func TestIstanbulParentCommittedSeal(t *testing.T) {
+	encode := func(extra *IstanbulExtra) *Header {
+		payload, err := rlp.EncodeToBytes(extra)
+		if err != nil {
+			t.Fatalf("failed to encode extra: %v", err)
+		}
+		return &Header{MixDigest: IstanbulDigest, Extra: append(make([]byte, IstanbulExtraVanity), payload...)}
+	}
+	plain := encode(&IstanbulExtra{Seal: []byte{}, CommittedSeal: [][]byte{}})
+	sealed := encode(&IstanbulExtra{Seal: []byte{}, CommittedSeal: [][]byte{{0x01}}})
+	parent := encode(&IstanbulExtra{Seal: []byte{}, CommittedSeal: [][]byte{}, ParentCommittedSeal: [][]byte{{0x02}, {0x03}}})
+
+	decoded, err := ExtractIstanbulExtra(parent)
+	if err != nil {
+		t.Fatalf("failed to decode extra: %v", err)
+	}
+	if !reflect.DeepEqual(decoded.ParentCommittedSeal, [][]byte{{0x02}, {0x03}}) {
+		t.Errorf("parent seals mismatch: have %x", decoded.ParentCommittedSeal)
+	}
+	if plain.Hash() != sealed.Hash() {
+		t.Errorf("committed seals covered by the hash")
+	}
+	if plain.Hash() == parent.Hash() {
+		t.Errorf("parent committed seals not covered by the hash")
+	}
+	// An explicitly empty parent seal list would give the same block two encodings
+	payload, _ := rlp.EncodeToBytes([]interface{}{[]common.Address{}, []byte{}, [][]byte{}, [][]byte{}})
+	if _, err := DecodeIstanbulExtra(append(make([]byte, IstanbulExtraVanity), payload...)); err != ErrInvalidIstanbulHeaderExtra {
+		t.Errorf("empty parent seals error mismatch: have %v, want %v", err, ErrInvalidIstanbulHeaderExtra)
+	}
+}
@@ -0,0 +1,107 @@
+// Copyright 2015 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"bytes"
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// AccessListTracer is a Tracer collecting the accounts and storage slots touched
+// during execution, including the ones of reverted calls.
+type AccessListTracer struct {
+	accounts map[common.Address]map[common.Hash]struct{}
+}
+
+// NewAccessListTracer creates a tracer with an empty access list.
+func NewAccessListTracer() *AccessListTracer {
+	return &AccessListTracer{
+		accounts: make(map[common.Address]map[common.Hash]struct{}),
+	}
+}
+
+// addAddress marks an account as accessed.
+func (t *AccessListTracer) addAddress(addr common.Address) {
+	if _, ok := t.accounts[addr]; !ok {
+		t.accounts[addr] = make(map[common.Hash]struct{})
+	}
+}
+
+// addSlot marks a storage slot of an account as accessed.
+func (t *AccessListTracer) addSlot(addr common.Address, slot common.Hash) {
+	t.addAddress(addr)
+	t.accounts[addr][slot] = struct{}{}
+}
+
+// CaptureStart implements Tracer, recording the sender and the recipient.
+func (t *AccessListTracer) CaptureStart(from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) error {
+	t.addAddress(from)
+	t.addAddress(to)
+	return nil
+}
+
+// CaptureState implements Tracer, recording the accounts and storage slots the
+// operation about to be executed accesses.
+func (t *AccessListTracer) CaptureState(env *EVM, pc uint64, op OpCode, gas, cost uint64, memory *Memory, stack *Stack, contract *Contract, depth int, err error) error {
+	switch {
+	case (op == SLOAD || op == SSTORE) && stack.len() >= 1:
+		t.addSlot(contract.Address(), common.BigToHash(stack.Back(0)))
+	case (op == BALANCE || op == EXTCODESIZE || op == EXTCODECOPY || op == SELFDESTRUCT) && stack.len() >= 1:
+		t.addAddress(common.BigToAddress(stack.Back(0)))
+	case (op == CALL || op == CALLCODE || op == DELEGATECALL || op == STATICCALL) && stack.len() >= 2:
+		t.addAddress(common.BigToAddress(stack.Back(1)))
+	case op == CREATE:
+		t.addAddress(crypto.CreateAddress(contract.Address(), env.StateDB.GetNonce(contract.Address())))
+	}
+	return nil
+}
+
+// CaptureFault implements Tracer.
+func (t *AccessListTracer) CaptureFault(env *EVM, pc uint64, op OpCode, gas, cost uint64, memory *Memory, stack *Stack, contract *Contract, depth int, err error) error {
+	return nil
+}
+
+// CaptureEnd implements Tracer.
+func (t *AccessListTracer) CaptureEnd(output []byte, gasUsed uint64, d time.Duration, err error) error {
+	return nil
+}
+
+// AccessList returns the accessed accounts and storage slots, sorted to keep the
+// result deterministic.
+func (t *AccessListTracer) AccessList() types.AccessList {
+	list := make(types.AccessList, 0, len(t.accounts))
+	for addr, slots := range t.accounts {
+		tuple := types.AccessTuple{Address: addr, StorageKeys: make([]common.Hash, 0, len(slots))}
+		for slot := range slots {
+			tuple.StorageKeys = append(tuple.StorageKeys, slot)
+		}
+		sort.Slice(tuple.StorageKeys, func(i, j int) bool {
+			return bytes.Compare(tuple.StorageKeys[i][:], tuple.StorageKeys[j][:]) < 0
+		})
+		list = append(list, tuple)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return bytes.Compare(list[i].Address[:], list[j].Address[:]) < 0
+	})
+	return list
+}
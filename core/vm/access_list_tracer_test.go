@@ -0,0 +1,93 @@
+// Copyright 2015 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// Tests that the access list tracer collects the accounts and storage slots
+// touched by nested calls, deduplicated and sorted.
+func TestAccessListTracer(t *testing.T) {
+	var (
+		sender = common.HexToAddress("0xaa")
+		caller = common.HexToAddress("0xc0de")
+		callee = common.HexToAddress("0xbeef")
+		other  = common.HexToAddress("0x0123")
+	)
+	db, _ := ethdb.NewMemDatabase()
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(db))
+
+	// sload(2), sstore(1, 1), sload(2), balance(other)
+	statedb.SetCode(callee, []byte{
+		byte(PUSH1), 2, byte(SLOAD), byte(POP),
+		byte(PUSH1), 1, byte(PUSH1), 1, byte(SSTORE),
+		byte(PUSH1), 2, byte(SLOAD), byte(POP),
+		byte(PUSH2), 0x01, 0x23, byte(BALANCE), byte(POP),
+	})
+	// call(gas, callee, 0, 0, 0, 0, 0)
+	statedb.SetCode(caller, []byte{
+		byte(PUSH1), 0, byte(DUP1), byte(DUP1), byte(DUP1), byte(DUP1),
+		byte(PUSH2), 0xbe, 0xef, byte(GAS), byte(CALL), byte(POP),
+	})
+	tracer := NewAccessListTracer()
+	ctx := Context{
+		CanTransfer: func(StateDB, common.Address, *big.Int) bool { return true },
+		Transfer:    func(StateDB, common.Address, common.Address, *big.Int) {},
+		BlockNumber: new(big.Int),
+	}
+	evm := NewEVM(ctx, statedb, params.TestChainConfig, Config{Debug: true, Tracer: tracer})
+	if _, _, err := evm.Call(AccountRef(sender), caller, nil, 1000000, new(big.Int)); err != nil {
+		t.Fatalf("call failed: %v", err)
+	}
+	list := tracer.AccessList()
+	want := []struct {
+		addr  common.Address
+		slots []common.Hash
+	}{
+		{sender, nil},
+		{other, nil},
+		{callee, []common.Hash{common.BigToHash(big.NewInt(1)), common.BigToHash(big.NewInt(2))}},
+		{caller, nil},
+	}
+	if len(list) != len(want) {
+		t.Fatalf("access list length mismatch: have %d, want %d", len(list), len(want))
+	}
+	for i, tuple := range list {
+		if tuple.Address != want[i].addr {
+			t.Errorf("tuple %d: address mismatch: have %x, want %x", i, tuple.Address, want[i].addr)
+		}
+		if len(tuple.StorageKeys) != len(want[i].slots) {
+			t.Errorf("tuple %d: storage keys mismatch: have %x, want %x", i, tuple.StorageKeys, want[i].slots)
+			continue
+		}
+		for j, key := range tuple.StorageKeys {
+			if key != want[i].slots[j] {
+				t.Errorf("tuple %d: storage key %d mismatch: have %x, want %x", i, j, key, want[i].slots[j])
+			}
+		}
+	}
+	if n := list.StorageKeys(); n != 2 {
+		t.Errorf("storage key count mismatch: have %d, want 2", n)
+	}
+}
@@ -19,6 +19,7 @@ package vm
 import (
 	"crypto/sha256"
 	"errors"
+	"fmt"
 	"math/big"
 
 	"github.com/ethereum/go-ethereum/common"
@@ -59,6 +60,79 @@ var PrecompiledContractsByzantium = map[common.Address]PrecompiledContract{
 	common.BytesToAddress([]byte{8}): &bn256Pairing{},
 }
 
+// extensions contains the additional precompiled contracts chain configs may
+// enable, keyed by the name they are registered under.
+var extensions = make(map[string]PrecompiledContract)
+
+// RegisterPrecompile makes a precompiled contract available for chain configs to
+// install under the given name. It is meant to be called from the init function
+// of the package implementing the contract, and panics on duplicate names.
+func RegisterPrecompile(name string, p PrecompiledContract) {
+	if _, ok := extensions[name]; ok {
+		panic(fmt.Sprintf("precompiled contract %q already registered", name))
+	}
+	extensions[name] = p
+}
+
+// CheckPrecompiles verifies that all the extension precompiled contracts enabled
+// by a chain config are registered, and don't clash with each other or with the
+// default ones.
+func CheckPrecompiles(config *params.ChainConfig) error {
+	seen := make(map[common.Address]bool)
+	for _, ext := range config.Precompiles {
+		if _, ok := extensions[ext.Name]; !ok {
+			return fmt.Errorf("unknown precompiled contract %q", ext.Name)
+		}
+		if PrecompiledContractsByzantium[ext.Address] != nil || seen[ext.Address] {
+			return fmt.Errorf("precompiled contract %q at occupied address %x", ext.Name, ext.Address)
+		}
+		seen[ext.Address] = true
+	}
+	return nil
+}
+
+// ActivePrecompiles returns the precompiled contracts installed in a block: the
+// default ones of the fork, extended with the ones enabled by the chain config
+// and repriced by its gas overrides.
+func ActivePrecompiles(config *params.ChainConfig, number *big.Int) map[common.Address]PrecompiledContract {
+	precompiles := PrecompiledContractsHomestead
+	if config.IsByzantium(number) {
+		precompiles = PrecompiledContractsByzantium
+	}
+	_, repriced := config.ActiveGasOverrides(number)
+
+	var active map[common.Address]PrecompiledContract
+	install := func(addr common.Address, p PrecompiledContract) {
+		if active == nil {
+			active = make(map[common.Address]PrecompiledContract, len(precompiles)+len(config.Precompiles))
+			for addr, p := range precompiles {
+				active[addr] = p
+			}
+		}
+		active[addr] = p
+	}
+	for _, ext := range config.Precompiles {
+		p, ok := extensions[ext.Name]
+		if !ok || !ext.IsActive(number) {
+			continue
+		}
+		install(ext.Address, p)
+	}
+	for addr, gas := range repriced {
+		p := precompiles[addr]
+		if active != nil {
+			p = active[addr]
+		}
+		if p != nil {
+			install(addr, &repricedContract{PrecompiledContract: p, gas: gas})
+		}
+	}
+	if active == nil {
+		return precompiles
+	}
+	return active
+}
+
 // RunPrecompiledContract runs and evaluates the output of a precompiled contract.
 func RunPrecompiledContract(p PrecompiledContract, input []byte, contract *Contract) (ret []byte, err error) {
 	gas := p.RequiredGas(input)
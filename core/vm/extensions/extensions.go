@@ -0,0 +1,91 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package extensions implements precompiled contracts beyond the ones of the
+// Ethereum protocol, which private chains may enable in their chain config:
+//
+//	"sha512":        SHA-512 hash of the input
+//	"ed25519Verify": Ed25519 signature verification
+//
+// Importing the package registers the contracts with the EVM.
+package extensions
+
+import (
+	"crypto/sha512"
+	"errors"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"golang.org/x/crypto/ed25519"
+)
+
+const (
+	Sha512BaseGas       uint64 = 60   // Base price for a SHA512 operation
+	Sha512PerWordGas    uint64 = 12   // Per-word price for a SHA512 operation
+	Ed25519VerifyGas    uint64 = 2000 // Base price for an Ed25519 signature verification
+	Ed25519PerWordGas   uint64 = 12   // Per-word price of the signed message
+	ed25519HeaderLength        = ed25519.PublicKeySize + ed25519.SignatureSize
+)
+
+var errEd25519InputLength = errors.New("ed25519 input too short")
+
+func init() {
+	vm.RegisterPrecompile("sha512", &sha512hash{})
+	vm.RegisterPrecompile("ed25519Verify", &ed25519Verify{})
+}
+
+// sha512hash implements the SHA-512 hash as a native contract.
+type sha512hash struct{}
+
+// RequiredGas returns the gas required to execute the pre-compiled contract.
+func (c *sha512hash) RequiredGas(input []byte) uint64 {
+	return uint64(len(input)+31)/32*Sha512PerWordGas + Sha512BaseGas
+}
+
+func (c *sha512hash) Run(input []byte) ([]byte, error) {
+	h := sha512.Sum512(input)
+	return h[:], nil
+}
+
+// ed25519Verify implements Ed25519 signature verification as a native contract.
+//
+// The input is the 32 byte public key, followed by the 64 byte signature and
+// the signed message. The output is a 32 byte word, 1 if the signature is valid
+// and 0 otherwise.
+type ed25519Verify struct{}
+
+// RequiredGas returns the gas required to execute the pre-compiled contract.
+func (c *ed25519Verify) RequiredGas(input []byte) uint64 {
+	if len(input) < ed25519HeaderLength {
+		return Ed25519VerifyGas
+	}
+	return uint64(len(input)-ed25519HeaderLength+31)/32*Ed25519PerWordGas + Ed25519VerifyGas
+}
+
+func (c *ed25519Verify) Run(input []byte) ([]byte, error) {
+	if len(input) < ed25519HeaderLength {
+		return nil, errEd25519InputLength
+	}
+	var (
+		pubkey = ed25519.PublicKey(input[:ed25519.PublicKeySize])
+		sig    = input[ed25519.PublicKeySize:ed25519HeaderLength]
+		msg    = input[ed25519HeaderLength:]
+	)
+	if ed25519.Verify(pubkey, msg, sig) {
+		return common.LeftPadBytes([]byte{1}, 32), nil
+	}
+	return make([]byte, 32), nil
+}
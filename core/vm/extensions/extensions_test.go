@@ -0,0 +1,107 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package extensions
+
+import (
+	"bytes"
+	"crypto/rand"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/params"
+	"golang.org/x/crypto/ed25519"
+)
+
+func TestSha512(t *testing.T) {
+	want := common.FromHex("ddaf35a193617abacc417349ae20413112e6fa4e89a97ea20a9eeee64b55d39a2192992a274fc1a836ba3c23a3feebbd454d4423643ce80e2a9ac94fa54ca49f")
+	if have, _ := new(sha512hash).Run([]byte("abc")); !bytes.Equal(have, want) {
+		t.Errorf("hash mismatch: have %x, want %x", have, want)
+	}
+}
+
+func TestEd25519Verify(t *testing.T) {
+	pub, key, _ := ed25519.GenerateKey(rand.Reader)
+	msg := []byte("hello world")
+	sig := ed25519.Sign(key, msg)
+
+	valid := append(append(append([]byte{}, pub...), sig...), msg...)
+	if have, err := new(ed25519Verify).Run(valid); err != nil || !bytes.Equal(have, common.LeftPadBytes([]byte{1}, 32)) {
+		t.Errorf("valid signature: have %x, %v", have, err)
+	}
+	invalid := append(append([]byte{}, valid[:len(valid)-1]...), 'D')
+	if have, err := new(ed25519Verify).Run(invalid); err != nil || !bytes.Equal(have, make([]byte, 32)) {
+		t.Errorf("invalid signature: have %x, %v", have, err)
+	}
+	if _, err := new(ed25519Verify).Run(valid[:ed25519HeaderLength-1]); err != errEd25519InputLength {
+		t.Errorf("short input: error mismatch: have %v, want %v", err, errEd25519InputLength)
+	}
+}
+
+// Tests that the extension precompiles are only callable at their configured
+// addresses once activated.
+func TestActivation(t *testing.T) {
+	addr := common.BytesToAddress([]byte{0x01, 0x00})
+	config := &params.ChainConfig{
+		ChainId:        big.NewInt(1),
+		HomesteadBlock: new(big.Int),
+		EIP150Block:    new(big.Int),
+		EIP155Block:    new(big.Int),
+		EIP158Block:    new(big.Int),
+		ByzantiumBlock: new(big.Int),
+		Precompiles:    []*params.PrecompileConfig{{Name: "sha512", Address: addr, Block: big.NewInt(10)}},
+	}
+	if err := vm.CheckPrecompiles(config); err != nil {
+		t.Fatalf("failed to check precompiles: %v", err)
+	}
+	for _, tt := range []struct {
+		number int64
+		want   []byte
+	}{
+		{9, nil},
+		{10, common.FromHex("ddaf35a193617abacc417349ae20413112e6fa4e89a97ea20a9eeee64b55d39a2192992a274fc1a836ba3c23a3feebbd454d4423643ce80e2a9ac94fa54ca49f")},
+	} {
+		db, _ := ethdb.NewMemDatabase()
+		statedb, _ := state.New(common.Hash{}, state.NewDatabase(db))
+
+		ctx := vm.Context{
+			CanTransfer: func(vm.StateDB, common.Address, *big.Int) bool { return true },
+			Transfer:    func(vm.StateDB, common.Address, common.Address, *big.Int) {},
+			BlockNumber: big.NewInt(tt.number),
+		}
+		evm := vm.NewEVM(ctx, statedb, config, vm.Config{})
+		ret, _, err := evm.Call(vm.AccountRef(common.Address{}), addr, []byte("abc"), 100000, new(big.Int))
+		if err != nil {
+			t.Fatalf("block %d: call failed: %v", tt.number, err)
+		}
+		if !bytes.Equal(ret, tt.want) {
+			t.Errorf("block %d: output mismatch: have %x, want %x", tt.number, ret, tt.want)
+		}
+	}
+	// Unknown names and clashing addresses must be rejected
+	config.Precompiles = []*params.PrecompileConfig{{Name: "sha1024", Address: addr, Block: new(big.Int)}}
+	if err := vm.CheckPrecompiles(config); err == nil {
+		t.Errorf("unknown precompile accepted")
+	}
+	config.Precompiles = []*params.PrecompileConfig{{Name: "sha512", Address: common.BytesToAddress([]byte{2}), Block: new(big.Int)}}
+	if err := vm.CheckPrecompiles(config); err == nil {
+		t.Errorf("precompile at occupied address accepted")
+	}
+}
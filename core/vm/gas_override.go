@@ -0,0 +1,98 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// CheckGasOverrides verifies that the opcodes and precompiled contracts repriced
+// by a chain config exist and may be repriced. Opcodes expanding memory or
+// forwarding gas to other contracts can't have a flat cost and are rejected.
+func CheckGasOverrides(config *params.ChainConfig) error {
+	seen := make(map[string]bool)
+	for _, o := range config.GasOverrides {
+		if seen[o.Name] {
+			return fmt.Errorf("duplicate gas override %q", o.Name)
+		}
+		seen[o.Name] = true
+
+		for name := range o.Opcodes {
+			op := StringToOp(name)
+			if op == STOP && name != "STOP" {
+				return fmt.Errorf("gas override %q: unknown opcode %q", o.Name, name)
+			}
+			if operation := constantinopleInstructionSet[op]; operation.memorySize != nil {
+				return fmt.Errorf("gas override %q: opcode %s can't be repriced", o.Name, name)
+			}
+		}
+		for addr := range o.Precompiles {
+			if PrecompiledContractsByzantium[addr] == nil && !isExtensionPrecompile(config, addr) {
+				return fmt.Errorf("gas override %q: no precompiled contract at %x", o.Name, addr)
+			}
+		}
+	}
+	return nil
+}
+
+// isExtensionPrecompile returns whether the chain config installs an extension
+// precompiled contract at an address.
+func isExtensionPrecompile(config *params.ChainConfig, addr common.Address) bool {
+	for _, ext := range config.Precompiles {
+		if ext.Address == addr {
+			return true
+		}
+	}
+	return false
+}
+
+// overrideGas reprices the opcodes of an instruction set.
+func overrideGas(table *[256]operation, opcodes map[string]uint64) {
+	for name, gas := range opcodes {
+		op := StringToOp(name)
+		if !table[op].valid {
+			continue
+		}
+		table[op].gasCost = overriddenGasFunc(table[op].gasCost, gas)
+	}
+}
+
+// overriddenGasFunc returns a gas function charging a flat cost, yet running the
+// original one for its side effects, e.g. the refund of cleared storage.
+func overriddenGasFunc(gasCost gasFunc, gas uint64) gasFunc {
+	return func(gt params.GasTable, evm *EVM, contract *Contract, stack *Stack, mem *Memory, memorySize uint64) (uint64, error) {
+		if _, err := gasCost(gt, evm, contract, stack, mem, memorySize); err != nil {
+			return 0, err
+		}
+		return gas, nil
+	}
+}
+
+// repricedContract is a precompiled contract with an overridden flat cost.
+type repricedContract struct {
+	PrecompiledContract
+	gas uint64
+}
+
+// RequiredGas implements PrecompiledContract.RequiredGas, returning the flat
+// cost regardless of the input.
+func (c *repricedContract) RequiredGas(input []byte) uint64 {
+	return c.gas
+}
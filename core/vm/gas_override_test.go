@@ -0,0 +1,107 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// Tests that repriced opcodes and precompiled contracts are charged their
+// overridden cost once the override activates.
+func TestGasOverrides(t *testing.T) {
+	var (
+		contract = common.HexToAddress("0xc0de")
+		sha256   = common.BytesToAddress([]byte{2})
+		code     = []byte{byte(PUSH1), 1, byte(PUSH1), 0, byte(SSTORE)} // sstore(0, 1)
+	)
+	config := &params.ChainConfig{
+		ChainId:        big.NewInt(1),
+		HomesteadBlock: new(big.Int),
+		EIP150Block:    new(big.Int),
+		EIP155Block:    new(big.Int),
+		EIP158Block:    new(big.Int),
+		ByzantiumBlock: new(big.Int),
+		GasOverrides: []*params.GasOverrideConfig{{
+			Name:        "sstore",
+			Block:       big.NewInt(10),
+			Opcodes:     map[string]uint64{"SSTORE": 50000},
+			Precompiles: map[common.Address]uint64{sha256: 1000},
+		}},
+	}
+	if err := CheckGasOverrides(config); err != nil {
+		t.Fatalf("failed to check gas overrides: %v", err)
+	}
+	for _, tt := range []struct {
+		number      int64
+		sstore, sha uint64
+	}{
+		{9, 2*GasFastestStep + params.SstoreSetGas, params.Sha256BaseGas + params.Sha256PerWordGas},
+		{10, 2*GasFastestStep + 50000, 1000},
+	} {
+		db, _ := ethdb.NewMemDatabase()
+		statedb, _ := state.New(common.Hash{}, state.NewDatabase(db))
+		statedb.SetCode(contract, code)
+
+		ctx := Context{
+			CanTransfer: func(StateDB, common.Address, *big.Int) bool { return true },
+			Transfer:    func(StateDB, common.Address, common.Address, *big.Int) {},
+			BlockNumber: big.NewInt(tt.number),
+		}
+		evm := NewEVM(ctx, statedb, config, Config{})
+
+		_, left, err := evm.Call(AccountRef(common.Address{}), contract, nil, 100000, new(big.Int))
+		if err != nil {
+			t.Fatalf("block %d: sstore call failed: %v", tt.number, err)
+		}
+		if used := 100000 - left; used != tt.sstore {
+			t.Errorf("block %d: sstore gas mismatch: have %d, want %d", tt.number, used, tt.sstore)
+		}
+		_, left, err = evm.Call(AccountRef(common.Address{}), sha256, []byte("abc"), 100000, new(big.Int))
+		if err != nil {
+			t.Fatalf("block %d: sha256 call failed: %v", tt.number, err)
+		}
+		if used := 100000 - left; used != tt.sha {
+			t.Errorf("block %d: sha256 gas mismatch: have %d, want %d", tt.number, used, tt.sha)
+		}
+	}
+}
+
+// Tests that overrides of unknown or dynamically priced opcodes and of missing
+// precompiled contracts are rejected.
+func TestCheckGasOverrides(t *testing.T) {
+	for i, o := range []*params.GasOverrideConfig{
+		{Name: "unknown", Opcodes: map[string]uint64{"SSTORAGE": 1}},
+		{Name: "memory", Opcodes: map[string]uint64{"MSTORE": 1}},
+		{Name: "call", Opcodes: map[string]uint64{"CALL": 1}},
+		{Name: "precompile", Precompiles: map[common.Address]uint64{common.HexToAddress("0xc0de"): 1}},
+	} {
+		config := &params.ChainConfig{GasOverrides: []*params.GasOverrideConfig{o}}
+		if err := CheckGasOverrides(config); err == nil {
+			t.Errorf("test %d: invalid override %q accepted", i, o.Name)
+		}
+	}
+	config := &params.ChainConfig{GasOverrides: []*params.GasOverrideConfig{{Name: "dup"}, {Name: "dup"}}}
+	if err := CheckGasOverrides(config); err == nil {
+		t.Errorf("duplicate override names accepted")
+	}
+}
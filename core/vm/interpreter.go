@@ -76,6 +76,10 @@ func NewInterpreter(evm *EVM, cfg Config) *Interpreter {
 			cfg.JumpTable = frontierInstructionSet
 		}
 	}
+	// Reprice the opcodes overridden by the chain config
+	if opcodes, _ := evm.ChainConfig().ActiveGasOverrides(evm.BlockNumber); opcodes != nil {
+		overrideGas(&cfg.JumpTable, opcodes)
+	}
 
 	return &Interpreter{
 		evm:      evm,
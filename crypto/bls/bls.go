@@ -0,0 +1,228 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package bls implements BLS signatures over the bn256 curve, along with the
+// threshold signing and distributed key generation needed to share a signing
+// key among a group of parties without any of them ever knowing it.
+//
+// Signatures live in G1 and public keys in G2, keeping signatures small as they
+// are the ones stored and transmitted most often.
+package bls
+
+import (
+	"crypto/rand"
+	"errors"
+	"io"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/crypto/bn256/cloudflare"
+)
+
+const (
+	SecretKeyLength = 32  // Length of a marshalled secret key
+	PublicKeyLength = 128 // Length of a marshalled public key
+	SignatureLength = 64  // Length of a marshalled signature
+)
+
+var (
+	errInvalidSecretKey = errors.New("invalid BLS secret key")
+	errInvalidPublicKey = errors.New("invalid BLS public key")
+	errInvalidSignature = errors.New("invalid BLS signature")
+)
+
+var (
+	// sqrtExponent is (p+1)/4, raising a quadratic residue modulo p to which
+	// yields its square root as p = 3 mod 4.
+	sqrtExponent = new(big.Int).Rsh(new(big.Int).Add(bn256.P, big.NewInt(1)), 2)
+
+	// curveB is the constant of the G1 curve equation y^2 = x^3 + b.
+	curveB = big.NewInt(3)
+)
+
+// SecretKey is a BLS secret key, a scalar modulo the order of the groups.
+type SecretKey struct {
+	x *big.Int
+}
+
+// PublicKey is a BLS public key, the generator of G2 raised to the secret key.
+type PublicKey struct {
+	p *bn256.G2
+}
+
+// Signature is a BLS signature, the hash of the message raised to the secret key.
+type Signature struct {
+	p *bn256.G1
+}
+
+// GenerateKey creates a new random secret key.
+func GenerateKey(random io.Reader) (*SecretKey, error) {
+	if random == nil {
+		random = rand.Reader
+	}
+	x, err := randScalar(random)
+	if err != nil {
+		return nil, err
+	}
+	return &SecretKey{x: x}, nil
+}
+
+// randScalar returns a random non-zero scalar modulo the group order.
+func randScalar(random io.Reader) (*big.Int, error) {
+	for {
+		x, err := rand.Int(random, bn256.Order)
+		if err != nil {
+			return nil, err
+		}
+		if x.Sign() > 0 {
+			return x, nil
+		}
+	}
+}
+
+// Public returns the public key belonging to the secret key.
+func (k *SecretKey) Public() *PublicKey {
+	return &PublicKey{p: new(bn256.G2).ScalarBaseMult(k.x)}
+}
+
+// Sign creates a signature of the message.
+func (k *SecretKey) Sign(msg []byte) *Signature {
+	return &Signature{p: new(bn256.G1).ScalarMult(hashToG1(msg), k.x)}
+}
+
+// Marshal encodes the secret key into its fixed size big endian form.
+func (k *SecretKey) Marshal() []byte {
+	return math.PaddedBigBytes(k.x, SecretKeyLength)
+}
+
+// UnmarshalSecretKey decodes a secret key encoded by Marshal.
+func UnmarshalSecretKey(data []byte) (*SecretKey, error) {
+	if len(data) != SecretKeyLength {
+		return nil, errInvalidSecretKey
+	}
+	x := new(big.Int).SetBytes(data)
+	if x.Sign() == 0 || x.Cmp(bn256.Order) >= 0 {
+		return nil, errInvalidSecretKey
+	}
+	return &SecretKey{x: x}, nil
+}
+
+// Verify checks whether the signature was created over the message by the
+// secret key belonging to the public key.
+func (k *PublicKey) Verify(msg []byte, sig *Signature) bool {
+	// e(sig, g2) == e(H(msg), pk), checked as e(sig, g2) * e(-H(msg), pk) == 1
+	hash := new(bn256.G1).Neg(hashToG1(msg))
+	return bn256.PairingCheck([]*bn256.G1{sig.p, hash}, []*bn256.G2{g2(), k.p})
+}
+
+// Marshal encodes the public key into its uncompressed form.
+func (k *PublicKey) Marshal() []byte {
+	return k.p.Marshal()
+}
+
+// Equal returns whether the two public keys are the same.
+func (k *PublicKey) Equal(other *PublicKey) bool {
+	return string(k.Marshal()) == string(other.Marshal())
+}
+
+// UnmarshalPublicKey decodes a public key encoded by Marshal.
+func UnmarshalPublicKey(data []byte) (*PublicKey, error) {
+	if len(data) != PublicKeyLength {
+		return nil, errInvalidPublicKey
+	}
+	p := new(bn256.G2)
+	if _, err := p.Unmarshal(data); err != nil {
+		return nil, errInvalidPublicKey
+	}
+	return &PublicKey{p: p}, nil
+}
+
+// Marshal encodes the signature into its uncompressed form.
+func (s *Signature) Marshal() []byte {
+	return s.p.Marshal()
+}
+
+// UnmarshalSignature decodes a signature encoded by Marshal.
+func UnmarshalSignature(data []byte) (*Signature, error) {
+	if len(data) != SignatureLength {
+		return nil, errInvalidSignature
+	}
+	p := new(bn256.G1)
+	if _, err := p.Unmarshal(data); err != nil {
+		return nil, errInvalidSignature
+	}
+	return &Signature{p: p}, nil
+}
+
+// AggregateSignatures sums up signatures created by distinct keys over the same
+// message into one, verifying against the aggregate of the keys. The keys must
+// not be chosen by the signers after seeing the others' (e.g. the public shares
+// of a key generation), lest one cancel the others out.
+func AggregateSignatures(sigs []*Signature) *Signature {
+	sum := new(bn256.G1).ScalarBaseMult(new(big.Int))
+	for _, sig := range sigs {
+		sum.Add(sum, sig.p)
+	}
+	return &Signature{p: sum}
+}
+
+// AggregatePublicKeys sums up public keys into the one verifying the aggregate
+// of their signatures.
+func AggregatePublicKeys(keys []*PublicKey) *PublicKey {
+	sum := new(bn256.G2).ScalarBaseMult(new(big.Int))
+	for _, key := range keys {
+		sum.Add(sum, key.p)
+	}
+	return &PublicKey{p: sum}
+}
+
+// g2 returns the generator of G2.
+func g2() *bn256.G2 {
+	return new(bn256.G2).ScalarBaseMult(big.NewInt(1))
+}
+
+// hashToG1 maps a message onto a point of G1 by hashing it together with an
+// increasing counter until the result is the x coordinate of a curve point.
+// Every point of G1 is in its prime order subgroup, so no cofactor clearing is
+// needed.
+func hashToG1(msg []byte) *bn256.G1 {
+	counter := make([]byte, 4)
+	for i := uint32(0); ; i++ {
+		counter[0], counter[1], counter[2], counter[3] = byte(i>>24), byte(i>>16), byte(i>>8), byte(i)
+
+		x := new(big.Int).SetBytes(crypto.Keccak256(counter, msg))
+		x.Mod(x, bn256.P)
+
+		rhs := new(big.Int).Mul(x, x)
+		rhs.Mul(rhs, x)
+		rhs.Add(rhs, curveB)
+		rhs.Mod(rhs, bn256.P)
+
+		y := new(big.Int).Exp(rhs, sqrtExponent, bn256.P)
+		if new(big.Int).Exp(y, big.NewInt(2), bn256.P).Cmp(rhs) != 0 {
+			continue
+		}
+		buf := make([]byte, 64)
+		math.ReadBits(x, buf[:32])
+		math.ReadBits(y, buf[32:])
+
+		point := new(bn256.G1)
+		if _, err := point.Unmarshal(buf); err == nil {
+			return point
+		}
+	}
+}
@@ -0,0 +1,175 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package bls
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestSignVerify(t *testing.T) {
+	key, err := GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	msg := []byte("hello world")
+	sig := key.Sign(msg)
+
+	if !key.Public().Verify(msg, sig) {
+		t.Errorf("valid signature rejected")
+	}
+	if key.Public().Verify([]byte("hello there"), sig) {
+		t.Errorf("signature of another message accepted")
+	}
+	other, _ := GenerateKey(nil)
+	if other.Public().Verify(msg, sig) {
+		t.Errorf("signature accepted with the wrong key")
+	}
+}
+
+func TestMarshalling(t *testing.T) {
+	key, _ := GenerateKey(nil)
+	sig := key.Sign([]byte("hello world"))
+
+	dec, err := UnmarshalSecretKey(key.Marshal())
+	if err != nil || dec.x.Cmp(key.x) != 0 {
+		t.Errorf("secret key mismatch: have %v (err %v), want %v", dec, err, key.x)
+	}
+	pub, err := UnmarshalPublicKey(key.Public().Marshal())
+	if err != nil || !pub.Equal(key.Public()) {
+		t.Errorf("public key mismatch: err %v", err)
+	}
+	dsig, err := UnmarshalSignature(sig.Marshal())
+	if err != nil || !bytes.Equal(dsig.Marshal(), sig.Marshal()) {
+		t.Errorf("signature mismatch: err %v", err)
+	}
+	if !pub.Verify([]byte("hello world"), dsig) {
+		t.Errorf("decoded signature rejected")
+	}
+	if _, err := UnmarshalSignature(make([]byte, SignatureLength-1)); err == nil {
+		t.Errorf("short signature accepted")
+	}
+	bad := sig.Marshal()
+	bad[SignatureLength-1] ^= 0x01
+	if _, err := UnmarshalSignature(bad); err == nil {
+		t.Errorf("point off the curve accepted")
+	}
+}
+
+// Tests that aggregate signatures verify against the aggregate of exactly the
+// keys that signed.
+func TestAggregate(t *testing.T) {
+	msg := []byte("hello world")
+
+	var (
+		keys []*PublicKey
+		sigs []*Signature
+	)
+	for i := 0; i < 3; i++ {
+		key, _ := GenerateKey(nil)
+		keys = append(keys, key.Public())
+		sigs = append(sigs, key.Sign(msg))
+	}
+	sig := AggregateSignatures(sigs)
+	if !AggregatePublicKeys(keys).Verify(msg, sig) {
+		t.Errorf("valid aggregate signature rejected")
+	}
+	if AggregatePublicKeys(keys[:2]).Verify(msg, sig) {
+		t.Errorf("aggregate signature accepted with a missing key")
+	}
+	if AggregatePublicKeys(keys).Verify(msg, AggregateSignatures(sigs[:2])) {
+		t.Errorf("aggregate signature accepted with a missing signer")
+	}
+	if AggregatePublicKeys(keys).Verify([]byte("hello there"), sig) {
+		t.Errorf("aggregate signature of another message accepted")
+	}
+}
+
+// Tests that a group key generated by a full distributed key generation can be
+// signed with by any threshold of the parties, but not by less.
+func TestDistributedKeyGeneration(t *testing.T) {
+	const (
+		threshold = 3
+		parties   = 4
+	)
+	// Every party deals its secret polynomial to all the others
+	dealings := make([]*Dealing, parties)
+	for i := range dealings {
+		dealing, err := NewDealing(nil, threshold, parties)
+		if err != nil {
+			t.Fatalf("dealer %d: failed to deal: %v", i, err)
+		}
+		dealings[i] = dealing
+	}
+	// Every party verifies and combines the shares it received
+	commitments := make([][]*PublicKey, parties)
+	for i, dealing := range dealings {
+		commitments[i] = dealing.Commitments
+	}
+	group, err := CombineCommitments(commitments)
+	if err != nil {
+		t.Fatalf("failed to combine commitments: %v", err)
+	}
+	shares := make([]*SecretKey, parties)
+	for i := range shares {
+		received := make([]*SecretKey, parties)
+		for j, dealing := range dealings {
+			if !VerifyShare(dealing.Commitments, uint64(i+1), dealing.Shares[i]) {
+				t.Fatalf("party %d: valid share from dealer %d rejected", i, j)
+			}
+			received[j] = dealing.Shares[i]
+		}
+		shares[i] = CombineShares(received)
+		if !shares[i].Public().Equal(PublicShare(group, uint64(i+1))) {
+			t.Fatalf("party %d: share doesn't match its public share", i)
+		}
+	}
+	// Any threshold of partial signatures must combine into the group signature
+	msg := []byte("hello world")
+
+	partials := make([]*PartialSignature, parties)
+	for i, share := range shares {
+		partials[i] = &PartialSignature{Index: uint64(i + 1), Signature: share.Sign(msg)}
+		if !PublicShare(group, uint64(i+1)).Verify(msg, partials[i].Signature) {
+			t.Fatalf("party %d: partial signature rejected", i)
+		}
+	}
+	first, err := Combine(partials[:threshold], threshold)
+	if err != nil {
+		t.Fatalf("failed to combine partial signatures: %v", err)
+	}
+	if !group[0].Verify(msg, first) {
+		t.Fatalf("combined signature rejected by the group key")
+	}
+	last, err := Combine(partials[parties-threshold:], threshold)
+	if err != nil {
+		t.Fatalf("failed to combine partial signatures: %v", err)
+	}
+	if !bytes.Equal(first.Marshal(), last.Marshal()) {
+		t.Errorf("different signer subsets yield different signatures")
+	}
+	if _, err := Combine(partials[:threshold-1], threshold); err != errTooFewPartials {
+		t.Errorf("combining too few partials: have %v, want %v", err, errTooFewPartials)
+	}
+	if _, err := Combine([]*PartialSignature{partials[0], partials[0], partials[1]}, threshold); err != errDuplicatePartial {
+		t.Errorf("combining duplicate partials: have %v, want %v", err, errDuplicatePartial)
+	}
+	// A tampered share must be caught against the dealer's commitments
+	if VerifyShare(dealings[0].Commitments, 1, dealings[0].Shares[1]) {
+		t.Errorf("share of another party accepted")
+	}
+}
@@ -0,0 +1,133 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package bls
+
+import (
+	"crypto/rand"
+	"errors"
+	"io"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/crypto/bn256/cloudflare"
+)
+
+var errInvalidThreshold = errors.New("invalid threshold")
+
+// Dealing is a single party's contribution to a distributed key generation
+// (joint Feldman verifiable secret sharing). The dealer picks a random secret
+// polynomial of degree threshold-1, publishes commitments to its coefficients
+// and hands every party the value of the polynomial at the party's index.
+//
+// The group secret key is the sum of the secrets of all qualified dealers; no
+// party ever learns it, but each one ends up holding a share of it.
+type Dealing struct {
+	Commitments []*PublicKey // Coefficients of the secret polynomial raised into G2
+	Shares      []*SecretKey // Value of the polynomial at the index of each party (Shares[i] for index i+1)
+}
+
+// NewDealing creates a dealing for a group of the given size, any threshold of
+// which can sign for the group.
+func NewDealing(random io.Reader, threshold, parties int) (*Dealing, error) {
+	if threshold <= 0 || threshold > parties {
+		return nil, errInvalidThreshold
+	}
+	if random == nil {
+		random = rand.Reader
+	}
+	coeffs := make([]*big.Int, threshold)
+	for i := range coeffs {
+		x, err := randScalar(random)
+		if err != nil {
+			return nil, err
+		}
+		coeffs[i] = x
+	}
+	dealing := &Dealing{
+		Commitments: make([]*PublicKey, threshold),
+		Shares:      make([]*SecretKey, parties),
+	}
+	for i, coeff := range coeffs {
+		dealing.Commitments[i] = &PublicKey{p: new(bn256.G2).ScalarBaseMult(coeff)}
+	}
+	for i := range dealing.Shares {
+		// Evaluate the polynomial at i+1 using Horner's method
+		x, y := big.NewInt(int64(i+1)), new(big.Int)
+		for j := len(coeffs) - 1; j >= 0; j-- {
+			y.Mul(y, x)
+			y.Add(y, coeffs[j])
+			y.Mod(y, bn256.Order)
+		}
+		dealing.Shares[i] = &SecretKey{x: y}
+	}
+	return dealing, nil
+}
+
+// VerifyShare checks whether a share handed out by a dealer is consistent with
+// the commitments it published.
+func VerifyShare(commitments []*PublicKey, index uint64, share *SecretKey) bool {
+	if index == 0 || len(commitments) == 0 {
+		return false
+	}
+	return share.Public().Equal(PublicShare(commitments, index))
+}
+
+// PublicShare evaluates the committed polynomial at the given index in the
+// exponent, yielding the public key of the share of the party with the index.
+func PublicShare(commitments []*PublicKey, index uint64) *PublicKey {
+	var (
+		x   = new(big.Int).SetUint64(index)
+		pow = big.NewInt(1)
+		sum = new(bn256.G2).ScalarBaseMult(new(big.Int))
+	)
+	for _, commitment := range commitments {
+		sum.Add(sum, new(bn256.G2).ScalarMult(commitment.p, pow))
+		pow = new(big.Int).Mod(new(big.Int).Mul(pow, x), bn256.Order)
+	}
+	return &PublicKey{p: sum}
+}
+
+// CombineCommitments sums the commitments of the qualified dealers, yielding the
+// commitments to the polynomial sharing the group secret key. Its first element
+// is the group public key.
+func CombineCommitments(commitments [][]*PublicKey) ([]*PublicKey, error) {
+	if len(commitments) == 0 {
+		return nil, errInvalidThreshold
+	}
+	combined := make([]*PublicKey, len(commitments[0]))
+	for i := range combined {
+		combined[i] = &PublicKey{p: new(bn256.G2).ScalarBaseMult(new(big.Int))}
+	}
+	for _, dealer := range commitments {
+		if len(dealer) != len(combined) {
+			return nil, errInvalidThreshold
+		}
+		for i, commitment := range dealer {
+			combined[i].p.Add(combined[i].p, commitment.p)
+		}
+	}
+	return combined, nil
+}
+
+// CombineShares sums the shares a party received from the qualified dealers,
+// yielding its share of the group secret key.
+func CombineShares(shares []*SecretKey) *SecretKey {
+	sum := new(big.Int)
+	for _, share := range shares {
+		sum.Add(sum, share.x)
+	}
+	return &SecretKey{x: sum.Mod(sum, bn256.Order)}
+}
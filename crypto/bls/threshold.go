@@ -0,0 +1,91 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package bls
+
+import (
+	"errors"
+	"math/big"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/crypto/bn256/cloudflare"
+)
+
+var (
+	errTooFewPartials   = errors.New("not enough partial signatures")
+	errDuplicatePartial = errors.New("duplicate partial signature")
+	errInvalidIndex     = errors.New("invalid share index")
+)
+
+// PartialSignature is a signature created with a share of a group secret key.
+// Any threshold of them, created by distinct shares over the same message,
+// combine into the signature of the group key.
+type PartialSignature struct {
+	Index     uint64     // Index of the share the signature was created with (starting from 1)
+	Signature *Signature // Signature created with the share
+}
+
+// Combine interpolates the group signature out of the partial signatures,
+// using the first threshold of them in the order of their share indices. The
+// partial signatures are expected to have been verified against the public
+// shares of their signers.
+func Combine(partials []*PartialSignature, threshold int) (*Signature, error) {
+	if threshold <= 0 || len(partials) < threshold {
+		return nil, errTooFewPartials
+	}
+	sorted := make([]*PartialSignature, len(partials))
+	copy(sorted, partials)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Index < sorted[j].Index })
+	sorted = sorted[:threshold]
+
+	indices := make([]uint64, len(sorted))
+	for i, partial := range sorted {
+		if partial.Index == 0 {
+			return nil, errInvalidIndex
+		}
+		if i > 0 && sorted[i-1].Index == partial.Index {
+			return nil, errDuplicatePartial
+		}
+		indices[i] = partial.Index
+	}
+	sig := new(bn256.G1).ScalarBaseMult(new(big.Int))
+	for i, partial := range sorted {
+		sig.Add(sig, new(bn256.G1).ScalarMult(partial.Signature.p, lagrange(indices, i)))
+	}
+	return &Signature{p: sig}, nil
+}
+
+// lagrange computes the Lagrange coefficient of the i-th index for
+// interpolating the polynomial through the given indices at zero.
+func lagrange(indices []uint64, i int) *big.Int {
+	var (
+		num = big.NewInt(1)
+		den = big.NewInt(1)
+		xi  = new(big.Int).SetUint64(indices[i])
+	)
+	for j, index := range indices {
+		if j == i {
+			continue
+		}
+		xj := new(big.Int).SetUint64(index)
+		num.Mul(num, xj)
+		num.Mod(num, bn256.Order)
+
+		den.Mul(den, new(big.Int).Sub(xj, xi))
+		den.Mod(den, bn256.Order)
+	}
+	return num.Mul(num, den.ModInverse(den, bn256.Order)).Mod(num, bn256.Order)
+}
@@ -268,7 +268,7 @@ func (db *Dashboard) collectData() {
 
 		prevNetworkIngress = metrics.DefaultRegistry.Get("p2p/InboundTraffic").(metrics.Meter).Count()
 		prevNetworkEgress  = metrics.DefaultRegistry.Get("p2p/OutboundTraffic").(metrics.Meter).Count()
-		prevProcessCPUTime = getProcessCPUTime()
+		prevProcessCPUTime = metrics.ProcessCPUTime()
 		prevSystemCPUUsage = systemCPUUsage
 		prevDiskRead       = metrics.DefaultRegistry.Get("eth/db/chaindata/disk/read").(metrics.Meter).Count()
 		prevDiskWrite      = metrics.DefaultRegistry.Get("eth/db/chaindata/disk/write").(metrics.Meter).Count()
@@ -287,7 +287,7 @@ func (db *Dashboard) collectData() {
 			var (
 				curNetworkIngress = metrics.DefaultRegistry.Get("p2p/InboundTraffic").(metrics.Meter).Count()
 				curNetworkEgress  = metrics.DefaultRegistry.Get("p2p/OutboundTraffic").(metrics.Meter).Count()
-				curProcessCPUTime = getProcessCPUTime()
+				curProcessCPUTime = metrics.ProcessCPUTime()
 				curSystemCPUUsage = systemCPUUsage
 				curDiskRead       = metrics.DefaultRegistry.Get("eth/db/chaindata/disk/read").(metrics.Meter).Count()
 				curDiskWrite      = metrics.DefaultRegistry.Get("eth/db/chaindata/disk/write").(metrics.Meter).Count()
@@ -0,0 +1,307 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package devnet runs networks of in-process Istanbul validators for integration
+// testing.
+//
+// The nodes of a devnet keep their databases in memory and are connected by
+// in-memory pipes instead of sockets, so whole networks can be run within a
+// single test binary, without touching the disk or the network.
+package devnet
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/istanbul"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/eth"
+	"github.com/ethereum/go-ethereum/eth/downloader"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/node"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/discover"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// Config contains the settings of a devnet.
+type Config struct {
+	Nodes       int                 // Number of validator nodes to run
+	ChainConfig *params.ChainConfig // Chain rules of the network (nil = all forks active at genesis)
+	Alloc       core.GenesisAlloc   // Initial account balances of the network
+	GasLimit    uint64              // Gas limit of the genesis block (0 = params.GenesisGasLimit)
+	Istanbul    istanbul.Config     // Consensus engine settings of the validators
+}
+
+// DefaultConfig contains the default settings of a devnet, running four
+// validators producing a block every second.
+var DefaultConfig = Config{
+	Nodes:    4,
+	Istanbul: *istanbul.DefaultConfig,
+}
+
+// Devnet is a network of in-process Istanbul validators.
+type Devnet struct {
+	config  Config
+	genesis *core.Genesis
+	nodes   []*Node
+}
+
+// Node is a single validator of a devnet.
+type Node struct {
+	Stack   *node.Node        // Protocol stack of the validator
+	Key     *ecdsa.PrivateKey // Node key the validator signs blocks with
+	Address common.Address    // Validator address derived from the node key
+
+	ethereum *eth.Ethereum
+	client   *rpc.Client
+}
+
+// New creates a devnet with the given configuration, generating the validator
+// keys and the genesis block. The nodes are not started until Start is called.
+func New(config Config) (*Devnet, error) {
+	if config.Nodes < 1 {
+		return nil, errors.New("devnet needs at least one node")
+	}
+	d := &Devnet{config: config}
+
+	// Generate the validators and the genesis containing them
+	validators := make([]common.Address, config.Nodes)
+	for i := range validators {
+		key, err := crypto.GenerateKey()
+		if err != nil {
+			return nil, err
+		}
+		validators[i] = crypto.PubkeyToAddress(key.PublicKey)
+		d.nodes = append(d.nodes, &Node{Key: key, Address: validators[i]})
+	}
+	genesis, err := d.makeGenesis(validators)
+	if err != nil {
+		return nil, err
+	}
+	d.genesis = genesis
+
+	// Assemble the protocol stacks, dialing each other through the devnet
+	for i, n := range d.nodes {
+		stack, err := node.New(&node.Config{
+			Name: fmt.Sprintf("devnet-%d", i),
+			P2P: p2p.Config{
+				PrivateKey:  n.Key,
+				MaxPeers:    config.Nodes,
+				NoDiscovery: true,
+				Dialer:      d,
+			},
+			NoUSB:  true,
+			Logger: log.New("devnet", i),
+		})
+		if err != nil {
+			return nil, err
+		}
+		ethConfig := eth.DefaultConfig
+		ethConfig.NetworkId = genesis.Config.ChainId.Uint64()
+		ethConfig.SyncMode = downloader.FullSync
+		ethConfig.Genesis = genesis
+		ethConfig.Istanbul = config.Istanbul
+
+		if err := stack.Register(func(ctx *node.ServiceContext) (node.Service, error) {
+			return eth.New(ctx, &ethConfig)
+		}); err != nil {
+			return nil, err
+		}
+		n.Stack = stack
+	}
+	return d, nil
+}
+
+// makeGenesis assembles the genesis block with the given initial validators.
+func (d *Devnet) makeGenesis(validators []common.Address) (*core.Genesis, error) {
+	extra, err := types.IstanbulGenesisExtra(nil, validators)
+	if err != nil {
+		return nil, err
+	}
+	config := d.config.ChainConfig
+	if config == nil {
+		config = &params.ChainConfig{
+			ChainId:        big.NewInt(1337),
+			HomesteadBlock: big.NewInt(0),
+			EIP150Block:    big.NewInt(0),
+			EIP155Block:    big.NewInt(0),
+			EIP158Block:    big.NewInt(0),
+			ByzantiumBlock: big.NewInt(0),
+		}
+	}
+	// Enforce the Istanbul engine, matching the settings of the validators
+	chainConfig := *config
+	chainConfig.Ethash, chainConfig.Clique = nil, nil
+	if chainConfig.Istanbul == nil {
+		chainConfig.Istanbul = &params.IstanbulConfig{
+			Epoch:          d.config.Istanbul.Epoch,
+			ProposerPolicy: uint64(d.config.Istanbul.ProposerPolicy),
+		}
+	}
+	gasLimit := d.config.GasLimit
+	if gasLimit == 0 {
+		gasLimit = params.GenesisGasLimit
+	}
+	return &core.Genesis{
+		Config:     &chainConfig,
+		Timestamp:  uint64(time.Now().Unix()),
+		ExtraData:  extra,
+		GasLimit:   gasLimit,
+		Difficulty: big.NewInt(1),
+		Mixhash:    types.IstanbulDigest,
+		Alloc:      d.config.Alloc,
+	}, nil
+}
+
+// Genesis returns the genesis block specification of the devnet.
+func (d *Devnet) Genesis() *core.Genesis {
+	return d.genesis
+}
+
+// Nodes returns the validators of the devnet.
+func (d *Devnet) Nodes() []*Node {
+	return d.nodes
+}
+
+// Start boots all validators, connects each of them to all others and starts
+// sealing blocks.
+func (d *Devnet) Start() error {
+	for i, n := range d.nodes {
+		if err := n.Stack.Start(); err != nil {
+			d.Stop()
+			return fmt.Errorf("node %d: %v", i, err)
+		}
+		if err := n.Stack.Service(&n.ethereum); err != nil {
+			d.Stop()
+			return fmt.Errorf("node %d: %v", i, err)
+		}
+		client, err := n.Stack.Attach()
+		if err != nil {
+			d.Stop()
+			return fmt.Errorf("node %d: %v", i, err)
+		}
+		n.client = client
+	}
+	for i, n := range d.nodes {
+		for _, peer := range d.nodes[i+1:] {
+			n.Stack.Server().AddPeer(peer.enode())
+		}
+	}
+	for i, n := range d.nodes {
+		if err := n.ethereum.StartMining(true); err != nil {
+			d.Stop()
+			return fmt.Errorf("node %d: %v", i, err)
+		}
+	}
+	return nil
+}
+
+// Stop terminates all running validators of the devnet.
+func (d *Devnet) Stop() {
+	for i, n := range d.nodes {
+		if n.client != nil {
+			n.client.Close()
+			n.client = nil
+		}
+		if err := n.Stack.Stop(); err != nil && err != node.ErrNodeStopped {
+			log.Warn("Failed to stop devnet node", "node", i, "err", err)
+		}
+		n.ethereum = nil
+	}
+}
+
+// WaitBlock waits until every validator imported the block with the given
+// number, returning its hash. An error is returned if the validators disagree
+// on the block or the context is cancelled first.
+func (d *Devnet) WaitBlock(ctx context.Context, number uint64) (common.Hash, error) {
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		var (
+			hash  common.Hash
+			ready = true
+		)
+		for i, n := range d.nodes {
+			if n.ethereum == nil {
+				return common.Hash{}, fmt.Errorf("node %d not running", i)
+			}
+			block := n.ethereum.BlockChain().GetBlockByNumber(number)
+			if block == nil {
+				ready = false
+				break
+			}
+			if i > 0 && block.Hash() != hash {
+				return common.Hash{}, fmt.Errorf("block %d mismatch: node 0 has %x, node %d has %x", number, hash, i, block.Hash())
+			}
+			hash = block.Hash()
+		}
+		if ready {
+			return hash, nil
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return common.Hash{}, ctx.Err()
+		}
+	}
+}
+
+// Dial implements p2p.NodeDialer, connecting to a validator of the devnet by an
+// in-memory pipe.
+func (d *Devnet) Dial(dest *discover.Node) (net.Conn, error) {
+	for _, n := range d.nodes {
+		if n.enode().ID != dest.ID {
+			continue
+		}
+		srv := n.Stack.Server()
+		if srv == nil {
+			return nil, fmt.Errorf("node not running: %s", dest.ID)
+		}
+		local, remote := net.Pipe()
+		go srv.SetupConn(local, 0, nil)
+		return remote, nil
+	}
+	return nil, fmt.Errorf("unknown node: %s", dest.ID)
+}
+
+// Client returns an in-process RPC client of the validator, available while the
+// devnet is running.
+func (n *Node) Client() *rpc.Client {
+	return n.client
+}
+
+// Ethereum returns the Ethereum service of the validator, available while the
+// devnet is running.
+func (n *Node) Ethereum() *eth.Ethereum {
+	return n.ethereum
+}
+
+// enode returns the discovery record of the validator. As nodes are dialed by
+// ID only, the address is a placeholder.
+func (n *Node) enode() *discover.Node {
+	return discover.NewNode(discover.PubkeyID(&n.Key.PublicKey), net.IP{127, 0, 0, 1}, 30303, 30303)
+}
@@ -0,0 +1,206 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package devnet
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/eth"
+	"github.com/ethereum/go-ethereum/eth/downloader"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/node"
+	"github.com/ethereum/go-ethereum/p2p"
+)
+
+// Tests that a devnet produces blocks agreed upon by all validators, including
+// the transactions submitted over RPC.
+func TestDevnet(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	sender := crypto.PubkeyToAddress(key.PublicKey)
+
+	config := DefaultConfig
+	config.Alloc = core.GenesisAlloc{sender: {Balance: big.NewInt(1000000000000000000)}}
+
+	net, err := New(config)
+	if err != nil {
+		t.Fatalf("failed to create devnet: %v", err)
+	}
+	if err := net.Start(); err != nil {
+		t.Fatalf("failed to start devnet: %v", err)
+	}
+	defer net.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	if _, err := net.WaitBlock(ctx, 1); err != nil {
+		t.Fatalf("failed to produce block: %v", err)
+	}
+	// Submit a transfer through one validator and check it gets sealed
+	client := ethclient.NewClient(net.Nodes()[1].Client())
+	signer := types.NewEIP155Signer(net.Genesis().Config.ChainId)
+	tx, _ := types.SignTx(types.NewTransaction(0, common.Address{0x01}, big.NewInt(1), 21000, big.NewInt(1), nil), signer, key)
+	if err := client.SendTransaction(ctx, tx); err != nil {
+		t.Fatalf("failed to send transaction: %v", err)
+	}
+	for {
+		if _, err := client.TransactionReceipt(ctx, tx.Hash()); err == nil {
+			break
+		}
+		select {
+		case <-time.After(100 * time.Millisecond):
+		case <-ctx.Done():
+			t.Fatalf("transaction not sealed: %v", ctx.Err())
+		}
+	}
+	head, err := client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		t.Fatalf("failed to retrieve head: %v", err)
+	}
+	if _, err := net.WaitBlock(ctx, head.Number.Uint64()); err != nil {
+		t.Fatalf("failed to agree on block: %v", err)
+	}
+	for i, n := range net.Nodes() {
+		balance, err := ethclient.NewClient(n.Client()).BalanceAt(ctx, common.Address{0x01}, nil)
+		if err != nil {
+			t.Fatalf("node %d: failed to retrieve balance: %v", i, err)
+		}
+		if balance.Cmp(big.NewInt(1)) != 0 {
+			t.Errorf("node %d: balance mismatch: have %v, want 1", i, balance)
+		}
+	}
+}
+
+// Tests that a node hosting two Istanbul tenant chains seals both of them under
+// the tenant validator keys, and that another node hosting the same tenants syncs
+// each chain over the p2p server of its tenant.
+func TestTenants(t *testing.T) {
+	tenants := []string{"alpha", "beta"}
+
+	// Generate a single validator chain for each tenant
+	var (
+		keys     = make(map[string]*ecdsa.PrivateKey)
+		genesis  = make(map[string]*core.Genesis)
+		template = &Devnet{config: DefaultConfig}
+	)
+	for _, tenant := range tenants {
+		key, _ := crypto.GenerateKey()
+		gspec, err := template.makeGenesis([]common.Address{crypto.PubkeyToAddress(key.PublicKey)})
+		if err != nil {
+			t.Fatalf("tenant %s: failed to create genesis: %v", tenant, err)
+		}
+		keys[tenant], genesis[tenant] = key, gspec
+	}
+	// Assemble a validating and a syncing node, both hosting the two tenants
+	newStack := func(name string, validator bool) *node.Node {
+		config := &node.Config{
+			Name:    name,
+			P2P:     p2p.Config{MaxPeers: 1, NoDiscovery: true},
+			NoUSB:   true,
+			Tenants: make(map[string]node.TenantConfig),
+			Logger:  log.New("node", name),
+		}
+		for _, tenant := range tenants {
+			tenantConfig := node.TenantConfig{ListenAddr: "127.0.0.1:0"}
+			if validator {
+				tenantConfig.PrivateKey = keys[tenant]
+			}
+			config.Tenants[tenant] = tenantConfig
+		}
+		stack, err := node.New(config)
+		if err != nil {
+			t.Fatalf("%s: failed to create protocol stack: %v", name, err)
+		}
+		for _, tenant := range tenants {
+			ethConfig := eth.DefaultConfig
+			ethConfig.NetworkId = genesis[tenant].Config.ChainId.Uint64()
+			ethConfig.SyncMode = downloader.FullSync
+			ethConfig.Genesis = genesis[tenant]
+			ethConfig.Istanbul = DefaultConfig.Istanbul
+
+			if err := stack.RegisterTenant(tenant, func(ctx *node.ServiceContext) (node.Service, error) {
+				return eth.New(ctx, &ethConfig)
+			}); err != nil {
+				t.Fatalf("%s: failed to register tenant %s: %v", name, tenant, err)
+			}
+		}
+		if err := stack.Start(); err != nil {
+			t.Fatalf("%s: failed to start protocol stack: %v", name, err)
+		}
+		return stack
+	}
+	sealer := newStack("sealer", true)
+	defer sealer.Stop()
+	syncer := newStack("syncer", false)
+	defer syncer.Stop()
+
+	services := func(stack *node.Node) map[string]*eth.Ethereum {
+		services := make(map[string]*eth.Ethereum)
+		for _, tenant := range tenants {
+			var ethereum *eth.Ethereum
+			if err := stack.TenantService(tenant, &ethereum); err != nil {
+				t.Fatalf("tenant %s: failed to retrieve service: %v", tenant, err)
+			}
+			services[tenant] = ethereum
+		}
+		return services
+	}
+	sealers, syncers := services(sealer), services(syncer)
+	for _, tenant := range tenants {
+		if err := sealers[tenant].StartMining(true); err != nil {
+			t.Fatalf("tenant %s: failed to start sealing: %v", tenant, err)
+		}
+		syncer.TenantServer(tenant).AddPeer(sealer.TenantServer(tenant).Self())
+	}
+	// Wait until the syncer imported the sealed blocks of both tenants
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	const number = 2
+	for _, tenant := range tenants {
+		for {
+			sealed := sealers[tenant].BlockChain().GetBlockByNumber(number)
+			synced := syncers[tenant].BlockChain().GetBlockByNumber(number)
+			if sealed != nil && synced != nil {
+				if sealed.Hash() != synced.Hash() {
+					t.Fatalf("tenant %s: block %d mismatch: sealed %x, synced %x", tenant, number, sealed.Hash(), synced.Hash())
+				}
+				if signer, err := sealers[tenant].Engine().Author(synced.Header()); err != nil || signer != crypto.PubkeyToAddress(keys[tenant].PublicKey) {
+					t.Fatalf("tenant %s: block signer mismatch: have %x (%v), want %x", tenant, signer, err, crypto.PubkeyToAddress(keys[tenant].PublicKey))
+				}
+				break
+			}
+			select {
+			case <-time.After(100 * time.Millisecond):
+			case <-ctx.Done():
+				t.Fatalf("tenant %s: block %d not synced: %v", tenant, number, ctx.Err())
+			}
+		}
+	}
+	if syncers["alpha"].BlockChain().Genesis().Hash() == syncers["beta"].BlockChain().Genesis().Hash() {
+		t.Errorf("tenant chains not isolated")
+	}
+}
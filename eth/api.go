@@ -19,17 +19,22 @@ package eth
 import (
 	"compress/gzip"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"math/big"
 	"os"
+	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/consensus"
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/internal/doctor"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/miner"
 	"github.com/ethereum/go-ethereum/params"
@@ -291,6 +296,69 @@ func (api *PrivateAdminAPI) ImportChain(file string) (bool, error) {
 	return true, nil
 }
 
+// ForkStatus is the activation status of a scheduled chain fork.
+type ForkStatus struct {
+	Name      string         `json:"name"`
+	Block     hexutil.Uint64 `json:"block"`
+	Active    bool           `json:"active"`
+	Remaining hexutil.Uint64 `json:"remaining,omitempty"` // Blocks left until activation
+}
+
+// ForkStatus reports the forks scheduled in the chain config, whether they are
+// active at the current head, and how many blocks are left until the upcoming
+// ones activate.
+func (api *PrivateAdminAPI) ForkStatus() []*ForkStatus {
+	head := api.eth.BlockChain().CurrentBlock().Number()
+
+	forks := api.eth.BlockChain().Config().Schedule()
+	statuses := make([]*ForkStatus, len(forks))
+	for i, fork := range forks {
+		statuses[i] = &ForkStatus{
+			Name:   fork.Name,
+			Block:  hexutil.Uint64(fork.Block.Uint64()),
+			Active: fork.Block.Cmp(head) <= 0,
+		}
+		if !statuses[i].Active {
+			statuses[i].Remaining = hexutil.Uint64(fork.Block.Uint64() - head.Uint64())
+		}
+	}
+	return statuses
+}
+
+// Doctor checks the setup of the running node for common misconfigurations,
+// reporting the steps to fix each problem found (see geth doctor).
+func (api *PrivateAdminAPI) Doctor() []*doctor.Result {
+	eth := api.eth
+	config := &doctor.Config{
+		InstanceDir:   eth.instanceDir,
+		ChainDB:       eth.chainDb,
+		Genesis:       eth.config.Genesis,
+		NTPServers:    eth.config.Istanbul.NTPServers,
+		MaxClockDrift: time.Duration(eth.config.Istanbul.MaxClockDrift) * time.Millisecond,
+	}
+	if eth.instanceDir != "" {
+		config.DataDir = filepath.Dir(eth.instanceDir)
+	}
+	if srv := eth.p2pServer; srv != nil {
+		config.Self = srv.Self()
+		config.NodeKey = srv.PrivateKey
+		if !srv.NoDiscovery {
+			config.NodeDatabase = srv.NodeDatabase
+		}
+	}
+	if engine, ok := eth.engine.(consensus.Istanbul); ok {
+		validators, err := engine.GetValidatorsAt(eth.blockchain.CurrentBlock().NumberU64())
+		if err != nil {
+			log.Warn("Failed to retrieve validators", "err", err)
+		}
+		if validators == nil {
+			validators = []common.Address{}
+		}
+		config.Validators = validators
+	}
+	return doctor.Run(config)
+}
+
 // PublicDebugAPI is the collection of Ethereum full node APIs exposed
 // over the public debugging endpoint.
 type PublicDebugAPI struct {
@@ -305,12 +373,36 @@ func NewPublicDebugAPI(eth *Ethereum) *PublicDebugAPI {
 
 // DumpBlock retrieves the entire state of the database at a given block.
 func (api *PublicDebugAPI) DumpBlock(blockNr rpc.BlockNumber) (state.Dump, error) {
+	stateDb, err := api.stateAt(blockNr)
+	if err != nil {
+		return state.Dump{}, err
+	}
+	return stateDb.RawDump(), nil
+}
+
+// DumpState retrieves the state of the database at a given block with the
+// accounts in canonical order, so that dumps of different nodes can be compared
+// directly. Large states can be dumped in pages by limiting the number of
+// accounts and continuing at the returned next key.
+func (api *PublicDebugAPI) DumpState(blockNr rpc.BlockNumber, opts *state.DumpOptions) (state.OrderedDump, error) {
+	stateDb, err := api.stateAt(blockNr)
+	if err != nil {
+		return state.OrderedDump{}, err
+	}
+	if opts == nil {
+		opts = new(state.DumpOptions)
+	}
+	return stateDb.OrderedDump(*opts)
+}
+
+// stateAt retrieves the state of the database at a given block.
+func (api *PublicDebugAPI) stateAt(blockNr rpc.BlockNumber) (*state.StateDB, error) {
 	if blockNr == rpc.PendingBlockNumber {
 		// If we're dumping the pending state, we need to request
 		// both the pending block as well as the pending state from
 		// the miner and operate on those
 		_, stateDb := api.eth.miner.Pending()
-		return stateDb.RawDump(), nil
+		return stateDb, nil
 	}
 	var block *types.Block
 	if blockNr == rpc.LatestBlockNumber {
@@ -319,13 +411,9 @@ func (api *PublicDebugAPI) DumpBlock(blockNr rpc.BlockNumber) (state.Dump, error
 		block = api.eth.blockchain.GetBlockByNumber(uint64(blockNr))
 	}
 	if block == nil {
-		return state.Dump{}, fmt.Errorf("block #%d not found", blockNr)
+		return nil, fmt.Errorf("block #%d not found", blockNr)
 	}
-	stateDb, err := api.eth.BlockChain().StateAt(block.Root())
-	if err != nil {
-		return state.Dump{}, err
-	}
-	return stateDb.RawDump(), nil
+	return api.eth.BlockChain().StateAt(block.Root())
 }
 
 // PrivateDebugAPI is the collection of Ethereum full node APIs exposed over
@@ -353,6 +441,18 @@ func (api *PrivateDebugAPI) GetBadBlocks(ctx context.Context) ([]core.BadBlockAr
 	return api.eth.BlockChain().BadBlocks()
 }
 
+// BadBlocks retrieves the forensic reports of all the bad blocks encountered,
+// including the offending block, its receipts and the local consensus state.
+func (api *PrivateDebugAPI) BadBlocks(ctx context.Context) ([]*core.BadBlockReport, error) {
+	return api.eth.BlockChain().BadBlockReports()
+}
+
+// FinalityConflict returns the two conflicting branches if a reorg of a finalized
+// block was attempted and block import halted, or nil otherwise.
+func (api *PrivateDebugAPI) FinalityConflict() *core.FinalityConflict {
+	return api.eth.BlockChain().FinalityConflict()
+}
+
 // StorageRangeResult is the result of a debug_storageRangeAt API call.
 type StorageRangeResult struct {
 	Storage storageMap   `json:"storage"`
@@ -457,6 +557,38 @@ func (api *PrivateDebugAPI) GetModifiedAccountsByHash(startHash common.Hash, end
 	return api.getModifiedAccounts(startBlock, endBlock)
 }
 
+// DiffState returns the accounts and storage slots which differ between the
+// states at two blocks, along with their values in both, in canonical order.
+func (api *PrivateDebugAPI) DiffState(blockA, blockB rpc.BlockNumber) (*state.StateDiff, error) {
+	from, err := api.blockByNumber(blockA)
+	if err != nil {
+		return nil, err
+	}
+	to, err := api.blockByNumber(blockB)
+	if err != nil {
+		return nil, err
+	}
+	return state.Diff(api.eth.blockchain.StateCache(), from.Root(), to.Root())
+}
+
+// blockByNumber retrieves a canonical block, the pending block not being
+// supported.
+func (api *PrivateDebugAPI) blockByNumber(blockNr rpc.BlockNumber) (*types.Block, error) {
+	var block *types.Block
+	switch blockNr {
+	case rpc.PendingBlockNumber:
+		return nil, errors.New("pending state not supported")
+	case rpc.LatestBlockNumber:
+		block = api.eth.blockchain.CurrentBlock()
+	default:
+		block = api.eth.blockchain.GetBlockByNumber(uint64(blockNr))
+	}
+	if block == nil {
+		return nil, fmt.Errorf("block #%d not found", blockNr)
+	}
+	return block, nil
+}
+
 func (api *PrivateDebugAPI) getModifiedAccounts(startBlock, endBlock *types.Block) ([]common.Address, error) {
 	if startBlock.Number().Uint64() >= endBlock.Number().Uint64() {
 		return nil, fmt.Errorf("start block height (%d) must be less than end block height (%d)", startBlock.Number().Uint64(), endBlock.Number().Uint64())
@@ -18,11 +18,14 @@ package eth
 
 import (
 	"context"
+	"errors"
 	"math/big"
+	"time"
 
 	"github.com/ethereum/go-ethereum/accounts"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/consensus"
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/bloombits"
 	"github.com/ethereum/go-ethereum/core/state"
@@ -30,12 +33,18 @@ import (
 	"github.com/ethereum/go-ethereum/core/vm"
 	"github.com/ethereum/go-ethereum/eth/downloader"
 	"github.com/ethereum/go-ethereum/eth/gasprice"
+	"github.com/ethereum/go-ethereum/eth/indexer"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/internal/ethapi"
 	"github.com/ethereum/go-ethereum/params"
 	"github.com/ethereum/go-ethereum/rpc"
 )
 
+// errIndexesDisabled is returned by index queries if no chain indexes are
+// maintained.
+var errIndexesDisabled = errors.New("chain indexes disabled")
+
 // EthApiBackend implements ethapi.Backend for full nodes
 type EthApiBackend struct {
 	eth *Ethereum
@@ -46,13 +55,48 @@ func (b *EthApiBackend) ChainConfig() *params.ChainConfig {
 	return b.eth.chainConfig
 }
 
+func (b *EthApiBackend) RPCGasCap() uint64 {
+	return b.eth.config.RPCGasCap
+}
+
+func (b *EthApiBackend) RPCEVMTimeout() time.Duration {
+	return b.eth.config.RPCEVMTimeout
+}
+
+func (b *EthApiBackend) RPCUnlockTimeout() time.Duration {
+	return b.eth.config.RPCUnlockTimeout
+}
+
+func (b *EthApiBackend) TxApproval() *ethapi.ApprovalConfig {
+	return &b.eth.config.TxApproval
+}
+
+func (b *EthApiBackend) AddressTransactions(ctx context.Context, addr common.Address, from, to uint64, limit int) ([]*indexer.AddressTx, error) {
+	if b.eth.indexer == nil {
+		return nil, errIndexesDisabled
+	}
+	return b.eth.indexer.AddressTxs(addr, from, to, limit)
+}
+
+func (b *EthApiBackend) LogQueryLimits() (uint64, int) {
+	return b.eth.config.RPCLogsBlockRange, b.eth.config.RPCLogsMaxResults
+}
+
+func (b *EthApiBackend) Engine() consensus.Engine {
+	return b.eth.engine
+}
+
+func (b *EthApiBackend) ChainReader() consensus.ChainReader {
+	return b.eth.blockchain
+}
+
 func (b *EthApiBackend) CurrentBlock() *types.Block {
 	return b.eth.blockchain.CurrentBlock()
 }
 
-func (b *EthApiBackend) SetHead(number uint64) {
+func (b *EthApiBackend) SetHead(number uint64, unsafe bool) error {
 	b.eth.protocolManager.downloader.Cancel()
-	b.eth.blockchain.SetHead(number)
+	return b.eth.blockchain.Rewind(number, unsafe)
 }
 
 func (b *EthApiBackend) HeaderByNumber(ctx context.Context, blockNr rpc.BlockNumber) (*types.Header, error) {
@@ -101,12 +145,21 @@ func (b *EthApiBackend) GetBlock(ctx context.Context, blockHash common.Hash) (*t
 }
 
 func (b *EthApiBackend) GetReceipts(ctx context.Context, blockHash common.Hash) (types.Receipts, error) {
-	return core.GetBlockReceipts(b.eth.chainDb, blockHash, core.GetBlockNumber(b.eth.chainDb, blockHash)), nil
+	number := core.GetBlockNumber(b.eth.chainDb, blockHash)
+	receipts := core.GetBlockReceipts(b.eth.chainDb, blockHash, number)
+	if receipts == nil && b.eth.receiptsPruned(number) {
+		return nil, errReceiptsPruned
+	}
+	return receipts, nil
 }
 
 func (b *EthApiBackend) GetLogs(ctx context.Context, blockHash common.Hash) ([][]*types.Log, error) {
-	receipts := core.GetBlockReceipts(b.eth.chainDb, blockHash, core.GetBlockNumber(b.eth.chainDb, blockHash))
+	number := core.GetBlockNumber(b.eth.chainDb, blockHash)
+	receipts := core.GetBlockReceipts(b.eth.chainDb, blockHash, number)
 	if receipts == nil {
+		if b.eth.receiptsPruned(number) {
+			return nil, errReceiptsPruned
+		}
 		return nil, nil
 	}
 	logs := make([][]*types.Log, len(receipts))
@@ -184,6 +237,10 @@ func (b *EthApiBackend) SubscribeTxPreEvent(ch chan<- core.TxPreEvent) event.Sub
 	return b.eth.TxPool().SubscribeTxPreEvent(ch)
 }
 
+func (b *EthApiBackend) SubscribeTxDropEvent(ch chan<- core.TxDropEvent) event.Subscription {
+	return b.eth.TxPool().SubscribeTxDropEvent(ch)
+}
+
 func (b *EthApiBackend) Downloader() *downloader.Downloader {
 	return b.eth.Downloader()
 }
@@ -196,6 +253,10 @@ func (b *EthApiBackend) SuggestPrice(ctx context.Context) (*big.Int, error) {
 	return b.gpo.SuggestPrice(ctx)
 }
 
+func (b *EthApiBackend) FeeHistory(ctx context.Context, blocks int, lastBlock rpc.BlockNumber, rewardPercentiles []float64) (*big.Int, [][]*big.Int, []*big.Int, []float64, error) {
+	return b.gpo.FeeHistory(ctx, blocks, lastBlock, rewardPercentiles)
+}
+
 func (b *EthApiBackend) ChainDb() ethdb.Database {
 	return b.eth.ChainDb()
 }
@@ -558,26 +558,23 @@ func (api *PrivateDebugAPI) traceTx(ctx context.Context, message core.Message, v
 		tracer vm.Tracer
 		err    error
 	)
+	// Define a meaningful timeout of a single transaction trace, never exceeding
+	// the execution time limit of the node
+	timeout := defaultTraceTimeout
+	if config != nil && config.Timeout != nil {
+		if timeout, err = time.ParseDuration(*config.Timeout); err != nil {
+			return nil, err
+		}
+	}
+	if limit := api.eth.config.RPCEVMTimeout; limit > 0 && timeout > limit {
+		timeout = limit
+	}
 	switch {
 	case config != nil && config.Tracer != nil:
-		// Define a meaningful timeout of a single transaction trace
-		timeout := defaultTraceTimeout
-		if config.Timeout != nil {
-			if timeout, err = time.ParseDuration(*config.Timeout); err != nil {
-				return nil, err
-			}
-		}
 		// Constuct the JavaScript tracer to execute with
 		if tracer, err = tracers.New(*config.Tracer); err != nil {
 			return nil, err
 		}
-		// Handle timeouts and RPC cancellations
-		deadlineCtx, cancel := context.WithTimeout(ctx, timeout)
-		go func() {
-			<-deadlineCtx.Done()
-			tracer.(*tracers.Tracer).Stop(errors.New("execution timeout"))
-		}()
-		defer cancel()
 
 	case config == nil:
 		tracer = vm.NewStructLogger(nil)
@@ -588,10 +585,28 @@ func (api *PrivateDebugAPI) traceTx(ctx context.Context, message core.Message, v
 	// Run the transaction with tracing enabled.
 	vmenv := vm.NewEVM(vmctx, statedb, api.config, vm.Config{Debug: true, Tracer: tracer})
 
+	// Handle timeouts and RPC cancellations, aborting the execution itself too
+	deadlineCtx, cancel := context.WithTimeout(ctx, timeout)
+	go func() {
+		<-deadlineCtx.Done()
+		vmenv.Cancel()
+		if tracer, ok := tracer.(*tracers.Tracer); ok {
+			tracer.Stop(errors.New("execution timeout"))
+		}
+	}()
+	defer cancel()
+
 	ret, gas, failed, err := core.ApplyMessage(vmenv, message, new(core.GasPool).AddGas(message.Gas()))
 	if err != nil {
 		return nil, fmt.Errorf("tracing failed: %v", err)
 	}
+	switch deadlineCtx.Err() {
+	case nil:
+	case context.DeadlineExceeded:
+		return nil, errors.New("execution timeout")
+	default:
+		return nil, deadlineCtx.Err()
+	}
 	// Depending on the tracer type, format and return the output
 	switch tracer := tracer.(type) {
 	case *vm.StructLogger:
@@ -0,0 +1,68 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// Tests that the deadline of a transaction trace is capped by the execution time
+// limit of the node, even if the trace asks for a longer timeout.
+func TestTraceTxTimeoutCap(t *testing.T) {
+	var (
+		db, _      = ethdb.NewMemDatabase()
+		statedb, _ = state.New(common.Hash{}, state.NewDatabase(db))
+		from       = common.HexToAddress("0x01")
+		loop       = common.HexToAddress("0x100")
+	)
+	// JUMPDEST, PUSH1 0, JUMP: loops until running out of gas
+	statedb.SetCode(loop, hexutil.MustDecode("0x5b600056"))
+
+	msg := types.NewMessage(from, &loop, 0, new(big.Int), 1<<62, new(big.Int), nil, false)
+	vmctx := vm.Context{
+		CanTransfer: core.CanTransfer,
+		Transfer:    core.Transfer,
+		BlockNumber: big.NewInt(1),
+		Time:        new(big.Int),
+		Difficulty:  new(big.Int),
+		GasLimit:    1 << 62,
+	}
+	api := NewPrivateDebugAPI(params.TestChainConfig, &Ethereum{config: &Config{RPCEVMTimeout: 100 * time.Millisecond}})
+
+	timeout := "1h"
+	config := &TraceConfig{LogConfig: &vm.LogConfig{DisableMemory: true, DisableStack: true, DisableStorage: true, Limit: 1}, Timeout: &timeout}
+
+	start := time.Now()
+	if _, err := api.traceTx(context.Background(), msg, vmctx, statedb, config); err == nil || err.Error() != "execution timeout" {
+		t.Fatalf("error mismatch: have %v, want execution timeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("trace not aborted at the node limit: took %v", elapsed)
+	}
+}
@@ -0,0 +1,163 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+// BlockWitness is the state accessed by the execution of a block: every trie
+// node and contract code read from the parent state, and every trie node and
+// contract code created by the block. The reads suffice to re-execute the block
+// without any other state, the writes to verify its resulting state root.
+type BlockWitness struct {
+	Number     hexutil.Uint64                `json:"number"`
+	Hash       common.Hash                   `json:"hash"`
+	ParentRoot common.Hash                   `json:"parentRoot"`
+	Root       common.Hash                   `json:"root"`
+	Reads      map[common.Hash]hexutil.Bytes `json:"reads"`
+	Writes     map[common.Hash]hexutil.Bytes `json:"writes"`
+}
+
+// GetBlockWitness re-executes a block on top of its parent state, returning the
+// trie nodes read and written by it.
+func (api *PrivateDebugAPI) GetBlockWitness(ctx context.Context, number rpc.BlockNumber) (*BlockWitness, error) {
+	var block *types.Block
+
+	switch number {
+	case rpc.PendingBlockNumber:
+		return nil, fmt.Errorf("witness of pending block not available")
+	case rpc.LatestBlockNumber:
+		block = api.eth.blockchain.CurrentBlock()
+	default:
+		block = api.eth.blockchain.GetBlockByNumber(uint64(number))
+	}
+	if block == nil {
+		return nil, fmt.Errorf("block #%d not found", number)
+	}
+	if block.NumberU64() == 0 {
+		return nil, fmt.Errorf("genesis block has no witness")
+	}
+	parent := api.eth.blockchain.GetBlock(block.ParentHash(), block.NumberU64()-1)
+	if parent == nil {
+		return nil, fmt.Errorf("parent %x not found", block.ParentHash())
+	}
+	pstate, err := api.computeStateDB(parent, defaultTraceReexec)
+	if err != nil {
+		return nil, err
+	}
+	// Execute the block on a fresh state cache, recording all node accesses
+	recorder := newWitnessDB(pstate.Database().TrieDB())
+	database := state.NewDatabase(recorder)
+
+	statedb, err := state.New(parent.Root(), database)
+	if err != nil {
+		return nil, err
+	}
+	if _, _, _, err := api.eth.blockchain.Processor().Process(block, statedb, vm.Config{}); err != nil {
+		return nil, err
+	}
+	root, err := statedb.Commit(api.config.IsEIP158(block.Number()))
+	if err != nil {
+		return nil, err
+	}
+	if root != block.Root() {
+		return nil, fmt.Errorf("state root mismatch: have %x, want %x", root, block.Root())
+	}
+	if err := database.TrieDB().Commit(root, false); err != nil {
+		return nil, err
+	}
+	return &BlockWitness{
+		Number:     hexutil.Uint64(block.NumberU64()),
+		Hash:       block.Hash(),
+		ParentRoot: parent.Root(),
+		Root:       root,
+		Reads:      recorder.reads,
+		Writes:     recorder.writes(),
+	}, nil
+}
+
+// witnessDB is a database recording the trie nodes and contract code loaded from
+// a backing trie database, and collecting everything written into memory.
+type witnessDB struct {
+	*ethdb.MemDatabase // Nodes and code written by the execution
+
+	source *trie.Database
+	reads  map[common.Hash]hexutil.Bytes
+	lock   sync.Mutex
+}
+
+// newWitnessDB creates a recording database reading through to the source.
+func newWitnessDB(source *trie.Database) *witnessDB {
+	mem, _ := ethdb.NewMemDatabase()
+	return &witnessDB{
+		MemDatabase: mem,
+		source:      source,
+		reads:       make(map[common.Hash]hexutil.Bytes),
+	}
+}
+
+// Get retrieves a node or code blob by hash, recording it if it was loaded from
+// the source. Other keys (e.g. preimages) are served from disk unrecorded.
+func (db *witnessDB) Get(key []byte) ([]byte, error) {
+	if blob, err := db.MemDatabase.Get(key); err == nil {
+		return blob, nil
+	}
+	if len(key) != common.HashLength {
+		return db.source.DiskDB().Get(key)
+	}
+	hash := common.BytesToHash(key)
+
+	blob, err := db.source.Node(hash)
+	if err != nil {
+		return nil, err
+	}
+	db.lock.Lock()
+	db.reads[hash] = common.CopyBytes(blob)
+	db.lock.Unlock()
+
+	return blob, nil
+}
+
+// Has retrieves whether a node or code blob is present in the database.
+func (db *witnessDB) Has(key []byte) (bool, error) {
+	blob, err := db.Get(key)
+	return err == nil && blob != nil, nil
+}
+
+// writes returns the nodes and code written into the database.
+func (db *witnessDB) writes() map[common.Hash]hexutil.Bytes {
+	writes := make(map[common.Hash]hexutil.Bytes)
+	for _, key := range db.Keys() {
+		if len(key) == common.HashLength {
+			blob, _ := db.MemDatabase.Get(key)
+			writes[common.BytesToHash(key)] = blob
+		}
+	}
+	return writes
+}
@@ -0,0 +1,110 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// Tests that the witness of a block is sufficient to re-execute it without any
+// other state, and contains the nodes of its resulting state.
+func TestBlockWitness(t *testing.T) {
+	var (
+		key, _  = crypto.GenerateKey()
+		sender  = crypto.PubkeyToAddress(key.PublicKey)
+		counter = common.Address{0xaa}
+		engine  = ethash.NewFaker()
+		db, _   = ethdb.NewMemDatabase()
+	)
+	// Create a chain incrementing a storage counter in every block
+	gspec := &core.Genesis{
+		Config: params.TestChainConfig,
+		Alloc: core.GenesisAlloc{
+			sender: {Balance: big.NewInt(1000000000)},
+			// PUSH1 1 SLOAD PUSH1 1 ADD PUSH1 1 SSTORE STOP
+			counter: {Code: common.FromHex("0x60015460010160015500"), Balance: new(big.Int)},
+		},
+	}
+	genesis := gspec.MustCommit(db)
+
+	chain, _ := core.NewBlockChain(db, nil, params.TestChainConfig, engine, vm.Config{})
+	defer chain.Stop()
+
+	blocks, _ := core.GenerateChain(params.TestChainConfig, genesis, engine, db, 2, func(i int, b *core.BlockGen) {
+		tx, _ := types.SignTx(types.NewTransaction(b.TxNonce(sender), counter, new(big.Int), 100000, new(big.Int), nil), types.HomesteadSigner{}, key)
+		b.AddTx(tx)
+	})
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	api := NewPrivateDebugAPI(params.TestChainConfig, &Ethereum{blockchain: chain, chainDb: db, engine: engine})
+
+	witness, err := api.GetBlockWitness(context.Background(), rpc.BlockNumber(2))
+	if err != nil {
+		t.Fatalf("failed to retrieve witness: %v", err)
+	}
+	if witness.Hash != blocks[1].Hash() || witness.ParentRoot != blocks[0].Root() || witness.Root != blocks[1].Root() {
+		t.Fatalf("witness header mismatch: have %x/%x/%x", witness.Hash, witness.ParentRoot, witness.Root)
+	}
+	if _, ok := witness.Reads[witness.ParentRoot]; !ok {
+		t.Errorf("parent state root missing from reads")
+	}
+	if _, ok := witness.Writes[witness.Root]; !ok {
+		t.Errorf("state root missing from writes")
+	}
+	for hash, blob := range witness.Reads {
+		if crypto.Keccak256Hash(blob) != hash {
+			t.Errorf("read %x: content hash mismatch", hash)
+		}
+	}
+	// Re-execute the block statelessly from the witness alone
+	stateless, _ := ethdb.NewMemDatabase()
+	for hash, blob := range witness.Reads {
+		stateless.Put(hash[:], blob)
+	}
+	statedb, err := state.New(witness.ParentRoot, state.NewDatabase(stateless))
+	if err != nil {
+		t.Fatalf("failed to open witness state: %v", err)
+	}
+	processor := core.NewStateProcessor(params.TestChainConfig, chain, engine)
+	if _, _, _, err := processor.Process(blocks[1], statedb, vm.Config{}); err != nil {
+		t.Fatalf("failed to execute block from witness: %v", err)
+	}
+	if root := statedb.IntermediateRoot(true); root != blocks[1].Root() {
+		t.Errorf("stateless root mismatch: have %x, want %x", root, blocks[1].Root())
+	}
+	if got := statedb.GetState(counter, common.BigToHash(big.NewInt(1))); got != common.BigToHash(big.NewInt(2)) {
+		t.Errorf("counter mismatch: have %x, want 2", got)
+	}
+	// The genesis block has nothing to witness
+	if _, err := api.GetBlockWitness(context.Background(), rpc.BlockNumber(0)); err == nil {
+		t.Errorf("genesis witness returned")
+	}
+}
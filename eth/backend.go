@@ -22,10 +22,12 @@ import (
 	"fmt"
 	"math/big"
 	"runtime"
+	"strings"
 	"sync"
 	"sync/atomic"
 
 	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/external"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/consensus"
@@ -41,6 +43,7 @@ import (
 	"github.com/ethereum/go-ethereum/eth/downloader"
 	"github.com/ethereum/go-ethereum/eth/filters"
 	"github.com/ethereum/go-ethereum/eth/gasprice"
+	"github.com/ethereum/go-ethereum/eth/indexer"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/event"
 	"github.com/ethereum/go-ethereum/internal/ethapi"
@@ -84,6 +87,8 @@ type Ethereum struct {
 
 	bloomRequests chan chan *bloombits.Retrieval // Channel receiving bloom data retrieval requests
 	bloomIndexer  *core.ChainIndexer             // Bloom indexer operating during block imports
+	receiptPruner *core.ChainIndexer             // Receipt pruner operating during block imports, nil if disabled
+	indexer       *indexer.Indexer               // Secondary chain indexes operating during block imports, nil if disabled
 
 	ApiBackend *EthApiBackend
 
@@ -93,6 +98,8 @@ type Ethereum struct {
 
 	networkId     uint64
 	netRPCService *ethapi.PublicNetAPI
+	p2pServer     *p2p.Server // Networking layer the service runs on, set when started
+	instanceDir   string      // Directory of the node's own files, empty if ephemeral
 
 	lock sync.RWMutex // Protects the variadic fields (e.g. gas price and etherbase)
 }
@@ -111,6 +118,12 @@ func New(ctx *node.ServiceContext, config *Config) (*Ethereum, error) {
 	if !config.SyncMode.IsValid() {
 		return nil, fmt.Errorf("invalid sync mode %d", config.SyncMode)
 	}
+	if err := config.TxApproval.Validate(); err != nil {
+		return nil, err
+	}
+	if err := core.SetBlockCompression(config.BlockCompression); err != nil {
+		return nil, err
+	}
 	chainDb, err := CreateDB(ctx, config, "chaindata")
 	if err != nil {
 		return nil, err
@@ -120,6 +133,9 @@ func New(ctx *node.ServiceContext, config *Config) (*Ethereum, error) {
 	if _, ok := genesisErr.(*params.ConfigCompatError); genesisErr != nil && !ok {
 		return nil, genesisErr
 	}
+	if err := chainConfig.CheckEngine(); err != nil {
+		return nil, err
+	}
 	log.Info("Initialised chain configuration", "config", chainConfig)
 
 	eth := &Ethereum{
@@ -135,7 +151,16 @@ func New(ctx *node.ServiceContext, config *Config) (*Ethereum, error) {
 		gasPrice:       config.GasPrice,
 		etherbase:      config.Etherbase,
 		bloomRequests:  make(chan chan *bloombits.Retrieval),
-		bloomIndexer:   NewBloomIndexer(chainDb, params.BloomBitsBlocks),
+	}
+	// Blocks of BFT engines are final once sealed, there's no need to wait for
+	// confirmations before indexing them.
+	confirms := uint64(bloomConfirms)
+	if _, ok := eth.engine.(consensus.FinalityVerifier); ok {
+		confirms = bloomFinalConfirms
+	}
+	eth.bloomIndexer = newBloomIndexer(chainDb, params.BloomBitsBlocks, confirms)
+	if config.ReceiptsRetention > 0 {
+		eth.receiptPruner = NewReceiptPruner(chainDb, config.ReceiptsRetention)
 	}
 
 	// force to set the istanbul etherbase to node key address
@@ -154,12 +179,17 @@ func New(ctx *node.ServiceContext, config *Config) (*Ethereum, error) {
 	}
 	var (
 		vmConfig    = vm.Config{EnablePreimageRecording: config.EnablePreimageRecording}
-		cacheConfig = &core.CacheConfig{Disabled: config.NoPruning, TrieNodeLimit: config.TrieCache, TrieTimeLimit: config.TrieTimeout}
+		cacheConfig = &core.CacheConfig{Disabled: config.NoPruning, TrieNodeLimit: config.TrieCache, TrieTimeLimit: config.TrieTimeout, AsyncIndexing: config.AsyncIndexing, TrieCleanLimit: config.TrieCleanCache}
 	)
 	eth.blockchain, err = core.NewBlockChain(chainDb, cacheConfig, eth.chainConfig, eth.engine, vmConfig)
 	if err != nil {
 		return nil, err
 	}
+	eth.blockchain.SetBadBlockDir(ctx.ResolvePath("badblocks"))
+	eth.instanceDir = ctx.ResolvePath("")
+	if config.ParallelTxs > 1 {
+		eth.blockchain.SetProcessor(core.NewParallelProcessor(eth.chainConfig, eth.blockchain, eth.engine, config.ParallelTxs))
+	}
 	// Rewind the chain in case of an incompatible config upgrade.
 	if compat, ok := genesisErr.(*params.ConfigCompatError); ok {
 		log.Warn("Rewinding chain to upgrade configuration", "err", compat)
@@ -167,6 +197,30 @@ func New(ctx *node.ServiceContext, config *Config) (*Ethereum, error) {
 		core.WriteChainConfig(chainDb, genesisHash, chainConfig)
 	}
 	eth.bloomIndexer.Start(eth.blockchain)
+	if eth.receiptPruner != nil {
+		eth.receiptPruner.Start(eth.blockchain)
+	}
+	if len(config.Indexes) > 0 {
+		eth.indexer = indexer.New(chainDb, confirms)
+		for _, name := range config.Indexes {
+			var index indexer.Index
+			switch strings.TrimSpace(name) {
+			case indexer.AddressIndexName:
+				index = indexer.NewAddressIndex(eth.chainConfig)
+			case indexer.TransferIndexName:
+				if !config.NoPruning {
+					return nil, errors.New("transfer index requires an archive node (--gcmode=archive)")
+				}
+				index = indexer.NewTransferIndex(eth.blockchain)
+			default:
+				return nil, fmt.Errorf("unknown chain index %q", name)
+			}
+			if err := eth.indexer.Register(index); err != nil {
+				return nil, err
+			}
+		}
+		eth.indexer.Start(eth.blockchain)
+	}
 
 	if config.TxPool.Journal != "" {
 		config.TxPool.Journal = ctx.ResolvePath(config.TxPool.Journal)
@@ -176,8 +230,17 @@ func New(ctx *node.ServiceContext, config *Config) (*Ethereum, error) {
 	if eth.protocolManager, err = NewProtocolManager(eth.chainConfig, config.SyncMode, config.NetworkId, eth.eventMux, eth.txPool, eth.engine, eth.blockchain, chainDb); err != nil {
 		return nil, err
 	}
+	if _, ok := eth.engine.(consensus.Istanbul); config.TxBroadcast.ValidatorsOnly && !ok {
+		log.Warn("Validator-only transaction broadcast requires Istanbul consensus, broadcasting to all peers")
+	}
+	eth.protocolManager.txBroadcast = config.TxBroadcast
 	eth.miner = miner.New(eth, eth.chainConfig, eth.EventMux(), eth.engine)
 	eth.miner.SetExtra(makeExtraData(config.ExtraData))
+	if config.TxOrdering != "" {
+		if err := eth.miner.SetTxOrdering(config.TxOrdering); err != nil {
+			return nil, err
+		}
+	}
 
 	eth.ApiBackend = &EthApiBackend{eth, nil}
 	gpoParams := config.GPO
@@ -220,19 +283,18 @@ func CreateDB(ctx *node.ServiceContext, config *Config, name string) (ethdb.Data
 
 // CreateConsensusEngine creates the required type of consensus engine instance for an Ethereum service
 func CreateConsensusEngine(ctx *node.ServiceContext, config *Config, chainConfig *params.ChainConfig, db ethdb.Database) consensus.Engine {
-	// If proof-of-authority is requested, set it up
-	if chainConfig.Clique != nil {
+	switch chainConfig.Engine() {
+	case params.EngineClique:
 		return clique.New(chainConfig.Clique, db)
-	}
-	// If Istanbul is requested, set it up
-	if chainConfig.Istanbul != nil {
+	case params.EngineIstanbul:
 		if chainConfig.Istanbul.Epoch != 0 {
 			config.Istanbul.Epoch = chainConfig.Istanbul.Epoch
 		}
 		config.Istanbul.ProposerPolicy = istanbul.ProposerPolicy(chainConfig.Istanbul.ProposerPolicy)
+		config.Istanbul.EmptyBlockPeriod = chainConfig.Istanbul.EmptyBlockPeriod
+		config.Istanbul.ChainID = chainConfig.ChainId
 		return istanbulBackend.New(&config.Istanbul, ctx.NodeKey(), db)
 	}
-
 	// Otherwise assume proof-of-work
 	ethConfig := config.Ethash
 	switch {
@@ -267,6 +329,16 @@ func (s *Ethereum) APIs() []rpc.API {
 	// Append any APIs exposed explicitly by the consensus engine
 	apis = append(apis, s.engine.APIs(s.BlockChain())...)
 
+	// Append the secondary index APIs if any indexes are maintained
+	if s.indexer != nil {
+		apis = append(apis, rpc.API{
+			Namespace: "index",
+			Version:   "1.0",
+			Service:   indexer.NewPublicIndexerAPI(s.indexer),
+			Public:    true,
+		})
+	}
+
 	// Append all the local APIs and return
 	return append(apis, []rpc.API{
 		{
@@ -368,7 +440,25 @@ func (s *Ethereum) StartMining(local bool) error {
 			log.Error("Etherbase account unavailable locally", "err", err)
 			return fmt.Errorf("signer missing: %v", err)
 		}
-		clique.Authorize(eb, wallet.SignHash)
+		signFn := wallet.SignHash
+		if signer, ok := wallet.(*external.ExternalSigner); ok {
+			// Let the external signer know it's approving block seals
+			signFn = signer.SignHashFn(external.KindConsensus)
+		}
+		clique.Authorize(eb, signFn)
+	}
+	if istanbul, ok := s.engine.(consensus.Istanbul); ok {
+		// Validators sign with the node key, unless an external signer serves it
+		account := accounts.Account{Address: eb}
+		if wallet, err := s.accountManager.Find(account); err == nil {
+			if signer, ok := wallet.(*external.ExternalSigner); ok {
+				signFn := signer.SignHashFn(external.KindConsensus)
+				istanbul.Authorize(func(hash []byte) ([]byte, error) {
+					return signFn(account, hash)
+				})
+				log.Info("Signing consensus messages with the external signer", "address", eb)
+			}
+		}
 	}
 	if local {
 		// If local (CPU) mining is started, we can disable the transaction rejection
@@ -413,6 +503,7 @@ func (s *Ethereum) Start(srvr *p2p.Server) error {
 
 	// Start the RPC service
 	s.netRPCService = ethapi.NewPublicNetAPI(srvr, s.NetVersion())
+	s.p2pServer = srvr
 
 	// Figure out a max peers count based on the server limits
 	maxPeers := srvr.MaxPeers
@@ -437,6 +528,12 @@ func (s *Ethereum) Stop() error {
 		s.stopDbUpgrade()
 	}
 	s.bloomIndexer.Close()
+	if s.receiptPruner != nil {
+		s.receiptPruner.Close()
+	}
+	if s.indexer != nil {
+		s.indexer.Close()
+	}
 	s.blockchain.Stop()
 	s.protocolManager.Stop()
 	if s.lesServer != nil {
@@ -83,6 +83,10 @@ const (
 	// considered probably final and its rotated bits are calculated.
 	bloomConfirms = 256
 
+	// bloomFinalConfirms is the number of confirmation blocks needed with consensus
+	// engines whose blocks are final once sealed.
+	bloomFinalConfirms = 0
+
 	// bloomThrottling is the time to wait between processing two consecutive index
 	// sections. It's useful during chain upgrades to prevent disk overload.
 	bloomThrottling = 100 * time.Millisecond
@@ -103,13 +107,19 @@ type BloomIndexer struct {
 // NewBloomIndexer returns a chain indexer that generates bloom bits data for the
 // canonical chain for fast logs filtering.
 func NewBloomIndexer(db ethdb.Database, size uint64) *core.ChainIndexer {
+	return newBloomIndexer(db, size, bloomConfirms)
+}
+
+// newBloomIndexer returns a chain indexer that generates bloom bits data for the
+// canonical chain, waiting for the given number of confirmations per section.
+func newBloomIndexer(db ethdb.Database, size uint64, confirms uint64) *core.ChainIndexer {
 	backend := &BloomIndexer{
 		db:   db,
 		size: size,
 	}
 	table := ethdb.NewTable(db, string(core.BloomBitsIndexPrefix))
 
-	return core.NewChainIndexer(db, table, backend, size, bloomConfirms, bloomThrottling, "bloombits")
+	return core.NewChainIndexer(db, table, backend, size, confirms, bloomThrottling, "bloombits")
 }
 
 // Reset implements core.ChainIndexerBackend, starting a new bloombits index
@@ -0,0 +1,474 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package bridge relays lock and burn events of this chain to a destination chain.
+//
+// Every event emitted by the bridge contract in a finalized block is packaged
+// with the istanbul commit seals of its block and a Merkle proof of its receipt,
+// allowing the destination chain to verify it without trusting the relay. Events
+// are relayed in both directions by running the service on a node of each chain,
+// pointed at the other one.
+//
+// Messages carry a unique ID committing to both chains, which the destination
+// contract must use to reject replays. The relay itself records every submitted
+// message and never submits one twice, even across restarts.
+package bridge
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+const (
+	// chainHeadChanSize is the size of channel listening to ChainHeadEvent.
+	chainHeadChanSize = 10
+
+	// retryInterval is the time to wait before retrying a failed submission.
+	retryInterval = 5 * time.Second
+)
+
+var (
+	// dbKeyCursor is the database key of the next block to relay.
+	dbKeyCursor = []byte("bridge-cursor")
+
+	// dbPrefixRelayed is the database prefix of the submitted messages, mapping
+	// message IDs to the hash of the transaction submitting them.
+	dbPrefixRelayed = []byte("bridge-relayed-")
+)
+
+var (
+	errNoDestination = errors.New("bridge destination not configured")
+	errNoEvent       = errors.New("event not emitted by the bridge contract")
+)
+
+// Config contains the settings of the bridge relay.
+type Config struct {
+	Contract    common.Address // Bridge contract of this chain emitting the events to relay
+	Events      []common.Hash  // Signatures of the lock and burn events to relay (empty = all)
+	Endpoint    string         // RPC endpoint of the destination chain
+	Destination common.Address // Bridge contract of the destination chain to submit to
+	ChainID     *big.Int       // Chain ID of the destination chain
+	Account     common.Address // Account submitting the messages to the destination chain
+	GasLimit    uint64         // Gas allowance of the submissions (0 = estimate)
+	GasPrice    *big.Int       // Gas price of the submissions (nil = suggested by the destination)
+	Format      string         // Message format accepted by the destination contract
+	From        uint64         // First block to relay if no progress is stored
+}
+
+// DefaultConfig contains the default settings of the bridge relay.
+var DefaultConfig = Config{
+	Format: "abi",
+}
+
+// Destination is the chain messages are relayed to.
+type Destination interface {
+	// Name returns a descriptive name of the destination for logging.
+	Name() string
+
+	// Submit sends an encoded message to the bridge contract of the destination
+	// chain, returning the hash of the submitting transaction.
+	Submit(payload []byte) (common.Hash, error)
+}
+
+// Service relays the bridge events of finalized blocks to the destination chain.
+type Service struct {
+	config *Config
+	chain  *core.BlockChain
+	db     ethdb.Database
+	dest   Destination
+	format Format
+
+	next    uint64 // Next block to relay
+	relayed uint64 // Messages submitted since startup
+	lastErr error  // Last submission failure, nil if the last submission succeeded
+	lock    sync.RWMutex
+
+	update chan struct{}
+	quit   chan struct{}
+	wg     sync.WaitGroup
+}
+
+// New creates a bridge relay submitting messages to the configured destination
+// endpoint, signing its transactions with the given function.
+func New(config *Config, chain *core.BlockChain, db ethdb.Database, sign SignTxFn) (*Service, error) {
+	if config.Endpoint == "" {
+		return nil, errNoDestination
+	}
+	dest, err := NewRPCDestination(config, sign)
+	if err != nil {
+		return nil, err
+	}
+	return NewWithDestination(config, chain, db, dest)
+}
+
+// NewWithDestination creates a bridge relay submitting messages to the given
+// destination. The destination settings of the config are ignored.
+func NewWithDestination(config *Config, chain *core.BlockChain, db ethdb.Database, dest Destination) (*Service, error) {
+	format, ok := formats[config.Format]
+	if !ok {
+		return nil, fmt.Errorf("unknown bridge message format %q", config.Format)
+	}
+	if config.ChainID == nil {
+		return nil, errors.New("destination chain ID not configured")
+	}
+	next := config.From
+	if blob, err := db.Get(dbKeyCursor); err == nil {
+		if len(blob) != 8 {
+			return nil, fmt.Errorf("corrupt bridge cursor: %x", blob)
+		}
+		next = binary.BigEndian.Uint64(blob)
+	}
+	return &Service{
+		config: config,
+		chain:  chain,
+		db:     db,
+		dest:   dest,
+		format: format,
+		next:   next,
+		update: make(chan struct{}, 1),
+		quit:   make(chan struct{}),
+	}, nil
+}
+
+// Protocols implements node.Service, returning the P2P network protocols used
+// by the bridge (nil as it doesn't use the devp2p overlay network).
+func (s *Service) Protocols() []p2p.Protocol { return nil }
+
+// APIs implements node.Service, returning the RPC API endpoints provided by the
+// bridge.
+func (s *Service) APIs() []rpc.API {
+	return []rpc.API{{
+		Namespace: "bridge",
+		Version:   "1.0",
+		Service:   &PublicBridgeAPI{s},
+		Public:    true,
+	}}
+}
+
+// Start implements node.Service, starting to relay the chain.
+func (s *Service) Start(server *p2p.Server) error {
+	if _, ok := s.chain.Engine().(consensus.FinalityVerifier); !ok {
+		log.Warn("Consensus engine without finality, bridge relay disabled")
+		return nil
+	}
+	s.wg.Add(2)
+	go s.loop()
+	go s.relayer()
+
+	log.Info("Started bridge relay", "contract", s.config.Contract, "destination", s.dest.Name(), "next", s.cursor())
+	return nil
+}
+
+// Stop implements node.Service, terminating the relay.
+func (s *Service) Stop() error {
+	close(s.quit)
+	s.wg.Wait()
+
+	if dest, ok := s.dest.(*RPCDestination); ok {
+		dest.Close()
+	}
+	log.Info("Bridge relay stopped", "next", s.cursor())
+	return nil
+}
+
+// loop notifies the relayer of chain head changes. Submissions may take long,
+// so they are done on a separate goroutine not to block the chain event feed.
+func (s *Service) loop() {
+	defer s.wg.Done()
+
+	headCh := make(chan core.ChainHeadEvent, chainHeadChanSize)
+	sub := s.chain.SubscribeChainHeadEvent(headCh)
+	defer sub.Unsubscribe()
+
+	s.notify()
+	for {
+		select {
+		case <-headCh:
+			s.notify()
+		case <-sub.Err():
+			return
+		case <-s.quit:
+			return
+		}
+	}
+}
+
+// notify signals the relayer that new blocks might be available.
+func (s *Service) notify() {
+	select {
+	case s.update <- struct{}{}:
+	default:
+	}
+}
+
+// relayer submits the events of every finalized block not yet relayed, retrying
+// failed submissions until they succeed.
+func (s *Service) relayer() {
+	defer s.wg.Done()
+
+	retry := time.NewTimer(0)
+	<-retry.C
+	defer retry.Stop()
+
+	for {
+		select {
+		case <-s.update:
+		case <-retry.C:
+		case <-s.quit:
+			return
+		}
+		if err := s.relay(); err != nil {
+			log.Warn("Failed to relay bridge events", "block", s.cursor(), "err", err)
+			retry.Reset(retryInterval)
+		}
+	}
+}
+
+// relay submits the events of all finalized blocks from the cursor onwards,
+// stopping at the first failure.
+func (s *Service) relay() error {
+	defer s.store()
+
+	for s.finalized(s.cursor()) {
+		select {
+		case <-s.quit:
+			return nil
+		default:
+		}
+		if err := s.relayBlock(s.cursor()); err != nil {
+			s.lock.Lock()
+			s.lastErr = err
+			s.lock.Unlock()
+			return err
+		}
+	}
+	return nil
+}
+
+// relayBlock submits the bridge events of a block not submitted yet, and
+// advances the cursor if all of them were accepted by the destination.
+func (s *Service) relayBlock(number uint64) error {
+	block := s.chain.GetBlockByNumber(number)
+	if block == nil {
+		return fmt.Errorf("block #%d missing", number)
+	}
+	receipts := core.GetBlockReceipts(s.db, block.Hash(), number)
+	for i, receipt := range receipts {
+		for j, l := range receipt.Logs {
+			if !s.matches(l) {
+				continue
+			}
+			msg, err := s.message(block.Header(), receipts, uint(i), uint(j))
+			if err != nil {
+				return err
+			}
+			if err := s.submit(msg); err != nil {
+				return err
+			}
+		}
+	}
+	s.lock.Lock()
+	s.next, s.lastErr = number+1, nil
+	s.lock.Unlock()
+	return nil
+}
+
+// submit sends a message to the destination unless it was submitted before, and
+// records it as submitted.
+func (s *Service) submit(msg *Message) error {
+	id := msg.ID()
+	if tx := s.submission(id); tx != (common.Hash{}) {
+		log.Debug("Skipping relayed bridge message", "id", id, "tx", tx)
+		return nil
+	}
+	payload, err := s.format.Encode(msg)
+	if err != nil {
+		return err
+	}
+	tx, err := s.dest.Submit(payload)
+	if err != nil {
+		return fmt.Errorf("%s: %v", s.dest.Name(), err)
+	}
+	if err := s.db.Put(append(dbPrefixRelayed, id[:]...), tx[:]); err != nil {
+		log.Crit("Failed to store bridge submission", "err", err)
+	}
+	s.lock.Lock()
+	s.relayed++
+	s.lock.Unlock()
+
+	log.Info("Relayed bridge message", "id", id, "block", msg.Proof.Header.Number, "tx", tx)
+	return nil
+}
+
+// submission returns the hash of the transaction a message was submitted in,
+// or the zero hash if it wasn't submitted yet.
+func (s *Service) submission(id common.Hash) common.Hash {
+	blob, err := s.db.Get(append(dbPrefixRelayed, id[:]...))
+	if err != nil {
+		return common.Hash{}
+	}
+	return common.BytesToHash(blob)
+}
+
+// message packages an event of a block along with the proof of its finality.
+func (s *Service) message(header *types.Header, receipts types.Receipts, txIndex, logIndex uint) (*Message, error) {
+	proof, err := proveReceipt(receipts, txIndex)
+	if err != nil {
+		return nil, err
+	}
+	return &Message{
+		SourceChain: s.chain.Config().ChainId,
+		DestChain:   s.config.ChainID,
+		Log:         receipts[txIndex].Logs[logIndex],
+		Proof: &FinalityProof{
+			Header:   header,
+			TxIndex:  txIndex,
+			LogIndex: logIndex,
+			Receipt:  proof,
+		},
+	}, nil
+}
+
+// matches checks whether a log is a bridge event to relay.
+func (s *Service) matches(l *types.Log) bool {
+	if l.Address != s.config.Contract {
+		return false
+	}
+	if len(s.config.Events) == 0 {
+		return true
+	}
+	if len(l.Topics) == 0 {
+		return false
+	}
+	for _, event := range s.config.Events {
+		if l.Topics[0] == event {
+			return true
+		}
+	}
+	return false
+}
+
+// finalized checks whether the given canonical block is final. Blocks of engines
+// without finality are never considered final.
+func (s *Service) finalized(number uint64) bool {
+	verifier, ok := s.chain.Engine().(consensus.FinalityVerifier)
+	if !ok {
+		return false
+	}
+	header := s.chain.GetHeaderByNumber(number)
+	if header == nil {
+		return false
+	}
+	return verifier.VerifyFinality(s.chain, header) == nil
+}
+
+// cursor returns the next block to relay.
+func (s *Service) cursor() uint64 {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return s.next
+}
+
+// store persists the relay cursor.
+func (s *Service) store() {
+	blob := make([]byte, 8)
+	binary.BigEndian.PutUint64(blob, s.cursor())
+
+	if err := s.db.Put(dbKeyCursor, blob); err != nil {
+		log.Crit("Failed to store bridge cursor", "err", err)
+	}
+}
+
+// PublicBridgeAPI provides access to the bridge relay and its messages.
+type PublicBridgeAPI struct {
+	s *Service
+}
+
+// BridgeStatus is the progress of the bridge relay.
+type BridgeStatus struct {
+	Next        hexutil.Uint64 `json:"next"`
+	Relayed     hexutil.Uint64 `json:"relayed"`
+	Destination string         `json:"destination"`
+	Error       string         `json:"error,omitempty"`
+}
+
+// Status returns the next block to be relayed and the last submission failure,
+// if the relay is currently stuck.
+func (api *PublicBridgeAPI) Status() *BridgeStatus {
+	api.s.lock.RLock()
+	defer api.s.lock.RUnlock()
+
+	status := &BridgeStatus{
+		Next:        hexutil.Uint64(api.s.next),
+		Relayed:     hexutil.Uint64(api.s.relayed),
+		Destination: api.s.dest.Name(),
+	}
+	if api.s.lastErr != nil {
+		status.Error = api.s.lastErr.Error()
+	}
+	return status
+}
+
+// GetMessage returns the RLP encoded message of a bridge event, given the hash
+// of the emitting transaction and the index of the event among its logs. It
+// allows third parties to relay or verify events themselves.
+func (api *PublicBridgeAPI) GetMessage(txHash common.Hash, index hexutil.Uint) (hexutil.Bytes, error) {
+	blockHash, number, txIndex := core.GetTxLookupEntry(api.s.db, txHash)
+	if blockHash == (common.Hash{}) {
+		return nil, fmt.Errorf("transaction %x not found", txHash)
+	}
+	header := api.s.chain.GetHeader(blockHash, number)
+	if header == nil {
+		return nil, fmt.Errorf("block %x missing", blockHash)
+	}
+	if !api.s.finalized(number) || core.GetCanonicalHash(api.s.db, number) != blockHash {
+		return nil, fmt.Errorf("block #%d not final", number)
+	}
+	receipts := core.GetBlockReceipts(api.s.db, blockHash, number)
+	if txIndex >= uint64(len(receipts)) || uint64(index) >= uint64(len(receipts[txIndex].Logs)) || !api.s.matches(receipts[txIndex].Logs[index]) {
+		return nil, errNoEvent
+	}
+	msg, err := api.s.message(header, receipts, uint(txIndex), uint(index))
+	if err != nil {
+		return nil, err
+	}
+	return rlp.EncodeToBytes(msg)
+}
+
+// Submission returns the hash of the destination transaction a message was
+// submitted in, or nil if it wasn't submitted yet.
+func (api *PublicBridgeAPI) Submission(id common.Hash) *common.Hash {
+	if tx := api.s.submission(id); tx != (common.Hash{}) {
+		return &tx
+	}
+	return nil
+}
@@ -0,0 +1,332 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package bridge
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/consensus/consensustest"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	istanbulBackend "github.com/ethereum/go-ethereum/consensus/istanbul/backend"
+	istanbulCore "github.com/ethereum/go-ethereum/consensus/istanbul/core"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/crypto/bls"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+var (
+	testKey, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313e5ec3bb4b4ea8b2a9")
+	testAddress = crypto.PubkeyToAddress(testKey.PublicKey)
+
+	bridgeContract = common.HexToAddress("0xb1d9e")
+	otherContract  = common.HexToAddress("0x07e4")
+
+	lockEvent = common.HexToHash("0x10c4")
+)
+
+// testDestination records the payloads submitted to it, failing on demand.
+type testDestination struct {
+	payloads [][]byte
+	fail     bool
+}
+
+func (d *testDestination) Name() string { return "test" }
+
+func (d *testDestination) Submit(payload []byte) (common.Hash, error) {
+	if d.fail {
+		return common.Hash{}, errors.New("destination down")
+	}
+	d.payloads = append(d.payloads, payload)
+	return crypto.Keccak256Hash(payload), nil
+}
+
+// newTestChain creates a chain where every block calls both the bridge contract
+// and another contract, each emitting a lock event.
+func newTestChain(t *testing.T, engine consensus.Engine, blocks int) (*core.BlockChain, ethdb.Database) {
+	// PUSH32 event PUSH1 0 PUSH1 0 LOG1 STOP
+	code := append(append([]byte{0x7f}, lockEvent[:]...), 0x60, 0x00, 0x60, 0x00, 0xa1, 0x00)
+
+	alloc := core.GenesisAlloc{
+		testAddress:    {Balance: big.NewInt(1000000000)},
+		bridgeContract: {Code: code, Balance: new(big.Int)},
+		otherContract:  {Code: code, Balance: new(big.Int)},
+	}
+	return consensustest.NewChain(t, engine, alloc, blocks, func(i int, b *core.BlockGen) {
+		for _, contract := range []common.Address{otherContract, bridgeContract} {
+			tx, _ := types.SignTx(types.NewTransaction(b.TxNonce(testAddress), contract, new(big.Int), 100000, new(big.Int), nil), types.HomesteadSigner{}, testKey)
+			b.AddTx(tx)
+		}
+	})
+}
+
+// Tests that the bridge events of final blocks are relayed exactly once, along
+// with valid receipt proofs, and that the cursor only advances over successful
+// submissions.
+func TestRelay(t *testing.T) {
+	engine := &consensustest.FinalEngine{Engine: ethash.NewFaker(), Final: 3}
+	chain, db := newTestChain(t, engine, 5)
+	defer chain.Stop()
+
+	dest := &testDestination{fail: true}
+	config := &Config{Contract: bridgeContract, Events: []common.Hash{lockEvent}, ChainID: big.NewInt(2), Format: "rlp", From: 1}
+	s, err := NewWithDestination(config, chain, db, dest)
+	if err != nil {
+		t.Fatalf("failed to create bridge: %v", err)
+	}
+	// A failing destination must not advance the cursor
+	if err := s.relay(); err == nil {
+		t.Fatalf("relay succeeded into failing destination")
+	}
+	if next := s.cursor(); next != 1 {
+		t.Fatalf("cursor mismatch after failure: have #%d, want #1", next)
+	}
+	if status := (&PublicBridgeAPI{s}).Status(); status.Error == "" {
+		t.Errorf("submission failure not reported")
+	}
+	// Recovering destination must receive the events of all final blocks
+	dest.fail = false
+	if err := s.relay(); err != nil {
+		t.Fatalf("failed to relay: %v", err)
+	}
+	if next := s.cursor(); next != 4 {
+		t.Fatalf("cursor mismatch: have #%d, want #4", next)
+	}
+	if len(dest.payloads) != 3 {
+		t.Fatalf("relayed message count mismatch: have %d, want 3", len(dest.payloads))
+	}
+	for i, payload := range dest.payloads {
+		msg := new(Message)
+		if err := rlp.DecodeBytes(payload, msg); err != nil {
+			t.Fatalf("message %d: failed to decode: %v", i, err)
+		}
+		if msg.Log.Address != bridgeContract || msg.Proof.Header.Number.Uint64() != uint64(i+1) || msg.Proof.TxIndex != 1 {
+			t.Errorf("message %d: event mismatch: contract %x, block #%d, tx %d", i, msg.Log.Address, msg.Proof.Header.Number, msg.Proof.TxIndex)
+		}
+		if msg.SourceChain.Cmp(params.TestChainConfig.ChainId) != 0 || msg.DestChain.Cmp(config.ChainID) != 0 {
+			t.Errorf("message %d: chain mismatch: source %v, destination %v", i, msg.SourceChain, msg.DestChain)
+		}
+		if _, err := verifyReceipt(msg.Proof); err != nil {
+			t.Errorf("message %d: invalid receipt proof: %v", i, err)
+		}
+		if tx := (&PublicBridgeAPI{s}).Submission(msg.ID()); tx == nil || *tx != crypto.Keccak256Hash(payload) {
+			t.Errorf("message %d: submission not recorded", i)
+		}
+	}
+	// Losing the cursor must not resubmit any message
+	db.Delete(dbKeyCursor)
+	if s, err = NewWithDestination(config, chain, db, dest); err != nil {
+		t.Fatalf("failed to recreate bridge: %v", err)
+	}
+	if err := s.relay(); err != nil {
+		t.Fatalf("failed to relay: %v", err)
+	}
+	if len(dest.payloads) != 3 {
+		t.Errorf("messages resubmitted: have %d, want 3", len(dest.payloads))
+	}
+	// Events must be retrievable for third party relays
+	block := chain.GetBlockByNumber(2)
+	blob, err := (&PublicBridgeAPI{s}).GetMessage(block.Transactions()[1].Hash(), 0)
+	if err != nil {
+		t.Fatalf("failed to retrieve message: %v", err)
+	}
+	if !bytes.Equal(blob, dest.payloads[1]) {
+		t.Errorf("retrieved message mismatch")
+	}
+	if _, err := (&PublicBridgeAPI{s}).GetMessage(block.Transactions()[0].Hash(), 0); err != errNoEvent {
+		t.Errorf("foreign event retrieval error mismatch: have %v, want %v", err, errNoEvent)
+	}
+}
+
+// sealHeader seals a header as an istanbul block committed by the given keys.
+func sealHeader(header *types.Header, validators []common.Address, keys []*ecdsa.PrivateKey) {
+	extra := &types.IstanbulExtra{Validators: validators}
+	blob, _ := rlp.EncodeToBytes(extra)
+	header.MixDigest = types.IstanbulDigest
+	header.Extra = append(make([]byte, types.IstanbulExtraVanity), blob...)
+
+	proposal := istanbulCore.PrepareCommittedSeal(header.Hash())
+	for _, key := range keys {
+		seal, _ := crypto.Sign(crypto.Keccak256(proposal), key)
+		extra.CommittedSeal = append(extra.CommittedSeal, seal)
+	}
+	blob, _ = rlp.EncodeToBytes(extra)
+	header.Extra = append(make([]byte, types.IstanbulExtraVanity), blob...)
+}
+
+// Tests that messages only verify if sealed by a quorum of the validators, and
+// if the event is part of the proven receipt.
+func TestVerify(t *testing.T) {
+	var (
+		keys       = make([]*ecdsa.PrivateKey, 5)
+		validators = make([]common.Address, 4)
+	)
+	for i := range keys {
+		keys[i], _ = crypto.GenerateKey()
+		if i < len(validators) {
+			validators[i] = crypto.PubkeyToAddress(keys[i].PublicKey)
+		}
+	}
+	event := &types.Log{Address: bridgeContract, Topics: []common.Hash{lockEvent}, Data: []byte{1}}
+	receipts := types.Receipts{
+		&types.Receipt{Status: types.ReceiptStatusSuccessful, Logs: []*types.Log{}},
+		&types.Receipt{Status: types.ReceiptStatusSuccessful, Logs: []*types.Log{event}},
+	}
+	proof, err := proveReceipt(receipts, 1)
+	if err != nil {
+		t.Fatalf("failed to prove receipt: %v", err)
+	}
+	newMessage := func(keys []*ecdsa.PrivateKey) *Message {
+		header := &types.Header{Number: big.NewInt(1), ReceiptHash: types.DeriveSha(receipts)}
+		sealHeader(header, validators, keys)
+		return &Message{
+			SourceChain: big.NewInt(1),
+			DestChain:   big.NewInt(2),
+			Log:         &types.Log{Address: event.Address, Topics: event.Topics, Data: event.Data},
+			Proof:       &FinalityProof{Header: header, TxIndex: 1, Receipt: proof},
+		}
+	}
+	if err := Verify(newMessage(keys[:3]), validators, nil); err != nil {
+		t.Fatalf("failed to verify quorum sealed message: %v", err)
+	}
+	if err := Verify(newMessage(keys[:2]), validators, nil); err != errInsufficientSeals {
+		t.Errorf("minority seal error mismatch: have %v, want %v", err, errInsufficientSeals)
+	}
+	if err := Verify(newMessage([]*ecdsa.PrivateKey{keys[0], keys[1], keys[4]}), validators, nil); err != errUnknownSealer {
+		t.Errorf("foreign seal error mismatch: have %v, want %v", err, errUnknownSealer)
+	}
+	if err := Verify(newMessage([]*ecdsa.PrivateKey{keys[0], keys[1], keys[0]}), validators, nil); err != errUnknownSealer {
+		t.Errorf("duplicate seal error mismatch: have %v, want %v", err, errUnknownSealer)
+	}
+	msg := newMessage(keys[:3])
+	msg.Log.Data = []byte{2}
+	if err := Verify(msg, validators, nil); err != errLogMismatch {
+		t.Errorf("forged event error mismatch: have %v, want %v", err, errLogMismatch)
+	}
+	msg = newMessage(keys[:3])
+	msg.Proof.TxIndex = 0
+	if err := Verify(msg, validators, nil); err == nil {
+		t.Errorf("mismatching receipt proof accepted")
+	}
+	// Messages must be bound to both chains
+	if newMessage(keys[:3]).ID() != newMessage(keys[1:4]).ID() {
+		t.Errorf("message ID depends on the committed seals")
+	}
+	msg = newMessage(keys[:3])
+	msg.DestChain = big.NewInt(3)
+	if msg.ID() == newMessage(keys[:3]).ID() {
+		t.Errorf("message ID independent of the destination chain")
+	}
+}
+
+// Tests that messages from blocks sealed by a threshold signature verify against
+// the threshold configuration, with the signer bitmap proven by the aggregate.
+func TestVerifyThreshold(t *testing.T) {
+	dealing, err := bls.NewDealing(nil, 3, 4)
+	if err != nil {
+		t.Fatalf("failed to deal key shares: %v", err)
+	}
+	config := &params.IstanbulThresholdConfig{
+		Block:     big.NewInt(1),
+		Threshold: 3,
+		GroupKey:  dealing.Commitments[0].Marshal(),
+	}
+	for _, share := range dealing.Shares {
+		key, _ := crypto.GenerateKey()
+		config.Members = append(config.Members, crypto.PubkeyToAddress(key.PublicKey))
+		config.PublicShares = append(config.PublicShares, share.Public().Marshal())
+	}
+	receipts := types.Receipts{&types.Receipt{Status: types.ReceiptStatusSuccessful, Logs: []*types.Log{{Address: bridgeContract}}}}
+	proof, err := proveReceipt(receipts, 0)
+	if err != nil {
+		t.Fatalf("failed to prove receipt: %v", err)
+	}
+	// newMessage seals the message with the shares of the signers, marking the
+	// claimed members in the bitmap
+	newMessage := func(signers []int, claimed byte) *Message {
+		header := &types.Header{Number: big.NewInt(1), ReceiptHash: types.DeriveSha(receipts), MixDigest: types.IstanbulDigest}
+		extra := &types.IstanbulExtra{Validators: config.Members}
+		blob, _ := rlp.EncodeToBytes(extra)
+		header.Extra = append(make([]byte, types.IstanbulExtraVanity), blob...)
+
+		proposal := istanbulCore.PrepareCommittedSeal(header.Hash())
+		var (
+			partials []*bls.PartialSignature
+			sigs     []*bls.Signature
+		)
+		for _, signer := range signers {
+			sig := dealing.Shares[signer].Sign(proposal)
+			partials = append(partials, &bls.PartialSignature{Index: uint64(signer + 1), Signature: sig})
+			sigs = append(sigs, sig)
+		}
+		sig, err := bls.Combine(partials, 3)
+		if err != nil {
+			t.Fatalf("failed to combine partial seals: %v", err)
+		}
+		extra.CommittedSeal = [][]byte{sig.Marshal(), {claimed}, bls.AggregateSignatures(sigs).Marshal()}
+		blob, _ = rlp.EncodeToBytes(extra)
+		header.Extra = append(make([]byte, types.IstanbulExtraVanity), blob...)
+
+		return &Message{
+			Log:   &types.Log{Address: bridgeContract},
+			Proof: &FinalityProof{Header: header, Receipt: proof},
+		}
+	}
+	if err := Verify(newMessage([]int{0, 1, 2}, 0x07), nil, config); err != nil {
+		t.Fatalf("failed to verify threshold sealed message: %v", err)
+	}
+	if err := Verify(newMessage([]int{0, 1, 2}, 0x0f), nil, config); err != istanbulBackend.ErrInvalidThresholdSeal {
+		t.Errorf("claimed sealer error mismatch: have %v, want %v", err, istanbulBackend.ErrInvalidThresholdSeal)
+	}
+	if err := Verify(newMessage([]int{0, 1, 2}, 0x03), nil, config); err != istanbulBackend.ErrInvalidThresholdSeal {
+		t.Errorf("minority bitmap error mismatch: have %v, want %v", err, istanbulBackend.ErrInvalidThresholdSeal)
+	}
+	// Before the activation the individual seals are required
+	if err := Verify(newMessage([]int{0, 1, 2}, 0x07), config.Members, &params.IstanbulThresholdConfig{Block: big.NewInt(2)}); err == nil {
+		t.Errorf("threshold seal accepted before the activation")
+	}
+}
+
+// Tests that the abi format wraps the message into a relay(bytes) call.
+func TestABIFormat(t *testing.T) {
+	msg := &Message{
+		SourceChain: big.NewInt(1),
+		DestChain:   big.NewInt(2),
+		Log:         &types.Log{Address: bridgeContract, Topics: []common.Hash{lockEvent}},
+		Proof:       &FinalityProof{Header: &types.Header{Number: big.NewInt(1)}},
+	}
+	payload, err := formats["abi"].Encode(msg)
+	if err != nil {
+		t.Fatalf("failed to encode message: %v", err)
+	}
+	if !bytes.Equal(payload[:4], relayMethods.Methods["relay"].Id()) {
+		t.Errorf("method selector mismatch: have %x, want %x", payload[:4], relayMethods.Methods["relay"].Id())
+	}
+	blob, _ := rlp.EncodeToBytes(msg)
+	if size := new(big.Int).SetBytes(payload[36:68]); size.Uint64() != uint64(len(blob)) || !bytes.Equal(payload[68:68+len(blob)], blob) {
+		t.Errorf("message argument mismatch")
+	}
+}
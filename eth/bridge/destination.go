@@ -0,0 +1,155 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package bridge
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"net/url"
+	"sync"
+	"time"
+
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// submitTimeout is the maximum time to wait for the destination chain to accept
+// a submission.
+const submitTimeout = 30 * time.Second
+
+var errNoAccount = errors.New("bridge account not configured")
+
+// SignTxFn signs a transaction of the bridge account.
+type SignTxFn func(tx *types.Transaction, chainID *big.Int) (*types.Transaction, error)
+
+// RPCDestination submits messages as transactions of the bridge account through
+// the RPC endpoint of the destination chain. The transactions are signed locally,
+// the account doesn't have to be known to the destination node.
+type RPCDestination struct {
+	endpoint string
+	contract common.Address
+	chainID  *big.Int
+	account  common.Address
+	gasLimit uint64
+	gasPrice *big.Int
+	sign     SignTxFn
+
+	client *rpc.Client // Connection to the destination, nil until first needed
+	lock   sync.Mutex  // Serialises submissions, guarding the nonces
+}
+
+// NewRPCDestination creates a destination submitting to the endpoint and bridge
+// contract configured, signing its transactions with the given function.
+func NewRPCDestination(config *Config, sign SignTxFn) (*RPCDestination, error) {
+	if config.Account == (common.Address{}) {
+		return nil, errNoAccount
+	}
+	if config.ChainID == nil {
+		return nil, errors.New("destination chain ID not configured")
+	}
+	return &RPCDestination{
+		endpoint: config.Endpoint,
+		contract: config.Destination,
+		chainID:  config.ChainID,
+		account:  config.Account,
+		gasLimit: config.GasLimit,
+		gasPrice: config.GasPrice,
+		sign:     sign,
+	}, nil
+}
+
+// Name implements Destination.
+func (d *RPCDestination) Name() string {
+	u, err := url.Parse(d.endpoint)
+	if err != nil || u.User == nil {
+		return d.endpoint
+	}
+	u.User = nil
+	return u.String()
+}
+
+// Submit implements Destination, sending a transaction calling the destination
+// contract with the payload. Connection failures drop the client to redial the
+// endpoint on the next submission.
+func (d *RPCDestination) Submit(payload []byte) (common.Hash, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if d.client == nil {
+		client, err := rpc.Dial(d.endpoint)
+		if err != nil {
+			return common.Hash{}, err
+		}
+		d.client = client
+	}
+	hash, err := d.submit(ethclient.NewClient(d.client), payload)
+	if err != nil {
+		if _, ok := err.(rpc.Error); !ok {
+			d.client.Close()
+			d.client = nil
+		}
+		return common.Hash{}, err
+	}
+	return hash, nil
+}
+
+// submit signs and sends a transaction carrying the payload.
+func (d *RPCDestination) submit(client *ethclient.Client, payload []byte) (common.Hash, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), submitTimeout)
+	defer cancel()
+
+	nonce, err := client.PendingNonceAt(ctx, d.account)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	gasPrice := d.gasPrice
+	if gasPrice == nil {
+		if gasPrice, err = client.SuggestGasPrice(ctx); err != nil {
+			return common.Hash{}, err
+		}
+	}
+	gas := d.gasLimit
+	if gas == 0 {
+		msg := ethereum.CallMsg{From: d.account, To: &d.contract, GasPrice: gasPrice, Data: payload}
+		if gas, err = client.EstimateGas(ctx, msg); err != nil {
+			return common.Hash{}, err
+		}
+	}
+	tx, err := d.sign(types.NewTransaction(nonce, d.contract, new(big.Int), gas, gasPrice, payload), d.chainID)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	if err := client.SendTransaction(ctx, tx); err != nil {
+		return common.Hash{}, err
+	}
+	return tx.Hash(), nil
+}
+
+// Close terminates the connection to the destination, if any.
+func (d *RPCDestination) Close() {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if d.client != nil {
+		d.client.Close()
+		d.client = nil
+	}
+}
@@ -0,0 +1,74 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package bridge
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// Format encodes relayed messages into the call data of the transactions sent
+// to the bridge contract of the destination chain.
+type Format interface {
+	Encode(msg *Message) ([]byte, error)
+}
+
+// formats contains the message formats available to the bridge, keyed by the
+// name they are registered under.
+var formats = map[string]Format{
+	"rlp": rlpFormat{},
+	"abi": abiFormat{},
+}
+
+// RegisterFormat makes a message format available for the bridge to use under
+// the given name. It is meant to be called from the init function of the package
+// implementing the format, and panics on duplicate names.
+func RegisterFormat(name string, format Format) {
+	if _, ok := formats[name]; ok {
+		panic(fmt.Sprintf("bridge message format %q already registered", name))
+	}
+	formats[name] = format
+}
+
+// rlpFormat sends the RLP encoding of messages as raw call data, for contracts
+// handling messages in their fallback function.
+type rlpFormat struct{}
+
+func (rlpFormat) Encode(msg *Message) ([]byte, error) {
+	return rlp.EncodeToBytes(msg)
+}
+
+// relayABI is the interface of the bridge contracts accepting the RLP encoding
+// of messages through a regular method call.
+const relayABI = `[{"type":"function","name":"relay","inputs":[{"name":"message","type":"bytes"}],"outputs":[]}]`
+
+var relayMethods, _ = abi.JSON(strings.NewReader(relayABI))
+
+// abiFormat sends messages as calls to relay(bytes), the argument being the RLP
+// encoding of the message.
+type abiFormat struct{}
+
+func (abiFormat) Encode(msg *Message) ([]byte, error) {
+	blob, err := rlp.EncodeToBytes(msg)
+	if err != nil {
+		return nil, err
+	}
+	return relayMethods.Pack("relay", blob)
+}
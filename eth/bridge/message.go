@@ -0,0 +1,187 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package bridge
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/istanbul"
+	istanbulBackend "github.com/ethereum/go-ethereum/consensus/istanbul/backend"
+	istanbulCore "github.com/ethereum/go-ethereum/consensus/istanbul/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+var (
+	errInsufficientSeals = errors.New("insufficient committed seals")
+	errUnknownSealer     = errors.New("committed seal of unknown validator")
+	errLogMismatch       = errors.New("event not in proven receipt")
+)
+
+// Message is a lock or burn event of the source chain, packaged along with the
+// proof of its finality for the destination chain.
+type Message struct {
+	SourceChain *big.Int       // Chain ID of the chain the event was emitted on
+	DestChain   *big.Int       // Chain ID of the chain the event is relayed to
+	Log         *types.Log     // Event being relayed (consensus fields only)
+	Proof       *FinalityProof // Proof of the event being final on the source chain
+}
+
+// FinalityProof proves an event to be part of a final block: the header of the
+// block carries the istanbul commit seals of the validators, and the receipt of
+// the emitting transaction is proven against the receipt root of the header.
+type FinalityProof struct {
+	Header   *types.Header // Sealed header of the block containing the event
+	TxIndex  uint          // Index of the emitting transaction in the block
+	LogIndex uint          // Index of the event in the logs of the transaction
+	Receipt  [][]byte      // Merkle proof of the receipt, in root to leaf order
+}
+
+// ID returns the unique identifier of the message, which the destination chain
+// uses to reject replayed messages. It commits to both chains, so a message can
+// neither be replayed on the source chain nor on third chains.
+func (m *Message) ID() common.Hash {
+	blob, _ := rlp.EncodeToBytes([]interface{}{
+		m.SourceChain,
+		m.DestChain,
+		m.Proof.Header.Hash(),
+		m.Proof.TxIndex,
+		m.Proof.LogIndex,
+	})
+	return crypto.Keccak256Hash(blob)
+}
+
+// Verify checks that the event of a message is part of a block sealed by a
+// quorum of the given validators, which must be the validator set the block was
+// proposed to. If the source chain seals with threshold signatures, its threshold
+// configuration must be given to verify blocks sealed after their activation.
+func Verify(msg *Message, validators []common.Address, threshold *params.IstanbulThresholdConfig) error {
+	if msg.Log == nil || msg.Proof == nil || msg.Proof.Header == nil {
+		return errors.New("incomplete message")
+	}
+	if err := verifySeals(msg.Proof.Header, validators, threshold); err != nil {
+		return err
+	}
+	receipt, err := verifyReceipt(msg.Proof)
+	if err != nil {
+		return err
+	}
+	if msg.Proof.LogIndex >= uint(len(receipt.Logs)) {
+		return errLogMismatch
+	}
+	proven := receipt.Logs[msg.Proof.LogIndex]
+	if proven.Address != msg.Log.Address || !bytes.Equal(proven.Data, msg.Log.Data) || len(proven.Topics) != len(msg.Log.Topics) {
+		return errLogMismatch
+	}
+	for i, topic := range proven.Topics {
+		if msg.Log.Topics[i] != topic {
+			return errLogMismatch
+		}
+	}
+	return nil
+}
+
+// verifySeals checks that more than two thirds of the validators committed to
+// the header, mirroring the quorum the istanbul engine enforces.
+func verifySeals(header *types.Header, validators []common.Address, threshold *params.IstanbulThresholdConfig) error {
+	extra, err := types.ExtractIstanbulExtra(header)
+	if err != nil {
+		return err
+	}
+	if threshold.IsActive(header.Number) {
+		return istanbulBackend.VerifyThresholdSeal(threshold, header.Hash(), extra.CommittedSeal)
+	}
+	pending := make(map[common.Address]bool, len(validators))
+	for _, addr := range validators {
+		pending[addr] = true
+	}
+	proposal := istanbulCore.PrepareCommittedSeal(header.Hash())
+
+	sealed := 0
+	for _, seal := range extra.CommittedSeal {
+		addr, err := istanbul.GetSignatureAddress(proposal, seal)
+		if err != nil {
+			return err
+		}
+		// Every validator may seal only once, duplicates are rejected too
+		if !pending[addr] {
+			return errUnknownSealer
+		}
+		delete(pending, addr)
+		sealed++
+	}
+	faulty := int(math.Ceil(float64(len(validators))/3)) - 1
+	if sealed <= 2*faulty || sealed == 0 {
+		return errInsufficientSeals
+	}
+	return nil
+}
+
+// verifyReceipt checks the Merkle proof of a receipt against the receipt root
+// of the proven header, returning the receipt.
+func verifyReceipt(proof *FinalityProof) (*types.Receipt, error) {
+	db, _ := ethdb.NewMemDatabase()
+	for _, node := range proof.Receipt {
+		db.Put(crypto.Keccak256(node), node)
+	}
+	key, _ := rlp.EncodeToBytes(proof.TxIndex)
+	blob, err, _ := trie.VerifyProof(proof.Header.ReceiptHash, key, db)
+	if err != nil {
+		return nil, fmt.Errorf("invalid receipt proof: %v", err)
+	}
+	if blob == nil {
+		return nil, errors.New("receipt not in block")
+	}
+	receipt := new(types.Receipt)
+	if err := rlp.DecodeBytes(blob, receipt); err != nil {
+		return nil, err
+	}
+	return receipt, nil
+}
+
+// proveReceipt creates the Merkle proof of a receipt of a block.
+func proveReceipt(receipts types.Receipts, index uint) ([][]byte, error) {
+	tr := new(trie.Trie)
+	for i := 0; i < receipts.Len(); i++ {
+		key, _ := rlp.EncodeToBytes(uint(i))
+		tr.Update(key, receipts.GetRlp(i))
+	}
+	key, _ := rlp.EncodeToBytes(index)
+
+	var proof proofList
+	if err := tr.Prove(key, 0, &proof); err != nil {
+		return nil, err
+	}
+	return proof, nil
+}
+
+// proofList collects the trie nodes of a Merkle proof, in root to leaf order.
+type proofList [][]byte
+
+func (n *proofList) Put(key []byte, value []byte) error {
+	*n = append(*n, value)
+	return nil
+}
@@ -31,6 +31,8 @@ import (
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/eth/downloader"
 	"github.com/ethereum/go-ethereum/eth/gasprice"
+	"github.com/ethereum/go-ethereum/internal/ethapi"
+	"github.com/ethereum/go-ethereum/miner"
 	"github.com/ethereum/go-ethereum/params"
 )
 
@@ -44,12 +46,14 @@ var DefaultConfig = Config{
 		DatasetsInMem:  1,
 		DatasetsOnDisk: 2,
 	},
-	NetworkId:     1,
-	LightPeers:    100,
-	DatabaseCache: 768,
-	TrieCache:     256,
-	TrieTimeout:   5 * time.Minute,
-	GasPrice:      big.NewInt(18 * params.Shannon),
+	NetworkId:      1,
+	LightPeers:     100,
+	DatabaseCache:  768,
+	TrieCache:      256,
+	TrieCleanCache: 256,
+	TrieTimeout:    5 * time.Minute,
+	GasPrice:       big.NewInt(18 * params.Shannon),
+	RPCEVMTimeout:  5 * time.Second,
 
 	TxPool: core.DefaultTxPoolConfig,
 	GPO: gasprice.Config{
@@ -86,6 +90,14 @@ type Config struct {
 	SyncMode  downloader.SyncMode
 	NoPruning bool
 
+	// ReceiptsRetention is the number of recent blocks to keep the receipts of,
+	// older ones are pruned in the background (0 = keep all).
+	ReceiptsRetention uint64 `toml:",omitempty"`
+
+	// Indexes lists the secondary chain indexes to maintain ("addresses",
+	// "transfers"), the latter requiring NoPruning.
+	Indexes []string `toml:",omitempty"`
+
 	// Light client options
 	LightServ  int `toml:",omitempty"` // Maximum percentage of time allowed for serving LES requests
 	LightPeers int `toml:",omitempty"` // Maximum number of LES client peers
@@ -95,19 +107,31 @@ type Config struct {
 	DatabaseHandles    int  `toml:"-"`
 	DatabaseCache      int
 	TrieCache          int
+	TrieCleanCache     int
 	TrieTimeout        time.Duration
+	AsyncIndexing      bool `toml:",omitempty"` // Write transaction lookups in the background
+
+	// BlockCompression is the compression of the block bodies and receipts
+	// written into the database (process wide). Existing entries stay readable
+	// whatever the setting, `geth db compress` converts them.
+	BlockCompression core.Compression `toml:",omitempty"`
+
+	// Block processing options
+	ParallelTxs int `toml:",omitempty"` // Number of transactions of imported blocks to execute in parallel (0 = serial)
 
 	// Mining-related options
 	Etherbase    common.Address `toml:",omitempty"`
 	MinerThreads int            `toml:",omitempty"`
 	ExtraData    []byte         `toml:",omitempty"`
 	GasPrice     *big.Int
+	TxOrdering   miner.TxOrdering `toml:",omitempty"` // Policy ordering the transactions of sealed blocks (empty = by price)
 
 	// Ethash options
 	Ethash ethash.Config
 
 	// Transaction pool options
-	TxPool core.TxPoolConfig
+	TxPool      core.TxPoolConfig
+	TxBroadcast TxBroadcastConfig
 
 	// Gas Price Oracle options
 	GPO gasprice.Config
@@ -115,6 +139,16 @@ type Config struct {
 	// Enables tracking of SHA3 preimages in the VM
 	EnablePreimageRecording bool
 
+	// RPC execution limits
+	RPCGasCap         uint64        `toml:",omitempty"` // Maximum gas of calls executed via RPC (0 = no cap)
+	RPCEVMTimeout     time.Duration // Maximum execution time of calls and traces executed via RPC (0 = no timeout)
+	RPCLogsBlockRange uint64        `toml:",omitempty"` // Maximum number of blocks a single log query may span (0 = unlimited)
+	RPCLogsMaxResults int           `toml:",omitempty"` // Maximum number of logs a single log query may return (0 = unlimited)
+	RPCUnlockTimeout  time.Duration `toml:",omitempty"` // Maximum duration accounts stay unlocked via RPC (0 = unlimited)
+
+	// Multi-signature approval of the transactions of guarded accounts
+	TxApproval ethapi.ApprovalConfig
+
 	// Istanbul options
 	Istanbul istanbul.Config
 
@@ -122,6 +156,17 @@ type Config struct {
 	DocRoot string `toml:"-"`
 }
 
+// TxBroadcastConfig is the policy of propagating transactions to peers.
+type TxBroadcastConfig struct {
+	// ValidatorsOnly restricts propagation to peers that are validators of the
+	// BFT consensus engine, as only they build blocks in permissioned networks.
+	ValidatorsOnly bool `toml:",omitempty"`
+
+	// SqrtFanout propagates each transaction to the square root of the eligible
+	// peers instead of all of them.
+	SqrtFanout bool `toml:",omitempty"`
+}
+
 type configMarshaling struct {
 	ExtraData hexutil.Bytes
 }
@@ -81,6 +81,7 @@ var (
 	errInvalidBlock            = errors.New("retrieved block is invalid")
 	errInvalidBody             = errors.New("retrieved block body is invalid")
 	errInvalidReceipt          = errors.New("retrieved receipt is invalid")
+	errInvalidPivot            = errors.New("retrieved pivot block is not final")
 	errCancelBlockFetch        = errors.New("block download canceled (requested)")
 	errCancelHeaderFetch       = errors.New("block header download canceled (requested)")
 	errCancelBodyFetch         = errors.New("block body download canceled (requested)")
@@ -113,7 +114,8 @@ type Downloader struct {
 	blockchain BlockChain
 
 	// Callbacks
-	dropPeer peerDropFn // Drops a peer for misbehaving
+	dropPeer    peerDropFn                // Drops a peer for misbehaving
+	verifyPivot func(*types.Header) error // Checks the finality proof of a fast sync pivot (nil = any)
 
 	// Status
 	synchroniseMock func(id string, hash common.Hash) error // Replacement for synchronise during testing
@@ -310,6 +312,15 @@ func (d *Downloader) UnregisterPeer(id string) error {
 	return nil
 }
 
+// SetPivotVerifier sets a callback to check the finality proof of every fast sync
+// pivot before its state is downloaded. Pivots failing the check abort the sync
+// and get the peer dropped. This is needed for engines with explicit finality
+// (e.g. Istanbul), where only a block carrying a quorum of commit seals may be
+// trusted without executing the chain up to it.
+func (d *Downloader) SetPivotVerifier(verify func(*types.Header) error) {
+	d.verifyPivot = verify
+}
+
 // Synchronise tries to sync up our local block chain with a remote peer, both
 // adding various sanity checks as well as wrapping it with various log entries.
 func (d *Downloader) Synchronise(id string, head common.Hash, td *big.Int, mode SyncMode) error {
@@ -320,7 +331,7 @@ func (d *Downloader) Synchronise(id string, head common.Hash, td *big.Int, mode
 
 	case errTimeout, errBadPeer, errStallingPeer,
 		errEmptyHeaderSet, errPeersUnavailable, errTooOld,
-		errInvalidAncestor, errInvalidChain:
+		errInvalidAncestor, errInvalidChain, errInvalidPivot:
 		log.Warn("Synchronisation failed, dropping peer", "peer", id, "err", err)
 		if d.dropPeer == nil {
 			// The dropPeer method is nil when `--copydb` is used for a local copy.
@@ -1419,6 +1430,12 @@ func (d *Downloader) processFastSyncContent(latest *types.Header) error {
 		if P != nil {
 			// If new pivot block found, cancel old state retrieval and restart
 			if oldPivot != P {
+				if d.verifyPivot != nil {
+					if err := d.verifyPivot(P.Header); err != nil {
+						log.Warn("Rejected fast sync pivot", "number", P.Header.Number, "hash", P.Header.Hash(), "err", err)
+						return errInvalidPivot
+					}
+				}
 				stateSync.Cancel()
 
 				stateSync = d.syncState(P.Header.Root)
@@ -685,6 +685,44 @@ func testCanonicalSynchronisation(t *testing.T, protocol int, mode SyncMode) {
 	assertOwnChain(t, tester, targetBlocks+1)
 }
 
+// Tests that fast sync only commits pivot blocks accepted by the pivot verifier.
+func TestPivotVerification63(t *testing.T) { testPivotVerification(t, 63) }
+func TestPivotVerification64(t *testing.T) { testPivotVerification(t, 64) }
+
+func testPivotVerification(t *testing.T, protocol int) {
+	t.Parallel()
+
+	tester := newTester()
+	defer tester.terminate()
+
+	targetBlocks := blockCacheItems - 15
+	hashes, headers, blocks, receipts := tester.makeChain(targetBlocks, 0, tester.genesis, nil, false)
+
+	// Reject every pivot and ensure the sync fails before touching the state
+	var verified []uint64
+	tester.downloader.SetPivotVerifier(func(header *types.Header) error {
+		verified = append(verified, header.Number.Uint64())
+		return errors.New("not final")
+	})
+	tester.newPeer("peer", protocol, hashes, headers, blocks, receipts)
+	if err := tester.sync("peer", nil, FastSync); err != errInvalidPivot {
+		t.Fatalf("sync error mismatch: have %v, want %v", err, errInvalidPivot)
+	}
+	if len(verified) != 1 || verified[0] != uint64(targetBlocks-fsMinFullBlocks) {
+		t.Fatalf("verified pivots mismatch: have %v, want [%d]", verified, targetBlocks-fsMinFullBlocks)
+	}
+	if head := tester.CurrentBlock().NumberU64(); head != 0 {
+		t.Errorf("head block advanced past unverified pivot: %d", head)
+	}
+	// Accept the pivots and ensure the sync goes through
+	tester.downloader.SetPivotVerifier(func(header *types.Header) error { return nil })
+	tester.newPeer("peer", protocol, hashes, headers, blocks, receipts)
+	if err := tester.sync("peer", nil, FastSync); err != nil {
+		t.Fatalf("failed to synchronise blocks: %v", err)
+	}
+	assertOwnChain(t, tester, targetBlocks+1)
+}
+
 // Tests that if a large batch of blocks are being downloaded, it is throttled
 // until the cached blocks are retrieved.
 func TestThrottling62(t *testing.T)     { testThrottling(t, 62, FullSync) }
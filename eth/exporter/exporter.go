@@ -0,0 +1,379 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package exporter pushes the logs of finalized blocks to external sinks.
+//
+// Logs are delivered at least once: a block is only marked exported after all
+// its matching logs have been accepted by every sink, so a sink may see the same
+// logs again after a failure or restart, but never misses any. The position in
+// the chain is persisted in the database to resume from on restart.
+package exporter
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+const (
+	// chainHeadChanSize is the size of channel listening to ChainHeadEvent.
+	chainHeadChanSize = 10
+
+	// retryInterval is the time to wait before retrying a failed delivery.
+	retryInterval = 5 * time.Second
+)
+
+// dbKeyCursor is the database key of the next block to export.
+var dbKeyCursor = []byte("log-exporter")
+
+var errNoSinks = errors.New("no export sinks configured")
+
+// Config contains the settings of the log exporter.
+type Config struct {
+	Webhook    string           // HTTP endpoint to POST the logs of each block to (empty = disabled)
+	Kafka      string           // Kafka REST proxy to produce the logs through (empty = disabled)
+	KafkaTopic string           // Kafka topic to produce the logs into
+	Addresses  []common.Address // Contracts to export the logs of (empty = all)
+	Topics     [][]common.Hash  // Topic filter, positional as in eth_getLogs (empty = all)
+	From       uint64           // First block to export if no progress is stored
+}
+
+// Sink is an external system the logs of finalized blocks are pushed to.
+type Sink interface {
+	// Name returns a descriptive name of the sink for logging.
+	Name() string
+
+	// Push delivers the matching logs of a block. The block is only marked as
+	// exported once all sinks returned successfully.
+	Push(block *types.Block, logs []*types.Log) error
+}
+
+// Service exports the logs of finalized blocks into external sinks.
+type Service struct {
+	config *Config
+	chain  *core.BlockChain
+	db     ethdb.Database
+	sinks  []Sink
+
+	next    uint64 // Next block to export
+	lastErr error  // Last delivery failure, nil if the last delivery succeeded
+	lock    sync.RWMutex
+
+	update chan struct{}
+	quit   chan struct{}
+	wg     sync.WaitGroup
+}
+
+// New creates a service exporting the logs of the chain into the sinks set up
+// in the config.
+func New(config *Config, chain *core.BlockChain, db ethdb.Database) (*Service, error) {
+	var sinks []Sink
+	if config.Webhook != "" {
+		sinks = append(sinks, NewWebhookSink(config.Webhook))
+	}
+	if config.Kafka != "" {
+		if config.KafkaTopic == "" {
+			return nil, errors.New("kafka topic not configured")
+		}
+		sinks = append(sinks, NewKafkaSink(config.Kafka, config.KafkaTopic))
+	}
+	return NewWithSinks(config, chain, db, sinks...)
+}
+
+// NewWithSinks creates a service exporting the logs of the chain into the given
+// sinks. The sink settings of the config are ignored.
+func NewWithSinks(config *Config, chain *core.BlockChain, db ethdb.Database, sinks ...Sink) (*Service, error) {
+	if len(sinks) == 0 {
+		return nil, errNoSinks
+	}
+	next := config.From
+	if blob, err := db.Get(dbKeyCursor); err == nil {
+		if len(blob) != 8 {
+			return nil, fmt.Errorf("corrupt exporter cursor: %x", blob)
+		}
+		next = binary.BigEndian.Uint64(blob)
+	}
+	return &Service{
+		config: config,
+		chain:  chain,
+		db:     db,
+		sinks:  sinks,
+		next:   next,
+		update: make(chan struct{}, 1),
+		quit:   make(chan struct{}),
+	}, nil
+}
+
+// Protocols implements node.Service, returning the P2P network protocols used
+// by the exporter (nil as it doesn't use the devp2p overlay network).
+func (s *Service) Protocols() []p2p.Protocol { return nil }
+
+// APIs implements node.Service, returning the RPC API endpoints provided by the
+// exporter.
+func (s *Service) APIs() []rpc.API {
+	return []rpc.API{{
+		Namespace: "exporter",
+		Version:   "1.0",
+		Service:   &PublicExporterAPI{s},
+		Public:    true,
+	}}
+}
+
+// Start implements node.Service, starting to export the chain.
+func (s *Service) Start(server *p2p.Server) error {
+	if _, ok := s.chain.Engine().(consensus.FinalityVerifier); !ok {
+		log.Warn("Consensus engine without finality, log exporter disabled")
+		return nil
+	}
+	s.wg.Add(2)
+	go s.loop()
+	go s.exporter()
+
+	names := make([]string, len(s.sinks))
+	for i, sink := range s.sinks {
+		names[i] = sink.Name()
+	}
+	log.Info("Started log exporter", "sinks", names, "next", s.cursor())
+	return nil
+}
+
+// Stop implements node.Service, terminating the export.
+func (s *Service) Stop() error {
+	close(s.quit)
+	s.wg.Wait()
+
+	log.Info("Log exporter stopped", "next", s.cursor())
+	return nil
+}
+
+// loop notifies the exporter of chain head changes. Deliveries may take long,
+// so they are done on a separate goroutine not to block the chain event feed.
+func (s *Service) loop() {
+	defer s.wg.Done()
+
+	headCh := make(chan core.ChainHeadEvent, chainHeadChanSize)
+	sub := s.chain.SubscribeChainHeadEvent(headCh)
+	defer sub.Unsubscribe()
+
+	s.notify()
+	for {
+		select {
+		case <-headCh:
+			s.notify()
+		case <-sub.Err():
+			return
+		case <-s.quit:
+			return
+		}
+	}
+}
+
+// notify signals the exporter that new blocks might be available.
+func (s *Service) notify() {
+	select {
+	case s.update <- struct{}{}:
+	default:
+	}
+}
+
+// exporter delivers every finalized block not yet exported, retrying failed
+// deliveries until they succeed.
+func (s *Service) exporter() {
+	defer s.wg.Done()
+
+	retry := time.NewTimer(0)
+	<-retry.C
+	defer retry.Stop()
+
+	for {
+		select {
+		case <-s.update:
+		case <-retry.C:
+		case <-s.quit:
+			return
+		}
+		if err := s.export(); err != nil {
+			log.Warn("Failed to export logs", "block", s.cursor(), "err", err)
+			retry.Reset(retryInterval)
+		}
+	}
+}
+
+// export delivers the logs of all finalized blocks from the cursor onwards,
+// stopping at the first failure.
+func (s *Service) export() error {
+	defer s.store()
+
+	for s.finalized(s.cursor()) {
+		select {
+		case <-s.quit:
+			return nil
+		default:
+		}
+		if err := s.exportBlock(s.cursor()); err != nil {
+			s.lock.Lock()
+			s.lastErr = err
+			s.lock.Unlock()
+			return err
+		}
+	}
+	return nil
+}
+
+// exportBlock pushes the matching logs of a block into all sinks, and advances
+// the cursor if all of them accepted it.
+func (s *Service) exportBlock(number uint64) error {
+	block := s.chain.GetBlockByNumber(number)
+	if block == nil {
+		return fmt.Errorf("block #%d missing", number)
+	}
+	logs := s.filter(core.GetBlockReceipts(s.db, block.Hash(), number))
+	if len(logs) > 0 {
+		for _, sink := range s.sinks {
+			if err := sink.Push(block, logs); err != nil {
+				return fmt.Errorf("%s: %v", sink.Name(), err)
+			}
+		}
+		log.Debug("Exported block logs", "number", number, "hash", block.Hash(), "logs", len(logs))
+	}
+	s.lock.Lock()
+	s.next, s.lastErr = number+1, nil
+	s.lock.Unlock()
+
+	// Persist the cursor immediately after a delivery to avoid duplicates, the
+	// progress over empty blocks is stored in one go at the end of a run
+	if len(logs) > 0 {
+		s.store()
+	}
+	return nil
+}
+
+// filter returns the logs of the receipts matching the configured addresses and
+// topics.
+func (s *Service) filter(receipts types.Receipts) []*types.Log {
+	var logs []*types.Log
+	for _, receipt := range receipts {
+		for _, l := range receipt.Logs {
+			if s.matches(l) {
+				logs = append(logs, l)
+			}
+		}
+	}
+	return logs
+}
+
+// matches checks whether a log passes the address and topic filters. An empty
+// topic position matches any topic, otherwise any of the listed ones.
+func (s *Service) matches(l *types.Log) bool {
+	if len(s.config.Addresses) > 0 {
+		found := false
+		for _, addr := range s.config.Addresses {
+			if l.Address == addr {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if len(s.config.Topics) > len(l.Topics) {
+		return false
+	}
+	for i, topics := range s.config.Topics {
+		if len(topics) == 0 {
+			continue
+		}
+		found := false
+		for _, topic := range topics {
+			if l.Topics[i] == topic {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// finalized checks whether the given canonical block is final.
+func (s *Service) finalized(number uint64) bool {
+	header := s.chain.GetHeaderByNumber(number)
+	if header == nil {
+		return false
+	}
+	return s.chain.Engine().(consensus.FinalityVerifier).VerifyFinality(s.chain, header) == nil
+}
+
+// cursor returns the next block to export.
+func (s *Service) cursor() uint64 {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return s.next
+}
+
+// store persists the export cursor.
+func (s *Service) store() {
+	blob := make([]byte, 8)
+	binary.BigEndian.PutUint64(blob, s.cursor())
+
+	if err := s.db.Put(dbKeyCursor, blob); err != nil {
+		log.Crit("Failed to store exporter cursor", "err", err)
+	}
+}
+
+// PublicExporterAPI provides access to the progress of the log exporter.
+type PublicExporterAPI struct {
+	s *Service
+}
+
+// ExporterStatus is the progress of the log exporter.
+type ExporterStatus struct {
+	Next  hexutil.Uint64 `json:"next"`
+	Sinks []string       `json:"sinks"`
+	Error string         `json:"error,omitempty"`
+}
+
+// Status returns the next block to be exported and the last delivery failure,
+// if the exporter is currently stuck.
+func (api *PublicExporterAPI) Status() *ExporterStatus {
+	api.s.lock.RLock()
+	defer api.s.lock.RUnlock()
+
+	status := &ExporterStatus{Next: hexutil.Uint64(api.s.next)}
+	for _, sink := range api.s.sinks {
+		status.Sinks = append(status.Sinks, sink.Name())
+	}
+	if api.s.lastErr != nil {
+		status.Error = api.s.lastErr.Error()
+	}
+	return status
+}
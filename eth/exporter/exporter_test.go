@@ -0,0 +1,184 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package exporter
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/consensus/consensustest"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+)
+
+var (
+	testKey, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313e5ec3bb4b4ea8b2a9")
+	testAddress = crypto.PubkeyToAddress(testKey.PublicKey)
+
+	topicEven = common.HexToHash("0x11")
+	topicOdd  = common.HexToHash("0x22")
+)
+
+// testSink records the logs pushed into it, failing on demand.
+type testSink struct {
+	logs []*types.Log
+	fail bool
+}
+
+func (s *testSink) Name() string { return "test" }
+
+func (s *testSink) Push(block *types.Block, logs []*types.Log) error {
+	if s.fail {
+		return errors.New("sink down")
+	}
+	s.logs = append(s.logs, logs...)
+	return nil
+}
+
+// newTestChain creates a chain where every block creates a contract emitting a
+// log with an alternating topic.
+func newTestChain(t *testing.T, engine consensus.Engine, blocks int) (*core.BlockChain, ethdb.Database) {
+	alloc := core.GenesisAlloc{testAddress: {Balance: big.NewInt(1000000000)}}
+	return consensustest.NewChain(t, engine, alloc, blocks, func(i int, b *core.BlockGen) {
+		topic := topicEven
+		if (i+1)%2 == 1 {
+			topic = topicOdd
+		}
+		// PUSH32 topic PUSH1 0 PUSH1 0 LOG1 STOP
+		code := append(append([]byte{0x7f}, topic[:]...), 0x60, 0x00, 0x60, 0x00, 0xa1, 0x00)
+		tx, _ := types.SignTx(types.NewContractCreation(b.TxNonce(testAddress), new(big.Int), 100000, new(big.Int), code), types.HomesteadSigner{}, testKey)
+		b.AddTx(tx)
+	})
+}
+
+// Tests that only the matching logs of final blocks are exported, and that the
+// cursor only advances over successful deliveries.
+func TestExport(t *testing.T) {
+	engine := &consensustest.FinalEngine{Engine: ethash.NewFaker(), Final: 6}
+	chain, db := newTestChain(t, engine, 10)
+	defer chain.Stop()
+
+	sink := &testSink{fail: true}
+	config := &Config{Topics: [][]common.Hash{{topicEven}}, From: 1}
+	s, err := NewWithSinks(config, chain, db, sink)
+	if err != nil {
+		t.Fatalf("failed to create exporter: %v", err)
+	}
+	// A failing sink must not advance the cursor
+	if err := s.export(); err == nil {
+		t.Fatalf("export succeeded into failing sink")
+	}
+	if next := s.cursor(); next != 2 {
+		t.Fatalf("cursor mismatch after failure: have #%d, want #2", next)
+	}
+	if status := (&PublicExporterAPI{s}).Status(); status.Error == "" {
+		t.Errorf("delivery failure not reported")
+	}
+	// Recovering sink must receive the logs of all final blocks
+	sink.fail = false
+	if err := s.export(); err != nil {
+		t.Fatalf("failed to export: %v", err)
+	}
+	if next := s.cursor(); next != 7 {
+		t.Fatalf("cursor mismatch: have #%d, want #7", next)
+	}
+	if len(sink.logs) != 3 {
+		t.Fatalf("exported log count mismatch: have %d, want 3", len(sink.logs))
+	}
+	for i, log := range sink.logs {
+		if want := uint64(2 * (i + 1)); log.BlockNumber != want || log.Topics[0] != topicEven {
+			t.Errorf("log %d: have block #%d topic %x, want block #%d topic %x", i, log.BlockNumber, log.Topics[0], want, topicEven)
+		}
+	}
+	if status := (&PublicExporterAPI{s}).Status(); status.Error != "" {
+		t.Errorf("stale delivery failure reported: %s", status.Error)
+	}
+	// Ensure the cursor is persisted across restarts
+	s, err = NewWithSinks(config, chain, db, sink)
+	if err != nil {
+		t.Fatalf("failed to recreate exporter: %v", err)
+	}
+	if next := s.cursor(); next != 7 {
+		t.Errorf("resumed cursor mismatch: have #%d, want #7", next)
+	}
+}
+
+// Tests that the logs of a block can be delivered to webhooks and through a
+// Kafka REST proxy.
+func TestSinks(t *testing.T) {
+	engine := &consensustest.FinalEngine{Engine: ethash.NewFaker(), Final: 1}
+	chain, db := newTestChain(t, engine, 1)
+	defer chain.Stop()
+
+	block := chain.GetBlockByNumber(1)
+	logs := core.GetBlockReceipts(db, block.Hash(), 1)[0].Logs
+
+	var (
+		path, contentType string
+		body              []byte
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, contentType = r.URL.Path, r.Header.Get("Content-Type")
+		body, _ = ioutil.ReadAll(r.Body)
+		if r.URL.Path == "/fail" {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+
+	// Webhooks must receive the logs along with the block
+	if err := NewWebhookSink(srv.URL+"/hook").Push(block, logs); err != nil {
+		t.Fatalf("failed to push to webhook: %v", err)
+	}
+	var hook BlockLogs
+	if err := json.Unmarshal(body, &hook); err != nil {
+		t.Fatalf("failed to decode webhook payload: %v", err)
+	}
+	if path != "/hook" || contentType != "application/json" {
+		t.Errorf("webhook request mismatch: path %s, content type %s", path, contentType)
+	}
+	if hook.Number != 1 || hook.Hash != block.Hash() || len(hook.Logs) != 1 || hook.Logs[0].Address != logs[0].Address {
+		t.Errorf("webhook payload mismatch: %s", body)
+	}
+	if err := NewWebhookSink(srv.URL+"/fail").Push(block, logs); err == nil {
+		t.Errorf("rejected webhook delivery succeeded")
+	}
+	// Kafka proxies must receive every log as a record keyed by contract
+	if err := NewKafkaSink(srv.URL+"/", "chain events").Push(block, logs); err != nil {
+		t.Fatalf("failed to push to kafka: %v", err)
+	}
+	var records kafkaRecords
+	if err := json.Unmarshal(body, &records); err != nil {
+		t.Fatalf("failed to decode kafka payload: %v", err)
+	}
+	if path != "/topics/chain events" || contentType != "application/vnd.kafka.json.v2+json" {
+		t.Errorf("kafka request mismatch: path %s, content type %s", path, contentType)
+	}
+	if len(records.Records) != 1 || records.Records[0].Key != logs[0].Address || records.Records[0].Value.TxHash != logs[0].TxHash {
+		t.Errorf("kafka payload mismatch: %s", body)
+	}
+}
@@ -0,0 +1,145 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package exporter
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// sinkTimeout is the maximum time to wait for a sink to accept a delivery.
+const sinkTimeout = 30 * time.Second
+
+// BlockLogs is the payload delivered to webhooks: the matching logs of a block.
+type BlockLogs struct {
+	Number hexutil.Uint64 `json:"number"`
+	Hash   common.Hash    `json:"hash"`
+	Logs   []*types.Log   `json:"logs"`
+}
+
+// WebhookSink delivers the logs of each block as a JSON POST request.
+type WebhookSink struct {
+	url    string
+	client *http.Client
+}
+
+// NewWebhookSink creates a sink posting to the given HTTP endpoint. Basic auth
+// credentials may be embedded into the URL.
+func NewWebhookSink(endpoint string) *WebhookSink {
+	return &WebhookSink{url: endpoint, client: &http.Client{Timeout: sinkTimeout}}
+}
+
+// Name implements Sink.
+func (w *WebhookSink) Name() string {
+	return "webhook " + redact(w.url)
+}
+
+// Push implements Sink, posting the logs of the block. Any non-2xx response is
+// treated as a failed delivery.
+func (w *WebhookSink) Push(block *types.Block, logs []*types.Log) error {
+	blob, err := json.Marshal(&BlockLogs{
+		Number: hexutil.Uint64(block.NumberU64()),
+		Hash:   block.Hash(),
+		Logs:   logs,
+	})
+	if err != nil {
+		return err
+	}
+	return post(w.client, w.url, "application/json", blob)
+}
+
+// KafkaSink produces each log as a record into a Kafka topic through a Kafka
+// REST proxy (v2 API), keyed by the emitting contract to keep the logs of a
+// contract ordered within a partition.
+type KafkaSink struct {
+	url    string
+	client *http.Client
+}
+
+// kafkaRecords is the produce request body of the Kafka REST proxy.
+type kafkaRecords struct {
+	Records []kafkaRecord `json:"records"`
+}
+
+type kafkaRecord struct {
+	Key   common.Address `json:"key"`
+	Value *types.Log     `json:"value"`
+}
+
+// NewKafkaSink creates a sink producing into the given topic through the REST
+// proxy listening on the given endpoint.
+func NewKafkaSink(proxy string, topic string) *KafkaSink {
+	return &KafkaSink{
+		url:    strings.TrimRight(proxy, "/") + "/topics/" + url.PathEscape(topic),
+		client: &http.Client{Timeout: sinkTimeout},
+	}
+}
+
+// Name implements Sink.
+func (k *KafkaSink) Name() string {
+	return "kafka " + redact(k.url)
+}
+
+// Push implements Sink, producing all logs of the block in a single request.
+func (k *KafkaSink) Push(block *types.Block, logs []*types.Log) error {
+	records := kafkaRecords{Records: make([]kafkaRecord, len(logs))}
+	for i, log := range logs {
+		records.Records[i] = kafkaRecord{Key: log.Address, Value: log}
+	}
+	blob, err := json.Marshal(records)
+	if err != nil {
+		return err
+	}
+	return post(k.client, k.url, "application/vnd.kafka.json.v2+json", blob)
+}
+
+// post sends a request body to an endpoint, failing on non-2xx responses.
+func post(client *http.Client, url string, contentType string, body []byte) error {
+	res, err := client.Post(url, contentType, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(res.Body, 256))
+		return fmt.Errorf("unexpected response: %s: %s", res.Status, bytes.TrimSpace(msg))
+	}
+	io.Copy(ioutil.Discard, res.Body)
+	return nil
+}
+
+// redact strips any credentials from an endpoint URL for logging.
+func redact(endpoint string) string {
+	u, err := url.Parse(endpoint)
+	if err != nil || u.User == nil {
+		return endpoint
+	}
+	u.User = nil
+	return u.String()
+}
@@ -28,6 +28,7 @@ import (
 	ethereum "github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/event"
@@ -38,6 +39,10 @@ var (
 	deadline = 5 * time.Minute // consider a filter inactive if it has not been polled for within deadline
 )
 
+// defaultLogPageSize is the number of logs delivered per page by the logPages
+// subscription if neither the client nor the node configure a page size.
+const defaultLogPageSize = 1000
+
 // filter is a helper struct that holds meta information over the filter type
 // and associated subscription in the event system.
 type filter struct {
@@ -164,6 +169,55 @@ func (api *PublicFilterAPI) NewPendingTransactions(ctx context.Context) (*rpc.Su
 	return rpcSub, nil
 }
 
+// DroppedTransaction is a transaction rejected by or dropped from the transaction
+// pool, as delivered by the droppedTransactions subscription.
+type DroppedTransaction struct {
+	Hash        common.Hash       `json:"hash"`
+	Reason      core.TxDropReason `json:"reason"`
+	Error       string            `json:"error,omitempty"`
+	Replacement *common.Hash      `json:"replacement,omitempty"`
+}
+
+// DroppedTransactions creates a subscription that is triggered each time the
+// transaction pool rejects, evicts or replaces a transaction, letting clients
+// tell a transaction that is still pending from one that was silently dropped.
+func (api *PublicFilterAPI) DroppedTransactions(ctx context.Context) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+
+	rpcSub := notifier.CreateSubscription()
+
+	go func() {
+		drops := make(chan core.TxDropEvent)
+		droppedTxSub := api.events.SubscribeDroppedTxEvents(drops)
+
+		for {
+			select {
+			case ev := <-drops:
+				dropped := &DroppedTransaction{Hash: ev.Tx.Hash(), Reason: ev.Reason}
+				if ev.Err != nil {
+					dropped.Error = ev.Err.Error()
+				}
+				if ev.Replacement != nil {
+					hash := ev.Replacement.Hash()
+					dropped.Replacement = &hash
+				}
+				notifier.Notify(rpcSub.ID, dropped)
+			case <-rpcSub.Err():
+				droppedTxSub.Unsubscribe()
+				return
+			case <-notifier.Closed():
+				droppedTxSub.Unsubscribe()
+				return
+			}
+		}
+	}()
+
+	return rpcSub, nil
+}
+
 // NewBlockFilter creates a filter that fetches blocks that are imported into the chain.
 // It is part of the filter package since polling goes with eth_getFilterChanges.
 //
@@ -267,6 +321,108 @@ func (api *PublicFilterAPI) Logs(ctx context.Context, crit FilterCriteria) (*rpc
 	return rpcSub, nil
 }
 
+// LogPage is a batch of historical logs delivered by the logPages subscription.
+// The logs of all blocks from FromBlock up to and including ToBlock are part of
+// the page; Done is set on the last page of the requested range.
+type LogPage struct {
+	FromBlock hexutil.Uint64 `json:"fromBlock"`
+	ToBlock   hexutil.Uint64 `json:"toBlock"`
+	Logs      []*types.Log   `json:"logs"`
+	Done      bool           `json:"done"`
+	Error     string         `json:"error,omitempty"`
+}
+
+// LogPages streams the logs matching the given criteria in pages of at least
+// pageSize logs, letting clients retrieve ranges exceeding the eth_getLogs
+// limits without the node having to hold the whole result in memory. Pages are
+// delivered in block order, each one only after the previous one was written
+// to the connection.
+func (api *PublicFilterAPI) LogPages(ctx context.Context, crit FilterCriteria, pageSize *int) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+	// Resolve the range up front, paging is exempt from the block range limit
+	from, to := crit.FromBlock, crit.ToBlock
+	if from == nil {
+		from = big.NewInt(0)
+	}
+	if to == nil {
+		to = big.NewInt(rpc.LatestBlockNumber.Int64())
+	}
+	header, err := api.backend.HeaderByNumber(ctx, rpc.LatestBlockNumber)
+	if header == nil {
+		if err == nil {
+			err = errors.New("head block unavailable")
+		}
+		return nil, err
+	}
+	begin, end := from.Int64(), to.Int64()
+	if begin < 0 {
+		begin = header.Number.Int64()
+	}
+	if end < 0 {
+		end = header.Number.Int64()
+	}
+	// Cap the page size to the result limit of one-off queries
+	_, limit := api.backend.LogQueryLimits()
+	size := defaultLogPageSize
+	if limit > 0 {
+		size = limit
+	}
+	if pageSize != nil && *pageSize > 0 && *pageSize < size {
+		size = *pageSize
+	}
+	filter := New(api.backend, begin, end, crit.Addresses, crit.Topics)
+	filter.SetLimit(size)
+
+	rpcSub := notifier.CreateSubscription()
+
+	go func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		go func() {
+			select {
+			case <-rpcSub.Err(): // client send an unsubscribe request
+			case <-notifier.Closed(): // connection dropped
+			case <-ctx.Done():
+			}
+			cancel()
+		}()
+		// Wait for the client to learn the subscription ID before paging
+		select {
+		case <-rpcSub.Active():
+		case <-ctx.Done():
+			return
+		}
+		for {
+			page := &LogPage{FromBlock: hexutil.Uint64(filter.begin)}
+
+			logs, err := filter.Logs(ctx)
+			if ctx.Err() != nil {
+				return
+			}
+			page.Logs, page.Done = returnLogs(logs), filter.Done()
+			if filter.begin > int64(page.FromBlock) {
+				page.ToBlock = hexutil.Uint64(filter.begin - 1)
+			} else {
+				page.ToBlock = page.FromBlock
+			}
+			switch {
+			case err != nil:
+				page.Error, page.Done = err.Error(), true
+			case !page.Done && filter.begin == int64(page.FromBlock):
+				page.Error, page.Done = fmt.Sprintf("block %d unavailable", page.FromBlock), true
+			}
+			if err := notifier.Notify(rpcSub.ID, page); err != nil || page.Done {
+				return
+			}
+		}
+	}()
+	return rpcSub, nil
+}
+
 // FilterCriteria represents a request to create a new filter.
 //
 // TODO(karalabe): Kill this in favor of ethereum.FilterQuery.
@@ -333,14 +489,60 @@ func (api *PublicFilterAPI) GetLogs(ctx context.Context, crit FilterCriteria) ([
 	if crit.ToBlock == nil {
 		crit.ToBlock = big.NewInt(rpc.LatestBlockNumber.Int64())
 	}
-	// Create and run the filter to get all the logs
-	filter := New(api.backend, crit.FromBlock.Int64(), crit.ToBlock.Int64(), crit.Addresses, crit.Topics)
+	logs, err := api.queryLogs(ctx, crit)
+	if err != nil {
+		return nil, err
+	}
+	return returnLogs(logs), err
+}
+
+// resolveRange converts the block range of a log query into absolute block
+// numbers, rejecting it if it spans more blocks than the node allows.
+func (api *PublicFilterAPI) resolveRange(ctx context.Context, from, to *big.Int) (int64, int64, error) {
+	begin, end := rpc.LatestBlockNumber.Int64(), rpc.LatestBlockNumber.Int64()
+	if from != nil {
+		begin = from.Int64()
+	}
+	if to != nil {
+		end = to.Int64()
+	}
+	if begin < 0 || end < 0 {
+		if header, _ := api.backend.HeaderByNumber(ctx, rpc.LatestBlockNumber); header != nil {
+			if begin < 0 {
+				begin = header.Number.Int64()
+			}
+			if end < 0 {
+				end = header.Number.Int64()
+			}
+		}
+	}
+	if limit, _ := api.backend.LogQueryLimits(); limit > 0 && begin >= 0 && end >= begin && uint64(end-begin) >= limit {
+		return 0, 0, fmt.Errorf("query spans %d blocks, exceeding the limit of %d", end-begin+1, limit)
+	}
+	return begin, end, nil
+}
 
+// queryLogs runs a one-off log query, enforcing the block range and result
+// count limits of the node.
+func (api *PublicFilterAPI) queryLogs(ctx context.Context, crit FilterCriteria) ([]*types.Log, error) {
+	begin, end, err := api.resolveRange(ctx, crit.FromBlock, crit.ToBlock)
+	if err != nil {
+		return nil, err
+	}
+	filter := New(api.backend, begin, end, crit.Addresses, crit.Topics)
+
+	_, limit := api.backend.LogQueryLimits()
+	if limit > 0 {
+		filter.SetLimit(limit + 1)
+	}
 	logs, err := filter.Logs(ctx)
 	if err != nil {
 		return nil, err
 	}
-	return returnLogs(logs), err
+	if limit > 0 && len(logs) > limit {
+		return nil, fmt.Errorf("query returns more than %d logs, narrow the block range or use the logPages subscription", limit)
+	}
+	return logs, nil
 }
 
 // UninstallFilter removes the filter with the given filter id.
@@ -373,18 +575,7 @@ func (api *PublicFilterAPI) GetFilterLogs(ctx context.Context, id rpc.ID) ([]*ty
 		return nil, fmt.Errorf("filter not found")
 	}
 
-	begin := rpc.LatestBlockNumber.Int64()
-	if f.crit.FromBlock != nil {
-		begin = f.crit.FromBlock.Int64()
-	}
-	end := rpc.LatestBlockNumber.Int64()
-	if f.crit.ToBlock != nil {
-		end = f.crit.ToBlock.Int64()
-	}
-	// Create and run the filter to get all the logs
-	filter := New(api.backend, begin, end, f.crit.Addresses, f.crit.Topics)
-
-	logs, err := filter.Logs(ctx)
+	logs, err := api.queryLogs(ctx, f.crit)
 	if err != nil {
 		return nil, err
 	}
@@ -37,12 +37,17 @@ type Backend interface {
 	GetLogs(ctx context.Context, blockHash common.Hash) ([][]*types.Log, error)
 
 	SubscribeTxPreEvent(chan<- core.TxPreEvent) event.Subscription
+	SubscribeTxDropEvent(chan<- core.TxDropEvent) event.Subscription
 	SubscribeChainEvent(ch chan<- core.ChainEvent) event.Subscription
 	SubscribeRemovedLogsEvent(ch chan<- core.RemovedLogsEvent) event.Subscription
 	SubscribeLogsEvent(ch chan<- []*types.Log) event.Subscription
 
 	BloomStatus() (uint64, uint64)
 	ServiceFilter(ctx context.Context, session *bloombits.MatcherSession)
+
+	// LogQueryLimits returns the maximum number of blocks and logs a single
+	// log query may span or return (0 = unlimited).
+	LogQueryLimits() (uint64, int)
 }
 
 // Filter can be used to retrieve and filter logs.
@@ -54,6 +59,9 @@ type Filter struct {
 	addresses  []common.Address
 	topics     [][]common.Hash
 
+	limit int  // Number of logs after which Logs returns early (0 = no limit)
+	done  bool // Whether the entire range of the filter has been searched
+
 	matcher *bloombits.Matcher
 }
 
@@ -92,12 +100,30 @@ func New(backend Backend, begin, end int64, addresses []common.Address, topics [
 	}
 }
 
+// SetLimit makes Logs return as soon as at least limit matching logs have been
+// gathered (0 = no limit). All logs of the block reaching the limit are returned
+// and a subsequent call to Logs continues the search from the next block.
+func (f *Filter) SetLimit(limit int) {
+	f.limit = limit
+}
+
+// Done reports whether the last call to Logs searched the filter range to its end.
+func (f *Filter) Done() bool {
+	return f.done
+}
+
+// full reports whether the given logs reached the limit of the filter.
+func (f *Filter) full(logs []*types.Log) bool {
+	return f.limit > 0 && len(logs) >= f.limit
+}
+
 // Logs searches the blockchain for matching log entries, returning all from the
 // first block that contains matches, updating the start of the filter accordingly.
 func (f *Filter) Logs(ctx context.Context) ([]*types.Log, error) {
 	// Figure out the limits of the filter range
 	header, _ := f.backend.HeaderByNumber(ctx, rpc.LatestBlockNumber)
 	if header == nil {
+		f.done = true
 		return nil, nil
 	}
 	head := header.Number.Uint64()
@@ -121,12 +147,14 @@ func (f *Filter) Logs(ctx context.Context) ([]*types.Log, error) {
 		} else {
 			logs, err = f.indexedLogs(ctx, indexed-1)
 		}
-		if err != nil {
+		if err != nil || f.full(logs) {
+			f.done = err == nil && f.begin > int64(end)
 			return logs, err
 		}
 	}
-	rest, err := f.unindexedLogs(ctx, end)
+	rest, err := f.unindexedLogs(ctx, end, len(logs))
 	logs = append(logs, rest...)
+	f.done = err == nil && f.begin > int64(end)
 	return logs, err
 }
 
@@ -171,18 +199,27 @@ func (f *Filter) indexedLogs(ctx context.Context, end uint64) ([]*types.Log, err
 			}
 			logs = append(logs, found...)
 
+			// Stop early if the result limit was reached
+			if f.full(logs) {
+				return logs, nil
+			}
+
 		case <-ctx.Done():
 			return logs, ctx.Err()
 		}
 	}
 }
 
-// indexedLogs returns the logs matching the filter criteria based on raw block
-// iteration and bloom matching.
-func (f *Filter) unindexedLogs(ctx context.Context, end uint64) ([]*types.Log, error) {
+// unindexedLogs returns the logs matching the filter criteria based on raw block
+// iteration and bloom matching. Gathered is the number of logs already found by
+// the indexed search, counted against the result limit.
+func (f *Filter) unindexedLogs(ctx context.Context, end uint64, gathered int) ([]*types.Log, error) {
 	var logs []*types.Log
 
 	for ; f.begin <= int64(end); f.begin++ {
+		if f.limit > 0 && gathered+len(logs) >= f.limit {
+			break
+		}
 		header, err := f.backend.HeaderByNumber(ctx, rpc.BlockNumber(f.begin))
 		if header == nil || err != nil {
 			return logs, err
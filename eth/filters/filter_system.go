@@ -51,6 +51,9 @@ const (
 	PendingTransactionsSubscription
 	// BlocksSubscription queries hashes for blocks that are imported
 	BlocksSubscription
+	// DroppedTransactionsSubscription queries transactions rejected by or
+	// dropped from the transaction pool
+	DroppedTransactionsSubscription
 	// LastSubscription keeps track of the last index
 	LastIndexSubscription
 )
@@ -60,6 +63,8 @@ const (
 	// txChanSize is the size of channel listening to TxPreEvent.
 	// The number is referenced from the size of tx pool.
 	txChanSize = 4096
+	// dropChanSize is the size of channel listening to TxDropEvent.
+	dropChanSize = 4096
 	// rmLogsChanSize is the size of channel listening to RemovedLogsEvent.
 	rmLogsChanSize = 10
 	// logsChanSize is the size of channel listening to LogsEvent.
@@ -80,6 +85,7 @@ type subscription struct {
 	logs      chan []*types.Log
 	hashes    chan common.Hash
 	headers   chan *types.Header
+	drops     chan core.TxDropEvent
 	installed chan struct{} // closed when the filter is installed
 	err       chan error    // closed when the filter is uninstalled
 }
@@ -143,6 +149,7 @@ func (sub *Subscription) Unsubscribe() {
 			case <-sub.f.logs:
 			case <-sub.f.hashes:
 			case <-sub.f.headers:
+			case <-sub.f.drops:
 			}
 		}
 
@@ -210,6 +217,7 @@ func (es *EventSystem) subscribeMinedPendingLogs(crit ethereum.FilterQuery, logs
 		logs:      logs,
 		hashes:    make(chan common.Hash),
 		headers:   make(chan *types.Header),
+		drops:     make(chan core.TxDropEvent),
 		installed: make(chan struct{}),
 		err:       make(chan error),
 	}
@@ -227,6 +235,7 @@ func (es *EventSystem) subscribeLogs(crit ethereum.FilterQuery, logs chan []*typ
 		logs:      logs,
 		hashes:    make(chan common.Hash),
 		headers:   make(chan *types.Header),
+		drops:     make(chan core.TxDropEvent),
 		installed: make(chan struct{}),
 		err:       make(chan error),
 	}
@@ -244,6 +253,7 @@ func (es *EventSystem) subscribePendingLogs(crit ethereum.FilterQuery, logs chan
 		logs:      logs,
 		hashes:    make(chan common.Hash),
 		headers:   make(chan *types.Header),
+		drops:     make(chan core.TxDropEvent),
 		installed: make(chan struct{}),
 		err:       make(chan error),
 	}
@@ -260,6 +270,7 @@ func (es *EventSystem) SubscribeNewHeads(headers chan *types.Header) *Subscripti
 		logs:      make(chan []*types.Log),
 		hashes:    make(chan common.Hash),
 		headers:   headers,
+		drops:     make(chan core.TxDropEvent),
 		installed: make(chan struct{}),
 		err:       make(chan error),
 	}
@@ -276,6 +287,24 @@ func (es *EventSystem) SubscribePendingTxEvents(hashes chan common.Hash) *Subscr
 		logs:      make(chan []*types.Log),
 		hashes:    hashes,
 		headers:   make(chan *types.Header),
+		drops:     make(chan core.TxDropEvent),
+		installed: make(chan struct{}),
+		err:       make(chan error),
+	}
+	return es.subscribe(sub)
+}
+
+// SubscribeDroppedTxEvents creates a subscription that writes the transactions
+// rejected by or dropped from the transaction pool.
+func (es *EventSystem) SubscribeDroppedTxEvents(drops chan core.TxDropEvent) *Subscription {
+	sub := &subscription{
+		id:        rpc.NewID(),
+		typ:       DroppedTransactionsSubscription,
+		created:   time.Now(),
+		logs:      make(chan []*types.Log),
+		hashes:    make(chan common.Hash),
+		headers:   make(chan *types.Header),
+		drops:     drops,
 		installed: make(chan struct{}),
 		err:       make(chan error),
 	}
@@ -320,6 +349,10 @@ func (es *EventSystem) broadcast(filters filterIndex, ev interface{}) {
 		for _, f := range filters[PendingTransactionsSubscription] {
 			f.hashes <- e.Tx.Hash()
 		}
+	case core.TxDropEvent:
+		for _, f := range filters[DroppedTransactionsSubscription] {
+			f.drops <- e
+		}
 	case core.ChainEvent:
 		for _, f := range filters[BlocksSubscription] {
 			f.headers <- e.Block.Header()
@@ -417,6 +450,9 @@ func (es *EventSystem) eventLoop() {
 		// Subscribe TxPreEvent form txpool
 		txCh  = make(chan core.TxPreEvent, txChanSize)
 		txSub = es.backend.SubscribeTxPreEvent(txCh)
+		// Subscribe TxDropEvent from txpool
+		dropCh  = make(chan core.TxDropEvent, dropChanSize)
+		dropSub = es.backend.SubscribeTxDropEvent(dropCh)
 		// Subscribe RemovedLogsEvent
 		rmLogsCh  = make(chan core.RemovedLogsEvent, rmLogsChanSize)
 		rmLogsSub = es.backend.SubscribeRemovedLogsEvent(rmLogsCh)
@@ -431,6 +467,7 @@ func (es *EventSystem) eventLoop() {
 	// Unsubscribe all events
 	defer sub.Unsubscribe()
 	defer txSub.Unsubscribe()
+	defer dropSub.Unsubscribe()
 	defer rmLogsSub.Unsubscribe()
 	defer logsSub.Unsubscribe()
 	defer chainEvSub.Unsubscribe()
@@ -450,6 +487,8 @@ func (es *EventSystem) eventLoop() {
 		// Handle subscribed events
 		case ev := <-txCh:
 			es.broadcast(index, ev)
+		case ev := <-dropCh:
+			es.broadcast(index, ev)
 		case ev := <-rmLogsCh:
 			es.broadcast(index, ev)
 		case ev := <-logsCh:
@@ -479,6 +518,8 @@ func (es *EventSystem) eventLoop() {
 		// System stopped
 		case <-txSub.Err():
 			return
+		case <-dropSub.Err():
+			return
 		case <-rmLogsSub.Err():
 			return
 		case <-logsSub.Err():
@@ -42,6 +42,7 @@ type testBackend struct {
 	db         ethdb.Database
 	sections   uint64
 	txFeed     *event.Feed
+	dropFeed   *event.Feed
 	rmLogsFeed *event.Feed
 	logsFeed   *event.Feed
 	chainFeed  *event.Feed
@@ -88,6 +89,10 @@ func (b *testBackend) SubscribeTxPreEvent(ch chan<- core.TxPreEvent) event.Subsc
 	return b.txFeed.Subscribe(ch)
 }
 
+func (b *testBackend) SubscribeTxDropEvent(ch chan<- core.TxDropEvent) event.Subscription {
+	return b.dropFeed.Subscribe(ch)
+}
+
 func (b *testBackend) SubscribeRemovedLogsEvent(ch chan<- core.RemovedLogsEvent) event.Subscription {
 	return b.rmLogsFeed.Subscribe(ch)
 }
@@ -104,6 +109,10 @@ func (b *testBackend) BloomStatus() (uint64, uint64) {
 	return params.BloomBitsBlocks, b.sections
 }
 
+func (b *testBackend) LogQueryLimits() (uint64, int) {
+	return 0, 0
+}
+
 func (b *testBackend) ServiceFilter(ctx context.Context, session *bloombits.MatcherSession) {
 	requests := make(chan chan *bloombits.Retrieval)
 
@@ -146,7 +155,7 @@ func TestBlockSubscription(t *testing.T) {
 		rmLogsFeed  = new(event.Feed)
 		logsFeed    = new(event.Feed)
 		chainFeed   = new(event.Feed)
-		backend     = &testBackend{mux, db, 0, txFeed, rmLogsFeed, logsFeed, chainFeed}
+		backend     = &testBackend{mux, db, 0, txFeed, new(event.Feed), rmLogsFeed, logsFeed, chainFeed}
 		api         = NewPublicFilterAPI(backend, false)
 		genesis     = new(core.Genesis).MustCommit(db)
 		chain, _    = core.GenerateChain(params.TestChainConfig, genesis, ethash.NewFaker(), db, 10, func(i int, gen *core.BlockGen) {})
@@ -203,7 +212,7 @@ func TestPendingTxFilter(t *testing.T) {
 		rmLogsFeed = new(event.Feed)
 		logsFeed   = new(event.Feed)
 		chainFeed  = new(event.Feed)
-		backend    = &testBackend{mux, db, 0, txFeed, rmLogsFeed, logsFeed, chainFeed}
+		backend    = &testBackend{mux, db, 0, txFeed, new(event.Feed), rmLogsFeed, logsFeed, chainFeed}
 		api        = NewPublicFilterAPI(backend, false)
 
 		transactions = []*types.Transaction{
@@ -256,6 +265,46 @@ func TestPendingTxFilter(t *testing.T) {
 	}
 }
 
+// TestDroppedTxSubscription tests whether dropped transaction subscriptions
+// receive the transactions dropped from the pool.
+func TestDroppedTxSubscription(t *testing.T) {
+	t.Parallel()
+
+	var (
+		mux      = new(event.TypeMux)
+		db, _    = ethdb.NewMemDatabase()
+		dropFeed = new(event.Feed)
+		backend  = &testBackend{mux, db, 0, new(event.Feed), dropFeed, new(event.Feed), new(event.Feed), new(event.Feed)}
+		api      = NewPublicFilterAPI(backend, false)
+
+		tx          = types.NewTransaction(0, common.Address{0x01}, new(big.Int), 0, big.NewInt(1), nil)
+		replacement = types.NewTransaction(0, common.Address{0x01}, new(big.Int), 0, big.NewInt(2), nil)
+		events      = []core.TxDropEvent{
+			{Tx: tx, Reason: core.TxDropUnderpriced, Err: core.ErrUnderpriced},
+			{Tx: tx, Reason: core.TxDropReplaced, Replacement: replacement},
+		}
+	)
+	drops := make(chan core.TxDropEvent)
+	sub := api.events.SubscribeDroppedTxEvents(drops)
+	defer sub.Unsubscribe()
+
+	go func() {
+		for _, ev := range events {
+			dropFeed.Send(ev)
+		}
+	}()
+	for i, want := range events {
+		select {
+		case have := <-drops:
+			if have.Tx.Hash() != want.Tx.Hash() || have.Reason != want.Reason || have.Err != want.Err || have.Replacement != want.Replacement {
+				t.Errorf("drop %d mismatch: have %+v, want %+v", i, have, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("drop %d timeout", i)
+		}
+	}
+}
+
 // TestLogFilterCreation test whether a given filter criteria makes sense.
 // If not it must return an error.
 func TestLogFilterCreation(t *testing.T) {
@@ -266,7 +315,7 @@ func TestLogFilterCreation(t *testing.T) {
 		rmLogsFeed = new(event.Feed)
 		logsFeed   = new(event.Feed)
 		chainFeed  = new(event.Feed)
-		backend    = &testBackend{mux, db, 0, txFeed, rmLogsFeed, logsFeed, chainFeed}
+		backend    = &testBackend{mux, db, 0, txFeed, new(event.Feed), rmLogsFeed, logsFeed, chainFeed}
 		api        = NewPublicFilterAPI(backend, false)
 
 		testCases = []struct {
@@ -315,7 +364,7 @@ func TestInvalidLogFilterCreation(t *testing.T) {
 		rmLogsFeed = new(event.Feed)
 		logsFeed   = new(event.Feed)
 		chainFeed  = new(event.Feed)
-		backend    = &testBackend{mux, db, 0, txFeed, rmLogsFeed, logsFeed, chainFeed}
+		backend    = &testBackend{mux, db, 0, txFeed, new(event.Feed), rmLogsFeed, logsFeed, chainFeed}
 		api        = NewPublicFilterAPI(backend, false)
 	)
 
@@ -345,7 +394,7 @@ func TestLogFilter(t *testing.T) {
 		rmLogsFeed = new(event.Feed)
 		logsFeed   = new(event.Feed)
 		chainFeed  = new(event.Feed)
-		backend    = &testBackend{mux, db, 0, txFeed, rmLogsFeed, logsFeed, chainFeed}
+		backend    = &testBackend{mux, db, 0, txFeed, new(event.Feed), rmLogsFeed, logsFeed, chainFeed}
 		api        = NewPublicFilterAPI(backend, false)
 
 		firstAddr      = common.HexToAddress("0x1111111111111111111111111111111111111111")
@@ -464,7 +513,7 @@ func TestPendingLogsSubscription(t *testing.T) {
 		rmLogsFeed = new(event.Feed)
 		logsFeed   = new(event.Feed)
 		chainFeed  = new(event.Feed)
-		backend    = &testBackend{mux, db, 0, txFeed, rmLogsFeed, logsFeed, chainFeed}
+		backend    = &testBackend{mux, db, 0, txFeed, new(event.Feed), rmLogsFeed, logsFeed, chainFeed}
 		api        = NewPublicFilterAPI(backend, false)
 
 		firstAddr      = common.HexToAddress("0x1111111111111111111111111111111111111111")
@@ -56,7 +56,7 @@ func BenchmarkFilters(b *testing.B) {
 		rmLogsFeed = new(event.Feed)
 		logsFeed   = new(event.Feed)
 		chainFeed  = new(event.Feed)
-		backend    = &testBackend{mux, db, 0, txFeed, rmLogsFeed, logsFeed, chainFeed}
+		backend    = &testBackend{mux, db, 0, txFeed, new(event.Feed), rmLogsFeed, logsFeed, chainFeed}
 		key1, _    = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
 		addr1      = crypto.PubkeyToAddress(key1.PublicKey)
 		addr2      = common.BytesToAddress([]byte("jeff"))
@@ -121,7 +121,7 @@ func TestFilters(t *testing.T) {
 		rmLogsFeed = new(event.Feed)
 		logsFeed   = new(event.Feed)
 		chainFeed  = new(event.Feed)
-		backend    = &testBackend{mux, db, 0, txFeed, rmLogsFeed, logsFeed, chainFeed}
+		backend    = &testBackend{mux, db, 0, txFeed, new(event.Feed), rmLogsFeed, logsFeed, chainFeed}
 		key1, _    = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
 		addr       = crypto.PubkeyToAddress(key1.PublicKey)
 
@@ -240,4 +240,43 @@ func TestFilters(t *testing.T) {
 	if len(logs) != 0 {
 		t.Error("expected 0 log, got", len(logs))
 	}
+
+	// Limited filters should return the logs page by page
+	filter = New(backend, 0, -1, []common.Address{addr}, [][]common.Hash{{hash1, hash2, hash3, hash4}})
+	filter.SetLimit(1)
+
+	for i, want := range []common.Hash{hash1, hash2, hash3, hash4} {
+		logs, _ = filter.Logs(context.Background())
+		if len(logs) != 1 || logs[0].Topics[0] != want {
+			t.Fatalf("page %d: expected log with topic %x, got %v", i, want, logs)
+		}
+	}
+	if logs, _ = filter.Logs(context.Background()); len(logs) != 0 || !filter.Done() {
+		t.Errorf("expected exhausted filter, got %d logs, done %v", len(logs), filter.Done())
+	}
+
+	// The API should reject queries beyond the configured limits
+	api := &PublicFilterAPI{backend: &limitedBackend{testBackend: backend, blocks: 100, results: 1}}
+
+	if _, err := api.GetLogs(context.Background(), FilterCriteria{FromBlock: big.NewInt(0), ToBlock: big.NewInt(100)}); err == nil {
+		t.Error("expected block range error")
+	}
+	if _, err := api.GetLogs(context.Background(), FilterCriteria{FromBlock: big.NewInt(0), ToBlock: big.NewInt(99), Addresses: []common.Address{addr}}); err == nil {
+		t.Error("expected result count error")
+	}
+	logs, err = api.GetLogs(context.Background(), FilterCriteria{FromBlock: big.NewInt(950), Addresses: []common.Address{addr}, Topics: [][]common.Hash{{hash3}}})
+	if err != nil || len(logs) != 1 {
+		t.Errorf("expected 1 log within limits, got %d (error %v)", len(logs), err)
+	}
+}
+
+// limitedBackend is a test backend enforcing log query limits.
+type limitedBackend struct {
+	*testBackend
+	blocks  uint64
+	results int
+}
+
+func (b *limitedBackend) LogQueryLimits() (uint64, int) {
+	return b.blocks, b.results
 }
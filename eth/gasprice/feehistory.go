@@ -0,0 +1,150 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package gasprice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// maxFeeHistory is the maximum number of blocks a single fee history query may
+// span.
+const maxFeeHistory = 1024
+
+var (
+	errInvalidBlockCount = errors.New("block count must be positive")
+	errMissingHistory    = errors.New("requested block not available")
+)
+
+// FeeHistory returns the fee market history of up to blocks blocks ending with
+// lastBlock: the number of the oldest block returned, the requested percentiles
+// of the priority fees paid in every block (weighted by gas used), the base fee
+// of every block and the one following, and the gas used ratio of every block.
+//
+// Transaction fees are paid as a flat gas price on this chain, so the base fee
+// is always zero and the full gas price counts as priority fee.
+func (gpo *Oracle) FeeHistory(ctx context.Context, blocks int, lastBlock rpc.BlockNumber, rewardPercentiles []float64) (*big.Int, [][]*big.Int, []*big.Int, []float64, error) {
+	if blocks < 1 {
+		return nil, nil, nil, nil, errInvalidBlockCount
+	}
+	if blocks > maxFeeHistory {
+		blocks = maxFeeHistory
+	}
+	for i, p := range rewardPercentiles {
+		if p < 0 || p > 100 || (i > 0 && p < rewardPercentiles[i-1]) {
+			return nil, nil, nil, nil, fmt.Errorf("invalid reward percentile #%d: %f", i, p)
+		}
+	}
+	// The pending block has no receipts to inspect, start from the head instead
+	if lastBlock == rpc.PendingBlockNumber {
+		lastBlock = rpc.LatestBlockNumber
+	}
+	head, err := gpo.backend.HeaderByNumber(ctx, lastBlock)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+	if head == nil {
+		return nil, nil, nil, nil, errMissingHistory
+	}
+	last := head.Number.Uint64()
+	if uint64(blocks) > last+1 {
+		blocks = int(last + 1)
+	}
+	oldest := last + 1 - uint64(blocks)
+
+	var (
+		reward       [][]*big.Int
+		baseFee      = make([]*big.Int, blocks+1)
+		gasUsedRatio = make([]float64, blocks)
+	)
+	if len(rewardPercentiles) > 0 {
+		reward = make([][]*big.Int, blocks)
+	}
+	for i := 0; i < blocks; i++ {
+		block, err := gpo.backend.BlockByNumber(ctx, rpc.BlockNumber(oldest+uint64(i)))
+		if err != nil {
+			return nil, nil, nil, nil, err
+		}
+		if block == nil {
+			return nil, nil, nil, nil, errMissingHistory
+		}
+		baseFee[i] = new(big.Int)
+		if block.GasLimit() > 0 {
+			gasUsedRatio[i] = float64(block.GasUsed()) / float64(block.GasLimit())
+		}
+		if reward != nil {
+			if reward[i], err = gpo.blockRewards(ctx, block, rewardPercentiles); err != nil {
+				return nil, nil, nil, nil, err
+			}
+		}
+	}
+	baseFee[blocks] = new(big.Int)
+
+	return new(big.Int).SetUint64(oldest), reward, baseFee, gasUsedRatio, nil
+}
+
+// txGasAndPrice is the gas used and the gas price paid by a transaction.
+type txGasAndPrice struct {
+	gasUsed uint64
+	price   *big.Int
+}
+
+// blockRewards calculates the given percentiles of the gas prices paid in a
+// block, weighted by the gas used by each transaction.
+func (gpo *Oracle) blockRewards(ctx context.Context, block *types.Block, percentiles []float64) ([]*big.Int, error) {
+	rewards := make([]*big.Int, len(percentiles))
+
+	txs := block.Transactions()
+	if len(txs) == 0 {
+		for i := range rewards {
+			rewards[i] = new(big.Int)
+		}
+		return rewards, nil
+	}
+	receipts, err := gpo.backend.GetReceipts(ctx, block.Hash())
+	if err != nil {
+		return nil, err
+	}
+	if len(receipts) < len(txs) {
+		return nil, fmt.Errorf("receipts of block #%d unavailable", block.NumberU64())
+	}
+	sorted := make([]txGasAndPrice, len(txs))
+	for i, tx := range txs {
+		sorted[i] = txGasAndPrice{gasUsed: receipts[i].GasUsed, price: tx.GasPrice()}
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].price.Cmp(sorted[j].price) < 0 })
+
+	var (
+		tx     = 0
+		sumGas = sorted[0].gasUsed
+	)
+	for i, p := range percentiles {
+		threshold := uint64(float64(block.GasUsed()) * p / 100)
+		for sumGas < threshold && tx < len(sorted)-1 {
+			tx++
+			sumGas += sorted[tx].gasUsed
+		}
+		rewards[i] = new(big.Int).Set(sorted[tx].price)
+	}
+	return rewards, nil
+}
@@ -25,13 +25,22 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/internal/ethapi"
+	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/params"
 	"github.com/ethereum/go-ethereum/rpc"
 )
 
 var maxPrice = big.NewInt(500 * params.Shannon)
 
+// Gas price suggestion strategies.
+const (
+	ModePercentile = "percentile" // Percentile of the cheapest prices in recent blocks
+	ModeFixed      = "fixed"      // Always the configured default price
+	ModeZero       = "zero"       // Always zero, for networks without transaction fees
+)
+
 type Config struct {
+	Mode       string `toml:",omitempty"` // Suggestion strategy (empty = percentile)
 	Blocks     int
 	Percentile int
 	Default    *big.Int `toml:",omitempty"`
@@ -46,6 +55,7 @@ type Oracle struct {
 	cacheLock sync.RWMutex
 	fetchLock sync.Mutex
 
+	mode                             string
 	checkBlocks, maxEmpty, maxBlocks int
 	percentile                       int
 }
@@ -63,9 +73,23 @@ func NewOracle(backend ethapi.Backend, params Config) *Oracle {
 	if percent > 100 {
 		percent = 100
 	}
+	mode := params.Mode
+	switch mode {
+	case "":
+		mode = ModePercentile
+	case ModePercentile, ModeZero:
+	case ModeFixed:
+		if params.Default == nil {
+			log.Warn("Fixed gas price oracle without default price, suggesting zero")
+		}
+	default:
+		log.Warn("Unknown gas price oracle mode, using percentile", "mode", mode)
+		mode = ModePercentile
+	}
 	return &Oracle{
 		backend:     backend,
 		lastPrice:   params.Default,
+		mode:        mode,
 		checkBlocks: blocks,
 		maxEmpty:    blocks / 2,
 		maxBlocks:   blocks * 5,
@@ -75,6 +99,15 @@ func NewOracle(backend ethapi.Backend, params Config) *Oracle {
 
 // SuggestPrice returns the recommended gas price.
 func (gpo *Oracle) SuggestPrice(ctx context.Context) (*big.Int, error) {
+	switch gpo.mode {
+	case ModeZero:
+		return new(big.Int), nil
+	case ModeFixed:
+		if gpo.lastPrice == nil {
+			return new(big.Int), nil
+		}
+		return new(big.Int).Set(gpo.lastPrice), nil
+	}
 	gpo.cacheLock.RLock()
 	lastHead := gpo.lastHead
 	lastPrice := gpo.lastPrice
@@ -0,0 +1,139 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package gasprice
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/internal/ethapi"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// testBackend serves a static chain of blocks and receipts to the oracle.
+type testBackend struct {
+	ethapi.Backend
+	blocks   []*types.Block
+	receipts map[common.Hash]types.Receipts
+}
+
+func (b *testBackend) HeaderByNumber(ctx context.Context, number rpc.BlockNumber) (*types.Header, error) {
+	if block, _ := b.BlockByNumber(ctx, number); block != nil {
+		return block.Header(), nil
+	}
+	return nil, nil
+}
+
+func (b *testBackend) BlockByNumber(ctx context.Context, number rpc.BlockNumber) (*types.Block, error) {
+	if number == rpc.LatestBlockNumber {
+		return b.blocks[len(b.blocks)-1], nil
+	}
+	if int(number) < len(b.blocks) {
+		return b.blocks[number], nil
+	}
+	return nil, nil
+}
+
+func (b *testBackend) GetReceipts(ctx context.Context, hash common.Hash) (types.Receipts, error) {
+	return b.receipts[hash], nil
+}
+
+// newTestBackend creates a chain where block n contains transactions paying
+// gas prices 1..n gwei, each using n times more gas than the previous.
+func newTestBackend(blocks int) *testBackend {
+	backend := &testBackend{receipts: make(map[common.Hash]types.Receipts)}
+	for n := 0; n < blocks; n++ {
+		var (
+			txs      []*types.Transaction
+			receipts types.Receipts
+			used     uint64
+		)
+		for i := 1; i <= n; i++ {
+			tx := types.NewTransaction(uint64(i), common.Address{}, new(big.Int), 21000, big.NewInt(int64(i)*1e9), nil)
+			txs = append(txs, tx)
+			receipts = append(receipts, &types.Receipt{GasUsed: uint64(i) * 1000})
+			used += uint64(i) * 1000
+		}
+		header := &types.Header{Number: big.NewInt(int64(n)), GasLimit: 100000, GasUsed: used}
+		block := types.NewBlock(header, txs, nil, receipts)
+		backend.blocks = append(backend.blocks, block)
+		backend.receipts[block.Hash()] = receipts
+	}
+	return backend
+}
+
+// Tests that the fixed and zero strategies suggest their configured prices.
+func TestSuggestPriceModes(t *testing.T) {
+	backend := newTestBackend(4)
+
+	zero := NewOracle(backend, Config{Mode: ModeZero, Default: big.NewInt(1)})
+	if price, err := zero.SuggestPrice(context.Background()); err != nil || price.Sign() != 0 {
+		t.Errorf("zero price mismatch: have %v (%v), want 0", price, err)
+	}
+	fixed := NewOracle(backend, Config{Mode: ModeFixed, Default: big.NewInt(7)})
+	if price, err := fixed.SuggestPrice(context.Background()); err != nil || price.Cmp(big.NewInt(7)) != 0 {
+		t.Errorf("fixed price mismatch: have %v (%v), want 7", price, err)
+	}
+	unset := NewOracle(backend, Config{Mode: ModeFixed})
+	if price, err := unset.SuggestPrice(context.Background()); err != nil || price.Sign() != 0 {
+		t.Errorf("unset fixed price mismatch: have %v (%v), want 0", price, err)
+	}
+}
+
+// Tests that the fee history reports gas weighted percentiles of the prices paid
+// in every block, and zero base fees.
+func TestFeeHistory(t *testing.T) {
+	oracle := NewOracle(newTestBackend(5), Config{Blocks: 2, Percentile: 60})
+
+	oldest, reward, baseFee, ratio, err := oracle.FeeHistory(context.Background(), 3, rpc.LatestBlockNumber, []float64{0, 50, 100})
+	if err != nil {
+		t.Fatalf("failed to retrieve fee history: %v", err)
+	}
+	if oldest.Uint64() != 2 {
+		t.Errorf("oldest block mismatch: have %v, want 2", oldest)
+	}
+	if len(baseFee) != 4 || len(ratio) != 3 || len(reward) != 3 {
+		t.Fatalf("result length mismatch: base fees %d, ratios %d, rewards %d", len(baseFee), len(ratio), len(reward))
+	}
+	for i, fee := range baseFee {
+		if fee.Sign() != 0 {
+			t.Errorf("base fee %d: have %v, want 0", i, fee)
+		}
+	}
+	// Block 4 uses 1000+2000+3000+4000 gas at 1..4 gwei: 50% of the gas falls
+	// within the 3 gwei transaction
+	if ratio[2] != 0.1 {
+		t.Errorf("gas used ratio mismatch: have %f, want 0.1", ratio[2])
+	}
+	want := []int64{1e9, 3e9, 4e9}
+	for i, w := range want {
+		if reward[2][i].Int64() != w {
+			t.Errorf("block 4 percentile %d: have %v, want %d", i, reward[2][i], w)
+		}
+	}
+	// Ranges beyond the genesis block are truncated
+	if oldest, _, _, _, err := oracle.FeeHistory(context.Background(), 10, 1, nil); err != nil || oldest.Uint64() != 0 {
+		t.Errorf("truncated history mismatch: oldest %v (%v), want 0", oldest, err)
+	}
+	// Invalid percentiles are rejected
+	if _, _, _, _, err := oracle.FeeHistory(context.Background(), 1, rpc.LatestBlockNumber, []float64{50, 10}); err == nil {
+		t.Errorf("unsorted percentiles accepted")
+	}
+}
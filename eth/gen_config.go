@@ -4,6 +4,7 @@ package eth
 
 import (
 	"math/big"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
@@ -12,6 +13,8 @@ import (
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/eth/downloader"
 	"github.com/ethereum/go-ethereum/eth/gasprice"
+	"github.com/ethereum/go-ethereum/internal/ethapi"
+	"github.com/ethereum/go-ethereum/miner"
 )
 
 var _ = (*configMarshaling)(nil)
@@ -21,19 +24,32 @@ func (c Config) MarshalTOML() (interface{}, error) {
 		Genesis                 *core.Genesis `toml:",omitempty"`
 		NetworkId               uint64
 		SyncMode                downloader.SyncMode
-		LightServ               int  `toml:",omitempty"`
-		LightPeers              int  `toml:",omitempty"`
-		SkipBcVersionCheck      bool `toml:"-"`
-		DatabaseHandles         int  `toml:"-"`
+		ReceiptsRetention       uint64   `toml:",omitempty"`
+		Indexes                 []string `toml:",omitempty"`
+		LightServ               int      `toml:",omitempty"`
+		LightPeers              int      `toml:",omitempty"`
+		SkipBcVersionCheck      bool     `toml:"-"`
+		DatabaseHandles         int      `toml:"-"`
 		DatabaseCache           int
-		Etherbase               common.Address `toml:",omitempty"`
-		MinerThreads            int            `toml:",omitempty"`
-		ExtraData               hexutil.Bytes  `toml:",omitempty"`
+		AsyncIndexing           bool             `toml:",omitempty"`
+		BlockCompression        core.Compression `toml:",omitempty"`
+		ParallelTxs             int              `toml:",omitempty"`
+		Etherbase               common.Address   `toml:",omitempty"`
+		MinerThreads            int              `toml:",omitempty"`
+		ExtraData               hexutil.Bytes    `toml:",omitempty"`
 		GasPrice                *big.Int
+		TxOrdering              miner.TxOrdering `toml:",omitempty"`
 		Ethash                  ethash.Config
 		TxPool                  core.TxPoolConfig
+		TxBroadcast             TxBroadcastConfig
 		GPO                     gasprice.Config
 		EnablePreimageRecording bool
+		RPCGasCap               uint64 `toml:",omitempty"`
+		RPCEVMTimeout           time.Duration
+		RPCLogsBlockRange       uint64        `toml:",omitempty"`
+		RPCLogsMaxResults       int           `toml:",omitempty"`
+		RPCUnlockTimeout        time.Duration `toml:",omitempty"`
+		TxApproval              ethapi.ApprovalConfig
 		DocRoot                 string `toml:"-"`
 		Istanbul                istanbul.Config
 	}
@@ -41,19 +57,32 @@ func (c Config) MarshalTOML() (interface{}, error) {
 	enc.Genesis = c.Genesis
 	enc.NetworkId = c.NetworkId
 	enc.SyncMode = c.SyncMode
+	enc.ReceiptsRetention = c.ReceiptsRetention
+	enc.Indexes = c.Indexes
 	enc.LightServ = c.LightServ
 	enc.LightPeers = c.LightPeers
 	enc.SkipBcVersionCheck = c.SkipBcVersionCheck
 	enc.DatabaseHandles = c.DatabaseHandles
 	enc.DatabaseCache = c.DatabaseCache
+	enc.AsyncIndexing = c.AsyncIndexing
+	enc.BlockCompression = c.BlockCompression
+	enc.ParallelTxs = c.ParallelTxs
 	enc.Etherbase = c.Etherbase
 	enc.MinerThreads = c.MinerThreads
 	enc.ExtraData = c.ExtraData
 	enc.GasPrice = c.GasPrice
+	enc.TxOrdering = c.TxOrdering
 	enc.Ethash = c.Ethash
 	enc.TxPool = c.TxPool
+	enc.TxBroadcast = c.TxBroadcast
 	enc.GPO = c.GPO
 	enc.EnablePreimageRecording = c.EnablePreimageRecording
+	enc.RPCGasCap = c.RPCGasCap
+	enc.RPCEVMTimeout = c.RPCEVMTimeout
+	enc.RPCLogsBlockRange = c.RPCLogsBlockRange
+	enc.RPCLogsMaxResults = c.RPCLogsMaxResults
+	enc.RPCUnlockTimeout = c.RPCUnlockTimeout
+	enc.TxApproval = c.TxApproval
 	enc.Istanbul = c.Istanbul
 	enc.DocRoot = c.DocRoot
 	return &enc, nil
@@ -64,19 +93,32 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 		Genesis                 *core.Genesis `toml:",omitempty"`
 		NetworkId               *uint64
 		SyncMode                *downloader.SyncMode
-		LightServ               *int  `toml:",omitempty"`
-		LightPeers              *int  `toml:",omitempty"`
-		SkipBcVersionCheck      *bool `toml:"-"`
-		DatabaseHandles         *int  `toml:"-"`
+		ReceiptsRetention       *uint64  `toml:",omitempty"`
+		Indexes                 []string `toml:",omitempty"`
+		LightServ               *int     `toml:",omitempty"`
+		LightPeers              *int     `toml:",omitempty"`
+		SkipBcVersionCheck      *bool    `toml:"-"`
+		DatabaseHandles         *int     `toml:"-"`
 		DatabaseCache           *int
-		Etherbase               *common.Address `toml:",omitempty"`
-		MinerThreads            *int            `toml:",omitempty"`
-		ExtraData               *hexutil.Bytes  `toml:",omitempty"`
+		AsyncIndexing           *bool             `toml:",omitempty"`
+		BlockCompression        *core.Compression `toml:",omitempty"`
+		ParallelTxs             *int              `toml:",omitempty"`
+		Etherbase               *common.Address   `toml:",omitempty"`
+		MinerThreads            *int              `toml:",omitempty"`
+		ExtraData               *hexutil.Bytes    `toml:",omitempty"`
 		GasPrice                *big.Int
+		TxOrdering              *miner.TxOrdering `toml:",omitempty"`
 		Ethash                  *ethash.Config
 		TxPool                  *core.TxPoolConfig
+		TxBroadcast             *TxBroadcastConfig
 		GPO                     *gasprice.Config
 		EnablePreimageRecording *bool
+		RPCGasCap               *uint64 `toml:",omitempty"`
+		RPCEVMTimeout           *time.Duration
+		RPCLogsBlockRange       *uint64        `toml:",omitempty"`
+		RPCLogsMaxResults       *int           `toml:",omitempty"`
+		RPCUnlockTimeout        *time.Duration `toml:",omitempty"`
+		TxApproval              *ethapi.ApprovalConfig
 		DocRoot                 *string `toml:"-"`
 		Istanbul                *istanbul.Config
 	}
@@ -93,6 +135,12 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.SyncMode != nil {
 		c.SyncMode = *dec.SyncMode
 	}
+	if dec.ReceiptsRetention != nil {
+		c.ReceiptsRetention = *dec.ReceiptsRetention
+	}
+	if dec.Indexes != nil {
+		c.Indexes = dec.Indexes
+	}
 	if dec.LightServ != nil {
 		c.LightServ = *dec.LightServ
 	}
@@ -108,6 +156,15 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.DatabaseCache != nil {
 		c.DatabaseCache = *dec.DatabaseCache
 	}
+	if dec.AsyncIndexing != nil {
+		c.AsyncIndexing = *dec.AsyncIndexing
+	}
+	if dec.BlockCompression != nil {
+		c.BlockCompression = *dec.BlockCompression
+	}
+	if dec.ParallelTxs != nil {
+		c.ParallelTxs = *dec.ParallelTxs
+	}
 	if dec.Etherbase != nil {
 		c.Etherbase = *dec.Etherbase
 	}
@@ -120,18 +177,42 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.GasPrice != nil {
 		c.GasPrice = dec.GasPrice
 	}
+	if dec.TxOrdering != nil {
+		c.TxOrdering = *dec.TxOrdering
+	}
 	if dec.Ethash != nil {
 		c.Ethash = *dec.Ethash
 	}
 	if dec.TxPool != nil {
 		c.TxPool = *dec.TxPool
 	}
+	if dec.TxBroadcast != nil {
+		c.TxBroadcast = *dec.TxBroadcast
+	}
 	if dec.GPO != nil {
 		c.GPO = *dec.GPO
 	}
 	if dec.EnablePreimageRecording != nil {
 		c.EnablePreimageRecording = *dec.EnablePreimageRecording
 	}
+	if dec.RPCGasCap != nil {
+		c.RPCGasCap = *dec.RPCGasCap
+	}
+	if dec.RPCEVMTimeout != nil {
+		c.RPCEVMTimeout = *dec.RPCEVMTimeout
+	}
+	if dec.RPCLogsBlockRange != nil {
+		c.RPCLogsBlockRange = *dec.RPCLogsBlockRange
+	}
+	if dec.RPCLogsMaxResults != nil {
+		c.RPCLogsMaxResults = *dec.RPCLogsMaxResults
+	}
+	if dec.RPCUnlockTimeout != nil {
+		c.RPCUnlockTimeout = *dec.RPCUnlockTimeout
+	}
+	if dec.TxApproval != nil {
+		c.TxApproval = *dec.TxApproval
+	}
 	if dec.Istanbul != nil {
 		c.Istanbul = *dec.Istanbul
 	}
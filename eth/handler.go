@@ -73,7 +73,12 @@ type ProtocolManager struct {
 	txpool      txPool
 	blockchain  *core.BlockChain
 	chainconfig *params.ChainConfig
+
+	fingerprint     common.Hash // Chain config fingerprint exchanged during the handshake
+	fingerprintFrom uint        // First protocol version exchanging the fingerprint (0 = never)
+
 	maxPeers    int
+	txBroadcast TxBroadcastConfig // Policy of propagating transactions to peers
 
 	downloader *downloader.Downloader
 	fetcher    *fetcher.Fetcher
@@ -130,6 +135,9 @@ func NewProtocolManager(config *params.ChainConfig, mode downloader.SyncMode, ne
 		manager.fastSync = uint32(1)
 	}
 	protocol := engine.Protocol()
+	if protocol.FingerprintFrom != 0 {
+		manager.fingerprintFrom, manager.fingerprint = protocol.FingerprintFrom, config.Fingerprint()
+	}
 	// Initiate a sub-protocol for every implemented version we can handle
 	manager.SubProtocols = make([]p2p.Protocol, 0, len(protocol.Versions))
 	for i, version := range protocol.Versions {
@@ -170,6 +178,12 @@ func NewProtocolManager(config *params.ChainConfig, mode downloader.SyncMode, ne
 	}
 	// Construct the different synchronisation mechanisms
 	manager.downloader = downloader.New(mode, chaindb, manager.eventMux, blockchain, nil, manager.removePeer)
+	if verifier, ok := engine.(consensus.FinalityVerifier); ok {
+		// Only accept fast sync pivots with a valid finality proof
+		manager.downloader.SetPivotVerifier(func(header *types.Header) error {
+			return verifier.VerifyFinality(blockchain, header)
+		})
+	}
 
 	validator := func(header *types.Header) error {
 		return engine.VerifyHeader(blockchain, header, true)
@@ -272,8 +286,12 @@ func (pm *ProtocolManager) handle(p *peer) error {
 		hash    = head.Hash()
 		number  = head.Number.Uint64()
 		td      = pm.blockchain.GetTd(hash, number)
+		config  common.Hash
 	)
-	if err := p.Handshake(pm.networkId, td, hash, genesis.Hash()); err != nil {
+	if pm.fingerprintFrom != 0 && uint(p.version) >= pm.fingerprintFrom {
+		config = pm.fingerprint
+	}
+	if err := p.Handshake(pm.networkId, td, hash, genesis.Hash(), config); err != nil {
 		p.Log().Debug("Ethereum handshake failed", "err", err)
 		return err
 	}
@@ -287,6 +305,16 @@ func (pm *ProtocolManager) handle(p *peer) error {
 	}
 	defer pm.removePeer(p.id)
 
+	// Let the consensus engine greet the peer if it extends the handshake
+	if handler, ok := pm.engine.(consensus.PeerHandler); ok {
+		if addr, ok := peerAddress(p); ok {
+			if err := handler.PeerConnected(addr, p, uint(p.version)); err != nil {
+				return err
+			}
+			defer handler.PeerDisconnected(addr)
+		}
+	}
+
 	// Register the peer in the downloader. If the downloader considers it banned, we disconnect
 	if err := pm.downloader.RegisterPeer(p.id, p.version, p); err != nil {
 		return err
@@ -705,6 +733,30 @@ func (pm *ProtocolManager) Enqueue(id string, block *types.Block) {
 	pm.fetcher.Enqueue(id, block)
 }
 
+// SyncFrom implements consensus.Synchroniser, synchronising with the peer of the
+// given address up to the head it announced. The total difficulty of the head is
+// extrapolated from the local one, assuming the constant block difficulty of BFT
+// chains.
+func (pm *ProtocolManager) SyncFrom(addr common.Address, head common.Hash, number uint64) {
+	var target *peer
+	for _, p := range pm.peers.Peers() {
+		if a, ok := peerAddress(p); ok && a == addr {
+			target = p
+			break
+		}
+	}
+	current := pm.blockchain.CurrentBlock()
+	if target == nil || number <= current.NumberU64() {
+		return
+	}
+	td := new(big.Int).Mul(current.Difficulty(), new(big.Int).SetUint64(number-current.NumberU64()))
+	td.Add(td, pm.blockchain.GetTd(current.Hash(), current.NumberU64()))
+	if _, ptd := target.Head(); td.Cmp(ptd) > 0 {
+		target.SetHead(head, td)
+	}
+	pm.synchronise(target)
+}
+
 // BroadcastBlock will either propagate a block to a subset of it's peers, or
 // will only announce it's availability (depending what's requested).
 func (pm *ProtocolManager) BroadcastBlock(block *types.Block, propagate bool) {
@@ -742,14 +794,76 @@ func (pm *ProtocolManager) BroadcastBlock(block *types.Block, propagate bool) {
 // already have the given transaction.
 func (pm *ProtocolManager) BroadcastTx(hash common.Hash, tx *types.Transaction) {
 	// Broadcast transaction to a batch of peers not knowing about it
-	peers := pm.peers.PeersWithoutTx(hash)
-	//FIXME include this again: peers = peers[:int(math.Sqrt(float64(len(peers))))]
+	peers := pm.txBroadcastPeers(pm.peers.PeersWithoutTx(hash))
 	for _, peer := range peers {
 		peer.SendTransactions(types.Transactions{tx})
 	}
 	log.Trace("Broadcast transaction", "hash", hash, "recipients", len(peers))
 }
 
+// txBroadcastPeers selects the peers to propagate a transaction to out of the
+// ones not knowing about it yet, according to the transaction broadcast policy.
+func (pm *ProtocolManager) txBroadcastPeers(peers []*peer) []*peer {
+	if pm.txBroadcast.ValidatorsOnly {
+		if validators := pm.currentValidators(); validators != nil {
+			eligible := make([]*peer, 0, len(peers))
+			for _, p := range peers {
+				if addr, ok := peerAddress(p); ok && validators[addr] {
+					eligible = append(eligible, p)
+				}
+			}
+			peers = eligible
+		}
+	}
+	if pm.txBroadcast.SqrtFanout && len(peers) > 0 {
+		peers = peers[:int(math.Ceil(math.Sqrt(float64(len(peers)))))]
+	}
+	return peers
+}
+
+// txBroadcastTo reports whether transactions are propagated to the given peer
+// at all under the transaction broadcast policy.
+func (pm *ProtocolManager) txBroadcastTo(p *peer) bool {
+	if !pm.txBroadcast.ValidatorsOnly {
+		return true
+	}
+	validators := pm.currentValidators()
+	if validators == nil {
+		return true
+	}
+	addr, ok := peerAddress(p)
+	return ok && validators[addr]
+}
+
+// currentValidators returns the set of validators authorized to seal the next
+// block, or nil if the consensus engine has no notion of validators or the set
+// is not known.
+func (pm *ProtocolManager) currentValidators() map[common.Address]bool {
+	engine, ok := pm.engine.(consensus.Istanbul)
+	if !ok {
+		return nil
+	}
+	list, err := engine.GetValidatorsAt(pm.blockchain.CurrentBlock().NumberU64())
+	if err != nil {
+		log.Debug("Failed to retrieve validators for transaction broadcast", "err", err)
+		return nil
+	}
+	validators := make(map[common.Address]bool, len(list))
+	for _, addr := range list {
+		validators[addr] = true
+	}
+	return validators
+}
+
+// peerAddress derives the account address of the key a peer is identified by.
+func peerAddress(p *peer) (common.Address, bool) {
+	pubKey, err := p.ID().Pubkey()
+	if err != nil {
+		return common.Address{}, false
+	}
+	return crypto.PubkeyToAddress(*pubKey), true
+}
+
 // Mined broadcast loop
 func (self *ProtocolManager) minedBroadcastLoop() {
 	// automatically stops if unsubscribe
@@ -782,6 +896,7 @@ type NodeInfo struct {
 	Difficulty *big.Int            `json:"difficulty"` // Total difficulty of the host's blockchain
 	Genesis    common.Hash         `json:"genesis"`    // SHA3 hash of the host's genesis block
 	Config     *params.ChainConfig `json:"config"`     // Chain configuration for the fork rules
+	ConfigHash common.Hash         `json:"configHash"` // Fingerprint of the chain configuration
 	Head       common.Hash         `json:"head"`       // SHA3 hash of the host's best owned block
 }
 
@@ -793,6 +908,7 @@ func (self *ProtocolManager) NodeInfo() *NodeInfo {
 		Difficulty: self.blockchain.GetTd(currentBlock.Hash(), currentBlock.NumberU64()),
 		Genesis:    self.blockchain.Genesis().Hash(),
 		Config:     self.blockchain.Config(),
+		ConfigHash: self.blockchain.Config().Fingerprint(),
 		Head:       currentBlock.Hash(),
 	}
 }
@@ -800,12 +916,7 @@ func (self *ProtocolManager) NodeInfo() *NodeInfo {
 func (self *ProtocolManager) FindPeers(targets map[common.Address]bool) map[common.Address]consensus.Peer {
 	m := make(map[common.Address]consensus.Peer)
 	for _, p := range self.peers.Peers() {
-		pubKey, err := p.ID().Pubkey()
-		if err != nil {
-			continue
-		}
-		addr := crypto.PubkeyToAddress(*pubKey)
-		if targets[addr] {
+		if addr, ok := peerAddress(p); ok && targets[addr] {
 			m[addr] = p
 		}
 	}
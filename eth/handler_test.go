@@ -35,6 +35,7 @@ import (
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/event"
 	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/discover"
 	"github.com/ethereum/go-ethereum/params"
 )
 
@@ -521,3 +522,81 @@ func testDAOChallenge(t *testing.T, localForked, remoteForked bool, timeout bool
 		}
 	}
 }
+
+// validatorEngine is a consensus engine reporting a fixed set of validators.
+type validatorEngine struct {
+	consensus.Engine
+	validators []common.Address
+}
+
+func (e *validatorEngine) Start(consensus.ChainReader, func() *types.Block, func(common.Hash) bool) error {
+	return nil
+}
+func (e *validatorEngine) Stop() error                                      { return nil }
+func (e *validatorEngine) GetValidatorsAt(uint64) ([]common.Address, error) { return e.validators, nil }
+func (e *validatorEngine) SetProposalValidator(consensus.ProposalValidator) {}
+func (e *validatorEngine) IsUpgradeActive(string, uint64) bool              { return false }
+func (e *validatorEngine) Authorize(func([]byte) ([]byte, error))           {}
+
+// Tests that transactions are only propagated to the peers selected by the
+// transaction broadcast policy.
+func TestTxBroadcastPolicy(t *testing.T) {
+	pm, _ := newTestProtocolManagerMust(t, downloader.FullSync, 0, nil, nil)
+	defer pm.Stop()
+
+	// Create a batch of peers, the first four of them being validators
+	var (
+		peers      []*peer
+		validators []common.Address
+	)
+	for i := 0; i < 9; i++ {
+		key, _ := crypto.GenerateKey()
+		peers = append(peers, pm.newPeer(63, p2p.NewPeer(discover.PubkeyID(&key.PublicKey), "", nil), nil))
+		if i < 4 {
+			validators = append(validators, crypto.PubkeyToAddress(key.PublicKey))
+		}
+	}
+	isValidator := func(p *peer) bool {
+		addr, _ := peerAddress(p)
+		for _, validator := range validators {
+			if addr == validator {
+				return true
+			}
+		}
+		return false
+	}
+	tests := []struct {
+		policy     TxBroadcastConfig
+		istanbul   bool
+		recipients int
+	}{
+		{TxBroadcastConfig{}, true, 9},
+		{TxBroadcastConfig{ValidatorsOnly: true}, false, 9},
+		{TxBroadcastConfig{ValidatorsOnly: true}, true, 4},
+		{TxBroadcastConfig{SqrtFanout: true}, true, 3},
+		{TxBroadcastConfig{ValidatorsOnly: true, SqrtFanout: true}, true, 2},
+	}
+	for i, tt := range tests {
+		pm.engine = ethash.NewFaker()
+		if tt.istanbul {
+			pm.engine = &validatorEngine{Engine: pm.engine, validators: validators}
+		}
+		pm.txBroadcast = tt.policy
+
+		recipients := pm.txBroadcastPeers(append([]*peer{}, peers...))
+		if len(recipients) != tt.recipients {
+			t.Errorf("test %d: recipient count mismatch: have %d, want %d", i, len(recipients), tt.recipients)
+		}
+		onlyValidators := tt.policy.ValidatorsOnly && tt.istanbul
+		for _, p := range recipients {
+			if onlyValidators && !isValidator(p) {
+				t.Errorf("test %d: transaction sent to non-validator %v", i, p.id)
+			}
+		}
+		for _, p := range peers {
+			if sync := pm.txBroadcastTo(p); sync != (!onlyValidators || isValidator(p)) {
+				t.Errorf("test %d: peer %v sync mismatch: have %v, validator %v", i, p.id, sync, isValidator(p))
+			}
+		}
+	}
+}
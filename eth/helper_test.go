@@ -171,21 +171,36 @@ func newTestPeer(name string, version int, pm *ProtocolManager, shake bool) (*te
 			head    = pm.blockchain.CurrentHeader()
 			td      = pm.blockchain.GetTd(head.Hash(), head.Number.Uint64())
 		)
-		tp.handshake(nil, td, head.Hash(), genesis.Hash())
+		var config common.Hash
+		if pm.fingerprintFrom != 0 && uint(version) >= pm.fingerprintFrom {
+			config = pm.fingerprint
+		}
+		tp.handshake(nil, td, head.Hash(), genesis.Hash(), config)
 	}
 	return tp, errc
 }
 
 // handshake simulates a trivial handshake that expects the same state from the
-// remote side as we are simulating locally.
-func (p *testPeer) handshake(t *testing.T, td *big.Int, head common.Hash, genesis common.Hash) {
-	msg := &statusData{
+// remote side as we are simulating locally, including the chain config
+// fingerprint if non-zero.
+func (p *testPeer) handshake(t *testing.T, td *big.Int, head common.Hash, genesis common.Hash, config common.Hash) {
+	var msg interface{} = &statusData{
 		ProtocolVersion: uint32(p.version),
 		NetworkId:       DefaultConfig.NetworkId,
 		TD:              td,
 		CurrentBlock:    head,
 		GenesisBlock:    genesis,
 	}
+	if config != (common.Hash{}) {
+		msg = &statusData163{
+			ProtocolVersion: uint32(p.version),
+			NetworkId:       DefaultConfig.NetworkId,
+			TD:              td,
+			CurrentBlock:    head,
+			GenesisBlock:    genesis,
+			ChainConfig:     config,
+		}
+	}
 	if err := p2p.ExpectMsg(p.app, StatusMsg, msg); err != nil {
 		t.Fatalf("status recv: %v", err)
 	}
@@ -0,0 +1,126 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package indexer
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// AddressIndexName is the name of the index of transactions per account.
+const AddressIndexName = "addresses"
+
+// addressTxList is the account list holding the transactions per account.
+var addressTxList = accountList("ia")
+
+// AddressTx is an entry of the address index, referencing a transaction sent
+// by, sent to or creating an account.
+type AddressTx struct {
+	BlockNumber uint64
+	BlockHash   common.Hash
+	TxHash      common.Hash
+	TxIndex     uint64
+}
+
+// AddressIndex indexes the transactions sent by, sent to and creating each
+// account.
+type AddressIndex struct {
+	config *params.ChainConfig
+}
+
+// NewAddressIndex creates an index of the transactions per account.
+func NewAddressIndex(config *params.ChainConfig) *AddressIndex {
+	return &AddressIndex{config: config}
+}
+
+// Name implements Index.
+func (idx *AddressIndex) Name() string {
+	return AddressIndexName
+}
+
+// Process implements Index, recording each transaction for its sender, its
+// recipient and the contract it created, if any.
+func (idx *AddressIndex) Process(db ethdb.Database, batch ethdb.Batch, blocks []*types.Block, receipts []types.Receipts) error {
+	writer := newListWriter(addressTxList, db, batch)
+
+	for i, block := range blocks {
+		signer := types.MakeSigner(idx.config, block.Number())
+		for j, tx := range block.Transactions() {
+			entry := &AddressTx{
+				BlockNumber: block.NumberU64(),
+				BlockHash:   block.Hash(),
+				TxHash:      tx.Hash(),
+				TxIndex:     uint64(j),
+			}
+			payload, err := rlp.EncodeToBytes(entry)
+			if err != nil {
+				return err
+			}
+			from, err := types.Sender(signer, tx)
+			if err != nil {
+				return err
+			}
+			accounts := []common.Address{from}
+			if to := tx.To(); to != nil && *to != from {
+				accounts = append(accounts, *to)
+			}
+			// Contract addresses are only known from the receipts, which might
+			// have been pruned already
+			if tx.To() == nil {
+				if j < len(receipts[i]) {
+					accounts = append(accounts, receipts[i][j].ContractAddress)
+				} else {
+					log.Debug("Missing receipt of contract creation", "block", block.NumberU64(), "tx", tx.Hash())
+				}
+			}
+			for _, account := range accounts {
+				if err := writer.append(account, block.NumberU64(), payload); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// ReadAddressTxs retrieves up to limit transactions of an account included in
+// the canonical blocks from..to, in chain order.
+func ReadAddressTxs(db ethdb.Database, addr common.Address, from, to uint64, limit int) []*AddressTx {
+	var (
+		txs  []*AddressTx
+		seen = make(map[common.Hash]bool)
+	)
+	addressTxList.iterate(db, addr, from, to, func(payload []byte) bool {
+		entry := new(AddressTx)
+		if err := rlp.DecodeBytes(payload, entry); err != nil {
+			log.Error("Invalid address index entry", "addr", addr, "err", err)
+			return false
+		}
+		if seen[entry.TxHash] || core.GetCanonicalHash(db, entry.BlockNumber) != entry.BlockHash {
+			return true
+		}
+		seen[entry.TxHash] = true
+		txs = append(txs, entry)
+		return len(txs) < limit
+	})
+	return txs
+}
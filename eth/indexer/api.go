@@ -0,0 +1,126 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package indexer
+
+import (
+	"math"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// maxQueryResults is the maximum number of entries returned by a single index
+// query, larger result sets need to be paged through by block range.
+const maxQueryResults = 1000
+
+// PublicIndexerAPI provides access to the secondary chain indexes.
+type PublicIndexerAPI struct {
+	indexer *Indexer
+}
+
+// NewPublicIndexerAPI creates a new API exposing the indexes of the indexer.
+func NewPublicIndexerAPI(indexer *Indexer) *PublicIndexerAPI {
+	return &PublicIndexerAPI{indexer: indexer}
+}
+
+// Status returns the number of leading chain blocks covered by each enabled
+// index.
+func (api *PublicIndexerAPI) Status() map[string]hexutil.Uint64 {
+	status := make(map[string]hexutil.Uint64)
+	for _, name := range api.indexer.Names() {
+		indexed, _ := api.indexer.Indexed(name)
+		status[name] = hexutil.Uint64(indexed)
+	}
+	return status
+}
+
+// AddressTransaction is a transaction sent by, sent to or creating an account.
+type AddressTransaction struct {
+	BlockNumber      hexutil.Uint64 `json:"blockNumber"`
+	BlockHash        common.Hash    `json:"blockHash"`
+	TransactionHash  common.Hash    `json:"transactionHash"`
+	TransactionIndex hexutil.Uint64 `json:"transactionIndex"`
+}
+
+// GetAddressTransactions returns the transactions of an account included in
+// the blocks fromBlock..toBlock, at most limit of them.
+func (api *PublicIndexerAPI) GetAddressTransactions(addr common.Address, fromBlock, toBlock *hexutil.Uint64, limit *int) ([]*AddressTransaction, error) {
+	from, to, max := queryBounds(fromBlock, toBlock, limit)
+
+	txs, err := api.indexer.AddressTxs(addr, from, to, max)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*AddressTransaction, len(txs))
+	for i, tx := range txs {
+		result[i] = &AddressTransaction{
+			BlockNumber:      hexutil.Uint64(tx.BlockNumber),
+			BlockHash:        tx.BlockHash,
+			TransactionHash:  tx.TxHash,
+			TransactionIndex: hexutil.Uint64(tx.TxIndex),
+		}
+	}
+	return result, nil
+}
+
+// InternalTransfer is a transfer of ether made by a contract.
+type InternalTransfer struct {
+	BlockNumber     hexutil.Uint64 `json:"blockNumber"`
+	BlockHash       common.Hash    `json:"blockHash"`
+	TransactionHash common.Hash    `json:"transactionHash"`
+	From            common.Address `json:"from"`
+	To              common.Address `json:"to"`
+	Value           *hexutil.Big   `json:"value"`
+}
+
+// GetInternalTransfers returns the internal transfers from or to an account
+// made in the blocks fromBlock..toBlock, at most limit of them.
+func (api *PublicIndexerAPI) GetInternalTransfers(addr common.Address, fromBlock, toBlock *hexutil.Uint64, limit *int) ([]*InternalTransfer, error) {
+	from, to, max := queryBounds(fromBlock, toBlock, limit)
+
+	transfers, err := api.indexer.Transfers(addr, from, to, max)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*InternalTransfer, len(transfers))
+	for i, transfer := range transfers {
+		result[i] = &InternalTransfer{
+			BlockNumber:     hexutil.Uint64(transfer.BlockNumber),
+			BlockHash:       transfer.BlockHash,
+			TransactionHash: transfer.TxHash,
+			From:            transfer.From,
+			To:              transfer.To,
+			Value:           (*hexutil.Big)(transfer.Value),
+		}
+	}
+	return result, nil
+}
+
+// queryBounds resolves the optional block range and result limit of a query.
+func queryBounds(fromBlock, toBlock *hexutil.Uint64, limit *int) (uint64, uint64, int) {
+	from, to, max := uint64(0), uint64(math.MaxUint64), maxQueryResults
+	if fromBlock != nil {
+		from = uint64(*fromBlock)
+	}
+	if toBlock != nil {
+		to = uint64(*toBlock)
+	}
+	if limit != nil && *limit > 0 && *limit < max {
+		max = *limit
+	}
+	return from, to, max
+}
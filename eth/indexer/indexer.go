@@ -0,0 +1,243 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package indexer maintains secondary indexes over the finalized sections of
+// the canonical chain, such as the transactions or internal value transfers
+// touching an account.
+package indexer
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+)
+
+const (
+	// SectionSize is the number of blocks handed to the indexes at once. It is
+	// kept small so that the indexes follow the head closely on BFT chains.
+	SectionSize = 64
+
+	// indexThrottling is the time to wait between processing two consecutive
+	// sections, preventing disk overload when catching up with a long chain.
+	indexThrottling = 10 * time.Millisecond
+)
+
+// indexMetaPrefix is the database key prefix under which the progress of the
+// secondary indexes is tracked, followed by the name of the index.
+var indexMetaPrefix = []byte("iX")
+
+var (
+	errIndexerStarted = errors.New("indexer already started")
+	errUnknownIndex   = errors.New("index not enabled")
+)
+
+// Index is a secondary index maintained over the canonical chain. The indexer
+// invokes it with the blocks of each section once they are final (or have
+// enough confirmations on chains without finality), in chain order.
+type Index interface {
+	// Name returns the unique name of the index, used to track its progress.
+	Name() string
+
+	// Process indexes the blocks of a chain section, writing all entries into
+	// the batch. The section is marked done only if the batch was written.
+	Process(db ethdb.Database, batch ethdb.Batch, blocks []*types.Block, receipts []types.Receipts) error
+}
+
+// Indexer runs a set of registered secondary indexes in the background, each
+// progressing through the chain independently so that newly enabled indexes
+// catch up without affecting existing ones.
+type Indexer struct {
+	db       ethdb.Database
+	confirms uint64
+
+	indexes  map[string]Index
+	indexers map[string]*core.ChainIndexer
+	started  bool
+	lock     sync.RWMutex
+}
+
+// New creates an indexer processing sections of the chain stored in db after
+// the given number of confirmations.
+func New(db ethdb.Database, confirms uint64) *Indexer {
+	return &Indexer{
+		db:       db,
+		confirms: confirms,
+		indexes:  make(map[string]Index),
+		indexers: make(map[string]*core.ChainIndexer),
+	}
+}
+
+// Register adds an index to be maintained. Indexes must be registered before
+// the indexer is started.
+func (ix *Indexer) Register(index Index) error {
+	ix.lock.Lock()
+	defer ix.lock.Unlock()
+
+	if ix.started {
+		return errIndexerStarted
+	}
+	name := index.Name()
+	if _, ok := ix.indexes[name]; ok {
+		return fmt.Errorf("index %q already registered", name)
+	}
+	table := ethdb.NewTable(ix.db, string(indexMetaPrefix)+name+"-")
+	backend := &sectionProcessor{db: ix.db, index: index}
+
+	ix.indexes[name] = index
+	ix.indexers[name] = core.NewChainIndexer(ix.db, table, backend, SectionSize, ix.confirms, indexThrottling, "index-"+name)
+	return nil
+}
+
+// Start starts processing the chain with all registered indexes.
+func (ix *Indexer) Start(chain core.ChainIndexerChain) {
+	ix.lock.Lock()
+	defer ix.lock.Unlock()
+
+	for _, indexer := range ix.indexers {
+		indexer.Start(chain)
+	}
+	ix.started = true
+}
+
+// Close stops all background processing.
+func (ix *Indexer) Close() error {
+	ix.lock.RLock()
+	defer ix.lock.RUnlock()
+
+	var errs []error
+	for _, indexer := range ix.indexers {
+		if err := indexer.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	switch len(errs) {
+	case 0:
+		return nil
+	case 1:
+		return errs[0]
+	default:
+		return fmt.Errorf("%v", errs)
+	}
+}
+
+// Names returns the sorted names of the registered indexes.
+func (ix *Indexer) Names() []string {
+	ix.lock.RLock()
+	defer ix.lock.RUnlock()
+
+	names := make([]string, 0, len(ix.indexes))
+	for name := range ix.indexes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Index returns the registered index with the given name, or nil if the index
+// is not enabled.
+func (ix *Indexer) Index(name string) Index {
+	ix.lock.RLock()
+	defer ix.lock.RUnlock()
+
+	return ix.indexes[name]
+}
+
+// Indexed returns the number of leading blocks of the chain covered by the
+// named index.
+func (ix *Indexer) Indexed(name string) (uint64, error) {
+	ix.lock.RLock()
+	indexer, ok := ix.indexers[name]
+	ix.lock.RUnlock()
+
+	if !ok {
+		return 0, errUnknownIndex
+	}
+	sections, _, _ := indexer.Sections()
+	return sections * SectionSize, nil
+}
+
+// AddressTxs retrieves up to limit transactions of an account included in the
+// canonical blocks from..to, failing if the address index is not enabled.
+func (ix *Indexer) AddressTxs(addr common.Address, from, to uint64, limit int) ([]*AddressTx, error) {
+	if ix.Index(AddressIndexName) == nil {
+		return nil, errUnknownIndex
+	}
+	return ReadAddressTxs(ix.db, addr, from, to, limit), nil
+}
+
+// Transfers retrieves up to limit internal transfers from or to an account made
+// in the canonical blocks from..to, failing if the transfer index is not enabled.
+func (ix *Indexer) Transfers(addr common.Address, from, to uint64, limit int) ([]*Transfer, error) {
+	if ix.Index(TransferIndexName) == nil {
+		return nil, errUnknownIndex
+	}
+	return ReadTransfers(ix.db, addr, from, to, limit), nil
+}
+
+// sectionProcessor implements core.ChainIndexerBackend, gathering the blocks of
+// a section and handing them to a secondary index at once.
+type sectionProcessor struct {
+	db    ethdb.Database
+	index Index
+
+	blocks   []*types.Block
+	receipts []types.Receipts
+	err      error
+}
+
+// Reset implements core.ChainIndexerBackend, starting a new section.
+func (p *sectionProcessor) Reset(section uint64, lastSectionHead common.Hash) error {
+	p.blocks, p.receipts, p.err = nil, nil, nil
+	return nil
+}
+
+// Process implements core.ChainIndexerBackend, loading the next block of the
+// section. Errors are deferred until the section is committed.
+func (p *sectionProcessor) Process(header *types.Header) {
+	if p.err != nil {
+		return
+	}
+	hash, number := header.Hash(), header.Number.Uint64()
+
+	block := core.GetBlock(p.db, hash, number)
+	if block == nil {
+		p.err = fmt.Errorf("block #%d [%x…] missing", number, hash[:4])
+		return
+	}
+	p.blocks = append(p.blocks, block)
+	p.receipts = append(p.receipts, core.GetBlockReceipts(p.db, hash, number))
+}
+
+// Commit implements core.ChainIndexerBackend, running the index over the blocks
+// of the section and writing out its entries.
+func (p *sectionProcessor) Commit() error {
+	if p.err != nil {
+		return p.err
+	}
+	batch := p.db.NewBatch()
+	if err := p.index.Process(p.db, batch, p.blocks, p.receipts); err != nil {
+		return err
+	}
+	p.blocks, p.receipts = nil, nil
+	return batch.Write()
+}
@@ -0,0 +1,157 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package indexer
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// forwarderCode returns contract code forwarding the received value to the
+// given address, reverting afterwards if requested.
+func forwarderCode(to common.Address, revert bool) []byte {
+	code := []byte{0x60, 0x00, 0x60, 0x00, 0x60, 0x00, 0x60, 0x00, byte(vm.CALLVALUE), byte(vm.PUSH20)}
+	code = append(code, to.Bytes()...)
+	code = append(code, byte(vm.GAS), byte(vm.CALL), byte(vm.POP))
+	if revert {
+		return append(code, 0x60, 0x00, 0x60, 0x00, byte(vm.REVERT))
+	}
+	return append(code, byte(vm.STOP))
+}
+
+// Tests that the address and transfer indexes pick up the transactions and the
+// internal transfers of finished sections, skipping reverted calls.
+func TestIndexes(t *testing.T) {
+	var (
+		key, _    = crypto.GenerateKey()
+		sender    = crypto.PubkeyToAddress(key.PublicKey)
+		recipient = common.HexToAddress("0x1000000000000000000000000000000000000001")
+		forwarder = common.HexToAddress("0x2000000000000000000000000000000000000002")
+		reverter  = common.HexToAddress("0x3000000000000000000000000000000000000003")
+		caller    = common.HexToAddress("0x4000000000000000000000000000000000000004")
+		signer    = types.HomesteadSigner{}
+	)
+	genesis := &core.Genesis{
+		Config: params.TestChainConfig,
+		Alloc: core.GenesisAlloc{
+			sender:    {Balance: big.NewInt(params.Ether)},
+			forwarder: {Code: forwarderCode(recipient, false), Balance: new(big.Int)},
+			reverter:  {Code: forwarderCode(forwarder, true), Balance: new(big.Int)},
+			caller:    {Code: forwarderCode(reverter, false), Balance: new(big.Int)},
+		},
+	}
+	var (
+		db, _    = ethdb.NewMemDatabase()
+		gendb, _ = ethdb.NewMemDatabase()
+		parent   = genesis.MustCommit(gendb)
+	)
+	genesis.MustCommit(db)
+
+	blocks, _ := core.GenerateChain(params.TestChainConfig, parent, ethash.NewFaker(), gendb, SectionSize, func(i int, gen *core.BlockGen) {
+		var to common.Address
+		switch i {
+		case 1:
+			to = forwarder
+		case 2:
+			to = caller
+		default:
+			return
+		}
+		tx, _ := types.SignTx(types.NewTransaction(gen.TxNonce(sender), to, big.NewInt(1000), 200000, new(big.Int), nil), signer, key)
+		gen.AddTx(tx)
+	})
+	chain, err := core.NewBlockChain(db, &core.CacheConfig{Disabled: true}, params.TestChainConfig, ethash.NewFaker(), vm.Config{})
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	defer chain.Stop()
+
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	indexer := New(db, 0)
+	if err := indexer.Register(NewAddressIndex(params.TestChainConfig)); err != nil {
+		t.Fatalf("failed to register address index: %v", err)
+	}
+	if err := indexer.Register(NewTransferIndex(chain)); err != nil {
+		t.Fatalf("failed to register transfer index: %v", err)
+	}
+	if err := indexer.Register(NewAddressIndex(params.TestChainConfig)); err == nil {
+		t.Fatalf("duplicate index registered")
+	}
+	indexer.Start(chain)
+	defer indexer.Close()
+
+	for _, name := range indexer.Names() {
+		for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+			if indexed, _ := indexer.Indexed(name); indexed == SectionSize {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("index %s: section not processed in time", name)
+			}
+		}
+	}
+	// Check the transactions of the involved accounts
+	if txs := ReadAddressTxs(db, sender, 0, SectionSize, maxQueryResults); len(txs) != 2 {
+		t.Errorf("sender transactions mismatch: have %d, want 2", len(txs))
+	}
+	txs := ReadAddressTxs(db, forwarder, 0, SectionSize, maxQueryResults)
+	if len(txs) != 1 || txs[0].TxHash != blocks[1].Transactions()[0].Hash() {
+		t.Errorf("forwarder transactions mismatch: have %v", txs)
+	}
+	if txs := ReadAddressTxs(db, sender, 3, SectionSize, maxQueryResults); len(txs) != 1 || txs[0].BlockNumber != 3 {
+		t.Errorf("ranged sender transactions mismatch: have %v", txs)
+	}
+	// Check that only the transfer of the successful call was recorded
+	transfers := ReadTransfers(db, recipient, 0, SectionSize, maxQueryResults)
+	if len(transfers) != 1 {
+		t.Fatalf("recipient transfers mismatch: have %d, want 1", len(transfers))
+	}
+	if transfer := transfers[0]; transfer.From != forwarder || transfer.Value.Int64() != 1000 || transfer.BlockNumber != 2 {
+		t.Errorf("recipient transfer mismatch: have %+v", transfer)
+	}
+	for _, addr := range []common.Address{caller, reverter} {
+		if transfers := ReadTransfers(db, addr, 0, SectionSize, maxQueryResults); len(transfers) != 0 {
+			t.Errorf("reverted transfers of %x recorded: %v", addr, transfers)
+		}
+	}
+	// Check the queries through the indexer, requiring their index to be enabled
+	if txs, err := indexer.AddressTxs(sender, 0, SectionSize, 1); err != nil || len(txs) != 1 || txs[0].BlockNumber != 2 {
+		t.Errorf("limited sender transactions mismatch: have %v (%v)", txs, err)
+	}
+	if transfers, err := indexer.Transfers(recipient, 0, SectionSize, maxQueryResults); err != nil || len(transfers) != 1 {
+		t.Errorf("recipient transfers mismatch: have %v (%v)", transfers, err)
+	}
+	disabled := New(db, 0)
+	if _, err := disabled.AddressTxs(sender, 0, SectionSize, maxQueryResults); err != errUnknownIndex {
+		t.Errorf("address query without index error mismatch: have %v, want %v", err, errUnknownIndex)
+	}
+	if _, err := disabled.Transfers(recipient, 0, SectionSize, maxQueryResults); err != errUnknownIndex {
+		t.Errorf("transfer query without index error mismatch: have %v, want %v", err, errUnknownIndex)
+	}
+}
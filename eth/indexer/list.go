@@ -0,0 +1,132 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package indexer
+
+import (
+	"encoding/binary"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethdb"
+)
+
+// accountList is an append-only list of index entries per account, ordered by
+// block number. The database holds the entry count of each account under
+// prefix + address and the entries under prefix + address + sequence number,
+// each value starting with the big endian number of the block it belongs to.
+//
+// Entries are only ever appended, so blocks reorged out of the chain or
+// sections indexed twice leave stale entries behind. Readers are expected to
+// skip entries of non-canonical blocks and duplicates.
+type accountList []byte
+
+// countKey returns the database key of the entry count of an account.
+func (l accountList) countKey(addr common.Address) []byte {
+	return append(append([]byte{}, l...), addr.Bytes()...)
+}
+
+// entryKey returns the database key of an entry of an account.
+func (l accountList) entryKey(addr common.Address, seq uint64) []byte {
+	key := make([]byte, len(l)+common.AddressLength+8)
+	copy(key, l)
+	copy(key[len(l):], addr.Bytes())
+	binary.BigEndian.PutUint64(key[len(l)+common.AddressLength:], seq)
+	return key
+}
+
+// count returns the number of entries stored for an account.
+func (l accountList) count(db ethdb.Database, addr common.Address) uint64 {
+	data, _ := db.Get(l.countKey(addr))
+	if len(data) != 8 {
+		return 0
+	}
+	return binary.BigEndian.Uint64(data)
+}
+
+// entry retrieves an entry of an account, returning the block number it was
+// recorded at and its payload.
+func (l accountList) entry(db ethdb.Database, addr common.Address, seq uint64) (uint64, []byte, bool) {
+	data, _ := db.Get(l.entryKey(addr, seq))
+	if len(data) < 8 {
+		return 0, nil, false
+	}
+	return binary.BigEndian.Uint64(data[:8]), data[8:], true
+}
+
+// iterate calls fn with the payload of the entries of an account recorded for
+// the blocks from..to in chain order, until fn returns false.
+func (l accountList) iterate(db ethdb.Database, addr common.Address, from, to uint64, fn func(payload []byte) bool) {
+	count := l.count(db, addr)
+
+	// Binary search the first entry of the requested range
+	start := sort.Search(int(count), func(i int) bool {
+		number, _, ok := l.entry(db, addr, uint64(i))
+		return !ok || number >= from
+	})
+	for seq := uint64(start); seq < count; seq++ {
+		number, payload, ok := l.entry(db, addr, seq)
+		if !ok || number > to {
+			return
+		}
+		// Stale entries of reindexed sections may precede the range
+		if number < from {
+			continue
+		}
+		if !fn(payload) {
+			return
+		}
+	}
+}
+
+// listWriter appends entries to an account list through a database batch,
+// tracking the entry counts not yet written to the database.
+type listWriter struct {
+	list   accountList
+	db     ethdb.Database
+	batch  ethdb.Batch
+	counts map[common.Address]uint64
+}
+
+// newListWriter creates a writer appending to the list through the batch.
+func newListWriter(list accountList, db ethdb.Database, batch ethdb.Batch) *listWriter {
+	return &listWriter{
+		list:   list,
+		db:     db,
+		batch:  batch,
+		counts: make(map[common.Address]uint64),
+	}
+}
+
+// append adds an entry recorded at the given block to the list of an account.
+func (w *listWriter) append(addr common.Address, number uint64, payload []byte) error {
+	seq, ok := w.counts[addr]
+	if !ok {
+		seq = w.list.count(w.db, addr)
+	}
+	value := make([]byte, 8+len(payload))
+	binary.BigEndian.PutUint64(value, number)
+	copy(value[8:], payload)
+
+	if err := w.batch.Put(w.list.entryKey(addr, seq), value); err != nil {
+		return err
+	}
+	w.counts[addr] = seq + 1
+
+	count := make([]byte, 8)
+	binary.BigEndian.PutUint64(count, seq+1)
+	return w.batch.Put(w.list.countKey(addr), count)
+}
@@ -0,0 +1,257 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package indexer
+
+import (
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// TransferIndexName is the name of the index of internal value transfers.
+const TransferIndexName = "transfers"
+
+// transferList is the account list holding the internal transfers per account.
+var transferList = accountList("it")
+
+// Transfer is an entry of the transfer index, an internal transfer of ether
+// made by a contract while executing a transaction.
+type Transfer struct {
+	BlockNumber uint64
+	BlockHash   common.Hash
+	TxHash      common.Hash
+	Index       uint64 // Position of the transfer among those of the transaction
+	From        common.Address
+	To          common.Address
+	Value       *big.Int
+}
+
+// TransferIndex indexes the ether transferred by contracts during execution,
+// i.e. the value transfers not visible in the transactions themselves. The
+// blocks are re-executed to find them, which requires the state of all blocks
+// to be retained.
+type TransferIndex struct {
+	chain *core.BlockChain
+}
+
+// NewTransferIndex creates an index of the internal transfers per account.
+func NewTransferIndex(chain *core.BlockChain) *TransferIndex {
+	return &TransferIndex{chain: chain}
+}
+
+// Name implements Index.
+func (idx *TransferIndex) Name() string {
+	return TransferIndexName
+}
+
+// Process implements Index, re-executing the transactions of each block and
+// recording the internal transfers for their sender and recipient.
+func (idx *TransferIndex) Process(db ethdb.Database, batch ethdb.Batch, blocks []*types.Block, receipts []types.Receipts) error {
+	writer := newListWriter(transferList, db, batch)
+
+	for _, block := range blocks {
+		transfers, err := idx.blockTransfers(block)
+		if err != nil {
+			return err
+		}
+		for _, transfer := range transfers {
+			payload, err := rlp.EncodeToBytes(transfer)
+			if err != nil {
+				return err
+			}
+			if err := writer.append(transfer.From, block.NumberU64(), payload); err != nil {
+				return err
+			}
+			if transfer.To != transfer.From {
+				if err := writer.append(transfer.To, block.NumberU64(), payload); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// blockTransfers re-executes a block on top of its parent state, collecting the
+// internal transfers of its successful transactions.
+func (idx *TransferIndex) blockTransfers(block *types.Block) ([]*Transfer, error) {
+	if len(block.Transactions()) == 0 {
+		return nil, nil
+	}
+	parent := idx.chain.GetHeader(block.ParentHash(), block.NumberU64()-1)
+	if parent == nil {
+		return nil, fmt.Errorf("parent of block #%d missing", block.NumberU64())
+	}
+	statedb, err := idx.chain.StateAt(parent.Root)
+	if err != nil {
+		return nil, fmt.Errorf("state of block #%d missing: %v", parent.Number, err)
+	}
+	var (
+		transfers []*Transfer
+		header    = block.Header()
+		gp        = new(core.GasPool).AddGas(block.GasLimit())
+		usedGas   = new(uint64)
+	)
+	for i, tx := range block.Transactions() {
+		statedb.Prepare(tx.Hash(), block.Hash(), i)
+
+		tracer := newTransferTracer()
+		receipt, _, err := core.ApplyTransaction(idx.chain.Config(), idx.chain, nil, gp, statedb, header, tx, usedGas, vm.Config{Debug: true, Tracer: tracer})
+		if err != nil {
+			return nil, fmt.Errorf("failed to re-execute transaction %x: %v", tx.Hash(), err)
+		}
+		if receipt.Status == types.ReceiptStatusFailed {
+			continue
+		}
+		for j, transfer := range tracer.transfers {
+			transfer.BlockNumber, transfer.BlockHash, transfer.TxHash, transfer.Index = block.NumberU64(), block.Hash(), tx.Hash(), uint64(j)
+			transfers = append(transfers, transfer)
+		}
+	}
+	return transfers, nil
+}
+
+// ReadTransfers retrieves up to limit internal transfers from or to an account
+// made in the canonical blocks from..to, in chain order.
+func ReadTransfers(db ethdb.Database, addr common.Address, from, to uint64, limit int) []*Transfer {
+	var (
+		transfers []*Transfer
+		seen      = make(map[string]bool)
+	)
+	transferList.iterate(db, addr, from, to, func(payload []byte) bool {
+		entry := new(Transfer)
+		if err := rlp.DecodeBytes(payload, entry); err != nil {
+			log.Error("Invalid transfer index entry", "addr", addr, "err", err)
+			return false
+		}
+		if seen[string(payload)] || core.GetCanonicalHash(db, entry.BlockNumber) != entry.BlockHash {
+			return true
+		}
+		seen[string(payload)] = true
+		transfers = append(transfers, entry)
+		return len(transfers) < limit
+	})
+	return transfers
+}
+
+// pendingCall is a call made by a contract whose outcome is not yet known.
+type pendingCall struct {
+	depth     int         // Call depth of the calling contract
+	create    bool        // Whether the call creates a contract
+	transfer  *Transfer   // Value transferred by the call, nil if none
+	transfers []*Transfer // Transfers made within the call
+}
+
+// transferTracer is a vm.Tracer collecting the value transfers made by
+// contracts, dropping those of calls that were reverted.
+type transferTracer struct {
+	pending   []*pendingCall
+	transfers []*Transfer
+}
+
+func newTransferTracer() *transferTracer {
+	return new(transferTracer)
+}
+
+// CaptureStart implements vm.Tracer.
+func (t *transferTracer) CaptureStart(from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) error {
+	return nil
+}
+
+// CaptureState implements vm.Tracer, resolving the outcome of finished calls
+// and tracking the ones about to be made.
+func (t *transferTracer) CaptureState(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, memory *vm.Memory, stack *vm.Stack, contract *vm.Contract, depth int, err error) error {
+	if err != nil {
+		return nil
+	}
+	// Calls made from deeper frames which did not return control were aborted
+	for len(t.pending) > 0 && t.pending[len(t.pending)-1].depth > depth {
+		t.pending = t.pending[:len(t.pending)-1]
+	}
+	// A call made from this frame has returned, its result is on the stack
+	if n := len(t.pending); n > 0 && t.pending[n-1].depth == depth {
+		call := t.pending[n-1]
+		t.pending = t.pending[:n-1]
+
+		if data := stack.Data(); len(data) > 0 && data[len(data)-1].Sign() != 0 {
+			if call.transfer != nil {
+				if call.create {
+					call.transfer.To = common.BigToAddress(data[len(data)-1])
+				}
+				t.record(call.transfer)
+			}
+			for _, transfer := range call.transfers {
+				t.record(transfer)
+			}
+		}
+	}
+	switch op {
+	case vm.CALL, vm.CREATE:
+		var (
+			value *big.Int
+			to    common.Address
+		)
+		if op == vm.CALL {
+			value, to = stack.Back(2), common.BigToAddress(stack.Back(1))
+		} else {
+			value = stack.Back(0)
+		}
+		call := &pendingCall{depth: depth, create: op == vm.CREATE}
+		if value.Sign() > 0 {
+			call.transfer = &Transfer{From: contract.Address(), To: to, Value: new(big.Int).Set(value)}
+		}
+		t.pending = append(t.pending, call)
+
+	case vm.CALLCODE, vm.DELEGATECALL, vm.STATICCALL:
+		// No value leaves the contract, but transfers within need tracking
+		t.pending = append(t.pending, &pendingCall{depth: depth})
+
+	case vm.SELFDESTRUCT:
+		if balance := env.StateDB.GetBalance(contract.Address()); balance.Sign() > 0 {
+			t.record(&Transfer{From: contract.Address(), To: common.BigToAddress(stack.Back(0)), Value: balance})
+		}
+	}
+	return nil
+}
+
+// record adds a transfer to the innermost pending call, or to the results if
+// the transfer is made from the outermost frame.
+func (t *transferTracer) record(transfer *Transfer) {
+	if n := len(t.pending); n > 0 {
+		t.pending[n-1].transfers = append(t.pending[n-1].transfers, transfer)
+		return
+	}
+	t.transfers = append(t.transfers, transfer)
+}
+
+// CaptureFault implements vm.Tracer.
+func (t *transferTracer) CaptureFault(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, memory *vm.Memory, stack *vm.Stack, contract *vm.Contract, depth int, err error) error {
+	return nil
+}
+
+// CaptureEnd implements vm.Tracer.
+func (t *transferTracer) CaptureEnd(output []byte, gasUsed uint64, d time.Duration, err error) error {
+	return nil
+}
@@ -235,13 +235,25 @@ func (p *peer) RequestReceipts(hashes []common.Hash) error {
 }
 
 // Handshake executes the eth protocol handshake, negotiating version number,
-// network IDs, difficulties, head and genesis blocks.
-func (p *peer) Handshake(network uint64, td *big.Int, head common.Hash, genesis common.Hash) error {
+// network IDs, difficulties, head and genesis blocks. If config is non-zero,
+// the chain config fingerprints are exchanged and matched too.
+func (p *peer) Handshake(network uint64, td *big.Int, head common.Hash, genesis common.Hash, config common.Hash) error {
 	// Send out own handshake in a new thread
 	errc := make(chan error, 2)
 	var status statusData // safe to read after two values have been received from errc
 
 	go func() {
+		if config != (common.Hash{}) {
+			errc <- p2p.Send(p.rw, StatusMsg, &statusData163{
+				ProtocolVersion: uint32(p.version),
+				NetworkId:       network,
+				TD:              td,
+				CurrentBlock:    head,
+				GenesisBlock:    genesis,
+				ChainConfig:     config,
+			})
+			return
+		}
 		errc <- p2p.Send(p.rw, StatusMsg, &statusData{
 			ProtocolVersion: uint32(p.version),
 			NetworkId:       network,
@@ -251,7 +263,7 @@ func (p *peer) Handshake(network uint64, td *big.Int, head common.Hash, genesis
 		})
 	}()
 	go func() {
-		errc <- p.readStatus(network, &status, genesis)
+		errc <- p.readStatus(network, &status, genesis, config)
 	}()
 	timeout := time.NewTimer(handshakeTimeout)
 	defer timeout.Stop()
@@ -269,7 +281,7 @@ func (p *peer) Handshake(network uint64, td *big.Int, head common.Hash, genesis
 	return nil
 }
 
-func (p *peer) readStatus(network uint64, status *statusData, genesis common.Hash) (err error) {
+func (p *peer) readStatus(network uint64, status *statusData, genesis common.Hash, config common.Hash) (err error) {
 	msg, err := p.rw.ReadMsg()
 	if err != nil {
 		return err
@@ -281,10 +293,23 @@ func (p *peer) readStatus(network uint64, status *statusData, genesis common.Has
 		return errResp(ErrMsgTooLarge, "%v > %v", msg.Size, ProtocolMaxMsgSize)
 	}
 	// Decode the handshake and make sure everything matches
-	if err := msg.Decode(&status); err != nil {
+	var remote common.Hash
+	if config != (common.Hash{}) {
+		var ext statusData163
+		if err := msg.Decode(&ext); err != nil {
+			return errResp(ErrDecode, "msg %v: %v", msg, err)
+		}
+		*status = statusData{ext.ProtocolVersion, ext.NetworkId, ext.TD, ext.CurrentBlock, ext.GenesisBlock}
+		remote = ext.ChainConfig
+	} else if err := msg.Decode(&status); err != nil {
 		return errResp(ErrDecode, "msg %v: %v", msg, err)
 	}
 	if status.GenesisBlock != genesis {
+		// Peers on the same network advertising the config fingerprint are most
+		// probably members of the same chain started from a different genesis.json
+		if config != (common.Hash{}) && status.NetworkId == network {
+			p.Log().Warn("Peer genesis block mismatch, check genesis.json", "local", genesis, "remote", status.GenesisBlock)
+		}
 		return errResp(ErrGenesisBlockMismatch, "%x (!= %x)", status.GenesisBlock[:8], genesis[:8])
 	}
 	if status.NetworkId != network {
@@ -293,6 +318,10 @@ func (p *peer) readStatus(network uint64, status *statusData, genesis common.Has
 	if int(status.ProtocolVersion) != p.version {
 		return errResp(ErrProtocolVersionMismatch, "%d (!= %d)", status.ProtocolVersion, p.version)
 	}
+	if remote != config {
+		p.Log().Warn("Peer chain config mismatch, check genesis.json", "local", config, "remote", remote)
+		return errResp(ErrChainConfigMismatch, "%x (!= %x)", remote[:8], config[:8])
+	}
 	return nil
 }
 
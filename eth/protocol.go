@@ -61,6 +61,7 @@ const (
 	ErrNoStatusMsg
 	ErrExtraStatusMsg
 	ErrSuspendedPeer
+	ErrChainConfigMismatch
 )
 
 func (e errCode) String() string {
@@ -78,6 +79,7 @@ var errorToString = map[int]string{
 	ErrNoStatusMsg:             "No status message",
 	ErrExtraStatusMsg:          "Extra status message",
 	ErrSuspendedPeer:           "Suspended peer",
+	ErrChainConfigMismatch:     "Chain config mismatch",
 }
 
 type txPool interface {
@@ -102,6 +104,17 @@ type statusData struct {
 	GenesisBlock    common.Hash
 }
 
+// statusData163 is the network packet for the status message from eth/163 on,
+// extending the original one with the fingerprint of the chain config.
+type statusData163 struct {
+	ProtocolVersion uint32
+	NetworkId       uint64
+	TD              *big.Int
+	CurrentBlock    common.Hash
+	GenesisBlock    common.Hash
+	ChainConfig     common.Hash
+}
+
 // newBlockHashesData is the network packet for the block announcements.
 type newBlockHashesData []struct {
 	Hash   common.Hash // Hash of one particular block being announced
@@ -18,15 +18,24 @@ package eth
 
 import (
 	"fmt"
+	"math/big"
 	"sync"
 	"testing"
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/consensus/istanbul"
+	istanbulBackend "github.com/ethereum/go-ethereum/consensus/istanbul/backend"
+	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/eth/downloader"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/event"
 	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/params"
 	"github.com/ethereum/go-ethereum/rlp"
 )
 
@@ -40,6 +49,106 @@ var testAccount, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6
 func TestStatusMsgErrors62(t *testing.T) { testStatusMsgErrors(t, 62) }
 func TestStatusMsgErrors63(t *testing.T) { testStatusMsgErrors(t, 63) }
 
+// Tests that eth/163 handshakes reject peers with a different chain config.
+func TestStatusMsgErrors163(t *testing.T) {
+	pm, _ := newTestProtocolManagerMust(t, downloader.FullSync, 0, nil, nil)
+	var (
+		genesis = pm.blockchain.Genesis()
+		head    = pm.blockchain.CurrentHeader()
+		td      = pm.blockchain.GetTd(head.Hash(), head.Number.Uint64())
+		config  = pm.blockchain.Config().Fingerprint()
+	)
+	defer pm.Stop()
+
+	tests := []struct {
+		code      uint64
+		data      interface{}
+		wantError error
+	}{
+		{
+			code: StatusMsg, data: statusData163{163, DefaultConfig.NetworkId, td, head.Hash(), common.Hash{3}, config},
+			wantError: errResp(ErrGenesisBlockMismatch, "0300000000000000 (!= %x)", genesis.Hash().Bytes()[:8]),
+		},
+		{
+			code: StatusMsg, data: statusData163{163, DefaultConfig.NetworkId, td, head.Hash(), genesis.Hash(), common.Hash{4}},
+			wantError: errResp(ErrChainConfigMismatch, "0400000000000000 (!= %x)", config.Bytes()[:8]),
+		},
+	}
+	for i, test := range tests {
+		p, errc := newTestPeer("peer", 163, pm, false)
+		go p2p.Send(p.app, test.code, test.data)
+
+		select {
+		case err := <-errc:
+			if err == nil {
+				t.Errorf("test %d: protocol returned nil error, want %q", i, test.wantError)
+			} else if err.Error() != test.wantError.Error() {
+				t.Errorf("test %d: wrong error: got %q, want %q", i, err, test.wantError)
+			}
+		case <-time.After(2 * time.Second):
+			t.Errorf("protocol did not shut down within 2 seconds")
+		}
+		p.close()
+	}
+}
+
+// Tests that istanbul peers running with differing gas overrides are rejected
+// during the handshake, whereas the ones sharing the repricing are accepted.
+func TestStatusMsgGasOverrides(t *testing.T) {
+	overridden := func(sstore uint64) *params.ChainConfig {
+		config := *params.TestChainConfig
+		config.GasOverrides = []*params.GasOverrideConfig{{
+			Name:    "sstore",
+			Block:   big.NewInt(10),
+			Opcodes: map[string]uint64{"SSTORE": sstore},
+		}}
+		return &config
+	}
+	var (
+		local, remote = overridden(5000), overridden(20000)
+		db, _         = ethdb.NewMemDatabase()
+		gspec         = &core.Genesis{Config: local}
+		genesis       = gspec.MustCommit(db)
+		blockchain, _ = core.NewBlockChain(db, nil, local, ethash.NewFaker(), vm.Config{})
+		engine        = istanbulBackend.New(istanbul.DefaultConfig, testBankKey, db)
+	)
+	pm, err := NewProtocolManager(local, downloader.FullSync, DefaultConfig.NetworkId, new(event.TypeMux), &testTxPool{}, engine, blockchain, db)
+	if err != nil {
+		t.Fatalf("failed to create protocol manager: %v", err)
+	}
+	pm.Start(1000)
+	defer pm.Stop()
+
+	td := blockchain.GetTd(genesis.Hash(), 0)
+
+	// A peer repricing SSTORE differently must be dropped
+	p, errc := newTestPeer("peer", 67, pm, false)
+	go p2p.Send(p.app, StatusMsg, &statusData163{67, DefaultConfig.NetworkId, td, genesis.Hash(), genesis.Hash(), remote.Fingerprint()})
+
+	want := errResp(ErrChainConfigMismatch, "%x (!= %x)", remote.Fingerprint().Bytes()[:8], local.Fingerprint().Bytes()[:8])
+	select {
+	case err := <-errc:
+		if err == nil || err.Error() != want.Error() {
+			t.Errorf("mismatching peer: error mismatch: have %v, want %v", err, want)
+		}
+	case <-time.After(2 * time.Second):
+		t.Errorf("protocol did not shut down within 2 seconds")
+	}
+	p.close()
+
+	// A peer sharing the repricing must be accepted
+	p, _ = newTestPeer("peer", 67, pm, false)
+	defer p.close()
+
+	p.handshake(t, td, genesis.Hash(), genesis.Hash(), local.Fingerprint())
+	for i := 0; pm.peers.Len() == 0; i++ {
+		if i == 100 {
+			t.Fatalf("matching peer not registered")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
 func testStatusMsgErrors(t *testing.T, protocol int) {
 	pm, _ := newTestProtocolManagerMust(t, downloader.FullSync, 0, nil, nil)
 	var (
@@ -93,8 +202,9 @@ func testStatusMsgErrors(t *testing.T, protocol int) {
 }
 
 // This test checks that received transactions are added to the local pool.
-func TestRecvTransactions62(t *testing.T) { testRecvTransactions(t, 62) }
-func TestRecvTransactions63(t *testing.T) { testRecvTransactions(t, 63) }
+func TestRecvTransactions62(t *testing.T)  { testRecvTransactions(t, 62) }
+func TestRecvTransactions63(t *testing.T)  { testRecvTransactions(t, 63) }
+func TestRecvTransactions163(t *testing.T) { testRecvTransactions(t, 163) }
 
 func testRecvTransactions(t *testing.T, protocol int) {
 	txAdded := make(chan []*types.Transaction)
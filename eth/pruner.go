@@ -0,0 +1,81 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"errors"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+)
+
+const (
+	// receiptPruneSection is the number of blocks the receipts of which are pruned
+	// together once they all fell out of the retention window.
+	receiptPruneSection = 1024
+
+	// receiptPruneThrottling is the time to wait between pruning two consecutive
+	// sections, preventing disk overload when catching up with a long chain.
+	receiptPruneThrottling = 100 * time.Millisecond
+)
+
+// errReceiptsPruned is returned if the receipts of a block were requested after
+// being pruned.
+var errReceiptsPruned = errors.New("receipts pruned")
+
+// ReceiptPruner implements a core.ChainIndexer, deleting the receipts of the
+// canonical blocks that are older than the retention window.
+type ReceiptPruner struct {
+	db ethdb.Database // database instance to delete the receipts from
+}
+
+// NewReceiptPruner returns a chain indexer that prunes the receipts of canonical
+// blocks once they are more than retention blocks deep.
+func NewReceiptPruner(db ethdb.Database, retention uint64) *core.ChainIndexer {
+	table := ethdb.NewTable(db, string(core.ReceiptPruneIndexPrefix))
+	return core.NewChainIndexer(db, table, &ReceiptPruner{db: db}, receiptPruneSection, retention, receiptPruneThrottling, "receipts")
+}
+
+// Reset implements core.ChainIndexerBackend, starting to prune a new section.
+func (p *ReceiptPruner) Reset(section uint64, lastSectionHead common.Hash) error {
+	return nil
+}
+
+// Process implements core.ChainIndexerBackend, deleting the receipts of a block.
+// Database batches can't hold deletions, so they are done one by one.
+func (p *ReceiptPruner) Process(header *types.Header) {
+	core.DeleteBlockReceipts(p.db, header.Hash(), header.Number.Uint64())
+}
+
+// Commit implements core.ChainIndexerBackend. Receipts are deleted as the blocks
+// are processed, there's nothing left to do.
+func (p *ReceiptPruner) Commit() error {
+	return nil
+}
+
+// receiptsPruned reports whether the receipts of the given canonical block were
+// pruned.
+func (s *Ethereum) receiptsPruned(number uint64) bool {
+	if s.receiptPruner == nil {
+		return false
+	}
+	sections, _, _ := s.receiptPruner.Sections()
+	return number < sections*receiptPruneSection
+}
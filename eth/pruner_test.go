@@ -0,0 +1,72 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// Tests that receipts are pruned once they fall out of the retention window,
+// section by section.
+func TestReceiptPruning(t *testing.T) {
+	var (
+		db, _   = ethdb.NewMemDatabase()
+		gspec   = &core.Genesis{Config: params.TestChainConfig}
+		genesis = gspec.MustCommit(db)
+	)
+	blockchain, _ := core.NewBlockChain(db, nil, gspec.Config, ethash.NewFaker(), vm.Config{})
+	defer blockchain.Stop()
+
+	const retention = 100
+	blocks, _ := core.GenerateChain(gspec.Config, genesis, ethash.NewFaker(), db, 2*receiptPruneSection+retention+10, nil)
+	if _, err := blockchain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	pruner := NewReceiptPruner(db, retention)
+	defer pruner.Close()
+	pruner.Start(blockchain)
+
+	// Wait for the two complete sections out of the retention window to be pruned
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		if sections, _, _ := pruner.Sections(); sections == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("receipts not pruned in time")
+		}
+	}
+	eth := &Ethereum{receiptPruner: pruner}
+	for _, block := range blocks {
+		number := block.NumberU64()
+		receipts := core.GetBlockReceipts(db, block.Hash(), number)
+
+		pruned := number < 2*receiptPruneSection
+		if pruned != (receipts == nil) {
+			t.Errorf("block %d: receipts present %v, want %v", number, receipts != nil, !pruned)
+		}
+		if eth.receiptsPruned(number) != pruned {
+			t.Errorf("block %d: pruned report %v, want %v", number, !pruned, pruned)
+		}
+	}
+}
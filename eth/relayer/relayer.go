@@ -0,0 +1,378 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package relayer executes meta-transactions signed by unfunded accounts on
+// their behalf, paying for them from a relayer account.
+//
+// Meta-transactions are EIP-712 typed data signed by their origin. The relayer
+// checks the signature, the per origin replay protection nonce and quota, then
+// wraps the call into a transaction of the relayer account with the origin
+// appended to the call data. The replay protection nonces are persisted in the
+// database, the quotas are kept in memory.
+package relayer
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// dbKeyNoncePrefix + origin -> next meta-transaction nonce (uint64 big endian)
+var dbKeyNoncePrefix = []byte("relayer-nonce-")
+
+var (
+	relayedMeter  = metrics.NewRegisteredMeter("relayer/relayed", nil)
+	rejectedMeter = metrics.NewRegisteredMeter("relayer/rejected", nil)
+)
+
+var (
+	errNoAccount      = errors.New("relayer account not configured")
+	errNonceMismatch  = errors.New("meta-transaction nonce mismatch")
+	errExpired        = errors.New("meta-transaction deadline passed")
+	errGasTooHigh     = errors.New("meta-transaction gas above relayer limit")
+	errTargetDenied   = errors.New("meta-transaction target not relayed")
+	errQuotaExceeded  = errors.New("origin quota exceeded")
+	errOriginMismatch = errors.New("meta-transaction not signed by its origin")
+	errUnknownRelay   = errors.New("unknown relayed transaction")
+)
+
+// Config contains the settings of the meta-transaction relayer.
+type Config struct {
+	Account     common.Address   // Account paying for the relayed transactions
+	GasPrice    *big.Int         // Gas price of the relayed transactions (nil = transaction pool minimum)
+	MaxGas      uint64           // Maximum gas of a single meta-transaction (0 = block gas limit)
+	Quota       uint64           // Meta-transactions relayed per origin and quota period (0 = unlimited)
+	QuotaPeriod time.Duration    // Period over which the origin quotas are counted
+	Targets     []common.Address // Contracts calls are relayed to (empty = all)
+}
+
+// DefaultConfig contains the default settings of the relayer.
+var DefaultConfig = Config{
+	Quota:       100,
+	QuotaPeriod: time.Hour,
+}
+
+// SignTxFn signs a transaction of the relayer account.
+type SignTxFn func(tx *types.Transaction, chainID *big.Int) (*types.Transaction, error)
+
+// quota counts the meta-transactions relayed for an origin in the current period.
+type quota struct {
+	start time.Time // Start of the current quota period
+	used  uint64    // Meta-transactions relayed in the current period
+}
+
+// Service relays meta-transactions into the transaction pool.
+type Service struct {
+	config *Config
+	chain  *core.BlockChain
+	pool   *core.TxPool
+	db     ethdb.Database
+	sign   SignTxFn
+	domain *Domain
+
+	quotas map[common.Address]*quota
+	lock   sync.Mutex // Serialises relays, guarding the nonces and quotas
+}
+
+// New creates a relayer paying for meta-transactions from the configured account,
+// signing its transactions with the given function.
+func New(config *Config, chain *core.BlockChain, pool *core.TxPool, db ethdb.Database, sign SignTxFn) (*Service, error) {
+	if config.Account == (common.Address{}) {
+		return nil, errNoAccount
+	}
+	if config.Quota > 0 && config.QuotaPeriod <= 0 {
+		return nil, fmt.Errorf("invalid quota period: %v", config.QuotaPeriod)
+	}
+	return &Service{
+		config: config,
+		chain:  chain,
+		pool:   pool,
+		db:     db,
+		sign:   sign,
+		domain: newDomain(chain.Config().ChainId, config.Account),
+		quotas: make(map[common.Address]*quota),
+	}, nil
+}
+
+// Protocols implements node.Service, returning the P2P network protocols used
+// by the relayer (nil as it doesn't use the devp2p overlay network).
+func (s *Service) Protocols() []p2p.Protocol { return nil }
+
+// APIs implements node.Service, returning the RPC API endpoints provided by the
+// relayer.
+func (s *Service) APIs() []rpc.API {
+	return []rpc.API{{
+		Namespace: "relayer",
+		Version:   "1.0",
+		Service:   &PublicRelayerAPI{s},
+		Public:    true,
+	}}
+}
+
+// Start implements node.Service, starting to accept meta-transactions.
+func (s *Service) Start(server *p2p.Server) error {
+	log.Info("Started meta-transaction relayer", "account", s.config.Account, "quota", s.config.Quota, "period", s.config.QuotaPeriod)
+	return nil
+}
+
+// Stop implements node.Service, terminating the relayer.
+func (s *Service) Stop() error {
+	log.Info("Meta-transaction relayer stopped")
+	return nil
+}
+
+// relay validates a meta-transaction and submits the transaction executing it
+// into the pool, returning the transaction.
+func (s *Service) relay(meta *MetaTransaction) (*types.Transaction, error) {
+	tx, err := s.wrap(meta)
+	if err != nil {
+		rejectedMeter.Mark(1)
+		log.Debug("Rejected meta-transaction", "origin", meta.From, "to", meta.To, "nonce", uint64(meta.Nonce), "err", err)
+		return nil, err
+	}
+	relayedMeter.Mark(1)
+	log.Debug("Relayed meta-transaction", "origin", meta.From, "to", meta.To, "nonce", uint64(meta.Nonce), "hash", tx.Hash())
+	return tx, nil
+}
+
+// wrap validates a meta-transaction, and if acceptable signs and submits the
+// relayer transaction executing it, consuming the origin's nonce and quota.
+func (s *Service) wrap(meta *MetaTransaction) (*types.Transaction, error) {
+	origin, err := meta.Origin(s.domain)
+	if err != nil {
+		return nil, err
+	}
+	if origin != meta.From {
+		return nil, errOriginMismatch
+	}
+	if meta.Deadline > 0 && time.Now().Unix() > int64(meta.Deadline) {
+		return nil, errExpired
+	}
+	if !s.allowed(meta.To) {
+		return nil, errTargetDenied
+	}
+	limit := s.config.MaxGas
+	if gasLimit := s.chain.CurrentBlock().GasLimit(); limit == 0 || limit > gasLimit {
+		limit = gasLimit
+	}
+	if uint64(meta.Gas) > limit {
+		return nil, errGasTooHigh
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	nonce := s.nonce(origin)
+	if uint64(meta.Nonce) != nonce {
+		return nil, fmt.Errorf("%v: have %d, want %d", errNonceMismatch, uint64(meta.Nonce), nonce)
+	}
+	var q *quota
+	if s.config.Quota > 0 {
+		if q = s.quota(origin, time.Now()); q.used >= s.config.Quota {
+			return nil, errQuotaExceeded
+		}
+	}
+	// Meta-transaction acceptable, execute it from the relayer account
+	price := s.config.GasPrice
+	if price == nil {
+		price = s.pool.GasPrice()
+	}
+	data := make([]byte, 0, len(meta.Data)+common.AddressLength)
+	data = append(append(data, meta.Data...), origin[:]...)
+
+	tx := types.NewTransaction(s.pool.State().GetNonce(s.config.Account), meta.To, new(big.Int), uint64(meta.Gas), price, data)
+	signed, err := s.sign(tx, s.chain.Config().ChainId)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.pool.AddLocal(signed); err != nil {
+		return nil, err
+	}
+	if q != nil {
+		q.used++
+	}
+	s.setNonce(origin, nonce+1)
+	return signed, nil
+}
+
+// allowed checks whether calls may be relayed to the given contract.
+func (s *Service) allowed(to common.Address) bool {
+	if len(s.config.Targets) == 0 {
+		return true
+	}
+	for _, target := range s.config.Targets {
+		if target == to {
+			return true
+		}
+	}
+	return false
+}
+
+// quota returns the quota usage of an origin, starting a new period if the last
+// one is over. The caller must hold the lock.
+func (s *Service) quota(origin common.Address, now time.Time) *quota {
+	q, ok := s.quotas[origin]
+	if !ok || now.Sub(q.start) >= s.config.QuotaPeriod {
+		// Drop the expired periods of other origins too, not to grow indefinitely
+		for addr, other := range s.quotas {
+			if now.Sub(other.start) >= s.config.QuotaPeriod {
+				delete(s.quotas, addr)
+			}
+		}
+		q = &quota{start: now}
+		s.quotas[origin] = q
+	}
+	return q
+}
+
+// nonce returns the next meta-transaction nonce of an origin.
+func (s *Service) nonce(origin common.Address) uint64 {
+	blob, err := s.db.Get(append(dbKeyNoncePrefix, origin[:]...))
+	if err != nil || len(blob) != 8 {
+		return 0
+	}
+	return binary.BigEndian.Uint64(blob)
+}
+
+// setNonce persists the next meta-transaction nonce of an origin.
+func (s *Service) setNonce(origin common.Address, nonce uint64) {
+	blob := make([]byte, 8)
+	binary.BigEndian.PutUint64(blob, nonce)
+
+	if err := s.db.Put(append(dbKeyNoncePrefix, origin[:]...), blob); err != nil {
+		log.Crit("Failed to store relayer nonce", "err", err)
+	}
+}
+
+// PublicRelayerAPI provides an API to relay meta-transactions.
+type PublicRelayerAPI struct {
+	s *Service
+}
+
+// Domain returns the EIP-712 domain the meta-transactions must be signed in.
+func (api *PublicRelayerAPI) Domain() *Domain {
+	return api.s.domain
+}
+
+// Nonce returns the nonce the next meta-transaction of the origin must carry.
+func (api *PublicRelayerAPI) Nonce(origin common.Address) hexutil.Uint64 {
+	api.s.lock.Lock()
+	defer api.s.lock.Unlock()
+
+	return hexutil.Uint64(api.s.nonce(origin))
+}
+
+// QuotaStatus is the quota usage of an origin in the current period.
+type QuotaStatus struct {
+	Quota     hexutil.Uint64 `json:"quota"`     // Meta-transactions allowed per period (0 = unlimited)
+	Used      hexutil.Uint64 `json:"used"`      // Meta-transactions relayed in the current period
+	Remaining hexutil.Uint64 `json:"remaining"` // Meta-transactions still allowed in the current period
+	Reset     hexutil.Uint64 `json:"reset"`     // Unix time the current period ends at
+}
+
+// Quota returns the quota usage of an origin.
+func (api *PublicRelayerAPI) Quota(origin common.Address) *QuotaStatus {
+	api.s.lock.Lock()
+	defer api.s.lock.Unlock()
+
+	status := &QuotaStatus{Quota: hexutil.Uint64(api.s.config.Quota)}
+	if api.s.config.Quota == 0 {
+		return status
+	}
+	q := api.s.quota(origin, time.Now())
+	status.Used = hexutil.Uint64(q.used)
+	status.Remaining = hexutil.Uint64(api.s.config.Quota - q.used)
+	status.Reset = hexutil.Uint64(q.start.Add(api.s.config.QuotaPeriod).Unix())
+	return status
+}
+
+// SendMetaTransaction relays a signed meta-transaction, returning the hash of
+// the relayer transaction executing it.
+func (api *PublicRelayerAPI) SendMetaTransaction(ctx context.Context, meta MetaTransaction) (common.Hash, error) {
+	tx, err := api.s.relay(&meta)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return tx.Hash(), nil
+}
+
+// RelayResult is the execution result of a relayed meta-transaction.
+type RelayResult struct {
+	Hash        common.Hash     `json:"hash"`
+	Origin      common.Address  `json:"origin"`
+	To          common.Address  `json:"to"`
+	Status      string          `json:"status"` // "pending", "success" or "failed"
+	BlockHash   *common.Hash    `json:"blockHash,omitempty"`
+	BlockNumber *hexutil.Uint64 `json:"blockNumber,omitempty"`
+	GasUsed     *hexutil.Uint64 `json:"gasUsed,omitempty"`
+	Logs        []*types.Log    `json:"logs,omitempty"`
+}
+
+// GetResult returns the execution result of a relayed meta-transaction, given
+// the hash of the relayer transaction.
+func (api *PublicRelayerAPI) GetResult(hash common.Hash) (*RelayResult, error) {
+	tx, blockHash, number, _ := core.GetTransaction(api.s.db, hash)
+	if tx == nil {
+		if tx = api.s.pool.Get(hash); tx == nil {
+			return nil, errUnknownRelay
+		}
+		return api.s.result(tx, "pending")
+	}
+	result, err := api.s.result(tx, "success")
+	if err != nil {
+		return nil, err
+	}
+	receipt, _, _, _ := core.GetReceipt(api.s.db, hash)
+	if receipt == nil {
+		return nil, errUnknownRelay
+	}
+	if receipt.Status == types.ReceiptStatusFailed {
+		result.Status = "failed"
+	}
+	result.BlockHash = &blockHash
+	result.BlockNumber = (*hexutil.Uint64)(&number)
+	result.GasUsed = (*hexutil.Uint64)(&receipt.GasUsed)
+	result.Logs = receipt.Logs
+	return result, nil
+}
+
+// result assembles the result of a relayer transaction, failing for any other
+// transaction.
+func (s *Service) result(tx *types.Transaction, status string) (*RelayResult, error) {
+	from, err := types.Sender(types.MakeSigner(s.chain.Config(), s.chain.CurrentBlock().Number()), tx)
+	if err != nil || from != s.config.Account || tx.To() == nil || len(tx.Data()) < common.AddressLength {
+		return nil, errUnknownRelay
+	}
+	return &RelayResult{
+		Hash:   tx.Hash(),
+		Origin: common.BytesToAddress(tx.Data()[len(tx.Data())-common.AddressLength:]),
+		To:     *tx.To(),
+		Status: status,
+	}, nil
+}
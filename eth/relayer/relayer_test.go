@@ -0,0 +1,224 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package relayer
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+var (
+	relayerKey, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313e5ec3bb4b4ea8b2a9")
+	relayerAddress = crypto.PubkeyToAddress(relayerKey.PublicKey)
+
+	target = common.HexToAddress("0x1000")
+)
+
+// testRelayer is a relayer on top of a chain only funding the relayer account.
+type testRelayer struct {
+	*Service
+	genesis *types.Block
+	pool    *core.TxPool
+}
+
+func newTestRelayer(t *testing.T, config *Config) *testRelayer {
+	db, _ := ethdb.NewMemDatabase()
+	gspec := &core.Genesis{
+		Config: params.TestChainConfig,
+		Alloc:  core.GenesisAlloc{relayerAddress: {Balance: big.NewInt(1000000000000000000)}},
+	}
+	genesis := gspec.MustCommit(db)
+
+	engine := ethash.NewFaker()
+	chain, _ := core.NewBlockChain(db, nil, params.TestChainConfig, engine, vm.Config{})
+	chain.SetProcessor(core.NewStateProcessor(params.TestChainConfig, chain, engine))
+
+	poolConfig := core.DefaultTxPoolConfig
+	poolConfig.Journal = ""
+	pool := core.NewTxPool(poolConfig, params.TestChainConfig, chain)
+
+	config.Account = relayerAddress
+	s, err := New(config, chain, pool, db, func(tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+		return types.SignTx(tx, types.NewEIP155Signer(chainID), relayerKey)
+	})
+	if err != nil {
+		t.Fatalf("failed to create relayer: %v", err)
+	}
+	return &testRelayer{Service: s, genesis: genesis, pool: pool}
+}
+
+func (r *testRelayer) close() {
+	r.pool.Stop()
+	r.chain.Stop()
+}
+
+// signMeta creates a meta-transaction of the key's account, signed in the domain.
+func signMeta(domain *Domain, key *ecdsa.PrivateKey, nonce uint64) MetaTransaction {
+	meta := MetaTransaction{
+		From:  crypto.PubkeyToAddress(key.PublicKey),
+		To:    target,
+		Data:  []byte{0xde, 0xad, 0xbe, 0xef},
+		Gas:   100000,
+		Nonce: hexutil.Uint64(nonce),
+	}
+	hash := meta.SigHash(domain)
+	meta.Signature, _ = crypto.Sign(hash[:], key)
+	return meta
+}
+
+// Tests that meta-transactions are relayed with the origin appended to the call
+// data, and that their results are reported once included.
+func TestRelay(t *testing.T) {
+	r := newTestRelayer(t, &Config{})
+	defer r.close()
+	api := &PublicRelayerAPI{r.Service}
+
+	key, _ := crypto.GenerateKey()
+	origin := crypto.PubkeyToAddress(key.PublicKey)
+
+	hash, err := api.SendMetaTransaction(nil, signMeta(api.Domain(), key, 0))
+	if err != nil {
+		t.Fatalf("failed to relay meta-transaction: %v", err)
+	}
+	tx := r.pool.Get(hash)
+	if tx == nil {
+		t.Fatalf("relayed transaction not pooled")
+	}
+	if want := append([]byte{0xde, 0xad, 0xbe, 0xef}, origin[:]...); !bytes.Equal(tx.Data(), want) {
+		t.Errorf("call data mismatch: have %x, want %x", tx.Data(), want)
+	}
+	if nonce := api.Nonce(origin); nonce != 1 {
+		t.Errorf("origin nonce mismatch: have %d, want 1", nonce)
+	}
+	result, err := api.GetResult(hash)
+	if err != nil {
+		t.Fatalf("failed to retrieve pending result: %v", err)
+	}
+	if result.Status != "pending" || result.Origin != origin || result.To != target {
+		t.Errorf("pending result mismatch: %+v", result)
+	}
+	// Include the transaction and check the reported execution
+	blocks, _ := core.GenerateChain(params.TestChainConfig, r.genesis, ethash.NewFaker(), r.db, 1, func(i int, b *core.BlockGen) {
+		b.AddTx(tx)
+	})
+	if _, err := r.chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	if result, err = api.GetResult(hash); err != nil {
+		t.Fatalf("failed to retrieve result: %v", err)
+	}
+	if result.Status != "success" || result.BlockHash == nil || *result.BlockHash != blocks[0].Hash() || result.Origin != origin {
+		t.Errorf("included result mismatch: %+v", result)
+	}
+	// Transactions not sent by the relayer are not reported
+	if _, err := api.GetResult(common.Hash{0x01}); err != errUnknownRelay {
+		t.Errorf("unknown transaction: error mismatch: have %v, want %v", err, errUnknownRelay)
+	}
+}
+
+// Tests that invalid, replayed or disallowed meta-transactions are rejected
+// without consuming the origin's nonce.
+func TestRelayRejections(t *testing.T) {
+	r := newTestRelayer(t, &Config{MaxGas: 200000, Targets: []common.Address{target}})
+	defer r.close()
+	api := &PublicRelayerAPI{r.Service}
+
+	key, _ := crypto.GenerateKey()
+	origin := crypto.PubkeyToAddress(key.PublicKey)
+
+	if _, err := api.SendMetaTransaction(nil, signMeta(api.Domain(), key, 0)); err != nil {
+		t.Fatalf("failed to relay meta-transaction: %v", err)
+	}
+	tests := map[string]struct {
+		tamper func(meta *MetaTransaction)
+		resign bool
+	}{
+		"replay": {func(meta *MetaTransaction) { meta.Nonce = 0 }, true},
+		"future": {func(meta *MetaTransaction) { meta.Nonce = 5 }, true},
+		"expired": {func(meta *MetaTransaction) {
+			meta.Deadline = hexutil.Uint64(uint64(time.Now().Add(-time.Minute).Unix()))
+		}, true},
+		"gas":       {func(meta *MetaTransaction) { meta.Gas = 300000 }, true},
+		"target":    {func(meta *MetaTransaction) { meta.To = common.HexToAddress("0x2000") }, true},
+		"tampered":  {func(meta *MetaTransaction) { meta.Data = []byte{0x01} }, false},
+		"signature": {func(meta *MetaTransaction) { meta.Signature = meta.Signature[:64] }, false},
+	}
+	for name, tt := range tests {
+		meta := signMeta(api.Domain(), key, 1)
+		tt.tamper(&meta)
+		if tt.resign {
+			hash := meta.SigHash(api.Domain())
+			meta.Signature, _ = crypto.Sign(hash[:], key)
+		}
+		if _, err := api.SendMetaTransaction(nil, meta); err == nil {
+			t.Errorf("%s: meta-transaction relayed", name)
+		}
+	}
+	if nonce := api.Nonce(origin); nonce != 1 {
+		t.Errorf("origin nonce mismatch: have %d, want 1", nonce)
+	}
+	// Signatures of another relayer's domain must not be accepted
+	other := newDomain(params.TestChainConfig.ChainId, common.HexToAddress("0x3000"))
+	if _, err := api.SendMetaTransaction(nil, signMeta(other, key, 1)); err == nil {
+		t.Errorf("meta-transaction of foreign domain relayed")
+	}
+}
+
+// Tests that the origins are limited to their quota per period, independently
+// of each other.
+func TestRelayQuota(t *testing.T) {
+	r := newTestRelayer(t, &Config{Quota: 2, QuotaPeriod: time.Hour})
+	defer r.close()
+	api := &PublicRelayerAPI{r.Service}
+
+	key, _ := crypto.GenerateKey()
+	origin := crypto.PubkeyToAddress(key.PublicKey)
+
+	for i := uint64(0); i < 2; i++ {
+		if _, err := api.SendMetaTransaction(nil, signMeta(api.Domain(), key, i)); err != nil {
+			t.Fatalf("meta-transaction %d: failed to relay: %v", i, err)
+		}
+	}
+	if _, err := api.SendMetaTransaction(nil, signMeta(api.Domain(), key, 2)); err != errQuotaExceeded {
+		t.Errorf("error mismatch: have %v, want %v", err, errQuotaExceeded)
+	}
+	if status := api.Quota(origin); status.Used != 2 || status.Remaining != 0 {
+		t.Errorf("quota mismatch: have %d used, %d remaining, want 2 used, 0 remaining", status.Used, status.Remaining)
+	}
+	other, _ := crypto.GenerateKey()
+	if _, err := api.SendMetaTransaction(nil, signMeta(api.Domain(), other, 0)); err != nil {
+		t.Errorf("other origin: failed to relay: %v", err)
+	}
+	// A new period must restore the quota
+	r.quotas[origin].start = time.Now().Add(-2 * time.Hour)
+	if _, err := api.SendMetaTransaction(nil, signMeta(api.Domain(), key, 2)); err != nil {
+		t.Errorf("failed to relay in new period: %v", err)
+	}
+}
@@ -0,0 +1,127 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package relayer
+
+import (
+	"errors"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+const (
+	// DomainName is the name of the EIP-712 signing domain of meta-transactions.
+	DomainName = "Relayer"
+
+	// DomainVersion is the version of the EIP-712 signing domain of meta-transactions.
+	DomainVersion = "1"
+)
+
+var (
+	domainTypeHash = crypto.Keccak256([]byte("EIP712Domain(string name,string version,uint256 chainId,address verifyingContract)"))
+	metaTxTypeHash = crypto.Keccak256([]byte("MetaTransaction(address from,address to,bytes data,uint256 gas,uint256 nonce,uint256 deadline)"))
+)
+
+var errInvalidSignature = errors.New("invalid meta-transaction signature")
+
+// MetaTransaction is a contract call signed by its origin as EIP-712 typed data,
+// to be executed on its behalf by the relayer account. The origin is appended
+// to the call data, the target contract is expected to take the last 20 bytes of
+// calls from the relayer as the actual sender (EIP-2771).
+type MetaTransaction struct {
+	From      common.Address `json:"from"`      // Origin signing the call
+	To        common.Address `json:"to"`        // Contract to call
+	Data      hexutil.Bytes  `json:"data"`      // Call data, without the origin
+	Gas       hexutil.Uint64 `json:"gas"`       // Gas limit of the relayed transaction
+	Nonce     hexutil.Uint64 `json:"nonce"`     // Per origin sequence number, preventing replays
+	Deadline  hexutil.Uint64 `json:"deadline"`  // Unix time after which the call may not be relayed (0 = never)
+	Signature hexutil.Bytes  `json:"signature"` // Signature of the typed data hash, [R || S || V] format
+}
+
+// Domain is the EIP-712 domain meta-transactions are signed in, binding the
+// signatures to a chain and relayer account.
+type Domain struct {
+	Name              string         `json:"name"`
+	Version           string         `json:"version"`
+	ChainId           *hexutil.Big   `json:"chainId"`
+	VerifyingContract common.Address `json:"verifyingContract"`
+}
+
+// newDomain returns the signing domain of the given relayer account.
+func newDomain(chainID *big.Int, relayer common.Address) *Domain {
+	return &Domain{
+		Name:              DomainName,
+		Version:           DomainVersion,
+		ChainId:           (*hexutil.Big)(chainID),
+		VerifyingContract: relayer,
+	}
+}
+
+// separator returns the EIP-712 domain separator.
+func (d *Domain) separator() []byte {
+	return crypto.Keccak256(
+		domainTypeHash,
+		crypto.Keccak256([]byte(d.Name)),
+		crypto.Keccak256([]byte(d.Version)),
+		math.PaddedBigBytes(d.ChainId.ToInt(), 32),
+		common.LeftPadBytes(d.VerifyingContract[:], 32),
+	)
+}
+
+// structHash returns the EIP-712 hash of the meta-transaction struct.
+func (tx *MetaTransaction) structHash() []byte {
+	return crypto.Keccak256(
+		metaTxTypeHash,
+		common.LeftPadBytes(tx.From[:], 32),
+		common.LeftPadBytes(tx.To[:], 32),
+		crypto.Keccak256(tx.Data),
+		math.PaddedBigBytes(new(big.Int).SetUint64(uint64(tx.Gas)), 32),
+		math.PaddedBigBytes(new(big.Int).SetUint64(uint64(tx.Nonce)), 32),
+		math.PaddedBigBytes(new(big.Int).SetUint64(uint64(tx.Deadline)), 32),
+	)
+}
+
+// SigHash returns the EIP-712 typed data hash of the meta-transaction in the
+// domain, the hash to be signed by the origin.
+func (tx *MetaTransaction) SigHash(domain *Domain) common.Hash {
+	return crypto.Keccak256Hash([]byte{0x19, 0x01}, domain.separator(), tx.structHash())
+}
+
+// Origin recovers the address which signed the meta-transaction in the domain.
+// Both the 0/1 and 27/28 recovery id conventions are accepted.
+func (tx *MetaTransaction) Origin(domain *Domain) (common.Address, error) {
+	if len(tx.Signature) != 65 {
+		return common.Address{}, errInvalidSignature
+	}
+	sig := make([]byte, 65)
+	copy(sig, tx.Signature)
+	if sig[64] >= 27 {
+		sig[64] -= 27
+	}
+	if sig[64] > 1 {
+		return common.Address{}, errInvalidSignature
+	}
+	hash := tx.SigHash(domain)
+	pub, err := crypto.SigToPub(hash[:], sig)
+	if err != nil {
+		return common.Address{}, errInvalidSignature
+	}
+	return crypto.PubkeyToAddress(*pub), nil
+}
@@ -0,0 +1,405 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package telemetry pushes the health of a validator to a central collector.
+//
+// It works like ethstats, but reports what the coordinators of a BFT consortium
+// chain need for a fleet-wide dashboard: the chain head along with the round it
+// was committed in, the consensus view currently being agreed on, the validator
+// status, the peer and transaction pool counts and the resource usage.
+//
+// The client connects to the /telemetry WebSocket endpoint of the collector,
+// authenticates with the shared secret of the reporting URL and sends JSON
+// messages of the form
+//
+//	{"type": "hello", "id": <name>, "secret": <secret>, "info": <NodeInfo>}
+//	{"type": "report", "id": <name>, "report": <Report>}
+//
+// The collector must answer the hello with {"type": "ready"} before reports are
+// sent, or close the connection to reject the node.
+package telemetry
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+	"golang.org/x/net/websocket"
+)
+
+const (
+	// chainHeadChanSize is the size of channel listening to ChainHeadEvent.
+	chainHeadChanSize = 10
+
+	// retryInterval is the time to wait before reconnecting to the collector.
+	retryInterval = 10 * time.Second
+
+	// sendTimeout is the maximum time to wait for a message to be sent.
+	sendTimeout = 5 * time.Second
+)
+
+// errUnauthorized is returned if the collector rejects the login.
+var errUnauthorized = errors.New("unauthorized")
+
+// Config contains the settings of the telemetry client.
+type Config struct {
+	URL      string        // Collector to report to (nodename:secret@host:port)
+	Interval time.Duration // Time between periodic reports, on top of the ones of new heads
+}
+
+// DefaultConfig contains the default settings of the telemetry client.
+var DefaultConfig = Config{
+	Interval: 10 * time.Second,
+}
+
+// NodeInfo is the static information about the node sent upon login.
+type NodeInfo struct {
+	Name    string         `json:"name"`    // Client identifier of the node
+	Enode   string         `json:"enode"`   // Enode URL of the node
+	Address common.Address `json:"address"` // Validator address derived from the node key
+	ChainID uint64         `json:"chainId"`
+	Genesis common.Hash    `json:"genesis"`
+	Version string         `json:"version"`
+	OS      string         `json:"os"`
+	Go      string         `json:"go"`
+}
+
+// BlockStats is the information reported about the head block.
+type BlockStats struct {
+	Number   uint64          `json:"number"`
+	Hash     common.Hash     `json:"hash"`
+	Time     uint64          `json:"time"`
+	Txs      int             `json:"txs"`
+	GasUsed  uint64          `json:"gasUsed"`
+	Proposer *common.Address `json:"proposer,omitempty"` // Proposer of the block, if known to the engine
+	Round    *uint64         `json:"round,omitempty"`    // Round the block was committed in, if known to the engine
+}
+
+// ResourceStats is the resource usage of the node process.
+type ResourceStats struct {
+	CPU        float64 `json:"cpu"`        // CPU usage since the previous report, in percent of a single core
+	HeapAlloc  uint64  `json:"heapAlloc"`  // Bytes allocated on the heap
+	Sys        uint64  `json:"sys"`        // Bytes of memory obtained from the OS
+	Goroutines int     `json:"goroutines"` // Number of running goroutines
+	DiskRead   int64   `json:"diskRead"`   // Bytes read from disk since startup
+	DiskWrite  int64   `json:"diskWrite"`  // Bytes written to disk since startup
+}
+
+// Report is the health of the node sent to the collector on every new head and
+// periodically.
+type Report struct {
+	Time      time.Time       `json:"time"`
+	Block     *BlockStats     `json:"block"`
+	View      *consensus.View `json:"view,omitempty"` // Consensus instance currently running, if the engine reports it
+	Validator bool            `json:"validator"`      // Whether the node is an authorized validator at the head
+	Syncing   bool            `json:"syncing"`
+	Peers     int             `json:"peers"`
+	Pending   int             `json:"pending"`
+	Queued    int             `json:"queued"`
+	Resources *ResourceStats  `json:"resources"`
+}
+
+// message is the envelope of the messages exchanged with the collector.
+type message struct {
+	Type   string    `json:"type"`
+	ID     string    `json:"id,omitempty"`
+	Secret string    `json:"secret,omitempty"`
+	Info   *NodeInfo `json:"info,omitempty"`
+	Report *Report   `json:"report,omitempty"`
+}
+
+// Service is the telemetry client pushing node reports to the collector.
+type Service struct {
+	config  *Config
+	chain   *core.BlockChain
+	pool    *core.TxPool
+	syncing func() bool // Reports whether the node is synchronising with the network
+
+	node string // Name of the node on the collector
+	pass string // Secret authorizing the node at the collector
+	host string // Address of the collector
+
+	server  *p2p.Server
+	address common.Address // Validator address derived from the node key
+
+	lastCPUTime  float64   // Process CPU time at the previous report
+	lastCPUCheck time.Time // Time of the previous CPU time measurement
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// New creates a telemetry client reporting the given chain and transaction pool.
+func New(config *Config, chain *core.BlockChain, pool *core.TxPool, syncing func() bool) (*Service, error) {
+	re := regexp.MustCompile("([^:@]*)(:([^@]*))?@(.+)")
+	parts := re.FindStringSubmatch(config.URL)
+	if len(parts) != 5 {
+		return nil, fmt.Errorf("invalid telemetry url: \"%s\", should be nodename:secret@host:port", config.URL)
+	}
+	if config.Interval <= 0 {
+		return nil, fmt.Errorf("invalid telemetry interval %v", config.Interval)
+	}
+	return &Service{
+		config:  config,
+		chain:   chain,
+		pool:    pool,
+		syncing: syncing,
+		node:    parts[1],
+		pass:    parts[3],
+		host:    parts[4],
+		quit:    make(chan struct{}),
+	}, nil
+}
+
+// Protocols implements node.Service, returning the P2P network protocols used
+// by the telemetry client (nil as it doesn't use the devp2p overlay network).
+func (s *Service) Protocols() []p2p.Protocol { return nil }
+
+// APIs implements node.Service, returning the RPC API endpoints provided by the
+// telemetry client (nil as it doesn't provide any user callable APIs).
+func (s *Service) APIs() []rpc.API { return nil }
+
+// Start implements node.Service, starting to report to the collector.
+func (s *Service) Start(server *p2p.Server) error {
+	s.server = server
+	s.address = nodeAddress(server.PrivateKey)
+
+	s.wg.Add(1)
+	go s.loop()
+
+	log.Info("Telemetry client started", "collector", s.host)
+	return nil
+}
+
+// Stop implements node.Service, terminating the reporting.
+func (s *Service) Stop() error {
+	close(s.quit)
+	s.wg.Wait()
+
+	log.Info("Telemetry client stopped")
+	return nil
+}
+
+// nodeAddress returns the address derived from the node key, which identifies
+// the node as a validator on BFT chains.
+func nodeAddress(key *ecdsa.PrivateKey) common.Address {
+	if key == nil {
+		return common.Address{}
+	}
+	return crypto.PubkeyToAddress(key.PublicKey)
+}
+
+// loop keeps connecting to the collector and reporting until termination.
+func (s *Service) loop() {
+	defer s.wg.Done()
+
+	headCh := make(chan core.ChainHeadEvent, chainHeadChanSize)
+	headSub := s.chain.SubscribeChainHeadEvent(headCh)
+	defer headSub.Unsubscribe()
+
+	for {
+		conn, err := s.connect()
+		if err != nil {
+			log.Warn("Telemetry collector unreachable", "err", err)
+		} else {
+			err = s.report(conn, headCh)
+			conn.Close()
+			if err == nil {
+				return
+			}
+			log.Warn("Telemetry reporting interrupted", "err", err)
+		}
+		select {
+		case <-s.quit:
+			return
+		case <-time.After(retryInterval):
+		}
+	}
+}
+
+// connect dials the collector, defaulting to TLS but falling back to plain
+// WebSocket, and logs in.
+func (s *Service) connect() (*websocket.Conn, error) {
+	path := fmt.Sprintf("%s/telemetry", s.host)
+	urls := []string{path}
+	if !strings.Contains(path, "://") {
+		urls = []string{"wss://" + path, "ws://" + path}
+	}
+	var (
+		conn *websocket.Conn
+		err  error
+	)
+	for _, url := range urls {
+		var conf *websocket.Config
+		if conf, err = websocket.NewConfig(url, "http://localhost/"); err != nil {
+			continue
+		}
+		conf.Dialer = &net.Dialer{Timeout: sendTimeout}
+		if conn, err = websocket.DialConfig(conf); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := s.login(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// login authenticates the node at the collector.
+func (s *Service) login(conn *websocket.Conn) error {
+	info := &NodeInfo{
+		Name:    s.server.Name,
+		Address: s.address,
+		Genesis: s.chain.Genesis().Hash(),
+		Version: params.Version,
+		OS:      runtime.GOOS + "-" + runtime.GOARCH,
+		Go:      runtime.Version(),
+	}
+	if chainID := s.chain.Config().ChainId; chainID != nil {
+		info.ChainID = chainID.Uint64()
+	}
+	if self := s.server.Self(); self != nil {
+		info.Enode = self.String()
+	}
+	if err := s.send(conn, &message{Type: "hello", ID: s.node, Secret: s.pass, Info: info}); err != nil {
+		return err
+	}
+	conn.SetReadDeadline(time.Now().Add(sendTimeout))
+	defer conn.SetReadDeadline(time.Time{})
+
+	var ack message
+	if err := websocket.JSON.Receive(conn, &ack); err != nil || ack.Type != "ready" {
+		return errUnauthorized
+	}
+	return nil
+}
+
+// report sends a report on every new head and periodically, until the
+// connection breaks or the client is stopped (returning nil).
+func (s *Service) report(conn *websocket.Conn, headCh chan core.ChainHeadEvent) error {
+	ticker := time.NewTicker(s.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.send(conn, &message{Type: "report", ID: s.node, Report: s.assembleReport()}); err != nil {
+			return err
+		}
+		select {
+		case <-s.quit:
+			return nil
+		case <-headCh:
+		case <-ticker.C:
+		}
+	}
+}
+
+// send writes a message to the collector.
+func (s *Service) send(conn *websocket.Conn, msg *message) error {
+	conn.SetWriteDeadline(time.Now().Add(sendTimeout))
+	return websocket.JSON.Send(conn, msg)
+}
+
+// assembleReport collects the current health of the node.
+func (s *Service) assembleReport() *Report {
+	head := s.chain.CurrentBlock()
+	report := &Report{
+		Time:      time.Now(),
+		Block:     s.assembleBlockStats(head),
+		Peers:     s.server.PeerCount(),
+		Resources: s.assembleResourceStats(),
+	}
+	report.Pending, report.Queued = s.pool.Stats()
+	if s.syncing != nil {
+		report.Syncing = s.syncing()
+	}
+	engine := s.chain.Engine()
+	if reporter, ok := engine.(consensus.ViewReporter); ok {
+		if view, err := reporter.CurrentView(); err == nil {
+			report.View = view
+		}
+	}
+	if istanbul, ok := engine.(consensus.Istanbul); ok {
+		validators, _ := istanbul.GetValidatorsAt(head.NumberU64())
+		for _, validator := range validators {
+			if validator == s.address {
+				report.Validator = true
+				break
+			}
+		}
+	}
+	return report
+}
+
+// assembleBlockStats collects the information to report about a block.
+func (s *Service) assembleBlockStats(block *types.Block) *BlockStats {
+	stats := &BlockStats{
+		Number:  block.NumberU64(),
+		Hash:    block.Hash(),
+		Time:    block.Time().Uint64(),
+		Txs:     len(block.Transactions()),
+		GasUsed: block.GasUsed(),
+	}
+	if inspector, ok := s.chain.Engine().(consensus.CommitInspector); ok && stats.Number > 0 {
+		if proposer, round, err := inspector.CommitInfo(s.chain, block.Header()); err == nil {
+			stats.Proposer, stats.Round = &proposer, &round
+		}
+	}
+	return stats
+}
+
+// assembleResourceStats measures the resource usage of the process.
+func (s *Service) assembleResourceStats() *ResourceStats {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	stats := &ResourceStats{
+		HeapAlloc:  mem.HeapAlloc,
+		Sys:        mem.Sys,
+		Goroutines: runtime.NumGoroutine(),
+	}
+	var disk metrics.DiskStats
+	if metrics.ReadDiskStats(&disk) == nil {
+		stats.DiskRead, stats.DiskWrite = disk.ReadBytes, disk.WriteBytes
+	}
+	now, cpu := time.Now(), metrics.ProcessCPUTime()
+	if !s.lastCPUCheck.IsZero() {
+		if elapsed := now.Sub(s.lastCPUCheck).Seconds(); elapsed > 0 {
+			stats.CPU = (cpu - s.lastCPUTime) / elapsed * 100
+		}
+	}
+	s.lastCPUTime, s.lastCPUCheck = cpu, now
+	return stats
+}
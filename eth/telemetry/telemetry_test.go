@@ -0,0 +1,162 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package telemetry
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/params"
+	"golang.org/x/net/websocket"
+)
+
+// newTestCollector starts a collector accepting the given secret and forwarding
+// the logins and reports it receives.
+func newTestCollector(secret string, hellos, reports chan *message) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.Handle("/telemetry", websocket.Handler(func(conn *websocket.Conn) {
+		var hello message
+		if err := websocket.JSON.Receive(conn, &hello); err != nil {
+			return
+		}
+		hellos <- &hello
+		if hello.Secret != secret {
+			return
+		}
+		websocket.JSON.Send(conn, &message{Type: "ready"})
+		for {
+			var report message
+			if err := websocket.JSON.Receive(conn, &report); err != nil {
+				return
+			}
+			reports <- &report
+		}
+	}))
+	return httptest.NewServer(mux)
+}
+
+// newTestService creates a telemetry client for a short chain, reporting to the
+// given collector url. The transaction pool journals into the given directory.
+func newTestService(t *testing.T, url string, datadir string) (*Service, *p2p.Server) {
+	db, _ := ethdb.NewMemDatabase()
+	genesis := (&core.Genesis{Config: params.TestChainConfig}).MustCommit(db)
+	blocks, _ := core.GenerateChain(params.TestChainConfig, genesis, ethash.NewFaker(), db, 3, nil)
+
+	chain, _ := core.NewBlockChain(db, nil, params.TestChainConfig, ethash.NewFaker(), vm.Config{})
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	poolConfig := core.DefaultTxPoolConfig
+	poolConfig.Journal = filepath.Join(datadir, "transactions.rlp")
+	pool := core.NewTxPool(poolConfig, params.TestChainConfig, chain)
+
+	key, _ := crypto.GenerateKey()
+	server := &p2p.Server{Config: p2p.Config{PrivateKey: key, Name: "test", MaxPeers: 1, NoDiscovery: true}}
+	if err := server.Start(); err != nil {
+		t.Fatalf("failed to start p2p server: %v", err)
+	}
+	config := DefaultConfig
+	config.URL = url
+	service, err := New(&config, chain, pool, func() bool { return false })
+	if err != nil {
+		t.Fatalf("failed to create telemetry client: %v", err)
+	}
+	return service, server
+}
+
+// Tests that the client logs in and reports the health of the node.
+func TestReporting(t *testing.T) {
+	hellos, reports := make(chan *message, 1), make(chan *message, 16)
+	collector := newTestCollector("secret", hellos, reports)
+	defer collector.Close()
+
+	datadir, err := ioutil.TempDir("", "telemetry-test")
+	if err != nil {
+		t.Fatalf("failed to create temporary datadir: %v", err)
+	}
+	defer os.RemoveAll(datadir)
+
+	service, server := newTestService(t, "validator:secret@"+strings.TrimPrefix(collector.URL, "http://"), datadir)
+	defer server.Stop()
+	if err := service.Start(server); err != nil {
+		t.Fatalf("failed to start telemetry client: %v", err)
+	}
+	defer service.Stop()
+
+	select {
+	case hello := <-hellos:
+		if hello.Type != "hello" || hello.ID != "validator" || hello.Info == nil {
+			t.Fatalf("invalid login: %+v", hello)
+		}
+		if hello.Info.Address != crypto.PubkeyToAddress(server.PrivateKey.PublicKey) {
+			t.Errorf("address mismatch: have %x", hello.Info.Address)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("login timeout")
+	}
+	select {
+	case msg := <-reports:
+		if msg.Type != "report" || msg.Report == nil {
+			t.Fatalf("invalid report: %+v", msg)
+		}
+		report := msg.Report
+		if report.Block.Number != 3 || report.Block.Hash != service.chain.CurrentBlock().Hash() {
+			t.Errorf("head mismatch: have #%d [%x]", report.Block.Number, report.Block.Hash)
+		}
+		if report.Resources == nil || report.Resources.Goroutines == 0 {
+			t.Errorf("missing resource usage: %+v", report.Resources)
+		}
+		if report.View != nil || report.Validator {
+			t.Errorf("consensus stats reported by proof-of-work node")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("report timeout")
+	}
+}
+
+// Tests that a login with the wrong secret is rejected.
+func TestUnauthorized(t *testing.T) {
+	hellos, reports := make(chan *message, 1), make(chan *message, 16)
+	collector := newTestCollector("secret", hellos, reports)
+	defer collector.Close()
+
+	datadir, err := ioutil.TempDir("", "telemetry-test")
+	if err != nil {
+		t.Fatalf("failed to create temporary datadir: %v", err)
+	}
+	defer os.RemoveAll(datadir)
+
+	service, server := newTestService(t, "validator:wrong@"+strings.TrimPrefix(collector.URL, "http://"), datadir)
+	defer server.Stop()
+	service.server = server
+
+	if _, err := service.connect(); err != errUnauthorized {
+		t.Errorf("login error mismatch: have %v, want %v", err, errUnauthorized)
+	}
+}
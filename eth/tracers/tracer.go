@@ -277,7 +277,8 @@ func (cw *contractWrapper) pushObject(vm *duktape.Context) {
 // Tracer provides an implementation of Tracer that evaluates a Javascript
 // function for each VM execution step.
 type Tracer struct {
-	inited bool // Flag whether the context was already inited from the EVM
+	inited      bool                                      // Flag whether the context was already inited from the EVM
+	precompiles map[common.Address]vm.PrecompiledContract // Precompiled contracts active in the traced block
 
 	vm *duktape.Context // Javascript VM instance
 
@@ -366,7 +367,11 @@ func New(code string) (*Tracer, error) {
 		return 1
 	})
 	tracer.vm.PushGlobalGoFunction("isPrecompiled", func(ctx *duktape.Context) int {
-		_, ok := vm.PrecompiledContractsByzantium[common.BytesToAddress(popSlice(ctx))]
+		precompiles := tracer.precompiles
+		if precompiles == nil {
+			precompiles = vm.PrecompiledContractsByzantium
+		}
+		_, ok := precompiles[common.BytesToAddress(popSlice(ctx))]
 		ctx.PushBoolean(ok)
 		return 1
 	})
@@ -515,6 +520,7 @@ func (jst *Tracer) CaptureState(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost
 		// Initialize the context if it wasn't done yet
 		if !jst.inited {
 			jst.ctx["block"] = env.BlockNumber.Uint64()
+			jst.precompiles = vm.ActivePrecompiles(env.ChainConfig(), env.BlockNumber)
 			jst.inited = true
 		}
 		// If tracing was interrupted, set the error and stop
@@ -52,6 +52,55 @@ var (
 		Name:  "debug",
 		Usage: "Prepends log messages with call-site location (file and line number)",
 	}
+	logFileFlag = cli.StringFlag{
+		Name:  "log.file",
+		Usage: "Write logs to the given file, rotating it according to the log.file.* flags",
+	}
+	logFileVerbosityFlag = cli.IntFlag{
+		Name:  "log.file.verbosity",
+		Usage: "Logging verbosity of the log file: 0=silent, 1=error, 2=warn, 3=info, 4=debug, 5=detail",
+		Value: 3,
+	}
+	logFileMaxSizeFlag = cli.IntFlag{
+		Name:  "log.file.maxsize",
+		Usage: "Size in megabytes above which the log file is rotated (0 = unlimited)",
+		Value: 100,
+	}
+	logFileMaxAgeFlag = cli.DurationFlag{
+		Name:  "log.file.maxage",
+		Usage: "Time after which the log file is rotated (0 = unlimited)",
+	}
+	logFileMaxBackupsFlag = cli.IntFlag{
+		Name:  "log.file.maxbackups",
+		Usage: "Number of rotated log files to keep (0 = keep all)",
+		Value: 10,
+	}
+	logFileCompressFlag = cli.BoolFlag{
+		Name:  "log.file.compress",
+		Usage: "Compress the rotated log files with gzip",
+	}
+	logSyslogFlag = cli.BoolFlag{
+		Name:  "log.syslog",
+		Usage: "Forward logs to the syslog daemon",
+	}
+	logSyslogAddrFlag = cli.StringFlag{
+		Name:  "log.syslog.addr",
+		Usage: "Remote syslog daemon to forward logs to, as [network://]host:port (default = local daemon)",
+	}
+	logSyslogFacilityFlag = cli.StringFlag{
+		Name:  "log.syslog.facility",
+		Usage: "Syslog facility to log with (e.g. daemon, user, local0-local7)",
+		Value: "daemon",
+	}
+	logSyslogTagFlag = cli.StringFlag{
+		Name:  "log.syslog.tag",
+		Usage: "Tag of the syslog messages (default = program name)",
+	}
+	logSyslogVerbosityFlag = cli.IntFlag{
+		Name:  "log.syslog.verbosity",
+		Usage: "Logging verbosity of syslog: 0=silent, 1=error, 2=warn, 3=info, 4=debug, 5=detail",
+		Value: 3,
+	}
 	pprofFlag = cli.BoolFlag{
 		Name:  "pprof",
 		Usage: "Enable the pprof HTTP server",
@@ -88,11 +137,16 @@ var (
 // Flags holds all command-line flags required for debugging.
 var Flags = []cli.Flag{
 	verbosityFlag, vmoduleFlag, backtraceAtFlag, debugFlag,
+	logFileFlag, logFileVerbosityFlag, logFileMaxSizeFlag, logFileMaxAgeFlag, logFileMaxBackupsFlag, logFileCompressFlag,
+	logSyslogFlag, logSyslogAddrFlag, logSyslogFacilityFlag, logSyslogTagFlag, logSyslogVerbosityFlag,
 	pprofFlag, pprofAddrFlag, pprofPortFlag,
 	memprofilerateFlag, blockprofilerateFlag, cpuprofileFlag, traceFlag,
 }
 
-var glogger *log.GlogHandler
+var (
+	glogger *log.GlogHandler
+	logFile log.Handler // Log file sink, closed on exit
+)
 
 func init() {
 	usecolor := term.IsTty(os.Stderr.Fd()) && os.Getenv("TERM") != "dumb"
@@ -111,7 +165,35 @@ func Setup(ctx *cli.Context) error {
 	glogger.Verbosity(log.Lvl(ctx.GlobalInt(verbosityFlag.Name)))
 	glogger.Vmodule(ctx.GlobalString(vmoduleFlag.Name))
 	glogger.BacktraceAt(ctx.GlobalString(backtraceAtFlag.Name))
-	log.Root().SetHandler(glogger)
+
+	// Additional log sinks, each filtering by its own verbosity
+	handlers := []log.Handler{glogger}
+	if path := ctx.GlobalString(logFileFlag.Name); path != "" {
+		config := log.RotateConfig{
+			MaxSize:    int64(ctx.GlobalInt(logFileMaxSizeFlag.Name)) * 1024 * 1024,
+			MaxAge:     ctx.GlobalDuration(logFileMaxAgeFlag.Name),
+			MaxBackups: ctx.GlobalInt(logFileMaxBackupsFlag.Name),
+			Compress:   ctx.GlobalBool(logFileCompressFlag.Name),
+		}
+		handler, err := log.RotatingFileHandler(path, config, log.LogfmtFormat())
+		if err != nil {
+			return fmt.Errorf("failed to open log file: %v", err)
+		}
+		logFile = handler
+		handlers = append(handlers, log.LvlFilterHandler(log.Lvl(ctx.GlobalInt(logFileVerbosityFlag.Name)), handler))
+	}
+	if ctx.GlobalBool(logSyslogFlag.Name) {
+		handler, err := syslogHandler(ctx.GlobalString(logSyslogAddrFlag.Name), ctx.GlobalString(logSyslogFacilityFlag.Name), ctx.GlobalString(logSyslogTagFlag.Name))
+		if err != nil {
+			return fmt.Errorf("failed to set up syslog: %v", err)
+		}
+		handlers = append(handlers, log.LvlFilterHandler(log.Lvl(ctx.GlobalInt(logSyslogVerbosityFlag.Name)), handler))
+	}
+	if len(handlers) > 1 {
+		log.Root().SetHandler(log.MultiHandler(handlers...))
+	} else {
+		log.Root().SetHandler(glogger)
+	}
 
 	// profiling, tracing
 	runtime.MemProfileRate = ctx.GlobalInt(memprofilerateFlag.Name)
@@ -149,4 +231,8 @@ func Setup(ctx *cli.Context) error {
 func Exit() {
 	Handler.StopCPUProfile()
 	Handler.StopGoTrace()
+
+	if closer, ok := logFile.(io.Closer); ok {
+		closer.Close()
+	}
 }
@@ -0,0 +1,387 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package doctor implements the self-check diagnostics of a node's setup, run
+// by the geth doctor command on a stopped node and by admin_doctor on a running
+// one.
+package doctor
+
+import (
+	"crypto/ecdsa"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/istanbul/backend"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/p2p/discover"
+	"github.com/ethereum/go-ethereum/p2p/netutil"
+)
+
+// Status is the outcome of a check.
+type Status string
+
+const (
+	StatusOK   Status = "ok"   // Nothing to do
+	StatusWarn Status = "warn" // The node works, but likely not as intended
+	StatusFail Status = "fail" // The node won't work until fixed
+	StatusSkip Status = "skip" // The check doesn't apply to the node
+)
+
+// Result is the outcome of a single check, with the steps to take to fix the
+// problem found, if any.
+type Result struct {
+	Check       string `json:"check"`
+	Status      Status `json:"status"`
+	Detail      string `json:"detail"`
+	Remediation string `json:"remediation,omitempty"`
+}
+
+// Config is the setup of the node to check. Checks depending on fields left
+// empty are skipped.
+type Config struct {
+	DataDir     string // Data directory of the node, empty if ephemeral
+	InstanceDir string // Directory of the node's own files within the data directory
+
+	ChainDB      ethdb.Database // Chain database, nil if it couldn't be opened
+	ChainDBError error          // Failure opening the chain database
+	Genesis      *core.Genesis  // Genesis configured for the node, nil to use the stored one
+
+	NTPServers    []string      // NTP servers to measure the clock drift against
+	MaxClockDrift time.Duration // Clock drift above which to warn
+
+	ListenAddr   string         // P2P listening address, empty if not listening
+	Self         *discover.Node // Record advertised by the running node, nil if not running
+	NodeDatabase string         // Path of the discovery node database, empty if not persisted
+
+	NodeKey    *ecdsa.PrivateKey // Node key, also signing the istanbul messages (nil = missing)
+	Validators []common.Address  // Validator set of the chain, nil if not an istanbul chain
+}
+
+var (
+	// DefaultNTPServers are the servers to measure the clock drift against if
+	// none are configured.
+	DefaultNTPServers = []string{"pool.ntp.org"}
+
+	// DefaultMaxClockDrift is the clock drift above which to warn if no threshold
+	// is configured. Istanbul block timestamps have a one second resolution.
+	DefaultMaxClockDrift = time.Second
+
+	// measureDrift measures the local clock drift against an NTP server, mocked
+	// in tests.
+	measureDrift = backend.MeasureClockDrift
+)
+
+// Run executes all checks against the node setup.
+func Run(config *Config) []*Result {
+	return []*Result{
+		checkDataDir(config),
+		checkDatabase(config),
+		checkGenesis(config),
+		checkClock(config),
+		checkPorts(config),
+		checkValidatorKey(config),
+		checkNodeDatabase(config),
+	}
+}
+
+// Failed reports whether any of the checks failed.
+func Failed(results []*Result) bool {
+	for _, result := range results {
+		if result.Status == StatusFail {
+			return true
+		}
+	}
+	return false
+}
+
+// Print writes a human readable report of the check results.
+func Print(w io.Writer, results []*Result) {
+	width := 0
+	for _, result := range results {
+		if len(result.Check) > width {
+			width = len(result.Check)
+		}
+	}
+	for _, result := range results {
+		fmt.Fprintf(w, "[%-4s] %-*s  %s\n", strings.ToUpper(string(result.Status)), width, result.Check, result.Detail)
+		if result.Remediation != "" {
+			fmt.Fprintf(w, "       %-*s  -> %s\n", width, "", result.Remediation)
+		}
+	}
+}
+
+// checkDataDir checks that the data directory is writable and that the keys in
+// it are not exposed to other users.
+func checkDataDir(config *Config) *Result {
+	res := &Result{Check: "datadir"}
+	if config.DataDir == "" {
+		return res.skip("ephemeral node without data directory")
+	}
+	info, err := os.Stat(config.DataDir)
+	switch {
+	case os.IsNotExist(err):
+		return res.warn(fmt.Sprintf("%s does not exist yet", config.DataDir),
+			"The directory is created on first start; check --datadir points to the intended location")
+	case err != nil:
+		return res.fail(err.Error(), "Check --datadir and the permissions of its parent directories")
+	case !info.IsDir():
+		return res.fail(fmt.Sprintf("%s is not a directory", config.DataDir), "Point --datadir to a directory")
+	}
+	if err := checkWritable(config.DataDir); err != nil {
+		return res.fail(fmt.Sprintf("%s is not writable: %v", config.DataDir, err),
+			fmt.Sprintf("Make the directory writable by the node's user, e.g. chown -R <user> %s", config.DataDir))
+	}
+	for _, path := range []string{filepath.Join(config.DataDir, "keystore"), filepath.Join(config.InstanceDir, "nodekey")} {
+		if info, err := os.Stat(path); err == nil && info.Mode().Perm()&0077 != 0 {
+			return res.warn(fmt.Sprintf("%s is accessible by other users (mode %v)", path, info.Mode().Perm()),
+				fmt.Sprintf("Restrict the access to the node's user, e.g. chmod -R go-rwx %s", path))
+		}
+	}
+	return res.ok(fmt.Sprintf("%s is writable", config.DataDir))
+}
+
+// checkDatabase checks that the head of the chain database is consistent.
+func checkDatabase(config *Config) *Result {
+	res := &Result{Check: "database"}
+	if config.ChainDBError != nil {
+		return res.fail(fmt.Sprintf("failed to open chain database: %v", config.ChainDBError),
+			"If the node is running, stop it or run admin.doctor() in its console instead")
+	}
+	db := config.ChainDB
+	if db == nil {
+		return res.skip("no chain database")
+	}
+	head := core.GetHeadBlockHash(db)
+	if head == (common.Hash{}) {
+		return res.ok("empty database, the chain will be initialized on first start")
+	}
+	number := core.GetBlockNumber(db, head)
+	header := core.GetHeader(db, head, number)
+	if header == nil {
+		return res.fail(fmt.Sprintf("head block %x missing", head),
+			"The database is corrupted; resync the chain after running geth removedb")
+	}
+	if canon := core.GetCanonicalHash(db, number); canon != head {
+		return res.fail(fmt.Sprintf("head block #%d %x is not canonical (%x)", number, head, canon),
+			"The database is corrupted; resync the chain after running geth removedb")
+	}
+	if core.GetBody(db, head, number) == nil {
+		return res.fail(fmt.Sprintf("body of head block #%d missing", number),
+			"The database is corrupted; resync the chain after running geth removedb")
+	}
+	if ok, _ := db.Has(header.Root[:]); !ok && header.Root != types.EmptyRootHash {
+		return res.fail(fmt.Sprintf("state of head block #%d missing (root %x)", number, header.Root),
+			"The node was likely not shut down cleanly; it rewinds to the last block with state on start, otherwise resync the chain")
+	}
+	return res.ok(fmt.Sprintf("head block #%d %x with state", number, head))
+}
+
+// checkGenesis checks that the stored genesis and chain config match the ones
+// configured for the node.
+func checkGenesis(config *Config) *Result {
+	res := &Result{Check: "genesis"}
+	db := config.ChainDB
+	if db == nil {
+		return res.skip("no chain database")
+	}
+	stored := core.GetCanonicalHash(db, 0)
+	if stored == (common.Hash{}) {
+		if config.Genesis == nil {
+			return res.warn("database not initialized, the main network genesis will be used",
+				"Run geth init <genesis.json> with the genesis of your network before starting the node")
+		}
+		return res.ok("database not initialized, the configured genesis will be used")
+	}
+	if config.Genesis == nil {
+		if chainConfig, _ := core.GetChainConfig(db, stored); chainConfig == nil {
+			return res.warn(fmt.Sprintf("genesis %x without chain config", stored),
+				"Re-run geth init with the genesis of your network to store its chain config")
+		}
+		return res.ok(fmt.Sprintf("genesis %x", stored))
+	}
+	if hash := config.Genesis.ToBlock(nil).Hash(); hash != stored {
+		return res.fail(fmt.Sprintf("database genesis %x, configured %x", stored, hash),
+			"The data directory belongs to another network; use the right --datadir, or remove the chain with geth removedb and init it again")
+	}
+	chainConfig, _ := core.GetChainConfig(db, stored)
+	if chainConfig == nil || config.Genesis.Config == nil {
+		return res.ok(fmt.Sprintf("genesis %x", stored))
+	}
+	head := core.GetBlockNumber(db, core.GetHeadHeaderHash(db))
+	if head == ^uint64(0) {
+		head = 0
+	}
+	if err := chainConfig.CheckCompatible(config.Genesis.Config, head); err != nil {
+		return res.fail(fmt.Sprintf("configured chain config incompatible with the chain: %v", err),
+			fmt.Sprintf("Use the genesis the network runs with, or rewind the chain below block %d with debug.setHead", err.RewindTo))
+	}
+	return res.ok(fmt.Sprintf("genesis %x, chain config compatible", stored))
+}
+
+// checkClock measures the drift of the local clock against the NTP servers.
+func checkClock(config *Config) *Result {
+	res := &Result{Check: "clock"}
+
+	servers, threshold := config.NTPServers, config.MaxClockDrift
+	if len(servers) == 0 {
+		servers = DefaultNTPServers
+	}
+	if threshold == 0 {
+		threshold = DefaultMaxClockDrift
+	}
+	var errs []string
+	for _, server := range servers {
+		drift, err := measureDrift(server)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", server, err))
+			continue
+		}
+		if drift < -threshold || drift > threshold {
+			return res.warn(fmt.Sprintf("drift of %v against %s exceeds %v", drift, server, threshold),
+				"Enable network time synchronisation (e.g. ntpd, chrony or systemd-timesyncd), drifting validators have their blocks rejected")
+		}
+		return res.ok(fmt.Sprintf("drift of %v against %s", drift, server))
+	}
+	return res.warn(fmt.Sprintf("no NTP server reachable (%s)", strings.Join(errs, ", ")),
+		"Allow outgoing UDP traffic to port 123, or configure reachable servers with --istanbul.ntpservers")
+}
+
+// checkPorts checks that the P2P port can be listened on, or that the running
+// node advertises an address reachable by its peers.
+func checkPorts(config *Config) *Result {
+	res := &Result{Check: "ports"}
+	if self := config.Self; self != nil {
+		addr := net.JoinHostPort(self.IP.String(), fmt.Sprint(self.TCP))
+		if self.IP.IsUnspecified() || netutil.IsLAN(self.IP) {
+			return res.warn(fmt.Sprintf("advertised address %s is not publicly routable", addr),
+				"If peers connect from other networks, set --nat extip:<public IP> and forward the port")
+		}
+		return res.ok(fmt.Sprintf("listening on %s", addr))
+	}
+	if config.ListenAddr == "" {
+		return res.skip("networking disabled")
+	}
+	tcp, err := net.Listen("tcp", config.ListenAddr)
+	if err != nil {
+		return res.fail(fmt.Sprintf("TCP %s unavailable: %v", config.ListenAddr, err),
+			"Stop the process using the port (another geth instance?) or choose another one with --port")
+	}
+	tcp.Close()
+
+	udp, err := net.ListenPacket("udp", config.ListenAddr)
+	if err != nil {
+		return res.fail(fmt.Sprintf("UDP %s unavailable: %v", config.ListenAddr, err),
+			"Stop the process using the port (another geth instance?) or choose another one with --port")
+	}
+	udp.Close()
+
+	return res.ok(fmt.Sprintf("TCP and UDP %s available, make sure firewalls let peers in", config.ListenAddr))
+}
+
+// checkValidatorKey checks that the node key is available and, on istanbul
+// chains, authorized to validate.
+func checkValidatorKey(config *Config) *Result {
+	res := &Result{Check: "validator key"}
+	if config.Validators == nil {
+		return res.skip("not an istanbul chain")
+	}
+	if config.NodeKey == nil {
+		return res.fail("node key missing, a new one would be generated on start",
+			fmt.Sprintf("Restore the key registered as validator to %s, or pass it with --nodekey", filepath.Join(config.InstanceDir, "nodekey")))
+	}
+	address := crypto.PubkeyToAddress(config.NodeKey.PublicKey)
+	for _, validator := range config.Validators {
+		if validator == address {
+			return res.ok(fmt.Sprintf("node key %x is one of %d validators", address, len(config.Validators)))
+		}
+	}
+	return res.warn(fmt.Sprintf("node key %x is not among the %d validators", address, len(config.Validators)),
+		"If the node should validate, restore the key registered for it, or have the validators vote it in with istanbul.propose")
+}
+
+// checkNodeDatabase checks that the discovery table of the node is persisted,
+// saving it from bootstrapping from the bootnodes on every restart.
+func checkNodeDatabase(config *Config) *Result {
+	res := &Result{Check: "node database"}
+	if config.NodeDatabase == "" {
+		return res.skip("discovery disabled or not persisted")
+	}
+	info, err := os.Stat(config.NodeDatabase)
+	switch {
+	case os.IsNotExist(err):
+		return res.warn("no known peers persisted yet",
+			"Peers are found through --bootnodes on start, make sure they are reachable")
+	case err != nil:
+		return res.fail(err.Error(), fmt.Sprintf("Check the permissions of %s", config.NodeDatabase))
+	case !info.IsDir():
+		return res.fail(fmt.Sprintf("%s is not a directory", config.NodeDatabase),
+			fmt.Sprintf("Remove %s, it is recreated on start", config.NodeDatabase))
+	}
+	if config.Self == nil {
+		// The running node holds the database lock, only open it when stopped
+		db, err := ethdb.NewLDBDatabase(config.NodeDatabase, 0, 0)
+		if err != nil {
+			return res.fail(fmt.Sprintf("failed to open %s: %v", config.NodeDatabase, err),
+				fmt.Sprintf("Remove %s, the known peers are rediscovered from the bootnodes", config.NodeDatabase))
+		}
+		db.Close()
+	}
+	if err := checkWritable(config.NodeDatabase); err != nil {
+		return res.fail(fmt.Sprintf("%s is not writable: %v", config.NodeDatabase, err),
+			"Make the directory writable by the node's user")
+	}
+	return res.ok(fmt.Sprintf("known peers persisted in %s", config.NodeDatabase))
+}
+
+// checkWritable checks that files can be created in a directory.
+func checkWritable(dir string) error {
+	f, err := ioutil.TempFile(dir, ".doctor")
+	if err != nil {
+		return err
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
+
+func (r *Result) ok(detail string) *Result {
+	r.Status, r.Detail = StatusOK, detail
+	return r
+}
+
+func (r *Result) skip(detail string) *Result {
+	r.Status, r.Detail = StatusSkip, detail
+	return r
+}
+
+func (r *Result) warn(detail, remediation string) *Result {
+	r.Status, r.Detail, r.Remediation = StatusWarn, detail, remediation
+	return r
+}
+
+func (r *Result) fail(detail, remediation string) *Result {
+	r.Status, r.Detail, r.Remediation = StatusFail, detail, remediation
+	return r
+}
@@ -0,0 +1,145 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package doctor
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// Tests that a sound setup passes all checks, and that the problems introduced
+// one by one are reported by the right check.
+func TestDoctor(t *testing.T) {
+	datadir, err := ioutil.TempDir("", "doctor-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(datadir)
+
+	drift := time.Duration(0)
+	defer func(measure func(string) (time.Duration, error)) { measureDrift = measure }(measureDrift)
+	measureDrift = func(string) (time.Duration, error) { return drift, nil }
+
+	key, _ := crypto.GenerateKey()
+	validator := crypto.PubkeyToAddress(key.PublicKey)
+
+	db, _ := ethdb.NewMemDatabase()
+	genesis := &core.Genesis{Config: params.AllEthashProtocolChanges, Alloc: core.GenesisAlloc{validator: {Balance: big.NewInt(1)}}}
+	genesis.MustCommit(db)
+
+	config := &Config{
+		DataDir:     datadir,
+		InstanceDir: filepath.Join(datadir, "geth"),
+		ChainDB:     db,
+		Genesis:     genesis,
+		ListenAddr:  "127.0.0.1:0",
+		NodeKey:     key,
+		Validators:  []common.Address{validator},
+	}
+	results := Run(config)
+	for _, result := range results {
+		if result.Status != StatusOK && result.Status != StatusSkip {
+			t.Errorf("check %s: status %s: %s", result.Check, result.Status, result.Detail)
+		}
+	}
+	if Failed(results) {
+		t.Errorf("sound setup failed")
+	}
+	// Break the setup and check that the problems are found
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	file := filepath.Join(datadir, "file")
+	if err := ioutil.WriteFile(file, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	other, _ := crypto.GenerateKey()
+	tests := []struct {
+		check  string
+		status Status
+		modify func(*Config)
+	}{
+		{"datadir", StatusWarn, func(c *Config) { c.DataDir = filepath.Join(datadir, "missing") }},
+		{"datadir", StatusFail, func(c *Config) { c.DataDir = file }},
+		{"database", StatusFail, func(c *Config) { c.ChainDB, c.ChainDBError = nil, errors.New("resource temporarily unavailable") }},
+		{"genesis", StatusWarn, func(c *Config) { c.ChainDB, _ = ethdb.NewMemDatabase(); c.Genesis = nil }},
+		{"genesis", StatusFail, func(c *Config) { c.Genesis = &core.Genesis{Config: params.AllEthashProtocolChanges} }},
+		{"clock", StatusWarn, func(*Config) { drift = 3 * time.Second }},
+		{"ports", StatusFail, func(c *Config) { c.ListenAddr = listener.Addr().String() }},
+		{"validator key", StatusWarn, func(c *Config) { c.NodeKey = other }},
+		{"validator key", StatusFail, func(c *Config) { c.NodeKey = nil }},
+		{"node database", StatusWarn, func(c *Config) { c.NodeDatabase = filepath.Join(datadir, "geth", "nodes") }},
+	}
+	for i, tt := range tests {
+		broken := *config
+		drift = 0
+		tt.modify(&broken)
+
+		var found *Result
+		for _, result := range Run(&broken) {
+			if result.Check == tt.check {
+				found = result
+			}
+		}
+		if found == nil {
+			t.Fatalf("test %d: check %s missing", i, tt.check)
+		}
+		if found.Status != tt.status {
+			t.Errorf("test %d: check %s status mismatch: have %s, want %s (%s)", i, tt.check, found.Status, tt.status, found.Detail)
+		}
+		if found.Remediation == "" {
+			t.Errorf("test %d: check %s without remediation", i, tt.check)
+		}
+	}
+}
+
+// Tests that the report lists every check with the remediation of the problems.
+func TestPrint(t *testing.T) {
+	results := []*Result{
+		{Check: "clock", Status: StatusOK, Detail: "drift of 1ms against pool.ntp.org"},
+		{Check: "validator key", Status: StatusWarn, Detail: "node key not a validator", Remediation: "vote it in"},
+	}
+	buf := new(bytes.Buffer)
+	Print(buf, results)
+
+	want := "[OK  ] clock          drift of 1ms against pool.ntp.org\n" +
+		"[WARN] validator key  node key not a validator\n" +
+		"                      -> vote it in\n"
+	if buf.String() != want {
+		t.Errorf("report mismatch:\nhave:\n%s\nwant:\n%s", buf, want)
+	}
+	if strings.Contains(buf.String(), "FAIL") || Failed(results) {
+		t.Errorf("report without failures reported as failed")
+	}
+}
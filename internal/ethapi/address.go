@@ -0,0 +1,92 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethapi
+
+import (
+	"encoding/json"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/urlscheme"
+)
+
+// AddressArg is an account argument of the API, given either as a plain
+// hexadecimal address or as an address URI (ethereum:<address>).
+type AddressArg common.Address
+
+// UnmarshalJSON parses an account from a JSON string.
+func (a *AddressArg) UnmarshalJSON(input []byte) error {
+	var s string
+	if err := json.Unmarshal(input, &s); err != nil || !urlscheme.IsAddressURI(s) {
+		return (*common.Address)(a).UnmarshalJSON(input)
+	}
+	addr, err := urlscheme.ParseAddress(s)
+	if err != nil {
+		return err
+	}
+	*a = AddressArg(addr)
+	return nil
+}
+
+// MarshalText returns the hex representation of the account.
+func (a AddressArg) MarshalText() ([]byte, error) {
+	return common.Address(a).MarshalText()
+}
+
+// Address returns the account as a plain address.
+func (a AddressArg) Address() common.Address {
+	return common.Address(a)
+}
+
+// UnmarshalJSON parses the arguments of a call, accepting the accounts in any
+// form AddressArg does.
+func (args *CallArgs) UnmarshalJSON(input []byte) error {
+	type callArgs CallArgs
+	var dec struct {
+		callArgs
+		From *AddressArg `json:"from"`
+		To   *AddressArg `json:"to"`
+	}
+	if err := json.Unmarshal(input, &dec); err != nil {
+		return err
+	}
+	*args = CallArgs(dec.callArgs)
+	if dec.From != nil {
+		args.From = dec.From.Address()
+	}
+	args.To = (*common.Address)(dec.To)
+	return nil
+}
+
+// UnmarshalJSON parses the arguments of a transaction, accepting the accounts
+// in any form AddressArg does.
+func (args *SendTxArgs) UnmarshalJSON(input []byte) error {
+	type sendTxArgs SendTxArgs
+	var dec struct {
+		sendTxArgs
+		From *AddressArg `json:"from"`
+		To   *AddressArg `json:"to"`
+	}
+	if err := json.Unmarshal(input, &dec); err != nil {
+		return err
+	}
+	*args = SendTxArgs(dec.sendTxArgs)
+	if dec.From != nil {
+		args.From = dec.From.Address()
+	}
+	args.To = (*common.Address)(dec.To)
+	return nil
+}
@@ -0,0 +1,66 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethapi
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Tests that account arguments are accepted both as plain addresses and as
+// address URIs, also within transaction and call arguments.
+func TestAddressArg(t *testing.T) {
+	addr := common.HexToAddress("0xfb6916095ca1df60bb79ce92ce3ea74c37c5d359")
+
+	for _, input := range []string{
+		`"` + addr.Hex() + `"`,
+		`"ethereum:` + addr.Hex() + `"`,
+		`"eth:pay-` + addr.Hex() + `@1"`,
+	} {
+		var arg AddressArg
+		if err := json.Unmarshal([]byte(input), &arg); err != nil || arg.Address() != addr {
+			t.Errorf("%s: address mismatch: have %x (%v), want %x", input, arg, err, addr)
+		}
+		var tx SendTxArgs
+		if err := json.Unmarshal([]byte(`{"from":`+input+`,"to":`+input+`,"gas":"0x5208"}`), &tx); err != nil {
+			t.Fatalf("%s: failed to decode transaction: %v", input, err)
+		}
+		if tx.From != addr || tx.To == nil || *tx.To != addr || tx.Gas == nil || *tx.Gas != 0x5208 {
+			t.Errorf("%s: transaction mismatch: have from %x, to %v, gas %v", input, tx.From, tx.To, tx.Gas)
+		}
+		var call CallArgs
+		if err := json.Unmarshal([]byte(`{"from":`+input+`,"to":`+input+`,"gas":"0x5208"}`), &call); err != nil {
+			t.Fatalf("%s: failed to decode call: %v", input, err)
+		}
+		if call.From != addr || call.To == nil || *call.To != addr || call.Gas != 0x5208 {
+			t.Errorf("%s: call mismatch: have from %x, to %v, gas %v", input, call.From, call.To, call.Gas)
+		}
+	}
+	// Contract creations carry no recipient
+	var tx SendTxArgs
+	if err := json.Unmarshal([]byte(`{"from":"`+addr.Hex()+`","to":null}`), &tx); err != nil || tx.To != nil {
+		t.Errorf("contract creation mismatch: have to %v (%v), want nil", tx.To, err)
+	}
+	for _, input := range []string{`"ethereum:0x1234"`, `"bitcoin:` + addr.Hex() + `"`, `"0x1234"`, `1`} {
+		var arg AddressArg
+		if err := json.Unmarshal([]byte(input), &arg); err == nil {
+			t.Errorf("%s: invalid address accepted", input)
+		}
+	}
+}
@@ -17,14 +17,33 @@
 package ethapi
 
 import (
+	"context"
+	"sort"
 	"sync"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common"
 )
 
+const (
+	defaultNonceLease = 5 * time.Minute // Lifetime of nonce leases if none is requested
+	maxNonceLease     = 1024            // Maximum number of nonces leased at once
+)
+
 type AddrLocker struct {
 	mu    sync.Mutex
 	locks map[common.Address]*sync.Mutex
+
+	leaseMu sync.Mutex
+	leases  map[common.Address]*nonceLeases
+}
+
+// nonceLeases tracks the nonces of an account handed out ahead of submitting
+// transactions with them.
+type nonceLeases struct {
+	next   uint64               // Lowest nonce never handed out
+	free   []uint64             // Nonces handed out but given back, sorted ascending
+	leased map[uint64]time.Time // Nonces reserved until the given time
 }
 
 // lock returns the lock of the given address.
@@ -51,3 +70,147 @@ func (l *AddrLocker) LockAddr(address common.Address) {
 func (l *AddrLocker) UnlockAddr(address common.Address) {
 	l.lock(address).Unlock()
 }
+
+// LeaseNonces reserves count nonces of an account for the given lifetime. Nonces
+// given back earlier are handed out first, filling the gaps they would otherwise
+// leave in the account's transaction sequence. Nonces below poolNonce, the next
+// nonce of the account as seen by the transaction pool, are considered used.
+func (l *AddrLocker) LeaseNonces(address common.Address, poolNonce uint64, count int, lifetime time.Duration) []uint64 {
+	l.leaseMu.Lock()
+	defer l.leaseMu.Unlock()
+
+	now := time.Now()
+	leases := l.accountLeases(address, poolNonce, now)
+
+	nonces := make([]uint64, 0, count)
+	for len(nonces) < count {
+		var nonce uint64
+		if len(leases.free) > 0 {
+			nonce, leases.free = leases.free[0], leases.free[1:]
+		} else {
+			nonce = leases.next
+			leases.next++
+		}
+		leases.leased[nonce] = now.Add(lifetime)
+		nonces = append(nonces, nonce)
+	}
+	return nonces
+}
+
+// UseNonce marks a nonce of an account as used by a transaction submitted to the
+// pool, ending its lease. Nonces skipped over by it are made available for
+// leasing, so they get filled.
+func (l *AddrLocker) UseNonce(address common.Address, nonce uint64) {
+	l.leaseMu.Lock()
+	defer l.leaseMu.Unlock()
+
+	leases, ok := l.leases[address]
+	if !ok {
+		return // Account doesn't lease nonces, nothing to track
+	}
+	delete(leases.leased, nonce)
+	for i, free := range leases.free {
+		if free == nonce {
+			leases.free = append(leases.free[:i], leases.free[i+1:]...)
+			break
+		}
+	}
+	if nonce >= leases.next {
+		if nonce-leases.next <= maxNonceLease {
+			for gap := leases.next; gap < nonce; gap++ {
+				leases.free = append(leases.free, gap)
+			}
+		}
+		leases.next = nonce + 1
+	}
+}
+
+// ReleaseNonces gives back leased nonces of an account that won't be used, to be
+// handed out again before any new ones. It returns the number of nonces that
+// were actually leased.
+func (l *AddrLocker) ReleaseNonces(address common.Address, nonces []uint64) int {
+	l.leaseMu.Lock()
+	defer l.leaseMu.Unlock()
+
+	leases, ok := l.leases[address]
+	if !ok {
+		return 0
+	}
+	released := 0
+	for _, nonce := range nonces {
+		if _, ok := leases.leased[nonce]; ok {
+			delete(leases.leased, nonce)
+			leases.free = append(leases.free, nonce)
+			released++
+		}
+	}
+	leases.compact()
+	return released
+}
+
+// accountLeases returns the lease tracker of an account, creating it if needed
+// and bringing it up to date with the transaction pool and the current time.
+func (l *AddrLocker) accountLeases(address common.Address, poolNonce uint64, now time.Time) *nonceLeases {
+	if l.leases == nil {
+		l.leases = make(map[common.Address]*nonceLeases)
+	}
+	leases, ok := l.leases[address]
+	if !ok {
+		leases = &nonceLeases{next: poolNonce, leased: make(map[uint64]time.Time)}
+		l.leases[address] = leases
+	}
+	// Forget about the nonces used in the meantime and recycle expired leases
+	if leases.next < poolNonce {
+		leases.next = poolNonce
+	}
+	free := leases.free[:0]
+	for _, nonce := range leases.free {
+		if nonce >= poolNonce {
+			free = append(free, nonce)
+		}
+	}
+	leases.free = free
+	for nonce, expiry := range leases.leased {
+		switch {
+		case nonce < poolNonce:
+			delete(leases.leased, nonce)
+		case now.After(expiry):
+			delete(leases.leased, nonce)
+			leases.free = append(leases.free, nonce)
+		}
+	}
+	leases.compact()
+	return leases
+}
+
+// compact sorts the free nonces and drops the ones at the top of the handed out
+// range, as they can be handed out as new ones.
+func (leases *nonceLeases) compact() {
+	sort.Slice(leases.free, func(i, j int) bool { return leases.free[i] < leases.free[j] })
+	for n := len(leases.free); n > 0 && leases.free[n-1] == leases.next-1; n-- {
+		leases.free = leases.free[:n-1]
+		leases.next--
+	}
+}
+
+// settleNonce ends the lease of a nonce after submitting a transaction with it,
+// marking it used on success or giving it back if it was leased for the
+// submission only.
+func (l *AddrLocker) settleNonce(address common.Address, nonce uint64, leased bool, err error) {
+	switch {
+	case err == nil:
+		l.UseNonce(address, nonce)
+	case leased:
+		l.ReleaseNonces(address, []uint64{nonce})
+	}
+}
+
+// reserveNonce leases the next nonce of an account for a transaction about to be
+// submitted.
+func (l *AddrLocker) reserveNonce(ctx context.Context, b Backend, address common.Address) (uint64, error) {
+	poolNonce, err := b.GetPoolNonce(ctx, address)
+	if err != nil {
+		return 0, err
+	}
+	return l.LeaseNonces(address, poolNonce, 1, defaultNonceLease)[0], nil
+}
@@ -0,0 +1,80 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethapi
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Tests that nonces are leased consecutively from the pool nonce, and that given
+// back or skipped nonces are handed out again before any new ones.
+func TestNonceLeases(t *testing.T) {
+	var (
+		locker AddrLocker
+		addr   = common.HexToAddress("0x01")
+	)
+	lease := func(poolNonce uint64, count int, want ...uint64) {
+		t.Helper()
+		if have := locker.LeaseNonces(addr, poolNonce, count, time.Minute); !reflect.DeepEqual(have, want) {
+			t.Fatalf("leased nonces mismatch: have %v, want %v", have, want)
+		}
+	}
+	lease(5, 3, 5, 6, 7)
+	lease(5, 1, 8)
+
+	// Released nonces fill the gaps first, unknown ones are ignored
+	if n := locker.ReleaseNonces(addr, []uint64{6, 7, 42}); n != 2 {
+		t.Fatalf("released nonce count mismatch: have %d, want 2", n)
+	}
+	if n := locker.ReleaseNonces(common.HexToAddress("0x02"), []uint64{1}); n != 0 {
+		t.Fatalf("released nonce count of unknown account mismatch: have %d, want 0", n)
+	}
+	lease(5, 3, 6, 7, 9)
+
+	// Releasing the top of the range hands the nonces out as new ones
+	locker.ReleaseNonces(addr, []uint64{9, 8})
+	lease(5, 1, 8)
+
+	// Nonces skipped by submitted transactions are filled, used ones never leased
+	locker.UseNonce(addr, 12)
+	lease(5, 4, 9, 10, 11, 13)
+
+	// Nonces below the pool nonce are considered used
+	locker.ReleaseNonces(addr, []uint64{9, 10, 11, 13})
+	lease(11, 2, 11, 13)
+}
+
+// Tests that expired leases are reclaimed.
+func TestNonceLeaseExpiry(t *testing.T) {
+	var (
+		locker AddrLocker
+		addr   = common.HexToAddress("0x01")
+	)
+	locker.LeaseNonces(addr, 0, 2, time.Hour)
+	locker.LeaseNonces(addr, 0, 2, -time.Second)
+
+	if have := locker.LeaseNonces(addr, 0, 3, time.Hour); !reflect.DeepEqual(have, []uint64{2, 3, 4}) {
+		t.Fatalf("leased nonces mismatch: have %v, want [2 3 4]", have)
+	}
+	if n := locker.ReleaseNonces(addr, []uint64{0, 1, 2, 3, 4}); n != 5 {
+		t.Fatalf("released nonce count mismatch: have %d, want 5", n)
+	}
+}
@@ -19,6 +19,7 @@ package ethapi
 import (
 	"bytes"
 	"context"
+	"encoding/binary"
 	"errors"
 	"fmt"
 	"math/big"
@@ -30,8 +31,10 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/consensus"
 	"github.com/ethereum/go-ethereum/consensus/ethash"
 	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/core/vm"
 	"github.com/ethereum/go-ethereum/crypto"
@@ -48,6 +51,10 @@ const (
 	defaultGasPrice = 50 * params.Shannon
 )
 
+// errExecutionTimeout is returned when an EVM execution is aborted for exceeding
+// the configured time limit.
+var errExecutionTimeout = errors.New("execution aborted: timeout exceeded")
+
 // PublicEthereumAPI provides an API to access Ethereum related information.
 // It offers only methods that operate on public data that is freely available to anyone.
 type PublicEthereumAPI struct {
@@ -64,6 +71,54 @@ func (s *PublicEthereumAPI) GasPrice(ctx context.Context) (*big.Int, error) {
 	return s.b.SuggestPrice(ctx)
 }
 
+// MaxPriorityFeePerGas returns a suggestion for the priority fee of dynamic fee
+// transactions. Without a base fee the whole gas price is the priority fee, so
+// this is the same as the suggested gas price.
+func (s *PublicEthereumAPI) MaxPriorityFeePerGas(ctx context.Context) (*hexutil.Big, error) {
+	price, err := s.b.SuggestPrice(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return (*hexutil.Big)(price), nil
+}
+
+// feeHistoryResult is the fee market history of a range of blocks.
+type feeHistoryResult struct {
+	OldestBlock  *hexutil.Big     `json:"oldestBlock"`
+	Reward       [][]*hexutil.Big `json:"reward,omitempty"`
+	BaseFee      []*hexutil.Big   `json:"baseFeePerGas,omitempty"`
+	GasUsedRatio []float64        `json:"gasUsedRatio"`
+}
+
+// FeeHistory returns the fee market history of the blockCount blocks ending
+// with lastBlock, along with the requested percentiles of the fees paid in each.
+func (s *PublicEthereumAPI) FeeHistory(ctx context.Context, blockCount hexutil.Uint64, lastBlock rpc.BlockNumber, rewardPercentiles []float64) (*feeHistoryResult, error) {
+	oldest, reward, baseFee, gasUsed, err := s.b.FeeHistory(ctx, int(blockCount), lastBlock, rewardPercentiles)
+	if err != nil {
+		return nil, err
+	}
+	results := &feeHistoryResult{
+		OldestBlock:  (*hexutil.Big)(oldest),
+		GasUsedRatio: gasUsed,
+	}
+	if reward != nil {
+		results.Reward = make([][]*hexutil.Big, len(reward))
+		for i, w := range reward {
+			results.Reward[i] = make([]*hexutil.Big, len(w))
+			for j, v := range w {
+				results.Reward[i][j] = (*hexutil.Big)(v)
+			}
+		}
+	}
+	if baseFee != nil {
+		results.BaseFee = make([]*hexutil.Big, len(baseFee))
+		for i, v := range baseFee {
+			results.BaseFee[i] = (*hexutil.Big)(v)
+		}
+	}
+	return results, nil
+}
+
 // ProtocolVersion returns the current Ethereum protocol version this node supports
 func (s *PublicEthereumAPI) ProtocolVersion() hexutil.Uint {
 	return hexutil.Uint(s.b.ProtocolVersion())
@@ -202,6 +257,7 @@ func (s *PublicAccountAPI) Accounts() []common.Address {
 type PrivateAccountAPI struct {
 	am        *accounts.Manager
 	nonceLock *AddrLocker
+	approvals *approvalQueue
 	b         Backend
 }
 
@@ -210,6 +266,7 @@ func NewPrivateAccountAPI(b Backend, nonceLock *AddrLocker) *PrivateAccountAPI {
 	return &PrivateAccountAPI{
 		am:        b.AccountManager(),
 		nonceLock: nonceLock,
+		approvals: newApprovalQueue(b.TxApproval()),
 		b:         b,
 	}
 }
@@ -269,6 +326,16 @@ func (s *PrivateAccountAPI) OpenWallet(url string, passphrase *string) error {
 	return wallet.Open(pass)
 }
 
+// CloseWallet terminates the connection to a hardware wallet, releasing the USB
+// device so that other applications may use it.
+func (s *PrivateAccountAPI) CloseWallet(url string) error {
+	wallet, err := s.am.Wallet(url)
+	if err != nil {
+		return err
+	}
+	return wallet.Close()
+}
+
 // DeriveAccount requests a HD wallet to derive a new account, optionally pinning
 // it for later reuse.
 func (s *PrivateAccountAPI) DeriveAccount(url string, path string, pin *bool) (accounts.Account, error) {
@@ -314,7 +381,11 @@ func (s *PrivateAccountAPI) ImportRawKey(privkey string, password string) (commo
 // UnlockAccount will unlock the account associated with the given address with
 // the given password for duration seconds. If duration is nil it will use a
 // default of 300 seconds. It returns an indication if the account was unlocked.
-func (s *PrivateAccountAPI) UnlockAccount(addr common.Address, password string, duration *uint64) (bool, error) {
+//
+// The optional scope restricts the account to signing for the listed RPC
+// namespaces and to transactions to the listed targets. The duration is capped
+// by the node's unlock timeout, if any, also for indefinite unlocks.
+func (s *PrivateAccountAPI) UnlockAccount(addr AddressArg, password string, duration *uint64, scope *keystore.UnlockScope) (bool, error) {
 	const max = uint64(time.Duration(math.MaxInt64) / time.Second)
 	var d time.Duration
 	if duration == nil {
@@ -324,13 +395,25 @@ func (s *PrivateAccountAPI) UnlockAccount(addr common.Address, password string,
 	} else {
 		d = time.Duration(*duration) * time.Second
 	}
-	err := fetchKeystore(s.am).TimedUnlock(accounts.Account{Address: addr}, password, d)
+	if limit := s.b.RPCUnlockTimeout(); limit > 0 && (d == 0 || d > limit) {
+		d = limit
+	}
+	err := fetchKeystore(s.am).ScopedUnlock(accounts.Account{Address: addr.Address()}, password, d, scope)
 	return err == nil, err
 }
 
+// checkUnlockScope returns an error if the account is unlocked for signing via
+// other RPC namespaces only.
+func checkUnlockScope(am *accounts.Manager, addr common.Address, namespace string) error {
+	if backends := am.Backends(keystore.KeyStoreType); len(backends) > 0 {
+		return backends[0].(*keystore.KeyStore).CheckScope(addr, namespace)
+	}
+	return nil
+}
+
 // LockAccount will lock the account associated with the given address when it's unlocked.
-func (s *PrivateAccountAPI) LockAccount(addr common.Address) bool {
-	return fetchKeystore(s.am).Lock(addr) == nil
+func (s *PrivateAccountAPI) LockAccount(addr AddressArg) bool {
+	return fetchKeystore(s.am).Lock(addr.Address()) == nil
 }
 
 // signTransactions sets defaults and signs the given transaction
@@ -361,24 +444,180 @@ func (s *PrivateAccountAPI) signTransaction(ctx context.Context, args SendTxArgs
 // tries to sign it with the key associated with args.To. If the given passwd isn't
 // able to decrypt the key it fails.
 func (s *PrivateAccountAPI) SendTransaction(ctx context.Context, args SendTxArgs, passwd string) (common.Hash, error) {
-	if args.Nonce == nil {
-		// Hold the addresse's mutex around signing to prevent concurrent assignment of
-		// the same nonce to multiple accounts.
-		s.nonceLock.LockAddr(args.From)
-		defer s.nonceLock.UnlockAddr(args.From)
+	if s.b.TxApproval().Guarded(args.From) {
+		return common.Hash{}, errApprovalRequired
+	}
+	leased := args.Nonce == nil
+	if leased {
+		// Reserve the nonce to prevent its concurrent assignment to other
+		// transactions of the account.
+		nonce, err := s.nonceLock.reserveNonce(ctx, s.b, args.From)
+		if err != nil {
+			return common.Hash{}, err
+		}
+		args.Nonce = (*hexutil.Uint64)(&nonce)
 	}
+	nonce := uint64(*args.Nonce)
+
 	signed, err := s.signTransaction(ctx, args, passwd)
+	if err != nil {
+		s.nonceLock.settleNonce(args.From, nonce, leased, err)
+		return common.Hash{}, err
+	}
+	hash, err := submitTransaction(ctx, s.b, signed)
+	s.nonceLock.settleNonce(args.From, nonce, leased, err)
+	return hash, err
+}
+
+// BatchTxResult is the outcome of submitting a transaction of a batch.
+type BatchTxResult struct {
+	Hash  common.Hash    `json:"hash"`
+	Nonce hexutil.Uint64 `json:"nonce"`
+	Error string         `json:"error,omitempty"`
+}
+
+// SendTransactionBatch creates, signs and submits a batch of transactions in
+// order. The nonces not given are reserved for the whole batch upfront, so the
+// transactions of an account get consecutive nonces even with other submissions
+// in flight. A failing transaction doesn't abort the batch, its nonce is passed
+// on to the next transaction of the account instead of leaving a gap.
+//
+// If no password is given, the senders must be unlocked. This avoids decrypting
+// the key for every transaction.
+func (s *PrivateAccountAPI) SendTransactionBatch(ctx context.Context, args []SendTxArgs, passwd *string) ([]*BatchTxResult, error) {
+	if len(args) > maxNonceLease {
+		return nil, fmt.Errorf("batch too large: %d transactions, max %d", len(args), maxNonceLease)
+	}
+	for _, tx := range args {
+		if s.b.TxApproval().Guarded(tx.From) {
+			return nil, errApprovalRequired
+		}
+	}
+	// Reserve the missing nonces of every sender at once
+	needed := make(map[common.Address]int)
+	for _, tx := range args {
+		if tx.Nonce == nil {
+			needed[tx.From]++
+		}
+	}
+	reserved := make(map[common.Address][]uint64)
+	defer func() {
+		for addr, nonces := range reserved {
+			s.nonceLock.ReleaseNonces(addr, nonces)
+		}
+	}()
+	for addr, count := range needed {
+		poolNonce, err := s.b.GetPoolNonce(ctx, addr)
+		if err != nil {
+			return nil, err
+		}
+		reserved[addr] = s.nonceLock.LeaseNonces(addr, poolNonce, count, defaultNonceLease)
+	}
+	// Sign and submit the transactions, reusing the nonces of failed ones
+	results := make([]*BatchTxResult, len(args))
+	for i, tx := range args {
+		leased := tx.Nonce == nil
+		if leased {
+			nonce := reserved[tx.From][0]
+			reserved[tx.From] = reserved[tx.From][1:]
+			tx.Nonce = (*hexutil.Uint64)(&nonce)
+		}
+		nonce := uint64(*tx.Nonce)
+		results[i] = &BatchTxResult{Nonce: hexutil.Uint64(nonce)}
+
+		hash, err := s.sendBatchTransaction(ctx, tx, passwd)
+		if err != nil {
+			results[i].Error = err.Error()
+			if leased {
+				reserved[tx.From] = append([]uint64{nonce}, reserved[tx.From]...)
+			}
+			continue
+		}
+		s.nonceLock.UseNonce(tx.From, nonce)
+		results[i].Hash = hash
+	}
+	return results, nil
+}
+
+// sendBatchTransaction signs a transaction of a batch, with the password if given
+// or the unlocked key otherwise, and submits it to the transaction pool.
+func (s *PrivateAccountAPI) sendBatchTransaction(ctx context.Context, args SendTxArgs, passwd *string) (common.Hash, error) {
+	var (
+		signed *types.Transaction
+		err    error
+	)
+	if passwd != nil {
+		signed, err = s.signTransaction(ctx, args, *passwd)
+	} else {
+		account := accounts.Account{Address: args.From}
+		wallet, werr := s.am.Find(account)
+		if werr != nil {
+			return common.Hash{}, werr
+		}
+		if err = checkUnlockScope(s.am, args.From, "personal"); err != nil {
+			return common.Hash{}, err
+		}
+		if err = args.setDefaults(ctx, s.b); err == nil {
+			var chainID *big.Int
+			if config := s.b.ChainConfig(); config.IsEIP155(s.b.CurrentBlock().Number()) {
+				chainID = config.ChainId
+			}
+			signed, err = wallet.SignTx(account, args.toTransaction(), chainID)
+		}
+	}
 	if err != nil {
 		return common.Hash{}, err
 	}
 	return submitTransaction(ctx, s.b, signed)
 }
 
+// LeaseNonces reserves count nonces of an account for the given number of
+// seconds (5 minutes by default), so an application can sign and submit its
+// transactions concurrently without racing for nonces. Nonces given back earlier
+// are handed out first to fill gaps. Nonces that won't be used should be given
+// back with ReleaseNonces, otherwise they are reclaimed when the lease expires.
+func (s *PrivateAccountAPI) LeaseNonces(ctx context.Context, addr AddressArg, count uint64, duration *uint64) ([]hexutil.Uint64, error) {
+	if count == 0 || count > maxNonceLease {
+		return nil, fmt.Errorf("invalid nonce count %d, must be between 1 and %d", count, maxNonceLease)
+	}
+	lifetime := defaultNonceLease
+	if duration != nil {
+		if *duration > uint64(time.Duration(math.MaxInt64)/time.Second) {
+			return nil, errors.New("lease duration too large")
+		}
+		lifetime = time.Duration(*duration) * time.Second
+	}
+	poolNonce, err := s.b.GetPoolNonce(ctx, addr.Address())
+	if err != nil {
+		return nil, err
+	}
+	nonces := s.nonceLock.LeaseNonces(addr.Address(), poolNonce, int(count), lifetime)
+
+	leased := make([]hexutil.Uint64, len(nonces))
+	for i, nonce := range nonces {
+		leased[i] = hexutil.Uint64(nonce)
+	}
+	return leased, nil
+}
+
+// ReleaseNonces gives back leased nonces of an account that won't be used. It
+// returns the number of nonces that were actually leased.
+func (s *PrivateAccountAPI) ReleaseNonces(addr AddressArg, nonces []hexutil.Uint64) int {
+	released := make([]uint64, len(nonces))
+	for i, nonce := range nonces {
+		released[i] = uint64(nonce)
+	}
+	return s.nonceLock.ReleaseNonces(addr.Address(), released)
+}
+
 // SignTransaction will create a transaction from the given arguments and
 // tries to sign it with the key associated with args.To. If the given passwd isn't
 // able to decrypt the key it fails. The transaction is returned in RLP-form, not broadcast
 // to other nodes
 func (s *PrivateAccountAPI) SignTransaction(ctx context.Context, args SendTxArgs, passwd string) (*SignTransactionResult, error) {
+	if s.b.TxApproval().Guarded(args.From) {
+		return nil, errApprovalRequired
+	}
 	// No need to obtain the noncelock mutex, since we won't be sending this
 	// tx into the transaction pool, but right back to the user
 	if args.Gas == nil {
@@ -422,9 +661,9 @@ func signHash(data []byte) []byte {
 // The key used to calculate the signature is decrypted with the given password.
 //
 // https://github.com/ethereum/go-ethereum/wiki/Management-APIs#personal_sign
-func (s *PrivateAccountAPI) Sign(ctx context.Context, data hexutil.Bytes, addr common.Address, passwd string) (hexutil.Bytes, error) {
+func (s *PrivateAccountAPI) Sign(ctx context.Context, data hexutil.Bytes, addr AddressArg, passwd string) (hexutil.Bytes, error) {
 	// Look up the wallet containing the requested signer
-	account := accounts.Account{Address: addr}
+	account := accounts.Account{Address: addr.Address()}
 
 	wallet, err := s.b.AccountManager().Find(account)
 	if err != nil {
@@ -493,12 +732,12 @@ func (s *PublicBlockChainAPI) BlockNumber() *big.Int {
 // GetBalance returns the amount of wei for the given address in the state of the
 // given block number. The rpc.LatestBlockNumber and rpc.PendingBlockNumber meta
 // block numbers are also allowed.
-func (s *PublicBlockChainAPI) GetBalance(ctx context.Context, address common.Address, blockNr rpc.BlockNumber) (*big.Int, error) {
+func (s *PublicBlockChainAPI) GetBalance(ctx context.Context, address AddressArg, blockNr rpc.BlockNumber) (*big.Int, error) {
 	state, _, err := s.b.StateAndHeaderByNumber(ctx, blockNr)
 	if state == nil || err != nil {
 		return nil, err
 	}
-	b := state.GetBalance(address)
+	b := state.GetBalance(address.Address())
 	return b, state.Error()
 }
 
@@ -580,27 +819,95 @@ func (s *PublicBlockChainAPI) GetUncleCountByBlockHash(ctx context.Context, bloc
 }
 
 // GetCode returns the code stored at the given address in the state for the given block number.
-func (s *PublicBlockChainAPI) GetCode(ctx context.Context, address common.Address, blockNr rpc.BlockNumber) (hexutil.Bytes, error) {
+func (s *PublicBlockChainAPI) GetCode(ctx context.Context, address AddressArg, blockNr rpc.BlockNumber) (hexutil.Bytes, error) {
 	state, _, err := s.b.StateAndHeaderByNumber(ctx, blockNr)
 	if state == nil || err != nil {
 		return nil, err
 	}
-	code := state.GetCode(address)
+	code := state.GetCode(address.Address())
 	return code, state.Error()
 }
 
 // GetStorageAt returns the storage from the state at the given address, key and
 // block number. The rpc.LatestBlockNumber and rpc.PendingBlockNumber meta block
 // numbers are also allowed.
-func (s *PublicBlockChainAPI) GetStorageAt(ctx context.Context, address common.Address, key string, blockNr rpc.BlockNumber) (hexutil.Bytes, error) {
+func (s *PublicBlockChainAPI) GetStorageAt(ctx context.Context, address AddressArg, key string, blockNr rpc.BlockNumber) (hexutil.Bytes, error) {
 	state, _, err := s.b.StateAndHeaderByNumber(ctx, blockNr)
 	if state == nil || err != nil {
 		return nil, err
 	}
-	res := state.GetState(address, common.HexToHash(key))
+	res := state.GetState(address.Address(), common.HexToHash(key))
 	return res[:], state.Error()
 }
 
+// AccountResult is the Merkle proof of an account and some of its storage slots
+// against the state root of a block.
+type AccountResult struct {
+	Address      common.Address  `json:"address"`
+	AccountProof []hexutil.Bytes `json:"accountProof"`
+	Balance      *hexutil.Big    `json:"balance"`
+	CodeHash     common.Hash     `json:"codeHash"`
+	Nonce        hexutil.Uint64  `json:"nonce"`
+	StorageHash  common.Hash     `json:"storageHash"`
+	StorageProof []StorageResult `json:"storageProof"`
+}
+
+// StorageResult is the Merkle proof of a storage slot against the storage root
+// of its account.
+type StorageResult struct {
+	Key   common.Hash     `json:"key"`
+	Value *hexutil.Big    `json:"value"`
+	Proof []hexutil.Bytes `json:"proof"`
+}
+
+// GetProof returns the account and storage values of the given address, along
+// with the Merkle proofs of them against the state of the given block number.
+// Missing accounts and slots are proven absent.
+func (s *PublicBlockChainAPI) GetProof(ctx context.Context, address AddressArg, storageKeys []common.Hash, blockNr rpc.BlockNumber) (*AccountResult, error) {
+	state, _, err := s.b.StateAndHeaderByNumber(ctx, blockNr)
+	if state == nil || err != nil {
+		return nil, err
+	}
+	storageHash := types.EmptyRootHash
+	if storageTrie := state.StorageTrie(address.Address()); storageTrie != nil {
+		storageHash = storageTrie.Hash()
+	}
+	storageProof := make([]StorageResult, len(storageKeys))
+	for i, key := range storageKeys {
+		storageProof[i] = StorageResult{Key: key, Value: (*hexutil.Big)(state.GetState(address.Address(), key).Big()), Proof: []hexutil.Bytes{}}
+		if storageHash == types.EmptyRootHash {
+			continue
+		}
+		proof, err := state.GetStorageProof(address.Address(), key)
+		if err != nil {
+			return nil, err
+		}
+		storageProof[i].Proof = toHexSlice(proof)
+	}
+	accountProof, err := state.GetProof(address.Address())
+	if err != nil {
+		return nil, err
+	}
+	return &AccountResult{
+		Address:      address.Address(),
+		AccountProof: toHexSlice(accountProof),
+		Balance:      (*hexutil.Big)(state.GetBalance(address.Address())),
+		CodeHash:     state.GetCodeHash(address.Address()),
+		Nonce:        hexutil.Uint64(state.GetNonce(address.Address())),
+		StorageHash:  storageHash,
+		StorageProof: storageProof,
+	}, state.Error()
+}
+
+// toHexSlice converts the nodes of a Merkle proof to their hex representation.
+func toHexSlice(proof [][]byte) []hexutil.Bytes {
+	nodes := make([]hexutil.Bytes, len(proof))
+	for i, node := range proof {
+		nodes[i] = node
+	}
+	return nodes
+}
+
 // CallArgs represents the arguments for a call.
 type CallArgs struct {
 	From     common.Address  `json:"from"`
@@ -611,13 +918,68 @@ type CallArgs struct {
 	Data     hexutil.Bytes   `json:"data"`
 }
 
-func (s *PublicBlockChainAPI) doCall(ctx context.Context, args CallArgs, blockNr rpc.BlockNumber, vmCfg vm.Config, timeout time.Duration) ([]byte, uint64, bool, error) {
+// OverrideAccount specifies the fields of an account to override during the
+// execution of a message call. State replaces the entire storage of the account,
+// whereas StateDiff only overrides the given slots; the two are exclusive.
+type OverrideAccount struct {
+	Nonce     *hexutil.Uint64              `json:"nonce"`
+	Code      *hexutil.Bytes               `json:"code"`
+	Balance   *hexutil.Big                 `json:"balance"`
+	State     *map[common.Hash]common.Hash `json:"state"`
+	StateDiff *map[common.Hash]common.Hash `json:"stateDiff"`
+}
+
+// StateOverride is the collection of accounts to override, keyed by address.
+type StateOverride map[common.Address]OverrideAccount
+
+// Apply overrides the fields of the specified accounts in the given state.
+func (diff *StateOverride) Apply(statedb *state.StateDB) error {
+	if diff == nil {
+		return nil
+	}
+	for addr, account := range *diff {
+		if account.State != nil && account.StateDiff != nil {
+			return fmt.Errorf("account %s has both 'state' and 'stateDiff'", addr.Hex())
+		}
+		// Replace the storage by recreating the account, keeping everything else
+		if account.State != nil {
+			nonce, code := statedb.GetNonce(addr), statedb.GetCode(addr)
+			statedb.CreateAccount(addr)
+			statedb.SetNonce(addr, nonce)
+			statedb.SetCode(addr, code)
+
+			for key, value := range *account.State {
+				statedb.SetState(addr, key, value)
+			}
+		}
+		if account.StateDiff != nil {
+			for key, value := range *account.StateDiff {
+				statedb.SetState(addr, key, value)
+			}
+		}
+		if account.Nonce != nil {
+			statedb.SetNonce(addr, uint64(*account.Nonce))
+		}
+		if account.Code != nil {
+			statedb.SetCode(addr, *account.Code)
+		}
+		if account.Balance != nil {
+			statedb.SetBalance(addr, (*big.Int)(account.Balance))
+		}
+	}
+	return nil
+}
+
+func (s *PublicBlockChainAPI) doCall(ctx context.Context, args CallArgs, blockNr rpc.BlockNumber, overrides *StateOverride, vmCfg vm.Config, timeout time.Duration) ([]byte, uint64, bool, error) {
 	defer func(start time.Time) { log.Debug("Executing EVM call finished", "runtime", time.Since(start)) }(time.Now())
 
 	state, header, err := s.b.StateAndHeaderByNumber(ctx, blockNr)
 	if state == nil || err != nil {
 		return nil, 0, false, err
 	}
+	if err := overrides.Apply(state); err != nil {
+		return nil, 0, false, err
+	}
 	// Set sender address or use a default if none specified
 	addr := args.From
 	if addr == (common.Address{}) {
@@ -632,6 +994,10 @@ func (s *PublicBlockChainAPI) doCall(ctx context.Context, args CallArgs, blockNr
 	if gas == 0 {
 		gas = math.MaxUint64 / 2
 	}
+	if gasCap := s.b.RPCGasCap(); gasCap != 0 && gas > gasCap {
+		log.Debug("Capping EVM call gas", "requested", gas, "cap", gasCap)
+		gas = gasCap
+	}
 	if gasPrice.Sign() == 0 {
 		gasPrice = new(big.Int).SetUint64(defaultGasPrice)
 	}
@@ -670,19 +1036,58 @@ func (s *PublicBlockChainAPI) doCall(ctx context.Context, args CallArgs, blockNr
 	if err := vmError(); err != nil {
 		return nil, 0, false, err
 	}
+	// Report aborted executions instead of their partial results
+	switch ctx.Err() {
+	case nil:
+	case context.DeadlineExceeded:
+		return nil, 0, false, errExecutionTimeout
+	default:
+		return nil, 0, false, ctx.Err()
+	}
 	return res, gas, failed, err
 }
 
 // Call executes the given transaction on the state for the given block number.
 // It doesn't make and changes in the state/blockchain and is useful to execute and retrieve values.
-func (s *PublicBlockChainAPI) Call(ctx context.Context, args CallArgs, blockNr rpc.BlockNumber) (hexutil.Bytes, error) {
-	result, _, _, err := s.doCall(ctx, args, blockNr, vm.Config{}, 5*time.Second)
+//
+// Accounts of the state may optionally be overridden for the duration of the call.
+func (s *PublicBlockChainAPI) Call(ctx context.Context, args CallArgs, blockNr rpc.BlockNumber, overrides *StateOverride) (hexutil.Bytes, error) {
+	result, _, _, err := s.doCall(ctx, args, blockNr, overrides, vm.Config{}, s.b.RPCEVMTimeout())
 	return (hexutil.Bytes)(result), err
 }
 
+// AccessListResult is the result of simulating a transaction, listing the
+// accounts and storage slots it touches.
+type AccessListResult struct {
+	AccessList types.AccessList `json:"accessList"`
+	GasUsed    hexutil.Uint64   `json:"gasUsed"`
+	Error      string           `json:"error,omitempty"`
+}
+
+// CreateAccessList executes the given transaction on the state for the given
+// block number and returns the accounts and storage slots it accesses, with the
+// accounts of the state optionally overridden. Slots touched by reverted calls
+// are included, as they are needed to reproduce the execution.
+func (s *PublicBlockChainAPI) CreateAccessList(ctx context.Context, args CallArgs, blockNr rpc.BlockNumber, overrides *StateOverride) (*AccessListResult, error) {
+	tracer := vm.NewAccessListTracer()
+	_, gas, failed, err := s.doCall(ctx, args, blockNr, overrides, vm.Config{Debug: true, Tracer: tracer}, s.b.RPCEVMTimeout())
+	if err != nil {
+		return nil, err
+	}
+	result := &AccessListResult{
+		AccessList: tracer.AccessList(),
+		GasUsed:    hexutil.Uint64(gas),
+	}
+	if failed {
+		result.Error = "execution reverted"
+	}
+	return result, nil
+}
+
 // EstimateGas returns an estimate of the amount of gas needed to execute the
-// given transaction against the current pending block.
-func (s *PublicBlockChainAPI) EstimateGas(ctx context.Context, args CallArgs) (hexutil.Uint64, error) {
+// given transaction against the current pending block, with the accounts of the
+// state optionally overridden.
+func (s *PublicBlockChainAPI) EstimateGas(ctx context.Context, args CallArgs, overrides *StateOverride) (hexutil.Uint64, error) {
 	// Binary search the gas requirement, as it may be higher than the amount used
 	var (
 		lo  uint64 = params.TxGas - 1
@@ -699,22 +1104,36 @@ func (s *PublicBlockChainAPI) EstimateGas(ctx context.Context, args CallArgs) (h
 		}
 		hi = block.GasLimit()
 	}
+	if gasCap := s.b.RPCGasCap(); gasCap != 0 && hi > gasCap {
+		hi = gasCap
+	}
 	cap = hi
 
-	// Create a helper to check if a gas allowance results in an executable transaction
-	executable := func(gas uint64) bool {
+	// Create a helper to check if a gas allowance results in an executable transaction,
+	// aborting the search altogether if the execution was interrupted
+	executable := func(gas uint64) (bool, error) {
 		args.Gas = hexutil.Uint64(gas)
 
-		_, _, failed, err := s.doCall(ctx, args, rpc.PendingBlockNumber, vm.Config{}, 0)
+		_, _, failed, err := s.doCall(ctx, args, rpc.PendingBlockNumber, overrides, vm.Config{}, s.b.RPCEVMTimeout())
+		if err == errExecutionTimeout {
+			return false, err
+		}
+		if err := ctx.Err(); err != nil {
+			return false, err
+		}
 		if err != nil || failed {
-			return false
+			return false, nil
 		}
-		return true
+		return true, nil
 	}
 	// Execute the binary search and hone in on an executable gas limit
 	for lo+1 < hi {
 		mid := (hi + lo) / 2
-		if !executable(mid) {
+		ok, err := executable(mid)
+		if err != nil {
+			return 0, err
+		}
+		if !ok {
 			lo = mid
 		} else {
 			hi = mid
@@ -722,7 +1141,11 @@ func (s *PublicBlockChainAPI) EstimateGas(ctx context.Context, args CallArgs) (h
 	}
 	// Reject the transaction as invalid if it still fails at the highest allowance
 	if hi == cap {
-		if !executable(hi) {
+		ok, err := executable(hi)
+		if err != nil {
+			return 0, err
+		}
+		if !ok {
 			return 0, fmt.Errorf("gas required exceeds allowance or always failing transaction")
 		}
 	}
@@ -993,12 +1416,12 @@ func (s *PublicTransactionPoolAPI) GetRawTransactionByBlockHashAndIndex(ctx cont
 }
 
 // GetTransactionCount returns the number of transactions the given address has sent for the given block number
-func (s *PublicTransactionPoolAPI) GetTransactionCount(ctx context.Context, address common.Address, blockNr rpc.BlockNumber) (*hexutil.Uint64, error) {
+func (s *PublicTransactionPoolAPI) GetTransactionCount(ctx context.Context, address AddressArg, blockNr rpc.BlockNumber) (*hexutil.Uint64, error) {
 	state, _, err := s.b.StateAndHeaderByNumber(ctx, blockNr)
 	if state == nil || err != nil {
 		return nil, err
 	}
-	nonce := state.GetNonce(address)
+	nonce := state.GetNonce(address.Address())
 	return (*hexutil.Uint64)(&nonce), state.Error()
 }
 
@@ -1016,6 +1439,65 @@ func (s *PublicTransactionPoolAPI) GetTransactionByHash(ctx context.Context, has
 	return nil
 }
 
+// addressTxPageSize is the number of transactions returned per page by
+// eth_getTransactionsByAddress.
+const addressTxPageSize = 100
+
+// AddressTransactions is a page of the transactions of an account.
+type AddressTransactions struct {
+	Transactions  []*RPCTransaction `json:"transactions"`
+	NextPageToken *hexutil.Bytes    `json:"nextPageToken"`
+}
+
+// GetTransactionsByAddress returns the transactions sent by, sent to or creating
+// the given account in the blocks fromBlock..toBlock (the whole chain by default)
+// in chain order. Results are paged, each page carrying the token to request the
+// next one with until all transactions were returned. Requires the address index.
+func (s *PublicTransactionPoolAPI) GetTransactionsByAddress(ctx context.Context, address AddressArg, fromBlock, toBlock *rpc.BlockNumber, pageToken *hexutil.Bytes) (*AddressTransactions, error) {
+	head := s.b.CurrentBlock().NumberU64()
+
+	from, to := uint64(0), head
+	if fromBlock != nil && *fromBlock >= 0 {
+		from = uint64(*fromBlock)
+	} else if fromBlock != nil {
+		from = head
+	}
+	if toBlock != nil && *toBlock >= 0 {
+		to = uint64(*toBlock)
+	}
+	// Resume from the transaction the token points to
+	var skip uint64
+	if pageToken != nil {
+		if len(*pageToken) != 16 {
+			return nil, errors.New("invalid page token")
+		}
+		from, skip = binary.BigEndian.Uint64((*pageToken)[:8]), binary.BigEndian.Uint64((*pageToken)[8:])
+	}
+	entries, err := s.b.AddressTransactions(ctx, address.Address(), from, to, addressTxPageSize+int(skip)+1)
+	if err != nil {
+		return nil, err
+	}
+	page := &AddressTransactions{Transactions: []*RPCTransaction{}}
+	for _, entry := range entries {
+		if entry.BlockNumber == from && entry.TxIndex < skip {
+			continue
+		}
+		if len(page.Transactions) == addressTxPageSize {
+			token := make(hexutil.Bytes, 16)
+			binary.BigEndian.PutUint64(token[:8], entry.BlockNumber)
+			binary.BigEndian.PutUint64(token[8:], entry.TxIndex)
+			page.NextPageToken = &token
+			break
+		}
+		tx, blockHash, blockNumber, index := core.GetTransaction(s.b.ChainDb(), entry.TxHash)
+		if tx == nil {
+			return nil, fmt.Errorf("transaction %x not found", entry.TxHash)
+		}
+		page.Transactions = append(page.Transactions, newRPCTransaction(tx, blockHash, blockNumber, index))
+	}
+	return page, nil
+}
+
 // GetRawTransactionByHash returns the bytes of the transaction for the given hash.
 func (s *PublicTransactionPoolAPI) GetRawTransactionByHash(ctx context.Context, hash common.Hash) (hexutil.Bytes, error) {
 	var tx *types.Transaction
@@ -1079,9 +1561,29 @@ func (s *PublicTransactionPoolAPI) GetTransactionReceipt(ctx context.Context, ha
 	if receipt.ContractAddress != (common.Address{}) {
 		fields["contractAddress"] = receipt.ContractAddress
 	}
+	// Attach the consensus metadata of the block, if the engine has any
+	if header, err := s.b.HeaderByNumber(ctx, rpc.BlockNumber(blockNumber)); err == nil && header != nil && header.Hash() == blockHash {
+		addCommitFields(s.b, header, fields)
+	}
 	return fields, nil
 }
 
+// addCommitFields extends the RPC representation of a receipt with the finality
+// status of its block, and the proposer and round it was committed by if the
+// consensus engine can tell.
+func addCommitFields(b Backend, header *types.Header, fields map[string]interface{}) {
+	engine := b.Engine()
+	if verifier, ok := engine.(consensus.FinalityVerifier); ok {
+		fields["finalized"] = verifier.VerifyFinality(b.ChainReader(), header) == nil
+	}
+	if inspector, ok := engine.(consensus.CommitInspector); ok {
+		if proposer, round, err := inspector.CommitInfo(b.ChainReader(), header); err == nil {
+			fields["proposer"] = proposer
+			fields["round"] = hexutil.Uint64(round)
+		}
+	}
+}
+
 // sign is a helper function that signs a transaction with the private key of the given address.
 func (s *PublicTransactionPoolAPI) sign(addr common.Address, tx *types.Transaction) (*types.Transaction, error) {
 	// Look up the wallet containing the requested signer
@@ -1091,6 +1593,9 @@ func (s *PublicTransactionPoolAPI) sign(addr common.Address, tx *types.Transacti
 	if err != nil {
 		return nil, err
 	}
+	if err := checkUnlockScope(s.b.AccountManager(), addr, "eth"); err != nil {
+		return nil, err
+	}
 	// Request the wallet to sign the transaction
 	var chainID *big.Int
 	if config := s.b.ChainConfig(); config.IsEIP155(s.b.CurrentBlock().Number()) {
@@ -1189,7 +1694,9 @@ func submitTransaction(ctx context.Context, b Backend, tx *types.Transaction) (c
 // SendTransaction creates a transaction for the given argument, sign it and submit it to the
 // transaction pool.
 func (s *PublicTransactionPoolAPI) SendTransaction(ctx context.Context, args SendTxArgs) (common.Hash, error) {
-
+	if s.b.TxApproval().Guarded(args.From) {
+		return common.Hash{}, errApprovalRequired
+	}
 	// Look up the wallet containing the requested signer
 	account := accounts.Account{Address: args.From}
 
@@ -1197,16 +1704,25 @@ func (s *PublicTransactionPoolAPI) SendTransaction(ctx context.Context, args Sen
 	if err != nil {
 		return common.Hash{}, err
 	}
+	if err := checkUnlockScope(s.b.AccountManager(), args.From, "eth"); err != nil {
+		return common.Hash{}, err
+	}
 
-	if args.Nonce == nil {
-		// Hold the addresse's mutex around signing to prevent concurrent assignment of
-		// the same nonce to multiple accounts.
-		s.nonceLock.LockAddr(args.From)
-		defer s.nonceLock.UnlockAddr(args.From)
+	leased := args.Nonce == nil
+	if leased {
+		// Reserve the nonce to prevent its concurrent assignment to other
+		// transactions of the account.
+		nonce, err := s.nonceLock.reserveNonce(ctx, s.b, args.From)
+		if err != nil {
+			return common.Hash{}, err
+		}
+		args.Nonce = (*hexutil.Uint64)(&nonce)
 	}
+	nonce := uint64(*args.Nonce)
 
 	// Set some sanity defaults and terminate on failure
 	if err := args.setDefaults(ctx, s.b); err != nil {
+		s.nonceLock.settleNonce(args.From, nonce, leased, err)
 		return common.Hash{}, err
 	}
 	// Assemble the transaction and sign with the wallet
@@ -1218,9 +1734,12 @@ func (s *PublicTransactionPoolAPI) SendTransaction(ctx context.Context, args Sen
 	}
 	signed, err := wallet.SignTx(account, tx, chainID)
 	if err != nil {
+		s.nonceLock.settleNonce(args.From, nonce, leased, err)
 		return common.Hash{}, err
 	}
-	return submitTransaction(ctx, s.b, signed)
+	hash, err := submitTransaction(ctx, s.b, signed)
+	s.nonceLock.settleNonce(args.From, nonce, leased, err)
+	return hash, err
 }
 
 // SendRawTransaction will add the signed transaction to the transaction pool.
@@ -1230,7 +1749,16 @@ func (s *PublicTransactionPoolAPI) SendRawTransaction(ctx context.Context, encod
 	if err := rlp.DecodeBytes(encodedTx, tx); err != nil {
 		return common.Hash{}, err
 	}
-	return submitTransaction(ctx, s.b, tx)
+	hash, err := submitTransaction(ctx, s.b, tx)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	// End the lease of the nonce if it was reserved by the sender
+	signer := types.MakeSigner(s.b.ChainConfig(), s.b.CurrentBlock().Number())
+	if from, err := types.Sender(signer, tx); err == nil {
+		s.nonceLock.UseNonce(from, tx.Nonce())
+	}
+	return hash, nil
 }
 
 // Sign calculates an ECDSA signature for:
@@ -1242,14 +1770,17 @@ func (s *PublicTransactionPoolAPI) SendRawTransaction(ctx context.Context, encod
 // The account associated with addr must be unlocked.
 //
 // https://github.com/ethereum/wiki/wiki/JSON-RPC#eth_sign
-func (s *PublicTransactionPoolAPI) Sign(addr common.Address, data hexutil.Bytes) (hexutil.Bytes, error) {
+func (s *PublicTransactionPoolAPI) Sign(addr AddressArg, data hexutil.Bytes) (hexutil.Bytes, error) {
 	// Look up the wallet containing the requested signer
-	account := accounts.Account{Address: addr}
+	account := accounts.Account{Address: addr.Address()}
 
 	wallet, err := s.b.AccountManager().Find(account)
 	if err != nil {
 		return nil, err
 	}
+	if err := checkUnlockScope(s.b.AccountManager(), addr.Address(), "eth"); err != nil {
+		return nil, err
+	}
 	// Sign the requested hash with the wallet
 	signature, err := wallet.SignHash(account, signHash(data))
 	if err == nil {
@@ -1268,6 +1799,9 @@ type SignTransactionResult struct {
 // The node needs to have the private key of the account corresponding with
 // the given from address and it needs to be unlocked.
 func (s *PublicTransactionPoolAPI) SignTransaction(ctx context.Context, args SendTxArgs) (*SignTransactionResult, error) {
+	if s.b.TxApproval().Guarded(args.From) {
+		return nil, errApprovalRequired
+	}
 	if args.Gas == nil {
 		return nil, fmt.Errorf("gas not specified")
 	}
@@ -1446,9 +1980,11 @@ func (api *PrivateDebugAPI) ChaindbCompact() error {
 	return nil
 }
 
-// SetHead rewinds the head of the blockchain to a previous block.
-func (api *PrivateDebugAPI) SetHead(number hexutil.Uint64) {
-	api.b.SetHead(uint64(number))
+// SetHead rewinds the head of the blockchain to a previous block. Dropping blocks
+// which are provably final must be explicitly allowed by unsafe, as it reverts
+// transactions the whole network considers settled.
+func (api *PrivateDebugAPI) SetHead(number hexutil.Uint64, unsafe *bool) error {
+	return api.b.SetHead(uint64(number), unsafe != nil && *unsafe)
 }
 
 // PublicNetAPI offers network related RPC methods
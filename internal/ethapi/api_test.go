@@ -0,0 +1,488 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethapi
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/eth/indexer"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// testBackend executes calls on the head state of a local chain, and collects the
+// transactions submitted to it.
+type testBackend struct {
+	Backend
+	db         ethdb.Database
+	chain      *core.BlockChain
+	am         *accounts.Manager
+	sent       []*types.Transaction
+	gasCap     uint64
+	evmTimeout time.Duration
+}
+
+// newTestBackend creates a backend over a chain containing only the genesis
+// block with the given allocation.
+func newTestBackend(t *testing.T, alloc core.GenesisAlloc) *testBackend {
+	db, _ := ethdb.NewMemDatabase()
+	gspec := &core.Genesis{Config: params.TestChainConfig, Alloc: alloc}
+	gspec.MustCommit(db)
+
+	chain, err := core.NewBlockChain(db, nil, gspec.Config, ethash.NewFaker(), vm.Config{})
+	if err != nil {
+		t.Fatalf("failed to create blockchain: %v", err)
+	}
+	return &testBackend{db: db, chain: chain, am: accounts.NewManager()}
+}
+
+func (b *testBackend) StateAndHeaderByNumber(ctx context.Context, number rpc.BlockNumber) (*state.StateDB, *types.Header, error) {
+	statedb, err := b.chain.State()
+	return statedb, b.chain.CurrentBlock().Header(), err
+}
+
+func (b *testBackend) GetEVM(ctx context.Context, msg core.Message, state *state.StateDB, header *types.Header, vmCfg vm.Config) (*vm.EVM, func() error, error) {
+	state.SetBalance(msg.From(), math.MaxBig256)
+
+	context := core.NewEVMContext(msg, header, b.chain, nil)
+	return vm.NewEVM(context, state, b.chain.Config(), vmCfg), func() error { return nil }, nil
+}
+
+func (b *testBackend) SendTx(ctx context.Context, tx *types.Transaction) error {
+	b.sent = append(b.sent, tx)
+	return nil
+}
+
+func (b *testBackend) GetPoolNonce(ctx context.Context, addr common.Address) (uint64, error) {
+	return 0, nil
+}
+
+// AddressTransactions emulates the address index by scanning the canonical
+// blocks for the transactions sent by or to the account.
+func (b *testBackend) AddressTransactions(ctx context.Context, addr common.Address, from, to uint64, limit int) ([]*indexer.AddressTx, error) {
+	var txs []*indexer.AddressTx
+	for number := from; number <= to && len(txs) < limit; number++ {
+		block := b.chain.GetBlockByNumber(number)
+		if block == nil {
+			break
+		}
+		signer := types.MakeSigner(b.chain.Config(), block.Number())
+		for i, tx := range block.Transactions() {
+			if sender, _ := types.Sender(signer, tx); sender != addr && (tx.To() == nil || *tx.To() != addr) {
+				continue
+			}
+			if len(txs) < limit {
+				txs = append(txs, &indexer.AddressTx{BlockNumber: number, BlockHash: block.Hash(), TxHash: tx.Hash(), TxIndex: uint64(i)})
+			}
+		}
+	}
+	return txs, nil
+}
+
+func (b *testBackend) SuggestPrice(ctx context.Context) (*big.Int, error) { return big.NewInt(1), nil }
+func (b *testBackend) ChainDb() ethdb.Database                            { return b.db }
+func (b *testBackend) AccountManager() *accounts.Manager                  { return b.am }
+func (b *testBackend) TxApproval() *ApprovalConfig                        { return new(ApprovalConfig) }
+func (b *testBackend) ChainConfig() *params.ChainConfig                   { return b.chain.Config() }
+func (b *testBackend) CurrentBlock() *types.Block                         { return b.chain.CurrentBlock() }
+func (b *testBackend) RPCGasCap() uint64                                  { return b.gasCap }
+func (b *testBackend) RPCEVMTimeout() time.Duration                       { return b.evmTimeout }
+
+var (
+	// returnBalanceCode returns the balance of the executing contract
+	returnBalanceCode = hexutil.MustDecode("0x303160005260206000f3")
+
+	// returnStorageCode returns the values of the storage slots 0 and 1
+	returnStorageCode = hexutil.MustDecode("0x60005460005260015460205260406000f3")
+
+	// requireStorageCode reverts unless storage slot 0 is set
+	requireStorageCode = hexutil.MustDecode("0x600054600a57600080fd5b00")
+
+	// returnGasCode returns the gas left to the call
+	returnGasCode = hexutil.MustDecode("0x5a60005260206000f3")
+
+	// loopCode loops until running out of gas
+	loopCode = hexutil.MustDecode("0x5b600056")
+)
+
+// Tests that calls exceeding the execution time limit of the node are aborted
+// with a timeout error instead of returning partial results.
+func TestCallTimeout(t *testing.T) {
+	loop := common.HexToAddress("0x100")
+	backend := newTestBackend(t, core.GenesisAlloc{loop: {Balance: new(big.Int), Code: loopCode}})
+	defer backend.chain.Stop()
+	backend.evmTimeout = 100 * time.Millisecond
+
+	api := NewPublicBlockChainAPI(backend)
+
+	start := time.Now()
+	if _, err := api.Call(context.Background(), CallArgs{To: &loop}, rpc.LatestBlockNumber, nil); err != errExecutionTimeout {
+		t.Fatalf("error mismatch: have %v, want %v", err, errExecutionTimeout)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("call not aborted at the node limit: took %v", elapsed)
+	}
+	if _, err := api.EstimateGas(context.Background(), CallArgs{To: &loop, Gas: 1 << 40}, nil); err != errExecutionTimeout {
+		t.Errorf("estimation error mismatch: have %v, want %v", err, errExecutionTimeout)
+	}
+}
+
+// Tests that the gas of calls is capped by the node's gas cap.
+func TestCallGasCap(t *testing.T) {
+	contract := common.HexToAddress("0x100")
+	backend := newTestBackend(t, core.GenesisAlloc{contract: {Balance: new(big.Int), Code: returnGasCode}})
+	defer backend.chain.Stop()
+	backend.gasCap = 100000
+
+	api := NewPublicBlockChainAPI(backend)
+
+	for _, gas := range []hexutil.Uint64{0, 50000000} {
+		res, err := api.Call(context.Background(), CallArgs{To: &contract, Gas: gas}, rpc.LatestBlockNumber, nil)
+		if err != nil {
+			t.Fatalf("gas %d: call failed: %v", gas, err)
+		}
+		// The intrinsic gas and the GAS opcode are charged before returning
+		if have, want := new(big.Int).SetBytes(res).Uint64(), backend.gasCap-params.TxGas-2; have != want {
+			t.Errorf("gas %d: gas left mismatch: have %d, want %d", gas, have, want)
+		}
+	}
+}
+
+// Tests that the balance, code and storage of accounts can be overridden for
+// the duration of a call.
+func TestCallStateOverride(t *testing.T) {
+	var (
+		contract = common.HexToAddress("0xc0de")
+		empty    = common.HexToAddress("0xe0")
+		one      = common.BigToHash(big.NewInt(1))
+		two      = common.BigToHash(big.NewInt(2))
+		three    = common.BigToHash(big.NewInt(3))
+	)
+	backend := newTestBackend(t, core.GenesisAlloc{
+		contract: {
+			Balance: big.NewInt(7),
+			Code:    returnStorageCode,
+			Storage: map[common.Hash]common.Hash{{}: one, one: two},
+		},
+	})
+	defer backend.chain.Stop()
+	api := NewPublicBlockChainAPI(backend)
+
+	code := func(code []byte) *hexutil.Bytes { return (*hexutil.Bytes)(&code) }
+	storage := func(slots map[common.Hash]common.Hash) *map[common.Hash]common.Hash { return &slots }
+
+	tests := []struct {
+		to        common.Address
+		overrides *StateOverride
+		want      []byte
+		fail      bool
+	}{
+		// No overrides, executing the genesis state
+		{to: contract, want: append(one.Bytes(), two.Bytes()...)},
+		// Code override of an empty account
+		{
+			to:        empty,
+			overrides: &StateOverride{empty: {Code: code(returnBalanceCode)}},
+			want:      common.Hash{}.Bytes(),
+		},
+		// Balance and code override of an existing account
+		{
+			to:        contract,
+			overrides: &StateOverride{contract: {Code: code(returnBalanceCode), Balance: (*hexutil.Big)(big.NewInt(1000))}},
+			want:      common.BigToHash(big.NewInt(1000)).Bytes(),
+		},
+		// Storage diff only overrides the given slots
+		{
+			to:        contract,
+			overrides: &StateOverride{contract: {StateDiff: storage(map[common.Hash]common.Hash{{}: three})}},
+			want:      append(three.Bytes(), two.Bytes()...),
+		},
+		// Storage replacement clears all other slots
+		{
+			to:        contract,
+			overrides: &StateOverride{contract: {State: storage(map[common.Hash]common.Hash{{}: three})}},
+			want:      append(three.Bytes(), common.Hash{}.Bytes()...),
+		},
+		// Storage replacement and diff are exclusive
+		{
+			to:        contract,
+			overrides: &StateOverride{contract: {State: storage(nil), StateDiff: storage(nil)}},
+			fail:      true,
+		},
+	}
+	for i, tt := range tests {
+		to := tt.to
+		result, err := api.Call(context.Background(), CallArgs{To: &to}, rpc.LatestBlockNumber, tt.overrides)
+		if tt.fail {
+			if err == nil {
+				t.Errorf("test %d: call succeeded, want error", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("test %d: call failed: %v", i, err)
+			continue
+		}
+		if !bytes.Equal(result, tt.want) {
+			t.Errorf("test %d: result mismatch: have %x, want %x", i, result, tt.want)
+		}
+	}
+	// Overrides must not leak into the chain state
+	statedb, _ := backend.chain.State()
+	if balance := statedb.GetBalance(contract); balance.Cmp(big.NewInt(7)) != 0 {
+		t.Errorf("overridden balance persisted: have %v, want 7", balance)
+	}
+	if len(statedb.GetCode(empty)) != 0 {
+		t.Errorf("overridden code persisted")
+	}
+}
+
+// Tests that gas estimation executes on the overridden state.
+func TestEstimateGasStateOverride(t *testing.T) {
+	contract := common.HexToAddress("0xc0de")
+
+	backend := newTestBackend(t, core.GenesisAlloc{contract: {Balance: new(big.Int), Code: requireStorageCode}})
+	defer backend.chain.Stop()
+	api := NewPublicBlockChainAPI(backend)
+
+	args := CallArgs{To: &contract, Gas: 100000}
+	if _, err := api.EstimateGas(context.Background(), args, nil); err == nil {
+		t.Fatalf("estimation of always failing call succeeded")
+	}
+	overrides := &StateOverride{contract: {StateDiff: &map[common.Hash]common.Hash{{}: common.BigToHash(big.NewInt(1))}}}
+	gas, err := api.EstimateGas(context.Background(), args, overrides)
+	if err != nil {
+		t.Fatalf("estimation with overridden storage failed: %v", err)
+	}
+	if uint64(gas) <= params.TxGas || uint64(gas) >= uint64(args.Gas) {
+		t.Errorf("estimated gas out of range: have %d, want within (%d, %d)", gas, params.TxGas, args.Gas)
+	}
+}
+
+// Tests that a batch of transactions gets consecutive nonces, failed transactions
+// passing their nonce on to the next one, and that the nonces skipped by explicit
+// ones are handed out first afterwards.
+func TestSendTransactionBatch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("failed to create temporary keystore directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	ks := keystore.NewKeyStore(dir, keystore.LightScryptN, keystore.LightScryptP)
+	account, err := ks.NewAccount("secret")
+	if err != nil {
+		t.Fatalf("failed to create account: %v", err)
+	}
+	backend := newTestBackend(t, nil)
+	defer backend.chain.Stop()
+	backend.am = accounts.NewManager(ks)
+
+	api := NewPrivateAccountAPI(backend, new(AddrLocker))
+
+	var (
+		to       = common.HexToAddress("0x01")
+		explicit = hexutil.Uint64(7)
+		passwd   = "secret"
+	)
+	batch := []SendTxArgs{
+		{From: account.Address, To: &to},
+		{From: account.Address}, // contract creation without code, rejected
+		{From: account.Address, To: &to},
+		{From: account.Address, To: &to, Nonce: &explicit},
+	}
+	results, err := api.SendTransactionBatch(context.Background(), batch, &passwd)
+	if err != nil {
+		t.Fatalf("failed to send batch: %v", err)
+	}
+	for i, want := range []uint64{0, 1, 1, 7} {
+		if uint64(results[i].Nonce) != want {
+			t.Errorf("result %d: nonce mismatch: have %d, want %d", i, results[i].Nonce, want)
+		}
+		if failed := results[i].Error != ""; failed != (i == 1) {
+			t.Errorf("result %d: failure mismatch: have %q", i, results[i].Error)
+		}
+	}
+	if len(backend.sent) != 3 {
+		t.Fatalf("submitted transaction count mismatch: have %d, want 3", len(backend.sent))
+	}
+	for i, tx := range backend.sent {
+		if tx.Hash() != results[[]int{0, 2, 3}[i]].Hash {
+			t.Errorf("transaction %d: hash mismatch with results", i)
+		}
+	}
+	// The nonces below the explicit one are leased next, filling the gap
+	nonces, err := api.LeaseNonces(context.Background(), AddressArg(account.Address), 6, nil)
+	if err != nil {
+		t.Fatalf("failed to lease nonces: %v", err)
+	}
+	if want := []hexutil.Uint64{2, 3, 4, 5, 6, 8}; !reflect.DeepEqual(nonces, want) {
+		t.Errorf("leased nonces mismatch: have %v, want %v", nonces, want)
+	}
+	if n := api.ReleaseNonces(AddressArg(account.Address), nonces); n != len(nonces) {
+		t.Errorf("released nonce count mismatch: have %d, want %d", n, len(nonces))
+	}
+}
+
+// Tests that the transactions of an account are returned in chain order within
+// the requested blocks, paged by the returned tokens.
+func TestGetTransactionsByAddress(t *testing.T) {
+	var (
+		key, _    = crypto.GenerateKey()
+		sender    = crypto.PubkeyToAddress(key.PublicKey)
+		recipient = common.HexToAddress("0x01")
+		other     = common.HexToAddress("0x02")
+		alloc     = core.GenesisAlloc{sender: {Balance: big.NewInt(params.Ether)}}
+	)
+	backend := newTestBackend(t, alloc)
+	defer backend.chain.Stop()
+
+	// Create a chain of four blocks, every other transaction paying the recipient
+	gendb, _ := ethdb.NewMemDatabase()
+	genesis := (&core.Genesis{Config: params.TestChainConfig, Alloc: alloc}).MustCommit(gendb)
+	signer := types.HomesteadSigner{}
+
+	blocks, _ := core.GenerateChain(params.TestChainConfig, genesis, ethash.NewFaker(), gendb, 4, func(i int, gen *core.BlockGen) {
+		for j := 0; j < 60; j++ {
+			to := recipient
+			if j%2 == 1 {
+				to = other
+			}
+			tx, _ := types.SignTx(types.NewTransaction(gen.TxNonce(sender), to, big.NewInt(1), params.TxGas, new(big.Int), nil), signer, key)
+			gen.AddTx(tx)
+		}
+	})
+	if _, err := backend.chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	var want []common.Hash
+	for _, block := range blocks {
+		for _, tx := range block.Transactions() {
+			if *tx.To() == recipient {
+				want = append(want, tx.Hash())
+			}
+		}
+	}
+	api := NewPublicTransactionPoolAPI(backend, new(AddrLocker))
+
+	// Page through all the transactions of the recipient
+	first, err := api.GetTransactionsByAddress(context.Background(), AddressArg(recipient), nil, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to retrieve first page: %v", err)
+	}
+	if len(first.Transactions) != addressTxPageSize || first.NextPageToken == nil {
+		t.Fatalf("first page mismatch: have %d transactions, token %v", len(first.Transactions), first.NextPageToken)
+	}
+	second, err := api.GetTransactionsByAddress(context.Background(), AddressArg(recipient), nil, nil, first.NextPageToken)
+	if err != nil {
+		t.Fatalf("failed to retrieve second page: %v", err)
+	}
+	if second.NextPageToken != nil {
+		t.Errorf("last page carries a token: %x", *second.NextPageToken)
+	}
+	have := append(first.Transactions, second.Transactions...)
+	if len(have) != len(want) {
+		t.Fatalf("transaction count mismatch: have %d, want %d", len(have), len(want))
+	}
+	for i, tx := range have {
+		if tx.Hash != want[i] {
+			t.Errorf("transaction %d: hash mismatch: have %x, want %x", i, tx.Hash, want[i])
+		}
+	}
+	// Check the block range and the rejection of malformed tokens
+	from, to := rpc.BlockNumber(2), rpc.BlockNumber(3)
+	ranged, err := api.GetTransactionsByAddress(context.Background(), AddressArg(recipient), &from, &to, nil)
+	if err != nil {
+		t.Fatalf("failed to retrieve ranged transactions: %v", err)
+	}
+	if len(ranged.Transactions) != 60 || ranged.Transactions[0].Hash != want[30] || ranged.NextPageToken != nil {
+		t.Errorf("ranged transactions mismatch: have %d transactions, token %v", len(ranged.Transactions), ranged.NextPageToken)
+	}
+	token := hexutil.Bytes{0x01}
+	if _, err := api.GetTransactionsByAddress(context.Background(), AddressArg(recipient), nil, nil, &token); err == nil {
+		t.Errorf("malformed page token accepted")
+	}
+}
+
+// testWallet is a wallet tracking whether it is currently opened.
+type testWallet struct {
+	accounts.Wallet
+	url    accounts.URL
+	opened bool
+}
+
+func (w *testWallet) URL() accounts.URL            { return w.url }
+func (w *testWallet) Open(passphrase string) error { w.opened = true; return nil }
+func (w *testWallet) Close() error                 { w.opened = false; return nil }
+
+// testWalletBackend is an account backend serving a fixed set of wallets.
+type testWalletBackend struct {
+	wallets []accounts.Wallet
+}
+
+func (b *testWalletBackend) Wallets() []accounts.Wallet { return b.wallets }
+
+func (b *testWalletBackend) Subscribe(sink chan<- accounts.WalletEvent) event.Subscription {
+	return event.NewSubscription(func(quit <-chan struct{}) error {
+		<-quit
+		return nil
+	})
+}
+
+// Tests that wallets can be closed by URL, and that unknown URLs are rejected.
+func TestCloseWallet(t *testing.T) {
+	wallet := &testWallet{url: accounts.URL{Scheme: "test", Path: "wallet"}}
+
+	backend := &testBackend{am: accounts.NewManager(&testWalletBackend{wallets: []accounts.Wallet{wallet}})}
+	api := NewPrivateAccountAPI(backend, new(AddrLocker))
+
+	if err := api.CloseWallet("test://unknown"); err != accounts.ErrUnknownWallet {
+		t.Fatalf("unknown wallet error mismatch: have %v, want %v", err, accounts.ErrUnknownWallet)
+	}
+	if err := api.OpenWallet("test://wallet", nil); err != nil {
+		t.Fatalf("failed to open wallet: %v", err)
+	}
+	if !wallet.opened {
+		t.Fatalf("wallet not opened")
+	}
+	if err := api.CloseWallet("test://wallet"); err != nil {
+		t.Fatalf("failed to close wallet: %v", err)
+	}
+	if wallet.opened {
+		t.Errorf("wallet still open after close")
+	}
+}
@@ -0,0 +1,296 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethapi
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// maxProposals is the maximum number of transaction proposals awaiting approval.
+const maxProposals = 256
+
+var (
+	errApprovalRequired = errors.New("transactions of the account require approval, use personal_proposeTransaction")
+	errNoApproval       = errors.New("account not guarded by transaction approval")
+	errUnknownProposal  = errors.New("unknown transaction proposal")
+	errNotApprover      = errors.New("signer is not a transaction approver")
+	errAlreadyApproved  = errors.New("transaction already approved by signer")
+	errNotApproved      = errors.New("transaction not approved yet")
+	errTooManyProposals = errors.New("too many pending transaction proposals")
+)
+
+// ApprovalConfig contains the settings of the multi-signature approval of the
+// transactions of guarded accounts, e.g. treasuries. Transactions of guarded
+// accounts are only signed by the node once enough approvers signed off them.
+type ApprovalConfig struct {
+	Accounts  []common.Address `toml:",omitempty"` // Accounts whose transactions require approval
+	Approvers []common.Address `toml:",omitempty"` // Keys allowed to approve transactions
+	Threshold int              `toml:",omitempty"` // Approvals required to send a transaction
+}
+
+// Validate checks that the approval threshold can be reached by the approvers.
+func (c *ApprovalConfig) Validate() error {
+	if len(c.Accounts) == 0 {
+		return nil
+	}
+	if c.Threshold <= 0 || c.Threshold > len(c.Approvers) {
+		return fmt.Errorf("invalid approval threshold %d of %d approvers", c.Threshold, len(c.Approvers))
+	}
+	return nil
+}
+
+// Guarded returns whether the transactions of an account require approval.
+func (c *ApprovalConfig) Guarded(addr common.Address) bool {
+	for _, account := range c.Accounts {
+		if account == addr {
+			return true
+		}
+	}
+	return false
+}
+
+// approver returns whether a key is allowed to approve transactions.
+func (c *ApprovalConfig) approver(addr common.Address) bool {
+	for _, approver := range c.Approvers {
+		if approver == addr {
+			return true
+		}
+	}
+	return false
+}
+
+// Proposal is a transaction of a guarded account awaiting approval. Approvers
+// sign off the proposal by signing its ID.
+type Proposal struct {
+	ID        common.Hash      `json:"id"`
+	Tx        SendTxArgs       `json:"tx"`
+	Proposed  time.Time        `json:"proposed"`
+	Approvals []common.Address `json:"approvals"`
+}
+
+// approvalQueue keeps the transaction proposals of the guarded accounts in
+// memory until they are approved and sent. Proposals are lost on restart.
+type approvalQueue struct {
+	config    *ApprovalConfig
+	proposals map[common.Hash]*Proposal
+	salt      [32]byte // Random salt, keeping the IDs of proposals apart across restarts
+	seq       uint64   // Proposal counter, keeping the IDs of identical transactions apart
+	lock      sync.Mutex
+}
+
+// newApprovalQueue creates an empty queue of transaction proposals.
+func newApprovalQueue(config *ApprovalConfig) *approvalQueue {
+	q := &approvalQueue{
+		config:    config,
+		proposals: make(map[common.Hash]*Proposal),
+	}
+	rand.Read(q.salt[:])
+	return q
+}
+
+// propose queues a transaction of a guarded account for approval.
+func (q *approvalQueue) propose(args SendTxArgs) (common.Hash, error) {
+	if !q.config.Guarded(args.From) {
+		return common.Hash{}, errNoApproval
+	}
+	blob, err := json.Marshal(args)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	if len(q.proposals) >= maxProposals {
+		return common.Hash{}, errTooManyProposals
+	}
+	seq := make([]byte, 8)
+	binary.BigEndian.PutUint64(seq, q.seq)
+	q.seq++
+
+	// Without the salt, approval signatures of a proposal would also approve the
+	// identical proposal of the same position after a restart
+	id := crypto.Keccak256Hash(q.salt[:], blob, seq)
+	q.proposals[id] = &Proposal{ID: id, Tx: args, Proposed: time.Now(), Approvals: []common.Address{}}
+	return id, nil
+}
+
+// approve records the approval of a proposal. Once approved by enough approvers,
+// the proposal is removed from the queue and returned for sending, ensuring it
+// is only sent once. Proposals failing to send must be restored.
+func (q *approvalQueue) approve(id common.Hash, approver common.Address) (*Proposal, error) {
+	if !q.config.approver(approver) {
+		return nil, errNotApprover
+	}
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	proposal, ok := q.proposals[id]
+	if !ok {
+		return nil, errUnknownProposal
+	}
+	for _, addr := range proposal.Approvals {
+		if addr == approver {
+			return nil, errAlreadyApproved
+		}
+	}
+	proposal.Approvals = append(proposal.Approvals, approver)
+	if len(proposal.Approvals) < q.config.Threshold {
+		return nil, nil
+	}
+	delete(q.proposals, id)
+	return proposal, nil
+}
+
+// take removes an approved proposal from the queue for sending.
+func (q *approvalQueue) take(id common.Hash) (*Proposal, error) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	proposal, ok := q.proposals[id]
+	if !ok {
+		return nil, errUnknownProposal
+	}
+	if len(proposal.Approvals) < q.config.Threshold {
+		return nil, errNotApproved
+	}
+	delete(q.proposals, id)
+	return proposal, nil
+}
+
+// restore puts back a proposal that failed to send.
+func (q *approvalQueue) restore(proposal *Proposal) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	q.proposals[proposal.ID] = proposal
+}
+
+// discard drops a proposal, returning whether it was queued.
+func (q *approvalQueue) discard(id common.Hash) bool {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	_, ok := q.proposals[id]
+	delete(q.proposals, id)
+	return ok
+}
+
+// list returns the queued proposals, oldest first.
+func (q *approvalQueue) list() []*Proposal {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	proposals := make([]*Proposal, 0, len(q.proposals))
+	for _, proposal := range q.proposals {
+		cpy := *proposal
+		cpy.Approvals = append([]common.Address{}, proposal.Approvals...)
+		proposals = append(proposals, &cpy)
+	}
+	sort.Slice(proposals, func(i, j int) bool {
+		return proposals[i].Proposed.Before(proposals[j].Proposed)
+	})
+	return proposals
+}
+
+// ProposeTransaction queues a transaction of a guarded account until enough
+// approvers sign off it. It returns the ID of the proposal for the approvers to
+// sign. The nonce and the missing fields are filled in when sending.
+func (s *PrivateAccountAPI) ProposeTransaction(ctx context.Context, args SendTxArgs) (common.Hash, error) {
+	if _, err := s.am.Find(accounts.Account{Address: args.From}); err != nil {
+		return common.Hash{}, err
+	}
+	return s.approvals.propose(args)
+}
+
+// ApproveTransaction signs off a proposal with the signature of an approver
+// over its ID, as created by personal_sign. Once approved by enough approvers,
+// the transaction is signed with the unlocked key of its sender and sent,
+// returning its hash. If the sender is locked, the approved proposal stays
+// queued for personal_executeTransaction.
+func (s *PrivateAccountAPI) ApproveTransaction(ctx context.Context, id common.Hash, sig hexutil.Bytes) (*common.Hash, error) {
+	approver, err := s.EcRecover(ctx, id[:], sig)
+	if err != nil {
+		return nil, err
+	}
+	proposal, err := s.approvals.approve(id, approver)
+	if proposal == nil || err != nil {
+		return nil, err
+	}
+	hash, err := s.sendProposal(ctx, proposal, nil)
+	if err != nil {
+		return nil, fmt.Errorf("transaction approved, but not sent: %v", err)
+	}
+	return &hash, nil
+}
+
+// ExecuteTransaction signs an approved proposal with the key of its sender
+// decrypted by the password, and sends it.
+func (s *PrivateAccountAPI) ExecuteTransaction(ctx context.Context, id common.Hash, passwd string) (common.Hash, error) {
+	proposal, err := s.approvals.take(id)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return s.sendProposal(ctx, proposal, &passwd)
+}
+
+// Proposals returns the transaction proposals awaiting approval or execution.
+func (s *PrivateAccountAPI) Proposals() []*Proposal {
+	return s.approvals.list()
+}
+
+// DiscardProposal drops a transaction proposal, returning whether it existed.
+func (s *PrivateAccountAPI) DiscardProposal(id common.Hash) bool {
+	return s.approvals.discard(id)
+}
+
+// sendProposal signs and sends an approved proposal taken from the queue, which
+// is restored if sending fails.
+func (s *PrivateAccountAPI) sendProposal(ctx context.Context, proposal *Proposal, passwd *string) (common.Hash, error) {
+	args := proposal.Tx
+
+	leased := args.Nonce == nil
+	if leased {
+		nonce, err := s.nonceLock.reserveNonce(ctx, s.b, args.From)
+		if err != nil {
+			s.approvals.restore(proposal)
+			return common.Hash{}, err
+		}
+		args.Nonce = (*hexutil.Uint64)(&nonce)
+	}
+	nonce := uint64(*args.Nonce)
+
+	hash, err := s.sendBatchTransaction(ctx, args, passwd)
+	s.nonceLock.settleNonce(args.From, nonce, leased, err)
+	if err != nil {
+		s.approvals.restore(proposal)
+		return common.Hash{}, err
+	}
+	return hash, nil
+}
@@ -0,0 +1,93 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethapi
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Tests that proposals are only released for sending once approved by enough
+// distinct approvers, and are released only once.
+func TestApprovalQueue(t *testing.T) {
+	var (
+		treasury  = common.HexToAddress("0x01")
+		approvers = []common.Address{common.HexToAddress("0xa1"), common.HexToAddress("0xa2"), common.HexToAddress("0xa3")}
+	)
+	config := &ApprovalConfig{Accounts: []common.Address{treasury}, Approvers: approvers, Threshold: 2}
+	if err := config.Validate(); err != nil {
+		t.Fatalf("valid config rejected: %v", err)
+	}
+	q := newApprovalQueue(config)
+
+	if _, err := q.propose(SendTxArgs{From: common.HexToAddress("0x02")}); err != errNoApproval {
+		t.Errorf("unguarded proposal error mismatch: have %v, want %v", err, errNoApproval)
+	}
+	id, err := q.propose(SendTxArgs{From: treasury})
+	if err != nil {
+		t.Fatalf("failed to propose: %v", err)
+	}
+	if other, _ := q.propose(SendTxArgs{From: treasury}); other == id {
+		t.Errorf("identical proposals share ID %x", id)
+	}
+	// Approvals must not carry over to the identical proposal after a restart
+	restarted := newApprovalQueue(config)
+	if other, _ := restarted.propose(SendTxArgs{From: treasury}); other == id {
+		t.Errorf("identical proposals share ID %x across restarts", id)
+	}
+	if _, err := restarted.approve(id, approvers[0]); err != errUnknownProposal {
+		t.Errorf("replayed approval error mismatch: have %v, want %v", err, errUnknownProposal)
+	}
+	if _, err := q.approve(id, common.HexToAddress("0xbad")); err != errNotApprover {
+		t.Errorf("foreign approval error mismatch: have %v, want %v", err, errNotApprover)
+	}
+	if p, err := q.approve(id, approvers[0]); p != nil || err != nil {
+		t.Fatalf("first approval: have %v, %v, want pending", p, err)
+	}
+	if _, err := q.approve(id, approvers[0]); err != errAlreadyApproved {
+		t.Errorf("duplicate approval error mismatch: have %v, want %v", err, errAlreadyApproved)
+	}
+	if _, err := q.take(id); err != errNotApproved {
+		t.Errorf("premature execution error mismatch: have %v, want %v", err, errNotApproved)
+	}
+	p, err := q.approve(id, approvers[2])
+	if p == nil || err != nil {
+		t.Fatalf("final approval: have %v, %v, want release", p, err)
+	}
+	if len(p.Approvals) != 2 {
+		t.Errorf("approval count mismatch: have %d, want 2", len(p.Approvals))
+	}
+	// Released proposals must not be released again until restored
+	if _, err := q.approve(id, approvers[1]); err != errUnknownProposal {
+		t.Errorf("released proposal approval error mismatch: have %v, want %v", err, errUnknownProposal)
+	}
+	q.restore(p)
+	if p, err := q.take(id); p == nil || err != nil {
+		t.Errorf("failed to take restored proposal: %v", err)
+	}
+	if len(q.list()) != 1 {
+		t.Errorf("pending proposal count mismatch: have %d, want 1", len(q.list()))
+	}
+	// Thresholds must be reachable
+	for _, threshold := range []int{0, 4} {
+		config := &ApprovalConfig{Accounts: []common.Address{treasury}, Approvers: approvers, Threshold: threshold}
+		if err := config.Validate(); err == nil {
+			t.Errorf("unreachable threshold %d accepted", threshold)
+		}
+	}
+}
@@ -19,10 +19,12 @@ package web3ext
 
 var Modules = map[string]string{
 	"admin":      Admin_JS,
+	"backup":     Backup_JS,
 	"chequebook": Chequebook_JS,
 	"clique":     Clique_JS,
 	"debug":      Debug_JS,
 	"eth":        Eth_JS,
+	"les":        LES_JS,
 	"miner":      Miner_JS,
 	"net":        Net_JS,
 	"personal":   Personal_JS,
@@ -31,6 +33,12 @@ var Modules = map[string]string{
 	"swarmfs":    SWARMFS_JS,
 	"txpool":     TxPool_JS,
 	"istanbul":   Istanbul_JS,
+	"snapshot":   Snapshot_JS,
+	"index":      Index_JS,
+	"exporter":   Exporter_JS,
+	"relayer":    Relayer_JS,
+	"bridge":     Bridge_JS,
+	"bzz":        Bzz_JS,
 }
 
 const Chequebook_JS = `
@@ -160,6 +168,22 @@ web3._extend({
 			name: 'stopWS',
 			call: 'admin_stopWS'
 		}),
+		new web3._extend.Method({
+			name: 'exportConfig',
+			call: 'admin_exportConfig',
+			outputFormatter: console.log
+		}),
+		new web3._extend.Method({
+			name: 'backup',
+			call: 'admin_backup',
+			params: 2,
+			inputFormatter: [null, null]
+		}),
+		new web3._extend.Method({
+			name: 'doctor',
+			call: 'admin_doctor',
+			params: 0
+		}),
 	],
 	properties: [
 		new web3._extend.Property({
@@ -174,6 +198,10 @@ web3._extend({
 			name: 'datadir',
 			getter: 'admin_datadir'
 		}),
+		new web3._extend.Property({
+			name: 'forkStatus',
+			getter: 'admin_forkStatus'
+		}),
 	]
 });
 `
@@ -195,7 +223,8 @@ web3._extend({
 		new web3._extend.Method({
 			name: 'setHead',
 			call: 'debug_setHead',
-			params: 1
+			params: 2,
+			inputFormatter: [null, null]
 		}),
 		new web3._extend.Method({
 			name: 'seedHash',
@@ -207,6 +236,18 @@ web3._extend({
 			call: 'debug_dumpBlock',
 			params: 1
 		}),
+		new web3._extend.Method({
+			name: 'dumpState',
+			call: 'debug_dumpState',
+			params: 2,
+			inputFormatter: [web3._extend.formatters.inputBlockNumberFormatter, null]
+		}),
+		new web3._extend.Method({
+			name: 'diffState',
+			call: 'debug_diffState',
+			params: 2,
+			inputFormatter: [web3._extend.formatters.inputBlockNumberFormatter, web3._extend.formatters.inputBlockNumberFormatter]
+		}),
 		new web3._extend.Method({
 			name: 'chaindbProperty',
 			call: 'debug_chaindbProperty',
@@ -346,6 +387,11 @@ web3._extend({
 			params: 2,
 			inputFormatter: [null, null]
 		}),
+		new web3._extend.Method({
+			name: 'getBlockWitness',
+			call: 'debug_getBlockWitness',
+			params: 1
+		}),
 		new web3._extend.Method({
 			name: 'traceBlockByHash',
 			call: 'debug_traceBlockByHash',
@@ -369,6 +415,21 @@ web3._extend({
 			call: 'debug_getBadBlocks',
 			params: 0,
 		}),
+		new web3._extend.Method({
+			name: 'badBlocks',
+			call: 'debug_badBlocks',
+			params: 0,
+		}),
+		new web3._extend.Method({
+			name: 'finalityConflict',
+			call: 'debug_finalityConflict',
+			params: 0,
+		}),
+		new web3._extend.Method({
+			name: 'replayConsensus',
+			call: 'debug_replayConsensus',
+			params: 1,
+		}),
 		new web3._extend.Method({
 			name: 'storageRangeAt',
 			call: 'debug_storageRangeAt',
@@ -419,6 +480,12 @@ web3._extend({
 			params: 1,
 			inputFormatter: [web3._extend.formatters.inputTransactionFormatter]
 		}),
+		new web3._extend.Method({
+			name: 'getTransactionsByAddress',
+			call: 'eth_getTransactionsByAddress',
+			params: 4,
+			inputFormatter: [web3._extend.formatters.inputAddressFormatter, web3._extend.formatters.inputBlockNumberFormatter, web3._extend.formatters.inputBlockNumberFormatter, null]
+		}),
 		new web3._extend.Method({
 			name: 'getRawTransaction',
 			call: 'eth_getRawTransactionByHash',
@@ -432,8 +499,31 @@ web3._extend({
 			params: 2,
 			inputFormatter: [web3._extend.formatters.inputBlockNumberFormatter, web3._extend.utils.toHex]
 		}),
+		new web3._extend.Method({
+			name: 'getProof',
+			call: 'eth_getProof',
+			params: 3,
+			inputFormatter: [web3._extend.formatters.inputAddressFormatter, null, web3._extend.formatters.inputBlockNumberFormatter]
+		}),
+		new web3._extend.Method({
+			name: 'createAccessList',
+			call: 'eth_createAccessList',
+			params: 2,
+			inputFormatter: [web3._extend.formatters.inputCallFormatter, web3._extend.formatters.inputDefaultBlockNumberFormatter]
+		}),
+		new web3._extend.Method({
+			name: 'feeHistory',
+			call: 'eth_feeHistory',
+			params: 3,
+			inputFormatter: [web3._extend.utils.toHex, web3._extend.formatters.inputBlockNumberFormatter, null]
+		}),
 	],
 	properties: [
+		new web3._extend.Property({
+			name: 'maxPriorityFeePerGas',
+			getter: 'eth_maxPriorityFeePerGas',
+			outputFormatter: web3._extend.utils.toBigNumber
+		}),
 		new web3._extend.Property({
 			name: 'pendingTransactions',
 			getter: 'eth_pendingTransactions',
@@ -450,6 +540,19 @@ web3._extend({
 });
 `
 
+const LES_JS = `
+web3._extend({
+	property: 'les',
+	methods: [],
+	properties: [
+		new web3._extend.Property({
+			name: 'finalizedHeader',
+			getter: 'les_finalizedHeader'
+		}),
+	]
+});
+`
+
 const Miner_JS = `
 web3._extend({
 	property: 'miner',
@@ -507,6 +610,12 @@ const Personal_JS = `
 web3._extend({
 	property: 'personal',
 	methods: [
+		new web3._extend.Method({
+			name: 'unlockAccountScoped',
+			call: 'personal_unlockAccount',
+			params: 4,
+			inputFormatter: [web3._extend.formatters.inputAddressFormatter, null, null, null]
+		}),
 		new web3._extend.Method({
 			name: 'importRawKey',
 			call: 'personal_importRawKey',
@@ -528,6 +637,11 @@ web3._extend({
 			call: 'personal_openWallet',
 			params: 2
 		}),
+		new web3._extend.Method({
+			name: 'closeWallet',
+			call: 'personal_closeWallet',
+			params: 1
+		}),
 		new web3._extend.Method({
 			name: 'deriveAccount',
 			call: 'personal_deriveAccount',
@@ -539,12 +653,55 @@ web3._extend({
 			params: 2,
 			inputFormatter: [web3._extend.formatters.inputTransactionFormatter, null]
 		}),
+		new web3._extend.Method({
+			name: 'sendTransactionBatch',
+			call: 'personal_sendTransactionBatch',
+			params: 2,
+			inputFormatter: [function(txs) { return txs.map(web3._extend.formatters.inputTransactionFormatter); }, null]
+		}),
+		new web3._extend.Method({
+			name: 'leaseNonces',
+			call: 'personal_leaseNonces',
+			params: 3,
+			inputFormatter: [web3._extend.formatters.inputAddressFormatter, null, null]
+		}),
+		new web3._extend.Method({
+			name: 'releaseNonces',
+			call: 'personal_releaseNonces',
+			params: 2,
+			inputFormatter: [web3._extend.formatters.inputAddressFormatter, null]
+		}),
+		new web3._extend.Method({
+			name: 'proposeTransaction',
+			call: 'personal_proposeTransaction',
+			params: 1,
+			inputFormatter: [web3._extend.formatters.inputTransactionFormatter]
+		}),
+		new web3._extend.Method({
+			name: 'approveTransaction',
+			call: 'personal_approveTransaction',
+			params: 2
+		}),
+		new web3._extend.Method({
+			name: 'executeTransaction',
+			call: 'personal_executeTransaction',
+			params: 2
+		}),
+		new web3._extend.Method({
+			name: 'discardProposal',
+			call: 'personal_discardProposal',
+			params: 1
+		}),
 	],
 	properties: [
 		new web3._extend.Property({
 			name: 'listWallets',
 			getter: 'personal_listWallets'
 		}),
+		new web3._extend.Property({
+			name: 'proposals',
+			getter: 'personal_proposals'
+		}),
 	]
 })
 `
@@ -644,6 +801,39 @@ web3._extend({
 			params: 1,
 			inputFormatter: [null]
 		}),
+		new web3._extend.Method({
+			name: 'peerVersions',
+			call: 'istanbul_peerVersions',
+			params: 0
+		}),
+		new web3._extend.Method({
+			name: 'upgradeStatus',
+			call: 'istanbul_upgradeStatus',
+			params: 0
+		}),
+		new web3._extend.Method({
+			name: 'dealKeyShares',
+			call: 'istanbul_dealKeyShares',
+			params: 2
+		}),
+		new web3._extend.Method({
+			name: 'combineKeyShares',
+			call: 'istanbul_combineKeyShares',
+			params: 2,
+			inputFormatter: [null, null]
+		}),
+		new web3._extend.Method({
+			name: 'getParticipation',
+			call: 'istanbul_getParticipation',
+			params: 2,
+			inputFormatter: [null, null]
+		}),
+		new web3._extend.Method({
+			name: 'getMisbehavior',
+			call: 'istanbul_getMisbehavior',
+			params: 2,
+			inputFormatter: [web3._extend.formatters.inputBlockNumberFormatter, web3._extend.formatters.inputBlockNumberFormatter]
+		}),
 		new web3._extend.Method({
 			name: 'getSnapshotAtHash',
 			call: 'istanbul_getSnapshotAtHash',
@@ -669,6 +859,23 @@ web3._extend({
 			name: 'discard',
 			call: 'istanbul_discard',
 			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'setMaintenance',
+			call: 'istanbul_setMaintenance',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'dumpState',
+			call: 'istanbul_dumpState',
+			params: 1,
+			inputFormatter: [null]
+		}),
+		new web3._extend.Method({
+			name: 'getSystemCallReceipt',
+			call: 'istanbul_getSystemCallReceipt',
+			params: 1,
+			inputFormatter: [null]
 		})
 	],
 	properties:
@@ -679,4 +886,232 @@ web3._extend({
 		}),
 	]
 });
+
+// printSnapshot prints the validator snapshot at a block number or hash (the
+// latest block if omitted) as a table of validators, votes and tallies.
+web3.istanbul.printSnapshot = function(block) {
+	var snap;
+	if (typeof block === 'string' && block.length === 66) {
+		snap = web3.istanbul.getSnapshotAtHash(block);
+	} else {
+		snap = web3.istanbul.getSnapshot(block === undefined ? null : web3._extend.formatters.inputBlockNumberFormatter(block));
+	}
+	var policies = ['round-robin', 'sticky'];
+	console.log('Snapshot #' + snap.number + ' (' + snap.hash + ')');
+	console.log('Epoch: ' + snap.epoch + ', proposer policy: ' + (policies[snap.policy] || snap.policy));
+	console.log('Validators (' + snap.validators.length + '):');
+	snap.validators.forEach(function(validator, i) {
+		console.log('  ' + i + '\t' + validator);
+	});
+	if (snap.votes.length > 0) {
+		console.log('Votes (' + snap.votes.length + '):');
+		snap.votes.forEach(function(vote) {
+			console.log('  #' + vote.block + '\t' + vote.validator + (vote.authorize ? ' +' : ' -') + ' ' + vote.address);
+		});
+	}
+	var tally = Object.keys(snap.tally).sort();
+	if (tally.length > 0) {
+		console.log('Tally:');
+		tally.forEach(function(address) {
+			var t = snap.tally[address];
+			console.log('  ' + address + '\t' + (t.authorize ? 'authorize' : 'kick') + '\t' + t.votes + '/' + snap.validators.length);
+		});
+	}
+};
+`
+
+const Snapshot_JS = `
+web3._extend({
+	property: 'snapshot',
+	methods: [
+		new web3._extend.Method({
+			name: 'list',
+			call: 'snapshot_list',
+			params: 0
+		})
+	]
+});
+`
+
+const Backup_JS = `
+web3._extend({
+	property: 'backup',
+	methods: [
+		new web3._extend.Method({
+			name: 'range',
+			call: 'backup_range',
+			params: 1
+		})
+	],
+	properties:
+	[
+		new web3._extend.Property({
+			name: 'info',
+			getter: 'backup_info'
+		}),
+	]
+});
+`
+
+const Exporter_JS = `
+web3._extend({
+	property: 'exporter',
+	methods: [],
+	properties:
+	[
+		new web3._extend.Property({
+			name: 'status',
+			getter: 'exporter_status'
+		}),
+	]
+});
+`
+
+const Relayer_JS = `
+web3._extend({
+	property: 'relayer',
+	methods:
+	[
+		new web3._extend.Method({
+			name: 'sendMetaTransaction',
+			call: 'relayer_sendMetaTransaction',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'getResult',
+			call: 'relayer_getResult',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'getNonce',
+			call: 'relayer_nonce',
+			params: 1,
+			inputFormatter: [web3._extend.formatters.inputAddressFormatter],
+			outputFormatter: web3._extend.utils.toDecimal
+		}),
+		new web3._extend.Method({
+			name: 'getQuota',
+			call: 'relayer_quota',
+			params: 1,
+			inputFormatter: [web3._extend.formatters.inputAddressFormatter]
+		}),
+	],
+	properties:
+	[
+		new web3._extend.Property({
+			name: 'domain',
+			getter: 'relayer_domain'
+		}),
+	]
+});
+`
+
+const Bridge_JS = `
+web3._extend({
+	property: 'bridge',
+	methods:
+	[
+		new web3._extend.Method({
+			name: 'getMessage',
+			call: 'bridge_getMessage',
+			params: 2,
+			inputFormatter: [null, web3._extend.utils.toHex]
+		}),
+		new web3._extend.Method({
+			name: 'getSubmission',
+			call: 'bridge_submission',
+			params: 1
+		}),
+	],
+	properties:
+	[
+		new web3._extend.Property({
+			name: 'status',
+			getter: 'bridge_status'
+		}),
+	]
+});
+`
+
+const Bzz_JS = `
+web3._extend({
+	property: 'bzz',
+	methods:
+	[
+		new web3._extend.Method({
+			name: 'hash',
+			call: 'bzz_hash',
+			params: 2,
+			inputFormatter: [null, null]
+		}),
+		new web3._extend.Method({
+			name: 'stat',
+			call: 'bzz_stat',
+			params: 2,
+			inputFormatter: [null, null]
+		}),
+		new web3._extend.Method({
+			name: 'list',
+			call: 'bzz_list',
+			params: 1
+		}),
+	],
+	properties:
+	[
+		new web3._extend.Property({
+			name: 'storeStats',
+			getter: 'bzz_storeStats'
+		}),
+		new web3._extend.Property({
+			name: 'pinned',
+			getter: 'bzz_pinned'
+		}),
+	]
+});
+
+// printList prints the listing of the manifest under a bzz path, directories
+// first, followed by the files with their size and content type.
+web3.bzz.printList = function(bzzpath) {
+	var list = web3.bzz.list(bzzpath);
+	var pad = function(s, n) {
+		s = String(s);
+		while (s.length < n) {
+			s = ' ' + s;
+		}
+		return s;
+	};
+	(list.common_prefixes || []).forEach(function(prefix) {
+		console.log(pad('DIR', 12) + '  ' + prefix);
+	});
+	(list.entries || []).forEach(function(entry) {
+		console.log(pad(entry.size || 0, 12) + '  ' + entry.path + (entry.contentType ? '\t' + entry.contentType : ''));
+	});
+};
+`
+
+const Index_JS = `
+web3._extend({
+	property: 'index',
+	methods: [
+		new web3._extend.Method({
+			name: 'getAddressTransactions',
+			call: 'index_getAddressTransactions',
+			params: 4,
+			inputFormatter: [web3._extend.formatters.inputAddressFormatter, null, null, null]
+		}),
+		new web3._extend.Method({
+			name: 'getInternalTransfers',
+			call: 'index_getInternalTransfers',
+			params: 4,
+			inputFormatter: [web3._extend.formatters.inputAddressFormatter, null, null, null]
+		}),
+	],
+	properties:
+	[
+		new web3._extend.Property({
+			name: 'status',
+			getter: 'index_status'
+		}),
+	]
+});
 `
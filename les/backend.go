@@ -80,6 +80,12 @@ type LightEthereum struct {
 }
 
 func New(ctx *node.ServiceContext, config *eth.Config) (*LightEthereum, error) {
+	if err := config.TxApproval.Validate(); err != nil {
+		return nil, err
+	}
+	if err := core.SetBlockCompression(config.BlockCompression); err != nil {
+		return nil, err
+	}
 	chainDb, err := eth.CreateDB(ctx, config, "lightchaindata")
 	if err != nil {
 		return nil, err
@@ -88,6 +94,9 @@ func New(ctx *node.ServiceContext, config *eth.Config) (*LightEthereum, error) {
 	if _, isCompat := genesisErr.(*params.ConfigCompatError); genesisErr != nil && !isCompat {
 		return nil, genesisErr
 	}
+	if err := chainConfig.CheckEngine(); err != nil {
+		return nil, err
+	}
 	log.Info("Initialised chain configuration", "config", chainConfig)
 
 	peers := newPeerSet()
@@ -176,7 +185,18 @@ func (s *LightDummyAPI) Mining() bool {
 // APIs returns the collection of RPC services the ethereum package offers.
 // NOTE, some of these services probably need to be moved to somewhere else.
 func (s *LightEthereum) APIs() []rpc.API {
-	return append(ethapi.GetAPIs(s.ApiBackend), []rpc.API{
+	apis := ethapi.GetAPIs(s.ApiBackend)
+
+	// Expose finality tracking if the consensus engine supports it
+	if api := NewPublicFinalityAPI(s.blockchain, s.engine); api != nil {
+		apis = append(apis, rpc.API{
+			Namespace: "les",
+			Version:   "1.0",
+			Service:   api,
+			Public:    true,
+		})
+	}
+	return append(apis, []rpc.API{
 		{
 			Namespace: "eth",
 			Version:   "1.0",
@@ -0,0 +1,121 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package les
+
+import (
+	"context"
+	"errors"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/light"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// maxFinalityLookback is the number of headers searched backwards from the head
+// for one carrying a valid finality proof.
+const maxFinalityLookback = 64
+
+var errNoFinalizedHeader = errors.New("no finalized header known")
+
+// PublicFinalityAPI exposes the finality information a light client tracks for
+// chains whose consensus engine provides explicit finality (e.g. Istanbul).
+//
+// No dedicated protocol messages are involved: Istanbul headers carry their
+// commit seals and validator votes in their extra-data and vote fields, so the
+// headers synced over the regular LES header requests already contain the seals
+// and validator set transitions, and are verified by the engine on import.
+// Inclusion proofs of accounts and receipts are retrieved through the existing
+// on-demand requests, checked against the roots of these headers. Light clients
+// needing finality thus only have to pick the headers proven final, which this
+// API does.
+type PublicFinalityAPI struct {
+	chain  lightChainReader
+	engine consensus.FinalityVerifier
+}
+
+// lightChainReader adapts a light chain to consensus.ChainReader. Just as with a
+// header chain, block bodies are not available locally.
+type lightChainReader struct {
+	*light.LightChain
+}
+
+// GetBlock implements consensus.ChainReader, always returning nil.
+func (lightChainReader) GetBlock(hash common.Hash, number uint64) *types.Block {
+	return nil
+}
+
+// NewPublicFinalityAPI creates the finality API for the given light chain. It
+// returns nil if the engine of the chain does not provide explicit finality.
+func NewPublicFinalityAPI(chain *light.LightChain, engine consensus.Engine) *PublicFinalityAPI {
+	verifier, ok := engine.(consensus.FinalityVerifier)
+	if !ok {
+		return nil
+	}
+	return &PublicFinalityAPI{chain: lightChainReader{chain}, engine: verifier}
+}
+
+// FinalizedHeader returns the most recent locally known header carrying a valid
+// finality proof.
+func (api *PublicFinalityAPI) FinalizedHeader() (*types.Header, error) {
+	header := api.chain.CurrentHeader()
+	for i := 0; header != nil && i < maxFinalityLookback; i++ {
+		if header.Number.Sign() == 0 {
+			return header, nil // genesis is final by definition
+		}
+		if api.engine.VerifyFinality(api.chain, header) == nil {
+			return header, nil
+		}
+		header = api.chain.GetHeader(header.ParentHash, header.Number.Uint64()-1)
+	}
+	return nil, errNoFinalizedHeader
+}
+
+// NewFinalizedHeads creates a subscription that fires each time a new head with
+// a valid finality proof is imported.
+func (api *PublicFinalityAPI) NewFinalizedHeads(ctx context.Context) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+	rpcSub := notifier.CreateSubscription()
+
+	// Subscribe before returning, so no head imported afterwards is missed
+	heads := make(chan core.ChainHeadEvent, 16)
+	headsSub := api.chain.SubscribeChainHeadEvent(heads)
+
+	go func() {
+		defer headsSub.Unsubscribe()
+
+		for {
+			select {
+			case ev := <-heads:
+				header := ev.Block.Header()
+				if api.engine.VerifyFinality(api.chain, header) == nil {
+					notifier.Notify(rpcSub.ID, header)
+				}
+			case <-rpcSub.Err():
+				return
+			case <-notifier.Closed():
+				return
+			}
+		}
+	}()
+	return rpcSub, nil
+}
@@ -0,0 +1,146 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package les
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/light"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// finalityEngine is a consensus engine considering every period-th header final.
+type finalityEngine struct {
+	consensus.Engine
+	period uint64
+}
+
+func (e *finalityEngine) VerifyFinality(chain consensus.ChainReader, header *types.Header) error {
+	if header.Number.Uint64()%e.period != 0 {
+		return errors.New("not final")
+	}
+	if chain.GetHeader(header.ParentHash, header.Number.Uint64()-1) == nil {
+		return consensus.ErrUnknownAncestor
+	}
+	return nil
+}
+
+// newFinalityTestChain creates a light chain with the given finality engine and
+// returns it along with n headers extending its genesis, not yet imported.
+func newFinalityTestChain(t *testing.T, engine consensus.Engine, n int) (*light.LightChain, []*types.Header) {
+	db, _ := ethdb.NewMemDatabase()
+	genesis := (&core.Genesis{Config: params.TestChainConfig}).MustCommit(db)
+
+	chain, err := light.NewLightChain(NewLesOdr(db, nil, nil, nil, nil), params.TestChainConfig, engine)
+	if err != nil {
+		t.Fatalf("failed to create light chain: %v", err)
+	}
+	blocks, _ := core.GenerateChain(params.TestChainConfig, genesis, ethash.NewFaker(), db, n, nil)
+	headers := make([]*types.Header, len(blocks))
+	for i, block := range blocks {
+		headers[i] = block.Header()
+	}
+	return chain, headers
+}
+
+// Tests that the most recent final header is found within the lookback window.
+func TestFinalizedHeader(t *testing.T) {
+	if api := NewPublicFinalityAPI(nil, ethash.NewFaker()); api != nil {
+		t.Fatalf("finality API created for engine without explicit finality")
+	}
+	engine := &finalityEngine{Engine: ethash.NewFaker(), period: 4}
+	chain, headers := newFinalityTestChain(t, engine, maxFinalityLookback+10)
+	defer chain.Stop()
+
+	api := NewPublicFinalityAPI(chain, engine)
+
+	// The genesis is final on an empty chain
+	header, err := api.FinalizedHeader()
+	if err != nil || header.Number.Uint64() != 0 {
+		t.Fatalf("empty chain: finalized header mismatch: have %v (%v), want 0", header, err)
+	}
+	// The closest final ancestor is returned once headers are imported
+	if _, err := chain.InsertHeaderChain(headers[:10], 1); err != nil {
+		t.Fatalf("failed to import headers: %v", err)
+	}
+	if header, err = api.FinalizedHeader(); err != nil || header.Hash() != headers[7].Hash() {
+		t.Fatalf("finalized header mismatch: have %v (%v), want #8", header, err)
+	}
+	// Nothing is returned if no final header is within the lookback window
+	engine.period = 1 << 20
+	if _, err := chain.InsertHeaderChain(headers[10:], 1); err != nil {
+		t.Fatalf("failed to import headers: %v", err)
+	}
+	if header, err = api.FinalizedHeader(); err != errNoFinalizedHeader {
+		t.Fatalf("finalized header out of lookback: have %v (%v), want error %v", header, err, errNoFinalizedHeader)
+	}
+}
+
+// Tests that only imported heads carrying a finality proof are notified.
+func TestNewFinalizedHeads(t *testing.T) {
+	engine := &finalityEngine{Engine: ethash.NewFaker(), period: 2}
+	chain, headers := newFinalityTestChain(t, engine, 6)
+	defer chain.Stop()
+
+	server := rpc.NewServer()
+	if err := server.RegisterName("les", NewPublicFinalityAPI(chain, engine)); err != nil {
+		t.Fatalf("failed to register finality API: %v", err)
+	}
+	defer server.Stop()
+	client := rpc.DialInProc(server)
+	defer client.Close()
+
+	heads := make(chan *types.Header, len(headers))
+	sub, err := client.Subscribe(context.Background(), "les", heads, "newFinalizedHeads")
+	if err != nil {
+		t.Fatalf("failed to subscribe: %v", err)
+	}
+	defer sub.Unsubscribe()
+
+	// Import the headers one by one, each becoming the new head
+	for _, header := range headers {
+		if _, err := chain.InsertHeaderChain([]*types.Header{header}, 1); err != nil {
+			t.Fatalf("failed to import header #%d: %v", header.Number, err)
+		}
+	}
+	for _, want := range []uint64{2, 4, 6} {
+		select {
+		case head := <-heads:
+			if head.Number.Uint64() != want || head.Hash() != headers[want-1].Hash() {
+				t.Fatalf("finalized head mismatch: have #%d %x, want #%d %x", head.Number, head.Hash(), want, headers[want-1].Hash())
+			}
+		case err := <-sub.Err():
+			t.Fatalf("subscription failed: %v", err)
+		case <-time.After(time.Second):
+			t.Fatalf("finalized head #%d not notified", want)
+		}
+	}
+	select {
+	case head := <-heads:
+		t.Fatalf("unexpected finalized head notified: #%d", head.Number)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
@@ -0,0 +1,189 @@
+package log
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// backupTimeFormat is the timestamp appended to the names of rotated log files,
+// chosen to sort chronologically and to be valid in file names on all platforms.
+const backupTimeFormat = "2006-01-02T15-04-05.000000000"
+
+// RotateConfig are the rotation settings of a log file.
+type RotateConfig struct {
+	MaxSize    int64         // Size in bytes above which the file is rotated (0 = unlimited)
+	MaxAge     time.Duration // Time after which the file is rotated (0 = unlimited)
+	MaxBackups int           // Number of rotated files to keep (0 = keep all)
+	Compress   bool          // Whether to gzip the rotated files
+}
+
+// RotatingFileHandler returns a handler which writes log records to the given
+// file like FileHandler, but moves the file aside once it grew too large or too
+// old, optionally compressing it and removing the oldest of the moved files.
+func RotatingFileHandler(path string, config RotateConfig, fmtr Format) (Handler, error) {
+	w := &rotatingWriter{path: path, config: config}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	return &closingHandler{w, StreamHandler(w, fmtr)}, nil
+}
+
+// rotatingWriter is a file writer rotating the file according to its settings.
+type rotatingWriter struct {
+	path   string
+	config RotateConfig
+
+	file   *os.File
+	size   int64     // Bytes written to the current file
+	opened time.Time // Time the current file was opened at
+
+	cleanup sync.WaitGroup // Compression and removal of rotated files in progress
+	lock    sync.Mutex
+}
+
+// open opens the log file for appending, creating it if needed.
+func (w *rotatingWriter) open() error {
+	f, err := os.OpenFile(w.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	w.file, w.size, w.opened = f, info.Size(), time.Now()
+	return nil
+}
+
+// Write writes a formatted log record, rotating the file first if the record
+// would take it over the size limit or if it reached its maximum age.
+func (w *rotatingWriter) Write(p []byte) (int, error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if w.file == nil {
+		return 0, os.ErrClosed
+	}
+	if (w.config.MaxSize > 0 && w.size > 0 && w.size+int64(len(p)) > w.config.MaxSize) ||
+		(w.config.MaxAge > 0 && time.Since(w.opened) > w.config.MaxAge) {
+		if err := w.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+// rotate moves the current file aside and opens a new one, compressing and
+// pruning the rotated files in the background.
+func (w *rotatingWriter) rotate() error {
+	if err := w.file.Close(); err != nil {
+		return err
+	}
+	w.file = nil
+
+	now := time.Now()
+	backup := w.path + "." + now.Format(backupTimeFormat)
+	for fileExists(backup) || fileExists(backup+".gz") {
+		now = now.Add(time.Nanosecond) // coarse clock, keep the names unique
+		backup = w.path + "." + now.Format(backupTimeFormat)
+	}
+	if err := os.Rename(w.path, backup); err != nil {
+		if oerr := w.open(); oerr != nil {
+			return oerr
+		}
+		return err
+	}
+	if err := w.open(); err != nil {
+		return err
+	}
+	w.cleanup.Add(1)
+	go func() {
+		defer w.cleanup.Done()
+		if w.config.Compress {
+			if err := compressFile(backup); err != nil {
+				Error("Failed to compress rotated log file", "file", backup, "err", err)
+			}
+		}
+		w.prune()
+	}()
+	return nil
+}
+
+// prune removes the oldest rotated files above the number of backups to keep.
+func (w *rotatingWriter) prune() {
+	if w.config.MaxBackups <= 0 {
+		return
+	}
+	backups, err := filepath.Glob(w.path + ".*")
+	if err != nil {
+		return
+	}
+	// Skip files still being compressed, they are counted by their gzip name
+	files := backups[:0]
+	for _, file := range backups {
+		if !fileExists(file + ".gz") {
+			files = append(files, file)
+		}
+	}
+	sort.Strings(files)
+	for len(files) > w.config.MaxBackups {
+		os.Remove(files[0])
+		files = files[1:]
+	}
+}
+
+// Close waits for the rotated files to be processed and closes the log file.
+func (w *rotatingWriter) Close() error {
+	w.cleanup.Wait() // cleanup may log, don't hold the lock
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if w.file == nil {
+		return nil
+	}
+	err := w.file.Close()
+	w.file = nil
+	return err
+}
+
+// fileExists reports whether a file exists at the given path.
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// compressFile gzips a file, replacing it with its compressed version.
+func compressFile(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(path+".gz", os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(dst)
+	if _, err = io.Copy(gz, src); err == nil {
+		err = gz.Close()
+	}
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(path + ".gz")
+		return err
+	}
+	src.Close()
+	return os.Remove(path)
+}
@@ -0,0 +1,58 @@
+package log
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Tests that the log file is rotated once it grows too large, and that only the
+// configured number of compressed backups is kept.
+func TestRotatingFileHandler(t *testing.T) {
+	dir, err := ioutil.TempDir("", "log-rotate-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "geth.log")
+	format := FormatFunc(func(r *Record) []byte { return []byte(r.Msg + "\n") })
+	h, err := RotatingFileHandler(path, RotateConfig{MaxSize: 10, MaxBackups: 2, Compress: true}, format)
+	if err != nil {
+		t.Fatalf("failed to create handler: %v", err)
+	}
+	for _, msg := range []string{"first", "second", "third", "fourth"} {
+		if err := h.Log(&Record{Msg: msg}); err != nil {
+			t.Fatalf("failed to log %q: %v", msg, err)
+		}
+	}
+	if err := h.(*closingHandler).Close(); err != nil {
+		t.Fatalf("failed to close handler: %v", err)
+	}
+	if content, _ := ioutil.ReadFile(path); string(content) != "fourth\n" {
+		t.Errorf("log file content mismatch: have %q, want %q", content, "fourth\n")
+	}
+	backups, _ := filepath.Glob(path + ".*")
+	if len(backups) != 2 {
+		t.Fatalf("backup count mismatch: have %v, want 2", backups)
+	}
+	for i, want := range []string{"second\n", "third\n"} {
+		if !strings.HasSuffix(backups[i], ".gz") {
+			t.Errorf("backup %d: not compressed: %s", i, backups[i])
+			continue
+		}
+		f, _ := os.Open(backups[i])
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			t.Fatalf("backup %d: invalid gzip: %v", i, err)
+		}
+		content, _ := ioutil.ReadAll(gz)
+		f.Close()
+		if string(content) != want {
+			t.Errorf("backup %d: content mismatch: have %q, want %q", i, content, want)
+		}
+	}
+}
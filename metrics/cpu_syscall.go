@@ -16,7 +16,7 @@
 
 // +build !windows
 
-package dashboard
+package metrics
 
 import (
 	"syscall"
@@ -24,8 +24,8 @@ import (
 	"github.com/ethereum/go-ethereum/log"
 )
 
-// getProcessCPUTime retrieves the process' CPU time since program startup.
-func getProcessCPUTime() float64 {
+// ProcessCPUTime retrieves the process' CPU time since program startup.
+func ProcessCPUTime() float64 {
 	var usage syscall.Rusage
 	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &usage); err != nil {
 		log.Warn("Failed to retrieve CPU time", "err", err)
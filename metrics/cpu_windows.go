@@ -14,10 +14,10 @@
 // You should have received a copy of the GNU Lesser General Public License
 // along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
 
-package dashboard
+package metrics
 
-// getProcessCPUTime returns 0 on Windows as there is no system call to resolve
+// ProcessCPUTime returns 0 on Windows as there is no system call to resolve
 // the actual process' CPU time.
-func getProcessCPUTime() float64 {
+func ProcessCPUTime() float64 {
 	return 0
 }
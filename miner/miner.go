@@ -162,6 +162,18 @@ func (self *Miner) SetExtra(extra []byte) error {
 	return nil
 }
 
+// SetTxOrdering sets the policy ordering the pending transactions in the blocks
+// assembled from now on.
+func (self *Miner) SetTxOrdering(ordering TxOrdering) error {
+	switch ordering {
+	case OrderByPrice, OrderByArrival, OrderFairShare:
+	default:
+		return fmt.Errorf("unknown transaction ordering %q", ordering)
+	}
+	self.worker.setTxOrdering(ordering)
+	return nil
+}
+
 // Pending returns the currently pending block and associated state.
 func (self *Miner) Pending() (*types.Block, *state.StateDB) {
 	return self.worker.pending()
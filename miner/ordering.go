@@ -0,0 +1,201 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package miner
+
+import (
+	"bytes"
+	"container/heap"
+	"sort"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// TxOrdering is the policy ordering the pending transactions in the blocks
+// assembled by the local sealer. Whatever the policy, the transactions of a
+// sender are always included in nonce order.
+type TxOrdering string
+
+const (
+	OrderByPrice   TxOrdering = "price"     // Highest gas price first, auctioning the block space
+	OrderByArrival TxOrdering = "fifo"      // Earliest arrival in the transaction pool first
+	OrderFairShare TxOrdering = "fairshare" // One transaction per sender in turn, by arrival of their first
+)
+
+// txSet is a set of transactions yielding them in the order of a policy,
+// while supporting removing entire batches of transactions for non-executable
+// accounts.
+type txSet interface {
+	// Peek returns the next transaction in order.
+	Peek() *types.Transaction
+
+	// Shift replaces the next transaction with the following one of the same
+	// account.
+	Shift()
+
+	// Pop removes the next transaction along with all following ones of the
+	// same account.
+	Pop()
+}
+
+// newTxSet orders the pending transactions, grouped by sender and sorted by
+// nonce, according to the policy. Transactions of unknown arrival time are
+// deemed to have arrived last.
+//
+// Note, the input map is reowned so the caller should not interact any more
+// with it after providing it to the constructor.
+func newTxSet(policy TxOrdering, signer types.Signer, txs map[common.Address]types.Transactions, arrivals map[common.Hash]time.Time) txSet {
+	switch policy {
+	case OrderByArrival:
+		return newTxsByArrival(signer, txs, arrivals)
+	case OrderFairShare:
+		return newTxsFairShare(signer, txs, arrivals)
+	default:
+		return types.NewTransactionsByPriceAndNonce(signer, txs)
+	}
+}
+
+// arrivalTime returns the time the transaction arrived at, or the far future if
+// unknown.
+func arrivalTime(arrivals map[common.Hash]time.Time, tx *types.Transaction) time.Time {
+	if at, ok := arrivals[tx.Hash()]; ok {
+		return at
+	}
+	return time.Unix(1<<62, 0)
+}
+
+// arrivalHead is the next transaction of an account, along with its arrival.
+type arrivalHead struct {
+	tx   *types.Transaction
+	from common.Address
+	at   time.Time
+}
+
+// arrivalHeap is a heap of account heads, earliest arrival first, ties broken
+// by sender for the order to be deterministic.
+type arrivalHeap []*arrivalHead
+
+func (h arrivalHeap) Len() int { return len(h) }
+func (h arrivalHeap) Less(i, j int) bool {
+	if !h[i].at.Equal(h[j].at) {
+		return h[i].at.Before(h[j].at)
+	}
+	return bytes.Compare(h[i].from[:], h[j].from[:]) < 0
+}
+func (h arrivalHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *arrivalHeap) Push(x interface{}) {
+	*h = append(*h, x.(*arrivalHead))
+}
+
+func (h *arrivalHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	*h = old[0 : n-1]
+	return x
+}
+
+// newArrivalHeads splits off the first transaction of each account, returning
+// them sorted by arrival.
+func newArrivalHeads(signer types.Signer, txs map[common.Address]types.Transactions, arrivals map[common.Hash]time.Time) arrivalHeap {
+	heads := make(arrivalHeap, 0, len(txs))
+	for _, accTxs := range txs {
+		// Ensure the sender address is from the signer
+		from, _ := types.Sender(signer, accTxs[0])
+		heads = append(heads, &arrivalHead{tx: accTxs[0], from: from, at: arrivalTime(arrivals, accTxs[0])})
+		txs[from] = accTxs[1:]
+	}
+	sort.Sort(heads)
+	return heads
+}
+
+// txsByArrival yields transactions strictly in the order they arrived in the
+// transaction pool, as long as the nonce order allows.
+type txsByArrival struct {
+	txs      map[common.Address]types.Transactions // Per account nonce-sorted list of transactions
+	heads    arrivalHeap                           // Next transaction for each unique account (arrival heap)
+	arrivals map[common.Hash]time.Time             // Arrival times of the transactions
+}
+
+func newTxsByArrival(signer types.Signer, txs map[common.Address]types.Transactions, arrivals map[common.Hash]time.Time) *txsByArrival {
+	heads := newArrivalHeads(signer, txs, arrivals)
+	heap.Init(&heads)
+
+	return &txsByArrival{
+		txs:      txs,
+		heads:    heads,
+		arrivals: arrivals,
+	}
+}
+
+func (t *txsByArrival) Peek() *types.Transaction {
+	if len(t.heads) == 0 {
+		return nil
+	}
+	return t.heads[0].tx
+}
+
+func (t *txsByArrival) Shift() {
+	head := t.heads[0]
+	if txs := t.txs[head.from]; len(txs) > 0 {
+		head.tx, head.at, t.txs[head.from] = txs[0], arrivalTime(t.arrivals, txs[0]), txs[1:]
+		heap.Fix(&t.heads, 0)
+	} else {
+		heap.Pop(&t.heads)
+	}
+}
+
+func (t *txsByArrival) Pop() {
+	heap.Pop(&t.heads)
+}
+
+// txsFairShare yields one transaction of each account in turn, the accounts
+// taking turns in the order their first transaction arrived, so that no sender
+// can crowd out the others by flooding the pool.
+type txsFairShare struct {
+	txs   map[common.Address]types.Transactions // Per account nonce-sorted list of transactions
+	heads []*arrivalHead                        // Next transaction for each unique account, in turn order
+}
+
+func newTxsFairShare(signer types.Signer, txs map[common.Address]types.Transactions, arrivals map[common.Hash]time.Time) *txsFairShare {
+	return &txsFairShare{
+		txs:   txs,
+		heads: newArrivalHeads(signer, txs, arrivals),
+	}
+}
+
+func (t *txsFairShare) Peek() *types.Transaction {
+	if len(t.heads) == 0 {
+		return nil
+	}
+	return t.heads[0].tx
+}
+
+func (t *txsFairShare) Shift() {
+	head := t.heads[0]
+	t.heads = t.heads[1:]
+	if txs := t.txs[head.from]; len(txs) > 0 {
+		head.tx, t.txs[head.from] = txs[0], txs[1:]
+		t.heads = append(t.heads, head)
+	}
+}
+
+func (t *txsFairShare) Pop() {
+	t.heads = t.heads[1:]
+}
@@ -0,0 +1,125 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package miner
+
+import (
+	"crypto/ecdsa"
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// orderingTx is a transaction of a test account, arriving at the given second
+// and paying the given gas price.
+type orderingTx struct {
+	account int
+	arrival int64
+	price   int64
+}
+
+// Tests that the ordering policies yield the pending transactions in the
+// expected order, always honouring the nonces of each account.
+func TestTxOrdering(t *testing.T) {
+	// Account 0 floods the pool early with cheap transactions, account 1 pays
+	// well for late ones, account 2 sends in between
+	pool := []orderingTx{
+		{0, 1, 1}, {0, 2, 1}, {0, 3, 1},
+		{1, 7, 10}, {1, 8, 10},
+		{2, 4, 5}, {2, 9, 5},
+	}
+	tests := []struct {
+		policy TxOrdering
+		want   []orderingTx
+	}{
+		{OrderByPrice, []orderingTx{{1, 7, 10}, {1, 8, 10}, {2, 4, 5}, {2, 9, 5}, {0, 1, 1}, {0, 2, 1}, {0, 3, 1}}},
+		{OrderByArrival, []orderingTx{{0, 1, 1}, {0, 2, 1}, {0, 3, 1}, {2, 4, 5}, {1, 7, 10}, {1, 8, 10}, {2, 9, 5}}},
+		{OrderFairShare, []orderingTx{{0, 1, 1}, {2, 4, 5}, {1, 7, 10}, {0, 2, 1}, {2, 9, 5}, {1, 8, 10}, {0, 3, 1}}},
+	}
+	keys := make([]*ecdsa.PrivateKey, 3)
+	for i := range keys {
+		keys[i], _ = crypto.GenerateKey()
+	}
+	signer := types.HomesteadSigner{}
+
+	for _, tt := range tests {
+		var (
+			txs      = make(map[common.Address]types.Transactions)
+			arrivals = make(map[common.Hash]time.Time)
+			origin   = make(map[common.Hash]orderingTx)
+		)
+		for _, ptx := range pool {
+			addr := crypto.PubkeyToAddress(keys[ptx.account].PublicKey)
+			tx, _ := types.SignTx(types.NewTransaction(uint64(len(txs[addr])), common.Address{}, big.NewInt(0), 21000, big.NewInt(ptx.price), nil), signer, keys[ptx.account])
+
+			txs[addr] = append(txs[addr], tx)
+			arrivals[tx.Hash()] = time.Unix(ptx.arrival, 0)
+			origin[tx.Hash()] = ptx
+		}
+		set := newTxSet(tt.policy, signer, txs, arrivals)
+
+		var have []orderingTx
+		for tx := set.Peek(); tx != nil; tx = set.Peek() {
+			have = append(have, origin[tx.Hash()])
+			set.Shift()
+		}
+		if !reflect.DeepEqual(have, tt.want) {
+			t.Errorf("%s: order mismatch:\nhave %v\nwant %v", tt.policy, have, tt.want)
+		}
+	}
+}
+
+// Tests that popping an account drops all its remaining transactions, whatever
+// the ordering policy.
+func TestTxOrderingPop(t *testing.T) {
+	keys := make([]*ecdsa.PrivateKey, 2)
+	for i := range keys {
+		keys[i], _ = crypto.GenerateKey()
+	}
+	signer := types.HomesteadSigner{}
+
+	for _, policy := range []TxOrdering{OrderByPrice, OrderByArrival, OrderFairShare} {
+		txs := make(map[common.Address]types.Transactions)
+		for i, key := range keys {
+			addr := crypto.PubkeyToAddress(key.PublicKey)
+			for nonce := uint64(0); nonce < 3; nonce++ {
+				tx, _ := types.SignTx(types.NewTransaction(nonce, common.Address{}, big.NewInt(0), 21000, big.NewInt(int64(2-i)), nil), signer, key)
+				txs[addr] = append(txs[addr], tx)
+			}
+		}
+		set := newTxSet(policy, signer, txs, nil)
+
+		first, _ := types.Sender(signer, set.Peek())
+		set.Pop()
+
+		count := 0
+		for tx := set.Peek(); tx != nil; tx = set.Peek() {
+			if from, _ := types.Sender(signer, tx); from == first {
+				t.Errorf("%s: transaction of popped account yielded", policy)
+			}
+			count++
+			set.Shift()
+		}
+		if count != 3 {
+			t.Errorf("%s: transaction count mismatch: have %d, want 3", policy, count)
+		}
+	}
+}
@@ -113,6 +113,7 @@ type worker struct {
 
 	coinbase common.Address
 	extra    []byte
+	ordering TxOrdering // policy ordering the pending transactions in new blocks
 
 	currentMu sync.Mutex
 	current   *Work
@@ -144,6 +145,7 @@ func newWorker(config *params.ChainConfig, engine consensus.Engine, coinbase com
 		coinbase:       coinbase,
 		agents:         make(map[Agent]struct{}),
 		unconfirmed:    newUnconfirmedBlocks(eth.BlockChain(), miningLogAtDepth),
+		ordering:       OrderByPrice,
 	}
 	// Subscribe TxPreEvent for tx pool
 	worker.txSub = eth.TxPool().SubscribeTxPreEvent(worker.txCh)
@@ -164,6 +166,12 @@ func (self *worker) setEtherbase(addr common.Address) {
 	self.coinbase = addr
 }
 
+func (self *worker) setTxOrdering(ordering TxOrdering) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	self.ordering = ordering
+}
+
 func (self *worker) setExtra(extra []byte) {
 	self.mu.Lock()
 	defer self.mu.Unlock()
@@ -286,6 +294,10 @@ func (self *worker) update() {
 				if self.config.Clique != nil && self.config.Clique.Period == 0 {
 					self.commitNewWork()
 				}
+				// If the engine is holding back an empty block, hand it one with transactions
+				if s, ok := self.engine.(consensus.EmptyBlockSuppressor); ok && s.SuppressEmptyBlocks() && self.pendingEmpty() {
+					self.commitNewWork()
+				}
 			}
 
 		// System stopped
@@ -299,6 +311,14 @@ func (self *worker) update() {
 	}
 }
 
+// pendingEmpty returns whether the work currently being mined has no transactions.
+func (self *worker) pendingEmpty() bool {
+	self.currentMu.Lock()
+	defer self.currentMu.Unlock()
+
+	return self.current != nil && self.current.tcount == 0
+}
+
 func (self *worker) wait() {
 	for {
 		mustCommitNewWork := true
@@ -465,7 +485,11 @@ func (self *worker) commitNewWork() {
 		log.Error("Failed to fetch pending transactions", "err", err)
 		return
 	}
-	txs := types.NewTransactionsByPriceAndNonce(self.current.signer, pending)
+	var arrivals map[common.Hash]time.Time
+	if self.ordering != OrderByPrice {
+		arrivals = self.eth.TxPool().Arrivals()
+	}
+	txs := newTxSet(self.ordering, self.current.signer, pending, arrivals)
 	work.commitTransactions(self.mux, txs, self.chain, self.coinbase)
 
 	// compute uncles for the new block.
@@ -491,7 +515,7 @@ func (self *worker) commitNewWork() {
 		delete(self.possibleUncles, hash)
 	}
 	// Create the new block to seal with the consensus engine
-	if work.Block, err = self.engine.Finalize(self.chain, header, work.state, work.txs, uncles, work.receipts); err != nil {
+	if work.Block, work.receipts, err = consensus.Finalize(self.engine, self.chain, header, work.state, work.txs, uncles, work.receipts); err != nil {
 		log.Error("Failed to finalize block for sealing", "err", err)
 		return
 	}
@@ -518,7 +542,7 @@ func (self *worker) commitUncle(work *Work, uncle *types.Header) error {
 	return nil
 }
 
-func (env *Work) commitTransactions(mux *event.TypeMux, txs *types.TransactionsByPriceAndNonce, bc *core.BlockChain, coinbase common.Address) {
+func (env *Work) commitTransactions(mux *event.TypeMux, txs txSet, bc *core.BlockChain, coinbase common.Address) {
 	gp := new(core.GasPool).AddGas(env.header.GasLimit)
 
 	var coalescedLogs []*types.Log
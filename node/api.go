@@ -229,6 +229,30 @@ func (api *PrivateAdminAPI) StopWS() (bool, error) {
 	return true, nil
 }
 
+// ExportConfig returns the effective configuration of the client in the same
+// TOML format accepted by the --config flag.
+func (api *PrivateAdminAPI) ExportConfig() (string, error) {
+	api.node.lock.RLock()
+	exporter := api.node.configExporter
+	api.node.lock.RUnlock()
+
+	if exporter == nil {
+		return "", ErrNoConfigExport
+	}
+	out, err := exporter()
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
+
+// Backup writes a consistent snapshot of the node's data into the given directory
+// while the node keeps running, including key material if keys is set. The backup
+// can be restored via the --restore flag.
+func (api *PrivateAdminAPI) Backup(path string, keys *bool) (*BackupInfo, error) {
+	return api.node.Backup(path, keys != nil && *keys)
+}
+
 // PublicAdminAPI is the collection of administrative API methods exposed over
 // both secure and unsecure RPC channels.
 type PublicAdminAPI struct {
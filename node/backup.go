@@ -0,0 +1,381 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package node
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/syndtr/goleveldb/leveldb"
+)
+
+// backupMarker is the file within a backup describing its contents.
+const backupMarker = "backup.json"
+
+var (
+	errBackupEphemeral = errors.New("ephemeral node has no data directory")
+	errNotBackup       = errors.New("not a node backup")
+)
+
+// Snapshotter is implemented by services storing data outside of the databases
+// opened through the node, allowing them to add it to hot backups.
+type Snapshotter interface {
+	Snapshot(backup *Backup) error
+}
+
+// BackupInfo describes the contents of a node backup.
+type BackupInfo struct {
+	Time      time.Time `json:"time"`      // Time the backup was taken at
+	Databases []string  `json:"databases"` // Databases within the backup, relative to its root
+	Files     []string  `json:"files"`     // Plain files within the backup, relative to its root
+	Keys      bool      `json:"keys"`      // Whether the backup contains key material
+}
+
+// Backup is a snapshot of a running node being written into a directory which
+// mirrors the layout of the data directory.
+type Backup struct {
+	dir     string // Directory the backup is written into
+	datadir string // Data directory of the node being backed up
+	info    *BackupInfo
+}
+
+// newBackup creates the directory of a new backup, which must not exist yet.
+func newBackup(dir string, datadir string, keys bool) (*Backup, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(dir); err == nil {
+		return nil, fmt.Errorf("backup directory %s already exists", dir)
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &Backup{
+		dir:     dir,
+		datadir: datadir,
+		info:    &BackupInfo{Time: time.Now(), Keys: keys},
+	}, nil
+}
+
+// relPath returns the location of a path within the data directory relative to
+// the root of the data directory, and hence of the backup.
+func (b *Backup) relPath(path string) (string, error) {
+	rel, err := filepath.Rel(b.datadir, path)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s is outside of the data directory", path)
+	}
+	return rel, nil
+}
+
+// CopyLevelDB copies a point-in-time snapshot of a live LevelDB database, found
+// at path within the data directory, into the backup.
+func (b *Backup) CopyLevelDB(db *leveldb.DB, path string) error {
+	rel, err := b.relPath(path)
+	if err != nil {
+		return err
+	}
+	snap, err := db.GetSnapshot()
+	if err != nil {
+		return err
+	}
+	defer snap.Release()
+
+	dst, err := leveldb.OpenFile(filepath.Join(b.dir, rel), nil)
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+
+	it := snap.NewIterator(nil, nil)
+	defer it.Release()
+
+	batch, size := new(leveldb.Batch), 0
+	for it.Next() {
+		batch.Put(it.Key(), it.Value())
+		if size += len(it.Key()) + len(it.Value()); size >= ethdb.IdealBatchSize {
+			if err := dst.Write(batch, nil); err != nil {
+				return err
+			}
+			batch.Reset()
+			size = 0
+		}
+	}
+	if err := it.Error(); err != nil {
+		return err
+	}
+	if err := dst.Write(batch, nil); err != nil {
+		return err
+	}
+	b.info.Databases = append(b.info.Databases, rel)
+	return nil
+}
+
+// CopyFile copies a file found at path within the data directory into the backup.
+func (b *Backup) CopyFile(path string) error {
+	rel, err := b.relPath(path)
+	if err != nil {
+		return err
+	}
+	return b.copyFile(path, rel)
+}
+
+// copyFile copies a file into the backup at the given relative location.
+func (b *Backup) copyFile(path string, rel string) error {
+	if err := copyFile(path, filepath.Join(b.dir, rel)); err != nil {
+		return err
+	}
+	b.info.Files = append(b.info.Files, rel)
+	return nil
+}
+
+// finish writes the description of the backup, marking it complete.
+func (b *Backup) finish() error {
+	blob, err := json.MarshalIndent(b.info, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(b.dir, backupMarker), blob, 0600)
+}
+
+// Backup writes a consistent snapshot of the data of the running node into dir,
+// which must not exist yet. Databases are copied from point-in-time snapshots,
+// so the node keeps serving while the backup is taken. Key material (the node
+// key and the keystore) is only included if keys is set.
+//
+// The node can't be stopped while a backup is in progress.
+func (n *Node) Backup(dir string, keys bool) (*BackupInfo, error) {
+	n.lock.RLock()
+	defer n.lock.RUnlock()
+
+	if n.server == nil {
+		return nil, ErrNodeStopped
+	}
+	if n.config.DataDir == "" {
+		return nil, errBackupEphemeral
+	}
+	b, err := newBackup(dir, n.config.DataDir, keys)
+	if err != nil {
+		return nil, err
+	}
+	if err := n.backup(b); err != nil {
+		os.RemoveAll(b.dir)
+		return nil, err
+	}
+	n.log.Info("Backed up node", "dir", b.dir, "databases", len(b.info.Databases), "files", len(b.info.Files), "keys", keys)
+	return b.info, nil
+}
+
+// backup writes the data of the node and its tenants into b.
+func (n *Node) backup(b *Backup) error {
+	// Snapshot the databases opened by the node and its services
+	for _, db := range n.databases.list() {
+		if err := b.CopyLevelDB(db.LDB(), db.Path()); err != nil {
+			if err == leveldb.ErrClosed {
+				n.databases.remove(db)
+				continue
+			}
+			return fmt.Errorf("failed to back up database %s: %v", db.Path(), err)
+		}
+	}
+	// Let the services storing data elsewhere snapshot it too
+	services := []map[reflect.Type]Service{n.services}
+	configs := []*Config{n.config}
+	for _, t := range n.tenants {
+		services = append(services, t.services)
+		configs = append(configs, n.tenantConfig(t.name))
+	}
+	for _, running := range services {
+		for kind, service := range running {
+			if snapshotter, ok := service.(Snapshotter); ok {
+				if err := snapshotter.Snapshot(b); err != nil {
+					return fmt.Errorf("failed to back up service %v: %v", kind, err)
+				}
+			}
+		}
+	}
+	// Copy the node lists, along with the node keys if requested
+	for _, config := range configs {
+		files := []string{datadirStaticNodes, datadirTrustedNodes}
+		if b.info.Keys {
+			files = append(files, datadirPrivateKey)
+		}
+		for _, file := range files {
+			if err := b.CopyFile(config.resolvePath(file)); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+	// Copy the keystore if requested, wherever it's located
+	if b.info.Keys {
+		_, _, keydir, err := n.config.AccountConfig()
+		if err != nil {
+			return err
+		}
+		files, err := ioutil.ReadDir(keydir)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		for _, file := range files {
+			if !file.Mode().IsRegular() {
+				continue
+			}
+			if err := b.copyFile(filepath.Join(keydir, file.Name()), filepath.Join(datadirDefaultKeyStore, file.Name())); err != nil {
+				return err
+			}
+		}
+	}
+	return b.finish()
+}
+
+// Restore copies a backup created by Node.Backup into the data directory of the
+// given configuration. Existing data is never overwritten, so a backup should be
+// restored into a fresh data directory before the node is started.
+func Restore(dir string, config *Config) error {
+	if config.DataDir == "" {
+		return errBackupEphemeral
+	}
+	blob, err := ioutil.ReadFile(filepath.Join(dir, backupMarker))
+	if err != nil {
+		return errNotBackup
+	}
+	info := new(BackupInfo)
+	if err := json.Unmarshal(blob, info); err != nil {
+		return fmt.Errorf("%v: %v", errNotBackup, err)
+	}
+	_, _, keydir, err := config.AccountConfig()
+	if err != nil {
+		return err
+	}
+	// Resolve where everything goes, making sure nothing gets overwritten
+	target := func(rel string) string {
+		if info.Keys && strings.HasPrefix(rel, datadirDefaultKeyStore+string(filepath.Separator)) {
+			return filepath.Join(keydir, strings.TrimPrefix(rel, datadirDefaultKeyStore))
+		}
+		return filepath.Join(config.DataDir, rel)
+	}
+	copies := make(map[string]string)
+	for _, rel := range info.Databases {
+		if _, err := os.Stat(target(rel)); err == nil {
+			return fmt.Errorf("database %s already exists", target(rel))
+		}
+		files, err := ioutil.ReadDir(filepath.Join(dir, rel))
+		if err != nil {
+			return err
+		}
+		for _, file := range files {
+			copies[filepath.Join(dir, rel, file.Name())] = filepath.Join(target(rel), file.Name())
+		}
+	}
+	for _, rel := range info.Files {
+		if _, err := os.Stat(target(rel)); err == nil {
+			return fmt.Errorf("file %s already exists", target(rel))
+		}
+		copies[filepath.Join(dir, rel)] = target(rel)
+	}
+	for src, dst := range copies {
+		if err := copyFile(src, dst); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyFile copies the file at src to dst, creating the parent directories of
+// the destination as needed.
+func copyFile(src string, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	stat, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0700); err != nil {
+		return err
+	}
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, stat.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// databaseSet tracks the LevelDB databases opened through the node, so that hot
+// backups can snapshot them.
+type databaseSet struct {
+	dbs  map[*ethdb.LDBDatabase]struct{}
+	lock sync.Mutex
+}
+
+// newDatabaseSet creates an empty set of tracked databases.
+func newDatabaseSet() *databaseSet {
+	return &databaseSet{dbs: make(map[*ethdb.LDBDatabase]struct{})}
+}
+
+// add starts tracking a database.
+func (s *databaseSet) add(db *ethdb.LDBDatabase) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.dbs[db] = struct{}{}
+}
+
+// remove stops tracking a database.
+func (s *databaseSet) remove(db *ethdb.LDBDatabase) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	delete(s.dbs, db)
+}
+
+// list returns the tracked databases.
+func (s *databaseSet) list() []*ethdb.LDBDatabase {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	dbs := make([]*ethdb.LDBDatabase, 0, len(s.dbs))
+	for db := range s.dbs {
+		dbs = append(dbs, db)
+	}
+	return dbs
+}
+
+// reset stops tracking all databases.
+func (s *databaseSet) reset() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.dbs = make(map[*ethdb.LDBDatabase]struct{})
+}
@@ -0,0 +1,118 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package node
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/ethdb"
+)
+
+// Tests that a running node can be backed up and the backup restored into a new
+// data directory, containing the data at the time of the backup.
+func TestBackupRestore(t *testing.T) {
+	root, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(root)
+
+	config := testNodeConfig()
+	config.DataDir = filepath.Join(root, "datadir")
+
+	stack, err := New(config)
+	if err != nil {
+		t.Fatalf("failed to create protocol stack: %v", err)
+	}
+	var db ethdb.Database
+	constructor := func(ctx *ServiceContext) (Service, error) {
+		if db, err = ctx.OpenDatabase("chaindata", 0, 0); err != nil {
+			return nil, err
+		}
+		return new(NoopService), nil
+	}
+	if err := stack.Register(constructor); err != nil {
+		t.Fatalf("failed to register service: %v", err)
+	}
+	if _, err := stack.Backup(filepath.Join(root, "backup"), true); err != ErrNodeStopped {
+		t.Fatalf("backup of stopped node error mismatch: have %v, want %v", err, ErrNodeStopped)
+	}
+	if err := stack.Start(); err != nil {
+		t.Fatalf("failed to start protocol stack: %v", err)
+	}
+	defer stack.Stop()
+
+	db.Put([]byte("before"), []byte("backup"))
+	static := []byte(`["enode://fe@127.0.0.1:30303"]`)
+	if err := ioutil.WriteFile(config.resolvePath(datadirStaticNodes), static, 0600); err != nil {
+		t.Fatalf("failed to write static nodes: %v", err)
+	}
+	os.MkdirAll(filepath.Join(config.DataDir, datadirDefaultKeyStore), 0700)
+	if err := ioutil.WriteFile(filepath.Join(config.DataDir, datadirDefaultKeyStore, "key"), []byte("key"), 0600); err != nil {
+		t.Fatalf("failed to write key file: %v", err)
+	}
+	// Back up the node and make sure later changes are not included
+	info, err := stack.Backup(filepath.Join(root, "backup"), true)
+	if err != nil {
+		t.Fatalf("failed to back up node: %v", err)
+	}
+	db.Put([]byte("after"), []byte("backup"))
+
+	if len(info.Databases) != 1 || info.Databases[0] != filepath.Join("test node", "chaindata") {
+		t.Errorf("backed up databases mismatch: have %v", info.Databases)
+	}
+	if !info.Keys || len(info.Files) != 2 {
+		t.Errorf("backed up files mismatch: have %v, keys %v", info.Files, info.Keys)
+	}
+	if _, err := stack.Backup(filepath.Join(root, "backup"), false); err == nil {
+		t.Fatalf("backup overwrote existing directory")
+	}
+	// Restore the backup into a new data directory and check its contents
+	restored := testNodeConfig()
+	restored.DataDir = filepath.Join(root, "restored")
+	if err := Restore(filepath.Join(root, "backup"), restored); err != nil {
+		t.Fatalf("failed to restore backup: %v", err)
+	}
+	if err := Restore(filepath.Join(root, "backup"), restored); err == nil {
+		t.Fatalf("restore overwrote existing data")
+	}
+	if err := Restore(root, restored); err != errNotBackup {
+		t.Fatalf("restore of non-backup error mismatch: have %v, want %v", err, errNotBackup)
+	}
+	rdb, err := ethdb.NewLDBDatabase(restored.resolvePath("chaindata"), 0, 0)
+	if err != nil {
+		t.Fatalf("failed to open restored database: %v", err)
+	}
+	defer rdb.Close()
+
+	if val, err := rdb.Get([]byte("before")); err != nil || !bytes.Equal(val, []byte("backup")) {
+		t.Errorf("restored value mismatch: have %q, %v", val, err)
+	}
+	if ok, _ := rdb.Has([]byte("after")); ok {
+		t.Errorf("value written after backup restored")
+	}
+	if blob, err := ioutil.ReadFile(restored.resolvePath(datadirStaticNodes)); err != nil || !bytes.Equal(blob, static) {
+		t.Errorf("restored static nodes mismatch: have %q, %v", blob, err)
+	}
+	if blob, err := ioutil.ReadFile(filepath.Join(restored.DataDir, datadirDefaultKeyStore, "key")); err != nil || string(blob) != "key" {
+		t.Errorf("restored key file mismatch: have %q, %v", blob, err)
+	}
+}
@@ -24,8 +24,10 @@ import (
 	"path/filepath"
 	"runtime"
 	"strings"
+	"time"
 
 	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/external"
 	"github.com/ethereum/go-ethereum/accounts/keystore"
 	"github.com/ethereum/go-ethereum/accounts/usbwallet"
 	"github.com/ethereum/go-ethereum/common"
@@ -33,6 +35,7 @@ import (
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/p2p"
 	"github.com/ethereum/go-ethereum/p2p/discover"
+	"github.com/ethereum/go-ethereum/rpc"
 )
 
 const (
@@ -82,9 +85,20 @@ type Config struct {
 	// scrypt KDF at the expense of security.
 	UseLightweightKDF bool `toml:",omitempty"`
 
+	// KeyStoreKDF selects the KDF new and updated keys are encrypted with, scrypt
+	// or argon2id, and overrides the parameters its non-zero fields are set for.
+	// Unset parameters default to the standard or, if UseLightweightKDF is set,
+	// the light ones.
+	KeyStoreKDF keystore.KDFParams `toml:",omitempty"`
+
 	// NoUSB disables hardware wallet monitoring and connectivity.
 	NoUSB bool `toml:",omitempty"`
 
+	// ExternalSigner is the endpoint (IPC path or HTTP URL) of an external signer
+	// to which all account signing requests are forwarded. If set, no keys need
+	// to be kept in the node's own keystore.
+	ExternalSigner string `toml:",omitempty"`
+
 	// IPCPath is the requested location to place the IPC endpoint. If the path is
 	// a simple file name, it is placed inside the data directory (or on the root
 	// pipe path on Windows), whereas if it's a resolvable path name (absolute or
@@ -119,6 +133,10 @@ type Config struct {
 	// exposed.
 	HTTPModules []string `toml:",omitempty"`
 
+	// HTTPOptions tunes the timeouts, keep-alive connections and compression of
+	// the HTTP RPC server.
+	HTTPOptions rpc.HTTPOptions `toml:",omitempty"`
+
 	// WSHost is the host interface on which to start the websocket RPC server. If
 	// this field is empty, no websocket API endpoint will be started.
 	WSHost string `toml:",omitempty"`
@@ -145,6 +163,23 @@ type Config struct {
 	// private APIs to untrusted users is a major security risk.
 	WSExposeAll bool `toml:",omitempty"`
 
+	// RPCAuthFile is the path of a JSON file holding the JWT secret and per-token
+	// access policies. If set, every HTTP and websocket RPC request must carry a
+	// valid bearer token and may only invoke the methods its policy allows.
+	RPCAuthFile string `toml:",omitempty"`
+
+	// RPCLimits bounds the resources a single IPC or websocket RPC connection may
+	// consume (batch size, response size, concurrency and request rate).
+	RPCLimits rpc.ServerLimits `toml:",omitempty"`
+
+	// RPCSlowQuery is the execution time above which IPC, HTTP and websocket RPC
+	// calls are logged along with their client and parameters. Zero disables it.
+	RPCSlowQuery time.Duration `toml:",omitempty"`
+
+	// Tenants configures the p2p servers of the tenant chains hosted by the node,
+	// keyed by tenant name. Tenants without any configuration only dial out.
+	Tenants map[string]TenantConfig `toml:",omitempty"`
+
 	// Logger is a custom logger to use with the p2p.Server.
 	Logger log.Logger `toml:",omitempty"`
 }
@@ -400,8 +435,39 @@ func (c *Config) AccountConfig() (int, int, string, error) {
 	return scryptN, scryptP, keydir, err
 }
 
+// KDFConfig determines the KDF and its parameters keys are encrypted with.
+func (c *Config) KDFConfig() keystore.KDFParams {
+	kdf := keystore.StandardKDF
+	if c.UseLightweightKDF {
+		kdf = keystore.LightKDF
+	}
+	if c.KeyStoreKDF.KDF != "" {
+		kdf.KDF = c.KeyStoreKDF.KDF
+	}
+	if c.KeyStoreKDF.ScryptN != 0 {
+		kdf.ScryptN = c.KeyStoreKDF.ScryptN
+	}
+	if c.KeyStoreKDF.ScryptP != 0 {
+		kdf.ScryptP = c.KeyStoreKDF.ScryptP
+	}
+	if c.KeyStoreKDF.Argon2Time != 0 {
+		kdf.Argon2Time = c.KeyStoreKDF.Argon2Time
+	}
+	if c.KeyStoreKDF.Argon2Memory != 0 {
+		kdf.Argon2Memory = c.KeyStoreKDF.Argon2Memory
+	}
+	if c.KeyStoreKDF.Argon2Threads != 0 {
+		kdf.Argon2Threads = c.KeyStoreKDF.Argon2Threads
+	}
+	return kdf
+}
+
 func makeAccountManager(conf *Config) (*accounts.Manager, string, error) {
-	scryptN, scryptP, keydir, err := conf.AccountConfig()
+	_, _, keydir, err := conf.AccountConfig()
+	kdf := conf.KDFConfig()
+	if err := kdf.Validate(); err != nil {
+		return nil, "", fmt.Errorf("invalid key store KDF: %v", err)
+	}
 	var ephemeral string
 	if keydir == "" {
 		// There is no datadir.
@@ -417,7 +483,15 @@ func makeAccountManager(conf *Config) (*accounts.Manager, string, error) {
 	}
 	// Assemble the account manager and supported backends
 	backends := []accounts.Backend{
-		keystore.NewKeyStore(keydir, scryptN, scryptP),
+		keystore.NewKeyStoreWithKDF(keydir, kdf),
+	}
+	if conf.ExternalSigner != "" {
+		// Forward signing requests to the external signer
+		extapi, err := external.NewExternalBackend(conf.ExternalSigner)
+		if err != nil {
+			return nil, "", fmt.Errorf("error connecting to external signer: %v", err)
+		}
+		backends = append(backends, extapi)
 	}
 	if !conf.NoUSB {
 		// Start a USB hub for Ledger hardware wallets
@@ -28,6 +28,7 @@ var (
 	ErrNodeStopped    = errors.New("node not started")
 	ErrNodeRunning    = errors.New("node already running")
 	ErrServiceUnknown = errors.New("unknown service")
+	ErrNoConfigExport = errors.New("configuration export not supported")
 
 	datadirInUseErrnos = map[uint]bool{11: true, 32: true, 35: true}
 )
@@ -55,9 +56,13 @@ func (e *DuplicateServiceError) Error() string {
 type StopError struct {
 	Server   error
 	Services map[reflect.Type]error
+	Tenants  map[string]map[reflect.Type]error
 }
 
 // Error generates a textual representation of the stop error.
 func (e *StopError) Error() string {
+	if len(e.Tenants) > 0 {
+		return fmt.Sprintf("server: %v, services: %v, tenants: %v", e.Server, e.Services, e.Tenants)
+	}
 	return fmt.Sprintf("server: %v, services: %v", e.Server, e.Services)
 }
@@ -50,6 +50,8 @@ type Node struct {
 
 	serviceFuncs []ServiceConstructor     // Service constructors (in dependency order)
 	services     map[reflect.Type]Service // Currently running services
+	tenants      []*tenant                // Independent chains hosted by the node (in registration order)
+	databases    *databaseSet             // Databases opened through the node, snapshotted by backups
 
 	rpcAPIs       []rpc.API   // List of APIs currently provided by the node
 	inprocHandler *rpc.Server // In-process RPC request handler to process the API requests
@@ -67,6 +69,10 @@ type Node struct {
 	wsListener net.Listener // Websocket RPC listener socket to server API requests
 	wsHandler  *rpc.Server  // Websocket RPC request handler to process the API requests
 
+	rpcAuth *rpc.Authenticator // Token authenticator guarding the HTTP and websocket endpoints (nil = open)
+
+	configExporter func() ([]byte, error) // Serializer of the effective client configuration (nil = unsupported)
+
 	stop chan struct{} // Channel to wait for termination notifications
 	lock sync.RWMutex
 
@@ -117,6 +123,7 @@ func New(conf *Config) (*Node, error) {
 		httpEndpoint:      conf.HTTPEndpoint(),
 		wsEndpoint:        conf.WSEndpoint(),
 		eventmux:          new(event.TypeMux),
+		databases:         newDatabaseSet(),
 		log:               conf.Logger,
 	}, nil
 }
@@ -166,57 +173,74 @@ func (n *Node) Start() error {
 	n.log.Info("Starting peer-to-peer node", "instance", n.serverConfig.Name)
 
 	// Otherwise copy and specialize the P2P configuration
-	services := make(map[reflect.Type]Service)
-	for _, constructor := range n.serviceFuncs {
-		// Create a new context for the particular service
-		ctx := &ServiceContext{
-			config:         n.config,
-			services:       make(map[reflect.Type]Service),
-			EventMux:       n.eventmux,
-			AccountManager: n.accman,
-		}
-		for kind, s := range services { // copy needed for threaded access
-			ctx.services[kind] = s
-		}
-		// Construct and save the service
-		service, err := constructor(ctx)
-		if err != nil {
+	services, err := n.constructServices(n.config, n.eventmux, n.serviceFuncs)
+	if err != nil {
+		return err
+	}
+	// Construct the services of the tenants, each within its own environment and
+	// with its own p2p server
+	for _, t := range n.tenants {
+		config := n.tenantConfig(t.name)
+		if t.services, err = n.constructServices(config, new(event.TypeMux), t.serviceFuncs); err != nil {
 			return err
 		}
-		kind := reflect.TypeOf(service)
-		if _, exists := services[kind]; exists {
-			return &DuplicateServiceError{Kind: kind}
-		}
-		services[kind] = service
+		t.server = &p2p.Server{Config: config.P2P}
+		t.server.Protocols = t.protocols()
 	}
-	// Gather the protocols and start the freshly assembled P2P server
+	// Gather the protocols and start the freshly assembled P2P servers
 	for _, service := range services {
 		running.Protocols = append(running.Protocols, service.Protocols()...)
 	}
-	if err := running.Start(); err != nil {
-		return convertFileLockError(err)
+	servers := []*p2p.Server{running}
+	for _, t := range n.tenants {
+		servers = append(servers, t.server)
+	}
+	stopServers := func() {
+		for _, server := range servers {
+			server.Stop()
+		}
+	}
+	for i, server := range servers {
+		if err := server.Start(); err != nil {
+			servers = servers[:i]
+			stopServers()
+			return convertFileLockError(err)
+		}
+	}
+	// Start each of the services on the server of their chain
+	type instance struct {
+		service Service
+		server  *p2p.Server
+	}
+	all := make([]instance, 0, len(services))
+	for _, service := range services {
+		all = append(all, instance{service, running})
+	}
+	for _, t := range n.tenants {
+		for _, service := range t.services {
+			all = append(all, instance{service, t.server})
+		}
 	}
-	// Start each of the services
-	started := []reflect.Type{}
-	for kind, service := range services {
+	started := []Service{}
+	for _, inst := range all {
 		// Start the next service, stopping all previous upon failure
-		if err := service.Start(running); err != nil {
-			for _, kind := range started {
-				services[kind].Stop()
+		if err := inst.service.Start(inst.server); err != nil {
+			for _, service := range started {
+				service.Stop()
 			}
-			running.Stop()
+			stopServers()
 
 			return err
 		}
 		// Mark the service started for potential cleanup
-		started = append(started, kind)
+		started = append(started, inst.service)
 	}
 	// Lastly start the configured RPC interfaces
 	if err := n.startRPC(services); err != nil {
-		for _, service := range services {
+		for _, service := range started {
 			service.Stop()
 		}
-		running.Stop()
+		stopServers()
 		return err
 	}
 	// Finish initializing the startup
@@ -255,6 +279,17 @@ func (n *Node) startRPC(services map[reflect.Type]Service) error {
 	for _, service := range services {
 		apis = append(apis, service.APIs()...)
 	}
+	for _, t := range n.tenants {
+		apis = append(apis, t.apis()...)
+	}
+	// Load the access policies of the public facing endpoints, if any
+	if n.config.RPCAuthFile != "" {
+		auth, err := rpc.LoadAuthConfig(n.config.RPCAuthFile)
+		if err != nil {
+			return err
+		}
+		n.rpcAuth = auth
+	}
 	// Start the various API endpoints, terminating all in case of errors
 	if err := n.startInProc(apis); err != nil {
 		return err
@@ -309,6 +344,8 @@ func (n *Node) startIPC(apis []rpc.API) error {
 	}
 	// Register all the APIs exposed by the services
 	handler := rpc.NewServer()
+	handler.SetLimits(n.config.RPCLimits)
+	handler.SetSlowQueryThreshold(n.config.RPCSlowQuery)
 	for _, api := range apis {
 		if err := handler.RegisterName(api.Namespace, api.Service); err != nil {
 			return err
@@ -377,8 +414,9 @@ func (n *Node) startHTTP(endpoint string, apis []rpc.API, modules []string, cors
 	}
 	// Register all the APIs exposed by the services
 	handler := rpc.NewServer()
+	handler.SetSlowQueryThreshold(n.config.RPCSlowQuery)
 	for _, api := range apis {
-		if whitelist[api.Namespace] || (len(whitelist) == 0 && api.Public) {
+		if whitelist[api.Namespace] || whitelist[n.apiModule(api.Namespace)] || (len(whitelist) == 0 && api.Public) {
 			if err := handler.RegisterName(api.Namespace, api.Service); err != nil {
 				return err
 			}
@@ -393,7 +431,11 @@ func (n *Node) startHTTP(endpoint string, apis []rpc.API, modules []string, cors
 	if listener, err = net.Listen("tcp", endpoint); err != nil {
 		return err
 	}
-	go rpc.NewHTTPServer(cors, vhosts, handler).Serve(listener)
+	server := rpc.NewHTTPServer(cors, vhosts, n.config.HTTPOptions, handler)
+	if n.rpcAuth != nil {
+		server.Handler = n.rpcAuth.Handler(server.Handler)
+	}
+	go server.Serve(listener)
 	n.log.Info("HTTP endpoint opened", "url", fmt.Sprintf("http://%s", endpoint), "cors", strings.Join(cors, ","), "vhosts", strings.Join(vhosts, ","))
 	// All listeners booted successfully
 	n.httpEndpoint = endpoint
@@ -430,8 +472,10 @@ func (n *Node) startWS(endpoint string, apis []rpc.API, modules []string, wsOrig
 	}
 	// Register all the APIs exposed by the services
 	handler := rpc.NewServer()
+	handler.SetLimits(n.config.RPCLimits)
+	handler.SetSlowQueryThreshold(n.config.RPCSlowQuery)
 	for _, api := range apis {
-		if exposeAll || whitelist[api.Namespace] || (len(whitelist) == 0 && api.Public) {
+		if exposeAll || whitelist[api.Namespace] || whitelist[n.apiModule(api.Namespace)] || (len(whitelist) == 0 && api.Public) {
 			if err := handler.RegisterName(api.Namespace, api.Service); err != nil {
 				return err
 			}
@@ -446,7 +490,11 @@ func (n *Node) startWS(endpoint string, apis []rpc.API, modules []string, wsOrig
 	if listener, err = net.Listen("tcp", endpoint); err != nil {
 		return err
 	}
-	go rpc.NewWSServer(wsOrigins, handler).Serve(listener)
+	server := rpc.NewWSServer(wsOrigins, handler)
+	if n.rpcAuth != nil {
+		server.Handler = n.rpcAuth.Handler(server.Handler)
+	}
+	go server.Serve(listener)
 	n.log.Info("WebSocket endpoint opened", "url", fmt.Sprintf("ws://%s", listener.Addr()))
 
 	// All listeners booted successfully
@@ -489,15 +537,29 @@ func (n *Node) Stop() error {
 	n.rpcAPIs = nil
 	failure := &StopError{
 		Services: make(map[reflect.Type]error),
+		Tenants:  make(map[string]map[reflect.Type]error),
 	}
 	for kind, service := range n.services {
 		if err := service.Stop(); err != nil {
 			failure.Services[kind] = err
 		}
 	}
+	for _, t := range n.tenants {
+		for kind, service := range t.services {
+			if err := service.Stop(); err != nil {
+				if failure.Tenants[t.name] == nil {
+					failure.Tenants[t.name] = make(map[reflect.Type]error)
+				}
+				failure.Tenants[t.name][kind] = err
+			}
+		}
+		t.server.Stop()
+		t.services, t.server = nil, nil
+	}
 	n.server.Stop()
 	n.services = nil
 	n.server = nil
+	n.databases.reset()
 
 	// Release instance directory lock.
 	if n.instanceDirLock != nil {
@@ -516,7 +578,7 @@ func (n *Node) Stop() error {
 		keystoreErr = os.RemoveAll(n.ephemeralKeystore)
 	}
 
-	if len(failure.Services) > 0 {
+	if len(failure.Services) > 0 || len(failure.Tenants) > 0 {
 		return failure
 	}
 	if keystoreErr != nil {
@@ -607,6 +669,15 @@ func (n *Node) DataDir() string {
 	return n.config.DataDir
 }
 
+// SetConfigExporter sets the function used to serialize the effective client
+// configuration (node and all services) for the admin_exportConfig RPC call.
+func (n *Node) SetConfigExporter(exporter func() ([]byte, error)) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	n.configExporter = exporter
+}
+
 // InstanceDir retrieves the instance directory used by the protocol stack.
 func (n *Node) InstanceDir() string {
 	return n.config.instanceDir()
@@ -645,7 +716,12 @@ func (n *Node) OpenDatabase(name string, cache, handles int) (ethdb.Database, er
 	if n.config.DataDir == "" {
 		return ethdb.NewMemDatabase()
 	}
-	return ethdb.NewLDBDatabase(n.config.resolvePath(name), cache, handles)
+	db, err := ethdb.NewLDBDatabase(n.config.resolvePath(name), cache, handles)
+	if err != nil {
+		return nil, err
+	}
+	n.databases.add(db)
+	return db, nil
 }
 
 // ResolvePath returns the absolute path of a resource in the instance directory.
@@ -33,6 +33,7 @@ import (
 type ServiceContext struct {
 	config         *Config
 	services       map[reflect.Type]Service // Index of the already constructed services
+	databases      *databaseSet             // Databases opened through the node (nil = untracked)
 	EventMux       *event.TypeMux           // Event multiplexer used for decoupled notifications
 	AccountManager *accounts.Manager        // Account manager created by the node.
 }
@@ -48,6 +49,9 @@ func (ctx *ServiceContext) OpenDatabase(name string, cache int, handles int) (et
 	if err != nil {
 		return nil, err
 	}
+	if ctx.databases != nil {
+		ctx.databases.add(db)
+	}
 	return db, nil
 }
 
@@ -0,0 +1,236 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package node
+
+import (
+	"crypto/ecdsa"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/discover"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+const (
+	datadirTenants = "tenants" // Folder within the datadir hosting the tenant datadirs
+
+	// tenantSeparator separates the tenant name from the RPC namespaces of its
+	// services.
+	tenantSeparator = "."
+)
+
+// tenantNameRegexp is the format tenant names must match, keeping them usable in
+// RPC namespaces, protocol names and paths alike.
+var tenantNameRegexp = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9]*$`)
+
+// TenantConfig is the p2p configuration of a tenant chain. Every tenant runs its
+// own p2p server under its own node key, as consensus engines identifying their
+// validators by node key (e.g. Istanbul) can't share a server between chains.
+type TenantConfig struct {
+	// PrivateKey is the node key of the tenant. If nil, it's loaded from (or
+	// generated into) the tenant's data directory.
+	PrivateKey *ecdsa.PrivateKey `toml:"-"`
+
+	// ListenAddr is the network address the p2p server of the tenant listens on.
+	// If empty, the tenant doesn't listen nor run discovery, only dialing out
+	// to its static nodes.
+	ListenAddr string `toml:",omitempty"`
+
+	// BootstrapNodes are used to discover the rest of the tenant's network.
+	BootstrapNodes []*discover.Node `toml:",omitempty"`
+}
+
+// tenant is an independent chain hosted by the node. Its services run isolated
+// from the others, with their own data directory, node key, event multiplexer,
+// RPC namespaces and p2p server, sharing only the accounts.
+type tenant struct {
+	name         string
+	serviceFuncs []ServiceConstructor     // Service constructors (in dependency order)
+	services     map[reflect.Type]Service // Currently running services
+	server       *p2p.Server              // Currently running p2p server of the tenant
+}
+
+// RegisterTenant injects a new service into the given tenant of the node's stack,
+// creating the tenant if it doesn't exist yet. The service created by the passed
+// constructor must be unique in its type with regard to sibling ones within the
+// same tenant.
+//
+// The RPC namespaces of tenant services are prefixed by "<tenant>.", while their
+// p2p protocols run on the tenant's own server, configured by Config.Tenants.
+func (n *Node) RegisterTenant(name string, constructor ServiceConstructor) error {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	if n.server != nil {
+		return ErrNodeRunning
+	}
+	if !tenantNameRegexp.MatchString(name) {
+		return fmt.Errorf("invalid tenant name %q", name)
+	}
+	for _, t := range n.tenants {
+		if t.name == name {
+			t.serviceFuncs = append(t.serviceFuncs, constructor)
+			return nil
+		}
+	}
+	n.tenants = append(n.tenants, &tenant{name: name, serviceFuncs: []ServiceConstructor{constructor}})
+	return nil
+}
+
+// TenantService retrieves a currently running service of a tenant registered of
+// a specific type.
+func (n *Node) TenantService(name string, service interface{}) error {
+	n.lock.RLock()
+	defer n.lock.RUnlock()
+
+	// Short circuit if the node's not running
+	if n.server == nil {
+		return ErrNodeStopped
+	}
+	// Otherwise try to find the service to return
+	element := reflect.ValueOf(service).Elem()
+	for _, t := range n.tenants {
+		if t.name != name {
+			continue
+		}
+		if running, ok := t.services[element.Type()]; ok {
+			element.Set(reflect.ValueOf(running))
+			return nil
+		}
+	}
+	return ErrServiceUnknown
+}
+
+// TenantServer retrieves the currently running p2p server of a tenant.
+func (n *Node) TenantServer(name string) *p2p.Server {
+	n.lock.RLock()
+	defer n.lock.RUnlock()
+
+	for _, t := range n.tenants {
+		if t.name == name {
+			return t.server
+		}
+	}
+	return nil
+}
+
+// Tenants returns the names of the tenants hosted by the node.
+func (n *Node) Tenants() []string {
+	n.lock.RLock()
+	defer n.lock.RUnlock()
+
+	names := make([]string, len(n.tenants))
+	for i, t := range n.tenants {
+		names[i] = t.name
+	}
+	return names
+}
+
+// tenantConfig derives the configuration of a tenant from the node's one, moving
+// its data directory into a subfolder and assigning it its own node key and p2p
+// server setup.
+func (n *Node) tenantConfig(name string) *Config {
+	config := *n.config
+	if config.DataDir != "" {
+		config.DataDir = filepath.Join(config.DataDir, datadirTenants, name)
+	}
+	tenant := n.config.Tenants[name]
+
+	// Resolve the key once, as ephemeral tenants would get a new one on each call
+	config.P2P.PrivateKey = tenant.PrivateKey
+	config.P2P.PrivateKey = config.NodeKey()
+
+	config.P2P.Name = n.config.NodeName()
+	config.P2P.Logger = n.log.New("tenant", name)
+	config.P2P.ListenAddr = tenant.ListenAddr
+	config.P2P.NoDiscovery = config.P2P.NoDiscovery || tenant.ListenAddr == ""
+	config.P2P.DiscoveryV5 = false
+	config.P2P.BootstrapNodes, config.P2P.BootstrapNodesV5 = tenant.BootstrapNodes, nil
+	config.P2P.StaticNodes = config.StaticNodes()
+	config.P2P.TrustedNodes = config.TrustedNodes()
+	config.P2P.NodeDatabase = config.NodeDB()
+
+	return &config
+}
+
+// constructServices instantiates the given service constructors in order within
+// the environment defined by config and mux.
+func (n *Node) constructServices(config *Config, mux *event.TypeMux, constructors []ServiceConstructor) (map[reflect.Type]Service, error) {
+	services := make(map[reflect.Type]Service)
+	for _, constructor := range constructors {
+		// Create a new context for the particular service
+		ctx := &ServiceContext{
+			config:         config,
+			services:       make(map[reflect.Type]Service),
+			EventMux:       mux,
+			AccountManager: n.accman,
+			databases:      n.databases,
+		}
+		for kind, s := range services { // copy needed for threaded access
+			ctx.services[kind] = s
+		}
+		// Construct and save the service
+		service, err := constructor(ctx)
+		if err != nil {
+			return nil, err
+		}
+		kind := reflect.TypeOf(service)
+		if _, exists := services[kind]; exists {
+			return nil, &DuplicateServiceError{Kind: kind}
+		}
+		services[kind] = service
+	}
+	return services, nil
+}
+
+// protocols gathers the p2p protocols of the tenant's services.
+func (t *tenant) protocols() []p2p.Protocol {
+	var protocols []p2p.Protocol
+	for _, service := range t.services {
+		protocols = append(protocols, service.Protocols()...)
+	}
+	return protocols
+}
+
+// apis gathers the RPC APIs of the tenant's services, moved into namespaces
+// prefixed by the tenant name.
+func (t *tenant) apis() []rpc.API {
+	var apis []rpc.API
+	for _, service := range t.services {
+		for _, api := range service.APIs() {
+			api.Namespace = t.name + tenantSeparator + api.Namespace
+			apis = append(apis, api)
+		}
+	}
+	return apis
+}
+
+// apiModule returns the module name of an RPC namespace used for whitelisting,
+// stripping any tenant prefix.
+func (n *Node) apiModule(namespace string) string {
+	for _, t := range n.tenants {
+		if prefix := t.name + tenantSeparator; strings.HasPrefix(namespace, prefix) {
+			return strings.TrimPrefix(namespace, prefix)
+		}
+	}
+	return namespace
+}
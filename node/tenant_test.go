@@ -0,0 +1,141 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package node
+
+import (
+	"crypto/ecdsa"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/discover"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// Tests that tenants can run services of the same type as the node and each other,
+// isolated in their data directories, keys, event muxes, protocols and APIs.
+func TestTenantIsolation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("failed to create temporary data directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	config := testNodeConfig()
+	config.DataDir = dir
+
+	stack, err := New(config)
+	if err != nil {
+		t.Fatalf("failed to create protocol stack: %v", err)
+	}
+	if err := stack.RegisterTenant("invalid_name", NewNoopService); err == nil {
+		t.Fatalf("invalid tenant name accepted")
+	}
+	// Register the same service type in the node and in two tenants
+	type environment struct {
+		path string
+		key  *ecdsa.PrivateKey
+		mux  *event.TypeMux
+	}
+	calls := make(chan string, 1)
+	envs := make(map[string]environment)
+
+	register := func(tenant string) func(ctx *ServiceContext) (Service, error) {
+		return func(ctx *ServiceContext) (Service, error) {
+			envs[tenant] = environment{ctx.ResolvePath("chaindata"), ctx.NodeKey(), ctx.EventMux}
+			return &InstrumentedService{
+				protocols: []p2p.Protocol{{Name: "test", Version: 1}},
+				apis: []rpc.API{
+					{Namespace: "test", Version: "1", Service: &OneMethodApi{fun: func() { calls <- tenant }}, Public: true},
+				},
+			}, nil
+		}
+	}
+	if err := stack.Register(register("")); err != nil {
+		t.Fatalf("failed to register service: %v", err)
+	}
+	for _, tenant := range []string{"alpha", "beta"} {
+		if err := stack.RegisterTenant(tenant, register(tenant)); err != nil {
+			t.Fatalf("failed to register tenant %s: %v", tenant, err)
+		}
+	}
+	if err := stack.Start(); err != nil {
+		t.Fatalf("failed to start protocol stack: %v", err)
+	}
+	defer stack.Stop()
+
+	// Ensure the environments are isolated
+	if have, want := envs["alpha"].path, filepath.Join(dir, datadirTenants, "alpha", "test node", "chaindata"); have != want {
+		t.Errorf("tenant data path mismatch: have %s, want %s", have, want)
+	}
+	if envs["alpha"].key.D.Cmp(envs["beta"].key.D) == 0 || envs["alpha"].key.D.Cmp(envs[""].key.D) == 0 {
+		t.Errorf("tenant node keys not isolated")
+	}
+	if envs["alpha"].mux == envs["beta"].mux || envs["alpha"].mux == envs[""].mux {
+		t.Errorf("tenant event muxes not isolated")
+	}
+	// Ensure the tenant services are retrievable
+	var service *InstrumentedService
+	if err := stack.TenantService("alpha", &service); err != nil {
+		t.Errorf("failed to retrieve tenant service: %v", err)
+	}
+	if err := stack.TenantService("gamma", &service); err != ErrServiceUnknown {
+		t.Errorf("unknown tenant service error mismatch: have %v, want %v", err, ErrServiceUnknown)
+	}
+	// Ensure the protocols run on separate servers under the tenant node keys
+	servers := map[string]*p2p.Server{"": stack.Server()}
+	for _, tenant := range []string{"alpha", "beta"} {
+		if servers[tenant] = stack.TenantServer(tenant); servers[tenant] == nil {
+			t.Fatalf("tenant %s: p2p server not running", tenant)
+		}
+	}
+	if stack.TenantServer("gamma") != nil {
+		t.Errorf("unknown tenant p2p server retrieved")
+	}
+	for tenant, server := range servers {
+		if len(server.Protocols) != 1 || server.Protocols[0].Name != "test" {
+			t.Errorf("tenant %q: protocols mismatch: have %v, want [test]", tenant, server.Protocols)
+		}
+		if have, want := server.Self().ID, discover.PubkeyID(&envs[tenant].key.PublicKey); have != want {
+			t.Errorf("tenant %q: node id mismatch: have %x, want %x", tenant, have[:8], want[:8])
+		}
+	}
+	// Ensure the APIs are namespaced
+	client, err := stack.Attach()
+	if err != nil {
+		t.Fatalf("failed to connect to the inproc API server: %v", err)
+	}
+	defer client.Close()
+
+	for method, tenant := range map[string]string{"test_theOneMethod": "", "alpha.test_theOneMethod": "alpha", "beta.test_theOneMethod": "beta"} {
+		if err := client.Call(nil, method); err != nil {
+			t.Errorf("%s: API request failed: %v", method, err)
+		}
+		select {
+		case result := <-calls:
+			if result != tenant {
+				t.Errorf("%s: tenant mismatch: have %q, want %q", method, result, tenant)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("%s: rpc execution timeout", method)
+		}
+	}
+}
@@ -22,6 +22,7 @@ import (
 	"errors"
 	"fmt"
 	"net"
+	"sort"
 	"time"
 
 	"github.com/ethereum/go-ethereum/log"
@@ -220,6 +221,7 @@ func (s *dialstate) newTasks(nRunning int, peers map[discover.NodeID]*Peer, now
 	randomCandidates := needDynDials / 2
 	if randomCandidates > 0 {
 		n := s.ntab.ReadRandomNodes(s.randomNodes)
+		preferFamily(s.randomNodes[:n], s.ntab.Self().IP)
 		for i := 0; i < randomCandidates && i < n; i++ {
 			if addDial(dynDialedConn, s.randomNodes[i]) {
 				needDynDials--
@@ -284,10 +286,23 @@ func (s *dialstate) taskDone(t task, now time.Time) {
 		delete(s.dialing, t.dest.ID)
 	case *discoverTask:
 		s.lookupRunning = false
+		preferFamily(t.results, s.ntab.Self().IP)
 		s.lookupBuf = append(s.lookupBuf, t.results...)
 	}
 }
 
+// preferFamily moves nodes of the same address family as ip to the front of the
+// list, so dials over our own family are attempted first. The order is left
+// as is if ip is unknown, i.e. when listening on all interfaces.
+func preferFamily(nodes []*discover.Node, ip net.IP) {
+	if len(ip) == 0 || ip.IsUnspecified() {
+		return
+	}
+	sort.SliceStable(nodes, func(i, j int) bool {
+		return netutil.SameFamily(nodes[i].IP, ip) && !netutil.SameFamily(nodes[j].IP, ip)
+	})
+}
+
 func (t *dialTask) Do(srv *Server) {
 	if t.dest.Incomplete() {
 		if !t.resolve(srv) {
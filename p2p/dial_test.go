@@ -655,6 +655,34 @@ func TestDialResolve(t *testing.T) {
 	}
 }
 
+// This test checks that dial candidates of our own address family are tried first.
+func TestPreferFamily(t *testing.T) {
+	nodes := []*discover.Node{
+		{ID: uintID(1), IP: net.ParseIP("2001:db8::1")},
+		{ID: uintID(2), IP: net.IP{10, 0, 0, 2}},
+		{ID: uintID(3), IP: net.ParseIP("2001:db8::3")},
+		{ID: uintID(4), IP: net.IP{10, 0, 0, 4}},
+	}
+	preferFamily(nodes, net.IPv4zero)
+	for i, id := range []uint32{1, 2, 3, 4} {
+		if nodes[i].ID != uintID(id) {
+			t.Fatalf("unspecified IP reordered nodes: position %d has %x", i, nodes[i].ID[:4])
+		}
+	}
+	preferFamily(nodes, net.IP{10, 0, 0, 1})
+	for i, id := range []uint32{2, 4, 1, 3} {
+		if nodes[i].ID != uintID(id) {
+			t.Errorf("position %d: got node %x, want %d", i, nodes[i].ID[:4], id)
+		}
+	}
+	preferFamily(nodes, net.ParseIP("2001:db8::2"))
+	for i, id := range []uint32{1, 3, 2, 4} {
+		if nodes[i].ID != uintID(id) {
+			t.Errorf("position %d: got node %x, want %d", i, nodes[i].ID[:4], id)
+		}
+	}
+}
+
 // compares task lists but doesn't care about the order.
 func sametasks(a, b []task) bool {
 	if len(a) != len(b) {
@@ -50,9 +50,10 @@ const (
 	nBuckets          = hashBits / 15       // Number of buckets
 	bucketMinDistance = hashBits - nBuckets // Log distance of closest bucket
 
-	// IP address limits.
-	bucketIPLimit, bucketSubnet = 2, 24 // at most 2 addresses from the same /24
-	tableIPLimit, tableSubnet   = 10, 24
+	// IP address limits. IPv6 sites typically get a /48 assigned, which is
+	// treated like an IPv4 /24.
+	bucketIPLimit, bucketSubnet, bucketSubnet6 = 2, 24, 48 // at most 2 addresses from the same /24 or /48
+	tableIPLimit, tableSubnet, tableSubnet6    = 10, 24, 48
 
 	maxBondingPingPongs = 16 // Limit on the number of concurrent ping/pong interactions
 	maxFindnodeFailures = 5  // Nodes exceeding this limit are dropped
@@ -129,7 +130,7 @@ func newTable(t transport, ourID NodeID, ourAddr *net.UDPAddr, nodeDBPath string
 		closeReq:   make(chan struct{}),
 		closed:     make(chan struct{}),
 		rand:       mrand.New(mrand.NewSource(0)),
-		ips:        netutil.DistinctNetSet{Subnet: tableSubnet, Limit: tableIPLimit},
+		ips:        netutil.DistinctNetSet{Subnet: tableSubnet, Subnet6: tableSubnet6, Limit: tableIPLimit},
 	}
 	if err := tab.setFallbackNodes(bootnodes); err != nil {
 		return nil, err
@@ -139,7 +140,7 @@ func newTable(t transport, ourID NodeID, ourAddr *net.UDPAddr, nodeDBPath string
 	}
 	for i := range tab.buckets {
 		tab.buckets[i] = &bucket{
-			ips: netutil.DistinctNetSet{Subnet: bucketSubnet, Limit: bucketIPLimit},
+			ips: netutil.DistinctNetSet{Subnet: bucketSubnet, Subnet6: bucketSubnet6, Limit: bucketIPLimit},
 		}
 	}
 	tab.seedRand()
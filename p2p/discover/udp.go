@@ -133,6 +133,9 @@ func (t *udp) nodeFromRPC(sender *net.UDPAddr, rn rpcNode) (*Node, error) {
 	if err := netutil.CheckRelayIP(sender.IP, rn.IP); err != nil {
 		return nil, err
 	}
+	if !netutil.CanReach(t.conn.LocalAddr().(*net.UDPAddr).IP, rn.IP) {
+		return nil, errors.New("unreachable address family")
+	}
 	if t.netrestrict != nil && !t.netrestrict.Contains(rn.IP) {
 		return nil, errors.New("not contained in netrestrict whitelist")
 	}
@@ -29,6 +29,11 @@ var (
 	ingressTrafficMeter = metrics.NewRegisteredMeter("p2p/InboundTraffic", nil)
 	egressConnectMeter  = metrics.NewRegisteredMeter("p2p/OutboundConnects", nil)
 	egressTrafficMeter  = metrics.NewRegisteredMeter("p2p/OutboundTraffic", nil)
+
+	ingressPlainMeter      = metrics.NewRegisteredMeter("p2p/InboundPlain", nil)
+	ingressCompressedMeter = metrics.NewRegisteredMeter("p2p/InboundCompressed", nil)
+	egressPlainMeter       = metrics.NewRegisteredMeter("p2p/OutboundPlain", nil)
+	egressCompressedMeter  = metrics.NewRegisteredMeter("p2p/OutboundCompressed", nil)
 )
 
 // meteredConn is a wrapper around a network TCP connection that meters both the
@@ -59,12 +59,20 @@ type Interface interface {
 //     "upnp"               uses the Universal Plug and Play protocol
 //     "pmp"                uses NAT-PMP with an auto-detected gateway address
 //     "pmp:192.168.0.1"    uses NAT-PMP with the given gateway address
+//     "stun"               discovers the external IP through a public STUN server
+//     "stun:host:port"     discovers the external IP through the given STUN server
 func Parse(spec string) (Interface, error) {
 	var (
 		parts = strings.SplitN(spec, ":", 2)
 		mech  = strings.ToLower(parts[0])
 		ip    net.IP
 	)
+	if mech == "stun" {
+		if len(parts) > 1 {
+			return STUN(parts[1]), nil
+		}
+		return STUN(DefaultSTUNServer), nil
+	}
 	if len(parts) > 1 {
 		ip = net.ParseIP(parts[1])
 		if ip == nil {
@@ -93,11 +101,34 @@ func Parse(spec string) (Interface, error) {
 const (
 	mapTimeout        = 20 * time.Minute
 	mapUpdateInterval = 15 * time.Minute
+	mapRetryInterval  = time.Minute // Interval to retry failed mappings at
 )
 
+// Status is a snapshot of the state of a port mapping kept alive by Map.
+type Status struct {
+	Protocol   string    `json:"protocol"`
+	ExtPort    int       `json:"extPort"`
+	IntPort    int       `json:"intPort"`
+	Mapped     bool      `json:"mapped"`          // Whether the last (re-)mapping attempt succeeded
+	Renewed    time.Time `json:"renewed"`         // Time of the last successful (re-)mapping
+	ExternalIP net.IP    `json:"externalIP"`      // External address last reported by the gateway
+	Error      string    `json:"error,omitempty"` // Error of the last failed attempt
+}
+
 // Map adds a port mapping on m and keeps it alive until c is closed.
 // This function is typically invoked in its own goroutine.
 func Map(m Interface, c chan struct{}, protocol string, extport, intport int, name string) {
+	MapNotify(m, c, protocol, extport, intport, name, nil)
+}
+
+// MapNotify is like Map, but also reports the state of the mapping to notify
+// (if non-nil) after each attempt to (re-)establish it.
+//
+// The mapping is renewed well before its lifetime ends. If a renewal fails, the
+// mapping is considered lost (e.g. the gateway rebooted) and re-established as
+// soon as the gateway accepts it again. Changes of the external address of the
+// gateway are detected along the way.
+func MapNotify(m Interface, c chan struct{}, protocol string, extport, intport int, name string, notify func(Status)) {
 	log := log.New("proto", protocol, "extport", extport, "intport", intport, "interface", m)
 	refresh := time.NewTimer(mapUpdateInterval)
 	defer func() {
@@ -105,11 +136,40 @@ func Map(m Interface, c chan struct{}, protocol string, extport, intport int, na
 		log.Debug("Deleting port mapping")
 		m.DeleteMapping(protocol, extport, intport)
 	}()
-	if err := m.AddMapping(protocol, extport, intport, name, mapTimeout); err != nil {
-		log.Debug("Couldn't add port mapping", "err", err)
-	} else {
-		log.Info("Mapped network port")
+	status := Status{Protocol: protocol, ExtPort: extport, IntPort: intport}
+
+	update := func() {
+		if err := m.AddMapping(protocol, extport, intport, name, mapTimeout); err != nil {
+			if status.Mapped {
+				log.Warn("Port mapping lost", "err", err)
+			} else {
+				log.Debug("Couldn't add port mapping", "err", err)
+			}
+			status.Mapped, status.Error = false, err.Error()
+			refresh.Reset(mapRetryInterval)
+		} else {
+			switch {
+			case status.Renewed.IsZero():
+				log.Info("Mapped network port")
+			case !status.Mapped:
+				log.Info("Port mapping re-established")
+			default:
+				log.Trace("Refreshed port mapping")
+			}
+			status.Mapped, status.Renewed, status.Error = true, time.Now(), ""
+			refresh.Reset(mapUpdateInterval)
+		}
+		if ip, err := m.ExternalIP(); err == nil {
+			if status.ExternalIP != nil && !status.ExternalIP.Equal(ip) {
+				log.Warn("External IP changed", "old", status.ExternalIP, "new", ip)
+			}
+			status.ExternalIP = ip
+		}
+		if notify != nil {
+			notify(status)
+		}
 	}
+	update()
 	for {
 		select {
 		case _, ok := <-c:
@@ -117,11 +177,7 @@ func Map(m Interface, c chan struct{}, protocol string, extport, intport int, na
 				return
 			}
 		case <-refresh.C:
-			log.Trace("Refreshing port mapping")
-			if err := m.AddMapping(protocol, extport, intport, name, mapTimeout); err != nil {
-				log.Debug("Couldn't add port mapping", "err", err)
-			}
-			refresh.Reset(mapUpdateInterval)
+			update()
 		}
 	}
 }
@@ -159,7 +215,8 @@ func Any() Interface {
 				return c
 			}
 		}
-		return nil
+		// No gateway to map ports on, try discovering at least the external IP
+		return discoverSTUN(DefaultSTUNServer)
 	})
 }
 
@@ -0,0 +1,170 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package nat
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+// DefaultSTUNServer is the STUN server queried if none is configured.
+const DefaultSTUNServer = "stun.l.google.com:19302"
+
+const (
+	stunTimeout = 3 * time.Second // Time to wait for a STUN response
+
+	stunBindingRequest  = 0x0001
+	stunBindingResponse = 0x0101
+	stunMagicCookie     = 0x2112A442
+	stunHeaderSize      = 20
+
+	stunAttrMappedAddress    = 0x0001
+	stunAttrXorMappedAddress = 0x0020
+)
+
+var errSTUNNoAddress = errors.New("no mapped address in STUN response")
+
+// STUN returns a NAT interface discovering the external IP address through a
+// STUN server (RFC 5389). STUN can't map ports, so the mapping operations do
+// nothing: the ports must be reachable already, e.g. on full cone NATs or
+// forwarded manually.
+func STUN(server string) Interface {
+	return &stun{server: server}
+}
+
+// discoverSTUN returns a STUN interface if the server responds.
+func discoverSTUN(server string) Interface {
+	s := &stun{server: server}
+	if _, err := s.ExternalIP(); err != nil {
+		return nil
+	}
+	return s
+}
+
+type stun struct {
+	server string
+}
+
+func (s *stun) String() string {
+	return fmt.Sprintf("STUN(%s)", s.server)
+}
+
+// These do nothing.
+func (*stun) AddMapping(string, int, int, string, time.Duration) error { return nil }
+func (*stun) DeleteMapping(string, int, int) error                     { return nil }
+
+// ExternalIP sends a binding request to the STUN server, returning the address
+// the request was seen as coming from.
+func (s *stun) ExternalIP() (net.IP, error) {
+	conn, err := net.DialTimeout("udp", s.server, stunTimeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	request := make([]byte, stunHeaderSize)
+	binary.BigEndian.PutUint16(request[0:], stunBindingRequest)
+	binary.BigEndian.PutUint32(request[4:], stunMagicCookie)
+	if _, err := rand.Read(request[8:stunHeaderSize]); err != nil {
+		return nil, err
+	}
+	conn.SetDeadline(time.Now().Add(stunTimeout))
+	if _, err := conn.Write(request); err != nil {
+		return nil, err
+	}
+	response := make([]byte, 1024)
+	n, err := conn.Read(response)
+	if err != nil {
+		return nil, err
+	}
+	return parseSTUNResponse(response[:n], request[8:stunHeaderSize])
+}
+
+// parseSTUNResponse extracts the mapped address from a binding response to the
+// request with the given transaction ID.
+func parseSTUNResponse(response []byte, txid []byte) (net.IP, error) {
+	if len(response) < stunHeaderSize {
+		return nil, errors.New("STUN response too short")
+	}
+	if binary.BigEndian.Uint16(response[0:]) != stunBindingResponse {
+		return nil, fmt.Errorf("unexpected STUN message type %#04x", binary.BigEndian.Uint16(response[0:]))
+	}
+	if binary.BigEndian.Uint32(response[4:]) != stunMagicCookie || !bytes.Equal(response[8:stunHeaderSize], txid) {
+		return nil, errors.New("STUN response to a different request")
+	}
+	attrs := response[stunHeaderSize:]
+	if length := int(binary.BigEndian.Uint16(response[2:])); length < len(attrs) {
+		attrs = attrs[:length]
+	}
+	var mapped net.IP
+	for len(attrs) >= 4 {
+		typ, size := binary.BigEndian.Uint16(attrs[0:]), int(binary.BigEndian.Uint16(attrs[2:]))
+		if len(attrs) < 4+size {
+			break
+		}
+		value := attrs[4 : 4+size]
+
+		switch typ {
+		case stunAttrXorMappedAddress:
+			// Preferred, as it can't be mangled by address rewriting middleboxes
+			if ip := parseSTUNAddress(value, response[4:stunHeaderSize]); ip != nil {
+				return ip, nil
+			}
+		case stunAttrMappedAddress:
+			mapped = parseSTUNAddress(value, nil)
+		}
+		// Attributes are padded to a multiple of 4 bytes
+		attrs = attrs[4+(size+3)&^3:]
+	}
+	if mapped == nil {
+		return nil, errSTUNNoAddress
+	}
+	return mapped, nil
+}
+
+// parseSTUNAddress decodes the IP of an address attribute, XOR-ed with the magic
+// cookie and transaction ID if given.
+func parseSTUNAddress(value []byte, xor []byte) net.IP {
+	if len(value) < 4 {
+		return nil
+	}
+	var size int
+	switch value[1] {
+	case 0x01:
+		size = net.IPv4len
+	case 0x02:
+		size = net.IPv6len
+	default:
+		return nil
+	}
+	if len(value) < 4+size {
+		return nil
+	}
+	ip := make(net.IP, size)
+	copy(ip, value[4:4+size])
+	for i := range ip {
+		if xor != nil {
+			ip[i] ^= xor[i]
+		}
+	}
+	return ip
+}
@@ -0,0 +1,116 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package nat
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+)
+
+// startSTUNServer runs a minimal STUN server on localhost, answering binding
+// requests with the given address encoded as XOR-MAPPED-ADDRESS.
+func startSTUNServer(t *testing.T, mapped net.IP) *net.UDPConn {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IP{127, 0, 0, 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		buf := make([]byte, 1024)
+		for {
+			n, addr, err := conn.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+			if n < stunHeaderSize || binary.BigEndian.Uint16(buf) != stunBindingRequest {
+				continue
+			}
+			ip := mapped.To4()
+			family := byte(0x01)
+			if ip == nil {
+				ip, family = mapped.To16(), 0x02
+			}
+			attr := make([]byte, 8+len(ip))
+			binary.BigEndian.PutUint16(attr[0:], stunAttrXorMappedAddress)
+			binary.BigEndian.PutUint16(attr[2:], uint16(4+len(ip)))
+			attr[5] = family
+			for i := range ip {
+				attr[8+i] = ip[i] ^ buf[4+i]
+			}
+			resp := make([]byte, stunHeaderSize, stunHeaderSize+len(attr))
+			binary.BigEndian.PutUint16(resp[0:], stunBindingResponse)
+			binary.BigEndian.PutUint16(resp[2:], uint16(len(attr)))
+			copy(resp[4:], buf[4:stunHeaderSize])
+			conn.WriteToUDP(append(resp, attr...), addr)
+		}
+	}()
+	return conn
+}
+
+func TestSTUN(t *testing.T) {
+	for _, want := range []net.IP{{33, 44, 55, 66}, net.ParseIP("2001:db8::68")} {
+		server := startSTUNServer(t, want)
+
+		ip, err := STUN(server.LocalAddr().String()).ExternalIP()
+		server.Close()
+		if err != nil {
+			t.Fatalf("%v: unexpected error: %v", want, err)
+		}
+		if !ip.Equal(want) {
+			t.Errorf("got IP %v, want %v", ip, want)
+		}
+	}
+}
+
+func TestSTUNMappedAddress(t *testing.T) {
+	txid := make([]byte, 12)
+	resp := []byte{
+		0x01, 0x01, 0x00, 0x0c, 0x21, 0x12, 0xa4, 0x42,
+		0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+		// MAPPED-ADDRESS, IPv4, port 30303, 10.0.0.1
+		0x00, 0x01, 0x00, 0x08, 0x00, 0x01, 0x76, 0x5f, 10, 0, 0, 1,
+	}
+	ip, err := parseSTUNResponse(resp, txid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (net.IP{10, 0, 0, 1}); !ip.Equal(want) {
+		t.Errorf("got IP %v, want %v", ip, want)
+	}
+	// Responses to other requests must be rejected.
+	if _, err := parseSTUNResponse(resp, []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12}); err == nil {
+		t.Error("expected error for mismatching transaction ID")
+	}
+}
+
+func TestParseSTUN(t *testing.T) {
+	tests := map[string]string{
+		"stun":                 "STUN(" + DefaultSTUNServer + ")",
+		"stun:127.0.0.1:3478":  "STUN(127.0.0.1:3478)",
+		"STUN:example.org:123": "STUN(example.org:123)",
+	}
+	for spec, want := range tests {
+		m, err := Parse(spec)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", spec, err)
+			continue
+		}
+		if got := m.String(); got != want {
+			t.Errorf("%q: got %s, want %s", spec, got, want)
+		}
+	}
+}
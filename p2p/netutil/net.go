@@ -215,11 +215,27 @@ func sameNet(bits uint, ip, other net.IP) bool {
 	return nb <= len(ip) && bytes.Equal(ip[:nb], other[:nb])
 }
 
+// SameFamily reports whether two IP addresses are both IPv4 or both IPv6.
+func SameFamily(ip, other net.IP) bool {
+	return (ip.To4() == nil) == (other.To4() == nil)
+}
+
+// CanReach reports whether a socket bound to the local IP can exchange packets
+// with the remote IP. Sockets bound to the unspecified address are dual-stack
+// and can reach both address families.
+func CanReach(local, remote net.IP) bool {
+	if len(local) == 0 || local.IsUnspecified() {
+		return true
+	}
+	return SameFamily(local, remote)
+}
+
 // DistinctNetSet tracks IPs, ensuring that at most N of them
 // fall into the same network range.
 type DistinctNetSet struct {
-	Subnet uint // number of common prefix bits
-	Limit  uint // maximum number of IPs in each subnet
+	Subnet  uint // number of common prefix bits
+	Subnet6 uint // number of common prefix bits for IPv6 addresses, Subnet if zero
+	Limit   uint // maximum number of IPs in each subnet
 
 	members map[string]uint
 	buf     net.IP
@@ -276,11 +292,13 @@ func (s *DistinctNetSet) key(ip net.IP) net.IP {
 		s.buf = make(net.IP, 17)
 	}
 	// Canonicalize ip and bits.
-	typ := byte('6')
+	typ, bits := byte('6'), s.Subnet
+	if s.Subnet6 != 0 {
+		bits = s.Subnet6
+	}
 	if ip4 := ip.To4(); ip4 != nil {
-		typ, ip = '4', ip4
+		typ, ip, bits = '4', ip4, s.Subnet
 	}
-	bits := s.Subnet
 	if bits > uint(len(ip)*8) {
 		bits = uint(len(ip) * 8)
 	}
@@ -243,6 +243,49 @@ func TestDistinctNetSet(t *testing.T) {
 	}
 }
 
+func TestDistinctNetSetIPv6(t *testing.T) {
+	set := DistinctNetSet{Subnet: 24, Subnet6: 48, Limit: 1}
+	ops := []struct {
+		add   string
+		fails bool
+	}{
+		{add: "2001:db8:1::1"},
+		{add: "2001:db8:1:ffff::1", fails: true},
+		{add: "2001:db8:2::1"},
+		{add: "10.0.0.1"},
+		{add: "10.0.0.2", fails: true},
+		{add: "10.0.1.1"},
+	}
+	for _, op := range ops {
+		if ok := set.Add(parseIP(op.add)); ok != !op.fails {
+			t.Errorf("Add(%s) == %t, want %t", op.add, ok, !op.fails)
+		}
+	}
+}
+
+func TestCanReach(t *testing.T) {
+	tests := []struct {
+		local, remote string
+		want          bool
+	}{
+		{"0.0.0.0", "2001:db8::1", true},
+		{"::", "10.0.0.1", true},
+		{"10.0.0.1", "10.0.0.2", true},
+		{"10.0.0.1", "2001:db8::1", false},
+		{"2001:db8::1", "10.0.0.1", false},
+		{"2001:db8::1", "2001:db8::2", true},
+		{"::ffff:10.0.0.1", "10.0.0.2", true},
+	}
+	for _, test := range tests {
+		if ok := CanReach(parseIP(test.local), parseIP(test.remote)); ok != test.want {
+			t.Errorf("CanReach(%s, %s) == %t, want %t", test.local, test.remote, ok, test.want)
+		}
+	}
+	if !CanReach(nil, parseIP("10.0.0.1")) {
+		t.Error("CanReach(nil, 10.0.0.1) == false, want true")
+	}
+}
+
 func TestDistinctNetSetAddRemove(t *testing.T) {
 	cfg := &quick.Config{}
 	fn := func(ips []net.IP) bool {
@@ -422,10 +422,29 @@ type PeerInfo struct {
 		Inbound       bool   `json:"inbound"`
 		Trusted       bool   `json:"trusted"`
 		Static        bool   `json:"static"`
+
+		Compression *CompressionInfo `json:"compression,omitempty"` // Message compression statistics (nil = unknown)
 	} `json:"network"`
 	Protocols map[string]interface{} `json:"protocols"` // Sub-protocol specific metadata fields
 }
 
+// CompressionInfo represents the message compression statistics of a peer. The
+// ratios are the wire size over the uncompressed size of the message payloads.
+type CompressionInfo struct {
+	Enabled       bool    `json:"enabled"`       // Whether the peer negotiated snappy compression
+	InboundPlain  uint64  `json:"inboundPlain"`  // Payload bytes received, after decompression
+	InboundWire   uint64  `json:"inboundWire"`   // Payload bytes received, as sent over the wire
+	InboundRatio  float64 `json:"inboundRatio"`  // Compression ratio of the received messages
+	OutboundPlain uint64  `json:"outboundPlain"` // Payload bytes sent, before compression
+	OutboundWire  uint64  `json:"outboundWire"`  // Payload bytes sent, as sent over the wire
+	OutboundRatio float64 `json:"outboundRatio"` // Compression ratio of the sent messages
+}
+
+// compressionReporter is implemented by transports compressing messages.
+type compressionReporter interface {
+	compression() *CompressionInfo
+}
+
 // Info gathers and returns a collection of metadata known about a peer.
 func (p *Peer) Info() *PeerInfo {
 	// Gather the protocol capabilities
@@ -445,6 +464,9 @@ func (p *Peer) Info() *PeerInfo {
 	info.Network.Inbound = p.rw.is(inboundConn)
 	info.Network.Trusted = p.rw.is(trustedConn)
 	info.Network.Static = p.rw.is(staticDialedConn)
+	if reporter, ok := p.rw.transport.(compressionReporter); ok {
+		info.Network.Compression = reporter.compression()
+	}
 
 	// Gather all the running protocol infos
 	for _, proto := range p.running {
@@ -33,6 +33,7 @@ import (
 	mrand "math/rand"
 	"net"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/ethereum/go-ethereum/crypto"
@@ -141,6 +142,15 @@ func (t *rlpx) doProtoHandshake(our *protoHandshake) (their *protoHandshake, err
 	return their, nil
 }
 
+// compression implements compressionReporter, returning the message compression
+// statistics of the connection.
+func (t *rlpx) compression() *CompressionInfo {
+	if t.rw == nil {
+		return nil
+	}
+	return t.rw.stats.info(t.rw.snappy)
+}
+
 func readProtocolHandshake(rw MsgReader, our *protoHandshake) (*protoHandshake, error) {
 	msg, err := rw.ReadMsg()
 	if err != nil {
@@ -573,6 +583,34 @@ type rlpxFrameRW struct {
 	ingressMAC hash.Hash
 
 	snappy bool
+	stats  compressionStats
+}
+
+// compressionStats counts the message payload bytes passing a connection with
+// compression enabled, both before and after compression.
+type compressionStats struct {
+	ingressPlain uint64 // Payload bytes received, after decompression
+	ingressWire  uint64 // Payload bytes received, as sent over the wire
+	egressPlain  uint64 // Payload bytes sent, before compression
+	egressWire   uint64 // Payload bytes sent, as sent over the wire
+}
+
+// info assembles the user facing compression statistics.
+func (s *compressionStats) info(enabled bool) *CompressionInfo {
+	info := &CompressionInfo{
+		Enabled:       enabled,
+		InboundPlain:  atomic.LoadUint64(&s.ingressPlain),
+		InboundWire:   atomic.LoadUint64(&s.ingressWire),
+		OutboundPlain: atomic.LoadUint64(&s.egressPlain),
+		OutboundWire:  atomic.LoadUint64(&s.egressWire),
+	}
+	if info.InboundPlain > 0 {
+		info.InboundRatio = float64(info.InboundWire) / float64(info.InboundPlain)
+	}
+	if info.OutboundPlain > 0 {
+		info.OutboundRatio = float64(info.OutboundWire) / float64(info.OutboundPlain)
+	}
+	return info
 }
 
 func newRLPXFrameRW(conn io.ReadWriter, s secrets) *rlpxFrameRW {
@@ -608,6 +646,11 @@ func (rw *rlpxFrameRW) WriteMsg(msg Msg) error {
 		payload, _ := ioutil.ReadAll(msg.Payload)
 		payload = snappy.Encode(nil, payload)
 
+		atomic.AddUint64(&rw.stats.egressPlain, uint64(msg.Size))
+		atomic.AddUint64(&rw.stats.egressWire, uint64(len(payload)))
+		egressPlainMeter.Mark(int64(msg.Size))
+		egressCompressedMeter.Mark(int64(len(payload)))
+
 		msg.Payload = bytes.NewReader(payload)
 		msg.Size = uint32(len(payload))
 	}
@@ -710,10 +753,16 @@ func (rw *rlpxFrameRW) ReadMsg() (msg Msg, err error) {
 		if size > int(maxUint24) {
 			return msg, errPlainMessageTooLarge
 		}
+		wire := len(payload)
 		payload, err = snappy.Decode(nil, payload)
 		if err != nil {
 			return msg, err
 		}
+		atomic.AddUint64(&rw.stats.ingressPlain, uint64(size))
+		atomic.AddUint64(&rw.stats.ingressWire, uint64(wire))
+		ingressPlainMeter.Mark(int64(size))
+		ingressCompressedMeter.Mark(int64(wire))
+
 		msg.Size, msg.Payload = uint32(size), bytes.NewReader(payload)
 	}
 	return msg, nil
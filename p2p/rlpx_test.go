@@ -378,6 +378,52 @@ func TestRLPXFrameRW(t *testing.T) {
 	}
 }
 
+// Tests that the payload sizes of compressed messages are accounted for on both
+// ends of a connection.
+func TestRLPXFrameCompressionStats(t *testing.T) {
+	var (
+		aesSecret      = make([]byte, 16)
+		macSecret      = make([]byte, 16)
+		egressMACinit  = make([]byte, 32)
+		ingressMACinit = make([]byte, 32)
+	)
+	for _, s := range [][]byte{aesSecret, macSecret, egressMACinit, ingressMACinit} {
+		rand.Read(s)
+	}
+	conn := new(bytes.Buffer)
+
+	s1 := secrets{AES: aesSecret, MAC: macSecret, EgressMAC: sha3.NewKeccak256(), IngressMAC: sha3.NewKeccak256()}
+	s1.EgressMAC.Write(egressMACinit)
+	s1.IngressMAC.Write(ingressMACinit)
+	rw1 := newRLPXFrameRW(conn, s1)
+	rw1.snappy = true
+
+	s2 := secrets{AES: aesSecret, MAC: macSecret, EgressMAC: sha3.NewKeccak256(), IngressMAC: sha3.NewKeccak256()}
+	s2.EgressMAC.Write(ingressMACinit)
+	s2.IngressMAC.Write(egressMACinit)
+	rw2 := newRLPXFrameRW(conn, s2)
+	rw2.snappy = true
+
+	// Send a highly compressible message, as proposals carrying similar transactions
+	payload, _ := rlp.EncodeToBytes(strings.Repeat("proposal", 1024))
+	if err := Send(rw1, 0x10, strings.Repeat("proposal", 1024)); err != nil {
+		t.Fatalf("WriteMsg error: %v", err)
+	}
+	if _, err := rw2.ReadMsg(); err != nil {
+		t.Fatalf("ReadMsg error: %v", err)
+	}
+	sent, received := rw1.stats.info(true), rw2.stats.info(true)
+	if sent.OutboundPlain != uint64(len(payload)) || received.InboundPlain != uint64(len(payload)) {
+		t.Errorf("plain size mismatch: sent %d, received %d, want %d", sent.OutboundPlain, received.InboundPlain, len(payload))
+	}
+	if sent.OutboundWire != received.InboundWire || sent.OutboundWire >= sent.OutboundPlain {
+		t.Errorf("wire size mismatch: sent %d, received %d", sent.OutboundWire, received.InboundWire)
+	}
+	if sent.OutboundRatio <= 0 || sent.OutboundRatio >= 0.5 || sent.OutboundRatio != received.InboundRatio {
+		t.Errorf("ratio mismatch: sent %v, received %v", sent.OutboundRatio, received.InboundRatio)
+	}
+}
+
 type handshakeAuthTest struct {
 	input       string
 	isPlain     bool
@@ -22,6 +22,7 @@ import (
 	"errors"
 	"fmt"
 	"net"
+	"sort"
 	"sync"
 	"time"
 
@@ -175,6 +176,9 @@ type Server struct {
 	loopWG        sync.WaitGroup // loop, listenLoop
 	peerFeed      event.Feed
 	log           log.Logger
+
+	natLock   sync.Mutex            // protects natStatus
+	natStatus map[string]nat.Status // last reported port mappings by protocol
 }
 
 type peerOpFunc func(map[discover.NodeID]*Peer)
@@ -432,7 +436,7 @@ func (srv *Server) Start() (err error) {
 		realaddr = conn.LocalAddr().(*net.UDPAddr)
 		if srv.NAT != nil {
 			if !realaddr.IP.IsLoopback() {
-				go nat.Map(srv.NAT, srv.quit, "udp", realaddr.Port, realaddr.Port, "ethereum discovery")
+				go nat.MapNotify(srv.NAT, srv.quit, "udp", realaddr.Port, realaddr.Port, "ethereum discovery", srv.setNATStatus)
 			}
 			// TODO: react to external IP changes over time.
 			if ext, err := srv.NAT.ExternalIP(); err == nil {
@@ -521,7 +525,7 @@ func (srv *Server) startListening() error {
 	if !laddr.IP.IsLoopback() && srv.NAT != nil {
 		srv.loopWG.Add(1)
 		go func() {
-			nat.Map(srv.NAT, srv.quit, "tcp", laddr.Port, laddr.Port, "ethereum p2p")
+			nat.MapNotify(srv.NAT, srv.quit, "tcp", laddr.Port, laddr.Port, "ethereum p2p", srv.setNATStatus)
 			srv.loopWG.Done()
 		}()
 	}
@@ -917,6 +921,49 @@ type NodeInfo struct {
 	} `json:"ports"`
 	ListenAddr string                 `json:"listenAddr"`
 	Protocols  map[string]interface{} `json:"protocols"`
+	NAT        *NATInfo               `json:"nat,omitempty"` // Port mapping state, if NAT traversal is enabled
+}
+
+// NATInfo represents the state of NAT traversal of the host.
+type NATInfo struct {
+	Mechanism  string       `json:"mechanism"`            // NAT traversal mechanism in use
+	ExternalIP string       `json:"externalIP,omitempty"` // Last discovered external IP address
+	Mappings   []nat.Status `json:"mappings"`             // State of the port mappings
+}
+
+// setNATStatus records the latest state of a port mapping.
+func (srv *Server) setNATStatus(status nat.Status) {
+	srv.natLock.Lock()
+	defer srv.natLock.Unlock()
+
+	if srv.natStatus == nil {
+		srv.natStatus = make(map[string]nat.Status)
+	}
+	srv.natStatus[status.Protocol] = status
+}
+
+// natInfo assembles the NAT traversal state from the cached mapping reports,
+// avoiding any blocking queries towards the gateway.
+func (srv *Server) natInfo() *NATInfo {
+	if srv.NAT == nil {
+		return nil
+	}
+	info := &NATInfo{
+		Mechanism: srv.NAT.String(),
+		Mappings:  []nat.Status{},
+	}
+	srv.natLock.Lock()
+	defer srv.natLock.Unlock()
+
+	var latest time.Time
+	for _, status := range srv.natStatus {
+		info.Mappings = append(info.Mappings, status)
+		if status.ExternalIP != nil && (info.ExternalIP == "" || status.Renewed.After(latest)) {
+			info.ExternalIP, latest = status.ExternalIP.String(), status.Renewed
+		}
+	}
+	sort.Slice(info.Mappings, func(i, j int) bool { return info.Mappings[i].Protocol < info.Mappings[j].Protocol })
+	return info
 }
 
 // NodeInfo gathers and returns a collection of metadata known about the host.
@@ -931,6 +978,7 @@ func (srv *Server) NodeInfo() *NodeInfo {
 		IP:         node.IP.String(),
 		ListenAddr: srv.ListenAddr,
 		Protocols:  make(map[string]interface{}),
+		NAT:        srv.natInfo(),
 	}
 	info.Ports.Discovery = int(node.UDP)
 	info.Ports.Listener = int(node.TCP)
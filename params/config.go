@@ -17,11 +17,15 @@
 package params
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"math"
 	"math/big"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto/sha3"
 )
 
 var (
@@ -101,16 +105,16 @@ var (
 	//
 	// This configuration is intentionally not using keyed fields to force anyone
 	// adding flags to the config to also have to set these fields.
-	AllEthashProtocolChanges = &ChainConfig{big.NewInt(1337), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, new(EthashConfig), nil, nil}
+	AllEthashProtocolChanges = &ChainConfig{big.NewInt(1337), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, new(EthashConfig), nil, nil, nil, nil}
 
 	// AllCliqueProtocolChanges contains every protocol change (EIPs) introduced
 	// and accepted by the Ethereum core developers into the Clique consensus.
 	//
 	// This configuration is intentionally not using keyed fields to force anyone
 	// adding flags to the config to also have to set these fields.
-	AllCliqueProtocolChanges = &ChainConfig{big.NewInt(1337), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, nil, &CliqueConfig{Period: 0, Epoch: 30000}, nil}
+	AllCliqueProtocolChanges = &ChainConfig{big.NewInt(1337), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, nil, &CliqueConfig{Period: 0, Epoch: 30000}, nil, nil, nil}
 
-	TestChainConfig = &ChainConfig{big.NewInt(1), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, new(EthashConfig), nil, nil}
+	TestChainConfig = &ChainConfig{big.NewInt(1), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, new(EthashConfig), nil, nil, nil, nil}
 	TestRules       = TestChainConfig.Rules(new(big.Int))
 )
 
@@ -141,6 +145,31 @@ type ChainConfig struct {
 	Ethash   *EthashConfig   `json:"ethash,omitempty"`
 	Clique   *CliqueConfig   `json:"clique,omitempty"`
 	Istanbul *IstanbulConfig `json:"istanbul,omitempty"`
+
+	// Additional precompiled contracts from the extension registry
+	Precompiles []*PrecompileConfig `json:"precompiles,omitempty"`
+
+	// Repricing of opcodes and precompiled contracts, in block order
+	GasOverrides []*GasOverrideConfig `json:"gasOverrides,omitempty"`
+}
+
+// PrecompileConfig enables an extension precompiled contract at an address from
+// a given block on.
+type PrecompileConfig struct {
+	Name    string         `json:"name"`    // Name the contract is registered under in the EVM
+	Address common.Address `json:"address"` // Address to install the contract at
+	Block   *big.Int       `json:"block"`   // Activation block (nil = disabled, 0 = already activated)
+}
+
+// GasOverrideConfig reprices opcodes and precompiled contracts from a given
+// block on. Opcodes are referenced by their mnemonic (e.g. "SSTORE"), and their
+// overridden cost replaces the one of the fork rules entirely. Overrides of
+// later entries take precedence over the ones of earlier entries.
+type GasOverrideConfig struct {
+	Name        string                    `json:"name"`                  // Name of the repricing, unique within the chain
+	Block       *big.Int                  `json:"block"`                 // Activation block (nil = disabled, 0 = already activated)
+	Opcodes     map[string]uint64         `json:"opcodes,omitempty"`     // Gas cost of opcodes by mnemonic
+	Precompiles map[common.Address]uint64 `json:"precompiles,omitempty"` // Gas cost of precompiled contracts by address
 }
 
 // EthashConfig is the consensus engine configs for proof-of-work based sealing.
@@ -164,8 +193,133 @@ func (c *CliqueConfig) String() string {
 
 // IstanbulConfig is the consensus engine configs for Istanbul based sealing.
 type IstanbulConfig struct {
-	Epoch          uint64 `json:"epoch"`  // Epoch length to reset votes and checkpoint
-	ProposerPolicy uint64 `json:"policy"` // The policy for proposer selection
+	Epoch          uint64                `json:"epoch"`            // Epoch length to reset votes and checkpoint (0 = 30000)
+	ProposerPolicy uint64                `json:"policy"`           // The policy for proposer selection
+	Reward         *IstanbulRewardConfig `json:"reward,omitempty"` // Block reward and fee policy (nil = no rewards)
+
+	// FixedPeriod makes block timestamps deterministic, each block being stamped
+	// exactly this many seconds after its parent regardless of the wall clock.
+	// Meant for test chains which need to be reproducible across machines.
+	FixedPeriod uint64 `json:"fixedPeriod,omitempty"` // Seconds between block timestamps (0 = wall clock)
+
+	// EmptyBlockPeriod holds back empty blocks until this many seconds passed
+	// since their parent. All validators need to agree on it, as they extend the
+	// timeout of the first round by it to not mistake an idle network for a
+	// faulty proposer.
+	EmptyBlockPeriod uint64 `json:"emptyBlockPeriod,omitempty"` // Seconds between an empty block and its parent (0 = same as the block period)
+
+	SystemCall *IstanbulSystemCallConfig `json:"systemCall,omitempty"` // System contract called when finalizing blocks (nil = disabled)
+
+	Upgrades []*IstanbulUpgradeConfig `json:"upgrades,omitempty"` // Protocol upgrades activated by validator signaling
+
+	Threshold *IstanbulThresholdConfig `json:"threshold,omitempty"` // Threshold signature committed seals (nil = individual seals)
+
+	Forks []*IstanbulForkConfig `json:"forks,omitempty"` // Scheduled changes of the engine behavior, in block order
+}
+
+// IstanbulForkConfig is a named change of the Istanbul engine behavior at a
+// given block. Settings left unset keep their value from the previous forks.
+type IstanbulForkConfig struct {
+	Name           string   `json:"name"`             // Name of the fork, unique within the chain
+	Block          *big.Int `json:"block"`            // Activation block (nil = disabled, 0 = already activated)
+	ProposerPolicy *uint64  `json:"policy,omitempty"` // Proposer selection policy from the fork on (nil = unchanged)
+}
+
+// IsActive returns whether the fork is active at num.
+func (c *IstanbulForkConfig) IsActive(num *big.Int) bool {
+	return isForked(c.Block, num)
+}
+
+// IsFork returns whether the named fork is active at num.
+func (c *IstanbulConfig) IsFork(name string, num *big.Int) bool {
+	fork := c.fork(name)
+	return fork != nil && fork.IsActive(num)
+}
+
+// ProposerPolicyAt returns the proposer selection policy in effect at num.
+func (c *IstanbulConfig) ProposerPolicyAt(num *big.Int) uint64 {
+	policy := c.ProposerPolicy
+	for _, fork := range c.Forks {
+		if fork.IsActive(num) && fork.ProposerPolicy != nil {
+			policy = *fork.ProposerPolicy
+		}
+	}
+	return policy
+}
+
+// fork returns the scheduled fork with the given name, if any.
+func (c *IstanbulConfig) fork(name string) *IstanbulForkConfig {
+	for _, fork := range c.Forks {
+		if fork.Name == name {
+			return fork
+		}
+	}
+	return nil
+}
+
+// IstanbulThresholdConfig switches the committed seals of blocks from a list of
+// individual validator signatures to a single BLS threshold signature of a group
+// key shared among the members through a distributed key generation. Any
+// Threshold members can assemble it, so it should be the commit quorum of the
+// validators: high enough to prove finality, low enough to stay reachable.
+type IstanbulThresholdConfig struct {
+	Block        *big.Int         `json:"block"`        // Block from which on the committed seals are threshold signatures
+	Threshold    uint64           `json:"threshold"`    // Number of partial seals needed to assemble a committed seal
+	Members      []common.Address `json:"members"`      // Validators holding a key share, in share index order
+	GroupKey     hexutil.Bytes    `json:"groupKey"`     // Public key the assembled seals are verified against
+	PublicShares []hexutil.Bytes  `json:"publicShares"` // Public keys of the members' shares, to verify partial seals with
+}
+
+// IsActive returns whether threshold seals are required at the given block.
+func (c *IstanbulThresholdConfig) IsActive(num *big.Int) bool {
+	return c != nil && isForked(c.Block, num)
+}
+
+// MemberIndex returns the share index (starting from 1) of a member, or 0 if the
+// address holds no share.
+func (c *IstanbulThresholdConfig) MemberIndex(address common.Address) uint64 {
+	for i, member := range c.Members {
+		if member == address {
+			return uint64(i + 1)
+		}
+	}
+	return 0
+}
+
+// IstanbulUpgradeConfig is a named protocol upgrade validators signal readiness
+// for in the headers they propose. It activates Delay blocks after the end of
+// the first epoch in which more than two thirds of the validators signaled.
+type IstanbulUpgradeConfig struct {
+	Name  string `json:"name"`  // Name of the upgrade signaled for
+	Delay uint64 `json:"delay"` // Blocks between reaching the signaling threshold and activation
+}
+
+// IstanbulRewardConfig is the block reward and transaction fee distribution
+// policy applied by the Istanbul engine when finalizing a block.
+type IstanbulRewardConfig struct {
+	BlockReward *big.Int        `json:"blockReward,omitempty"` // Wei minted for every block
+	SealerShare uint64          `json:"sealerShare,omitempty"` // Percentage of the reward split among the parent block's committers, the rest goes to the proposer
+	BurnFees    bool            `json:"burnFees,omitempty"`    // Destroy the transaction fees instead of paying them out
+	Treasury    *common.Address `json:"treasury,omitempty"`    // Recipient of the transaction fees (nil = coinbase)
+
+	// ParticipationWindow weighs each committer's part of the sealer share by the
+	// fraction of this many preceding blocks carrying its commit seal, the part
+	// withheld from unreliable validators going to the proposer.
+	ParticipationWindow uint64 `json:"participationWindow,omitempty"` // Blocks to measure participation over (0 = even split)
+}
+
+// IstanbulSystemCallConfig is a system contract the Istanbul engine calls at the
+// end of every block, e.g. to rotate validators or distribute fees.
+type IstanbulSystemCallConfig struct {
+	Contract common.Address `json:"contract"`       // Address of the system contract to call
+	Block    *big.Int       `json:"block"`          // Block from which on to call the contract (nil = disabled, 0 = from genesis)
+	Gas      uint64         `json:"gas,omitempty"`  // Gas allowance of the call (0 = default)
+	Data     hexutil.Bytes  `json:"data,omitempty"` // Call data passed to the contract
+}
+
+// IsActive returns whether the system contract is called at num.
+func (c *IstanbulSystemCallConfig) IsActive(num *big.Int) bool {
+	return isForked(c.Block, num)
 }
 
 // String implements the stringer interface, returning the consensus engine details.
@@ -200,6 +354,36 @@ func (c *ChainConfig) String() string {
 	)
 }
 
+// Fingerprint returns a hash of the consensus rules of the chain configuration,
+// allowing nodes to make sure they agree on the fork rules, engine parameters and
+// gas costs without exchanging the entire config. Fields not affecting consensus,
+// like the EIP150 sync hint, are left out.
+func (c *ChainConfig) Fingerprint() (h common.Hash) {
+	blob, err := json.Marshal([]interface{}{
+		c.ChainId,
+		c.HomesteadBlock,
+		c.DAOForkBlock,
+		c.DAOForkSupport,
+		c.EIP150Block,
+		c.EIP155Block,
+		c.EIP158Block,
+		c.ByzantiumBlock,
+		c.ConstantinopleBlock,
+		c.Ethash != nil,
+		c.Clique,
+		c.Istanbul,
+		c.Precompiles,
+		c.GasOverrides,
+	})
+	if err != nil {
+		panic(fmt.Sprintf("failed to encode chain config: %v", err))
+	}
+	hw := sha3.NewKeccak256()
+	hw.Write(blob)
+	hw.Sum(h[:0])
+	return h
+}
+
 // IsHomestead returns whether num is either equal to the homestead block or greater.
 func (c *ChainConfig) IsHomestead(num *big.Int) bool {
 	return isForked(c.HomesteadBlock, num)
@@ -230,6 +414,39 @@ func (c *ChainConfig) IsConstantinople(num *big.Int) bool {
 	return isForked(c.ConstantinopleBlock, num)
 }
 
+// IsActive returns whether the precompiled contract is installed at num.
+func (c *PrecompileConfig) IsActive(num *big.Int) bool {
+	return isForked(c.Block, num)
+}
+
+// IsActive returns whether the repricing is in effect at num.
+func (c *GasOverrideConfig) IsActive(num *big.Int) bool {
+	return isForked(c.Block, num)
+}
+
+// ActiveGasOverrides returns the opcode and precompiled contract costs overridden at
+// num, nil if nothing is repriced.
+func (c *ChainConfig) ActiveGasOverrides(num *big.Int) (opcodes map[string]uint64, precompiles map[common.Address]uint64) {
+	for _, o := range c.GasOverrides {
+		if !o.IsActive(num) {
+			continue
+		}
+		for op, gas := range o.Opcodes {
+			if opcodes == nil {
+				opcodes = make(map[string]uint64)
+			}
+			opcodes[op] = gas
+		}
+		for addr, gas := range o.Precompiles {
+			if precompiles == nil {
+				precompiles = make(map[common.Address]uint64)
+			}
+			precompiles[addr] = gas
+		}
+	}
+	return opcodes, precompiles
+}
+
 // GasTable returns the gas table corresponding to the current phase (homestead or homestead reprice).
 //
 // The returned GasTable's fields shouldn't, under any circumstances, be changed.
@@ -293,9 +510,186 @@ func (c *ChainConfig) checkCompatible(newcfg *ChainConfig, head *big.Int) *Confi
 	if isForkIncompatible(c.ConstantinopleBlock, newcfg.ConstantinopleBlock, head) {
 		return newCompatError("Constantinople fork block", c.ConstantinopleBlock, newcfg.ConstantinopleBlock)
 	}
+	if old, cur := c.systemCall(), newcfg.systemCall(); old != nil || cur != nil {
+		var oldBlock, curBlock *big.Int
+		if old != nil {
+			oldBlock = old.Block
+		}
+		if cur != nil {
+			curBlock = cur.Block
+		}
+		if isForkIncompatible(oldBlock, curBlock, head) {
+			return newCompatError("Istanbul system call block", oldBlock, curBlock)
+		}
+		if old != nil && cur != nil && old.IsActive(head) && (old.Contract != cur.Contract || old.Gas != cur.Gas || !bytes.Equal(old.Data, cur.Data)) {
+			return newCompatError("Istanbul system call", oldBlock, curBlock)
+		}
+	}
+	// Istanbul forks are matched by name, the ones already activated can't change
+	for _, old := range c.istanbulForks() {
+		var block *big.Int
+		if cur := newcfg.istanbulFork(old.Name); cur != nil {
+			if old.IsActive(head) && !configPolicyEqual(old.ProposerPolicy, cur.ProposerPolicy) {
+				return newCompatError(fmt.Sprintf("Istanbul fork %s policy", old.Name), old.Block, cur.Block)
+			}
+			block = cur.Block
+		}
+		if isForkIncompatible(old.Block, block, head) {
+			return newCompatError(fmt.Sprintf("Istanbul fork %s block", old.Name), old.Block, block)
+		}
+	}
+	for _, cur := range newcfg.istanbulForks() {
+		if cur.IsActive(head) && c.istanbulFork(cur.Name) == nil {
+			return newCompatError(fmt.Sprintf("Istanbul fork %s block", cur.Name), nil, cur.Block)
+		}
+	}
+	// Precompiles are matched by address, the ones already activated can't change
+	for _, old := range c.Precompiles {
+		var block *big.Int
+		if cur := newcfg.precompile(old.Address); cur != nil {
+			if cur.Name != old.Name && old.IsActive(head) {
+				return newCompatError(fmt.Sprintf("precompile %x name", old.Address), old.Block, cur.Block)
+			}
+			block = cur.Block
+		}
+		if isForkIncompatible(old.Block, block, head) {
+			return newCompatError(fmt.Sprintf("precompile %x activation block", old.Address), old.Block, block)
+		}
+	}
+	for _, cur := range newcfg.Precompiles {
+		if cur.IsActive(head) && c.precompile(cur.Address) == nil {
+			return newCompatError(fmt.Sprintf("precompile %x activation block", cur.Address), nil, cur.Block)
+		}
+	}
+	// Gas overrides are matched by name, the ones already activated can't change
+	for _, old := range c.GasOverrides {
+		var block *big.Int
+		if cur := newcfg.gasOverride(old.Name); cur != nil {
+			if old.IsActive(head) && !gasOverridesEqual(old, cur) {
+				return newCompatError(fmt.Sprintf("gas override %s costs", old.Name), old.Block, cur.Block)
+			}
+			block = cur.Block
+		}
+		if isForkIncompatible(old.Block, block, head) {
+			return newCompatError(fmt.Sprintf("gas override %s block", old.Name), old.Block, block)
+		}
+	}
+	for _, cur := range newcfg.GasOverrides {
+		if cur.IsActive(head) && c.gasOverride(cur.Name) == nil {
+			return newCompatError(fmt.Sprintf("gas override %s block", cur.Name), nil, cur.Block)
+		}
+	}
+	return nil
+}
+
+// ScheduledFork is a named fork of the chain and its activation block.
+type ScheduledFork struct {
+	Name  string
+	Block *big.Int
+}
+
+// Schedule returns the forks of the chain with an activation block configured,
+// including the Istanbul engine forks, extension precompiles and gas overrides.
+func (c *ChainConfig) Schedule() []*ScheduledFork {
+	var forks []*ScheduledFork
+	add := func(name string, block *big.Int) {
+		if block != nil {
+			forks = append(forks, &ScheduledFork{Name: name, Block: block})
+		}
+	}
+	add("homestead", c.HomesteadBlock)
+	if c.DAOForkSupport {
+		add("dao", c.DAOForkBlock)
+	}
+	add("eip150", c.EIP150Block)
+	add("eip155", c.EIP155Block)
+	add("eip158", c.EIP158Block)
+	add("byzantium", c.ByzantiumBlock)
+	add("constantinople", c.ConstantinopleBlock)
+
+	if c.Istanbul != nil {
+		if c.Istanbul.SystemCall != nil {
+			add("istanbul.systemCall", c.Istanbul.SystemCall.Block)
+		}
+		if c.Istanbul.Threshold != nil {
+			add("istanbul.threshold", c.Istanbul.Threshold.Block)
+		}
+		for _, fork := range c.Istanbul.Forks {
+			add("istanbul."+fork.Name, fork.Block)
+		}
+	}
+	for _, p := range c.Precompiles {
+		add("precompile."+p.Name, p.Block)
+	}
+	for _, o := range c.GasOverrides {
+		add("gas."+o.Name, o.Block)
+	}
+	return forks
+}
+
+// istanbulForks returns the Istanbul engine forks configured, if any.
+func (c *ChainConfig) istanbulForks() []*IstanbulForkConfig {
+	if c.Istanbul == nil {
+		return nil
+	}
+	return c.Istanbul.Forks
+}
+
+// istanbulFork returns the Istanbul engine fork with the given name, if any.
+func (c *ChainConfig) istanbulFork(name string) *IstanbulForkConfig {
+	if c.Istanbul == nil {
+		return nil
+	}
+	return c.Istanbul.fork(name)
+}
+
+// systemCall returns the Istanbul system contract call configured, if any.
+func (c *ChainConfig) systemCall() *IstanbulSystemCallConfig {
+	if c.Istanbul == nil {
+		return nil
+	}
+	return c.Istanbul.SystemCall
+}
+
+// precompile returns the extension precompiled contract configured at an address.
+func (c *ChainConfig) precompile(addr common.Address) *PrecompileConfig {
+	for _, p := range c.Precompiles {
+		if p.Address == addr {
+			return p
+		}
+	}
 	return nil
 }
 
+// gasOverride returns the gas override configured with the given name, if any.
+func (c *ChainConfig) gasOverride(name string) *GasOverrideConfig {
+	for _, o := range c.GasOverrides {
+		if o.Name == name {
+			return o
+		}
+	}
+	return nil
+}
+
+// gasOverridesEqual returns whether two gas overrides reprice the same opcodes
+// and precompiled contracts to the same costs.
+func gasOverridesEqual(x, y *GasOverrideConfig) bool {
+	if len(x.Opcodes) != len(y.Opcodes) || len(x.Precompiles) != len(y.Precompiles) {
+		return false
+	}
+	for op, gas := range x.Opcodes {
+		if have, ok := y.Opcodes[op]; !ok || have != gas {
+			return false
+		}
+	}
+	for addr, gas := range x.Precompiles {
+		if have, ok := y.Precompiles[addr]; !ok || have != gas {
+			return false
+		}
+	}
+	return true
+}
+
 // isForkIncompatible returns true if a fork scheduled at s1 cannot be rescheduled to
 // block s2 because head is already past the fork.
 func isForkIncompatible(s1, s2, head *big.Int) bool {
@@ -310,6 +704,14 @@ func isForked(s, head *big.Int) bool {
 	return s.Cmp(head) <= 0
 }
 
+// configPolicyEqual returns whether two optional policy settings are equal.
+func configPolicyEqual(x, y *uint64) bool {
+	if x == nil || y == nil {
+		return x == y
+	}
+	return *x == *y
+}
+
 func configNumEqual(x, y *big.Int) bool {
 	if x == nil {
 		return y == nil
@@ -20,6 +20,8 @@ import (
 	"math/big"
 	"reflect"
 	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
 )
 
 func TestCheckCompatible(t *testing.T) {
@@ -70,6 +72,101 @@ func TestCheckCompatible(t *testing.T) {
 				RewindTo:     9,
 			},
 		},
+		{
+			stored:  &ChainConfig{Precompiles: []*PrecompileConfig{{Name: "sha512", Address: common.Address{0x10}, Block: big.NewInt(10)}}},
+			new:     &ChainConfig{Precompiles: []*PrecompileConfig{{Name: "sha512", Address: common.Address{0x10}, Block: big.NewInt(20)}}},
+			head:    9,
+			wantErr: nil,
+		},
+		{
+			stored: &ChainConfig{Precompiles: []*PrecompileConfig{{Name: "sha512", Address: common.Address{0x10}, Block: big.NewInt(10)}}},
+			new:    &ChainConfig{},
+			head:   15,
+			wantErr: &ConfigCompatError{
+				What:         "precompile 1000000000000000000000000000000000000000 activation block",
+				StoredConfig: big.NewInt(10),
+				NewConfig:    nil,
+				RewindTo:     9,
+			},
+		},
+		{
+			stored: &ChainConfig{Precompiles: []*PrecompileConfig{{Name: "sha512", Address: common.Address{0x10}, Block: big.NewInt(10)}}},
+			new:    &ChainConfig{Precompiles: []*PrecompileConfig{{Name: "ed25519Verify", Address: common.Address{0x10}, Block: big.NewInt(10)}}},
+			head:   15,
+			wantErr: &ConfigCompatError{
+				What:         "precompile 1000000000000000000000000000000000000000 name",
+				StoredConfig: big.NewInt(10),
+				NewConfig:    big.NewInt(10),
+				RewindTo:     9,
+			},
+		},
+		{
+			stored:  &ChainConfig{GasOverrides: []*GasOverrideConfig{{Name: "sstore", Block: big.NewInt(10), Opcodes: map[string]uint64{"SSTORE": 50000}}}},
+			new:     &ChainConfig{GasOverrides: []*GasOverrideConfig{{Name: "sstore", Block: big.NewInt(10), Opcodes: map[string]uint64{"SSTORE": 80000}}}},
+			head:    9,
+			wantErr: nil,
+		},
+		{
+			stored: &ChainConfig{GasOverrides: []*GasOverrideConfig{{Name: "sstore", Block: big.NewInt(10), Opcodes: map[string]uint64{"SSTORE": 50000}}}},
+			new:    &ChainConfig{GasOverrides: []*GasOverrideConfig{{Name: "sstore", Block: big.NewInt(10), Opcodes: map[string]uint64{"SSTORE": 80000}}}},
+			head:   15,
+			wantErr: &ConfigCompatError{
+				What:         "gas override sstore costs",
+				StoredConfig: big.NewInt(10),
+				NewConfig:    big.NewInt(10),
+				RewindTo:     9,
+			},
+		},
+		{
+			stored: &ChainConfig{},
+			new:    &ChainConfig{GasOverrides: []*GasOverrideConfig{{Name: "sstore", Block: big.NewInt(10), Opcodes: map[string]uint64{"SSTORE": 50000}}}},
+			head:   15,
+			wantErr: &ConfigCompatError{
+				What:         "gas override sstore block",
+				StoredConfig: nil,
+				NewConfig:    big.NewInt(10),
+				RewindTo:     9,
+			},
+		},
+		{
+			stored: &ChainConfig{Istanbul: &IstanbulConfig{SystemCall: &IstanbulSystemCallConfig{Contract: common.Address{0x10}, Block: big.NewInt(10)}}},
+			new:    &ChainConfig{Istanbul: &IstanbulConfig{SystemCall: &IstanbulSystemCallConfig{Contract: common.Address{0x20}, Block: big.NewInt(10)}}},
+			head:   15,
+			wantErr: &ConfigCompatError{
+				What:         "Istanbul system call",
+				StoredConfig: big.NewInt(10),
+				NewConfig:    big.NewInt(10),
+				RewindTo:     9,
+			},
+		},
+		{
+			stored:  &ChainConfig{Istanbul: &IstanbulConfig{Forks: []*IstanbulForkConfig{{Name: "sticky", Block: big.NewInt(10)}}}},
+			new:     &ChainConfig{Istanbul: &IstanbulConfig{Forks: []*IstanbulForkConfig{{Name: "sticky", Block: big.NewInt(20)}}}},
+			head:    9,
+			wantErr: nil,
+		},
+		{
+			stored: &ChainConfig{Istanbul: &IstanbulConfig{Forks: []*IstanbulForkConfig{{Name: "sticky", Block: big.NewInt(10)}}}},
+			new:    &ChainConfig{},
+			head:   15,
+			wantErr: &ConfigCompatError{
+				What:         "Istanbul fork sticky block",
+				StoredConfig: big.NewInt(10),
+				NewConfig:    nil,
+				RewindTo:     9,
+			},
+		},
+		{
+			stored: &ChainConfig{Istanbul: &IstanbulConfig{Forks: []*IstanbulForkConfig{{Name: "sticky", Block: big.NewInt(10)}}}},
+			new:    &ChainConfig{Istanbul: &IstanbulConfig{Forks: []*IstanbulForkConfig{{Name: "sticky", Block: big.NewInt(10), ProposerPolicy: new(uint64)}}}},
+			head:   15,
+			wantErr: &ConfigCompatError{
+				What:         "Istanbul fork sticky policy",
+				StoredConfig: big.NewInt(10),
+				NewConfig:    big.NewInt(10),
+				RewindTo:     9,
+			},
+		},
 	}
 
 	for _, test := range tests {
@@ -79,3 +176,52 @@ func TestCheckCompatible(t *testing.T) {
 		}
 	}
 }
+
+// Tests that the gas overrides active at a block are merged, later ones taking
+// precedence.
+func TestGasOverrides(t *testing.T) {
+	config := &ChainConfig{GasOverrides: []*GasOverrideConfig{
+		{Name: "first", Block: big.NewInt(10), Opcodes: map[string]uint64{"SSTORE": 50000, "SLOAD": 1000}, Precompiles: map[common.Address]uint64{{0x01}: 5000}},
+		{Name: "second", Block: big.NewInt(20), Opcodes: map[string]uint64{"SSTORE": 80000}},
+		{Name: "disabled", Opcodes: map[string]uint64{"SSTORE": 0}},
+	}}
+	if opcodes, precompiles := config.ActiveGasOverrides(big.NewInt(9)); opcodes != nil || precompiles != nil {
+		t.Errorf("overrides active before activation: %v, %v", opcodes, precompiles)
+	}
+	opcodes, precompiles := config.ActiveGasOverrides(big.NewInt(10))
+	if len(opcodes) != 2 || opcodes["SSTORE"] != 50000 || opcodes["SLOAD"] != 1000 || precompiles[common.Address{0x01}] != 5000 {
+		t.Errorf("first overrides mismatch: %v, %v", opcodes, precompiles)
+	}
+	opcodes, _ = config.ActiveGasOverrides(big.NewInt(20))
+	if len(opcodes) != 2 || opcodes["SSTORE"] != 80000 || opcodes["SLOAD"] != 1000 {
+		t.Errorf("merged overrides mismatch: %v", opcodes)
+	}
+	// Repricing changes the config fingerprint
+	repriced := &ChainConfig{GasOverrides: []*GasOverrideConfig{{Name: "first", Block: big.NewInt(10), Opcodes: map[string]uint64{"SSTORE": 50001}}}}
+	if config.Fingerprint() == repriced.Fingerprint() {
+		t.Errorf("fingerprint ignores gas overrides")
+	}
+}
+
+// Tests that the config fingerprint covers the consensus rules only.
+func TestFingerprint(t *testing.T) {
+	base := *TestChainConfig
+
+	hinted := base
+	hinted.EIP150Hash = common.Hash{0x01}
+	if base.Fingerprint() != hinted.Fingerprint() {
+		t.Errorf("fingerprint covers the EIP150 sync hint")
+	}
+	forked := base
+	forked.ConstantinopleBlock = big.NewInt(10)
+	if base.Fingerprint() == forked.Fingerprint() {
+		t.Errorf("fingerprint ignores fork blocks")
+	}
+	istanbul := base
+	istanbul.Istanbul = &IstanbulConfig{Epoch: 30000}
+	other := base
+	other.Istanbul = &IstanbulConfig{Epoch: 100}
+	if istanbul.Fingerprint() == other.Fingerprint() {
+		t.Errorf("fingerprint ignores engine parameters")
+	}
+}
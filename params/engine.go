@@ -0,0 +1,129 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package params
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Names of the consensus engines selectable through the chain config. There is
+// no standalone PBFT engine, Istanbul being the PBFT variant this client runs.
+const (
+	EngineEthash   = "ethash"
+	EngineClique   = "clique"
+	EngineIstanbul = "istanbul"
+)
+
+// istanbulPolicies is the number of proposer selection policies the Istanbul
+// engine supports: round robin (0) and sticky (1).
+const istanbulPolicies = 2
+
+var errNoEngineParams = errors.New("missing consensus engine parameter")
+
+// Engine returns the name of the consensus engine the chain config selects.
+// Chains without any engine section run proof-of-work.
+func (c *ChainConfig) Engine() string {
+	switch {
+	case c.Clique != nil:
+		return EngineClique
+	case c.Istanbul != nil:
+		return EngineIstanbul
+	default:
+		return EngineEthash
+	}
+}
+
+// CheckEngine verifies that the chain config selects a single consensus engine
+// and carries all the parameters it needs, so misconfigured chains are refused
+// at startup instead of silently falling back to defaults.
+func (c *ChainConfig) CheckEngine() error {
+	var engines []string
+	if c.Ethash != nil {
+		engines = append(engines, EngineEthash)
+	}
+	if c.Clique != nil {
+		engines = append(engines, EngineClique)
+	}
+	if c.Istanbul != nil {
+		engines = append(engines, EngineIstanbul)
+	}
+	if len(engines) > 1 {
+		return fmt.Errorf("multiple consensus engines configured: %s", strings.Join(engines, ", "))
+	}
+	switch c.Engine() {
+	case EngineClique:
+		if c.Clique.Epoch == 0 {
+			return fmt.Errorf("clique: %v: epoch", errNoEngineParams)
+		}
+	case EngineIstanbul:
+		if err := c.Istanbul.check(); err != nil {
+			return fmt.Errorf("istanbul: %v", err)
+		}
+	}
+	return nil
+}
+
+// check verifies the Istanbul engine parameters.
+func (c *IstanbulConfig) check() error {
+	if c.ProposerPolicy >= istanbulPolicies {
+		return fmt.Errorf("unknown proposer policy %d", c.ProposerPolicy)
+	}
+	if c.Reward != nil && c.Reward.SealerShare > 100 {
+		return fmt.Errorf("sealer share %d%% above 100%%", c.Reward.SealerShare)
+	}
+	if c.SystemCall != nil && c.SystemCall.Block != nil && c.SystemCall.Contract == (common.Address{}) {
+		return fmt.Errorf("%v: system call contract", errNoEngineParams)
+	}
+	if t := c.Threshold; t != nil && t.Block != nil {
+		switch {
+		case len(t.GroupKey) == 0:
+			return fmt.Errorf("%v: threshold group key", errNoEngineParams)
+		case t.Threshold == 0 || t.Threshold > uint64(len(t.Members)):
+			return fmt.Errorf("threshold %d out of range for %d members", t.Threshold, len(t.Members))
+		case len(t.PublicShares) != len(t.Members):
+			return fmt.Errorf("%d public shares for %d threshold members", len(t.PublicShares), len(t.Members))
+		}
+	}
+	return c.checkForks()
+}
+
+// checkForks verifies that the scheduled forks have unique names, are ordered by
+// activation block and only switch to known proposer policies.
+func (c *IstanbulConfig) checkForks() error {
+	seen := make(map[string]bool)
+	for i, fork := range c.Forks {
+		if fork.Name == "" || seen[fork.Name] {
+			return fmt.Errorf("fork #%d: missing or duplicate name %q", i, fork.Name)
+		}
+		seen[fork.Name] = true
+
+		if fork.Block == nil {
+			continue
+		}
+		if i > 0 && c.Forks[i-1].Block != nil && c.Forks[i-1].Block.Cmp(fork.Block) > 0 {
+			return fmt.Errorf("fork %s: activation block %v before previous fork's %v", fork.Name, fork.Block, c.Forks[i-1].Block)
+		}
+		if fork.ProposerPolicy != nil && *fork.ProposerPolicy >= istanbulPolicies {
+			return fmt.Errorf("fork %s: unknown proposer policy %d", fork.Name, *fork.ProposerPolicy)
+		}
+	}
+	return nil
+}
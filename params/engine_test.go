@@ -0,0 +1,57 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package params
+
+import (
+	"math/big"
+	"testing"
+)
+
+// Tests that the consensus engine is selected from the chain config and that
+// incomplete engine sections are refused.
+func TestCheckEngine(t *testing.T) {
+	policy := uint64(2)
+	tests := []struct {
+		config *ChainConfig
+		engine string
+		fail   bool
+	}{
+		{&ChainConfig{}, EngineEthash, false},
+		{MainnetChainConfig, EngineEthash, false},
+		{RinkebyChainConfig, EngineClique, false},
+		{OttomanChainConfig, EngineIstanbul, false},
+		{&ChainConfig{Clique: &CliqueConfig{Period: 15}}, EngineClique, true},
+		{&ChainConfig{Ethash: new(EthashConfig), Clique: &CliqueConfig{Epoch: 30000}}, EngineClique, true},
+		{&ChainConfig{Istanbul: &IstanbulConfig{}}, EngineIstanbul, false},
+		{&ChainConfig{Istanbul: &IstanbulConfig{Epoch: 30000, ProposerPolicy: 2}}, EngineIstanbul, true},
+		{&ChainConfig{Istanbul: &IstanbulConfig{Epoch: 30000, Reward: &IstanbulRewardConfig{SealerShare: 101}}}, EngineIstanbul, true},
+		{&ChainConfig{Istanbul: &IstanbulConfig{Epoch: 30000, SystemCall: &IstanbulSystemCallConfig{Block: big.NewInt(1)}}}, EngineIstanbul, true},
+		{&ChainConfig{Istanbul: &IstanbulConfig{Epoch: 30000, Forks: []*IstanbulForkConfig{{Name: "sticky", Block: big.NewInt(1), ProposerPolicy: &policy}}}}, EngineIstanbul, true},
+	}
+	for i, tt := range tests {
+		if engine := tt.config.Engine(); engine != tt.engine {
+			t.Errorf("test %d: engine mismatch: have %s, want %s", i, engine, tt.engine)
+		}
+		err := tt.config.CheckEngine()
+		if tt.fail && err == nil {
+			t.Errorf("test %d: invalid config accepted", i)
+		}
+		if !tt.fail && err != nil {
+			t.Errorf("test %d: valid config rejected: %v", i, err)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+var (
+	errMissingToken = errors.New("missing bearer token")
+	errUnknownToken = errors.New("unknown token identifier")
+)
+
+// TokenPolicy defines what a single authentication token is allowed to do.
+type TokenPolicy struct {
+	Namespaces []string `json:"namespaces"` // API namespaces the token may call in full (e.g. "eth")
+	Methods    []string `json:"methods"`    // Individual methods the token may call (e.g. "admin_peers")
+	Rate       float64  `json:"rate"`       // Sustained requests per second allowed (0 = unlimited)
+	Burst      int      `json:"burst"`      // Maximum burst of requests above the sustained rate
+
+	namespaces map[string]bool
+	methods    map[string]bool
+	limiter    *rateLimiter
+}
+
+// AuthConfig is the on-disk configuration of the RPC authentication layer.
+type AuthConfig struct {
+	Secret string                  `json:"secret"` // Hex encoded HMAC secret used to sign tokens
+	Tokens map[string]*TokenPolicy `json:"tokens"` // Policies keyed by the token's subject claim
+}
+
+// Authenticator verifies JWT bearer tokens on incoming HTTP and WebSocket RPC
+// requests and enforces the access policy associated with each token.
+//
+// Tokens must be HS256 signed with the shared secret and carry the identifier
+// of their policy in the "sub" claim. Any "exp", "nbf" and "iat" claims present
+// are validated too.
+type Authenticator struct {
+	secret []byte
+	tokens map[string]*TokenPolicy
+}
+
+// LoadAuthConfig reads an authentication configuration from a JSON file and
+// creates an authenticator from it.
+func LoadAuthConfig(path string) (*Authenticator, error) {
+	blob, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var config AuthConfig
+	if err := json.Unmarshal(blob, &config); err != nil {
+		return nil, fmt.Errorf("invalid auth config %s: %v", path, err)
+	}
+	return NewAuthenticator(&config)
+}
+
+// NewAuthenticator creates an authenticator from the given configuration.
+func NewAuthenticator(config *AuthConfig) (*Authenticator, error) {
+	secret, err := hex.DecodeString(strings.TrimPrefix(config.Secret, "0x"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid auth secret: %v", err)
+	}
+	if len(secret) < 32 {
+		return nil, fmt.Errorf("auth secret too short: have %d bytes, want at least 32", len(secret))
+	}
+	auth := &Authenticator{
+		secret: secret,
+		tokens: make(map[string]*TokenPolicy),
+	}
+	for id, policy := range config.Tokens {
+		policy.namespaces = make(map[string]bool)
+		for _, namespace := range policy.Namespaces {
+			policy.namespaces[namespace] = true
+		}
+		policy.methods = make(map[string]bool)
+		for _, method := range policy.Methods {
+			policy.methods[method] = true
+		}
+		policy.limiter = newRateLimiter(policy.Rate, policy.Burst)
+		auth.tokens[id] = policy
+	}
+	return auth, nil
+}
+
+// authenticate validates the bearer token of the given request and returns the
+// policy it is entitled to.
+func (a *Authenticator) authenticate(r *http.Request) (*TokenPolicy, error) {
+	header := r.Header.Get("Authorization")
+	if !strings.HasPrefix(header, "Bearer ") {
+		return nil, errMissingToken
+	}
+	claims := new(jwt.StandardClaims)
+	_, err := jwt.ParseWithClaims(strings.TrimPrefix(header, "Bearer "), claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method %v", token.Header["alg"])
+		}
+		return a.secret, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	policy, ok := a.tokens[claims.Subject]
+	if !ok {
+		return nil, errUnknownToken
+	}
+	return policy, nil
+}
+
+// Handler wraps an HTTP or WebSocket RPC handler, rejecting requests without a
+// valid token and attaching the token's policy to the request context for the
+// server to enforce on every call.
+func (a *Authenticator) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// CORS preflight requests never carry credentials
+		if r.Method == http.MethodOptions {
+			next.ServeHTTP(w, r)
+			return
+		}
+		policy, err := a.authenticate(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), policyKey{}, policy)))
+	})
+}
+
+// policyKey is the context key under which the token policy is stored.
+type policyKey struct{}
+
+// policyFromContext retrieves the token policy of the request, if any.
+func policyFromContext(ctx context.Context) *TokenPolicy {
+	policy, _ := ctx.Value(policyKey{}).(*TokenPolicy)
+	return policy
+}
+
+// withPolicy copies the token policy (if any) from src into dst.
+func withPolicy(dst, src context.Context) context.Context {
+	if policy := policyFromContext(src); policy != nil {
+		return context.WithValue(dst, policyKey{}, policy)
+	}
+	return dst
+}
+
+// check verifies that the given method may be invoked under the policy and that
+// the token did not exceed its rate limit.
+func (p *TokenPolicy) check(namespace, method string) Error {
+	if !p.namespaces[namespace] && !p.methods[namespace+serviceMethodSeparator+method] {
+		return &unauthorizedError{namespace, method}
+	}
+	if !p.limiter.allow() {
+		return &limitExceededError{"rate limit exceeded"}
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+const testAuthSecret = "0x0102030405060708091011121314151617181920212223242526272829303132"
+
+func newTestAuthServer(t *testing.T) *httptest.Server {
+	auth, err := NewAuthenticator(&AuthConfig{
+		Secret: testAuthSecret,
+		Tokens: map[string]*TokenPolicy{
+			"full":    {Namespaces: []string{"service"}},
+			"echo":    {Methods: []string{"service_echo"}},
+			"limited": {Namespaces: []string{"service"}, Rate: 0.001, Burst: 2},
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to create authenticator: %v", err)
+	}
+	return httptest.NewServer(auth.Handler(newTestServer("service", new(Service))))
+}
+
+func signTestToken(t *testing.T, subject string, key []byte) string {
+	if key == nil {
+		key, _ = hex.DecodeString(testAuthSecret[2:])
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.StandardClaims{
+		Subject:  subject,
+		IssuedAt: time.Now().Unix(),
+	}).SignedString(key)
+	if err != nil {
+		t.Fatalf("failed to sign token: %v", err)
+	}
+	return token
+}
+
+// authCall issues a raw JSON-RPC call with the given bearer token, returning the
+// HTTP status code and the error code of the response (0 if none).
+func authCall(t *testing.T, url, token, method string) (int, int) {
+	body := `{"jsonrpc":"2.0","id":1,"method":"` + method + `","params":["a",1,null]}`
+	req, _ := http.NewRequest(http.MethodPost, url, strings.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return resp.StatusCode, 0
+	}
+	var res struct {
+		Error *jsonError `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if res.Error != nil {
+		return resp.StatusCode, res.Error.Code
+	}
+	return resp.StatusCode, 0
+}
+
+func TestAuthRejectsInvalidTokens(t *testing.T) {
+	server := newTestAuthServer(t)
+	defer server.Close()
+
+	if status, _ := authCall(t, server.URL, "", "service_echo"); status != http.StatusUnauthorized {
+		t.Errorf("missing token: status mismatch: have %d, want %d", status, http.StatusUnauthorized)
+	}
+	if status, _ := authCall(t, server.URL, "garbage", "service_echo"); status != http.StatusUnauthorized {
+		t.Errorf("malformed token: status mismatch: have %d, want %d", status, http.StatusUnauthorized)
+	}
+	forged := signTestToken(t, "full", []byte("another secret of thirty-two bytes"))
+	if status, _ := authCall(t, server.URL, forged, "service_echo"); status != http.StatusUnauthorized {
+		t.Errorf("forged token: status mismatch: have %d, want %d", status, http.StatusUnauthorized)
+	}
+	unknown := signTestToken(t, "unknown", nil)
+	if status, _ := authCall(t, server.URL, unknown, "service_echo"); status != http.StatusUnauthorized {
+		t.Errorf("unknown token: status mismatch: have %d, want %d", status, http.StatusUnauthorized)
+	}
+}
+
+func TestAuthAccessControl(t *testing.T) {
+	server := newTestAuthServer(t)
+	defer server.Close()
+
+	tests := []struct {
+		token  string
+		method string
+		code   int
+	}{
+		{"full", "service_echo", 0},
+		{"full", "service_rets", 0},
+		{"echo", "service_echo", 0},
+		{"echo", "service_rets", -32001},
+		{"echo", "rpc_modules", -32001},
+	}
+	for i, tt := range tests {
+		status, code := authCall(t, server.URL, signTestToken(t, tt.token, nil), tt.method)
+		if status != http.StatusOK {
+			t.Errorf("test %d: status mismatch: have %d, want %d", i, status, http.StatusOK)
+		}
+		if code != tt.code {
+			t.Errorf("test %d: error code mismatch: have %d, want %d", i, code, tt.code)
+		}
+	}
+}
+
+func TestAuthRateLimit(t *testing.T) {
+	server := newTestAuthServer(t)
+	defer server.Close()
+
+	token := signTestToken(t, "limited", nil)
+	for i := 0; i < 2; i++ {
+		if _, code := authCall(t, server.URL, token, "service_echo"); code != 0 {
+			t.Fatalf("request %d: unexpected error code %d", i, code)
+		}
+	}
+	if _, code := authCall(t, server.URL, token, "service_echo"); code != -32005 {
+		t.Errorf("error code mismatch: have %d, want %d", code, -32005)
+	}
+}
@@ -64,3 +64,22 @@ type shutdownError struct{}
 func (e *shutdownError) ErrorCode() int { return -32000 }
 
 func (e *shutdownError) Error() string { return "server is shutting down" }
+
+// issued when the authenticated caller is not permitted to invoke a method.
+type unauthorizedError struct {
+	service string
+	method  string
+}
+
+func (e *unauthorizedError) ErrorCode() int { return -32001 }
+
+func (e *unauthorizedError) Error() string {
+	return fmt.Sprintf("access to method %s%s%s denied", e.service, serviceMethodSeparator, e.method)
+}
+
+// issued when a request exceeds one of the configured resource limits.
+type limitExceededError struct{ message string }
+
+func (e *limitExceededError) ErrorCode() int { return -32005 }
+
+func (e *limitExceededError) Error() string { return e.message }
@@ -18,6 +18,8 @@ package rpc
 
 import (
 	"bytes"
+	"compress/gzip"
+	"compress/zlib"
 	"context"
 	"encoding/json"
 	"errors"
@@ -146,14 +148,42 @@ func (t *httpReadWriteNopCloser) Close() error {
 	return nil
 }
 
+// HTTPOptions tunes the response encoding and connection handling of an HTTP
+// RPC server. Zero timeouts disable the respective timeout.
+type HTTPOptions struct {
+	ReadTimeout  time.Duration `toml:",omitempty"` // Maximum duration for reading an entire request
+	WriteTimeout time.Duration `toml:",omitempty"` // Maximum duration for writing a response
+	IdleTimeout  time.Duration `toml:",omitempty"` // Maximum time to wait for the next request on a kept alive connection
+
+	DisableKeepAlives  bool `toml:",omitempty"` // Close connections after each response
+	DisableCompression bool `toml:",omitempty"` // Never compress responses, regardless of Accept-Encoding
+}
+
+// DefaultHTTPOptions are the HTTP RPC server settings used by default.
+var DefaultHTTPOptions = HTTPOptions{
+	ReadTimeout:  30 * time.Second,
+	WriteTimeout: 30 * time.Second,
+	IdleTimeout:  120 * time.Second,
+}
+
 // NewHTTPServer creates a new HTTP RPC server around an API provider.
 //
 // Deprecated: Server implements http.Handler
-func NewHTTPServer(cors []string, vhosts []string, srv *Server) *http.Server {
-	// Wrap the CORS-handler within a host-handler
+func NewHTTPServer(cors []string, vhosts []string, opts HTTPOptions, srv *Server) *http.Server {
+	// Wrap the CORS-handler within a host-handler, compressing whatever passes
 	handler := newCorsHandler(srv, cors)
 	handler = newVHostHandler(vhosts, handler)
-	return &http.Server{Handler: handler}
+	if !opts.DisableCompression {
+		handler = newCompressionHandler(handler)
+	}
+	server := &http.Server{
+		Handler:      handler,
+		ReadTimeout:  opts.ReadTimeout,
+		WriteTimeout: opts.WriteTimeout,
+		IdleTimeout:  opts.IdleTimeout,
+	}
+	server.SetKeepAlivesEnabled(!opts.DisableKeepAlives)
+	return server
 }
 
 // ServeHTTP serves JSON-RPC requests over HTTP.
@@ -173,7 +203,11 @@ func (srv *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	defer codec.Close()
 
 	w.Header().Set("content-type", contentType)
-	srv.ServeSingleRequest(codec, OptionMethodInvocation)
+	ctx := withPolicy(context.Background(), r.Context())
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		ctx = context.WithValue(ctx, clientKey{}, host)
+	}
+	srv.serveRequest(ctx, codec, true, OptionMethodInvocation)
 }
 
 // validateRequest returns a non-zero response code and error message if the
@@ -254,3 +288,86 @@ func newVHostHandler(vhosts []string, next http.Handler) http.Handler {
 	}
 	return &virtualHostHandler{vhostMap, next}
 }
+
+// compressionHandler compresses the responses of the wrapped handler with the
+// encoding negotiated via the Accept-Encoding header of the request.
+type compressionHandler struct {
+	next http.Handler
+}
+
+func newCompressionHandler(next http.Handler) http.Handler {
+	return &compressionHandler{next}
+}
+
+// ServeHTTP implements http.Handler, compressing the response if the client
+// accepts gzip or deflate encoding.
+func (h *compressionHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var (
+		encoding   = negotiateEncoding(r.Header.Get("Accept-Encoding"))
+		compressor io.WriteCloser
+	)
+	switch encoding {
+	case "gzip":
+		compressor = gzip.NewWriter(w)
+	case "deflate":
+		compressor = zlib.NewWriter(w)
+	default:
+		h.next.ServeHTTP(w, r)
+		return
+	}
+	defer compressor.Close()
+
+	w.Header().Set("Content-Encoding", encoding)
+	w.Header().Add("Vary", "Accept-Encoding")
+	w.Header().Del("Content-Length")
+	h.next.ServeHTTP(&compressedResponseWriter{ResponseWriter: w, compressor: compressor}, r)
+}
+
+// negotiateEncoding picks the response encoding from the ones a client accepts,
+// preferring gzip over deflate. An empty string is returned if neither of them
+// is acceptable.
+func negotiateEncoding(accept string) string {
+	var gzipOK, deflateOK bool
+	for _, part := range strings.Split(accept, ",") {
+		fields := strings.Split(part, ";")
+		coding := strings.ToLower(strings.TrimSpace(fields[0]))
+
+		// Codings with a zero quality value are explicitly unacceptable
+		rejected := false
+		for _, param := range fields[1:] {
+			param = strings.Replace(param, " ", "", -1)
+			if strings.HasPrefix(param, "q=") && strings.Trim(param[2:], "0.") == "" {
+				rejected = true
+			}
+		}
+		switch coding {
+		case "gzip", "x-gzip":
+			gzipOK = !rejected
+		case "deflate":
+			deflateOK = !rejected
+		}
+	}
+	switch {
+	case gzipOK:
+		return "gzip"
+	case deflateOK:
+		return "deflate"
+	}
+	return ""
+}
+
+// compressedResponseWriter is an http.ResponseWriter writing the response body
+// through a compressor.
+type compressedResponseWriter struct {
+	http.ResponseWriter
+	compressor io.WriteCloser
+}
+
+// Write implements io.Writer, compressing the written data.
+func (w *compressedResponseWriter) Write(b []byte) (int, error) {
+	// Make sure content type sniffing happens on the uncompressed data
+	if w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", http.DetectContentType(b))
+	}
+	return w.compressor.Write(b)
+}
@@ -17,6 +17,10 @@
 package rpc
 
 import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -52,3 +56,77 @@ func testHTTPErrorResponse(t *testing.T, method, contentType, body string, expec
 		t.Fatalf("response code should be %d not %d", expected, code)
 	}
 }
+
+func TestNegotiateEncoding(t *testing.T) {
+	tests := []struct {
+		accept, want string
+	}{
+		{"", ""},
+		{"identity", ""},
+		{"gzip", "gzip"},
+		{"deflate", "deflate"},
+		{"deflate, gzip", "gzip"},
+		{"gzip;q=0, deflate", "deflate"},
+		{"gzip; q=0.0, deflate;q=0", ""},
+		{"GZIP;q=0.5", "gzip"},
+		{"br, x-gzip", "gzip"},
+	}
+	for _, tt := range tests {
+		if have := negotiateEncoding(tt.accept); have != tt.want {
+			t.Errorf("Accept-Encoding %q: encoding mismatch: have %q, want %q", tt.accept, have, tt.want)
+		}
+	}
+}
+
+// Tests that HTTP responses are compressed with the negotiated encoding.
+func TestHTTPCompression(t *testing.T) {
+	server := newTestServer("service", new(Service))
+	defer server.Stop()
+
+	tests := []struct {
+		options  HTTPOptions
+		accept   string
+		encoding string
+	}{
+		{DefaultHTTPOptions, "", ""},
+		{DefaultHTTPOptions, "gzip", "gzip"},
+		{DefaultHTTPOptions, "deflate", "deflate"},
+		{HTTPOptions{DisableCompression: true}, "gzip", ""},
+	}
+	for i, tt := range tests {
+		httpsrv := httptest.NewServer(NewHTTPServer(nil, []string{"*"}, tt.options, server).Handler)
+
+		req, _ := http.NewRequest(http.MethodPost, httpsrv.URL, strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"service_rets"}`))
+		req.Header.Set("Content-Type", contentType)
+		req.Header.Set("Accept-Encoding", tt.accept)
+
+		// Setting Accept-Encoding disables the transparent decompression of the client
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("test %d: request failed: %v", i, err)
+		}
+		if have := resp.Header.Get("Content-Encoding"); have != tt.encoding {
+			t.Errorf("test %d: content encoding mismatch: have %q, want %q", i, have, tt.encoding)
+		}
+		var body io.Reader = resp.Body
+		switch tt.encoding {
+		case "gzip":
+			if body, err = gzip.NewReader(resp.Body); err != nil {
+				t.Fatalf("test %d: invalid gzip response: %v", i, err)
+			}
+		case "deflate":
+			if body, err = zlib.NewReader(resp.Body); err != nil {
+				t.Fatalf("test %d: invalid deflate response: %v", i, err)
+			}
+		}
+		blob, err := ioutil.ReadAll(body)
+		if err != nil {
+			t.Fatalf("test %d: failed to read response: %v", i, err)
+		}
+		if !strings.Contains(string(blob), `"result":"`) {
+			t.Errorf("test %d: unexpected response: %s", i, blob)
+		}
+		resp.Body.Close()
+		httpsrv.Close()
+	}
+}
@@ -0,0 +1,119 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+// slowQueryParamsLimit is the maximum length of the parameters of a call written
+// to the slow query log, longer ones are truncated.
+const slowQueryParamsLimit = 256
+
+// methodMetrics tracks the executions of a single RPC method, shared by all the
+// servers exposing it.
+type methodMetrics struct {
+	requests metrics.Meter // Number of executed calls
+	errors   metrics.Meter // Number of calls returning an error
+	duration metrics.Timer // Execution time distribution of the calls
+}
+
+var (
+	methodMetricsSet  = make(map[string]*methodMetrics)
+	methodMetricsLock sync.Mutex
+)
+
+// metricsOf returns the metrics of an RPC method, registering them on first use
+// as rpc/<namespace>/<method>/{requests,errors,duration}.
+func metricsOf(service, method string) *methodMetrics {
+	name := service + serviceMethodSeparator + method
+
+	methodMetricsLock.Lock()
+	defer methodMetricsLock.Unlock()
+
+	if m, ok := methodMetricsSet[name]; ok {
+		return m
+	}
+	prefix := "rpc/" + service + "/" + method
+	m := &methodMetrics{
+		requests: metrics.GetOrRegisterMeter(prefix+"/requests", nil),
+		errors:   metrics.GetOrRegisterMeter(prefix+"/errors", nil),
+		duration: metrics.GetOrRegisterTimer(prefix+"/duration", nil),
+	}
+	methodMetricsSet[name] = m
+	return m
+}
+
+// mark records the execution of a call taking the given time.
+func (m *methodMetrics) mark(elapsed time.Duration, failed bool) {
+	m.requests.Mark(1)
+	if failed {
+		m.errors.Mark(1)
+	}
+	m.duration.Update(elapsed)
+}
+
+// SetSlowQueryThreshold configures the execution time above which calls are
+// written to the slow query log. Zero disables the log. It must not be called
+// concurrently with serving requests.
+func (s *Server) SetSlowQueryThreshold(threshold time.Duration) {
+	s.slowQuery = threshold
+}
+
+// logSlowQuery writes a call exceeding the slow query threshold to the log, along
+// with the client issuing it and its (truncated) parameters.
+func logSlowQuery(client string, req *serverRequest, elapsed time.Duration, err error) {
+	params := make([]interface{}, len(req.args))
+	for i, arg := range req.args {
+		params[i] = arg.Interface()
+	}
+	ctx := []interface{}{
+		"method", req.svcname + serviceMethodSeparator + req.method,
+		"client", client,
+		"elapsed", elapsed,
+		"params", truncateParams(params),
+	}
+	if err != nil {
+		ctx = append(ctx, "err", err)
+	}
+	log.Warn("Slow RPC call", ctx...)
+}
+
+// truncateParams encodes call parameters for logging, cutting them at the slow
+// query log limit.
+func truncateParams(params []interface{}) string {
+	blob, err := json.Marshal(params)
+	if err != nil {
+		// Fall back to the type names if the parameters can't be encoded
+		types := make([]string, len(params))
+		for i, param := range params {
+			types[i] = fmt.Sprintf("%T", param)
+		}
+		blob = []byte("[" + strings.Join(types, ",") + "]")
+	}
+	if len(blob) > slowQueryParamsLimit {
+		return string(blob[:slowQueryParamsLimit]) + "..."
+	}
+	return string(blob)
+}
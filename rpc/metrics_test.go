@@ -0,0 +1,90 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+type MetricsService struct{}
+
+func (s *MetricsService) Ok() bool { return true }
+
+func (s *MetricsService) Fail() error { return errors.New("failed") }
+
+// Tests that calls are accounted per method, errors included.
+func TestMethodMetrics(t *testing.T) {
+	defer func(enabled bool) { metrics.Enabled = enabled }(metrics.Enabled)
+	metrics.Enabled = true
+
+	server := newTestServer("metrics", new(MetricsService))
+	server.SetSlowQueryThreshold(time.Nanosecond)
+	defer server.Stop()
+
+	client := DialInProc(server)
+	defer client.Close()
+
+	for i := 0; i < 3; i++ {
+		if err := client.Call(nil, "metrics_ok"); err != nil {
+			t.Fatalf("call failed: %v", err)
+		}
+	}
+	if err := client.Call(nil, "metrics_fail"); err == nil {
+		t.Fatalf("failing call succeeded")
+	}
+	tests := []struct {
+		method           string
+		requests, errors int64
+	}{
+		{"ok", 3, 0},
+		{"fail", 1, 1},
+	}
+	for _, tt := range tests {
+		m := metricsOf("metrics", tt.method)
+		if have := m.requests.Count(); have != tt.requests {
+			t.Errorf("%s: request count mismatch: have %d, want %d", tt.method, have, tt.requests)
+		}
+		if have := m.errors.Count(); have != tt.errors {
+			t.Errorf("%s: error count mismatch: have %d, want %d", tt.method, have, tt.errors)
+		}
+		if have := m.duration.Count(); have != tt.requests {
+			t.Errorf("%s: timing count mismatch: have %d, want %d", tt.method, have, tt.requests)
+		}
+	}
+	if metrics.DefaultRegistry.Get("rpc/metrics/ok/duration") == nil {
+		t.Errorf("method metrics not registered")
+	}
+}
+
+// Tests that the logged parameters of slow calls are truncated.
+func TestTruncateParams(t *testing.T) {
+	if have := truncateParams([]interface{}{"hello", 1, nil}); have != `["hello",1,null]` {
+		t.Errorf("short params mismatch: have %s", have)
+	}
+	long := truncateParams([]interface{}{strings.Repeat("a", 2*slowQueryParamsLimit)})
+	if len(long) != slowQueryParamsLimit+3 || !strings.HasSuffix(long, "...") {
+		t.Errorf("long params not truncated: have %d bytes", len(long))
+	}
+	if have := truncateParams([]interface{}{make(chan int)}); have != "[chan int]" {
+		t.Errorf("unencodable params mismatch: have %s", have)
+	}
+}
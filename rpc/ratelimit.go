@@ -0,0 +1,84 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"sync"
+	"time"
+)
+
+// ServerLimits bounds the resources a single RPC connection may consume, to
+// protect the node against misbehaving or abusive clients. Zero values disable
+// the respective limit.
+type ServerLimits struct {
+	BatchItems   int     `toml:",omitempty"` // Maximum number of requests in a single batch
+	ResponseSize int     `toml:",omitempty"` // Maximum size of a single (batch) response in bytes
+	InFlight     int     `toml:",omitempty"` // Maximum number of concurrently executing requests
+	Rate         float64 `toml:",omitempty"` // Sustained requests per second
+	Burst        int     `toml:",omitempty"` // Maximum burst of requests above the sustained rate
+}
+
+// rateLimiter is a token bucket allowing a sustained rate of events per second
+// with bursts of up to a configured size.
+type rateLimiter struct {
+	rate  float64 // Number of tokens refilled per second
+	burst float64 // Maximum number of tokens the bucket can hold
+
+	tokens float64   // Number of tokens currently available
+	last   time.Time // Last time the token count was updated
+	lock   sync.Mutex
+}
+
+// newRateLimiter creates a token bucket refilling at rate tokens per second and
+// holding at most burst tokens. A non-positive rate disables limiting.
+func newRateLimiter(rate float64, burst int) *rateLimiter {
+	if rate <= 0 {
+		return nil
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	return &rateLimiter{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// allow reports whether an event may happen now, consuming a token if so. A nil
+// limiter allows everything.
+func (l *rateLimiter) allow() bool {
+	if l == nil {
+		return true
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+
+	if l.tokens < 1 {
+		return false
+	}
+	l.tokens--
+	return true
+}
@@ -0,0 +1,137 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// limitedCall sends a raw request over a fresh connection to a server with the
+// given limits and returns the raw (batch) response.
+func limitedCall(t *testing.T, limits ServerLimits, requests ...string) []json.RawMessage {
+	server := newTestServer("service", new(Service))
+	server.SetLimits(limits)
+	defer server.Stop()
+
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+	go server.ServeCodec(NewJSONCodec(serverConn), OptionMethodInvocation)
+
+	in := json.NewDecoder(clientConn)
+	var responses []json.RawMessage
+	for _, request := range requests {
+		if _, err := clientConn.Write([]byte(request)); err != nil {
+			t.Fatalf("failed to send request: %v", err)
+		}
+		var response json.RawMessage
+		if err := in.Decode(&response); err != nil {
+			t.Fatalf("failed to read response: %v", err)
+		}
+		responses = append(responses, response)
+	}
+	return responses
+}
+
+const limitedEcho = `{"jsonrpc":"2.0","id":1,"method":"service_echo","params":["hello",1,null]}`
+
+func TestServerBatchLimit(t *testing.T) {
+	limits := ServerLimits{BatchItems: 2}
+
+	small := "[" + limitedEcho + "," + limitedEcho + "]"
+	large := "[" + limitedEcho + "," + limitedEcho + "," + limitedEcho + "]"
+
+	res := limitedCall(t, limits, small, large, limitedEcho)
+	if strings.Contains(string(res[0]), "error") {
+		t.Errorf("small batch rejected: %s", res[0])
+	}
+	if !strings.Contains(string(res[1]), "batch too large") {
+		t.Errorf("large batch accepted: %s", res[1])
+	}
+	if strings.Contains(string(res[2]), "error") {
+		t.Errorf("connection unusable after rejected batch: %s", res[2])
+	}
+}
+
+func TestServerResponseLimit(t *testing.T) {
+	res := limitedCall(t, ServerLimits{ResponseSize: 16}, limitedEcho)
+	if !strings.Contains(string(res[0]), "response too large") {
+		t.Errorf("oversized response accepted: %s", res[0])
+	}
+	res = limitedCall(t, ServerLimits{ResponseSize: 1024}, limitedEcho)
+	if strings.Contains(string(res[0]), "error") {
+		t.Errorf("small response rejected: %s", res[0])
+	}
+}
+
+type LimitedSubscriptionService struct {
+	subs chan *Subscription
+}
+
+func (s *LimitedSubscriptionService) Feed(ctx context.Context) (*Subscription, error) {
+	notifier, _ := NotifierFromContext(ctx)
+	sub := notifier.CreateSubscription()
+	s.subs <- sub
+	return sub, nil
+}
+
+// Tests that subscriptions whose ID is rejected by the response limit are torn
+// down instead of lingering for the lifetime of the connection.
+func TestServerResponseLimitSubscription(t *testing.T) {
+	service := &LimitedSubscriptionService{subs: make(chan *Subscription, 1)}
+	server := newTestServer("service", service)
+	server.SetLimits(ServerLimits{ResponseSize: 16})
+	defer server.Stop()
+
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+	go server.ServeCodec(NewJSONCodec(serverConn), OptionMethodInvocation|OptionSubscriptions)
+
+	request := `{"jsonrpc":"2.0","id":1,"method":"service_subscribe","params":["feed"]}`
+	if _, err := clientConn.Write([]byte(request)); err != nil {
+		t.Fatalf("failed to send request: %v", err)
+	}
+	var response json.RawMessage
+	if err := json.NewDecoder(clientConn).Decode(&response); err != nil {
+		t.Fatalf("failed to read response: %v", err)
+	}
+	if !strings.Contains(string(response), "response too large") {
+		t.Fatalf("oversized subscription response accepted: %s", response)
+	}
+	sub := <-service.subs
+	select {
+	case <-sub.Err():
+	case <-time.After(time.Second):
+		t.Fatalf("rejected subscription not torn down")
+	}
+}
+
+func TestServerConnectionRateLimit(t *testing.T) {
+	res := limitedCall(t, ServerLimits{Rate: 0.001, Burst: 2}, limitedEcho, limitedEcho, limitedEcho)
+	for i := 0; i < 2; i++ {
+		if strings.Contains(string(res[i]), "error") {
+			t.Errorf("request %d throttled: %s", i, res[i])
+		}
+	}
+	if !strings.Contains(string(res[2]), "rate limit exceeded") {
+		t.Errorf("request over the limit served: %s", res[2])
+	}
+}
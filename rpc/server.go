@@ -18,12 +18,14 @@ package rpc
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"reflect"
 	"runtime"
 	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/ethereum/go-ethereum/log"
 	"gopkg.in/fatih/set.v0"
@@ -119,13 +121,19 @@ func (s *Server) RegisterName(name string, rcvr interface{}) error {
 	return nil
 }
 
+// SetLimits configures the resource limits applied to every connection served
+// afterwards. It must not be called concurrently with serving requests.
+func (s *Server) SetLimits(limits ServerLimits) {
+	s.limits = limits
+}
+
 // serveRequest will reads requests from the codec, calls the RPC callback and
 // writes the response to the given codec.
 //
 // If singleShot is true it will process a single request, otherwise it will handle
 // requests until the codec returns an error when reading a request (in most cases
 // an EOF). It executes requests in parallel when singleShot is false.
-func (s *Server) serveRequest(codec ServerCodec, singleShot bool, options CodecOption) error {
+func (s *Server) serveRequest(ctx context.Context, codec ServerCodec, singleShot bool, options CodecOption) error {
 	var pend sync.WaitGroup
 
 	defer func() {
@@ -140,9 +148,14 @@ func (s *Server) serveRequest(codec ServerCodec, singleShot bool, options CodecO
 		s.codecsMu.Unlock()
 	}()
 
-	ctx, cancel := context.WithCancel(context.Background())
+	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
+	// identify the client by its connection unless already known
+	if ClientFromContext(ctx) == "" {
+		ctx = context.WithValue(ctx, clientKey{}, fmt.Sprintf("conn-%d", atomic.AddUint64(&connCounter, 1)))
+	}
+
 	// if the codec supports notification include a notifier that callbacks can use
 	// to send notification to clients. It is thight to the codec/connection. If the
 	// connection is closed the notifier will stop and cancels all active subscriptions.
@@ -157,6 +170,13 @@ func (s *Server) serveRequest(codec ServerCodec, singleShot bool, options CodecO
 	s.codecs.Add(codec)
 	s.codecsMu.Unlock()
 
+	// set up the per connection resource limits
+	var inflight chan struct{}
+	if s.limits.InFlight > 0 {
+		inflight = make(chan struct{}, s.limits.InFlight)
+	}
+	limiter := newRateLimiter(s.limits.Rate, s.limits.Burst)
+
 	// test if the server is ordered to stop
 	for atomic.LoadInt32(&s.run) == 1 {
 		reqs, batch, err := s.readRequest(codec)
@@ -186,6 +206,20 @@ func (s *Server) serveRequest(codec ServerCodec, singleShot bool, options CodecO
 			}
 			return nil
 		}
+		// reject oversized batches as a whole, throttle the individual requests
+		if batch && s.limits.BatchItems > 0 && len(reqs) > s.limits.BatchItems {
+			err := &limitExceededError{fmt.Sprintf("batch too large (%d>%d)", len(reqs), s.limits.BatchItems)}
+			codec.Write(codec.CreateErrorResponse(nil, err))
+			if singleShot {
+				return nil
+			}
+			continue
+		}
+		for _, req := range reqs {
+			if req.err == nil && !limiter.allow() {
+				req.err = &limitExceededError{"rate limit exceeded"}
+			}
+		}
 		// If a single shot request is executing, run and return immediately
 		if singleShot {
 			if batch {
@@ -195,11 +229,18 @@ func (s *Server) serveRequest(codec ServerCodec, singleShot bool, options CodecO
 			}
 			return nil
 		}
-		// For multi-shot connections, start a goroutine to serve and loop back
+		// For multi-shot connections, start a goroutine to serve and loop back.
+		// If too many requests are in flight, stop reading until one finishes.
+		if inflight != nil {
+			inflight <- struct{}{}
+		}
 		pend.Add(1)
 
 		go func(reqs []*serverRequest, batch bool) {
 			defer pend.Done()
+			if inflight != nil {
+				defer func() { <-inflight }()
+			}
 			if batch {
 				s.execBatch(ctx, codec, reqs)
 			} else {
@@ -210,19 +251,33 @@ func (s *Server) serveRequest(codec ServerCodec, singleShot bool, options CodecO
 	return nil
 }
 
+// clientKey is the context key under which the identity of the client is stored.
+type clientKey struct{}
+
+// connCounter numbers the served connections to tell their clients apart.
+var connCounter uint64
+
+// ClientFromContext returns an identifier of the client issuing a request, the
+// remote host for HTTP requests or a name unique to the connection otherwise.
+// Services can use it to account for the resources consumed by each client.
+func ClientFromContext(ctx context.Context) string {
+	client, _ := ctx.Value(clientKey{}).(string)
+	return client
+}
+
 // ServeCodec reads incoming requests from codec, calls the appropriate callback and writes the
 // response back using the given codec. It will block until the codec is closed or the server is
 // stopped. In either case the codec is closed.
 func (s *Server) ServeCodec(codec ServerCodec, options CodecOption) {
 	defer codec.Close()
-	s.serveRequest(codec, false, options)
+	s.serveRequest(context.Background(), codec, false, options)
 }
 
 // ServeSingleRequest reads and processes a single RPC request from the given codec. It will not
 // close the codec unless a non-recoverable error has occurred. Note, this method will return after
 // a single request has been processed!
 func (s *Server) ServeSingleRequest(codec ServerCodec, options CodecOption) {
-	s.serveRequest(codec, true, options)
+	s.serveRequest(context.Background(), codec, true, options)
 }
 
 // Stop will stop reading new requests, wait for stopPendingRequestTimeout to allow pending requests to finish,
@@ -254,7 +309,7 @@ func (s *Server) createSubscription(ctx context.Context, c ServerCodec, req *ser
 }
 
 // handle executes a request and returns the response from the callback.
-func (s *Server) handle(ctx context.Context, codec ServerCodec, req *serverRequest) (interface{}, func()) {
+func (s *Server) handle(ctx context.Context, codec ServerCodec, req *serverRequest) (interface{}, func(bool)) {
 	if req.err != nil {
 		return codec.CreateErrorResponse(&req.id, req.err), nil
 	}
@@ -276,16 +331,28 @@ func (s *Server) handle(ctx context.Context, codec ServerCodec, req *serverReque
 		return codec.CreateErrorResponse(&req.id, &invalidParamsError{"Expected subscription id as first argument"}), nil
 	}
 
+	// enforce the access policy of authenticated callers
+	if policy := policyFromContext(ctx); policy != nil {
+		if err := policy.check(req.svcname, req.method); err != nil {
+			return codec.CreateErrorResponse(&req.id, err), nil
+		}
+	}
+
 	if req.callb.isSubscribe {
 		subid, err := s.createSubscription(ctx, codec, req)
 		if err != nil {
 			return codec.CreateErrorResponse(&req.id, &callbackError{err.Error()}), nil
 		}
 
-		// active the subscription after the sub id was successfully sent to the client
-		activateSub := func() {
+		// active the subscription after the sub id was successfully sent to the client,
+		// or drop it if the response could not be delivered
+		activateSub := func(delivered bool) {
 			notifier, _ := NotifierFromContext(ctx)
-			notifier.activate(subid, req.svcname)
+			if delivered {
+				notifier.activate(subid, req.svcname)
+			} else {
+				notifier.discard(subid)
+			}
 		}
 
 		return codec.CreateResponse(req.id, subid), activateSub
@@ -307,18 +374,26 @@ func (s *Server) handle(ctx context.Context, codec ServerCodec, req *serverReque
 		arguments = append(arguments, req.args...)
 	}
 
-	// execute RPC method and return result
+	// execute RPC method, accounting for its execution time and result
+	start := time.Now()
 	reply := req.callb.method.Func.Call(arguments)
+	elapsed := time.Since(start)
+
+	var err error
+	if req.callb.errPos >= 0 && !reply[req.callb.errPos].IsNil() {
+		err = reply[req.callb.errPos].Interface().(error)
+	}
+	metricsOf(req.svcname, req.method).mark(elapsed, err != nil)
+	if s.slowQuery > 0 && elapsed >= s.slowQuery {
+		logSlowQuery(ClientFromContext(ctx), req, elapsed, err)
+	}
+
+	// return result
 	if len(reply) == 0 {
 		return codec.CreateResponse(req.id, nil), nil
 	}
-
-	if req.callb.errPos >= 0 { // test if method returned an error
-		if !reply[req.callb.errPos].IsNil() {
-			e := reply[req.callb.errPos].Interface().(error)
-			res := codec.CreateErrorResponse(&req.id, &callbackError{e.Error()})
-			return res, nil
-		}
+	if err != nil { // test if method returned an error
+		return codec.CreateErrorResponse(&req.id, &callbackError{err.Error()}), nil
 	}
 	return codec.CreateResponse(req.id, reply[0].Interface()), nil
 }
@@ -326,11 +401,19 @@ func (s *Server) handle(ctx context.Context, codec ServerCodec, req *serverReque
 // exec executes the given request and writes the result back using the codec.
 func (s *Server) exec(ctx context.Context, codec ServerCodec, req *serverRequest) {
 	var response interface{}
-	var callback func()
+	var callback func(bool)
 	if req.err != nil {
 		response = codec.CreateErrorResponse(&req.id, req.err)
 	} else {
+		var err Error
 		response, callback = s.handle(ctx, codec, req)
+		if response, err = s.checkResponseSize(response); err != nil {
+			response = codec.CreateErrorResponse(&req.id, err)
+			if callback != nil {
+				callback(false)
+				callback = nil
+			}
+		}
 	}
 
 	if err := codec.Write(response); err != nil {
@@ -340,7 +423,7 @@ func (s *Server) exec(ctx context.Context, codec ServerCodec, req *serverRequest
 
 	// when request was a subscribe request this allows these subscriptions to be actived
 	if callback != nil {
-		callback()
+		callback(true)
 	}
 }
 
@@ -348,27 +431,56 @@ func (s *Server) exec(ctx context.Context, codec ServerCodec, req *serverRequest
 // It will only write the response back when the last request is processed.
 func (s *Server) execBatch(ctx context.Context, codec ServerCodec, requests []*serverRequest) {
 	responses := make([]interface{}, len(requests))
-	var callbacks []func()
+	var callbacks []func(bool)
 	for i, req := range requests {
 		if req.err != nil {
 			responses[i] = codec.CreateErrorResponse(&req.id, req.err)
 		} else {
-			var callback func()
+			var callback func(bool)
 			if responses[i], callback = s.handle(ctx, codec, req); callback != nil {
 				callbacks = append(callbacks, callback)
 			}
 		}
 	}
 
-	if err := codec.Write(responses); err != nil {
+	var response interface{} = responses
+	if encoded, err := s.checkResponseSize(responses); err != nil {
+		for i, req := range requests {
+			responses[i] = codec.CreateErrorResponse(&req.id, err)
+		}
+		for _, c := range callbacks {
+			c(false)
+		}
+		callbacks = nil
+	} else {
+		response = encoded
+	}
+	if err := codec.Write(response); err != nil {
 		log.Error(fmt.Sprintf("%v\n", err))
 		codec.Close()
 	}
 
 	// when request holds one of more subscribe requests this allows these subscriptions to be activated
 	for _, c := range callbacks {
-		c()
+		c(true)
+	}
+}
+
+// checkResponseSize returns an error if the encoded response exceeds the
+// configured response size limit. Otherwise it returns the response to write,
+// already encoded if the limit required doing so.
+func (s *Server) checkResponseSize(response interface{}) (interface{}, Error) {
+	if s.limits.ResponseSize <= 0 {
+		return response, nil
+	}
+	blob, err := json.Marshal(response)
+	if err != nil {
+		return response, nil // let the codec report the encoding failure
+	}
+	if len(blob) > s.limits.ResponseSize {
+		return nil, &limitExceededError{fmt.Sprintf("response too large (%d>%d)", len(blob), s.limits.ResponseSize)}
 	}
+	return json.RawMessage(blob), nil
 }
 
 // readRequest requests the next (batch) request from the codec. It will return the collection
@@ -410,7 +522,7 @@ func (s *Server) readRequest(codec ServerCodec) ([]*serverRequest, bool, Error)
 
 		if r.isPubSub { // eth_subscribe, r.method contains the subscription method name
 			if callb, ok := svc.subscriptions[r.method]; ok {
-				requests[i] = &serverRequest{id: r.id, svcname: svc.name, callb: callb}
+				requests[i] = &serverRequest{id: r.id, svcname: svc.name, method: "subscribe", callb: callb}
 				if r.params != nil && len(callb.argTypes) > 0 {
 					argTypes := []reflect.Type{reflect.TypeOf("")}
 					argTypes = append(argTypes, callb.argTypes...)
@@ -427,7 +539,7 @@ func (s *Server) readRequest(codec ServerCodec) ([]*serverRequest, bool, Error)
 		}
 
 		if callb, ok := svc.callbacks[r.method]; ok { // lookup RPC method
-			requests[i] = &serverRequest{id: r.id, svcname: svc.name, callb: callb}
+			requests[i] = &serverRequest{id: r.id, svcname: svc.name, method: r.method, callb: callb}
 			if r.params != nil && len(callb.argTypes) > 0 {
 				if args, err := codec.ParseRequestArguments(callb.argTypes, r.params); err == nil {
 					requests[i].args = args
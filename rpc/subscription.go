@@ -37,7 +37,8 @@ type ID string
 type Subscription struct {
 	ID        ID
 	namespace string
-	err       chan error // closed on unsubscribe
+	err       chan error    // closed on unsubscribe
+	active    chan struct{} // closed on activation
 }
 
 // Err returns a channel that is closed when the client send an unsubscribe request.
@@ -45,6 +46,12 @@ func (s *Subscription) Err() <-chan error {
 	return s.err
 }
 
+// Active returns a channel that is closed once the subscription ID was sent to
+// the client, from when on notifications are delivered instead of dropped.
+func (s *Subscription) Active() <-chan struct{} {
+	return s.active
+}
+
 // notifierKey is used to store a notifier within the connection context.
 type notifierKey struct{}
 
@@ -78,7 +85,7 @@ func NotifierFromContext(ctx context.Context) (*Notifier, bool) {
 // are dropped until the subscription is marked as active. This is done
 // by the RPC server after the subscription ID is send to the client.
 func (n *Notifier) CreateSubscription() *Subscription {
-	s := &Subscription{ID: NewID(), err: make(chan error)}
+	s := &Subscription{ID: NewID(), err: make(chan error), active: make(chan struct{})}
 	n.subMu.Lock()
 	n.inactive[s.ID] = s
 	n.subMu.Unlock()
@@ -120,6 +127,17 @@ func (n *Notifier) unsubscribe(id ID) error {
 	return ErrSubscriptionNotFound
 }
 
+// discard drops a subscription which was never activated, because its ID could
+// not be sent to the client.
+func (n *Notifier) discard(id ID) {
+	n.subMu.Lock()
+	defer n.subMu.Unlock()
+	if s, found := n.inactive[id]; found {
+		close(s.err)
+		delete(n.inactive, id)
+	}
+}
+
 // activate enables a subscription. Until a subscription is enabled all
 // notifications are dropped. This method is called by the RPC server after
 // the subscription ID was sent to client. This prevents notifications being
@@ -131,5 +149,6 @@ func (n *Notifier) activate(id ID, namespace string) {
 		sub.namespace = namespace
 		n.active[id] = sub
 		delete(n.inactive, id)
+		close(sub.active)
 	}
 }
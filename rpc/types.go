@@ -22,6 +22,7 @@ import (
 	"reflect"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"gopkg.in/fatih/set.v0"
@@ -57,6 +58,7 @@ type service struct {
 type serverRequest struct {
 	id            interface{}
 	svcname       string
+	method        string
 	callb         *callback
 	args          []reflect.Value
 	isUnsubscribe bool
@@ -69,7 +71,9 @@ type subscriptions map[string]*callback  // collection of subscription callbacks
 
 // Server represents a RPC server
 type Server struct {
-	services serviceRegistry
+	services  serviceRegistry
+	limits    ServerLimits
+	slowQuery time.Duration // Execution time above which calls are logged
 
 	run      int32
 	codecsMu sync.Mutex
@@ -68,7 +68,11 @@ func (srv *Server) WebsocketHandler(allowedOrigins []string) http.Handler {
 			decoder := func(v interface{}) error {
 				return websocketJSONCodec.Receive(conn, v)
 			}
-			srv.ServeCodec(NewCodec(conn, encoder, decoder), OptionMethodInvocation|OptionSubscriptions)
+			codec := NewCodec(conn, encoder, decoder)
+			defer codec.Close()
+
+			ctx := withPolicy(context.Background(), conn.Request().Context())
+			srv.serveRequest(ctx, codec, false, OptionMethodInvocation|OptionSubscriptions)
 		},
 	}
 }
@@ -17,11 +17,13 @@
 package api
 
 import (
+	"errors"
 	"fmt"
 	"io"
+	"math/big"
 	"net/http"
 	"path"
-	"regexp"
+	"sort"
 	"strings"
 	"sync"
 
@@ -31,13 +33,12 @@ import (
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/urlscheme"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/metrics"
 	"github.com/ethereum/go-ethereum/swarm/storage"
 )
 
-var hashMatcher = regexp.MustCompile("^[0-9A-Fa-f]{64}")
-
 //setup metrics
 var (
 	apiResolveCount    = metrics.NewRegisteredCounter("api.resolve.count", nil)
@@ -47,6 +48,7 @@ var (
 	apiGetCount        = metrics.NewRegisteredCounter("api.get.count", nil)
 	apiGetNotFound     = metrics.NewRegisteredCounter("api.get.notfound", nil)
 	apiGetHttp300      = metrics.NewRegisteredCounter("api.get.http.300", nil)
+	apiGetRedirect     = metrics.NewRegisteredCounter("api.get.redirect", nil)
 	apiModifyCount     = metrics.NewRegisteredCounter("api.modify.count", nil)
 	apiModifyFail      = metrics.NewRegisteredCounter("api.modify.fail", nil)
 	apiAddFileCount    = metrics.NewRegisteredCounter("api.addfile.count", nil)
@@ -61,6 +63,20 @@ type Resolver interface {
 	Resolve(string) (common.Hash, error)
 }
 
+// HistoricalResolver is a Resolver able to resolve names against the state of
+// the registry at a past block.
+type HistoricalResolver interface {
+	Resolver
+	ResolveAt(name string, block *big.Int) (common.Hash, error)
+}
+
+// NameIndex is a Resolver keeping an index of the names it knows of, allowing
+// names to be listed by owner and looked up by content hash.
+type NameIndex interface {
+	OwnedNames(owner common.Address) []string
+	NamesOf(hash common.Hash) []string
+}
+
 // NoResolverError is returned by MultiResolver.Resolve if no resolver
 // can be found for the address.
 type NoResolverError struct {
@@ -115,6 +131,75 @@ func NewMultiResolver(opts ...MultiResolverOption) (m *MultiResolver) {
 // the Hash from the the first one which does not return error
 // will be returned.
 func (m MultiResolver) Resolve(addr string) (h common.Hash, err error) {
+	rs, err := m.resolversFor(addr)
+	if err != nil {
+		return h, err
+	}
+	for _, r := range rs {
+		h, err = r.Resolve(addr)
+		if err == nil {
+			return
+		}
+	}
+	return
+}
+
+// ResolveAt resolves address against the state of the registry at the given
+// block, choosing a Resolver by TLD like Resolve. Resolvers not supporting
+// historical lookups are skipped.
+func (m MultiResolver) ResolveAt(addr string, block *big.Int) (h common.Hash, err error) {
+	rs, err := m.resolversFor(addr)
+	if err != nil {
+		return h, err
+	}
+	err = fmt.Errorf("no ENS resolver supports historical lookups of %q", addr)
+	for _, r := range rs {
+		if hr, ok := r.(HistoricalResolver); ok {
+			h, err = hr.ResolveAt(addr, block)
+			if err == nil {
+				return
+			}
+		}
+	}
+	return
+}
+
+// OwnedNames returns the names owned by an address known to any of the
+// resolvers keeping a name index.
+func (m MultiResolver) OwnedNames(owner common.Address) []string {
+	return m.collect(func(idx NameIndex) []string { return idx.OwnedNames(owner) })
+}
+
+// NamesOf returns the names resolving to a content hash known to any of the
+// resolvers keeping a name index.
+func (m MultiResolver) NamesOf(hash common.Hash) []string {
+	return m.collect(func(idx NameIndex) []string { return idx.NamesOf(hash) })
+}
+
+// collect merges the names returned by the name indexes of all resolvers.
+func (m MultiResolver) collect(names func(NameIndex) []string) []string {
+	seen := make(map[string]bool)
+	var result []string
+	for _, rs := range m.resolvers {
+		for _, r := range rs {
+			idx, ok := r.(NameIndex)
+			if !ok {
+				continue
+			}
+			for _, name := range names(idx) {
+				if !seen[name] {
+					seen[name] = true
+					result = append(result, name)
+				}
+			}
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+// resolversFor returns the resolvers responsible for the TLD of address.
+func (m MultiResolver) resolversFor(addr string) ([]Resolver, error) {
 	rs := m.resolvers[""]
 	tld := path.Ext(addr)
 	if tld != "" {
@@ -125,15 +210,9 @@ func (m MultiResolver) Resolve(addr string) (h common.Hash, err error) {
 		}
 	}
 	if rs == nil {
-		return h, NewNoResolverError(tld)
+		return nil, NewNoResolverError(tld)
 	}
-	for _, r := range rs {
-		h, err = r.Resolve(addr)
-		if err == nil {
-			return
-		}
-	}
-	return
+	return rs, nil
 }
 
 /*
@@ -142,8 +221,9 @@ on top of the dpa
 it is the public interface of the dpa which is included in the ethereum stack
 */
 type Api struct {
-	dpa *storage.DPA
-	dns Resolver
+	dpa       *storage.DPA
+	dns       Resolver
+	manifests *ManifestParams
 }
 
 //the api constructor initialises
@@ -155,6 +235,32 @@ func NewApi(dpa *storage.DPA, dns Resolver) (self *Api) {
 	return
 }
 
+// SetManifestParams sets the limits applied to the manifests loaded through
+// the api, nil restores the defaults.
+func (self *Api) SetManifestParams(params *ManifestParams) {
+	self.manifests = params
+}
+
+// Traced returns a view of the api recording all chunks retrieved through it,
+// manifests included, in the given trace.
+func (self *Api) Traced(trace *storage.Trace) *Api {
+	return &Api{
+		dpa:       self.dpa.Traced(trace),
+		dns:       self.dns,
+		manifests: self.manifests,
+	}
+}
+
+// ForClient returns a view of the api storing and retrieving on behalf of the
+// given client, subject to the per client limits of the DPA.
+func (self *Api) ForClient(id string) *Api {
+	return &Api{
+		dpa:       self.dpa.ForClient(id),
+		dns:       self.dns,
+		manifests: self.manifests,
+	}
+}
+
 // to be used only in TEST
 func (self *Api) Upload(uploadDir, index string) (hash string, err error) {
 	fs := NewFileSystem(self)
@@ -162,6 +268,12 @@ func (self *Api) Upload(uploadDir, index string) (hash string, err error) {
 	return hash, err
 }
 
+// Hash returns the hash of the manifest Upload would create for a local file
+// or directory, without storing anything.
+func (self *Api) Hash(localpath string) (string, error) {
+	return NewFileSystem(self).Hash(localpath, "")
+}
+
 // DPA reader API
 func (self *Api) Retrieve(key storage.Key) storage.LazySectionReader {
 	return self.dpa.Retrieve(key)
@@ -179,12 +291,18 @@ func (self *Api) Resolve(uri *URI) (storage.Key, error) {
 	log.Trace(fmt.Sprintf("Resolving : %v", uri.Addr))
 
 	// if the URI is immutable, check if the address is a hash
-	isHash := hashMatcher.MatchString(uri.Addr)
+	key, isHash := contentHash(uri.Addr)
 	if uri.Immutable() || uri.DeprecatedImmutable() {
 		if !isHash {
 			return nil, fmt.Errorf("immutable address not a content hash: %q", uri.Addr)
 		}
-		return common.Hex2Bytes(uri.Addr), nil
+		return key, nil
+	}
+
+	// if the name is pinned to a block, resolve it in the registry state at
+	// that block
+	if name, block := urlscheme.SplitVersion(uri.Addr); block != nil {
+		return self.resolveAt(name, block)
 	}
 
 	// if DNS is not configured, check if the address is a hash
@@ -193,7 +311,7 @@ func (self *Api) Resolve(uri *URI) (storage.Key, error) {
 			apiResolveFail.Inc(1)
 			return nil, fmt.Errorf("no DNS to resolve name: %q", uri.Addr)
 		}
-		return common.Hex2Bytes(uri.Addr), nil
+		return key, nil
 	}
 
 	// try and resolve the address
@@ -204,7 +322,54 @@ func (self *Api) Resolve(uri *URI) (storage.Key, error) {
 		apiResolveFail.Inc(1)
 		return nil, err
 	}
-	return common.Hex2Bytes(uri.Addr), nil
+	return key, nil
+}
+
+// resolveAt resolves a name against the state of the registry at a block.
+func (self *Api) resolveAt(name string, block *big.Int) (storage.Key, error) {
+	resolver, ok := self.dns.(HistoricalResolver)
+	if !ok {
+		apiResolveFail.Inc(1)
+		return nil, fmt.Errorf("no DNS to resolve name %q at block %v", name, block)
+	}
+	resolved, err := resolver.ResolveAt(name, block)
+	if err != nil {
+		apiResolveFail.Inc(1)
+		return nil, err
+	}
+	return resolved[:], nil
+}
+
+// ListOwnedNames returns the names registered to an owner known to the name
+// index of the DNS.
+func (self *Api) ListOwnedNames(owner common.Address) ([]string, error) {
+	idx, ok := self.dns.(NameIndex)
+	if !ok {
+		return nil, errors.New("no DNS name index")
+	}
+	return idx.OwnedNames(owner), nil
+}
+
+// ReverseResolve returns the names known to the name index of the DNS which
+// resolve to the given key.
+func (self *Api) ReverseResolve(key storage.Key) ([]string, error) {
+	idx, ok := self.dns.(NameIndex)
+	if !ok {
+		return nil, errors.New("no DNS name index")
+	}
+	return idx.NamesOf(common.BytesToHash(key)), nil
+}
+
+// contentHash returns the storage key an address encodes either as a
+// hexadecimal hash or as a CID, and whether it does.
+func contentHash(addr string) (storage.Key, bool) {
+	if urlscheme.IsContentHash(addr) {
+		return common.Hex2Bytes(addr), true
+	}
+	if key, _, err := DecodeCID(addr); err == nil {
+		return key, true
+	}
+	return nil, false
 }
 
 // Put provides singleton manifest creation on top of dpa store
@@ -228,12 +393,24 @@ func (self *Api) Put(content, contentType string) (storage.Key, error) {
 	return key, nil
 }
 
+// RedirectError is returned by Get for paths the manifest redirects.
+type RedirectError struct {
+	Status   int    // 301 or 302
+	Location string // relative to the manifest root if starting with a slash
+}
+
+func (e *RedirectError) Error() string {
+	return fmt.Sprintf("redirect (%d) to %s", e.Status, e.Location)
+}
+
 // Get uses iterative manifest retrieval and prefix matching
 // to resolve basePath to content using dpa retrieve
 // it returns a section reader, mimeType, status and an error
+// paths redirected by the manifest return a *RedirectError, paths not found
+// return the error page of the manifest as reader if it has one
 func (self *Api) Get(key storage.Key, path string) (reader storage.LazySectionReader, mimeType string, status int, err error) {
 	apiGetCount.Inc(1)
-	trie, err := loadManifest(self.dpa, key, nil)
+	trie, err := loadManifest(self.dpa, self.manifests, key, nil)
 	if err != nil {
 		apiGetNotFound.Inc(1)
 		status = http.StatusNotFound
@@ -251,6 +428,10 @@ func (self *Api) Get(key storage.Key, path string) (reader storage.LazySectionRe
 		if status == http.StatusMultipleChoices {
 			apiGetHttp300.Inc(1)
 			return
+		} else if isRedirect(status) {
+			apiGetRedirect.Inc(1)
+			err = &RedirectError{Status: status, Location: entry.Location}
+			return
 		} else {
 			mimeType = entry.ContentType
 			log.Trace(fmt.Sprintf("content lookup key: '%v' (%v)", key, mimeType))
@@ -261,14 +442,43 @@ func (self *Api) Get(key storage.Key, path string) (reader storage.LazySectionRe
 		apiGetNotFound.Inc(1)
 		err = fmt.Errorf("manifest entry for '%s' not found", path)
 		log.Warn(fmt.Sprintf("%v", err))
+		if page := trie.findErrorPage(); page != nil {
+			mimeType = page.ContentType
+			reader = self.dpa.Retrieve(common.Hex2Bytes(page.Hash))
+		}
 	}
 	return
 }
 
+// GetEntry returns the metadata of the manifest entry the path resolves to, as
+// Get does, with the full path of the entry and the size of its content. It
+// also returns the status of the entry.
+func (self *Api) GetEntry(key storage.Key, path string) (*ManifestEntry, int, error) {
+	trie, err := loadManifest(self.dpa, self.manifests, key, nil)
+	if err != nil {
+		return nil, http.StatusNotFound, err
+	}
+	entry, fullpath := trie.getEntry(path)
+	if entry == nil {
+		return nil, http.StatusNotFound, fmt.Errorf("manifest entry for '%s' not found", path)
+	}
+	info := entry.ManifestEntry
+	info.Path = fullpath
+	if info.Status == http.StatusMultipleChoices || isRedirect(info.Status) {
+		return &info, info.Status, nil
+	}
+	size, err := self.dpa.Retrieve(common.Hex2Bytes(entry.Hash)).Size(nil)
+	if err != nil {
+		return nil, http.StatusNotFound, err
+	}
+	info.Size = size
+	return &info, http.StatusOK, nil
+}
+
 func (self *Api) Modify(key storage.Key, path, contentHash, contentType string) (storage.Key, error) {
 	apiModifyCount.Inc(1)
 	quitC := make(chan bool)
-	trie, err := loadManifest(self.dpa, key, quitC)
+	trie, err := loadManifest(self.dpa, self.manifests, key, quitC)
 	if err != nil {
 		apiModifyFail.Inc(1)
 		return nil, err
@@ -475,7 +685,7 @@ func (self *Api) BuildDirectoryTree(mhash string, nameresolver bool) (key storag
 	}
 
 	quitC := make(chan bool)
-	rootTrie, err := loadManifest(self.dpa, key, quitC)
+	rootTrie, err := loadManifest(self.dpa, self.manifests, key, quitC)
 	if err != nil {
 		return nil, nil, fmt.Errorf("can't load manifest %v: %v", key.String(), err)
 	}
@@ -21,7 +21,9 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"math/big"
 	"os"
+	"reflect"
 	"testing"
 
 	"github.com/ethereum/go-ethereum/common"
@@ -141,14 +143,33 @@ func (t *testResolver) Resolve(addr string) (common.Hash, error) {
 	return *t.hash, nil
 }
 
+// historicalTestResolver resolves names to the hash set at the latest block up
+// to the requested one.
+type historicalTestResolver struct {
+	*testResolver
+	history map[int64]common.Hash
+}
+
+func (t *historicalTestResolver) ResolveAt(addr string, block *big.Int) (common.Hash, error) {
+	for number := block.Int64(); number >= 0; number-- {
+		if hash, ok := t.history[number]; ok {
+			return hash, nil
+		}
+	}
+	return common.Hash{}, fmt.Errorf("DNS name not found at block %v: %q", block, addr)
+}
+
 // TestAPIResolve tests resolving URIs which can either contain content hashes
 // or ENS names
 func TestAPIResolve(t *testing.T) {
 	ensAddr := "swarm.eth"
 	hashAddr := "1111111111111111111111111111111111111111111111111111111111111111"
 	resolvedAddr := "2222222222222222222222222222222222222222222222222222222222222222"
+	cidAddr := EncodeCID(common.Hex2Bytes(hashAddr), false)
 	doesResolve := newTestResolver(resolvedAddr)
 	doesntResolve := newTestResolver("")
+	historicalAddr := "3333333333333333333333333333333333333333333333333333333333333333"
+	doesResolveAt := &historicalTestResolver{doesResolve, map[int64]common.Hash{100: common.HexToHash(historicalAddr)}}
 
 	type test struct {
 		desc      string
@@ -166,6 +187,12 @@ func TestAPIResolve(t *testing.T) {
 			addr:   hashAddr,
 			result: hashAddr,
 		},
+		{
+			desc:   "DNS not configured, CID address, returns hash address",
+			dns:    nil,
+			addr:   cidAddr,
+			result: hashAddr,
+		},
 		{
 			desc:      "DNS not configured, ENS address, returns error",
 			dns:       nil,
@@ -185,6 +212,13 @@ func TestAPIResolve(t *testing.T) {
 			immutable: true,
 			result:    hashAddr,
 		},
+		{
+			desc:      "DNS configured, immutable CID address, returns hash address",
+			dns:       doesResolve,
+			addr:      cidAddr,
+			immutable: true,
+			result:    hashAddr,
+		},
 		{
 			desc:   "DNS configured, hash address, hash doesn't resolve, returns hash address",
 			dns:    doesntResolve,
@@ -210,6 +244,36 @@ func TestAPIResolve(t *testing.T) {
 			addr:      ensAddr,
 			expectErr: errors.New(`DNS name not found: "swarm.eth"`),
 		},
+		{
+			desc:   "DNS configured, ENS address with port version, returns address resolved at block",
+			dns:    doesResolveAt,
+			addr:   ensAddr + ":150",
+			result: historicalAddr,
+		},
+		{
+			desc:   "DNS configured, ENS address with block suffix, returns address resolved at block",
+			dns:    doesResolveAt,
+			addr:   ensAddr + "@100",
+			result: historicalAddr,
+		},
+		{
+			desc:      "DNS configured, ENS address with block suffix, name doesn't resolve at block, returns error",
+			dns:       doesResolveAt,
+			addr:      ensAddr + "@99",
+			expectErr: errors.New(`DNS name not found at block 99: "swarm.eth"`),
+		},
+		{
+			desc:      "DNS without history, ENS address with block suffix, returns error",
+			dns:       doesResolve,
+			addr:      ensAddr + "@100",
+			expectErr: errors.New(`no DNS to resolve name "swarm.eth" at block 100`),
+		},
+		{
+			desc:      "DNS not configured, ENS address with block suffix, returns error",
+			dns:       nil,
+			addr:      ensAddr + "@100",
+			expectErr: errors.New(`no DNS to resolve name "swarm.eth" at block 100`),
+		},
 	}
 	for _, x := range tests {
 		t.Run(x.desc, func(t *testing.T) {
@@ -362,3 +426,86 @@ func TestMultiResolver(t *testing.T) {
 		})
 	}
 }
+
+func TestMultiResolverAt(t *testing.T) {
+	hash := common.HexToHash("0x2222222222222222222222222222222222222222222222222222222222222222")
+	historical := &historicalTestResolver{newTestResolver(""), map[int64]common.Hash{1: hash}}
+
+	r := NewMultiResolver(
+		MultiResolverOptionWithResolver(newTestResolver(hash.Hex()), "eth"),
+		MultiResolverOptionWithResolver(historical, "eth"),
+	)
+	if res, err := r.ResolveAt("swarm.eth", big.NewInt(1)); err != nil || res != hash {
+		t.Fatalf("expected %v, got %v (%v)", hash.Hex(), res.Hex(), err)
+	}
+	r = NewMultiResolver(MultiResolverOptionWithResolver(newTestResolver(hash.Hex()), "eth"))
+	if _, err := r.ResolveAt("swarm.eth", big.NewInt(1)); err == nil {
+		t.Fatal("expected error resolving without historical resolver")
+	}
+	if _, err := r.ResolveAt("swarm.test", big.NewInt(1)); err == nil || err.Error() != NewNoResolverError("test").Error() {
+		t.Fatalf("expected no resolver error, got %v", err)
+	}
+}
+
+// indexTestResolver is a resolver with a name index.
+type indexTestResolver struct {
+	*testResolver
+	owners  map[string]common.Address
+	content map[string]common.Hash
+}
+
+func (t *indexTestResolver) OwnedNames(owner common.Address) (names []string) {
+	for name, o := range t.owners {
+		if o == owner {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func (t *indexTestResolver) NamesOf(hash common.Hash) (names []string) {
+	for name, h := range t.content {
+		if h == hash {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func TestAPINameIndex(t *testing.T) {
+	owner := common.HexToAddress("0x01")
+	hash := common.HexToHash("0x2222222222222222222222222222222222222222222222222222222222222222")
+	eth := &indexTestResolver{
+		testResolver: newTestResolver(""),
+		owners:       map[string]common.Address{"swarm.eth": owner, "other.eth": common.HexToAddress("0x02")},
+		content:      map[string]common.Hash{"swarm.eth": hash, "other.eth": hash},
+	}
+	test := &indexTestResolver{
+		testResolver: newTestResolver(""),
+		owners:       map[string]common.Address{"swarm.test": owner},
+	}
+	api := &Api{dns: NewMultiResolver(
+		MultiResolverOptionWithResolver(eth, ""),
+		MultiResolverOptionWithResolver(eth, "eth"),
+		MultiResolverOptionWithResolver(test, "test"),
+		MultiResolverOptionWithResolver(newTestResolver(""), "test"),
+	)}
+
+	owned, err := api.ListOwnedNames(owner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(owned, []string{"swarm.eth", "swarm.test"}) {
+		t.Fatalf("owned names mismatch: %v", owned)
+	}
+	names, err := api.ReverseResolve(storage.Key(hash[:]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(names, []string{"other.eth", "swarm.eth"}) {
+		t.Fatalf("reverse resolved names mismatch: %v", names)
+	}
+	if _, err := (&Api{dns: newTestResolver("")}).ReverseResolve(storage.Key(hash[:])); err == nil {
+		t.Fatal("expected error without name index")
+	}
+}
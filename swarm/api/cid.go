@@ -0,0 +1,155 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package api
+
+import (
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/swarm/storage"
+)
+
+// Codes of the multiformats table (https://github.com/multiformats/multicodec)
+// used for swarm references. The chunk tree hash is built on keccak256, it is
+// labelled as such in multihashes.
+const (
+	multihashKeccak256 = 0x1b
+
+	cidVersion         = 1
+	codecRaw           = 0x55 // raw content, bzz-raw
+	codecSwarmManifest = 0xfa // manifest, bzz
+)
+
+var (
+	errInvalidMultihash = errors.New("invalid multihash")
+	errInvalidCID       = errors.New("invalid CID")
+)
+
+var (
+	base32Encoding = base32.NewEncoding("abcdefghijklmnopqrstuvwxyz234567").WithPadding(base32.NoPadding)
+	base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+)
+
+// EncodeMultihash encodes a swarm reference as a binary multihash.
+func EncodeMultihash(key storage.Key) []byte {
+	mh := appendUvarint(nil, multihashKeccak256)
+	mh = appendUvarint(mh, uint64(len(key)))
+	return append(mh, key...)
+}
+
+// DecodeMultihash decodes a binary multihash of a swarm reference.
+func DecodeMultihash(mh []byte) (storage.Key, error) {
+	code, n := binary.Uvarint(mh)
+	if n <= 0 {
+		return nil, errInvalidMultihash
+	}
+	if code != multihashKeccak256 {
+		return nil, fmt.Errorf("unsupported multihash function 0x%x", code)
+	}
+	mh = mh[n:]
+	size, n := binary.Uvarint(mh)
+	if n <= 0 || size != uint64(len(mh[n:])) || size == 0 {
+		return nil, errInvalidMultihash
+	}
+	return storage.Key(mh[n:]), nil
+}
+
+// EncodeCID encodes a swarm reference as a version 1 CID in base32, the
+// default of IPFS tooling. Raw references carry the raw codec, others are
+// taken as manifests.
+func EncodeCID(key storage.Key, raw bool) string {
+	codec := uint64(codecSwarmManifest)
+	if raw {
+		codec = codecRaw
+	}
+	cid := appendUvarint(nil, cidVersion)
+	cid = appendUvarint(cid, codec)
+	cid = append(cid, EncodeMultihash(key)...)
+
+	return "b" + base32Encoding.EncodeToString(cid)
+}
+
+// DecodeCID decodes a swarm reference from a version 1 CID in base32, base58
+// or hex multibase encoding, returning whether it refers to raw content.
+func DecodeCID(s string) (storage.Key, bool, error) {
+	if len(s) < 2 {
+		return nil, false, errInvalidCID
+	}
+	var (
+		cid []byte
+		err error
+	)
+	switch s[0] {
+	case 'b':
+		cid, err = base32Encoding.DecodeString(s[1:])
+	case 'B':
+		cid, err = base32Encoding.DecodeString(strings.ToLower(s[1:]))
+	case 'z':
+		cid, err = decodeBase58(s[1:])
+	case 'f', 'F':
+		cid, err = hex.DecodeString(s[1:])
+	default:
+		return nil, false, fmt.Errorf("unsupported multibase prefix %q", s[0])
+	}
+	if err != nil {
+		return nil, false, errInvalidCID
+	}
+	version, n := binary.Uvarint(cid)
+	if n <= 0 || version != cidVersion {
+		return nil, false, errInvalidCID
+	}
+	cid = cid[n:]
+	codec, n := binary.Uvarint(cid)
+	if n <= 0 {
+		return nil, false, errInvalidCID
+	}
+	if codec != codecRaw && codec != codecSwarmManifest {
+		return nil, false, fmt.Errorf("unsupported CID codec 0x%x", codec)
+	}
+	key, err := DecodeMultihash(cid[n:])
+	return key, codec == codecRaw, err
+}
+
+func appendUvarint(b []byte, x uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	return append(b, buf[:binary.PutUvarint(buf[:], x)]...)
+}
+
+// decodeBase58 decodes a string in the bitcoin base58 alphabet.
+func decodeBase58(s string) ([]byte, error) {
+	var (
+		n     = new(big.Int)
+		radix = big.NewInt(58)
+	)
+	zeros := 0
+	for ; zeros < len(s) && s[zeros] == base58Alphabet[0]; zeros++ {
+	}
+	for _, c := range []byte(s) {
+		digit := strings.IndexByte(base58Alphabet, c)
+		if digit < 0 {
+			return nil, fmt.Errorf("invalid base58 character %q", c)
+		}
+		n.Mul(n, radix)
+		n.Add(n, big.NewInt(int64(digit)))
+	}
+	return append(make([]byte, zeros), n.Bytes()...), nil
+}
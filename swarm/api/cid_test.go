@@ -0,0 +1,94 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package api
+
+import (
+	"bytes"
+	"encoding/hex"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Tests that swarm references survive a round trip through CIDs in all the
+// supported multibase encodings.
+func TestCIDEncoding(t *testing.T) {
+	key := common.Hex2Bytes("2a6f0e4b8c6d5e1f3a9b7c8d0e1f2a3b4c5d6e7f8091a2b3c4d5e6f708192a3b")
+
+	for _, raw := range []bool{false, true} {
+		cid := EncodeCID(key, raw)
+		if !strings.HasPrefix(cid, "b") {
+			t.Fatalf("CID not in base32: %s", cid)
+		}
+		bin, err := base32Encoding.DecodeString(cid[1:])
+		if err != nil {
+			t.Fatalf("failed to decode CID %s: %v", cid, err)
+		}
+		encodings := []string{
+			cid,
+			"B" + strings.ToUpper(cid[1:]),
+			"f" + hex.EncodeToString(bin),
+			"z" + encodeBase58(bin),
+		}
+		for _, enc := range encodings {
+			have, isRaw, err := DecodeCID(enc)
+			if err != nil {
+				t.Fatalf("failed to decode %s: %v", enc, err)
+			}
+			if !bytes.Equal(have, key) {
+				t.Errorf("%s: key mismatch: have %x, want %x", enc, have, key)
+			}
+			if isRaw != raw {
+				t.Errorf("%s: raw mismatch: have %v, want %v", enc, isRaw, raw)
+			}
+		}
+	}
+	// Multihashes of other functions or codecs are rejected
+	invalid := []string{
+		"",
+		"swarm.eth",
+		"b" + base32Encoding.EncodeToString([]byte{0x01, 0x55, 0x12, 0x20}),                      // sha2-256
+		"b" + base32Encoding.EncodeToString(append([]byte{0x01, 0x70}, EncodeMultihash(key)...)), // dag-pb
+		"b" + base32Encoding.EncodeToString(append([]byte{0x00, 0xfa}, EncodeMultihash(key)...)), // version 0
+		"b" + base32Encoding.EncodeToString([]byte{0x01, 0xfa, 0x01, 0x1b, 0x20, 0x00}),          // truncated
+		"QmYwAPJzv5CZsnA625s3Xf2nemtYgPpHdWEz79ojWnPbdG",
+	}
+	for _, enc := range invalid {
+		if key, _, err := DecodeCID(enc); err == nil {
+			t.Errorf("%q: decoded invalid CID into %x", enc, key)
+		}
+	}
+}
+
+func encodeBase58(b []byte) string {
+	var (
+		n     = new(big.Int).SetBytes(b)
+		radix = big.NewInt(58)
+		mod   = new(big.Int)
+		out   []byte
+	)
+	for n.Sign() > 0 {
+		n.DivMod(n, radix, mod)
+		out = append([]byte{base58Alphabet[mod.Int64()]}, out...)
+	}
+	for i := 0; i < len(b) && b[i] == 0; i++ {
+		out = append([]byte{base58Alphabet[0]}, out...)
+	}
+	return string(out)
+}
@@ -284,6 +284,24 @@ func (c *Client) List(hash, prefix string) (*api.ManifestList, error) {
 	return &list, nil
 }
 
+// Info returns the metadata of the entry at the given path of a manifest,
+// with the size of its content.
+func (c *Client) Info(hash, path string) (*api.ManifestEntry, error) {
+	res, err := http.DefaultClient.Get(c.Gateway + "/bzz-info:/" + hash + "/" + path)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected HTTP status: %s", res.Status)
+	}
+	var entry api.ManifestEntry
+	if err := json.NewDecoder(res.Body).Decode(&entry); err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
 // Uploader uploads files to swarm using a provided UploadFn
 type Uploader interface {
 	Upload(UploadFn) error
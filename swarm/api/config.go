@@ -35,6 +35,7 @@ import (
 const (
 	DefaultHTTPListenAddr = "127.0.0.1"
 	DefaultHTTPPort       = "8500"
+	DefaultHTTPCache      = 64 // Megabytes of served documents to cache in memory
 )
 
 // separate bzz directories
@@ -46,6 +47,8 @@ type Config struct {
 	*network.HiveParams
 	Swap *swap.SwapParams
 	*network.SyncParams
+	*storage.LimiterParams
+	*ManifestParams
 	Contract    common.Address
 	EnsRoot     common.Address
 	EnsAPIs     []string
@@ -61,27 +64,39 @@ type Config struct {
 	Cors        string
 	BzzAccount  string
 	BootNodes   string
+
+	HTTPCache     uint64 // Megabytes of served documents to cache in memory (0 = disabled)
+	HTTPCacheDisk uint64 // Megabytes of served documents to cache on disk (0 = disabled)
+
+	PublishAPI      string         // Chain RPC endpoint to watch for published content (empty = disabled)
+	PublishContract common.Address // Contract emitting the ContentPublished events to pin the content of
+	PublishFrom     uint64         // First block to pin the published content from
+	PublishRefresh  uint64         // Seconds between re-fetches of the pinned content
 }
 
 //create a default config with all parameters to set to defaults
 func NewDefaultConfig() (self *Config) {
 
 	self = &Config{
-		StoreParams:   storage.NewDefaultStoreParams(),
-		ChunkerParams: storage.NewChunkerParams(),
-		HiveParams:    network.NewDefaultHiveParams(),
-		SyncParams:    network.NewDefaultSyncParams(),
-		Swap:          swap.NewDefaultSwapParams(),
-		ListenAddr:    DefaultHTTPListenAddr,
-		Port:          DefaultHTTPPort,
-		Path:          node.DefaultDataDir(),
-		EnsAPIs:       nil,
-		EnsRoot:       ens.TestNetAddress,
-		NetworkId:     network.NetworkId,
-		SwapEnabled:   false,
-		SyncEnabled:   true,
-		SwapApi:       "",
-		BootNodes:     "",
+		StoreParams:    storage.NewDefaultStoreParams(),
+		ChunkerParams:  storage.NewChunkerParams(),
+		HiveParams:     network.NewDefaultHiveParams(),
+		SyncParams:     network.NewDefaultSyncParams(),
+		LimiterParams:  storage.NewDefaultLimiterParams(),
+		ManifestParams: NewDefaultManifestParams(),
+		Swap:           swap.NewDefaultSwapParams(),
+		ListenAddr:     DefaultHTTPListenAddr,
+		Port:           DefaultHTTPPort,
+		Path:           node.DefaultDataDir(),
+		EnsAPIs:        nil,
+		EnsRoot:        ens.TestNetAddress,
+		NetworkId:      network.NetworkId,
+		SwapEnabled:    false,
+		SyncEnabled:    true,
+		SwapApi:        "",
+		BootNodes:      "",
+		HTTPCache:      DefaultHTTPCache,
+		PublishRefresh: DefaultPublishRefresh,
 	}
 
 	return
@@ -0,0 +1,117 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package api
+
+import (
+	"context"
+
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethereum/go-ethereum/swarm/network"
+	"github.com/ethereum/go-ethereum/swarm/storage"
+)
+
+const (
+	// eventChanSize is the size of the channels listening to swarm activity.
+	eventChanSize = 256
+)
+
+// Event is a notification of swarm activity, with exactly one of the payloads
+// set depending on its type.
+type Event struct {
+	Type   string              `json:"type"` // "chunk", "upload" or "sync"
+	Chunk  *storage.ChunkEvent `json:"chunk,omitempty"`
+	Upload *storage.StoreEvent `json:"upload,omitempty"`
+	Sync   *network.SyncEvent  `json:"sync,omitempty"`
+}
+
+// Monitor bridges the internal swarm activity feeds to RPC subscribers, allowing
+// user interfaces to follow uploads, downloads and syncing without polling.
+type Monitor struct {
+	netStore *storage.NetStore
+	dpa      *storage.DPA
+	hive     *network.Hive
+}
+
+// NewMonitor creates an activity monitor over the given components, any of which
+// may be nil if not running (e.g. on a local swarm without networking).
+func NewMonitor(netStore *storage.NetStore, dpa *storage.DPA, hive *network.Hive) *Monitor {
+	return &Monitor{
+		netStore: netStore,
+		dpa:      dpa,
+		hive:     hive,
+	}
+}
+
+// StoreStats returns the usage of the local storage API (bzz_storeStats) by each
+// client seen recently, HTTP clients identified by their address and RPC ones
+// by their connection.
+func (self *Monitor) StoreStats() []*storage.ClientStats {
+	if self.dpa == nil || self.dpa.Limiter == nil {
+		return []*storage.ClientStats{}
+	}
+	return self.dpa.Limiter.Stats()
+}
+
+// Events creates a subscription (bzz_subscribe("events")) which is notified of
+// chunks being stored and retrieved, documents being uploaded and the progress
+// of syncing with peers.
+func (self *Monitor) Events(ctx context.Context) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+	rpcSub := notifier.CreateSubscription()
+
+	var (
+		chunkCh  = make(chan storage.ChunkEvent, eventChanSize)
+		uploadCh = make(chan storage.StoreEvent, eventChanSize)
+		syncCh   = make(chan network.SyncEvent, eventChanSize)
+		subs     []event.Subscription
+	)
+	if self.netStore != nil {
+		subs = append(subs, self.netStore.SubscribeChunkEvents(chunkCh))
+	}
+	if self.dpa != nil {
+		subs = append(subs, self.dpa.SubscribeStoreEvents(uploadCh))
+	}
+	if self.hive != nil {
+		subs = append(subs, self.hive.SubscribeSyncEvents(syncCh))
+	}
+	go func() {
+		defer func() {
+			for _, sub := range subs {
+				sub.Unsubscribe()
+			}
+		}()
+		for {
+			select {
+			case ev := <-chunkCh:
+				notifier.Notify(rpcSub.ID, &Event{Type: "chunk", Chunk: &ev})
+			case ev := <-uploadCh:
+				notifier.Notify(rpcSub.ID, &Event{Type: "upload", Upload: &ev})
+			case ev := <-syncCh:
+				notifier.Notify(rpcSub.ID, &Event{Type: "sync", Sync: &ev})
+			case <-rpcSub.Err():
+				return
+			case <-notifier.Closed():
+				return
+			}
+		}
+	}()
+	return rpcSub, nil
+}
@@ -24,6 +24,7 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"strings"
 	"sync"
 
 	"github.com/ethereum/go-ethereum/common"
@@ -42,11 +43,22 @@ func NewFileSystem(api *Api) *FileSystem {
 }
 
 // Upload replicates a local directory as a manifest file and uploads it
-// using dpa store
+// using dpa store. Files whose chunks are all stored locally already, uploaded
+// before or synced from neighbours, are not stored again.
 // TODO: localpath should point to a manifest
 //
 // DEPRECATED: Use the HTTP API instead
 func (self *FileSystem) Upload(lpath, index string) (string, error) {
+	return self.upload(lpath, index, false)
+}
+
+// Hash returns the hash of the manifest Upload would create for a local file
+// or directory, without storing anything.
+func (self *FileSystem) Hash(lpath, index string) (string, error) {
+	return self.upload(lpath, index, true)
+}
+
+func (self *FileSystem) upload(lpath, index string, dryRun bool) (string, error) {
 	var list []*manifestTrieEntry
 	localpath, err := filepath.Abs(filepath.Clean(lpath))
 	if err != nil {
@@ -113,7 +125,15 @@ func (self *FileSystem) Upload(lpath, index string) (string, error) {
 				stat, _ := f.Stat()
 				var hash storage.Key
 				wg := &sync.WaitGroup{}
-				hash, err = self.api.dpa.Store(f, stat.Size(), wg, nil)
+				// hash the file first, only storing it if not stored already
+				hash, err = self.api.dpa.Chunker.Split(f, stat.Size(), nil, nil, nil)
+				if err == nil && !dryRun {
+					if self.api.isStored(hash) {
+						log.Debug("Skipping upload of stored file", "path", entry.Path, "hash", hash)
+					} else if _, err = f.Seek(0, io.SeekStart); err == nil {
+						hash, err = self.api.dpa.Store(f, stat.Size(), wg, nil)
+					}
+				}
 				if hash != nil {
 					list[i].Hash = hash.String()
 				}
@@ -142,7 +162,8 @@ func (self *FileSystem) Upload(lpath, index string) (string, error) {
 	}
 
 	trie := &manifestTrie{
-		dpa: self.api.dpa,
+		dpa:    self.api.dpa,
+		params: self.api.manifests,
 	}
 	quitC := make(chan bool)
 	for i, entry := range list {
@@ -160,7 +181,7 @@ func (self *FileSystem) Upload(lpath, index string) (string, error) {
 		trie.addEntry(entry, quitC)
 	}
 
-	err2 := trie.recalcAndStore()
+	err2 := trie.recalc(!dryRun)
 	var hs string
 	if err2 == nil {
 		hs = trie.hash.String()
@@ -169,6 +190,30 @@ func (self *FileSystem) Upload(lpath, index string) (string, error) {
 	return hs, err2
 }
 
+// Stat reports the size and integrity of the content under a bzz path, optionally
+// repairing missing chunks from the network (see Api.Stat).
+func (self *FileSystem) Stat(bzzpath string, repair bool) (*ManifestStat, error) {
+	return self.api.Stat(bzzpath, repair)
+}
+
+// List returns the files and common prefixes of the manifest under a bzz path,
+// as served by the bzz-list HTTP scheme.
+func (self *FileSystem) List(bzzpath string) (*ManifestList, error) {
+	uri, err := Parse("bzz:/" + strings.TrimPrefix(bzzpath, "/"))
+	if err != nil {
+		return nil, err
+	}
+	key, err := self.api.Resolve(uri)
+	if err != nil {
+		return nil, err
+	}
+	list, err := self.api.GetManifestList(key, uri.Path)
+	if err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
+
 // Download replicates the manifest basePath structure on the local filesystem
 // under localpath
 //
@@ -199,7 +244,7 @@ func (self *FileSystem) Download(bzzpath, localpath string) error {
 	}
 
 	quitC := make(chan bool)
-	trie, err := loadManifest(self.api.dpa, key, quitC)
+	trie, err := loadManifest(self.api.dpa, self.api.manifests, key, quitC)
 	if err != nil {
 		log.Warn(fmt.Sprintf("fs.Download: loadManifestTrie error: %v", err))
 		return err
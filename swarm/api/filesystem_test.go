@@ -85,6 +85,51 @@ func TestApiDirUpload0(t *testing.T) {
 	})
 }
 
+// Tests that hashing a directory doesn't store anything and yields the hash of
+// its upload, and that uploading it again only stores the manifests.
+func TestApiDirHash(t *testing.T) {
+	testFileSystem(t, func(fs *FileSystem) {
+		dir := filepath.Join("testdata", "test0")
+
+		events := make(chan storage.StoreEvent, 16)
+		sub := fs.api.dpa.SubscribeStoreEvents(events)
+		defer sub.Unsubscribe()
+
+		hash, err := fs.api.Hash(dir)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(events) != 0 {
+			t.Fatalf("hashing stored %d documents", len(events))
+		}
+		if fs.api.isStored(storage.Key(common.Hex2Bytes(hash))) {
+			t.Fatalf("hashed manifest stored")
+		}
+		bzzhash, err := fs.Upload(dir, "")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if bzzhash != hash {
+			t.Fatalf("hash mismatch: have %v, want %v", hash, bzzhash)
+		}
+		// The first upload stores the files and the manifests, the second only
+		// the manifests
+		stored := len(events)
+		for len(events) > 0 {
+			<-events
+		}
+		if bzzhash, err = fs.Upload(dir, ""); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if bzzhash != hash {
+			t.Fatalf("reupload hash mismatch: have %v, want %v", bzzhash, hash)
+		}
+		if files := stored - len(events); files != 3 {
+			t.Errorf("stored file count mismatch: have %d, want %d", files, 3)
+		}
+	})
+}
+
 func TestApiDirUploadModify(t *testing.T) {
 	testFileSystem(t, func(fs *FileSystem) {
 		api := fs.api
@@ -192,3 +237,71 @@ func TestApiFileUploadWithRootFile(t *testing.T) {
 		checkResponse(t, resp, exp)
 	})
 }
+
+func TestApiStat(t *testing.T) {
+	testFileSystem(t, func(fs *FileSystem) {
+		api := fs.api
+		bzzhash, err := fs.Upload(filepath.Join("testdata", "test0"), "")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		stat, err := fs.Stat(bzzhash, false)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if stat.Entries != 3 || stat.Size != 18136+133+202 || len(stat.Missing) != 0 {
+			t.Errorf("stat mismatch: have %+v, want 3 complete entries of %d bytes", stat, 18136+133+202)
+		}
+		if stat, err = fs.Stat(bzzhash+"/img", false); err != nil || stat.Entries != 1 || stat.Size != 18136 {
+			t.Errorf("path stat mismatch: have %+v, %v, want 1 entry of 18136 bytes", stat, err)
+		}
+		// Reference a chunk unknown to the store, it must be reported missing
+		missing := common.Hex2Bytes("1111111111111111111111111111111111111111111111111111111111111111")
+		manifest := `{"entries":[{"path":"a","hash":"` + common.Bytes2Hex(missing) + `","size":10}]}`
+		wg := &sync.WaitGroup{}
+		key, err := api.dpa.Store(bytes.NewReader([]byte(manifest)), int64(len(manifest)), wg, nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		wg.Wait()
+		for _, repair := range []bool{false, true} {
+			stat, err := fs.Stat(key.Hex(), repair)
+			if err != nil {
+				t.Fatalf("repair %v: unexpected error: %v", repair, err)
+			}
+			if stat.Entries != 1 || stat.Size != 10 || stat.Repaired != 0 {
+				t.Errorf("repair %v: stat mismatch: have %+v", repair, stat)
+			}
+			if len(stat.Missing) != 1 || stat.Missing[0] != storage.Key(missing).Hex() {
+				t.Errorf("repair %v: missing chunks mismatch: have %v, want [%x]", repair, stat.Missing, missing)
+			}
+		}
+	})
+}
+
+// Tests that listing a manifest groups nested directories into common prefixes.
+func TestApiList(t *testing.T) {
+	testFileSystem(t, func(fs *FileSystem) {
+		bzzhash, err := fs.Upload(filepath.Join("testdata", "test0"), "")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		list, err := fs.List(bzzhash)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(list.CommonPrefixes) != 1 || list.CommonPrefixes[0] != "img/" {
+			t.Errorf("common prefixes mismatch: have %v, want [img/]", list.CommonPrefixes)
+		}
+		var paths []string
+		for _, entry := range list.Entries {
+			paths = append(paths, entry.Path)
+		}
+		if len(paths) != 2 || paths[0] != "index.css" || paths[1] != "index.html" {
+			t.Errorf("entries mismatch: have %v, want [index.css index.html]", paths)
+		}
+		if list, err = fs.List(bzzhash + "/img/"); err != nil || len(list.Entries) != 1 || len(list.CommonPrefixes) != 0 {
+			t.Errorf("path list mismatch: have %+v, %v, want 1 entry", list, err)
+		}
+	})
+}
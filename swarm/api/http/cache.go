@@ -0,0 +1,220 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package http
+
+import (
+	"bytes"
+	"container/list"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+// cacheEntryRatio is the inverse of the largest share of a cache tier a single
+// document may take up, larger ones are streamed without caching.
+const cacheEntryRatio = 8
+
+var (
+	cacheHitCount     = metrics.NewRegisteredCounter("api.http.cache.hit", nil)
+	cacheDiskHitCount = metrics.NewRegisteredCounter("api.http.cache.disk.hit", nil)
+	cacheMissCount    = metrics.NewRegisteredCounter("api.http.cache.miss", nil)
+	cacheSizeGauge    = metrics.NewRegisteredGauge("api.http.cache.size", nil)
+	cacheDiskGauge    = metrics.NewRegisteredGauge("api.http.cache.disk.size", nil)
+)
+
+// cachedDocument is a document served by the gateway.
+type cachedDocument struct {
+	contentType string
+	data        []byte
+}
+
+// lruIndex tracks the recency and total size of the items of a cache tier.
+type lruIndex struct {
+	order *list.List               // Items in recency order, most recent first
+	items map[string]*list.Element // Items by key, the elements holding *lruItem
+	size  int64                    // Total size of the items
+	limit int64                    // Maximum total size of the items
+}
+
+// lruItem is a single item of a cache tier.
+type lruItem struct {
+	key  string
+	size int64
+	doc  *cachedDocument // Document held in memory (nil for the disk tier)
+}
+
+func newLRUIndex(limit int64) *lruIndex {
+	return &lruIndex{
+		order: list.New(),
+		items: make(map[string]*list.Element),
+		limit: limit,
+	}
+}
+
+// get retrieves an item, marking it as most recently used.
+func (idx *lruIndex) get(key string) *lruItem {
+	elem, ok := idx.items[key]
+	if !ok {
+		return nil
+	}
+	idx.order.MoveToFront(elem)
+	return elem.Value.(*lruItem)
+}
+
+// add inserts an item, returning the least recently used ones evicted to make
+// room for it.
+func (idx *lruIndex) add(item *lruItem) []*lruItem {
+	if elem, ok := idx.items[item.key]; ok {
+		idx.order.MoveToFront(elem)
+		return nil
+	}
+	idx.items[item.key] = idx.order.PushFront(item)
+	idx.size += item.size
+
+	var evicted []*lruItem
+	for idx.size > idx.limit {
+		oldest := idx.order.Remove(idx.order.Back()).(*lruItem)
+		delete(idx.items, oldest.key)
+		idx.size -= oldest.size
+		evicted = append(evicted, oldest)
+	}
+	return evicted
+}
+
+// responseCache is a size bounded cache of the documents served by the gateway,
+// keyed by the resolved content hash and the path within it. Content in swarm
+// is immutable, so entries never go stale and are only evicted to make room.
+//
+// Documents are held in memory, and if a directory is given, also written to
+// disk, from where they are reloaded after being evicted from memory.
+type responseCache struct {
+	mem  *lruIndex
+	disk *lruIndex // Index of the documents on disk (nil = memory only)
+	dir  string
+
+	lock sync.Mutex
+}
+
+// newResponseCache creates a cache holding up to memSize bytes of documents in
+// memory and diskSize bytes in dir. The documents already on disk are indexed
+// in their modification order.
+func newResponseCache(memSize, diskSize int64, dir string) (*responseCache, error) {
+	c := &responseCache{mem: newLRUIndex(memSize)}
+	if diskSize <= 0 || dir == "" {
+		return c, nil
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].ModTime().Before(files[j].ModTime()) })
+
+	c.disk, c.dir = newLRUIndex(diskSize), dir
+	for _, file := range files {
+		if file.IsDir() {
+			continue
+		}
+		for _, evicted := range c.disk.add(&lruItem{key: file.Name(), size: file.Size()}) {
+			os.Remove(filepath.Join(dir, evicted.key))
+		}
+	}
+	cacheDiskGauge.Update(c.disk.size)
+	return c, nil
+}
+
+// cacheable checks whether a document of the given size fits into the cache.
+func (c *responseCache) cacheable(size int64) bool {
+	if size <= c.mem.limit/cacheEntryRatio {
+		return true
+	}
+	return c.disk != nil && size <= c.disk.limit/cacheEntryRatio
+}
+
+// get retrieves a document from the cache.
+func (c *responseCache) get(key string) (*cachedDocument, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if item := c.mem.get(key); item != nil {
+		cacheHitCount.Inc(1)
+		return item.doc, true
+	}
+	if c.disk != nil {
+		name := diskName(key)
+		if item := c.disk.get(name); item != nil {
+			blob, err := ioutil.ReadFile(filepath.Join(c.dir, name))
+			if i := bytes.IndexByte(blob, '\n'); err == nil && i >= 0 {
+				doc := &cachedDocument{contentType: string(blob[:i]), data: blob[i+1:]}
+				if int64(len(doc.data)) <= c.mem.limit/cacheEntryRatio {
+					c.addMem(key, doc)
+				}
+
+				cacheDiskHitCount.Inc(1)
+				return doc, true
+			}
+			log.Debug("Failed to load cached document", "key", key, "err", err)
+		}
+	}
+	cacheMissCount.Inc(1)
+	return nil, false
+}
+
+// put inserts a document into the cache.
+func (c *responseCache) put(key string, doc *cachedDocument) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	size := int64(len(doc.data))
+	if size <= c.mem.limit/cacheEntryRatio {
+		c.addMem(key, doc)
+	}
+	if c.disk != nil && size <= c.disk.limit/cacheEntryRatio {
+		name := diskName(key)
+		if c.disk.get(name) != nil {
+			return
+		}
+		blob := append([]byte(doc.contentType+"\n"), doc.data...)
+		if err := ioutil.WriteFile(filepath.Join(c.dir, name), blob, 0600); err != nil {
+			log.Warn("Failed to write cached document", "key", key, "err", err)
+			return
+		}
+		for _, evicted := range c.disk.add(&lruItem{key: name, size: int64(len(blob))}) {
+			os.Remove(filepath.Join(c.dir, evicted.key))
+		}
+		cacheDiskGauge.Update(c.disk.size)
+	}
+}
+
+// addMem inserts a document into the memory tier. The lock must be held.
+func (c *responseCache) addMem(key string, doc *cachedDocument) {
+	c.mem.add(&lruItem{key: key, size: int64(len(doc.data)), doc: doc})
+	cacheSizeGauge.Update(c.mem.size)
+}
+
+// diskName returns the file name a document is stored under on disk.
+func diskName(key string) string {
+	return crypto.Keccak256Hash([]byte(key)).Hex()[2:]
+}
@@ -0,0 +1,75 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package http
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+// Tests that the response cache evicts the least recently used documents from
+// memory, reloading them from disk if available.
+func TestResponseCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "swarm-http-cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Memory fits 8 documents of 100 bytes, disk all of them
+	cache, err := newResponseCache(800, 8*1024, dir)
+	if err != nil {
+		t.Fatalf("failed to create cache: %v", err)
+	}
+	for i := 0; i < 10; i++ {
+		cache.put(fmt.Sprintf("doc-%d", i), &cachedDocument{contentType: "text/plain", data: bytes.Repeat([]byte{byte(i)}, 100)})
+	}
+	if len(cache.mem.items) != 8 {
+		t.Errorf("memory item count mismatch: have %d, want 8", len(cache.mem.items))
+	}
+	if _, ok := cache.mem.items["doc-0"]; ok {
+		t.Errorf("least recently used document not evicted from memory")
+	}
+	// Evicted documents must be loaded from disk, also after a restart
+	for _, c := range []*responseCache{cache, mustResponseCache(t, 800, 8*1024, dir)} {
+		doc, ok := c.get("doc-0")
+		if !ok {
+			t.Fatalf("evicted document not found")
+		}
+		if doc.contentType != "text/plain" || !bytes.Equal(doc.data, bytes.Repeat([]byte{0}, 100)) {
+			t.Errorf("document mismatch: have %s/%x", doc.contentType, doc.data)
+		}
+	}
+	// Documents too large for both tiers must not be cached
+	if cache.cacheable(1025) {
+		t.Errorf("oversized document cacheable")
+	}
+	if _, ok := cache.get("missing"); ok {
+		t.Errorf("missing document found")
+	}
+}
+
+func mustResponseCache(t *testing.T, memSize, diskSize int64, dir string) *responseCache {
+	cache, err := newResponseCache(memSize, diskSize, dir)
+	if err != nil {
+		t.Fatalf("failed to create cache: %v", err)
+	}
+	return cache
+}
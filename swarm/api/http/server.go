@@ -21,6 +21,7 @@ package http
 
 import (
 	"archive/tar"
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -28,6 +29,7 @@ import (
 	"io/ioutil"
 	"mime"
 	"mime/multipart"
+	"net"
 	"net/http"
 	"os"
 	"path"
@@ -36,6 +38,7 @@ import (
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/metrics"
 	"github.com/ethereum/go-ethereum/swarm/api"
@@ -60,6 +63,9 @@ var (
 	getFilesFail     = metrics.NewRegisteredCounter("api.http.get.files.fail", nil)
 	getListCount     = metrics.NewRegisteredCounter("api.http.get.list.count", nil)
 	getListFail      = metrics.NewRegisteredCounter("api.http.get.list.fail", nil)
+	getInfoCount     = metrics.NewRegisteredCounter("api.http.get.info.count", nil)
+	getInfoFail      = metrics.NewRegisteredCounter("api.http.get.info.fail", nil)
+	getTraceCount    = metrics.NewRegisteredCounter("api.http.get.trace.count", nil)
 	requestCount     = metrics.NewRegisteredCounter("http.request.count", nil)
 	htmlRequestCount = metrics.NewRegisteredCounter("http.request.html.count", nil)
 	jsonRequestCount = metrics.NewRegisteredCounter("http.request.json.count", nil)
@@ -69,8 +75,11 @@ var (
 // ServerConfig is the basic configuration needed for the HTTP server and also
 // includes CORS settings.
 type ServerConfig struct {
-	Addr       string
-	CorsString string
+	Addr          string
+	CorsString    string
+	CacheSize     int64  // Bytes of documents to cache in memory (0 = disabled)
+	CacheDiskSize int64  // Bytes of documents to cache on disk (0 = disabled)
+	CacheDir      string // Directory of the on-disk document cache
 }
 
 // browser API for registering bzz url scheme handlers:
@@ -90,17 +99,37 @@ func StartHttpServer(api *api.Api, config *ServerConfig) {
 		MaxAge:         600,
 		AllowedHeaders: []string{"*"},
 	})
-	hdlr := c.Handler(NewServer(api))
+	server := NewServer(api)
+	if config.CacheSize > 0 || config.CacheDiskSize > 0 {
+		cache, err := newResponseCache(config.CacheSize, config.CacheDiskSize, config.CacheDir)
+		if err != nil {
+			log.Error("Failed to create http response cache", "err", err)
+		} else {
+			server.cache = cache
+		}
+	}
+	hdlr := c.Handler(server)
 
 	go http.ListenAndServe(config.Addr, hdlr)
 }
 
 func NewServer(api *api.Api) *Server {
-	return &Server{api}
+	return &Server{api: api}
 }
 
 type Server struct {
-	api *api.Api
+	api   *api.Api
+	cache *responseCache // cache of the served documents (nil = disabled)
+}
+
+// forClient returns a view of the server storing and retrieving on behalf of
+// the requester, identified by its remote address.
+func (s *Server) forClient(r *http.Request) *Server {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return &Server{api: s.api.ForClient(host), cache: s.cache}
 }
 
 // Request wraps http.Request and also includes the parsed bzz URI
@@ -339,6 +368,17 @@ func (s *Server) HandleGet(w http.ResponseWriter, r *Request) {
 		s.NotFound(w, r, fmt.Errorf("error resolving %s: %s", r.uri.Addr, err))
 		return
 	}
+	// allow the request to overwrite the content type using a query
+	// parameter
+	contentType := "application/octet-stream"
+	if typ := r.URL.Query().Get("content_type"); typ != "" {
+		contentType = typ
+	}
+	raw := r.uri.Raw() || r.uri.DeprecatedRaw()
+	cacheKey := "raw/" + key.Hex() + "/" + r.uri.Path
+	if raw && s.serveCached(w, r, cacheKey, contentType) {
+		return
+	}
 
 	// if path is set, interpret <key> as a manifest and return the
 	// raw entry at the given path
@@ -383,23 +423,16 @@ func (s *Server) HandleGet(w http.ResponseWriter, r *Request) {
 
 	// check the root chunk exists by retrieving the file's size
 	reader := s.api.Retrieve(key)
-	if _, err := reader.Size(nil); err != nil {
+	size, err := reader.Size(nil)
+	if err != nil {
 		getFail.Inc(1)
 		s.NotFound(w, r, fmt.Errorf("Root chunk not found %s: %s", key, err))
 		return
 	}
 
 	switch {
-	case r.uri.Raw() || r.uri.DeprecatedRaw():
-		// allow the request to overwrite the content type using a query
-		// parameter
-		contentType := "application/octet-stream"
-		if typ := r.URL.Query().Get("content_type"); typ != "" {
-			contentType = typ
-		}
-		w.Header().Set("Content-Type", contentType)
-
-		http.ServeContent(w, &r.Request, "", time.Now(), reader)
+	case raw:
+		s.serveContent(w, r, cacheKey, contentType, reader, size)
 	case r.uri.Hash():
 		w.Header().Set("Content-Type", "text/plain")
 		w.WriteHeader(http.StatusOK)
@@ -498,7 +531,7 @@ func (s *Server) HandleGetList(w http.ResponseWriter, r *Request) {
 		return
 	}
 
-	list, err := s.getManifestList(key, r.uri.Path)
+	list, err := s.api.GetManifestList(key, r.uri.Path)
 
 	if err != nil {
 		getListFail.Inc(1)
@@ -529,60 +562,36 @@ func (s *Server) HandleGetList(w http.ResponseWriter, r *Request) {
 	json.NewEncoder(w).Encode(&list)
 }
 
-func (s *Server) getManifestList(key storage.Key, prefix string) (list api.ManifestList, err error) {
-	walker, err := s.api.NewManifestWalker(key, nil)
+// HandleGetInfo handles a GET request to bzz-info:/<manifest>/<path> and returns
+// the metadata of the manifest entry at <path> as JSON, with the size of its
+// content. Ambiguous paths respond with the list of matching entries.
+func (s *Server) HandleGetInfo(w http.ResponseWriter, r *Request) {
+	getInfoCount.Inc(1)
+	key, err := s.api.Resolve(r.uri)
 	if err != nil {
+		getInfoFail.Inc(1)
+		s.NotFound(w, r, fmt.Errorf("error resolving %s: %s", r.uri.Addr, err))
 		return
 	}
-
-	err = walker.Walk(func(entry *api.ManifestEntry) error {
-		// handle non-manifest files
-		if entry.ContentType != api.ManifestType {
-			// ignore the file if it doesn't have the specified prefix
-			if !strings.HasPrefix(entry.Path, prefix) {
-				return nil
-			}
-
-			// if the path after the prefix contains a slash, add a
-			// common prefix to the list, otherwise add the entry
-			suffix := strings.TrimPrefix(entry.Path, prefix)
-			if index := strings.Index(suffix, "/"); index > -1 {
-				list.CommonPrefixes = append(list.CommonPrefixes, prefix+suffix[:index+1])
-				return nil
-			}
-			if entry.Path == "" {
-				entry.Path = "/"
-			}
-			list.Entries = append(list.Entries, entry)
-			return nil
-		}
-
-		// if the manifest's path is a prefix of the specified prefix
-		// then just recurse into the manifest by returning nil and
-		// continuing the walk
-		if strings.HasPrefix(prefix, entry.Path) {
-			return nil
-		}
-
-		// if the manifest's path has the specified prefix, then if the
-		// path after the prefix contains a slash, add a common prefix
-		// to the list and skip the manifest, otherwise recurse into
-		// the manifest by returning nil and continuing the walk
-		if strings.HasPrefix(entry.Path, prefix) {
-			suffix := strings.TrimPrefix(entry.Path, prefix)
-			if index := strings.Index(suffix, "/"); index > -1 {
-				list.CommonPrefixes = append(list.CommonPrefixes, prefix+suffix[:index+1])
-				return api.SkipManifest
-			}
-			return nil
+	entry, status, err := s.api.GetEntry(key, r.uri.Path)
+	if err != nil {
+		getInfoFail.Inc(1)
+		s.NotFound(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if status == http.StatusMultipleChoices {
+		list, err := s.api.GetManifestList(key, r.uri.Path)
+		if err != nil {
+			getInfoFail.Inc(1)
+			s.Error(w, r, err)
+			return
 		}
-
-		// the manifest neither has the prefix or needs recursing in to
-		// so just skip it
-		return api.SkipManifest
-	})
-
-	return list, nil
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(&list)
+		return
+	}
+	json.NewEncoder(w).Encode(entry)
 }
 
 // HandleGetFile handles a GET request to bzz://<manifest>/<path> and responds
@@ -601,11 +610,30 @@ func (s *Server) HandleGetFile(w http.ResponseWriter, r *Request) {
 		s.NotFound(w, r, fmt.Errorf("error resolving %s: %s", r.uri.Addr, err))
 		return
 	}
+	// point to the registered name of the content, if any
+	if names, _ := s.api.ReverseResolve(key); len(names) > 0 {
+		w.Header().Set("Link", fmt.Sprintf(`</bzz:/%s/%s>; rel="canonical"`, names[0], r.uri.Path))
+	}
+	cacheKey := "bzz/" + key.Hex() + "/" + r.uri.Path
+	if s.serveCached(w, r, cacheKey, "") {
+		return
+	}
 
 	reader, contentType, status, err := s.api.Get(key, r.uri.Path)
+	if redirect, ok := err.(*api.RedirectError); ok {
+		location := redirect.Location
+		if strings.HasPrefix(location, "/") {
+			location = fmt.Sprintf("/%s:/%s%s", r.uri.Scheme, r.uri.Addr, location)
+		}
+		http.Redirect(w, &r.Request, location, redirect.Status)
+		return
+	}
 	if err != nil {
-		switch status {
-		case http.StatusNotFound:
+		switch {
+		case status == http.StatusNotFound && reader != nil:
+			getFileNotFound.Inc(1)
+			s.serveErrorPage(w, r, contentType, reader, err)
+		case status == http.StatusNotFound:
 			getFileNotFound.Inc(1)
 			s.NotFound(w, r, err)
 		default:
@@ -618,7 +646,7 @@ func (s *Server) HandleGetFile(w http.ResponseWriter, r *Request) {
 	//the request results in ambiguous files
 	//e.g. /read with readme.md and readinglist.txt available in manifest
 	if status == http.StatusMultipleChoices {
-		list, err := s.getManifestList(key, r.uri.Path)
+		list, err := s.api.GetManifestList(key, r.uri.Path)
 
 		if err != nil {
 			getFileFail.Inc(1)
@@ -633,17 +661,75 @@ func (s *Server) HandleGetFile(w http.ResponseWriter, r *Request) {
 	}
 
 	// check the root chunk exists by retrieving the file's size
-	if _, err := reader.Size(nil); err != nil {
+	size, err := reader.Size(nil)
+	if err != nil {
 		getFileNotFound.Inc(1)
 		s.NotFound(w, r, fmt.Errorf("File not found %s: %s", r.uri, err))
 		return
 	}
+	s.serveContent(w, r, cacheKey, contentType, reader, size)
+}
+
+// serveErrorPage responds with the error page of a manifest with status 404,
+// falling back to the default page if it can't be retrieved.
+func (s *Server) serveErrorPage(w http.ResponseWriter, r *Request, contentType string, reader storage.LazySectionReader, err error) {
+	size, serr := reader.Size(nil)
+	if serr != nil {
+		s.NotFound(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Length", strconv.FormatInt(size, 10))
+	w.WriteHeader(http.StatusNotFound)
+	if r.Method != "HEAD" {
+		io.Copy(w, io.NewSectionReader(reader, 0, size))
+	}
+}
 
+// serveCached responds with a document from the response cache, overriding its
+// content type if one is given. It returns false if the document isn't cached.
+func (s *Server) serveCached(w http.ResponseWriter, r *Request, key string, contentType string) bool {
+	if s.cache == nil {
+		return false
+	}
+	doc, ok := s.cache.get(key)
+	if !ok {
+		return false
+	}
+	if contentType == "" {
+		contentType = doc.contentType
+	}
 	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("ETag", etag(key))
 
+	http.ServeContent(w, &r.Request, "", time.Now(), bytes.NewReader(doc.data))
+	return true
+}
+
+// serveContent responds with a document, caching it if it fits into the response
+// cache. Range requests are served from the whole cached document, HEAD requests
+// without retrieving it.
+func (s *Server) serveContent(w http.ResponseWriter, r *Request, key string, contentType string, reader storage.LazySectionReader, size int64) {
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("ETag", etag(key))
+
+	if r.Method != "HEAD" && s.cache != nil && s.cache.cacheable(size) {
+		data := make([]byte, size)
+		if n, err := reader.ReadAt(data, 0); int64(n) == size && (err == nil || err == io.EOF) {
+			s.cache.put(key, &cachedDocument{contentType: contentType, data: data})
+			http.ServeContent(w, &r.Request, "", time.Now(), bytes.NewReader(data))
+			return
+		}
+	}
 	http.ServeContent(w, &r.Request, "", time.Now(), reader)
 }
 
+// etag returns the entity tag of a served document. Documents are immutable
+// under their response cache key, so its digest identifies their content.
+func etag(key string) string {
+	return fmt.Sprintf("%q", crypto.Keccak256Hash([]byte(key)).Hex()[2:])
+}
+
 func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if metrics.Enabled {
 		//The increment for request count and request timer themselves have a flag check
@@ -669,13 +755,30 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	uri, err := api.Parse(strings.TrimLeft(r.URL.Path, "/"))
+	// Serve the request subject to the limits of the requester
+	s = s.forClient(r)
+
+	var (
+		uri *api.URI
+		err error
+	)
+	ipfs := strings.HasPrefix(r.URL.Path, "/ipfs/")
+	if ipfs {
+		uri, err = api.ParseIPFSPath(r.URL.Path)
+	} else {
+		uri, err = api.Parse(strings.TrimLeft(r.URL.Path, "/"))
+	}
 	req := &Request{Request: *r, uri: uri}
 	if err != nil {
 		s.logError("Invalid URI %q: %s", r.URL.Path, err)
 		s.BadRequest(w, req, fmt.Sprintf("Invalid URI %q: %s", r.URL.Path, err))
 		return
 	}
+	// IPFS style paths are content addressed, they can only be read
+	if ipfs && r.Method != "GET" && r.Method != "HEAD" {
+		ShowError(w, req, fmt.Sprintf("No %s to %s allowed.", r.Method, r.URL.Path), http.StatusMethodNotAllowed)
+		return
+	}
 	s.logDebug("%s request received for %s", r.Method, uri)
 
 	switch r.Method {
@@ -706,28 +809,99 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		}
 		s.HandleDelete(w, req)
 
-	case "GET":
-		if uri.Raw() || uri.Hash() || uri.DeprecatedRaw() {
-			s.HandleGet(w, req)
+	case "GET", "HEAD":
+		if r.Method == "GET" && r.URL.Query().Get("trace") == "true" {
+			s.HandleTrace(w, req)
 			return
 		}
+		// HEAD requests are served like GET ones, without the body
+		s.handleGet(w, req)
 
-		if uri.List() {
-			s.HandleGetList(w, req)
-			return
-		}
+	default:
+		ShowError(w, req, fmt.Sprintf("Method "+r.Method+" is not supported.", uri), http.StatusMethodNotAllowed)
 
-		if r.Header.Get("Accept") == "application/x-tar" {
-			s.HandleGetFiles(w, req)
-			return
-		}
+	}
+}
 
-		s.HandleGetFile(w, req)
+// handleGet dispatches a GET request by the scheme of its URI
+func (s *Server) handleGet(w http.ResponseWriter, r *Request) {
+	uri := r.uri
+	if uri.Raw() || uri.Hash() || uri.DeprecatedRaw() {
+		s.HandleGet(w, r)
+		return
+	}
 
-	default:
-		ShowError(w, req, fmt.Sprintf("Method "+r.Method+" is not supported.", uri), http.StatusMethodNotAllowed)
+	if uri.List() {
+		s.HandleGetList(w, r)
+		return
+	}
+
+	if uri.Info() {
+		s.HandleGetInfo(w, r)
+		return
+	}
 
+	if r.Header.Get("Accept") == "application/x-tar" {
+		s.HandleGetFiles(w, r)
+		return
 	}
+
+	s.HandleGetFile(w, r)
+}
+
+// traceResponse is the response to a traced GET request.
+type traceResponse struct {
+	Status int   `json:"status"` // status of the untraced response
+	Bytes  int64 `json:"bytes"`  // length of the untraced response body
+	*storage.TraceReport
+}
+
+// discardResponseWriter records the status and length of a response, dropping
+// its body.
+type discardResponseWriter struct {
+	header http.Header
+	status int
+	bytes  int64
+}
+
+func (w *discardResponseWriter) Header() http.Header { return w.header }
+
+func (w *discardResponseWriter) WriteHeader(status int) {
+	w.status = status
+}
+
+func (w *discardResponseWriter) Write(b []byte) (int, error) {
+	w.bytes += int64(len(b))
+	return len(b), nil
+}
+
+// HandleTrace handles a GET request with the trace=true query parameter. It
+// retrieves the requested document bypassing the response cache and responds
+// with the trace of all chunks retrieved, whether they were found locally or
+// which peer delivered them, and their latencies as JSON instead of the
+// document.
+func (s *Server) HandleTrace(w http.ResponseWriter, r *Request) {
+	getTraceCount.Inc(1)
+
+	trace := storage.NewTrace()
+	traced := &Server{api: s.api.Traced(trace)}
+
+	// Serve the request without the trace parameter
+	req, url := *r, *r.URL
+	query := url.Query()
+	query.Del("trace")
+	url.RawQuery = query.Encode()
+	req.URL = &url
+
+	rw := &discardResponseWriter{header: make(http.Header), status: http.StatusOK}
+	traced.handleGet(rw, &req)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(&traceResponse{
+		Status:      rw.status,
+		Bytes:       rw.bytes,
+		TraceReport: trace.Report(),
+	})
 }
 
 func (s *Server) updateManifest(key storage.Key, update func(mw *api.ManifestWriter) error) (storage.Key, error) {
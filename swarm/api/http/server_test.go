@@ -18,6 +18,7 @@ package http_test
 
 import (
 	"bytes"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
@@ -318,3 +319,245 @@ func TestBzzRootRedirect(t *testing.T) {
 		t.Fatalf("expected response to equal %q, got %q", data, gotData)
 	}
 }
+
+// Tests that IPFS style gateway paths serve the manifests and raw content
+// their CIDs refer to.
+func TestBzzGetIPFS(t *testing.T) {
+	srv := testutil.NewTestSwarmServer(t)
+	defer srv.Close()
+
+	client := swarm.NewClient(srv.URL)
+	data := []byte("data")
+	file := &swarm.File{
+		ReadCloser: ioutil.NopCloser(bytes.NewReader(data)),
+		ManifestEntry: api.ManifestEntry{
+			Path:        "index.txt",
+			ContentType: "text/plain",
+			Size:        int64(len(data)),
+		},
+	}
+	manifest, err := client.Upload(file, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := client.UploadRaw(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	paths := []string{
+		"/ipfs/" + api.EncodeCID(common.Hex2Bytes(manifest), false) + "/index.txt",
+		"/ipfs/" + api.EncodeCID(common.Hex2Bytes(raw), true),
+	}
+	for _, path := range paths {
+		res, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("GET %s: status mismatch: have %d, want %d", path, res.StatusCode, http.StatusOK)
+		}
+		if !bytes.Equal(body, data) {
+			t.Fatalf("GET %s: expected response to equal %q, got %q", path, data, body)
+		}
+	}
+	// Content addressed paths can't be written to
+	res, err := http.Post(srv.URL+paths[0], "text/plain", bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusMethodNotAllowed {
+		t.Fatalf("POST status mismatch: have %d, want %d", res.StatusCode, http.StatusMethodNotAllowed)
+	}
+}
+
+// Tests that manifest entries redirecting their path respond with redirects,
+// and that paths without an entry are served the error page of the manifest.
+func TestBzzRedirectsAndErrorPage(t *testing.T) {
+	srv := testutil.NewTestSwarmServer(t)
+	defer srv.Close()
+
+	client := swarm.NewClient(srv.URL)
+	index := []byte("index")
+	page := []byte("page not found")
+	indexHash, err := client.UploadRaw(bytes.NewReader(index), int64(len(index)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pageHash, err := client.UploadRaw(bytes.NewReader(page), int64(len(page)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash, err := client.UploadManifest(&api.Manifest{Entries: []api.ManifestEntry{
+		{Path: "index.html", Hash: indexHash, ContentType: "text/html"},
+		{Path: "old.html", Status: http.StatusMovedPermanently, Location: "/index.html"},
+		{Path: "elsewhere", Status: http.StatusFound, Location: "https://example.com/"},
+		{Path: "404.html", Hash: pageHash, ContentType: "text/html", Status: http.StatusNotFound},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	httpClient := http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	for _, x := range []struct {
+		path     string
+		status   int
+		location string
+		body     []byte
+	}{
+		{"index.html", http.StatusOK, "", index},
+		{"old.html", http.StatusMovedPermanently, "/bzz:/" + hash + "/index.html", nil},
+		{"elsewhere", http.StatusFound, "https://example.com/", nil},
+		{"missing.html", http.StatusNotFound, "", page},
+	} {
+		res, err := httpClient.Get(srv.URL + "/bzz:/" + hash + "/" + x.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.StatusCode != x.status {
+			t.Errorf("GET %s: status mismatch: have %d, want %d", x.path, res.StatusCode, x.status)
+		}
+		if location := res.Header.Get("Location"); location != x.location {
+			t.Errorf("GET %s: location mismatch: have %q, want %q", x.path, location, x.location)
+		}
+		if x.body != nil && !bytes.Equal(body, x.body) {
+			t.Errorf("GET %s: expected response to equal %q, got %q", x.path, x.body, body)
+		}
+	}
+}
+
+// Tests that HEAD requests report the size, content type and entity tag of
+// documents without their body, and that bzz-info returns the metadata of
+// manifest entries.
+func TestBzzHeadAndInfo(t *testing.T) {
+	srv := testutil.NewTestSwarmServer(t)
+	defer srv.Close()
+
+	client := swarm.NewClient(srv.URL)
+	data := []byte("data")
+	file := &swarm.File{
+		ReadCloser: ioutil.NopCloser(bytes.NewReader(data)),
+		ManifestEntry: api.ManifestEntry{
+			Path:        "dir/index.txt",
+			ContentType: "text/plain",
+			Size:        int64(len(data)),
+		},
+	}
+	hash, err := client.Upload(file, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	url := srv.URL + "/bzz:/" + hash + "/dir/index.txt"
+
+	res, err := http.Head(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("status mismatch: have %d, want %d", res.StatusCode, http.StatusOK)
+	}
+	if len(body) != 0 {
+		t.Errorf("HEAD response has a body: %q", body)
+	}
+	if res.ContentLength != int64(len(data)) {
+		t.Errorf("content length mismatch: have %d, want %d", res.ContentLength, len(data))
+	}
+	if typ := res.Header.Get("Content-Type"); typ != "text/plain" {
+		t.Errorf("content type mismatch: have %q, want %q", typ, "text/plain")
+	}
+	etag := res.Header.Get("ETag")
+	if etag == "" {
+		t.Fatalf("no ETag in HEAD response")
+	}
+	// The entity tag must validate the cached document
+	req, _ := http.NewRequest("GET", url, nil)
+	req.Header.Set("If-None-Match", etag)
+	if res, err = http.DefaultClient.Do(req); err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusNotModified {
+		t.Errorf("conditional GET status mismatch: have %d, want %d", res.StatusCode, http.StatusNotModified)
+	}
+
+	entry, err := client.Info(hash, "dir/index.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry.Path != "dir/index.txt" || entry.ContentType != "text/plain" || entry.Size != int64(len(data)) {
+		t.Errorf("entry mismatch: have %+v", entry)
+	}
+	if _, err := client.Info(hash, "missing.txt"); err == nil {
+		t.Errorf("info of missing entry returned no error")
+	}
+}
+
+// Tests that traced requests report the retrieved chunks instead of the content.
+func TestBzzTrace(t *testing.T) {
+	srv := testutil.NewTestSwarmServer(t)
+	defer srv.Close()
+
+	client := swarm.NewClient(srv.URL)
+	data := bytes.Repeat([]byte("data"), 4096)
+	file := &swarm.File{
+		ReadCloser: ioutil.NopCloser(bytes.NewReader(data)),
+		ManifestEntry: api.ManifestEntry{
+			Path:        "file.txt",
+			ContentType: "text/plain",
+			Size:        int64(len(data)),
+		},
+	}
+	hash, err := client.Upload(file, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := http.Get(srv.URL + "/bzz:/" + hash + "/file.txt?trace=true")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	if typ := res.Header.Get("Content-Type"); typ != "application/json" {
+		t.Fatalf("content type mismatch: have %q, want %q", typ, "application/json")
+	}
+	var trace struct {
+		Status int   `json:"status"`
+		Bytes  int64 `json:"bytes"`
+		storage.TraceReport
+	}
+	if err := json.NewDecoder(res.Body).Decode(&trace); err != nil {
+		t.Fatalf("failed to decode trace: %v", err)
+	}
+	if trace.Status != http.StatusOK || trace.Bytes != int64(len(data)) {
+		t.Errorf("traced response mismatch: have status %d with %d bytes, want %d with %d", trace.Status, trace.Bytes, http.StatusOK, len(data))
+	}
+	// The manifest, the root and the 4 data chunks of the file are all local
+	if trace.Local < 6 || trace.Remote != 0 || trace.Failed != 0 {
+		t.Errorf("chunk counts mismatch: have %d local, %d remote, %d failed", trace.Local, trace.Remote, trace.Failed)
+	}
+	if len(trace.Chunks) != trace.Local {
+		t.Errorf("chunk trace count mismatch: have %d, want %d", len(trace.Chunks), trace.Local)
+	}
+	for _, chunk := range trace.Chunks {
+		if !chunk.Local || chunk.Size == 0 {
+			t.Errorf("chunk %x trace mismatch: local %v, size %d", chunk.Key, chunk.Local, chunk.Size)
+		}
+	}
+}
@@ -23,6 +23,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"regexp"
 	"strings"
 	"sync"
 	"time"
@@ -34,8 +35,53 @@ import (
 
 const (
 	ManifestType = "application/bzz-manifest+json"
+
+	DefaultMaxManifestSize    = 8 * 1024 * 1024 // bytes of a single manifest
+	DefaultMaxManifestEntries = 65536           // entries of a single manifest
+	DefaultMaxManifestDepth   = 256             // levels of nested manifests
 )
 
+var entryHashMatcher = regexp.MustCompile("^([0-9A-Fa-f]{2})+$")
+
+// ManifestParams limits the manifests loaded from the store, protecting the
+// node from hostile manifests exhausting its memory. A zero limit disables
+// the check.
+type ManifestParams struct {
+	MaxManifestSize    int64
+	MaxManifestEntries int
+	MaxManifestDepth   int
+}
+
+func NewDefaultManifestParams() *ManifestParams {
+	return &ManifestParams{
+		MaxManifestSize:    DefaultMaxManifestSize,
+		MaxManifestEntries: DefaultMaxManifestEntries,
+		MaxManifestDepth:   DefaultMaxManifestDepth,
+	}
+}
+
+// ManifestError is returned for manifests which are malformed or exceed the
+// configured limits. Line and Column locate syntax and type errors in the
+// manifest JSON, Field names the offending field if known.
+type ManifestError struct {
+	Hash   storage.Key
+	Line   int
+	Column int
+	Field  string
+	Err    error
+}
+
+func (e *ManifestError) Error() string {
+	msg := fmt.Sprintf("manifest %v", e.Hash.Log())
+	if e.Line > 0 {
+		msg += fmt.Sprintf(" line %d column %d", e.Line, e.Column)
+	}
+	if e.Field != "" {
+		msg += fmt.Sprintf(" field %s", e.Field)
+	}
+	return fmt.Sprintf("%s: %v", msg, e.Err)
+}
+
 // Manifest represents a swarm manifest
 type Manifest struct {
 	Entries []ManifestEntry `json:"entries,omitempty"`
@@ -50,6 +96,17 @@ type ManifestEntry struct {
 	Size        int64     `json:"size,omitempty"`
 	ModTime     time.Time `json:"mod_time,omitempty"`
 	Status      int       `json:"status,omitempty"`
+	Location    string    `json:"location,omitempty"`
+}
+
+// Entries with a redirect status (301 or 302) redirect requests for their path
+// to Location instead of serving content. Locations starting with a slash are
+// relative to the root of the manifest. An entry with status 404 designates
+// the page served for paths the manifest has no entry for.
+
+// isRedirect returns whether an entry status redirects requests.
+func isRedirect(status int) bool {
+	return status == http.StatusMovedPermanently || status == http.StatusFound
 }
 
 // ManifestList represents the result of listing files in a manifest
@@ -58,6 +115,64 @@ type ManifestList struct {
 	Entries        []*ManifestEntry `json:"entries,omitempty"`
 }
 
+// GetManifestList lists the entries of the manifest under key having the given
+// path prefix, grouping the entries of nested directories into common prefixes.
+func (a *Api) GetManifestList(key storage.Key, prefix string) (list ManifestList, err error) {
+	walker, err := a.NewManifestWalker(key, nil)
+	if err != nil {
+		return
+	}
+
+	err = walker.Walk(func(entry *ManifestEntry) error {
+		// handle non-manifest files
+		if entry.ContentType != ManifestType {
+			// ignore the file if it doesn't have the specified prefix
+			if !strings.HasPrefix(entry.Path, prefix) {
+				return nil
+			}
+
+			// if the path after the prefix contains a slash, add a
+			// common prefix to the list, otherwise add the entry
+			suffix := strings.TrimPrefix(entry.Path, prefix)
+			if index := strings.Index(suffix, "/"); index > -1 {
+				list.CommonPrefixes = append(list.CommonPrefixes, prefix+suffix[:index+1])
+				return nil
+			}
+			if entry.Path == "" {
+				entry.Path = "/"
+			}
+			list.Entries = append(list.Entries, entry)
+			return nil
+		}
+
+		// if the manifest's path is a prefix of the specified prefix
+		// then just recurse into the manifest by returning nil and
+		// continuing the walk
+		if strings.HasPrefix(prefix, entry.Path) {
+			return nil
+		}
+
+		// if the manifest's path has the specified prefix, then if the
+		// path after the prefix contains a slash, add a common prefix
+		// to the list and skip the manifest, otherwise recurse into
+		// the manifest by returning nil and continuing the walk
+		if strings.HasPrefix(entry.Path, prefix) {
+			suffix := strings.TrimPrefix(entry.Path, prefix)
+			if index := strings.Index(suffix, "/"); index > -1 {
+				list.CommonPrefixes = append(list.CommonPrefixes, prefix+suffix[:index+1])
+				return SkipManifest
+			}
+			return nil
+		}
+
+		// the manifest neither has the prefix or needs recursing in to
+		// so just skip it
+		return SkipManifest
+	})
+
+	return list, nil
+}
+
 // NewManifest creates and stores a new, empty manifest
 func (a *Api) NewManifest() (storage.Key, error) {
 	var manifest Manifest
@@ -76,7 +191,7 @@ type ManifestWriter struct {
 }
 
 func (a *Api) NewManifestWriter(key storage.Key, quitC chan bool) (*ManifestWriter, error) {
-	trie, err := loadManifest(a.dpa, key, quitC)
+	trie, err := loadManifest(a.dpa, a.manifests, key, quitC)
 	if err != nil {
 		return nil, fmt.Errorf("error loading manifest %s: %s", key, err)
 	}
@@ -115,7 +230,7 @@ type ManifestWalker struct {
 }
 
 func (a *Api) NewManifestWalker(key storage.Key, quitC chan bool) (*ManifestWalker, error) {
-	trie, err := loadManifest(a.dpa, key, quitC)
+	trie, err := loadManifest(a.dpa, a.manifests, key, quitC)
 	if err != nil {
 		return nil, fmt.Errorf("error loading manifest %s: %s", key, err)
 	}
@@ -164,6 +279,8 @@ func (m *ManifestWalker) walk(trie *manifestTrie, prefix string, walkFn WalkFn)
 
 type manifestTrie struct {
 	dpa     *storage.DPA
+	params  *ManifestParams         // nil means the defaults
+	depth   int                     // number of manifests above this one
 	entries [257]*manifestTrieEntry // indexed by first character of basePath, entries[256] is the empty basePath entry
 	hash    storage.Key             // if hash != nil, it is stored
 }
@@ -181,23 +298,44 @@ type manifestTrieEntry struct {
 	subtrie *manifestTrie
 }
 
-func loadManifest(dpa *storage.DPA, hash storage.Key, quitC chan bool) (trie *manifestTrie, err error) { // non-recursive, subtrees are downloaded on-demand
+func loadManifest(dpa *storage.DPA, params *ManifestParams, hash storage.Key, quitC chan bool) (trie *manifestTrie, err error) { // non-recursive, subtrees are downloaded on-demand
+	return loadManifestAt(dpa, params, hash, 0, quitC)
+}
 
+func loadManifestAt(dpa *storage.DPA, params *ManifestParams, hash storage.Key, depth int, quitC chan bool) (trie *manifestTrie, err error) {
+	if params == nil {
+		params = NewDefaultManifestParams()
+	}
+	if params.MaxManifestDepth > 0 && depth > params.MaxManifestDepth {
+		return nil, &ManifestError{Hash: hash, Err: fmt.Errorf("nested deeper than %d manifests", params.MaxManifestDepth)}
+	}
 	log.Trace(fmt.Sprintf("manifest lookup key: '%v'.", hash.Log()))
 	// retrieve manifest via DPA
 	manifestReader := dpa.Retrieve(hash)
-	return readManifest(manifestReader, hash, dpa, quitC)
+	trie, err = readManifest(manifestReader, hash, dpa, params, quitC)
+	if trie != nil {
+		trie.depth = depth
+	}
+	return
 }
 
-func readManifest(manifestReader storage.LazySectionReader, hash storage.Key, dpa *storage.DPA, quitC chan bool) (trie *manifestTrie, err error) { // non-recursive, subtrees are downloaded on-demand
-
-	// TODO check size for oversized manifests
+func readManifest(manifestReader storage.LazySectionReader, hash storage.Key, dpa *storage.DPA, params *ManifestParams, quitC chan bool) (trie *manifestTrie, err error) { // non-recursive, subtrees are downloaded on-demand
+	if params == nil {
+		params = NewDefaultManifestParams()
+	}
 	size, err := manifestReader.Size(quitC)
 	if err != nil { // size == 0
 		// can't determine size means we don't have the root chunk
 		err = fmt.Errorf("Manifest not Found")
 		return
 	}
+	// check the size before allocating, the root chunk is all it takes to
+	// claim an arbitrary size
+	if params.MaxManifestSize > 0 && size > params.MaxManifestSize {
+		err = &ManifestError{Hash: hash, Err: fmt.Errorf("size %d exceeds limit of %d bytes", size, params.MaxManifestSize)}
+		log.Trace(fmt.Sprintf("%v", err))
+		return
+	}
 	manifestData := make([]byte, size)
 	read, err := manifestReader.Read(manifestData)
 	if int64(read) < size {
@@ -209,27 +347,104 @@ func readManifest(manifestReader storage.LazySectionReader, hash storage.Key, dp
 	}
 
 	log.Trace(fmt.Sprintf("Manifest %v retrieved", hash.Log()))
-	var man struct {
-		Entries []*manifestTrieEntry `json:"entries"`
-	}
-	err = json.Unmarshal(manifestData, &man)
+	entries, err := decodeManifest(manifestData, hash, params)
 	if err != nil {
-		err = fmt.Errorf("Manifest %v is malformed: %v", hash.Log(), err)
 		log.Trace(fmt.Sprintf("%v", err))
 		return
 	}
 
-	log.Trace(fmt.Sprintf("Manifest %v has %d entries.", hash.Log(), len(man.Entries)))
+	log.Trace(fmt.Sprintf("Manifest %v has %d entries.", hash.Log(), len(entries)))
 
 	trie = &manifestTrie{
-		dpa: dpa,
+		dpa:    dpa,
+		params: params,
 	}
-	for _, entry := range man.Entries {
+	for _, entry := range entries {
 		trie.addEntry(entry, quitC)
 	}
 	return
 }
 
+// decodeManifest strictly decodes the manifest JSON, rejecting unknown fields,
+// trailing data, invalid entries and more entries than allowed.
+func decodeManifest(data []byte, hash storage.Key, params *ManifestParams) ([]*manifestTrieEntry, error) {
+	var man struct {
+		Entries []*manifestTrieEntry `json:"entries"`
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&man); err != nil {
+		merr := &ManifestError{Hash: hash, Err: err}
+		offset := dec.InputOffset()
+		switch e := err.(type) {
+		case *json.SyntaxError:
+			offset = e.Offset - 1 // the offending byte has been read
+		case *json.UnmarshalTypeError:
+			offset, merr.Field = e.Offset, e.Field
+		}
+		merr.Line, merr.Column = lineAndColumn(data, offset)
+		return nil, merr
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		line, column := lineAndColumn(data, dec.InputOffset())
+		return nil, &ManifestError{Hash: hash, Line: line, Column: column, Err: errors.New("unexpected data after manifest")}
+	}
+	if params.MaxManifestEntries > 0 && len(man.Entries) > params.MaxManifestEntries {
+		return nil, &ManifestError{Hash: hash, Field: "entries", Err: fmt.Errorf("%d entries exceed limit of %d", len(man.Entries), params.MaxManifestEntries)}
+	}
+	for i, entry := range man.Entries {
+		if err := validateManifestEntry(entry); err != nil {
+			err.Hash = hash
+			err.Field = fmt.Sprintf("entries[%d].%s", i, err.Field)
+			return nil, err
+		}
+	}
+	return man.Entries, nil
+}
+
+// validateManifestEntry checks the values of a decoded manifest entry, the
+// returned error names the offending field relative to the entry.
+func validateManifestEntry(entry *manifestTrieEntry) *ManifestError {
+	switch {
+	case entry == nil:
+		return &ManifestError{Err: errors.New("entry is null")}
+	case entry.Hash != "" && !entryHashMatcher.MatchString(entry.Hash):
+		return &ManifestError{Field: "hash", Err: fmt.Errorf("invalid hash %q", entry.Hash)}
+	case entry.ContentType == ManifestType && entry.Hash == "":
+		return &ManifestError{Field: "hash", Err: errors.New("missing hash of submanifest")}
+	case entry.Size < 0:
+		return &ManifestError{Field: "size", Err: fmt.Errorf("negative size %d", entry.Size)}
+	case entry.Mode < 0:
+		return &ManifestError{Field: "mode", Err: fmt.Errorf("negative mode %d", entry.Mode)}
+	case isRedirect(entry.Status) && entry.Location == "":
+		return &ManifestError{Field: "location", Err: errors.New("missing location of redirect")}
+	case entry.Location != "" && !isRedirect(entry.Status):
+		return &ManifestError{Field: "location", Err: fmt.Errorf("location given for status %d", entry.Status)}
+	case entry.Status == http.StatusNotFound && entry.Hash == "":
+		return &ManifestError{Field: "hash", Err: errors.New("missing hash of error page")}
+	case entry.Status != 0 && entry.Status != http.StatusMultipleChoices && entry.Status != http.StatusNotFound && !isRedirect(entry.Status):
+		return &ManifestError{Field: "status", Err: fmt.Errorf("unsupported status %d", entry.Status)}
+	}
+	return nil
+}
+
+// lineAndColumn converts a byte offset in data to a 1-based line and column.
+func lineAndColumn(data []byte, offset int64) (line, column int) {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	line, column = 1, 1
+	for _, b := range data[:offset] {
+		if b == '\n' {
+			line++
+			column = 1
+		} else {
+			column++
+		}
+	}
+	return
+}
+
 func (self *manifestTrie) addEntry(entry *manifestTrieEntry, quitC chan bool) {
 	self.hash = nil // trie modified, hash needs to be re-calculated on demand
 
@@ -263,7 +478,9 @@ func (self *manifestTrie) addEntry(entry *manifestTrieEntry, quitC chan bool) {
 	commonPrefix := entry.Path[:cpl]
 
 	subtrie := &manifestTrie{
-		dpa: self.dpa,
+		dpa:    self.dpa,
+		params: self.params,
+		depth:  self.depth + 1,
 	}
 	entry.Path = entry.Path[cpl:]
 	oldentry.Path = oldentry.Path[cpl:]
@@ -323,6 +540,12 @@ func (self *manifestTrie) deleteEntry(path string, quitC chan bool) {
 }
 
 func (self *manifestTrie) recalcAndStore() error {
+	return self.recalc(true)
+}
+
+// recalc calculates the hash of the manifests of the trie, storing them only
+// if requested
+func (self *manifestTrie) recalc(store bool) error {
 	if self.hash != nil {
 		return nil
 	}
@@ -334,7 +557,7 @@ func (self *manifestTrie) recalcAndStore() error {
 	for _, entry := range self.entries {
 		if entry != nil {
 			if entry.Hash == "" { // TODO: paralellize
-				err := entry.subtrie.recalcAndStore()
+				err := entry.subtrie.recalc(store)
 				if err != nil {
 					return err
 				}
@@ -351,6 +574,11 @@ func (self *manifestTrie) recalcAndStore() error {
 	}
 
 	sr := bytes.NewReader(manifest)
+	if !store {
+		key, err := self.dpa.Chunker.Split(sr, int64(len(manifest)), nil, nil, nil)
+		self.hash = key
+		return err
+	}
 	wg := &sync.WaitGroup{}
 	key, err2 := self.dpa.Store(sr, int64(len(manifest)), wg, nil)
 	wg.Wait()
@@ -361,7 +589,7 @@ func (self *manifestTrie) recalcAndStore() error {
 func (self *manifestTrie) loadSubTrie(entry *manifestTrieEntry, quitC chan bool) (err error) {
 	if entry.subtrie == nil {
 		hash := common.Hex2Bytes(entry.Hash)
-		entry.subtrie, err = loadManifest(self.dpa, hash, quitC)
+		entry.subtrie, err = loadManifestAt(self.dpa, self.params, hash, self.depth+1, quitC)
 		entry.Hash = "" // might not match, should be recalculated
 	}
 	return
@@ -481,6 +709,19 @@ func (self *manifestTrie) findPrefixOf(path string, quitC chan bool) (entry *man
 	return
 }
 
+// findErrorPage returns the entry designated as the error page for paths the
+// manifest has no entry for, if any.
+func (self *manifestTrie) findErrorPage() (page *manifestTrieEntry) {
+	quitC := make(chan bool)
+	self.listWithPrefix("", quitC, func(entry *manifestTrieEntry, suffix string) {
+		if page == nil && entry.Status == http.StatusNotFound {
+			page = entry
+			close(quitC)
+		}
+	})
+	return page
+}
+
 // file system manifest always contains regularized paths
 // no leading or trailing slashes, only single slashes inside
 func RegularSlashes(path string) (res string) {
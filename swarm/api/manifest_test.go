@@ -23,8 +23,10 @@ import (
 	"io"
 	"net/http"
 	"strings"
+	"sync"
 	"testing"
 
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/swarm/storage"
 )
 
@@ -42,7 +44,7 @@ func manifest(paths ...string) (manifestReader storage.LazySectionReader) {
 
 func testGetEntry(t *testing.T, path, match string, multiple bool, paths ...string) *manifestTrie {
 	quitC := make(chan bool)
-	trie, err := readManifest(manifest(paths...), nil, nil, quitC)
+	trie, err := readManifest(manifest(paths...), nil, nil, nil, quitC)
 	if err != nil {
 		t.Errorf("unexpected error making manifest: %v", err)
 	}
@@ -97,7 +99,7 @@ func TestGetEntry(t *testing.T) {
 func TestExactMatch(t *testing.T) {
 	quitC := make(chan bool)
 	mf := manifest("shouldBeExactMatch.css", "shouldBeExactMatch.css.map")
-	trie, err := readManifest(mf, nil, nil, quitC)
+	trie, err := readManifest(mf, nil, nil, nil, quitC)
 	if err != nil {
 		t.Errorf("unexpected error making manifest: %v", err)
 	}
@@ -128,7 +130,7 @@ func TestAddFileWithManifestPath(t *testing.T) {
 	reader := &storage.LazyTestSectionReader{
 		SectionReader: io.NewSectionReader(bytes.NewReader(manifest), 0, int64(len(manifest))),
 	}
-	trie, err := readManifest(reader, nil, nil, nil)
+	trie, err := readManifest(reader, nil, nil, nil, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -144,3 +146,159 @@ func TestAddFileWithManifestPath(t *testing.T) {
 	checkEntry(t, "ac", "ac", false, trie)
 	checkEntry(t, "a", "a", false, trie)
 }
+
+func manifestReader(data string) storage.LazySectionReader {
+	return &storage.LazyTestSectionReader{
+		SectionReader: io.NewSectionReader(strings.NewReader(data), 0, int64(len(data))),
+	}
+}
+
+// TestReadManifestLimits tests that malformed manifests and manifests
+// exceeding the limits are rejected, reporting where the problem is.
+func TestReadManifestLimits(t *testing.T) {
+	params := &ManifestParams{
+		MaxManifestSize:    256,
+		MaxManifestEntries: 2,
+	}
+	hash := strings.Repeat("ab", 32)
+	for _, x := range []struct {
+		manifest string
+		line     int
+		column   int
+		field    string
+		err      string
+	}{
+		{
+			manifest: `{"entries":[{"path":"a"},{"path":"b"}]}`,
+		},
+		{
+			manifest: `{"entries":[{"path":"` + strings.Repeat("a", 256) + `"}]}`,
+			err:      "exceeds limit of 256 bytes",
+		},
+		{
+			manifest: `{"entries":[{"path":"a"},{"path":"b"},{"path":"c"}]}`,
+			field:    "entries",
+			err:      "3 entries exceed limit of 2",
+		},
+		{
+			manifest: "{\"entries\":[\n{\"path\":\"a\"},\n{\"path\" \"b\"}]}",
+			line:     3,
+			column:   9,
+			err:      "invalid character",
+		},
+		{
+			manifest: "{\"entries\":[\n{\"path\":\"a\",\"size\":\"1\"}]}",
+			line:     2,
+			field:    "size",
+			err:      "cannot unmarshal string",
+		},
+		{
+			manifest: `{"entries":[{"path":"a","owner":"me"}]}`,
+			line:     1,
+			err:      `unknown field "owner"`,
+		},
+		{
+			manifest: `{"entries":[]} {}`,
+			line:     1,
+			err:      "unexpected data after manifest",
+		},
+		{
+			manifest: `{"entries":[{"path":"a","hash":"xyz"}]}`,
+			field:    "entries[0].hash",
+			err:      "invalid hash",
+		},
+		{
+			manifest: `{"entries":[{"path":"a","contentType":"` + ManifestType + `"}]}`,
+			field:    "entries[0].hash",
+			err:      "missing hash of submanifest",
+		},
+		{
+			manifest: `{"entries":[{"path":"a","status":301,"location":"/b"},{"path":"c","status":404,"hash":"` + hash + `"}]}`,
+		},
+		{
+			manifest: `{"entries":[{"path":"a","status":302}]}`,
+			field:    "entries[0].location",
+			err:      "missing location of redirect",
+		},
+		{
+			manifest: `{"entries":[{"path":"a","hash":"` + hash + `","location":"/b"}]}`,
+			field:    "entries[0].location",
+			err:      "location given for status 0",
+		},
+		{
+			manifest: `{"entries":[{"path":"a","status":404}]}`,
+			field:    "entries[0].hash",
+			err:      "missing hash of error page",
+		},
+		{
+			manifest: `{"entries":[{"path":"a","hash":"` + hash + `","status":500}]}`,
+			field:    "entries[0].status",
+			err:      "unsupported status 500",
+		},
+		{
+			manifest: `{"entries":[{"path":"a","hash":"` + hash + `"},{"path":"b","size":-1}]}`,
+			field:    "entries[1].size",
+			err:      "negative size",
+		},
+	} {
+		_, err := readManifest(manifestReader(x.manifest), nil, nil, params, nil)
+		if x.err == "" {
+			if err != nil {
+				t.Errorf("unexpected error reading %s: %v", x.manifest, err)
+			}
+			continue
+		}
+		merr, ok := err.(*ManifestError)
+		if !ok {
+			t.Errorf("expected manifest error reading %s, got %v", x.manifest, err)
+			continue
+		}
+		if !strings.Contains(merr.Err.Error(), x.err) {
+			t.Errorf("expected error containing %q reading %s, got %q", x.err, x.manifest, merr.Err)
+		}
+		if x.line != 0 && merr.Line != x.line {
+			t.Errorf("expected error at line %d reading %s, got %d", x.line, x.manifest, merr.Line)
+		}
+		if x.column != 0 && merr.Column != x.column {
+			t.Errorf("expected error at column %d reading %s, got %d", x.column, x.manifest, merr.Column)
+		}
+		if !strings.HasSuffix(merr.Field, x.field) || (x.field == "") != (merr.Field == "") {
+			t.Errorf("expected error in field %q reading %s, got %q", x.field, x.manifest, merr.Field)
+		}
+	}
+}
+
+// TestManifestDepthLimit tests that nested manifests are only followed up to
+// the maximum depth.
+func TestManifestDepthLimit(t *testing.T) {
+	testApi(t, func(api *Api) {
+		store := func(manifest string) string {
+			key, err := api.Store(strings.NewReader(manifest), int64(len(manifest)), &sync.WaitGroup{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			return key.String()
+		}
+		hash := store(`{"entries":[{"path":"c","hash":"` + strings.Repeat("ab", 32) + `"}]}`)
+		for _, path := range []string{"b", "a"} {
+			hash = store(`{"entries":[{"path":"` + path + `","hash":"` + hash + `","contentType":"` + ManifestType + `"}]}`)
+		}
+		key := storage.Key(common.Hex2Bytes(hash))
+
+		trie, err := loadManifest(api.dpa, api.manifests, key, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkEntry(t, "abc", "abc", false, trie)
+
+		api.SetManifestParams(&ManifestParams{MaxManifestDepth: 1})
+		trie, err = loadManifest(api.dpa, api.manifests, key, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = trie.listWithPrefix("", nil, func(*manifestTrieEntry, string) {})
+		if _, ok := err.(*ManifestError); !ok {
+			t.Fatalf("expected manifest error listing too deeply nested manifests, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,342 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package api
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/swarm/storage"
+)
+
+const (
+	// DefaultPublishRefresh is the default number of seconds between re-fetches of
+	// the published content, keeping it from being garbage collected.
+	DefaultPublishRefresh = 3600
+
+	// publishQueueSize is the number of newly published hashes queued for fetching,
+	// overflowing ones are fetched on the next refresh.
+	publishQueueSize = 256
+)
+
+// contentPublishedTopic is the topic of the ContentPublished(bytes32) event
+// announcing the swarm hash of new content.
+var contentPublishedTopic = crypto.Keccak256Hash([]byte("ContentPublished(bytes32)"))
+
+var (
+	publishFetchCount = metrics.NewRegisteredCounter("api.publish.fetch.count", nil)
+	publishFetchFail  = metrics.NewRegisteredCounter("api.publish.fetch.fail", nil)
+)
+
+// PinnedContent is the fetch status of content published on chain.
+type PinnedContent struct {
+	Hash    string    `json:"hash"`            // Swarm hash of the content
+	Block   uint64    `json:"block"`           // Block the content was published in
+	Files   int       `json:"files"`           // Number of documents fetched
+	Size    int64     `json:"size"`            // Total size of the documents fetched
+	Fetched time.Time `json:"fetched"`         // Last time the content was fetched in full
+	Error   string    `json:"error,omitempty"` // Failure of the last fetch, if any
+}
+
+// ContentWatcher follows the ContentPublished events of a contract, fetching the
+// published content into the local store as soon as announced and re-fetching it
+// periodically, so that gateways serve dapp front-ends without network lookups.
+// Manifests are fetched along with all the documents they reference.
+type ContentWatcher struct {
+	api      *Api
+	backend  ethereum.LogFilterer
+	contract common.Address
+	from     uint64
+	refresh  time.Duration
+
+	pinned map[common.Hash]*PinnedContent
+	lock   sync.RWMutex
+
+	queue chan common.Hash
+	sub   event.Subscription
+	quit  chan struct{}
+	abort chan bool // Closed on stop, aborting retrievals in progress
+	wg    sync.WaitGroup
+}
+
+// NewContentWatcher creates a watcher pinning the content published by the
+// contract from the given block on, re-fetching it every refresh interval.
+func NewContentWatcher(api *Api, backend ethereum.LogFilterer, contract common.Address, from uint64, refresh time.Duration) *ContentWatcher {
+	return &ContentWatcher{
+		api:      api,
+		backend:  backend,
+		contract: contract,
+		from:     from,
+		refresh:  refresh,
+		pinned:   make(map[common.Hash]*PinnedContent),
+		queue:    make(chan common.Hash, publishQueueSize),
+		quit:     make(chan struct{}),
+		abort:    make(chan bool),
+	}
+}
+
+// Start subscribes to the published content events, then catches up with the
+// content published before, and starts fetching.
+func (self *ContentWatcher) Start() error {
+	query := ethereum.FilterQuery{
+		FromBlock: new(big.Int).SetUint64(self.from),
+		Addresses: []common.Address{self.contract},
+		Topics:    [][]common.Hash{{contentPublishedTopic}},
+	}
+	logs := make(chan types.Log, publishQueueSize)
+	sub, err := self.backend.SubscribeFilterLogs(context.Background(), query, logs)
+	if err != nil {
+		return err
+	}
+	past, err := self.backend.FilterLogs(context.Background(), query)
+	if err != nil {
+		sub.Unsubscribe()
+		return err
+	}
+	for i := range past {
+		self.process(&past[i])
+	}
+	self.sub = sub
+
+	self.wg.Add(2)
+	go self.loop(logs)
+	go self.fetcher()
+
+	log.Info("Watching published swarm content", "contract", self.contract, "pinned", len(past))
+	return nil
+}
+
+// Stop terminates watching and fetching the published content.
+func (self *ContentWatcher) Stop() {
+	self.sub.Unsubscribe()
+	close(self.quit)
+	close(self.abort)
+	self.wg.Wait()
+}
+
+// Pinned returns the fetch status of the published content, in the order it
+// was published.
+func (self *ContentWatcher) Pinned() []*PinnedContent {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+
+	pinned := make([]*PinnedContent, 0, len(self.pinned))
+	for _, content := range self.pinned {
+		copy := *content
+		pinned = append(pinned, &copy)
+	}
+	sort.Slice(pinned, func(i, j int) bool {
+		if pinned[i].Block != pinned[j].Block {
+			return pinned[i].Block < pinned[j].Block
+		}
+		return pinned[i].Hash < pinned[j].Hash
+	})
+	return pinned
+}
+
+// loop processes the published content events until stopped.
+func (self *ContentWatcher) loop(logs chan types.Log) {
+	defer self.wg.Done()
+
+	for {
+		select {
+		case l := <-logs:
+			self.process(&l)
+		case err := <-self.sub.Err():
+			// Keep refreshing the content known so far, but newly published
+			// content is missed until restarted
+			log.Error("Published swarm content subscription failed", "contract", self.contract, "err", err)
+			return
+		case <-self.quit:
+			return
+		}
+	}
+}
+
+// process pins the content published by an event, or unpins it if the event
+// was reverted by a reorg. The hash may either be indexed or in the log data.
+func (self *ContentWatcher) process(l *types.Log) {
+	if l.Address != self.contract || len(l.Topics) == 0 || l.Topics[0] != contentPublishedTopic {
+		return
+	}
+	var hash common.Hash
+	switch {
+	case len(l.Topics) > 1:
+		hash = l.Topics[1]
+	case len(l.Data) >= common.HashLength:
+		hash = common.BytesToHash(l.Data[:common.HashLength])
+	default:
+		return
+	}
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	if l.Removed {
+		if content, ok := self.pinned[hash]; ok && content.Block == l.BlockNumber {
+			delete(self.pinned, hash)
+			log.Debug("Unpinned reverted swarm content", "hash", hash, "block", l.BlockNumber)
+		}
+		return
+	}
+	if _, ok := self.pinned[hash]; ok {
+		return
+	}
+	self.pinned[hash] = &PinnedContent{Hash: storage.Key(hash[:]).String(), Block: l.BlockNumber}
+	log.Debug("Pinned published swarm content", "hash", hash, "block", l.BlockNumber)
+
+	select {
+	case self.queue <- hash:
+	default:
+	}
+}
+
+// fetcher fetches newly published content as it arrives, and all the pinned
+// content every refresh interval, also retrying failed fetches.
+func (self *ContentWatcher) fetcher() {
+	defer self.wg.Done()
+
+	refresh := time.NewTicker(self.refresh)
+	defer refresh.Stop()
+
+	for {
+		select {
+		case hash := <-self.queue:
+			self.fetch(hash)
+		case <-refresh.C:
+			for _, hash := range self.hashes() {
+				select {
+				case <-self.quit:
+					return
+				default:
+				}
+				self.fetch(hash)
+			}
+		case <-self.quit:
+			return
+		}
+	}
+}
+
+// hashes returns the hashes of all pinned content.
+func (self *ContentWatcher) hashes() []common.Hash {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+
+	hashes := make([]common.Hash, 0, len(self.pinned))
+	for hash := range self.pinned {
+		hashes = append(hashes, hash)
+	}
+	return hashes
+}
+
+// fetch retrieves pinned content in full and records the outcome.
+func (self *ContentWatcher) fetch(hash common.Hash) {
+	self.lock.RLock()
+	_, ok := self.pinned[hash]
+	self.lock.RUnlock()
+	if !ok {
+		return
+	}
+	publishFetchCount.Inc(1)
+	files, size, err := self.prefetch(storage.Key(hash[:]))
+
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	content, ok := self.pinned[hash]
+	if !ok {
+		return
+	}
+	if err != nil {
+		publishFetchFail.Inc(1)
+		log.Warn("Failed to fetch published swarm content", "hash", hash, "err", err)
+		content.Error = err.Error()
+		return
+	}
+	content.Files, content.Size, content.Fetched, content.Error = files, size, time.Now(), ""
+}
+
+// prefetch reads the content behind a key, along with all documents referenced
+// if it is a manifest, returning the number and total size of the documents.
+func (self *ContentWatcher) prefetch(key storage.Key) (int, int64, error) {
+	walker, err := self.api.NewManifestWalker(key, self.abort)
+	if err != nil {
+		// Not a manifest, or not retrievable: the raw read reports which
+		size, err := self.read(key)
+		if err != nil {
+			return 0, 0, err
+		}
+		return 1, size, nil
+	}
+	var (
+		files int
+		total int64
+	)
+	err = walker.Walk(func(entry *ManifestEntry) error {
+		if entry.ContentType == ManifestType || entry.Hash == "" {
+			return nil
+		}
+		size, err := self.read(storage.Key(common.Hex2Bytes(entry.Hash)))
+		if err != nil {
+			return err
+		}
+		files, total = files+1, total+size
+		return nil
+	})
+	return files, total, err
+}
+
+// read retrieves all chunks of a document, returning its size.
+func (self *ContentWatcher) read(key storage.Key) (int64, error) {
+	reader := self.api.Retrieve(key)
+	size, err := reader.Size(self.abort)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := io.Copy(ioutil.Discard, io.NewSectionReader(reader, 0, size)); err != nil {
+		return 0, err
+	}
+	return size, nil
+}
+
+// PublishAPI provides access to the content pinned from chain events.
+type PublishAPI struct {
+	watcher *ContentWatcher
+}
+
+// NewPublishAPI creates the API of a published content watcher.
+func NewPublishAPI(watcher *ContentWatcher) *PublishAPI {
+	return &PublishAPI{watcher}
+}
+
+// Pinned returns the fetch status of the content published on chain
+// (bzz_pinned).
+func (self *PublishAPI) Pinned() []*PinnedContent {
+	return self.watcher.Pinned()
+}
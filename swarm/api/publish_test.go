@@ -0,0 +1,136 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package api
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/swarm/storage"
+)
+
+// testLogBackend serves a fixed set of past logs and streams the ones sent on
+// its feed.
+type testLogBackend struct {
+	past []types.Log
+	feed event.Feed
+}
+
+func (b *testLogBackend) FilterLogs(ctx context.Context, q ethereum.FilterQuery) ([]types.Log, error) {
+	return b.past, nil
+}
+
+func (b *testLogBackend) SubscribeFilterLogs(ctx context.Context, q ethereum.FilterQuery, ch chan<- types.Log) (ethereum.Subscription, error) {
+	return b.feed.Subscribe(ch), nil
+}
+
+// publishedLog creates a ContentPublished event of the contract, the hash being
+// either indexed or in the data.
+func publishedLog(contract common.Address, key storage.Key, block uint64, indexed bool) types.Log {
+	l := types.Log{
+		Address:     contract,
+		Topics:      []common.Hash{contentPublishedTopic},
+		BlockNumber: block,
+	}
+	if indexed {
+		l.Topics = append(l.Topics, common.BytesToHash(key))
+	} else {
+		l.Data = common.CopyBytes(key)
+	}
+	return l
+}
+
+// waitPinned waits until the number of fully fetched pinned contents reaches the
+// wanted one, returning all pinned content.
+func waitPinned(t *testing.T, watcher *ContentWatcher, want int) []*PinnedContent {
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		pinned, fetched := watcher.Pinned(), 0
+		for _, content := range pinned {
+			if !content.Fetched.IsZero() {
+				fetched++
+			}
+		}
+		if fetched == want {
+			return pinned
+		}
+	}
+	t.Fatalf("timed out waiting for %d fetched contents: %+v", want, watcher.Pinned())
+	return nil
+}
+
+// Tests that content published both before and after starting is pinned and
+// fetched, manifests along with their documents, and that reverted publications
+// are unpinned.
+func TestContentWatcher(t *testing.T) {
+	testApi(t, func(api *Api) {
+		contract := common.HexToAddress("0x0100")
+
+		// Publish a raw document and a manifest of another document
+		raw := []byte("raw published document")
+		wg := new(sync.WaitGroup)
+		rawKey, err := api.Store(bytes.NewReader(raw), int64(len(raw)), wg)
+		if err != nil {
+			t.Fatalf("failed to store raw document: %v", err)
+		}
+		wg.Wait()
+		manifestKey, err := api.Put("<h1>dapp</h1>", "text/html")
+		if err != nil {
+			t.Fatalf("failed to store manifest: %v", err)
+		}
+		otherKey, err := api.Put("unrelated", "text/plain")
+		if err != nil {
+			t.Fatalf("failed to store unrelated content: %v", err)
+		}
+		backend := &testLogBackend{past: []types.Log{
+			publishedLog(contract, rawKey, 1, false),
+			publishedLog(common.HexToAddress("0x0200"), otherKey, 1, true),
+		}}
+		watcher := NewContentWatcher(api, backend, contract, 0, time.Hour)
+		if err := watcher.Start(); err != nil {
+			t.Fatalf("failed to start watcher: %v", err)
+		}
+		defer watcher.Stop()
+
+		pinned := waitPinned(t, watcher, 1)
+		if len(pinned) != 1 || pinned[0].Hash != rawKey.String() || pinned[0].Files != 1 || pinned[0].Size != int64(len(raw)) {
+			t.Fatalf("past publication mismatch: %+v", pinned[0])
+		}
+		// Content published while running must be fetched right away
+		backend.feed.Send(publishedLog(contract, manifestKey, 2, true))
+
+		pinned = waitPinned(t, watcher, 2)
+		if pinned[1].Hash != manifestKey.String() || pinned[1].Block != 2 || pinned[1].Files != 1 || pinned[1].Size != int64(len("<h1>dapp</h1>")) {
+			t.Errorf("live publication mismatch: %+v", pinned[1])
+		}
+		// Reverted publications must be unpinned
+		reverted := publishedLog(contract, manifestKey, 2, true)
+		reverted.Removed = true
+		backend.feed.Send(reverted)
+
+		waitPinned(t, watcher, 1)
+		if pinned := (&PublishAPI{watcher}).Pinned(); len(pinned) != 1 || pinned[0].Hash != rawKey.String() {
+			t.Errorf("pinned content mismatch after revert: %+v", pinned)
+		}
+	})
+}
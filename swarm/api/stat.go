@@ -0,0 +1,156 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package api
+
+import (
+	"encoding/binary"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/swarm/storage"
+)
+
+// ManifestStat is the summary of the content under a bzz path, as found in the
+// local chunk store.
+type ManifestStat struct {
+	Size      int64    `json:"size"`      // Total size of the files
+	Entries   int      `json:"entries"`   // Number of files
+	Manifests int      `json:"manifests"` // Number of manifests, including the root one
+	Depth     int      `json:"depth"`     // Nesting depth of the manifests
+	Chunks    int      `json:"chunks"`    // Number of chunks checked
+	Missing   []string `json:"missing"`   // Keys of the chunks missing locally
+	Repaired  int      `json:"repaired"`  // Number of missing chunks retrieved from the network
+}
+
+// Stat walks the manifest tree under a bzz path, reporting the size and number
+// of files and the integrity of their chunk trees. Only the manifests are read,
+// the files are checked by the presence of their chunks in the local store.
+//
+// If repair is set, missing chunks are requested from the network, and the check
+// continues in the subtrees of the ones retrieved.
+func (self *Api) Stat(bzzpath string, repair bool) (*ManifestStat, error) {
+	uri, err := Parse(path.Join("bzz:/", bzzpath))
+	if err != nil {
+		return nil, err
+	}
+	key, err := self.Resolve(uri)
+	if err != nil {
+		return nil, err
+	}
+	stat := &ManifestStat{Missing: []string{}}
+	if err := self.statManifest(key, uri.Path, 1, repair, stat); err != nil {
+		return nil, err
+	}
+	return stat, nil
+}
+
+// statManifest accumulates the statistics of the entries of a manifest matching
+// a path prefix.
+func (self *Api) statManifest(key storage.Key, prefix string, depth int, repair bool, stat *ManifestStat) error {
+	if _, complete := self.statChunks(key, repair, stat); !complete {
+		return nil
+	}
+	trie, err := loadManifest(self.dpa, self.manifests, key, nil)
+	if err != nil {
+		return fmt.Errorf("error loading manifest %s: %v", key, err)
+	}
+	stat.Manifests++
+	if depth > stat.Depth {
+		stat.Depth = depth
+	}
+	for _, entry := range trie.entries {
+		if entry == nil || entry.Hash == "" {
+			continue
+		}
+		hash := storage.Key(common.Hex2Bytes(entry.Hash))
+
+		if entry.ContentType == ManifestType {
+			// Descend into submanifests on the requested path
+			switch {
+			case strings.HasPrefix(entry.Path, prefix):
+				err = self.statManifest(hash, "", depth+1, repair, stat)
+			case strings.HasPrefix(prefix, entry.Path):
+				err = self.statManifest(hash, prefix[len(entry.Path):], depth+1, repair, stat)
+			}
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		if !strings.HasPrefix(entry.Path, prefix) {
+			continue
+		}
+		stat.Entries++
+		if size, complete := self.statChunks(hash, repair, stat); complete {
+			stat.Size += size
+		} else {
+			stat.Size += entry.Size
+		}
+	}
+	return nil
+}
+
+// statChunks checks the chunk tree of a document for missing chunks, returning
+// the size of the document and whether the tree is complete.
+func (self *Api) statChunks(key storage.Key, repair bool, stat *ManifestStat) (int64, bool) {
+	stat.Chunks++
+
+	chunk, err := self.localStore().Get(key)
+	if err != nil || len(chunk.SData) < 8 {
+		if !repair {
+			stat.Missing = append(stat.Missing, key.Hex())
+			return 0, false
+		}
+		if chunk, err = self.dpa.Get(key); err != nil || chunk == nil || len(chunk.SData) < 8 {
+			log.Debug("Failed to repair chunk", "key", key.Log(), "err", err)
+			stat.Missing = append(stat.Missing, key.Hex())
+			return 0, false
+		}
+		stat.Repaired++
+	}
+	// Chunks spanning more than a chunk's worth of data hold the keys of their children
+	size := int64(binary.LittleEndian.Uint64(chunk.SData[:8]))
+	if size <= storage.DefaultBranches*int64(len(key)) {
+		return size, true
+	}
+	complete := true
+	for children := chunk.SData[8:]; len(children) >= len(key); children = children[len(key):] {
+		if _, ok := self.statChunks(storage.Key(children[:len(key)]), repair, stat); !ok {
+			complete = false
+		}
+	}
+	return size, complete
+}
+
+// isStored returns whether the whole chunk tree of a document is stored locally.
+func (self *Api) isStored(key storage.Key) bool {
+	_, complete := self.statChunks(key, false, new(ManifestStat))
+	return complete
+}
+
+// localStore returns the chunk store holding the locally available chunks.
+func (self *Api) localStore() storage.ChunkStore {
+	if store, ok := self.dpa.ChunkStore.(interface {
+		Local() storage.ChunkStore
+	}); ok {
+		return store.Local()
+	}
+	return self.dpa.ChunkStore
+}
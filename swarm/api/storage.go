@@ -16,7 +16,12 @@
 
 package api
 
-import "path"
+import (
+	"context"
+	"path"
+
+	"github.com/ethereum/go-ethereum/rpc"
+)
 
 type Response struct {
 	MimeType string
@@ -26,7 +31,8 @@ type Response struct {
 	Content string
 }
 
-// implements a service
+// implements a service, serving each RPC client subject to its limits in the
+// DPA
 //
 // DEPRECATED: Use the HTTP API instead
 type Storage struct {
@@ -41,8 +47,8 @@ func NewStorage(api *Api) *Storage {
 // its content type
 //
 // DEPRECATED: Use the HTTP API instead
-func (self *Storage) Put(content, contentType string) (string, error) {
-	key, err := self.api.Put(content, contentType)
+func (self *Storage) Put(ctx context.Context, content, contentType string) (string, error) {
+	key, err := self.client(ctx).Put(content, contentType)
 	if err != nil {
 		return "", err
 	}
@@ -57,16 +63,17 @@ func (self *Storage) Put(content, contentType string) (string, error) {
 // size is resp.Size
 //
 // DEPRECATED: Use the HTTP API instead
-func (self *Storage) Get(bzzpath string) (*Response, error) {
+func (self *Storage) Get(ctx context.Context, bzzpath string) (*Response, error) {
 	uri, err := Parse(path.Join("bzz:/", bzzpath))
 	if err != nil {
 		return nil, err
 	}
-	key, err := self.api.Resolve(uri)
+	api := self.client(ctx)
+	key, err := api.Resolve(uri)
 	if err != nil {
 		return nil, err
 	}
-	reader, mimeType, status, err := self.api.Get(key, uri.Path)
+	reader, mimeType, status, err := api.Get(key, uri.Path)
 	if err != nil {
 		return nil, err
 	}
@@ -87,18 +94,27 @@ func (self *Storage) Get(bzzpath string) (*Response, error) {
 // and merge on  to it. creating an entry w conentType (mime)
 //
 // DEPRECATED: Use the HTTP API instead
-func (self *Storage) Modify(rootHash, path, contentHash, contentType string) (newRootHash string, err error) {
+func (self *Storage) Modify(ctx context.Context, rootHash, path, contentHash, contentType string) (newRootHash string, err error) {
 	uri, err := Parse("bzz:/" + rootHash)
 	if err != nil {
 		return "", err
 	}
-	key, err := self.api.Resolve(uri)
+	api := self.client(ctx)
+	key, err := api.Resolve(uri)
 	if err != nil {
 		return "", err
 	}
-	key, err = self.api.Modify(key, path, contentHash, contentType)
+	key, err = api.Modify(key, path, contentHash, contentType)
 	if err != nil {
 		return "", err
 	}
 	return key.String(), nil
 }
+
+// client returns a view of the api for the RPC client issuing a request.
+func (self *Storage) client(ctx context.Context) *Api {
+	if id := rpc.ClientFromContext(ctx); id != "" {
+		return self.api.ForClient(id)
+	}
+	return self.api
+}
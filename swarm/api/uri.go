@@ -18,8 +18,9 @@ package api
 
 import (
 	"fmt"
-	"net/url"
 	"strings"
+
+	"github.com/ethereum/go-ethereum/common/urlscheme"
 )
 
 // URI is a reference to content stored in swarm.
@@ -31,6 +32,7 @@ type URI struct {
 	// * bzz-immutable - immutable URI of an entry in a swarm manifest
 	//                   (address is not resolved)
 	// * bzz-list      -  list of all files contained in a swarm manifest
+	// * bzz-info      - metadata of an entry in a swarm manifest
 	//
 	// Deprecated Schemes:
 	// * bzzr - raw swarm content
@@ -40,8 +42,10 @@ type URI struct {
 	//
 	Scheme string
 
-	// Addr is either a hexadecimal storage key or it an address which
-	// resolves to a storage key
+	// Addr is either a hexadecimal storage key, a CID of a storage key
+	// or it an address which resolves to a storage key. Addresses can be
+	// pinned to the state of the registry at a block by a port or suffix
+	// (e.g. swarm.eth:4200000 or swarm.eth@4200000)
 	Addr string
 
 	// Path is the path to the content within a swarm manifest
@@ -58,36 +62,37 @@ type URI struct {
 // * <scheme>://<addr>
 // * <scheme>://<addr>/<path>
 //
-// with scheme one of bzz, bzz-raw, bzz-immutable, bzz-list or bzz-hash
+// with scheme one of bzz, bzz-raw, bzz-immutable, bzz-list, bzz-info or bzz-hash
 // or deprecated ones bzzr and bzzi
 func Parse(rawuri string) (*URI, error) {
-	u, err := url.Parse(rawuri)
+	u, err := urlscheme.ParseContentURL(rawuri)
 	if err != nil {
 		return nil, err
 	}
-	uri := &URI{Scheme: u.Scheme}
+	return &URI{Scheme: u.Scheme, Addr: u.Addr(), Path: u.Path}, nil
+}
 
-	// check the scheme is valid
-	switch uri.Scheme {
-	case "bzz", "bzz-raw", "bzz-immutable", "bzz-list", "bzz-hash", "bzzr", "bzzi":
-	default:
-		return nil, fmt.Errorf("unknown scheme %q", u.Scheme)
+// ParseIPFSPath parses an IPFS style gateway path of the form
+// /ipfs/<cid>/<path> into a URI. CIDs of manifests map onto an immutable entry
+// of the manifest, CIDs of raw content onto the raw content itself.
+func ParseIPFSPath(rawpath string) (*URI, error) {
+	parts := strings.SplitN(strings.TrimLeft(rawpath, "/"), "/", 3)
+	if len(parts) < 2 || parts[0] != "ipfs" || parts[1] == "" {
+		return nil, fmt.Errorf("invalid IPFS path %q", rawpath)
 	}
-
-	// handle URIs like bzz://<addr>/<path> where the addr and path
-	// have already been split by url.Parse
-	if u.Host != "" {
-		uri.Addr = u.Host
-		uri.Path = strings.TrimLeft(u.Path, "/")
-		return uri, nil
+	_, raw, err := DecodeCID(parts[1])
+	if err != nil {
+		return nil, err
 	}
-
-	// URI is like bzz:/<addr>/<path> so split the addr and path from
-	// the raw path (which will be /<addr>/<path>)
-	parts := strings.SplitN(strings.TrimLeft(u.Path, "/"), "/", 2)
-	uri.Addr = parts[0]
-	if len(parts) == 2 {
-		uri.Path = parts[1]
+	uri := &URI{Scheme: "bzz-immutable", Addr: parts[1]}
+	if len(parts) == 3 {
+		uri.Path = parts[2]
+	}
+	if raw {
+		if uri.Path != "" {
+			return nil, fmt.Errorf("raw content has no path: %q", rawpath)
+		}
+		uri.Scheme = "bzz-raw"
 	}
 	return uri, nil
 }
@@ -104,6 +109,10 @@ func (u *URI) List() bool {
 	return u.Scheme == "bzz-list"
 }
 
+func (u *URI) Info() bool {
+	return u.Scheme == "bzz-info"
+}
+
 func (u *URI) DeprecatedRaw() bool {
 	return u.Scheme == "bzzr"
 }
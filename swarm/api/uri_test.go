@@ -19,6 +19,8 @@ package api
 import (
 	"reflect"
 	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
 )
 
 func TestParseURI(t *testing.T) {
@@ -99,6 +101,14 @@ func TestParseURI(t *testing.T) {
 			uri:       "bzz://abc123/path/to/entry",
 			expectURI: &URI{Scheme: "bzz", Addr: "abc123", Path: "path/to/entry"},
 		},
+		{
+			uri:       "bzz://swarm.eth:4200000/path/to/entry",
+			expectURI: &URI{Scheme: "bzz", Addr: "swarm.eth:4200000", Path: "path/to/entry"},
+		},
+		{
+			uri:       "bzz:/swarm.eth@4200000/path/to/entry",
+			expectURI: &URI{Scheme: "bzz", Addr: "swarm.eth@4200000", Path: "path/to/entry"},
+		},
 		{
 			uri:        "bzz-hash:",
 			expectURI:  &URI{Scheme: "bzz-hash"},
@@ -174,3 +184,45 @@ func TestParseURI(t *testing.T) {
 		}
 	}
 }
+
+func TestParseIPFSPath(t *testing.T) {
+	key := common.Hex2Bytes("1111111111111111111111111111111111111111111111111111111111111111")
+	manifest, raw := EncodeCID(key, false), EncodeCID(key, true)
+
+	tests := []struct {
+		path      string
+		expectURI *URI
+	}{
+		{path: "/ipfs/"},
+		{path: "/ipfs/abc123"},
+		{path: "/bzz/" + manifest},
+		{path: "/ipfs/" + raw + "/path/to/entry"},
+		{
+			path:      "/ipfs/" + manifest,
+			expectURI: &URI{Scheme: "bzz-immutable", Addr: manifest},
+		},
+		{
+			path:      "/ipfs/" + manifest + "/path/to/entry",
+			expectURI: &URI{Scheme: "bzz-immutable", Addr: manifest, Path: "path/to/entry"},
+		},
+		{
+			path:      "/ipfs/" + raw,
+			expectURI: &URI{Scheme: "bzz-raw", Addr: raw},
+		},
+	}
+	for _, x := range tests {
+		actual, err := ParseIPFSPath(x.path)
+		if x.expectURI == nil {
+			if err == nil {
+				t.Fatalf("expected %s to error", x.path)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("error parsing %s: %s", x.path, err)
+		}
+		if !reflect.DeepEqual(actual, x.expectURI) {
+			t.Fatalf("expected %s to return %#v, got %#v", x.path, x.expectURI, actual)
+		}
+	}
+}
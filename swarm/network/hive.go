@@ -19,10 +19,13 @@ package network
 import (
 	"fmt"
 	"math/rand"
+	"net"
 	"path/filepath"
+	"sort"
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/event"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/metrics"
 	"github.com/ethereum/go-ethereum/p2p/discover"
@@ -56,6 +59,7 @@ type Hive struct {
 	quit         chan bool
 	toggle       chan bool
 	more         chan bool
+	syncFeed     event.Feed // feed of the sync progress with all peers
 
 	// for testing only
 	swapEnabled bool
@@ -108,6 +112,11 @@ func NewHive(addr common.Hash, params *HiveParams, swapEnabled, syncEnabled bool
 	}
 }
 
+// SubscribeSyncEvents registers a subscription of SyncEvent.
+func (self *Hive) SubscribeSyncEvents(ch chan<- SyncEvent) event.Subscription {
+	return self.syncFeed.Subscribe(ch)
+}
+
 func (self *Hive) SyncEnabled(on bool) {
 	self.syncEnabled = on
 }
@@ -303,16 +312,34 @@ func newNodeRecord(addr *peerAddr) *kademlia.NodeRecord {
 // this is to store all thats needed
 func (self *Hive) HandlePeersMsg(req *peersMsgData, from *peer) {
 	var nrs []*kademlia.NodeRecord
+	local := self.listenIP()
 	for _, p := range req.Peers {
 		if err := netutil.CheckRelayIP(from.remoteAddr.IP, p.IP); err != nil {
 			log.Trace(fmt.Sprintf("invalid peer IP %v from %v: %v", from.remoteAddr.IP, p.IP, err))
 			continue
 		}
+		if !netutil.CanReach(local, p.IP) {
+			log.Trace(fmt.Sprintf("unreachable peer IP %v from %v: listening on %v", p.IP, from.remoteAddr.IP, local))
+			continue
+		}
 		nrs = append(nrs, newNodeRecord(p))
 	}
 	self.kad.Add(nrs)
 }
 
+// listenIP returns the IP the node is listening on, nil if unknown or listening
+// on all interfaces
+func (self *Hive) listenIP() net.IP {
+	if self.listenAddr == nil {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(self.listenAddr())
+	if err != nil {
+		return nil
+	}
+	return parseHostIP(host)
+}
+
 // peer wraps the protocol instance to represent a connected peer
 // it implements kademlia.Node interface
 type peer struct {
@@ -385,6 +412,12 @@ func (self *Hive) peers(req *retrieveRequestMsgData) {
 			for _, peer := range self.getPeers(key, int(req.MaxPeers)) {
 				addrs = append(addrs, peer.remoteAddr)
 			}
+			// send peers reachable over the requester's address family first
+			if from := req.from.remoteAddr; from != nil && len(from.IP) > 0 {
+				sort.SliceStable(addrs, func(i, j int) bool {
+					return netutil.SameFamily(addrs[i].IP, from.IP) && !netutil.SameFamily(addrs[j].IP, from.IP)
+				})
+			}
 			log.Debug(fmt.Sprintf("Hive sending %d peer addresses to %v. req.Id: %v, req.Key: %v", len(addrs), req.from, req.Id, req.Key.Log()))
 
 			peersData := &peersMsgData{
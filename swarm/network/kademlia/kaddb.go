@@ -270,6 +270,23 @@ func (self *KadDb) delete(row int, purge []bool) {
 	self.Nodes[row] = nodes
 }
 
+// remove deletes the record of a node from the kaddb row of the given index
+func (self *KadDb) remove(addr Address, index int) bool {
+	defer self.lock.Unlock()
+	self.lock.Lock()
+
+	if _, found := self.index[addr]; !found {
+		return false
+	}
+	dbrow := self.Nodes[index]
+	purge := make([]bool, len(dbrow))
+	for i, node := range dbrow {
+		purge[i] = node.Addr == addr
+	}
+	self.delete(index, purge)
+	return true
+}
+
 // save persists kaddb on disk (written to file on path in json format.
 func (self *KadDb) save(path string, cb func(*NodeRecord, Node)) error {
 	defer self.lock.Unlock()
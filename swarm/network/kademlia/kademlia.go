@@ -315,6 +315,13 @@ func (self *Kademlia) Add(nrs []*NodeRecord) {
 	self.db.add(nrs, self.proximityBin)
 }
 
+// Remove deletes the record of a node from kaddb so that it is not suggested
+// for connection any more, e.g. because it belongs to a different network.
+// It reports whether a record was found.
+func (self *Kademlia) Remove(addr Address) bool {
+	return self.db.remove(addr, self.proximityBin(addr))
+}
+
 // nodesByDistance is a list of nodes, ordered by distance to target.
 type nodesByDistance struct {
 	nodes  []Node
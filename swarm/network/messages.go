@@ -19,6 +19,7 @@ package network
 import (
 	"fmt"
 	"net"
+	"strings"
 	"time"
 
 	"github.com/ethereum/go-ethereum/contracts/chequebook"
@@ -179,6 +180,20 @@ type peerAddr struct {
 	Addr kademlia.Address
 }
 
+// parseHostIP parses the host part of a listening or remote address, dropping
+// the zone of IPv6 link-local addresses. IPv4 addresses are returned in their 4
+// byte form, so they are sent and persisted the same way as by discovery.
+func parseHostIP(host string) net.IP {
+	if i := strings.LastIndexByte(host, '%'); i >= 0 {
+		host = host[:i]
+	}
+	ip := net.ParseIP(host)
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4
+	}
+	return ip
+}
+
 // peerAddr pretty prints as enode
 func (self *peerAddr) String() string {
 	var nodeid discover.NodeID
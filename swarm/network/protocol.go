@@ -57,6 +57,7 @@ var (
 	paymentMsgCounter         = metrics.NewRegisteredCounter("network.protocol.msg.payment.count", nil)
 	invalidMsgCounter         = metrics.NewRegisteredCounter("network.protocol.msg.invalid.count", nil)
 	handleStatusMsgCounter    = metrics.NewRegisteredCounter("network.protocol.msg.handlestatus.count", nil)
+	handshakeMismatchCounter  = metrics.NewRegisteredCounter("network.protocol.handshake.mismatch.count", nil)
 )
 
 const (
@@ -365,11 +366,16 @@ func (self *bzz) handleStatus() (err error) {
 		return fmt.Errorf("<- %v: %v", msg, err)
 	}
 
-	if status.NetworkId != self.NetworkId {
-		return fmt.Errorf("network id mismatch: %d (!= %d)", status.NetworkId, self.NetworkId)
-	}
-
-	if Version != status.Version {
+	// drop peers of other networks and forget their address, so that nodes
+	// gossiped by them are not mixed into our kademlia table
+	if status.NetworkId != self.NetworkId || Version != status.Version {
+		handshakeMismatchCounter.Inc(1)
+		if status.Addr != nil && self.hive.kad.Remove(status.Addr.Addr) {
+			log.Debug(fmt.Sprintf("removed node record of peer %v from other network (%d/%d)", status.Addr, status.Version, status.NetworkId))
+		}
+		if status.NetworkId != self.NetworkId {
+			return fmt.Errorf("network id mismatch: %d (!= %d)", status.NetworkId, self.NetworkId)
+		}
 		return fmt.Errorf("protocol version mismatch: %d (!= %d)", status.Version, Version)
 	}
 
@@ -428,6 +434,7 @@ func (self *bzz) sync(state *syncState) error {
 		self.dbAccess,
 		self.unsyncedKeys, self.store,
 		self.syncParams, state, func() bool { return self.syncEnabled },
+		&self.hive.syncFeed,
 	)
 	if err != nil {
 		return nil
@@ -442,9 +449,12 @@ func (self *bzz) String() string {
 
 // repair reported address if IP missing
 func (self *bzz) peerAddr(base *peerAddr) *peerAddr {
-	if base.IP.IsUnspecified() {
+	if len(base.IP) == 0 || base.IP.IsUnspecified() {
 		host, _, _ := net.SplitHostPort(self.peer.RemoteAddr().String())
-		base.IP = net.ParseIP(host)
+		base.IP = parseHostIP(host)
+	}
+	if ip4 := base.IP.To4(); ip4 != nil {
+		base.IP = ip4
 	}
 	return base
 }
@@ -459,7 +469,7 @@ func (self *bzz) selfAddr() *peerAddr {
 	addr := &peerAddr{
 		Addr: self.hive.addr,
 		ID:   id[:],
-		IP:   net.ParseIP(host),
+		IP:   parseHostIP(host),
 		Port: uint16(intport),
 	}
 	return addr
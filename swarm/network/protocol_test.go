@@ -15,3 +15,70 @@
 // along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
 
 package network
+
+import (
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/swarm/network/kademlia"
+	bzzswap "github.com/ethereum/go-ethereum/swarm/services/swap"
+)
+
+// TestHandshakeMismatch checks that peers of other networks or protocol versions
+// are rejected and their node records removed from kaddb.
+func TestHandshakeMismatch(t *testing.T) {
+	for _, test := range []struct {
+		networkId uint64
+		version   uint64
+		err       string
+	}{
+		{NetworkId + 1, Version, "network id mismatch"},
+		{NetworkId, Version + 1, "protocol version mismatch"},
+	} {
+		hive := NewHive(common.HexToHash("0x01"), NewDefaultHiveParams(), false, false)
+		hive.listenAddr = func() string { return "127.0.0.1:30399" }
+
+		remote := &peerAddr{IP: net.ParseIP("10.0.0.1").To4(), Port: 30399, Addr: kademlia.Address(common.HexToHash("0x02"))}
+		hive.kad.Add([]*kademlia.NodeRecord{newNodeRecord(remote)})
+		if hive.kad.DBCount() != 1 {
+			t.Fatalf("expected 1 node record, got %d", hive.kad.DBCount())
+		}
+
+		swapParams := bzzswap.NewDefaultSwapParams()
+		local, rw := p2p.MsgPipe()
+		self := &bzz{
+			hive:       hive,
+			rw:         local,
+			swapParams: swapParams,
+			NetworkId:  NetworkId,
+		}
+		errc := make(chan error, 1)
+		go func() { errc <- self.handleStatus() }()
+
+		msg, err := rw.ReadMsg()
+		if err != nil {
+			t.Fatalf("failed to read status: %v", err)
+		}
+		msg.Discard()
+		status := &statusMsgData{
+			Version:   test.version,
+			ID:        "test",
+			Addr:      remote,
+			Swap:      &bzzswap.SwapProfile{Profile: swapParams.Profile, PayProfile: swapParams.PayProfile},
+			NetworkId: test.networkId,
+		}
+		if err := p2p.Send(rw, statusMsg, status); err != nil {
+			t.Fatalf("failed to send status: %v", err)
+		}
+		if err := <-errc; err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Fatalf("expected %q error, got %v", test.err, err)
+		}
+		if hive.kad.DBCount() != 0 {
+			t.Fatalf("node record of %q peer not removed", test.err)
+		}
+		local.Close()
+	}
+}
@@ -22,6 +22,7 @@ import (
 	"fmt"
 	"path/filepath"
 
+	"github.com/ethereum/go-ethereum/event"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/swarm/storage"
 )
@@ -68,12 +69,12 @@ type syncState struct {
 
 // wrapper of db-s to provide mockable custom local chunk store access to syncer
 type DbAccess struct {
-	db  *storage.DbStore
+	db  storage.SyncDbStore
 	loc *storage.LocalStore
 }
 
 func NewDbAccess(loc *storage.LocalStore) *DbAccess {
-	return &DbAccess{loc.DbStore.(*storage.DbStore), loc}
+	return &DbAccess{loc.DbStore.(storage.SyncDbStore), loc}
 }
 
 // to obtain the chunks from key or request db entry only
@@ -118,6 +119,15 @@ func (self syncState) String() string {
 	}
 }
 
+// SyncEvent is posted when a history synchronisation stage with a peer starts,
+// and once the whole history is synced.
+type SyncEvent struct {
+	Peer   storage.Key `json:"peer"`   // Address of the remote peer
+	First  uint64      `json:"first"`  // First storage counter of the stage
+	Last   uint64      `json:"last"`   // Last storage counter of the stage
+	Synced bool        `json:"synced"` // Whether all history is synced with the peer
+}
+
 // syncer parameters (global, not peer specific)
 type SyncParams struct {
 	RequestDbPath      string // path for request db (leveldb)
@@ -159,6 +169,7 @@ type syncer struct {
 	deliveryRequest chan bool       // one of two triggers needed to send unsyncedKeys
 	newUnsyncedKeys chan bool       // one of two triggers needed to send unsynced keys
 	quit            chan bool       // signal to quit loops
+	feed            *event.Feed     // feed to post sync progress to (nil = don't post)
 
 	// DB related fields
 	dbAccess *DbAccess // access to dbStore
@@ -185,6 +196,7 @@ func newSyncer(
 	params *SyncParams,
 	state *syncState,
 	syncF func() bool,
+	feed *event.Feed,
 ) (*syncer, error) {
 
 	syncBufferSize := params.SyncBufferSize
@@ -201,6 +213,7 @@ func newSyncer(
 		SyncParams:      params,
 		state:           state,
 		quit:            make(chan bool),
+		feed:            feed,
 		unsyncedKeys:    unsyncedKeys,
 		store:           store,
 	}
@@ -274,6 +287,7 @@ func (self *syncer) sync() {
 	// 0. first replay stale requests from request db
 	if state.SessionAt == 0 {
 		log.Debug(fmt.Sprintf("syncer[%v]: nothing to sync", self.key.Log()))
+		self.post(SyncEvent{Peer: self.key, Synced: true})
 		return
 	}
 	log.Debug(fmt.Sprintf("syncer[%v]: start replaying stale requests from request db", self.key.Log()))
@@ -321,11 +335,13 @@ func (self *syncer) sync() {
 		self.syncState(state)
 	}
 	log.Info(fmt.Sprintf("syncer[%v]: syncing all history complete", self.key.Log()))
+	self.post(SyncEvent{Peer: self.key, First: state.First, Last: state.Last, Synced: true})
 
 }
 
 // wait till syncronised block uptil state is synced
 func (self *syncer) syncState(state *syncState) {
+	self.post(SyncEvent{Peer: self.key, First: state.First, Last: state.Last})
 	self.syncStates <- state
 	select {
 	case <-state.synced:
@@ -333,6 +349,13 @@ func (self *syncer) syncState(state *syncState) {
 	}
 }
 
+// post notifies the subscribers of sync progress, if any.
+func (self *syncer) post(ev SyncEvent) {
+	if self.feed != nil {
+		self.feed.Send(ev)
+	}
+}
+
 // stop quits both request processor and saves the request cache to disk
 func (self *syncer) stop() {
 	close(self.quit)
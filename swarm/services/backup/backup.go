@@ -0,0 +1,375 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package backup publishes the finalized chain into swarm.
+//
+// The chain is uploaded in ranges of consecutive blocks. Every range is a swarm
+// manifest holding the RLP stream of its blocks (importable via geth import) and
+// the RLP list of the receipts of every block. A root manifest indexes all the
+// ranges by their block span, and is optionally registered under an ENS name.
+package backup
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethereum/go-ethereum/swarm/api"
+	"github.com/ethereum/go-ethereum/swarm/api/client"
+)
+
+const (
+	// chainHeadChanSize is the size of channel listening to ChainHeadEvent.
+	chainHeadChanSize = 10
+
+	// blocksPath and receiptsPath are the paths of the block and receipt data
+	// within the manifest of a range.
+	blocksPath   = "blocks.rlp"
+	receiptsPath = "receipts.rlp"
+)
+
+// dbKeyProgress is the database key of the publishing progress.
+var dbKeyProgress = []byte("swarm-backup")
+
+// Config contains the settings of the chain publisher.
+type Config struct {
+	Gateway string // Swarm HTTP gateway to upload through
+	Range   uint64 // Number of blocks to bundle into a single range
+	Name    string // ENS name to register the root manifest under (empty = don't register)
+}
+
+// Registrar is a name service the root manifest can be registered with.
+type Registrar interface {
+	SetContentHash(name string, hash common.Hash) (*types.Transaction, error)
+}
+
+// Range is a span of blocks published into swarm.
+type Range struct {
+	From uint64 `json:"from"`
+	To   uint64 `json:"to"`
+	Hash string `json:"hash"` // Swarm hash of the range manifest
+}
+
+// progress is the persisted state of the publisher.
+type progress struct {
+	Ranges     []*Range `json:"ranges"`     // Published ranges, in chain order
+	Root       string   `json:"root"`       // Swarm hash of the root manifest
+	Registered string   `json:"registered"` // Root hash last registered with the name service
+}
+
+// Service uploads the finalized chain into swarm.
+type Service struct {
+	config    *Config
+	chain     *core.BlockChain
+	db        ethdb.Database
+	client    *client.Client
+	registrar func() (Registrar, error) // Lazily connects to the name service
+
+	progress *progress
+	lock     sync.RWMutex
+
+	update chan struct{}
+	quit   chan struct{}
+	wg     sync.WaitGroup
+}
+
+// New creates a service publishing the chain into swarm. The registrar is only
+// resolved on the first registration, as it may depend on other services.
+func New(config *Config, chain *core.BlockChain, db ethdb.Database, registrar func() (Registrar, error)) (*Service, error) {
+	prog := new(progress)
+	if blob, err := db.Get(dbKeyProgress); err == nil {
+		if err := json.Unmarshal(blob, prog); err != nil {
+			return nil, fmt.Errorf("corrupt backup progress: %v", err)
+		}
+	}
+	return &Service{
+		config:    config,
+		chain:     chain,
+		db:        db,
+		client:    client.NewClient(config.Gateway),
+		registrar: registrar,
+		progress:  prog,
+		update:    make(chan struct{}, 1),
+		quit:      make(chan struct{}),
+	}, nil
+}
+
+// Protocols implements node.Service, returning the P2P network protocols used
+// by the backup service (nil as it doesn't use the devp2p overlay network).
+func (s *Service) Protocols() []p2p.Protocol { return nil }
+
+// APIs implements node.Service, returning the RPC API endpoints provided by the
+// backup service.
+func (s *Service) APIs() []rpc.API {
+	return []rpc.API{{
+		Namespace: "backup",
+		Version:   "1.0",
+		Service:   &PublicBackupAPI{s},
+		Public:    true,
+	}}
+}
+
+// Start implements node.Service, starting to publish the chain.
+func (s *Service) Start(server *p2p.Server) error {
+	if _, ok := s.chain.Engine().(consensus.FinalityVerifier); !ok {
+		log.Warn("Consensus engine without finality, swarm chain backup disabled")
+		return nil
+	}
+	s.wg.Add(2)
+	go s.loop()
+	go s.publisher()
+
+	log.Info("Started swarm chain backup", "gateway", s.config.Gateway, "range", s.config.Range, "name", s.config.Name)
+	return nil
+}
+
+// Stop implements node.Service, terminating the publishing.
+func (s *Service) Stop() error {
+	close(s.quit)
+	s.wg.Wait()
+
+	log.Info("Swarm chain backup stopped")
+	return nil
+}
+
+// loop notifies the publisher of chain head changes. Uploads may take long, so
+// they are done on a separate goroutine not to block the chain event feed.
+func (s *Service) loop() {
+	defer s.wg.Done()
+
+	headCh := make(chan core.ChainHeadEvent, chainHeadChanSize)
+	sub := s.chain.SubscribeChainHeadEvent(headCh)
+	defer sub.Unsubscribe()
+
+	for {
+		select {
+		case <-headCh:
+			select {
+			case s.update <- struct{}{}:
+			default:
+			}
+		case <-sub.Err():
+			return
+		case <-s.quit:
+			return
+		}
+	}
+}
+
+// publisher uploads every range completely finalized, and registers the root
+// manifest afterwards.
+func (s *Service) publisher() {
+	defer s.wg.Done()
+
+	for {
+		select {
+		case <-s.update:
+			for s.finalized(s.next() + s.config.Range - 1) {
+				if s.stopped() {
+					return
+				}
+				if err := s.publish(s.next()); err != nil {
+					log.Warn("Failed to publish chain range to swarm", "from", s.next(), "err", err)
+					break
+				}
+			}
+			s.register()
+
+		case <-s.quit:
+			return
+		}
+	}
+}
+
+// stopped checks whether the service is being terminated.
+func (s *Service) stopped() bool {
+	select {
+	case <-s.quit:
+		return true
+	default:
+		return false
+	}
+}
+
+// next returns the first block of the next range to publish.
+func (s *Service) next() uint64 {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	if n := len(s.progress.Ranges); n > 0 {
+		return s.progress.Ranges[n-1].To + 1
+	}
+	return 0
+}
+
+// finalized checks whether the given canonical block is final.
+func (s *Service) finalized(number uint64) bool {
+	header := s.chain.GetHeaderByNumber(number)
+	if header == nil {
+		return false
+	}
+	return s.chain.Engine().(consensus.FinalityVerifier).VerifyFinality(s.chain, header) == nil
+}
+
+// publish uploads the blocks and receipts of a range into swarm, and updates the
+// root manifest to include it.
+func (s *Service) publish(from uint64) error {
+	to := from + s.config.Range - 1
+
+	blocks, receipts := new(bytes.Buffer), make([][]*types.ReceiptForStorage, 0, s.config.Range)
+	for number := from; number <= to; number++ {
+		block := s.chain.GetBlockByNumber(number)
+		if block == nil {
+			return fmt.Errorf("block #%d missing", number)
+		}
+		if err := block.EncodeRLP(blocks); err != nil {
+			return err
+		}
+		stored := make([]*types.ReceiptForStorage, 0, len(block.Transactions()))
+		for _, receipt := range core.GetBlockReceipts(s.db, block.Hash(), number) {
+			stored = append(stored, (*types.ReceiptForStorage)(receipt))
+		}
+		receipts = append(receipts, stored)
+	}
+	blocksHash, err := s.client.UploadRaw(bytes.NewReader(blocks.Bytes()), int64(blocks.Len()))
+	if err != nil {
+		return err
+	}
+	blob, err := rlp.EncodeToBytes(receipts)
+	if err != nil {
+		return err
+	}
+	receiptsHash, err := s.client.UploadRaw(bytes.NewReader(blob), int64(len(blob)))
+	if err != nil {
+		return err
+	}
+	hash, err := s.client.UploadManifest(&api.Manifest{Entries: []api.ManifestEntry{
+		{Hash: blocksHash, Path: blocksPath, ContentType: "application/octet-stream", Size: int64(blocks.Len())},
+		{Hash: receiptsHash, Path: receiptsPath, ContentType: "application/octet-stream", Size: int64(len(blob))},
+	}})
+	if err != nil {
+		return err
+	}
+	// Range uploaded, update the root manifest to reference it
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	ranges := append(s.progress.Ranges, &Range{From: from, To: to, Hash: hash})
+	root := &api.Manifest{Entries: make([]api.ManifestEntry, len(ranges))}
+	for i, r := range ranges {
+		root.Entries[i] = api.ManifestEntry{Hash: r.Hash, Path: rangePath(r), ContentType: api.ManifestType}
+	}
+	rootHash, err := s.client.UploadManifest(root)
+	if err != nil {
+		return err
+	}
+	s.progress.Ranges, s.progress.Root = ranges, rootHash
+	s.store()
+
+	log.Info("Published chain range to swarm", "from", from, "to", to, "hash", hash, "root", rootHash)
+	return nil
+}
+
+// rangePath returns the path of a range within the root manifest.
+func rangePath(r *Range) string {
+	return fmt.Sprintf("%012d-%012d/", r.From, r.To)
+}
+
+// register updates the name service to point to the latest root manifest.
+func (s *Service) register() {
+	s.lock.RLock()
+	root, registered := s.progress.Root, s.progress.Registered
+	s.lock.RUnlock()
+
+	if s.config.Name == "" || root == "" || root == registered {
+		return
+	}
+	registrar, err := s.registrar()
+	if err != nil {
+		log.Warn("Failed to connect to name service", "err", err)
+		return
+	}
+	tx, err := registrar.SetContentHash(s.config.Name, common.HexToHash(root))
+	if err != nil {
+		log.Warn("Failed to register chain backup", "name", s.config.Name, "root", root, "err", err)
+		return
+	}
+	s.lock.Lock()
+	s.progress.Registered = root
+	s.store()
+	s.lock.Unlock()
+
+	log.Info("Registered chain backup", "name", s.config.Name, "root", root, "tx", tx.Hash())
+}
+
+// store persists the publishing progress. The lock must be held.
+func (s *Service) store() {
+	blob, err := json.Marshal(s.progress)
+	if err != nil {
+		log.Crit("Failed to encode backup progress", "err", err)
+	}
+	if err := s.db.Put(dbKeyProgress, blob); err != nil {
+		log.Crit("Failed to store backup progress", "err", err)
+	}
+}
+
+// PublicBackupAPI provides access to the chain ranges published into swarm.
+type PublicBackupAPI struct {
+	s *Service
+}
+
+// BackupInfo is the mapping of the published chain to swarm.
+type BackupInfo struct {
+	Name   string   `json:"name,omitempty"`
+	Root   string   `json:"root"`
+	Ranges []*Range `json:"ranges"`
+}
+
+// Info returns the swarm root manifest of the published chain and the ranges
+// included in it.
+func (api *PublicBackupAPI) Info() *BackupInfo {
+	api.s.lock.RLock()
+	defer api.s.lock.RUnlock()
+
+	return &BackupInfo{
+		Name:   api.s.config.Name,
+		Root:   api.s.progress.Root,
+		Ranges: append([]*Range(nil), api.s.progress.Ranges...),
+	}
+}
+
+// Range returns the swarm manifest of the range containing the given block.
+func (api *PublicBackupAPI) Range(number uint64) (*Range, error) {
+	api.s.lock.RLock()
+	defer api.s.lock.RUnlock()
+
+	for _, r := range api.s.progress.Ranges {
+		if r.From <= number && number <= r.To {
+			return r, nil
+		}
+	}
+	return nil, fmt.Errorf("block #%d not published", number)
+}
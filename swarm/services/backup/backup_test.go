@@ -0,0 +1,132 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package backup
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/swarm/api/client"
+	"github.com/ethereum/go-ethereum/swarm/testutil"
+)
+
+// finalEngine is a consensus engine considering every block final.
+type finalEngine struct {
+	consensus.Engine
+}
+
+func (finalEngine) VerifyFinality(chain consensus.ChainReader, header *types.Header) error {
+	return nil
+}
+
+// testRegistrar records the content hashes registered with it.
+type testRegistrar struct {
+	hashes map[string]common.Hash
+}
+
+func (r *testRegistrar) SetContentHash(name string, hash common.Hash) (*types.Transaction, error) {
+	r.hashes[name] = hash
+	return new(types.Transaction), nil
+}
+
+// Tests that finalized ranges are published into swarm in an importable form,
+// and the root manifest is registered with the name service.
+func TestPublish(t *testing.T) {
+	srv := testutil.NewTestSwarmServer(t)
+	defer srv.Close()
+
+	// Create a chain with a couple of full ranges and a partial one
+	engine := finalEngine{ethash.NewFaker()}
+	db, _ := ethdb.NewMemDatabase()
+	genesis := new(core.Genesis).MustCommit(db)
+	chain, _ := core.NewBlockChain(db, nil, params.TestChainConfig, engine, vm.Config{})
+	defer chain.Stop()
+
+	blocks, _ := core.GenerateChain(params.TestChainConfig, genesis, engine, db, 10, nil)
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	registrar := &testRegistrar{hashes: make(map[string]common.Hash)}
+	s, err := New(&Config{Gateway: srv.URL, Range: 4, Name: "chain.eth"}, chain, db, func() (Registrar, error) {
+		return registrar, nil
+	})
+	if err != nil {
+		t.Fatalf("failed to create backup service: %v", err)
+	}
+	for s.finalized(s.next() + s.config.Range - 1) {
+		if err := s.publish(s.next()); err != nil {
+			t.Fatalf("failed to publish range from #%d: %v", s.next(), err)
+		}
+	}
+	s.register()
+
+	info := (&PublicBackupAPI{s}).Info()
+	if len(info.Ranges) != 2 || info.Ranges[1].From != 4 || info.Ranges[1].To != 7 {
+		t.Fatalf("published ranges mismatch: have %v", info.Ranges)
+	}
+	if have := registrar.hashes["chain.eth"]; have != common.HexToHash(info.Root) {
+		t.Errorf("registered hash mismatch: have %x, want %s", have, info.Root)
+	}
+	// Ensure the blocks can be retrieved through the root manifest
+	bzz := client.NewClient(srv.URL)
+	list, err := bzz.List(info.Root, rangePath(info.Ranges[1]))
+	if err != nil {
+		t.Fatalf("failed to list range: %v", err)
+	}
+	if len(list.Entries) != 2 {
+		t.Fatalf("range entries mismatch: have %d, want 2", len(list.Entries))
+	}
+	file, err := bzz.Download(info.Root, rangePath(info.Ranges[1])+blocksPath)
+	if err != nil {
+		t.Fatalf("failed to download blocks: %v", err)
+	}
+	defer file.Close()
+
+	blob, _ := ioutil.ReadAll(file)
+	stream := rlp.NewStream(bytes.NewReader(blob), 0)
+	for number := uint64(4); number <= 7; number++ {
+		block := new(types.Block)
+		if err := stream.Decode(block); err != nil {
+			t.Fatalf("failed to decode block #%d: %v", number, err)
+		}
+		if block.Hash() != chain.GetBlockByNumber(number).Hash() {
+			t.Errorf("block #%d mismatch", number)
+		}
+	}
+	if err := stream.Decode(new(types.Block)); err != io.EOF {
+		t.Errorf("trailing data after range: %v", err)
+	}
+	// Ensure progress is persisted across restarts
+	s, err = New(&Config{Gateway: srv.URL, Range: 4}, chain, db, nil)
+	if err != nil {
+		t.Fatalf("failed to recreate backup service: %v", err)
+	}
+	if next := s.next(); next != 8 {
+		t.Errorf("resumed range mismatch: have #%d, want #8", next)
+	}
+}
@@ -0,0 +1,199 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package snapshot
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethereum/go-ethereum/swarm/storage"
+)
+
+const (
+	// chainHeadChanSize is the size of channel listening to ChainHeadEvent.
+	chainHeadChanSize = 10
+
+	// maxSnapshots is the number of recently generated snapshots to advertise.
+	maxSnapshots = 16
+)
+
+// Info describes a generated snapshot.
+type Info struct {
+	Number   uint64      `json:"number"`
+	Hash     common.Hash `json:"hash"`
+	Root     common.Hash `json:"root"`
+	Entries  uint64      `json:"entries"`
+	Segments int         `json:"segments"`
+	Manifest storage.Key `json:"manifest"`
+}
+
+// Service periodically generates snapshots of the state of finalized blocks.
+type Service struct {
+	chain    *core.BlockChain
+	store    Store
+	interval uint64 // Number of blocks between two snapshots
+
+	snapshots []*Info // Recently generated snapshots, oldest first
+	lock      sync.RWMutex
+
+	generating int32 // Flag whether a snapshot is being generated
+	quit       chan struct{}
+	wg         sync.WaitGroup
+}
+
+// New creates a snapshot service generating a snapshot of every interval-th
+// block once it is finalized.
+func New(chain *core.BlockChain, store Store, interval uint64) *Service {
+	return &Service{
+		chain:    chain,
+		store:    store,
+		interval: interval,
+		quit:     make(chan struct{}),
+	}
+}
+
+// Protocols implements node.Service, returning the P2P network protocols used
+// by the snapshot service (nil as it doesn't use the devp2p overlay network).
+func (s *Service) Protocols() []p2p.Protocol { return nil }
+
+// APIs implements node.Service, returning the RPC API endpoints provided by the
+// snapshot service.
+func (s *Service) APIs() []rpc.API {
+	return []rpc.API{{
+		Namespace: "snapshot",
+		Version:   "1.0",
+		Service:   &PublicSnapshotAPI{s},
+		Public:    true,
+	}}
+}
+
+// Start implements node.Service, starting to track the chain head.
+func (s *Service) Start(server *p2p.Server) error {
+	if _, ok := s.chain.Engine().(consensus.FinalityVerifier); !ok {
+		log.Warn("Consensus engine without finality, state snapshots disabled")
+		return nil
+	}
+	s.wg.Add(1)
+	go s.loop()
+
+	log.Info("Started state snapshot service", "interval", s.interval)
+	return nil
+}
+
+// Stop implements node.Service, terminating the snapshot generation.
+func (s *Service) Stop() error {
+	close(s.quit)
+	s.wg.Wait()
+
+	log.Info("State snapshot service stopped")
+	return nil
+}
+
+// loop waits for the snapshot blocks to be imported and finalized, and generates
+// their snapshots in the background. If a generation is still running when the
+// next snapshot block arrives, the latter is skipped.
+func (s *Service) loop() {
+	defer s.wg.Done()
+
+	headCh := make(chan core.ChainHeadEvent, chainHeadChanSize)
+	sub := s.chain.SubscribeChainHeadEvent(headCh)
+	defer sub.Unsubscribe()
+
+	for {
+		select {
+		case ev := <-headCh:
+			header := ev.Block.Header()
+			if header.Number.Uint64()%s.interval != 0 {
+				continue
+			}
+			if err := s.chain.Engine().(consensus.FinalityVerifier).VerifyFinality(s.chain, header); err != nil {
+				log.Debug("Skipping snapshot of unfinalized block", "number", header.Number, "hash", header.Hash(), "err", err)
+				continue
+			}
+			if !atomic.CompareAndSwapInt32(&s.generating, 0, 1) {
+				log.Warn("Skipping snapshot, previous one still generating", "number", header.Number)
+				continue
+			}
+			s.wg.Add(1)
+			go func() {
+				defer s.wg.Done()
+				defer atomic.StoreInt32(&s.generating, 0)
+
+				s.generate(header)
+			}()
+
+		case <-sub.Err():
+			return
+		case <-s.quit:
+			return
+		}
+	}
+}
+
+// generate creates the snapshot of a block and advertises it if successful.
+func (s *Service) generate(header *types.Header) {
+	start := time.Now()
+
+	key, manifest, err := Generate(s.chain.StateCache(), header, s.store)
+	if err != nil {
+		log.Error("Failed to generate state snapshot", "number", header.Number, "hash", header.Hash(), "err", err)
+		return
+	}
+	info := &Info{
+		Number:   header.Number.Uint64(),
+		Hash:     header.Hash(),
+		Root:     header.Root,
+		Entries:  manifest.Entries,
+		Segments: len(manifest.Segments),
+		Manifest: key,
+	}
+	s.lock.Lock()
+	s.snapshots = append(s.snapshots, info)
+	if len(s.snapshots) > maxSnapshots {
+		s.snapshots = s.snapshots[len(s.snapshots)-maxSnapshots:]
+	}
+	s.lock.Unlock()
+
+	log.Info("Generated state snapshot", "number", info.Number, "hash", info.Hash, "entries", info.Entries,
+		"segments", info.Segments, "manifest", key, "elapsed", common.PrettyDuration(time.Since(start)))
+}
+
+// Snapshots returns the recently generated snapshots, oldest first.
+func (s *Service) Snapshots() []*Info {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return append([]*Info(nil), s.snapshots...)
+}
+
+// PublicSnapshotAPI provides access to the snapshots generated by the node.
+type PublicSnapshotAPI struct {
+	s *Service
+}
+
+// List returns the recently generated snapshots, oldest first.
+func (api *PublicSnapshotAPI) List() []*Info {
+	return api.s.Snapshots()
+}
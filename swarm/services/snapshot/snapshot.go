@@ -0,0 +1,264 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package snapshot serializes the state of finalized blocks into hash addressed
+// segments stored in swarm, and restores the state from them.
+//
+// A snapshot consists of segments, each an RLP list of raw state entries (trie
+// nodes and contract code), and a manifest listing the segment keys along with
+// the header the state belongs to. The swarm key of the manifest identifies the
+// whole snapshot: every layer of it is content addressed, so a node trusting the
+// manifest key can restore the state from untrusted peers.
+package snapshot
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/swarm/api/client"
+	"github.com/ethereum/go-ethereum/swarm/storage"
+)
+
+// segmentSize is the approximate number of bytes of state entries bundled into
+// a single segment.
+const segmentSize = 4 * 1024 * 1024
+
+var (
+	// errEmptyState is returned when generating a snapshot of a state without
+	// any entries.
+	errEmptyState = errors.New("empty state")
+)
+
+// Store is a content addressed storage for snapshot segments and manifests.
+type Store interface {
+	// Put stores a blob, returning the key it is addressable by.
+	Put(data []byte) (storage.Key, error)
+
+	// Get retrieves the blob addressed by key.
+	Get(key storage.Key) ([]byte, error)
+}
+
+// dpaStore is a Store backed by an in-process distributed preimage archive.
+type dpaStore struct {
+	dpa *storage.DPA
+}
+
+// NewDPAStore creates a snapshot store on top of a running DPA.
+func NewDPAStore(dpa *storage.DPA) Store {
+	return &dpaStore{dpa: dpa}
+}
+
+// Put implements Store, splitting the blob into chunks and waiting until all of
+// them are stored.
+func (s *dpaStore) Put(data []byte) (storage.Key, error) {
+	swg, wwg := new(sync.WaitGroup), new(sync.WaitGroup)
+	key, err := s.dpa.Store(bytes.NewReader(data), int64(len(data)), swg, wwg)
+	if err != nil {
+		return nil, err
+	}
+	swg.Wait()
+	wwg.Wait()
+	return key, nil
+}
+
+// Get implements Store, joining the chunks of the blob.
+func (s *dpaStore) Get(key storage.Key) ([]byte, error) {
+	reader := s.dpa.Retrieve(key)
+	size, err := reader.Size(nil)
+	if err != nil {
+		return nil, err
+	}
+	data := make([]byte, size)
+	if n, err := reader.ReadAt(data, 0); int64(n) != size {
+		return nil, err
+	}
+	return data, nil
+}
+
+// bzzStore is a Store backed by the HTTP gateway of a swarm node.
+type bzzStore struct {
+	client *client.Client
+}
+
+// NewBzzStore creates a snapshot store uploading to and downloading from the
+// swarm node serving the given HTTP gateway.
+func NewBzzStore(gateway string) Store {
+	return &bzzStore{client: client.NewClient(gateway)}
+}
+
+// Put implements Store, uploading the blob as raw swarm content.
+func (s *bzzStore) Put(data []byte) (storage.Key, error) {
+	hash, err := s.client.UploadRaw(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+	return common.FromHex(hash), nil
+}
+
+// Get implements Store, downloading the blob as raw swarm content.
+func (s *bzzStore) Get(key storage.Key) ([]byte, error) {
+	body, err := s.client.DownloadRaw(key.String())
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	return ioutil.ReadAll(body)
+}
+
+// Manifest describes a snapshot of the state of a finalized block.
+type Manifest struct {
+	Header   *types.Header // Header of the block the state belongs to
+	Entries  uint64        // Total number of state entries in the snapshot
+	Segments []storage.Key // Keys of the segments holding the state entries
+}
+
+// Generate iterates the entire state of a block, stores it in segments and then
+// the manifest describing them, returning the key of the latter.
+func Generate(db state.Database, header *types.Header, store Store) (storage.Key, *Manifest, error) {
+	statedb, err := state.New(header.Root, db)
+	if err != nil {
+		return nil, nil, err
+	}
+	manifest := &Manifest{Header: header}
+
+	var (
+		segment [][]byte
+		size    int
+	)
+	flush := func() error {
+		blob, err := rlp.EncodeToBytes(segment)
+		if err != nil {
+			return err
+		}
+		key, err := store.Put(blob)
+		if err != nil {
+			return err
+		}
+		manifest.Segments = append(manifest.Segments, key)
+		segment, size = nil, 0
+		return nil
+	}
+	it := state.NewNodeIterator(statedb)
+	for it.Next() {
+		// Embedded nodes are stored as part of their parents
+		if it.Hash == (common.Hash{}) {
+			continue
+		}
+		entry, err := db.TrieDB().Node(it.Hash)
+		if err != nil {
+			return nil, nil, err
+		}
+		segment = append(segment, entry)
+		size += len(entry)
+		manifest.Entries++
+
+		if size >= segmentSize {
+			if err := flush(); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+	if it.Error != nil {
+		return nil, nil, it.Error
+	}
+	if len(segment) > 0 {
+		if err := flush(); err != nil {
+			return nil, nil, err
+		}
+	}
+	if manifest.Entries == 0 {
+		return nil, nil, errEmptyState
+	}
+	blob, err := rlp.EncodeToBytes(manifest)
+	if err != nil {
+		return nil, nil, err
+	}
+	key, err := store.Put(blob)
+	if err != nil {
+		return nil, nil, err
+	}
+	return key, manifest, nil
+}
+
+// Fetch retrieves the manifest of a snapshot.
+func Fetch(store Store, key storage.Key) (*Manifest, error) {
+	blob, err := store.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	manifest := new(Manifest)
+	if err := rlp.DecodeBytes(blob, manifest); err != nil {
+		return nil, fmt.Errorf("invalid manifest: %v", err)
+	}
+	if manifest.Header == nil {
+		return nil, errors.New("invalid manifest: missing header")
+	}
+	return manifest, nil
+}
+
+// Restore downloads all the segments of a snapshot into the database, and then
+// verifies that the complete state of the snapshot block is available. Entries
+// are stored under their own hash, so segments can't inject foreign data, only
+// withhold some; the final iteration over the state detects the latter.
+func Restore(store Store, manifest *Manifest, db ethdb.Database) error {
+	var entries uint64
+	for i, key := range manifest.Segments {
+		blob, err := store.Get(key)
+		if err != nil {
+			return fmt.Errorf("segment %d (%s): %v", i, key, err)
+		}
+		var segment [][]byte
+		if err := rlp.DecodeBytes(blob, &segment); err != nil {
+			return fmt.Errorf("segment %d (%s): %v", i, key, err)
+		}
+		batch := db.NewBatch()
+		for _, entry := range segment {
+			if err := batch.Put(crypto.Keccak256(entry), entry); err != nil {
+				return err
+			}
+		}
+		if err := batch.Write(); err != nil {
+			return err
+		}
+		entries += uint64(len(segment))
+		log.Info("Restored snapshot segment", "index", i, "segments", len(manifest.Segments), "entries", entries)
+	}
+	return Verify(db, manifest.Header.Root)
+}
+
+// Verify checks that the state with the given root is completely available in
+// the database.
+func Verify(db ethdb.Database, root common.Hash) error {
+	statedb, err := state.New(root, state.NewDatabase(db))
+	if err != nil {
+		return err
+	}
+	it := state.NewNodeIterator(statedb)
+	for it.Next() {
+	}
+	return it.Error
+}
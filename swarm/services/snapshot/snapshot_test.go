@@ -0,0 +1,113 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package snapshot
+
+import (
+	"io/ioutil"
+	"math/big"
+	"os"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/swarm/storage"
+)
+
+// makeTestState creates a state with a number of accounts, some of them having
+// contract code and storage.
+func makeTestState(t *testing.T) (state.Database, common.Hash) {
+	db, _ := ethdb.NewMemDatabase()
+	sdb := state.NewDatabase(db)
+	statedb, _ := state.New(common.Hash{}, sdb)
+
+	for i := byte(0); i < 64; i++ {
+		addr := common.BytesToAddress([]byte{i})
+		statedb.AddBalance(addr, big.NewInt(int64(i)+1))
+		if i%4 == 0 {
+			statedb.SetCode(addr, []byte{i, i, i})
+			statedb.SetState(addr, common.Hash{i}, common.Hash{i, i})
+		}
+	}
+	root, err := statedb.Commit(false)
+	if err != nil {
+		t.Fatalf("failed to commit state: %v", err)
+	}
+	return sdb, root
+}
+
+// Tests that a generated snapshot restores the complete state into an empty
+// database.
+func TestGenerateRestore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "snapshot-test")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	dpa, err := storage.NewLocalDPA(dir)
+	if err != nil {
+		t.Fatalf("failed to create DPA: %v", err)
+	}
+	dpa.Start()
+	defer dpa.Stop()
+	store := NewDPAStore(dpa)
+
+	sdb, root := makeTestState(t)
+	header := &types.Header{Number: big.NewInt(100), Root: root}
+
+	key, manifest, err := Generate(sdb, header, store)
+	if err != nil {
+		t.Fatalf("failed to generate snapshot: %v", err)
+	}
+	fetched, err := Fetch(store, key)
+	if err != nil {
+		t.Fatalf("failed to fetch manifest: %v", err)
+	}
+	if fetched.Header.Hash() != header.Hash() || fetched.Entries != manifest.Entries {
+		t.Fatalf("manifest mismatch: have %d entries of %x, want %d of %x", fetched.Entries, fetched.Header.Hash(), manifest.Entries, header.Hash())
+	}
+	db, _ := ethdb.NewMemDatabase()
+	if err := Restore(store, fetched, db); err != nil {
+		t.Fatalf("failed to restore snapshot: %v", err)
+	}
+	statedb, err := state.New(root, state.NewDatabase(db))
+	if err != nil {
+		t.Fatalf("failed to open restored state: %v", err)
+	}
+	for i := byte(0); i < 64; i++ {
+		addr := common.BytesToAddress([]byte{i})
+		if balance := statedb.GetBalance(addr); balance.Int64() != int64(i)+1 {
+			t.Errorf("account %d: balance mismatch: have %v, want %d", i, balance, i+1)
+		}
+		if i%4 == 0 {
+			if code := statedb.GetCode(addr); len(code) != 3 || code[0] != i {
+				t.Errorf("account %d: code mismatch: have %x", i, code)
+			}
+			if value := statedb.GetState(addr, common.Hash{i}); value != (common.Hash{i, i}) {
+				t.Errorf("account %d: storage mismatch: have %x", i, value)
+			}
+		}
+	}
+	// Withholding the segments must be detected
+	fetched.Segments = nil
+	db, _ = ethdb.NewMemDatabase()
+	if err := Restore(store, fetched, db); err == nil {
+		t.Errorf("incomplete snapshot restored")
+	}
+}
@@ -329,6 +329,7 @@ type LazyChunkReader struct {
 	chunkSize int64       // inherit from chunker
 	branches  int64       // inherit from chunker
 	hashSize  int64       // inherit from chunker
+	trace     *Trace      // records the retrieved chunks (nil = not traced)
 }
 
 // implements the Joiner interface
@@ -347,7 +348,7 @@ func (self *LazyChunkReader) Size(quitC chan bool) (n int64, err error) {
 	if self.chunk != nil {
 		return self.chunk.Size, nil
 	}
-	chunk := retrieve(self.key, self.chunkC, quitC)
+	chunk := retrieve(self.key, self.chunkC, quitC, self.trace)
 	if chunk == nil {
 		select {
 		case <-quitC:
@@ -450,7 +451,7 @@ func (self *LazyChunkReader) join(b []byte, off int64, eoff int64, depth int, tr
 		wg.Add(1)
 		go func(j int64) {
 			childKey := chunk.SData[8+j*self.hashSize : 8+(j+1)*self.hashSize]
-			chunk := retrieve(childKey, self.chunkC, quitC)
+			chunk := retrieve(childKey, self.chunkC, quitC, self.trace)
 			if chunk == nil {
 				select {
 				case errC <- fmt.Errorf("chunk %v-%v not found", off, off+treeSize):
@@ -469,11 +470,16 @@ func (self *LazyChunkReader) join(b []byte, off int64, eoff int64, depth int, tr
 // the helper method submits chunks for a key to a oueue (DPA) and
 // block until they time out or arrive
 // abort if quitC is readable
-func retrieve(key Key, chunkC chan *Chunk, quitC chan bool) *Chunk {
+// the retrieval is recorded if traced
+func retrieve(key Key, chunkC chan *Chunk, quitC chan bool, trace *Trace) *Chunk {
 	chunk := &Chunk{
 		Key: key,
 		C:   make(chan bool), // close channel to signal data delivery
 	}
+	if trace != nil {
+		chunk.trace = &ChunkTrace{Key: key}
+	}
+	start := time.Now()
 	// submit chunk for retrieval
 	select {
 	case chunkC <- chunk: // submit retrieval request, someone should be listening on the other side (or we will time out globally)
@@ -485,9 +491,18 @@ func retrieve(key Key, chunkC chan *Chunk, quitC chan bool) *Chunk {
 
 	case <-quitC:
 		// this is how we control process leakage (quitC is closed once join is finished (after timeout))
+		if trace != nil {
+			// the chunk is still being retrieved, don't touch its record
+			trace.add(&ChunkTrace{Key: key, Latency: time.Since(start), Error: "aborted"})
+		}
 		return nil
 	case <-chunk.C: // bells are ringing, data have been delivered
 	}
+	if trace != nil {
+		chunk.trace.Latency = time.Since(start)
+		chunk.trace.Size = len(chunk.SData)
+		trace.add(chunk.trace)
+	}
 	if len(chunk.SData) == 0 {
 		return nil // chunk.Size = int64(binary.LittleEndian.Uint64(chunk.SData[0:8]))
 
@@ -71,7 +71,8 @@ type gcItem struct {
 }
 
 type DbStore struct {
-	db *LDBDatabase
+	db   *LDBDatabase
+	path string
 
 	// this should be stored in db, accessed transactionally
 	entryCnt, accessCnt, dataIdx, capacity uint64
@@ -81,6 +82,8 @@ type DbStore struct {
 
 	hashfunc SwarmHasher
 
+	shared *sharedCounter // storage counter of the shards of a sharded store
+
 	lock sync.Mutex
 }
 
@@ -88,6 +91,7 @@ func NewDbStore(path string, hash SwarmHasher, capacity uint64, radius int) (s *
 	s = new(DbStore)
 
 	s.hashfunc = hash
+	s.path = path
 
 	s.db, err = NewLDBDatabase(path)
 	if err != nil {
@@ -427,6 +431,9 @@ func (s *DbStore) Put(chunk *Chunk) {
 
 	batch := new(leveldb.Batch)
 
+	if s.shared != nil {
+		s.dataIdx = s.shared.next()
+	}
 	batch.Put(getDataKey(s.dataIdx), data)
 
 	index.Idx = s.dataIdx
@@ -540,6 +547,12 @@ func (s *DbStore) Close() {
 	s.db.Close()
 }
 
+// Backup hands the live database of the store and its path over to the given
+// function, which is expected to copy a snapshot of it.
+func (s *DbStore) Backup(copy func(db *leveldb.DB, path string) error) error {
+	return copy(s.db.db, s.path)
+}
+
 //  describes a section of the DbStore representing the unsynced
 // domain relevant to a peer
 // Start - Stop designate a continuous area Keys in an address space
@@ -552,6 +565,20 @@ type DbSyncState struct {
 	First, Last uint64
 }
 
+// SyncIterator walks the keys of the chunks to sync to a peer, returning nil
+// once done.
+type SyncIterator interface {
+	Next() Key
+}
+
+// SyncDbStore is a persistent chunk store peers are synced from, by storage
+// counter.
+type SyncDbStore interface {
+	ChunkStore
+	Counter() uint64
+	NewSyncIterator(state DbSyncState) (SyncIterator, error)
+}
+
 // implements the syncer iterator interface
 // iterates by storage index (~ time of storage = first entry to db)
 type dbSyncIterator struct {
@@ -560,11 +587,11 @@ type dbSyncIterator struct {
 }
 
 // initialises a sync iterator from a syncToken (passed in with the handshake)
-func (self *DbStore) NewSyncIterator(state DbSyncState) (si *dbSyncIterator, err error) {
+func (self *DbStore) NewSyncIterator(state DbSyncState) (SyncIterator, error) {
 	if state.First > state.Last {
 		return nil, fmt.Errorf("no entries found")
 	}
-	si = &dbSyncIterator{
+	si := &dbSyncIterator{
 		it:          self.db.NewIterator(),
 		DbSyncState: state,
 	}
@@ -23,6 +23,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/ethereum/go-ethereum/event"
 	"github.com/ethereum/go-ethereum/log"
 )
 
@@ -60,6 +61,13 @@ type DPA struct {
 	lock    sync.Mutex
 	running bool
 	quitC   chan bool
+
+	storeFeed event.Feed
+	root      *DPA // DPA a view was created from, sending the store events (nil = not a view)
+
+	Limiter *Limiter      // per client limits applied to the views returned by ForClient (nil = unlimited)
+	client  *clientLimits // limits of the client of a client view (nil = not limited)
+	trace   *Trace        // records the retrieved chunks of a traced view (nil = not traced)
 }
 
 // for testing locally
@@ -91,13 +99,89 @@ func NewDPA(store ChunkStore, params *ChunkerParams) *DPA {
 // Chunk retrieval blocks on netStore requests with a timeout so reader will
 // report error if retrieval of chunks within requested range time out.
 func (self *DPA) Retrieve(key Key) LazySectionReader {
-	return self.Chunker.Join(key, self.retrieveC)
+	reader := self.Chunker.Join(key, self.retrieveC)
+	if lazy, ok := reader.(*LazyChunkReader); ok {
+		lazy.trace = self.trace
+	}
+	if self.client != nil {
+		reader = &limitedSectionReader{reader, self.Limiter, self.client, self.quitC}
+	}
+	return reader
+}
+
+// Traced returns a view of the DPA recording the chunks retrieved through it in
+// the given trace. The view shares the workers of the DPA, it must not be
+// started or stopped.
+func (self *DPA) Traced(trace *Trace) *DPA {
+	view := self.view()
+	view.trace = trace
+	return view
+}
+
+// ForClient returns a view of the DPA storing and retrieving on behalf of the
+// given client, subject to the per client limits of the DPA's Limiter. Without
+// a limiter the DPA itself is returned. The view shares the workers of the DPA,
+// it must not be started or stopped.
+func (self *DPA) ForClient(id string) *DPA {
+	if self.Limiter == nil {
+		return self
+	}
+	view := self.view()
+	view.client = self.Limiter.client(id)
+	return view
+}
+
+// view returns a copy of the DPA sharing its workers.
+func (self *DPA) view() *DPA {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	root := self
+	if self.root != nil {
+		root = self.root
+	}
+	return &DPA{
+		ChunkStore: self.ChunkStore,
+		storeC:     self.storeC,
+		retrieveC:  self.retrieveC,
+		Chunker:    self.Chunker,
+		running:    self.running,
+		quitC:      self.quitC,
+		Limiter:    self.Limiter,
+		client:     self.client,
+		trace:      self.trace,
+		root:       root,
+	}
 }
 
 // Public API. Main entry point for document storage directly. Used by the
 // FS-aware API and httpaccess
 func (self *DPA) Store(data io.Reader, size int64, swg *sync.WaitGroup, wwg *sync.WaitGroup) (key Key, err error) {
-	return self.Chunker.Split(data, size, self.storeC, swg, wwg)
+	if self.client != nil {
+		if !self.Limiter.acquire(self.client, self.quitC) {
+			return nil, errLimiterStopped
+		}
+		defer self.Limiter.release(self.client)
+		data = &limitedReader{data, self.Limiter, self.client}
+	}
+	key, err = self.Chunker.Split(data, size, self.storeC, swg, wwg)
+	if err == nil {
+		self.events().Send(StoreEvent{Key: key, Size: size})
+	}
+	return key, err
+}
+
+// SubscribeStoreEvents registers a subscription of StoreEvent.
+func (self *DPA) SubscribeStoreEvents(ch chan<- StoreEvent) event.Subscription {
+	return self.events().Subscribe(ch)
+}
+
+// events returns the feed of store events, shared by the views of a DPA.
+func (self *DPA) events() *event.Feed {
+	if self.root != nil {
+		return &self.root.storeFeed
+	}
+	return &self.storeFeed
 }
 
 func (self *DPA) Start() {
@@ -136,7 +220,10 @@ func (self *DPA) retrieveLoop() {
 func (self *DPA) retrieveWorker() {
 	for chunk := range self.retrieveC {
 		log.Trace(fmt.Sprintf("dpa: retrieve loop : chunk %v", chunk.Key.Log()))
-		storedChunk, err := self.Get(chunk.Key)
+		storedChunk, err := self.get(chunk)
+		if err != nil && chunk.trace != nil {
+			chunk.trace.Error = err.Error()
+		}
 		if err == notFound {
 			log.Trace(fmt.Sprintf("chunk %v not found", chunk.Key.Log()))
 		} else if err != nil {
@@ -155,6 +242,20 @@ func (self *DPA) retrieveWorker() {
 	}
 }
 
+// get retrieves a requested chunk from the chunk store, recording whether it was
+// found locally or delivered by a peer for traced requests.
+func (self *DPA) get(chunk *Chunk) (*Chunk, error) {
+	if chunk.trace == nil {
+		return self.Get(chunk.Key)
+	}
+	if store, ok := self.ChunkStore.(*dpaChunkStore); ok {
+		return store.get(chunk.Key, chunk.trace)
+	}
+	storedChunk, err := self.Get(chunk.Key)
+	chunk.trace.Local = err == nil
+	return storedChunk, err
+}
+
 // storeLoop dispatches the parallel chunk store request processors
 // received on the store channel to its ChunkStore (NetStore or LocalStore)
 func (self *DPA) storeLoop() {
@@ -199,10 +300,18 @@ func NewDpaChunkStore(localStore, netStore ChunkStore) *dpaChunkStore {
 // Get is the entrypoint for local retrieve requests
 // waits for response or times out
 func (self *dpaChunkStore) Get(key Key) (chunk *Chunk, err error) {
+	return self.get(key, nil)
+}
+
+// get retrieves a chunk, recording where it came from if traced
+func (self *dpaChunkStore) get(key Key, trace *ChunkTrace) (chunk *Chunk, err error) {
 	chunk, err = self.netStore.Get(key)
 	// timeout := time.Now().Add(searchTimeout)
 	if chunk.SData != nil {
 		log.Trace(fmt.Sprintf("DPA.Get: %v found locally, %d bytes", key.Log(), len(chunk.SData)))
+		if trace != nil {
+			trace.Local = true
+		}
 		return
 	}
 	// TODO: use self.timer time.Timer and reset with defer disableTimer
@@ -213,6 +322,9 @@ func (self *dpaChunkStore) Get(key Key) (chunk *Chunk, err error) {
 		err = notFound
 	case <-chunk.Req.C:
 		log.Trace(fmt.Sprintf("DPA.Get: %v retrieved, %d bytes (%p)", key.Log(), len(chunk.SData), chunk))
+		if trace != nil {
+			trace.Peer = peerName(chunk.Source)
+		}
 	}
 	return
 }
@@ -237,5 +349,11 @@ func (self *dpaChunkStore) Put(entry *Chunk) {
 	self.netStore.Put(chunk)
 }
 
+// Local returns the chunk store holding the locally available chunks, allowing
+// access without falling back to the network.
+func (self *dpaChunkStore) Local() ChunkStore {
+	return self.localStore
+}
+
 // Close chunk store
 func (self *dpaChunkStore) Close() {}
@@ -141,3 +141,31 @@ func TestDPA_capacity(t *testing.T) {
 		t.Errorf("Comparison error after clearing memStore.")
 	}
 }
+
+// Tests that storing a document posts an event with its root key.
+func TestDPAStoreEvents(t *testing.T) {
+	dbStore := initDbStore(t)
+	defer os.RemoveAll("/tmp/bzz")
+
+	dpa := NewDPA(&LocalStore{NewMemStore(dbStore, defaultCacheCapacity), dbStore}, NewChunkerParams())
+	dpa.Start()
+	defer dpa.Stop()
+
+	events := make(chan StoreEvent, 1)
+	sub := dpa.SubscribeStoreEvents(events)
+	defer sub.Unsubscribe()
+
+	reader, _ := testDataReaderAndSlice(0x10000)
+	key, err := dpa.Store(reader, 0x10000, &sync.WaitGroup{}, nil)
+	if err != nil {
+		t.Fatalf("Store error: %v", err)
+	}
+	select {
+	case ev := <-events:
+		if !bytes.Equal(ev.Key, key) || ev.Size != 0x10000 {
+			t.Errorf("event mismatch: have %v/%d, want %v/%d", ev.Key, ev.Size, key, 0x10000)
+		}
+	default:
+		t.Fatalf("no store event posted")
+	}
+}
@@ -0,0 +1,42 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package storage
+
+// ChunkOp is the kind of chunk activity reported by a ChunkEvent.
+type ChunkOp string
+
+const (
+	ChunkStored    ChunkOp = "store"    // Chunk stored locally and propagated to the network
+	ChunkDelivered ChunkOp = "deliver"  // Chunk arrived from the network for a pending request
+	ChunkRetrieved ChunkOp = "retrieve" // Chunk served from the local store
+	ChunkRequested ChunkOp = "request"  // Chunk missing locally, requested from the network
+)
+
+// ChunkEvent is posted by the NetStore for every chunk stored or retrieved.
+type ChunkEvent struct {
+	Op   ChunkOp `json:"op"`
+	Key  Key     `json:"key"`
+	Size int64   `json:"size,omitempty"`
+}
+
+// StoreEvent is posted by the DPA when a document has been split into chunks,
+// all of them handed over to the chunk store. If the caller waits for storage,
+// the chunks are also stored by the time the event is posted.
+type StoreEvent struct {
+	Key  Key   `json:"key"`  // Root key of the document
+	Size int64 `json:"size"` // Size of the document in bytes
+}
@@ -0,0 +1,307 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package storage
+
+import (
+	"errors"
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	limiterSection     = 64 * 4096        // largest read or write of a client served at once
+	limiterIdleTimeout = 10 * time.Minute // time after which the usage of an idle client is dropped
+)
+
+var errLimiterStopped = errors.New("dpa stopped")
+
+// LimiterParams bounds the resources a single client of the DPA may consume,
+// so a heavy uploader or downloader cannot starve the other users of the node.
+// Zero values disable the respective limit.
+type LimiterParams struct {
+	StoreRate    uint64 // Bytes per second a single client may store
+	RetrieveRate uint64 // Bytes per second a single client may retrieve
+	MaxRequests  int    // Maximum number of concurrently served requests of a single client
+}
+
+// NewDefaultLimiterParams returns parameters leaving the clients unlimited.
+func NewDefaultLimiterParams() *LimiterParams {
+	return &LimiterParams{}
+}
+
+// ClientStats is the usage of the DPA by a single client.
+type ClientStats struct {
+	Client    string        `json:"client"`
+	Stored    uint64        `json:"stored"`    // bytes stored
+	Retrieved uint64        `json:"retrieved"` // bytes retrieved
+	Active    int           `json:"active"`    // requests being served
+	Queued    int           `json:"queued"`    // requests waiting for one of the client's request slots
+	Throttled time.Duration `json:"throttled"` // time spent waiting for the rate limits, in nanoseconds
+	LastSeen  time.Time     `json:"lastSeen"`
+}
+
+// Limiter enforces the per client limits of the DPA and keeps track of the
+// usage of each client, see DPA.ForClient.
+//
+// Every client has its own request slots and token buckets, so the requests of
+// a client exceeding its limits queue up behind each other instead of in front
+// of the ones of other clients. Large requests are served in sections, which
+// interleaves the chunks of concurrent clients in the shared DPA workers.
+type Limiter struct {
+	params  LimiterParams
+	clients map[string]*clientLimits
+	pruned  time.Time
+	lock    sync.Mutex
+}
+
+// clientLimits holds the limits and usage of a single client.
+type clientLimits struct {
+	store    *tokenBucket
+	retrieve *tokenBucket
+	slots    chan struct{} // request slots (nil = unlimited)
+	stats    ClientStats   // guarded by the limiter lock
+}
+
+// NewLimiter creates a limiter enforcing the given per client limits, nil
+// meaning unlimited clients whose usage is only tracked.
+func NewLimiter(params *LimiterParams) *Limiter {
+	if params == nil {
+		params = NewDefaultLimiterParams()
+	}
+	return &Limiter{
+		params:  *params,
+		clients: make(map[string]*clientLimits),
+		pruned:  time.Now(),
+	}
+}
+
+// Stats returns the usage of all clients seen recently, ordered by client.
+func (l *Limiter) Stats() []*ClientStats {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	stats := make([]*ClientStats, 0, len(l.clients))
+	for _, client := range l.clients {
+		s := client.stats
+		stats = append(stats, &s)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Client < stats[j].Client })
+	return stats
+}
+
+// client returns the limits of a client, creating them on first use. Clients
+// idle for longer than limiterIdleTimeout are dropped.
+func (l *Limiter) client(id string) *clientLimits {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	now := time.Now()
+	if now.Sub(l.pruned) > limiterIdleTimeout {
+		for cid, client := range l.clients {
+			if client.stats.Active == 0 && client.stats.Queued == 0 && now.Sub(client.stats.LastSeen) > limiterIdleTimeout {
+				delete(l.clients, cid)
+			}
+		}
+		l.pruned = now
+	}
+	client, ok := l.clients[id]
+	if !ok {
+		client = &clientLimits{
+			store:    newTokenBucket(l.params.StoreRate, now),
+			retrieve: newTokenBucket(l.params.RetrieveRate, now),
+			stats:    ClientStats{Client: id},
+		}
+		if l.params.MaxRequests > 0 {
+			client.slots = make(chan struct{}, l.params.MaxRequests)
+		}
+		l.clients[id] = client
+	}
+	client.stats.LastSeen = now
+	return client
+}
+
+// acquire waits for a free request slot of the client, or returns false if quit
+// is closed first.
+func (l *Limiter) acquire(client *clientLimits, quit chan bool) bool {
+	if client.slots != nil {
+		l.update(client, func(s *ClientStats) { s.Queued++ })
+		select {
+		case client.slots <- struct{}{}:
+			l.update(client, func(s *ClientStats) { s.Queued-- })
+		case <-quit:
+			l.update(client, func(s *ClientStats) { s.Queued-- })
+			return false
+		}
+	}
+	l.update(client, func(s *ClientStats) { s.Active++ })
+	return true
+}
+
+// release frees a request slot acquired by the client.
+func (l *Limiter) release(client *clientLimits) {
+	l.update(client, func(s *ClientStats) { s.Active-- })
+	if client.slots != nil {
+		<-client.slots
+	}
+}
+
+// wait takes n bytes worth of tokens from the bucket of the client, sleeping
+// until they are available.
+func (l *Limiter) wait(client *clientLimits, bucket *tokenBucket, n int) {
+	delay := bucket.take(n, time.Now())
+	if delay <= 0 {
+		return
+	}
+	l.update(client, func(s *ClientStats) { s.Throttled += delay })
+	time.Sleep(delay)
+}
+
+func (l *Limiter) update(client *clientLimits, f func(*ClientStats)) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	f(&client.stats)
+	client.stats.LastSeen = time.Now()
+}
+
+// section returns the largest number of bytes the bucket allows a client to
+// read or write at once.
+func (l *Limiter) section(bucket *tokenBucket) int {
+	if bucket != nil && int(bucket.burst) < limiterSection {
+		return int(bucket.burst)
+	}
+	return limiterSection
+}
+
+// tokenBucket allows a sustained rate of bytes per second with bursts of up to
+// one second worth of bytes, but at least a chunk.
+type tokenBucket struct {
+	rate  float64 // Number of tokens refilled per second
+	burst float64 // Maximum number of tokens the bucket can hold
+
+	tokens float64   // Number of tokens currently available, negative if reserved in advance
+	last   time.Time // Last time the token count was updated
+	lock   sync.Mutex
+}
+
+// newTokenBucket creates a full token bucket refilling at rate tokens per
+// second. A zero rate disables limiting.
+func newTokenBucket(rate uint64, now time.Time) *tokenBucket {
+	if rate == 0 {
+		return nil
+	}
+	burst := float64(rate)
+	if burst < 4096 {
+		burst = 4096
+	}
+	return &tokenBucket{
+		rate:   float64(rate),
+		burst:  burst,
+		tokens: burst,
+		last:   now,
+	}
+}
+
+// take consumes n tokens and returns how long the caller has to wait before the
+// tokens are actually available. A nil bucket never delays.
+func (b *tokenBucket) take(n int, now time.Time) time.Duration {
+	if b == nil {
+		return 0
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if now.After(b.last) {
+		b.tokens += now.Sub(b.last).Seconds() * b.rate
+		if b.tokens > b.burst {
+			b.tokens = b.burst
+		}
+		b.last = now
+	}
+	b.tokens -= float64(n)
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// limitedReader throttles the data stored by a client.
+type limitedReader struct {
+	io.Reader
+	limiter *Limiter
+	client  *clientLimits
+}
+
+func (r *limitedReader) Read(b []byte) (int, error) {
+	if section := r.limiter.section(r.client.store); len(b) > section {
+		b = b[:section]
+	}
+	n, err := r.Reader.Read(b)
+	if n > 0 {
+		r.limiter.update(r.client, func(s *ClientStats) { s.Stored += uint64(n) })
+		r.limiter.wait(r.client, r.client.store, n)
+	}
+	return n, err
+}
+
+// limitedSectionReader serves the reads of a client one section at a time,
+// each taking one of the client's request slots and throttled to its rate.
+type limitedSectionReader struct {
+	LazySectionReader
+	limiter *Limiter
+	client  *clientLimits
+	quitC   chan bool
+}
+
+func (r *limitedSectionReader) Read(b []byte) (int, error) {
+	if section := r.limiter.section(r.client.retrieve); len(b) > section {
+		b = b[:section]
+	}
+	if !r.limiter.acquire(r.client, r.quitC) {
+		return 0, errLimiterStopped
+	}
+	defer r.limiter.release(r.client)
+
+	r.limiter.wait(r.client, r.client.retrieve, len(b))
+	n, err := r.LazySectionReader.Read(b)
+	r.limiter.update(r.client, func(s *ClientStats) { s.Retrieved += uint64(n) })
+	return n, err
+}
+
+func (r *limitedSectionReader) ReadAt(b []byte, off int64) (int, error) {
+	section := r.limiter.section(r.client.retrieve)
+	for read := 0; ; {
+		size := len(b) - read
+		if size > section {
+			size = section
+		}
+		if !r.limiter.acquire(r.client, r.quitC) {
+			return read, errLimiterStopped
+		}
+		r.limiter.wait(r.client, r.client.retrieve, size)
+		n, err := r.LazySectionReader.ReadAt(b[read:read+size], off+int64(read))
+		r.limiter.update(r.client, func(s *ClientStats) { s.Retrieved += uint64(n) })
+		r.limiter.release(r.client)
+
+		read += n
+		if err != nil || n < size || read == len(b) {
+			return read, err
+		}
+	}
+}
@@ -0,0 +1,142 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package storage
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTokenBucket(t *testing.T) {
+	now := time.Now()
+	if newTokenBucket(0, now) != nil {
+		t.Fatal("zero rate bucket not disabled")
+	}
+	bucket := newTokenBucket(8192, now)
+
+	// A full bucket serves a burst without delay, the tokens taken beyond it are
+	// delayed according to the rate
+	if delay := bucket.take(8192, now); delay != 0 {
+		t.Fatalf("burst delayed by %v", delay)
+	}
+	if delay := bucket.take(4096, now); delay != 500*time.Millisecond {
+		t.Fatalf("delay mismatch: have %v, want %v", delay, 500*time.Millisecond)
+	}
+	// The debt is paid back over time
+	if delay := bucket.take(4096, now.Add(500*time.Millisecond)); delay != 500*time.Millisecond {
+		t.Fatalf("delay mismatch: have %v, want %v", delay, 500*time.Millisecond)
+	}
+	if delay := bucket.take(4096, now.Add(3*time.Second)); delay != 0 {
+		t.Fatalf("refilled bucket delayed by %v", delay)
+	}
+	// Small rates still allow a chunk at once
+	if bucket := newTokenBucket(100, now); bucket.take(4096, now) != 0 {
+		t.Fatal("chunk delayed by a small rate bucket")
+	}
+}
+
+func TestLimiterRequests(t *testing.T) {
+	limiter := NewLimiter(&LimiterParams{MaxRequests: 1})
+	heavy, light := limiter.client("heavy"), limiter.client("light")
+
+	if !limiter.acquire(heavy, nil) {
+		t.Fatal("failed to acquire free slot")
+	}
+	// The slots of other clients are not affected
+	if !limiter.acquire(light, nil) {
+		t.Fatal("failed to acquire slot of other client")
+	}
+	limiter.release(light)
+
+	// Further requests of the client wait for the slot to be released
+	acquired := make(chan bool)
+	go func() { acquired <- limiter.acquire(heavy, nil) }()
+	select {
+	case <-acquired:
+		t.Fatal("acquired slot of busy client")
+	case <-time.After(50 * time.Millisecond):
+	}
+	stats := limiter.Stats()
+	if len(stats) != 2 || stats[0].Client != "heavy" || stats[0].Active != 1 || stats[0].Queued != 1 {
+		t.Fatalf("stats mismatch: %+v", stats[0])
+	}
+	limiter.release(heavy)
+	if !<-acquired {
+		t.Fatal("failed to acquire released slot")
+	}
+	limiter.release(heavy)
+
+	// Waiting requests are abandoned on quit
+	quit := make(chan bool)
+	limiter.acquire(heavy, nil)
+	go func() { acquired <- limiter.acquire(heavy, quit) }()
+	close(quit)
+	if <-acquired {
+		t.Fatal("acquired slot after quit")
+	}
+}
+
+func TestDPAClientLimits(t *testing.T) {
+	dbStore := initDbStore(t)
+	defer os.RemoveAll("/tmp/bzz")
+	dpa := &DPA{
+		Chunker:    NewTreeChunker(NewChunkerParams()),
+		ChunkStore: &LocalStore{NewMemStore(dbStore, defaultCacheCapacity), dbStore},
+		Limiter:    NewLimiter(&LimiterParams{StoreRate: 64 * 1024, RetrieveRate: 64 * 1024, MaxRequests: 2}),
+	}
+	dpa.Start()
+	defer dpa.Stop()
+
+	// Store and retrieve exceeding the burst of the client
+	size := 96 * 1024
+	reader, slice := testDataReaderAndSlice(size)
+	client := dpa.ForClient("client")
+
+	wg := &sync.WaitGroup{}
+	key, err := client.Store(reader, int64(size), wg, nil)
+	if err != nil {
+		t.Fatalf("Store error: %v", err)
+	}
+	wg.Wait()
+	result := make([]byte, size)
+	if n, err := client.Retrieve(key).ReadAt(result, 0); err != io.EOF || n != size {
+		t.Fatalf("Retrieve error: %v (%d bytes)", err, n)
+	}
+	if !bytes.Equal(slice, result) {
+		t.Fatal("Comparison error")
+	}
+	// Retrieving without a client is not accounted
+	if _, err := dpa.Retrieve(key).ReadAt(result, 0); err != io.EOF {
+		t.Fatalf("Retrieve error: %v", err)
+	}
+
+	stats := dpa.Limiter.Stats()
+	if len(stats) != 1 {
+		t.Fatalf("expected 1 client, got %d", len(stats))
+	}
+	if s := stats[0]; s.Client != "client" || s.Stored != uint64(size) || s.Retrieved != uint64(size) || s.Active != 0 {
+		t.Fatalf("stats mismatch: %+v", s)
+	}
+	// Both the store and the retrieval went over the burst by half a second
+	if throttled := stats[0].Throttled; throttled < 500*time.Millisecond {
+		t.Fatalf("client throttled for %v only", throttled)
+	}
+}
@@ -20,6 +20,7 @@ import (
 	"encoding/binary"
 
 	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/syndtr/goleveldb/leveldb"
 )
 
 //metrics variables
@@ -36,6 +37,18 @@ type LocalStore struct {
 
 // This constructor uses MemStore and DbStore as components
 func NewLocalStore(hash SwarmHasher, params *StoreParams) (*LocalStore, error) {
+	if len(params.Shards) > 0 {
+		shards, err := NewShardedDbStore(hash, params.Shards, params.Radius)
+		if err != nil {
+			return nil, err
+		}
+		memStore := NewMemStore(nil, params.CacheCapacity)
+		memStore.dbStore = shards
+		return &LocalStore{
+			memStore: memStore,
+			DbStore:  shards,
+		}, nil
+	}
 	dbStore, err := NewDbStore(params.ChunkDbPath, hash, params.DbCapacity, params.Radius)
 	if err != nil {
 		return nil, err
@@ -51,7 +64,7 @@ func (self *LocalStore) CacheCounter() uint64 {
 }
 
 func (self *LocalStore) DbCounter() uint64 {
-	return self.DbStore.(*DbStore).Counter()
+	return self.DbStore.(SyncDbStore).Counter()
 }
 
 // LocalStore is itself a chunk store
@@ -91,3 +104,14 @@ func (self *LocalStore) Get(key Key) (chunk *Chunk, err error) {
 
 // Close local store
 func (self *LocalStore) Close() {}
+
+// Backup hands the persistent chunk databases over to the given function for
+// copying, see DbStore.Backup.
+func (self *LocalStore) Backup(copy func(db *leveldb.DB, path string) error) error {
+	if store, ok := self.DbStore.(interface {
+		Backup(func(*leveldb.DB, string) error) error
+	}); ok {
+		return store.Backup(copy)
+	}
+	return nil
+}
@@ -44,10 +44,15 @@ type MemStore struct {
 	entryCnt, capacity uint   // stored entries
 	accessCnt          uint64 // access counter; oldest is thrown away when full
 	dbAccessCnt        uint64
-	dbStore            *DbStore
+	dbStore            accessCounter
 	lock               sync.Mutex
 }
 
+// accessCounter is a persistent store counting accesses to its chunks.
+type accessCounter interface {
+	updateAccessCnt(key Key)
+}
+
 /*
 a hash prefix subtree containing subtrees or one storage entry (but never both)
 
@@ -64,7 +69,9 @@ a hash prefix subtree containing subtrees or one storage entry (but never both)
 func NewMemStore(d *DbStore, capacity uint) (m *MemStore) {
 	m = &MemStore{}
 	m.memtree = newMemTree(memTreeFLW, nil, 0)
-	m.dbStore = d
+	if d != nil {
+		m.dbStore = d
+	}
 	m.setCapacity(capacity)
 	return
 }
@@ -21,6 +21,7 @@ import (
 	"path/filepath"
 	"time"
 
+	"github.com/ethereum/go-ethereum/event"
 	"github.com/ethereum/go-ethereum/log"
 )
 
@@ -39,6 +40,7 @@ type NetStore struct {
 	hashfunc   SwarmHasher
 	localStore *LocalStore
 	cloud      CloudStore
+	feed       event.Feed
 }
 
 // backend engine for cloud store
@@ -55,6 +57,7 @@ type StoreParams struct {
 	DbCapacity    uint64
 	CacheCapacity uint
 	Radius        int
+	Shards        []*ShardParams // Shards of the chunk store, replacing ChunkDbPath and DbCapacity
 }
 
 //create params with default values
@@ -69,7 +72,9 @@ func NewDefaultStoreParams() (self *StoreParams) {
 //this can only finally be set after all config options (file, cmd line, env vars)
 //have been evaluated
 func (self *StoreParams) Init(path string) {
-	self.ChunkDbPath = filepath.Join(path, "chunks")
+	if self.ChunkDbPath == "" {
+		self.ChunkDbPath = filepath.Join(path, "chunks")
+	}
 }
 
 // netstore contructor, takes path argument that is used to initialise dbStore,
@@ -108,11 +113,13 @@ func (self *NetStore) Put(entry *Chunk) {
 		close(entry.Req.C)
 		// deliver the chunk to requesters upstream
 		go self.cloud.Deliver(entry)
+		self.feed.Send(ChunkEvent{Op: ChunkDelivered, Key: entry.Key, Size: entry.Size})
 	} else {
 		log.Trace(fmt.Sprintf("NetStore.Put: localStore.Put %v stored locally", entry.Key.Log()))
 		// handle propagating store requests
 		// go self.cloud.Store(entry)
 		go self.cloud.Store(entry)
+		self.feed.Send(ChunkEvent{Op: ChunkStored, Key: entry.Key, Size: entry.Size})
 	}
 }
 
@@ -127,6 +134,7 @@ func (self *NetStore) Get(key Key) (*Chunk, error) {
 			log.Trace(fmt.Sprintf("NetStore.Get: %v hit on an existing request", key))
 			// no need to launch again
 		}
+		self.feed.Send(ChunkEvent{Op: ChunkRetrieved, Key: key, Size: chunk.Size})
 		return chunk, err
 	}
 	// no data and no request status
@@ -134,8 +142,14 @@ func (self *NetStore) Get(key Key) (*Chunk, error) {
 	chunk = NewChunk(key, newRequestStatus(key))
 	self.localStore.memStore.Put(chunk)
 	go self.cloud.Retrieve(chunk)
+	self.feed.Send(ChunkEvent{Op: ChunkRequested, Key: key})
 	return chunk, nil
 }
 
+// SubscribeChunkEvents registers a subscription of ChunkEvent.
+func (self *NetStore) SubscribeChunkEvents(ch chan<- ChunkEvent) event.Subscription {
+	return self.feed.Subscribe(ch)
+}
+
 // Close netstore
 func (self *NetStore) Close() {}
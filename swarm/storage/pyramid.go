@@ -296,7 +296,7 @@ func (self *PyramidChunker) processChunk(id int64, hasher SwarmHash, job *chunkJ
 
 func (self *PyramidChunker) loadTree(chunkLevel [][]*TreeEntry, key Key, chunkC chan *Chunk, quitC chan bool) error {
 	// Get the root chunk to get the total size
-	chunk := retrieve(key, chunkC, quitC)
+	chunk := retrieve(key, chunkC, quitC, nil)
 	if chunk == nil {
 		return errLoadingTreeRootChunk
 	}
@@ -346,7 +346,7 @@ func (self *PyramidChunker) loadTree(chunkLevel [][]*TreeEntry, key Key, chunkC
 			branchCount = int64(len(ent.chunk)-8) / self.hashSize
 			for i := int64(0); i < branchCount; i++ {
 				key := ent.chunk[8+(i*self.hashSize) : 8+((i+1)*self.hashSize)]
-				newChunk := retrieve(key, chunkC, quitC)
+				newChunk := retrieve(key, chunkC, quitC, nil)
 				if newChunk == nil {
 					return errLoadingTreeChunk
 				}
@@ -410,7 +410,7 @@ func (self *PyramidChunker) prepareChunks(isAppend bool, chunkLevel [][]*TreeEnt
 			lastBranch := parent.branchCount - 1
 			lastKey := parent.chunk[8+lastBranch*self.hashSize : 8+(lastBranch+1)*self.hashSize]
 
-			unFinishedChunk = retrieve(lastKey, chunkC, quitC)
+			unFinishedChunk = retrieve(lastKey, chunkC, quitC, nil)
 			if unFinishedChunk.Size < self.chunkSize {
 
 				parent.subtreeSize = parent.subtreeSize - uint64(unFinishedChunk.Size)
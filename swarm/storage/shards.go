@@ -0,0 +1,251 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package storage
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/crypto/sha3"
+	"github.com/syndtr/goleveldb/leveldb"
+)
+
+// shardRingPoints is the number of points the shard of the largest capacity
+// takes on the hash ring, smaller shards take proportionally fewer.
+const shardRingPoints = 256
+
+var keyShardID = []byte{6}
+
+// ShardParams configures a shard of the persistent chunk store.
+type ShardParams struct {
+	Path     string // Directory of the shard database
+	Capacity uint64 // Number of chunks the shard holds before garbage collection
+}
+
+// sharedCounter hands out the storage indices of all the shards of a store,
+// keeping them unique and ordered across the shards.
+type sharedCounter struct {
+	idx  uint64
+	lock sync.Mutex
+}
+
+func (c *sharedCounter) next() uint64 {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	idx := c.idx
+	c.idx++
+	return idx
+}
+
+func (c *sharedCounter) counter() uint64 {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.idx
+}
+
+type shardPoint struct {
+	hash  []byte
+	shard int
+}
+
+// ShardedDbStore is a persistent chunk store split across several databases,
+// typically on different disks. Chunks are assigned to the shards by consistent
+// hashing of their keys, each shard taking a share of the key space
+// proportional to its capacity, so adding a shard only moves the chunks falling
+// into its share. Storage indices are shared by the shards, peers sync from the
+// store as from a single database.
+type ShardedDbStore struct {
+	shards []*DbStore
+	ring   []shardPoint // points of the shards on the hash ring, by hash
+	shared *sharedCounter
+}
+
+// NewShardedDbStore opens the databases of the given shards. Every shard is
+// identified by a random ID kept in its database, so shards may be moved or
+// listed in any order without reassigning their chunks.
+func NewShardedDbStore(hash SwarmHasher, params []*ShardParams, radius int) (*ShardedDbStore, error) {
+	if len(params) == 0 {
+		return nil, fmt.Errorf("no chunk store shards")
+	}
+	s := &ShardedDbStore{shared: new(sharedCounter)}
+
+	var maxCapacity uint64
+	for _, shard := range params {
+		if shard.Capacity > maxCapacity {
+			maxCapacity = shard.Capacity
+		}
+	}
+	for i, shard := range params {
+		if shard.Capacity == 0 {
+			s.Close()
+			return nil, fmt.Errorf("chunk store shard %s has no capacity", shard.Path)
+		}
+		db, err := NewDbStore(shard.Path, hash, shard.Capacity, radius)
+		if err != nil {
+			s.Close()
+			return nil, fmt.Errorf("failed to open chunk store shard %s: %v", shard.Path, err)
+		}
+		s.shards = append(s.shards, db)
+
+		id, err := db.db.Get(keyShardID)
+		if err != nil {
+			id = make([]byte, 32)
+			if _, err := rand.Read(id); err != nil {
+				s.Close()
+				return nil, err
+			}
+			db.db.Put(keyShardID, id)
+		}
+		points := shardRingPoints * shard.Capacity / maxCapacity
+		if points == 0 {
+			points = 1
+		}
+		for j := uint64(0); j < points; j++ {
+			var buf [8]byte
+			binary.BigEndian.PutUint64(buf[:], j)
+
+			hasher := sha3.NewKeccak256()
+			hasher.Write(id)
+			hasher.Write(buf[:])
+			s.ring = append(s.ring, shardPoint{hash: hasher.Sum(nil), shard: i})
+		}
+		// Continue after the latest index of any shard
+		if db.dataIdx >= s.shared.idx {
+			s.shared.idx = db.dataIdx + 1
+		}
+	}
+	sort.Slice(s.ring, func(i, j int) bool {
+		return bytes.Compare(s.ring[i].hash, s.ring[j].hash) < 0
+	})
+	for _, db := range s.shards {
+		db.shared = s.shared
+	}
+	return s, nil
+}
+
+// shard returns the index of the shard a chunk key belongs to, the one of the
+// first point on the ring following the key.
+func (s *ShardedDbStore) shard(key Key) int {
+	i := sort.Search(len(s.ring), func(i int) bool {
+		return bytes.Compare(s.ring[i].hash, key) >= 0
+	})
+	if i == len(s.ring) {
+		i = 0
+	}
+	return s.ring[i].shard
+}
+
+// Put stores a chunk in the shard its key belongs to.
+func (s *ShardedDbStore) Put(chunk *Chunk) {
+	s.shards[s.shard(chunk.Key)].Put(chunk)
+}
+
+// Get retrieves a chunk from the shard its key belongs to, falling back to the
+// other shards for chunks stored before the shards were changed.
+func (s *ShardedDbStore) Get(key Key) (*Chunk, error) {
+	owner := s.shard(key)
+	chunk, err := s.shards[owner].Get(key)
+	if err == nil {
+		return chunk, nil
+	}
+	for i, db := range s.shards {
+		if i == owner {
+			continue
+		}
+		if chunk, err := db.Get(key); err == nil {
+			return chunk, nil
+		}
+	}
+	return nil, err
+}
+
+func (s *ShardedDbStore) updateAccessCnt(key Key) {
+	s.shards[s.shard(key)].updateAccessCnt(key)
+}
+
+// Counter returns the storage counter of the store.
+func (s *ShardedDbStore) Counter() uint64 {
+	return s.shared.counter()
+}
+
+// Shards returns the databases of the shards.
+func (s *ShardedDbStore) Shards() []*DbStore {
+	return s.shards
+}
+
+// Backup hands the databases of all the shards over to the given function for
+// copying, see DbStore.Backup.
+func (s *ShardedDbStore) Backup(copy func(db *leveldb.DB, path string) error) error {
+	for _, shard := range s.shards {
+		if err := shard.Backup(copy); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// NewSyncIterator creates an iterator over the chunks of all the shards in the
+// given key and storage counter ranges, in order of their keys.
+func (s *ShardedDbStore) NewSyncIterator(state DbSyncState) (SyncIterator, error) {
+	it := &shardedSyncIterator{
+		its:   make([]SyncIterator, len(s.shards)),
+		heads: make([]Key, len(s.shards)),
+	}
+	for i, db := range s.shards {
+		si, err := db.NewSyncIterator(state)
+		if err != nil {
+			return nil, err
+		}
+		it.its[i], it.heads[i] = si, si.Next()
+	}
+	return it, nil
+}
+
+// Close closes the databases of all the shards.
+func (s *ShardedDbStore) Close() {
+	for _, db := range s.shards {
+		db.Close()
+	}
+}
+
+// shardedSyncIterator merges the sync iterators of the shards, which are
+// disjoint and ordered by key.
+type shardedSyncIterator struct {
+	its   []SyncIterator
+	heads []Key
+}
+
+func (it *shardedSyncIterator) Next() Key {
+	next := -1
+	for i, head := range it.heads {
+		if head != nil && (next < 0 || bytes.Compare(head, it.heads[next]) < 0) {
+			next = i
+		}
+	}
+	if next < 0 {
+		return nil
+	}
+	key := it.heads[next]
+	it.heads[next] = it.its[next].Next()
+	return key
+}
@@ -0,0 +1,124 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package storage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto/sha3"
+)
+
+func newTestShardChunk(i int) *Chunk {
+	data := make([]byte, 16)
+	binary.LittleEndian.PutUint64(data, 8)
+	binary.LittleEndian.PutUint64(data[8:], uint64(i))
+
+	hasher := sha3.NewKeccak256()
+	hasher.Write(data)
+	return &Chunk{Key: hasher.Sum(nil), SData: data, Size: 8}
+}
+
+// Tests that chunks are spread over the shards by their capacity, remain
+// retrievable after adding a shard and are synced as from a single store.
+func TestShardedDbStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bzz-storage-shards")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	shards := []*ShardParams{
+		{Path: filepath.Join(dir, "shard-0"), Capacity: 3000},
+		{Path: filepath.Join(dir, "shard-1"), Capacity: 1000},
+	}
+	s, err := NewShardedDbStore(MakeHashFunc(SHA3Hash), shards, defaultRadius)
+	if err != nil {
+		t.Fatalf("failed to create sharded store: %v", err)
+	}
+	var chunks []*Chunk
+	for i := 0; i < 1000; i++ {
+		chunk := newTestShardChunk(i)
+		s.Put(chunk)
+		chunks = append(chunks, chunk)
+	}
+	if have := s.Counter(); have != 1001 {
+		t.Errorf("storage counter mismatch: have %d, want %d", have, 1001)
+	}
+	// The larger shard must hold most of the chunks
+	large, small := s.Shards()[0].entryCnt, s.Shards()[1].entryCnt
+	if large+small != 1000 {
+		t.Fatalf("stored chunk count mismatch: have %d, want %d", large+small, 1000)
+	}
+	if large < 2*small {
+		t.Errorf("chunks not spread by capacity: %d in the large shard, %d in the small one", large, small)
+	}
+	// Syncing must return the chunks of all the shards in order of their keys
+	it, err := s.NewSyncIterator(DbSyncState{
+		Start: make(Key, 32),
+		Stop:  bytes.Repeat([]byte{0xff}, 32),
+		First: 0,
+		Last:  s.Counter(),
+	})
+	if err != nil {
+		t.Fatalf("failed to create sync iterator: %v", err)
+	}
+	var prev Key
+	synced := 0
+	for key := it.Next(); key != nil; key = it.Next() {
+		if prev != nil && bytes.Compare(prev, key) >= 0 {
+			t.Fatalf("sync keys out of order: %x after %x", key, prev)
+		}
+		prev = key
+		synced++
+	}
+	if synced != 1000 {
+		t.Errorf("synced chunk count mismatch: have %d, want %d", synced, 1000)
+	}
+	s.Close()
+
+	// Reopen with a new shard listed first, all chunks must be found
+	shards = append([]*ShardParams{{Path: filepath.Join(dir, "shard-2"), Capacity: 3000}}, shards...)
+	if s, err = NewShardedDbStore(MakeHashFunc(SHA3Hash), shards, defaultRadius); err != nil {
+		t.Fatalf("failed to reopen sharded store: %v", err)
+	}
+	defer s.Close()
+
+	if have := s.Counter(); have < 1001 {
+		t.Errorf("storage counter reset: have %d, want at least %d", have, 1001)
+	}
+	moved := 0
+	for _, chunk := range chunks {
+		stored, err := s.Get(chunk.Key)
+		if err != nil {
+			t.Fatalf("chunk %x not found: %v", chunk.Key, err)
+		}
+		if !bytes.Equal(stored.SData, chunk.SData) {
+			t.Fatalf("chunk %x data mismatch", chunk.Key)
+		}
+		if s.shard(chunk.Key) == 0 {
+			moved++
+		}
+	}
+	if moved == 0 || moved == len(chunks) {
+		t.Errorf("new shard takes %d of %d chunks", moved, len(chunks))
+	}
+}
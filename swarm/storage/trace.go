@@ -0,0 +1,94 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package storage
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ChunkTrace records the retrieval of a chunk.
+type ChunkTrace struct {
+	Key     Key           `json:"key"`
+	Local   bool          `json:"local"`          // found in the local store
+	Peer    string        `json:"peer,omitempty"` // peer delivering a remote chunk
+	Size    int           `json:"size"`           // bytes of chunk data
+	Latency time.Duration `json:"latency"`        // time until delivered, in nanoseconds
+	Error   string        `json:"error,omitempty"`
+}
+
+// Trace collects the chunks retrieved for a request, see DPA.Traced.
+type Trace struct {
+	start  time.Time
+	chunks []*ChunkTrace
+	lock   sync.Mutex
+}
+
+// TraceReport summarises a trace.
+type TraceReport struct {
+	Duration time.Duration `json:"duration"` // time since the trace started, in nanoseconds
+	Local    int           `json:"local"`    // chunks found in the local store
+	Remote   int           `json:"remote"`   // chunks retrieved from peers
+	Failed   int           `json:"failed"`   // chunks not found
+	Chunks   []*ChunkTrace `json:"chunks"`   // chunks in order of delivery
+}
+
+// NewTrace creates an empty trace.
+func NewTrace() *Trace {
+	return &Trace{start: time.Now()}
+}
+
+func (t *Trace) add(chunk *ChunkTrace) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.chunks = append(t.chunks, chunk)
+}
+
+// Report summarises the chunks retrieved so far.
+func (t *Trace) Report() *TraceReport {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	report := &TraceReport{
+		Duration: time.Since(t.start),
+		Chunks:   append([]*ChunkTrace{}, t.chunks...),
+	}
+	for _, chunk := range t.chunks {
+		switch {
+		case chunk.Error != "":
+			report.Failed++
+		case chunk.Local:
+			report.Local++
+		default:
+			report.Remote++
+		}
+	}
+	return report
+}
+
+// peerName returns the name of the peer a chunk was delivered by.
+func peerName(peer Peer) string {
+	if peer == nil {
+		return ""
+	}
+	if stringer, ok := peer.(fmt.Stringer); ok {
+		return stringer.String()
+	}
+	return fmt.Sprintf("%v", peer)
+}
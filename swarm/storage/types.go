@@ -157,6 +157,7 @@ type Chunk struct {
 	Req      *RequestStatus  // request Status needed by netStore
 	wg       *sync.WaitGroup // wg to synchronize
 	dbStored chan bool       // never remove a chunk from memStore before it is written to dbStore
+	trace    *ChunkTrace     // retrieval record of traced requests
 }
 
 func NewChunk(key Key, rs *RequestStatus) *Chunk {
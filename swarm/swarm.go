@@ -23,6 +23,7 @@ import (
 	"fmt"
 	"math/big"
 	"net"
+	"path/filepath"
 	"strings"
 	"time"
 	"unicode"
@@ -33,6 +34,7 @@ import (
 	"github.com/ethereum/go-ethereum/contracts/ens"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/event"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/metrics"
 	"github.com/ethereum/go-ethereum/node"
@@ -72,8 +74,10 @@ type Swarm struct {
 	privateKey  *ecdsa.PrivateKey
 	corsString  string
 	swapEnabled bool
-	lstore      *storage.LocalStore // local store, needs to store for releasing resources after node stopped
-	sfs         *fuse.SwarmFS       // need this to cleanup all the active mounts on node exit
+	lstore      *storage.LocalStore  // local store, needs to store for releasing resources after node stopped
+	sfs         *fuse.SwarmFS        // need this to cleanup all the active mounts on node exit
+	ensWatches  []event.Subscription // subscriptions keeping the ENS name indexes up to date
+	publish     *api.ContentWatcher  // watcher pinning the content published on chain, nil if disabled
 }
 
 type SwarmAPI struct {
@@ -147,6 +151,7 @@ func NewSwarm(ctx *node.ServiceContext, backend chequebook.Backend, config *api.
 	log.Debug(fmt.Sprintf("-> Local Access to Swarm"))
 	// Swarm Hash Merklised Chunking for Arbitrary-length Document/File storage
 	self.dpa = storage.NewDPA(dpaChunkStore, self.config.ChunkerParams)
+	self.dpa.Limiter = storage.NewLimiter(self.config.LimiterParams)
 	log.Debug(fmt.Sprintf("-> Content Store API"))
 
 	if len(config.EnsAPIs) > 0 {
@@ -157,18 +162,34 @@ func NewSwarm(ctx *node.ServiceContext, backend chequebook.Backend, config *api.
 			if err != nil {
 				return nil, err
 			}
+			if sub, err := r.WatchNames(); err != nil {
+				log.Warn("ENS name index not updated from registry events", "url", endpoint, "err", err)
+			} else {
+				self.ensWatches = append(self.ensWatches, sub)
+			}
 			opts = append(opts, api.MultiResolverOptionWithResolver(r, tld))
 		}
 		self.dns = api.NewMultiResolver(opts...)
 	}
 
 	self.api = api.NewApi(self.dpa, self.dns)
+	self.api.SetManifestParams(self.config.ManifestParams)
 	// Manifests for Smart Hosting
 	log.Debug(fmt.Sprintf("-> Web3 virtual server API"))
 
 	self.sfs = fuse.NewSwarmFS(self.api)
 	log.Debug("-> Initializing Fuse file system")
 
+	if config.PublishAPI != "" {
+		client, err := rpc.Dial(config.PublishAPI)
+		if err != nil {
+			return nil, fmt.Errorf("error connecting to publish API %s: %s", config.PublishAPI, err)
+		}
+		refresh := time.Duration(config.PublishRefresh) * time.Second
+		self.publish = api.NewContentWatcher(self.api, ethclient.NewClient(client), config.PublishContract, config.PublishFrom, refresh)
+		log.Debug(fmt.Sprintf("-> Published content watcher %v @ address %v", config.PublishAPI, config.PublishContract.Hex()))
+	}
+
 	return self, nil
 }
 
@@ -305,12 +326,21 @@ func (self *Swarm) Start(srv *p2p.Server) error {
 	self.dpa.Start()
 	log.Debug(fmt.Sprintf("Swarm DPA started"))
 
+	if self.publish != nil {
+		if err := self.publish.Start(); err != nil {
+			return fmt.Errorf("Unable to watch published content: %v", err)
+		}
+	}
+
 	// start swarm http proxy server
 	if self.config.Port != "" {
 		addr := net.JoinHostPort(self.config.ListenAddr, self.config.Port)
 		go httpapi.StartHttpServer(self.api, &httpapi.ServerConfig{
-			Addr:       addr,
-			CorsString: self.corsString,
+			Addr:          addr,
+			CorsString:    self.corsString,
+			CacheSize:     int64(self.config.HTTPCache) * 1024 * 1024,
+			CacheDiskSize: int64(self.config.HTTPCacheDisk) * 1024 * 1024,
+			CacheDir:      filepath.Join(self.config.Path, "httpcache"),
 		})
 		log.Info(fmt.Sprintf("Swarm http proxy started on %v", addr))
 
@@ -344,6 +374,9 @@ func (self *Swarm) updateGauges() {
 // implements the node.Service interface
 // stops all component services.
 func (self *Swarm) Stop() error {
+	if self.publish != nil {
+		self.publish.Stop()
+	}
 	self.dpa.Stop()
 	err := self.hive.Stop()
 	if ch := self.config.Swap.Chequebook(); ch != nil {
@@ -355,10 +388,22 @@ func (self *Swarm) Stop() error {
 		self.lstore.DbStore.Close()
 	}
 	self.sfs.Stop()
+	for _, sub := range self.ensWatches {
+		sub.Unsubscribe()
+	}
 	stopCounter.Inc(1)
 	return err
 }
 
+// Snapshot implements node.Snapshotter, adding the local chunk store to backups
+// of the node.
+func (self *Swarm) Snapshot(backup *node.Backup) error {
+	if self.lstore == nil {
+		return nil
+	}
+	return self.lstore.Backup(backup.CopyLevelDB)
+}
+
 // implements the node.Service interface
 func (self *Swarm) Protocols() []p2p.Protocol {
 	proto, err := network.Bzz(self.depo, self.backend, self.hive, self.dbAccess, self.config.Swap, self.config.SyncParams, self.config.NetworkId)
@@ -371,7 +416,9 @@ func (self *Swarm) Protocols() []p2p.Protocol {
 // implements node.Service
 // Apis returns the RPC Api descriptors the Swarm implementation offers
 func (self *Swarm) APIs() []rpc.API {
-	return []rpc.API{
+	netStore, _ := self.storage.(*storage.NetStore)
+
+	apis := []rpc.API{
 		// public APIs
 		{
 			Namespace: "bzz",
@@ -379,6 +426,12 @@ func (self *Swarm) APIs() []rpc.API {
 			Service:   &Info{self.config, chequebook.ContractParams},
 			Public:    true,
 		},
+		{
+			Namespace: "bzz",
+			Version:   "0.1",
+			Service:   api.NewMonitor(netStore, self.dpa, self.hive),
+			Public:    true,
+		},
 		// admin APIs
 		{
 			Namespace: "bzz",
@@ -414,6 +467,15 @@ func (self *Swarm) APIs() []rpc.API {
 		},
 		// {Namespace, Version, api.NewAdmin(self), false},
 	}
+	if self.publish != nil {
+		apis = append(apis, rpc.API{
+			Namespace: "bzz",
+			Version:   "0.1",
+			Service:   api.NewPublishAPI(self.publish),
+			Public:    true,
+		})
+	}
+	return apis
 }
 
 func (self *Swarm) Api() *api.Api {
@@ -0,0 +1,82 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package trie
+
+import (
+	"math"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/hashicorp/golang-lru/simplelru"
+)
+
+var (
+	cleanHitMeter   = metrics.NewRegisteredMeter("trie/cleancache/hit", nil)
+	cleanMissMeter  = metrics.NewRegisteredMeter("trie/cleancache/miss", nil)
+	cleanWriteMeter = metrics.NewRegisteredMeter("trie/cleancache/write", nil)
+)
+
+// cleanCache is a size limited LRU cache of trie nodes already persisted to
+// disk, saving database reads for the hot parts of the tries.
+type cleanCache struct {
+	nodes *simplelru.LRU
+	size  common.StorageSize // Storage size of the cached nodes
+	limit common.StorageSize // Storage size to evict nodes above
+	lock  sync.Mutex
+}
+
+// newCleanCache creates a clean node cache holding at most the given number of
+// megabytes worth of nodes.
+func newCleanCache(megabytes int) *cleanCache {
+	c := &cleanCache{limit: common.StorageSize(megabytes) * 1024 * 1024}
+	c.nodes, _ = simplelru.NewLRU(math.MaxInt32, func(key, value interface{}) {
+		c.size -= common.StorageSize(common.HashLength + len(value.([]byte)))
+	})
+	return c
+}
+
+// get retrieves a node from the cache, nil if it's not cached.
+func (c *cleanCache) get(hash common.Hash) []byte {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if blob, ok := c.nodes.Get(hash); ok {
+		cleanHitMeter.Mark(1)
+		return blob.([]byte)
+	}
+	cleanMissMeter.Mark(1)
+	return nil
+}
+
+// set caches a node, evicting the least recently used ones if the cache grew
+// beyond its limit.
+func (c *cleanCache) set(hash common.Hash, blob []byte) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.nodes.Contains(hash) {
+		return
+	}
+	c.nodes.Add(hash, blob)
+	c.size += common.StorageSize(common.HashLength + len(blob))
+	cleanWriteMeter.Mark(int64(len(blob)))
+
+	for c.size > c.limit {
+		c.nodes.RemoveOldest()
+	}
+}
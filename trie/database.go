@@ -45,6 +45,7 @@ type DatabaseReader interface {
 // periodically flush a couple tries to disk, garbage collecting the remainder.
 type Database struct {
 	diskdb ethdb.Database // Persistent storage for matured trie nodes
+	cleans *cleanCache    // Cache of nodes already persisted (nil = disabled)
 
 	nodes     map[common.Hash]*cachedNode // Data and references relationships of a node
 	preimages map[common.Hash][]byte      // Preimages of nodes from the secure trie
@@ -71,8 +72,20 @@ type cachedNode struct {
 // NewDatabase creates a new trie database to store ephemeral trie content before
 // its written out to disk or garbage collected.
 func NewDatabase(diskdb ethdb.Database) *Database {
+	return NewDatabaseWithCache(diskdb, 0)
+}
+
+// NewDatabaseWithCache creates a new trie database to store ephemeral trie content
+// before its written out to disk or garbage collected. It also acts as a read
+// cache of the given number of megabytes for nodes loaded from disk.
+func NewDatabaseWithCache(diskdb ethdb.Database, cache int) *Database {
+	var cleans *cleanCache
+	if cache > 0 {
+		cleans = newCleanCache(cache)
+	}
 	return &Database{
 		diskdb: diskdb,
+		cleans: cleans,
 		nodes: map[common.Hash]*cachedNode{
 			{}: {children: make(map[common.Hash]int)},
 		},
@@ -129,8 +142,17 @@ func (db *Database) Node(hash common.Hash) ([]byte, error) {
 	if node != nil {
 		return node.blob, nil
 	}
+	if db.cleans != nil {
+		if blob := db.cleans.get(hash); blob != nil {
+			return blob, nil
+		}
+	}
 	// Content unavailable in memory, attempt to retrieve from disk
-	return db.diskdb.Get(hash[:])
+	blob, err := db.diskdb.Get(hash[:])
+	if err == nil && db.cleans != nil {
+		db.cleans.set(hash, blob)
+	}
+	return blob, err
 }
 
 // preimage retrieves a cached trie node pre-image from memory. If it cannot be
@@ -267,7 +289,7 @@ func (db *Database) Commit(node common.Hash, report bool) error {
 	}
 	// Move the trie itself into the batch, flushing if enough data is accumulated
 	nodes, storage := len(db.nodes), db.nodesSize+db.preimagesSize
-	if err := db.commit(node, batch); err != nil {
+	if err := db.commit(node, batch, true); err != nil {
 		log.Error("Failed to commit trie from trie database", "err", err)
 		db.lock.RUnlock()
 		return err
@@ -302,15 +324,53 @@ func (db *Database) Commit(node common.Hash, report bool) error {
 	return nil
 }
 
-// commit is the private locked version of Commit.
-func (db *Database) commit(hash common.Hash, batch ethdb.Batch) error {
+// CommitTo moves a particular node and all its children, along with all the
+// pre-images accumulated up to this point, into an external batch without
+// writing it out, allowing them to be persisted atomically with other data.
+//
+// The committed data is kept in memory until the returned function is called,
+// which must only happen after the batch was successfully written.
+func (db *Database) CommitTo(node common.Hash, batch ethdb.Batch) (func(), error) {
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+
+	// Only the flushed preimages may be dropped on release, others may be inserted
+	// in the meantime
+	flushed := make([]common.Hash, 0, len(db.preimages))
+	for hash, preimage := range db.preimages {
+		if err := batch.Put(db.secureKey(hash[:]), preimage); err != nil {
+			return nil, err
+		}
+		flushed = append(flushed, hash)
+	}
+	if err := db.commit(node, batch, false); err != nil {
+		return nil, err
+	}
+	release := func() {
+		db.lock.Lock()
+		defer db.lock.Unlock()
+
+		for _, hash := range flushed {
+			if preimage, ok := db.preimages[hash]; ok {
+				delete(db.preimages, hash)
+				db.preimagesSize -= common.StorageSize(common.HashLength + len(preimage))
+			}
+		}
+		db.uncache(node)
+	}
+	return release, nil
+}
+
+// commit is the private locked version of Commit, optionally flushing the batch
+// whenever it grows large enough.
+func (db *Database) commit(hash common.Hash, batch ethdb.Batch, flush bool) error {
 	// If the node does not exist, it's a previously committed node
 	node, ok := db.nodes[hash]
 	if !ok {
 		return nil
 	}
 	for child := range node.children {
-		if err := db.commit(child, batch); err != nil {
+		if err := db.commit(child, batch, flush); err != nil {
 			return err
 		}
 	}
@@ -318,7 +378,7 @@ func (db *Database) commit(hash common.Hash, batch ethdb.Batch) error {
 		return err
 	}
 	// If we've reached an optimal match size, commit and start over
-	if batch.ValueSize() >= ethdb.IdealBatchSize {
+	if flush && batch.ValueSize() >= ethdb.IdealBatchSize {
 		if err := batch.Write(); err != nil {
 			return err
 		}
@@ -341,6 +401,10 @@ func (db *Database) uncache(hash common.Hash) {
 	for child := range node.children {
 		db.uncache(child)
 	}
+	// The node was just persisted, keep it around as a clean one
+	if db.cleans != nil {
+		db.cleans.set(hash, node.blob)
+	}
 	delete(db.nodes, hash)
 	db.nodesSize -= common.StorageSize(common.HashLength + len(node.blob))
 }
@@ -0,0 +1,144 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package trie
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethdb"
+)
+
+// Tests that committing a trie into an external batch keeps it cached until the
+// batch is written and the commit released.
+func TestDatabaseCommitTo(t *testing.T) {
+	diskdb, _ := ethdb.NewMemDatabase()
+	triedb := NewDatabase(diskdb)
+
+	trie, _ := New(common.Hash{}, triedb)
+	for i := byte(0); i < 100; i++ {
+		trie.Update([]byte{i}, []byte{i, i})
+	}
+	root, _ := trie.Commit(nil)
+
+	batch := diskdb.NewBatch()
+	release, err := triedb.CommitTo(root, batch)
+	if err != nil {
+		t.Fatalf("failed to commit trie: %v", err)
+	}
+	if diskdb.Len() != 0 {
+		t.Fatalf("trie written before the batch: %d entries", diskdb.Len())
+	}
+	if node, _ := triedb.Node(root); node == nil {
+		t.Fatalf("trie root uncached before the batch was written")
+	}
+	if err := batch.Write(); err != nil {
+		t.Fatalf("failed to write batch: %v", err)
+	}
+	release()
+
+	if size := triedb.Size(); size != 0 {
+		t.Errorf("committed trie still cached: %v", size)
+	}
+	trie, err = New(root, NewDatabase(diskdb))
+	if err != nil {
+		t.Fatalf("failed to reopen trie: %v", err)
+	}
+	for i := byte(0); i < 100; i++ {
+		if val := trie.Get([]byte{i}); len(val) != 2 || val[0] != i {
+			t.Errorf("key %x: value mismatch: have %x", i, val)
+		}
+	}
+}
+
+// Tests that releasing a commit into an external batch only drops the preimages
+// flushed into it, keeping the ones inserted in the meantime.
+func TestDatabaseCommitToPreimages(t *testing.T) {
+	diskdb, _ := ethdb.NewMemDatabase()
+	triedb := NewDatabase(diskdb)
+
+	flushed, pending := common.Hash{1}, common.Hash{2}
+	triedb.lock.Lock()
+	triedb.insertPreimage(flushed, []byte{0x01})
+	triedb.lock.Unlock()
+
+	batch := diskdb.NewBatch()
+	release, err := triedb.CommitTo(common.Hash{}, batch)
+	if err != nil {
+		t.Fatalf("failed to commit: %v", err)
+	}
+	triedb.lock.Lock()
+	triedb.insertPreimage(pending, []byte{0x02, 0x02})
+	triedb.lock.Unlock()
+
+	if err := batch.Write(); err != nil {
+		t.Fatalf("failed to write batch: %v", err)
+	}
+	release()
+
+	if _, err := diskdb.Get(triedb.secureKey(flushed[:])); err != nil {
+		t.Errorf("flushed preimage not persisted: %v", err)
+	}
+	if _, ok := triedb.preimages[flushed]; ok {
+		t.Errorf("flushed preimage still cached")
+	}
+	if _, ok := triedb.preimages[pending]; !ok {
+		t.Errorf("pending preimage dropped")
+	}
+	if want := common.StorageSize(common.HashLength + 2); triedb.preimagesSize != want {
+		t.Errorf("preimage size mismatch: have %v, want %v", triedb.preimagesSize, want)
+	}
+}
+
+// Tests that nodes read from disk or flushed to it are served from the clean
+// cache, and that the cache stays within its size limit.
+func TestDatabaseCleanCache(t *testing.T) {
+	diskdb, _ := ethdb.NewMemDatabase()
+	triedb := NewDatabaseWithCache(diskdb, 1)
+
+	trie, _ := New(common.Hash{}, triedb)
+	for i := byte(0); i < 100; i++ {
+		trie.Update([]byte{i}, []byte{i, i})
+	}
+	root, _ := trie.Commit(nil)
+	if err := triedb.Commit(root, false); err != nil {
+		t.Fatalf("failed to commit trie: %v", err)
+	}
+	// Flushed nodes must be readable without the disk
+	for _, key := range diskdb.Keys() {
+		diskdb.Delete(key)
+	}
+	if blob, err := triedb.Node(root); err != nil || len(blob) == 0 {
+		t.Fatalf("flushed root not cached: %v", err)
+	}
+	// Nodes read from disk must be cached, evicting the oldest ones when full
+	for i := 0; i < 2048; i++ {
+		diskdb.Put(common.BytesToHash([]byte{byte(i >> 8), byte(i)}).Bytes(), make([]byte, 1024))
+	}
+	for i := 0; i < 2048; i++ {
+		triedb.Node(common.BytesToHash([]byte{byte(i >> 8), byte(i)}))
+	}
+	if size := triedb.cleans.size; size > triedb.cleans.limit {
+		t.Errorf("clean cache size above limit: have %v, limit %v", size, triedb.cleans.limit)
+	}
+	if blob := triedb.cleans.get(common.BytesToHash([]byte{0x07, 0xff})); blob == nil {
+		t.Errorf("recently read node not cached")
+	}
+	if blob := triedb.cleans.get(root); blob != nil {
+		t.Errorf("least recently used node not evicted")
+	}
+}